@@ -4,6 +4,7 @@ module "blob_storage_backup" {
   vault                           = azurerm_data_protection_backup_vault.backup_vault
   backup_name                     = each.value.backup_name
   retention_period                = each.value.retention_period
+  retention_rules                 = each.value.retention_rules
   backup_intervals                = each.value.backup_intervals
   storage_account_id              = each.value.storage_account_id
   storage_account_containers      = each.value.storage_account_containers
@@ -14,12 +15,29 @@ module "blob_storage_backup" {
 
 }
 
+module "data_lake_storage_backup" {
+  for_each                        = var.data_lake_storage_backups
+  source                          = "./modules/backup/data_lake_storage"
+  vault                           = azurerm_data_protection_backup_vault.backup_vault
+  backup_name                     = each.value.backup_name
+  retention_period                = each.value.retention_period
+  retention_rules                 = each.value.retention_rules
+  backup_intervals                = each.value.backup_intervals
+  storage_account_id              = each.value.storage_account_id
+  storage_account_containers      = each.value.storage_account_containers
+  backup_policy_naming_template   = each.value.backup_policy_naming_template
+  backup_instance_naming_template = each.value.backup_instance_naming_template
+  time_zone                       = try(each.value.time_zone, null)
+
+}
+
 module "managed_disk_backup" {
   for_each                          = var.managed_disk_backups
   source                            = "./modules/backup/managed_disk"
   vault                             = azurerm_data_protection_backup_vault.backup_vault
   backup_name                       = each.value.backup_name
   retention_period                  = each.value.retention_period
+  retention_rules                   = each.value.retention_rules
   backup_intervals                  = each.value.backup_intervals
   managed_disk_id                   = each.value.managed_disk_id
   managed_disk_resource_group       = each.value.managed_disk_resource_group
@@ -35,6 +53,7 @@ module "postgresql_flexible_server_backup" {
   vault                             = azurerm_data_protection_backup_vault.backup_vault
   backup_name                       = each.value.backup_name
   retention_period                  = each.value.retention_period
+  retention_rules                   = each.value.retention_rules
   backup_intervals                  = each.value.backup_intervals
   server_id                         = each.value.server_id
   server_resource_group_id          = each.value.server_resource_group_id
@@ -43,3 +62,68 @@ module "postgresql_flexible_server_backup" {
   backup_instance_naming_template   = each.value.backup_instance_naming_template
 
 }
+
+module "mysql_flexible_server_backup" {
+  for_each                          = var.mysql_flexible_server_backups
+  source                            = "./modules/backup/mysql_flexible_server"
+  vault                             = azurerm_data_protection_backup_vault.backup_vault
+  backup_name                       = each.value.backup_name
+  retention_period                  = each.value.retention_period
+  retention_rules                   = each.value.retention_rules
+  backup_intervals                  = each.value.backup_intervals
+  server_id                         = each.value.server_id
+  server_resource_group_id          = each.value.server_resource_group_id
+  assign_resource_group_level_roles = each.key == keys(var.mysql_flexible_server_backups)[0] ? true : false
+  backup_policy_naming_template     = each.value.backup_policy_naming_template
+  backup_instance_naming_template   = each.value.backup_instance_naming_template
+
+}
+
+module "aks_cluster_backup" {
+  for_each                          = var.aks_cluster_backups
+  source                            = "./modules/backup/aks_cluster"
+  vault                             = azurerm_data_protection_backup_vault.backup_vault
+  backup_name                       = each.value.backup_name
+  retention_period                  = each.value.retention_period
+  retention_rules                   = each.value.retention_rules
+  backup_intervals                  = each.value.backup_intervals
+  cluster_id                        = each.value.cluster_id
+  cluster_identity_principal_id     = each.value.cluster_identity_principal_id
+  snapshot_resource_group           = each.value.snapshot_resource_group
+  storage_account_id                = each.value.storage_account_id
+  storage_account_container         = each.value.storage_account_container
+  included_namespaces               = each.value.included_namespaces
+  excluded_namespaces               = each.value.excluded_namespaces
+  assign_resource_group_level_roles = each.key == keys(var.aks_cluster_backups)[0] ? true : false
+  backup_policy_naming_template     = each.value.backup_policy_naming_template
+  backup_instance_naming_template   = each.value.backup_instance_naming_template
+
+}
+
+module "virtual_machine_backup" {
+  for_each                      = var.vm_backups
+  source                        = "./modules/backup/virtual_machine"
+  vault                         = azurerm_recovery_services_vault.recovery_services_vault[0]
+  backup_name                   = each.value.backup_name
+  retention_period              = each.value.retention_period
+  backup_intervals              = each.value.backup_intervals
+  vm_id                         = each.value.vm_id
+  backup_policy_naming_template = each.value.backup_policy_naming_template
+  retention_rules               = each.value.retention_rules
+
+}
+
+module "file_share_backup" {
+  for_each                      = var.file_share_backups
+  source                        = "./modules/backup/file_share"
+  vault                         = azurerm_recovery_services_vault.recovery_services_vault[0]
+  backup_name                   = each.value.backup_name
+  retention_period              = each.value.retention_period
+  backup_intervals              = each.value.backup_intervals
+  storage_account_id            = each.value.storage_account_id
+  file_share_name               = each.value.file_share_name
+  backup_policy_naming_template = each.value.backup_policy_naming_template
+  retention_rules               = each.value.retention_rules
+
+  depends_on = [azurerm_backup_container_storage_account.file_share]
+}
@@ -0,0 +1,30 @@
+data "azurerm_client_config" "current" {}
+
+resource "azurerm_kubernetes_cluster_extension" "backup_extension" {
+  name           = "azure-aks-backup"
+  cluster_id     = var.cluster_id
+  extension_type = "Microsoft.DataProtection.Kubernetes"
+  release_train  = "stable"
+
+  configuration_settings = {
+    "configuration.backupStorageLocation.bucket"                = var.storage_account_container
+    "configuration.backupStorageLocation.config.resourceGroup"  = local.storage_account_resource_group_name
+    "configuration.backupStorageLocation.config.storageAccount" = local.storage_account_name
+    "configuration.backupStorageLocation.config.subscriptionId" = local.storage_account_subscription_id
+    "credentials.tenantId"                                      = data.azurerm_client_config.current.tenant_id
+  }
+}
+
+resource "azurerm_role_assignment" "role_assignment_extension_storage_blob_data_contributor" {
+  scope                = var.storage_account_id
+  role_definition_name = "Storage Blob Data Contributor"
+  principal_id         = azurerm_kubernetes_cluster_extension.backup_extension.aks_assigned_identity[0].principal_id
+  principal_type       = "ServicePrincipal"
+}
+
+resource "azurerm_kubernetes_cluster_trusted_access_role_binding" "trusted_access" {
+  name                  = local.trusted_access_role_binding_name
+  kubernetes_cluster_id = var.cluster_id
+  source_resource_id    = var.vault.id
+  roles                 = ["Microsoft.DataProtection/backupVaults/backup-operator"]
+}
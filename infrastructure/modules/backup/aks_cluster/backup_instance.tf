@@ -0,0 +1,46 @@
+resource "azurerm_role_assignment" "role_assignment_snapshot_reader" {
+  count                = var.assign_resource_group_level_roles == true ? 1 : 0
+  scope                = var.snapshot_resource_group.id
+  role_definition_name = "Reader"
+  principal_id         = var.vault.identity[0].principal_id
+  principal_type       = "ServicePrincipal"
+}
+
+resource "azurerm_role_assignment" "role_assignment_cluster_reader" {
+  scope                = var.cluster_id
+  role_definition_name = "Reader"
+  principal_id         = var.vault.identity[0].principal_id
+  principal_type       = "ServicePrincipal"
+}
+
+resource "azurerm_role_assignment" "role_assignment_cluster_snapshot_contributor" {
+  scope                = var.snapshot_resource_group.id
+  role_definition_name = "Contributor"
+  principal_id         = var.cluster_identity_principal_id
+  principal_type       = "ServicePrincipal"
+}
+
+resource "azurerm_data_protection_backup_instance_kubernetes_cluster" "backup_instance" {
+  name                         = local.backup_instance_name
+  vault_id                     = var.vault.id
+  location                     = var.vault.location
+  kubernetes_cluster_id        = var.cluster_id
+  snapshot_resource_group_name = var.snapshot_resource_group.name
+  backup_policy_id             = azurerm_data_protection_backup_policy_kubernetes_cluster.backup_policy.id
+
+  backup_datasource_parameters {
+    included_namespaces              = var.included_namespaces
+    excluded_namespaces              = var.excluded_namespaces
+    cluster_scoped_resources_enabled = true
+    volume_snapshot_enabled          = true
+  }
+
+  depends_on = [
+    azurerm_kubernetes_cluster_extension.backup_extension,
+    azurerm_kubernetes_cluster_trusted_access_role_binding.trusted_access,
+    azurerm_role_assignment.role_assignment_extension_storage_blob_data_contributor,
+    azurerm_role_assignment.role_assignment_snapshot_reader,
+    azurerm_role_assignment.role_assignment_cluster_reader,
+    azurerm_role_assignment.role_assignment_cluster_snapshot_contributor
+  ]
+}
@@ -0,0 +1,31 @@
+locals {
+
+  resource_type = "aks"
+
+  # Render names using templates
+  backup_policy_name = replace(
+    replace(
+      replace(var.backup_policy_naming_template, "{resource_abbreviation}", "bkpol"),
+      "{resource_type}", local.resource_type
+    ),
+    "{backup_name}", var.backup_name
+  )
+
+  backup_instance_name = replace(
+    replace(
+      replace(var.backup_instance_naming_template, "{resource_abbreviation}", "bkinst"),
+      "{resource_type}", local.resource_type
+    ),
+    "{backup_name}", var.backup_name
+  )
+
+  # The backup extension is configured with the parts of the storage account id
+  storage_account_id_segments         = split("/", var.storage_account_id)
+  storage_account_subscription_id     = local.storage_account_id_segments[2]
+  storage_account_resource_group_name = local.storage_account_id_segments[4]
+  storage_account_name                = local.storage_account_id_segments[8]
+
+  # Trusted access role binding names are limited to 24 characters, and must be unique per cluster
+  trusted_access_role_binding_name = "bkp-${substr(sha1(var.vault.id), 0, 16)}"
+
+}
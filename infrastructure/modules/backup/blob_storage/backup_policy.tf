@@ -5,6 +5,28 @@ resource "azurerm_data_protection_backup_policy_blob_storage" "backup_policy" {
   backup_repeating_time_intervals  = var.backup_intervals
   time_zone                        = var.time_zone
 
+  # Rules are prioritised in the order given, and ahead of the daily-retention rule (priority 9),
+  # which is why variables.tf allows no more than 8 of them when that rule is enabled
+  dynamic "retention_rule" {
+    for_each = var.retention_rules
+    content {
+      name     = retention_rule.value.name
+      priority = retention_rule.key + 1
+
+      criteria {
+        absolute_criteria = retention_rule.value.absolute_criteria
+        days_of_week      = retention_rule.value.days_of_week
+        weeks_of_month    = retention_rule.value.weeks_of_month
+        months_of_year    = retention_rule.value.months_of_year
+      }
+
+      life_cycle {
+        data_store_type = "VaultStore"
+        duration        = retention_rule.value.duration
+      }
+    }
+  }
+
   dynamic "retention_rule" {
     for_each = coalesce(var.enable_daily_retention_rule, false) ? [1] : []
     content {
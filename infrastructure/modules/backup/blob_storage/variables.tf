@@ -41,3 +41,15 @@ variable "enable_daily_retention_rule" {
   type    = bool
   default = false
 }
+
+variable "retention_rules" {
+  type = list(object({
+    name              = string
+    duration          = string
+    absolute_criteria = optional(string)
+    days_of_week      = optional(list(string))
+    weeks_of_month    = optional(list(string))
+    months_of_year    = optional(list(string))
+  }))
+  default = []
+}
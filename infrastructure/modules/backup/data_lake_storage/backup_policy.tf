@@ -0,0 +1,19 @@
+resource "azurerm_data_protection_backup_policy_data_lake_storage" "backup_policy" {
+  name                            = local.backup_policy_name
+  data_protection_backup_vault_id = var.vault.id
+  backup_schedule                 = var.backup_intervals
+  default_retention_duration      = var.retention_period
+  time_zone                       = var.time_zone
+
+  dynamic "retention_rule" {
+    for_each = var.retention_rules
+    content {
+      name              = retention_rule.value.name
+      duration          = retention_rule.value.duration
+      absolute_criteria = retention_rule.value.absolute_criteria
+      days_of_week      = retention_rule.value.days_of_week
+      weeks_of_month    = retention_rule.value.weeks_of_month
+      months_of_year    = retention_rule.value.months_of_year
+    }
+  }
+}
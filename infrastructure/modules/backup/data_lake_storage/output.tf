@@ -0,0 +1,7 @@
+output "backup_policy" {
+  value = azurerm_data_protection_backup_policy_data_lake_storage.backup_policy
+}
+
+output "backup_instance" {
+  value = azurerm_data_protection_backup_instance_data_lake_storage.backup_instance
+}
\ No newline at end of file
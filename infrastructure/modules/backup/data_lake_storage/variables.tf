@@ -0,0 +1,50 @@
+variable "vault" {
+  type = any
+}
+
+variable "backup_name" {
+  type = string
+}
+
+variable "retention_period" {
+  type = string
+}
+
+variable "backup_intervals" {
+  type = list(string)
+}
+
+variable "storage_account_id" {
+  type = string
+}
+
+variable "storage_account_containers" {
+  type = list(string)
+}
+
+variable "backup_policy_naming_template" {
+  type    = string
+  default = "{resource_abbreviation}-{resource_type}-{backup_name}"
+}
+
+variable "backup_instance_naming_template" {
+  type    = string
+  default = "{resource_abbreviation}-{resource_type}-{backup_name}"
+}
+
+variable "time_zone" {
+  type    = string
+  default = null
+}
+
+variable "retention_rules" {
+  type = list(object({
+    name              = string
+    duration          = string
+    absolute_criteria = optional(string)
+    days_of_week      = optional(list(string))
+    weeks_of_month    = optional(list(string))
+    months_of_year    = optional(list(string))
+  }))
+  default = []
+}
@@ -0,0 +1,43 @@
+resource "azurerm_backup_policy_file_share" "backup_policy" {
+  name                = local.backup_policy_name
+  resource_group_name = var.vault.resource_group_name
+  recovery_vault_name = var.vault.name
+  timezone            = "UTC"
+
+  backup {
+    frequency = "Daily"
+    time      = local.backup_time
+  }
+
+  retention_daily {
+    count = local.retention_days
+  }
+
+  # The weekly, monthly and yearly recovery points are taken from the daily backup on the given days
+  dynamic "retention_weekly" {
+    for_each = var.retention_rules.weekly != null ? [var.retention_rules.weekly] : []
+    content {
+      count    = retention_weekly.value.count
+      weekdays = retention_weekly.value.weekdays
+    }
+  }
+
+  dynamic "retention_monthly" {
+    for_each = var.retention_rules.monthly != null ? [var.retention_rules.monthly] : []
+    content {
+      count    = retention_monthly.value.count
+      weekdays = retention_monthly.value.weekdays
+      weeks    = retention_monthly.value.weeks
+    }
+  }
+
+  dynamic "retention_yearly" {
+    for_each = var.retention_rules.yearly != null ? [var.retention_rules.yearly] : []
+    content {
+      count    = retention_yearly.value.count
+      weekdays = retention_yearly.value.weekdays
+      weeks    = retention_yearly.value.weeks
+      months   = retention_yearly.value.months
+    }
+  }
+}
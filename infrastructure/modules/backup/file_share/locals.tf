@@ -0,0 +1,20 @@
+locals {
+
+  resource_type = "afs"
+
+  # Render names using templates
+  backup_policy_name = replace(
+    replace(
+      replace(var.backup_policy_naming_template, "{resource_abbreviation}", "bkpol"),
+      "{resource_type}", local.resource_type
+    ),
+    "{backup_name}", var.backup_name
+  )
+
+  # Recovery services policies run once a day, at the time of day that the interval starts (in UTC)
+  backup_time = formatdate("hh:mm", timeadd(split("/", var.backup_intervals[0])[1], "0s"))
+
+  # Recovery services policies keep a number of daily recovery points, rather than a duration
+  retention_days = tonumber(regex("^P([0-9]+)D$", var.retention_period)[0])
+
+}
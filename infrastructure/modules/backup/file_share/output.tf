@@ -0,0 +1,7 @@
+output "backup_policy" {
+  value = azurerm_backup_policy_file_share.backup_policy
+}
+
+output "protected_item" {
+  value = azurerm_backup_protected_file_share.protected_item
+}
\ No newline at end of file
@@ -0,0 +1,7 @@
+resource "azurerm_backup_protected_file_share" "protected_item" {
+  resource_group_name       = var.vault.resource_group_name
+  recovery_vault_name       = var.vault.name
+  source_storage_account_id = var.storage_account_id
+  source_file_share_name    = var.file_share_name
+  backup_policy_id          = azurerm_backup_policy_file_share.backup_policy.id
+}
@@ -0,0 +1,49 @@
+variable "vault" {
+  type = any
+}
+
+variable "backup_name" {
+  type = string
+}
+
+variable "retention_period" {
+  type = string
+}
+
+variable "backup_intervals" {
+  type = list(string)
+}
+
+variable "storage_account_id" {
+  type = string
+}
+
+variable "file_share_name" {
+  type = string
+}
+
+variable "backup_policy_naming_template" {
+  type    = string
+  default = "{resource_abbreviation}-{resource_type}-{backup_name}"
+}
+
+variable "retention_rules" {
+  type = object({
+    weekly = optional(object({
+      count    = number
+      weekdays = list(string)
+    }))
+    monthly = optional(object({
+      count    = number
+      weekdays = list(string)
+      weeks    = list(string)
+    }))
+    yearly = optional(object({
+      count    = number
+      weekdays = list(string)
+      weeks    = list(string)
+      months   = list(string)
+    }))
+  })
+  default = {}
+}
@@ -3,4 +3,17 @@ resource "azurerm_data_protection_backup_policy_disk" "backup_policy" {
   vault_id                        = var.vault.id
   default_retention_duration      = var.retention_period
   backup_repeating_time_intervals = var.backup_intervals
+
+  dynamic "retention_rule" {
+    for_each = var.retention_rules
+    content {
+      name     = retention_rule.value.name
+      duration = retention_rule.value.duration
+      priority = retention_rule.key + 1
+
+      criteria {
+        absolute_criteria = retention_rule.value.absolute_criteria
+      }
+    }
+  }
 }
@@ -0,0 +1,32 @@
+resource "azurerm_data_protection_backup_policy_mysql_flexible_server" "backup_policy" {
+  name                            = local.backup_policy_name
+  vault_id                        = var.vault.id
+  backup_repeating_time_intervals = var.backup_intervals
+
+  default_retention_rule {
+    life_cycle {
+      duration        = var.retention_period
+      data_store_type = "VaultStore"
+    }
+  }
+
+  dynamic "retention_rule" {
+    for_each = var.retention_rules
+    content {
+      name     = retention_rule.value.name
+      priority = retention_rule.key + 1
+
+      criteria {
+        absolute_criteria = retention_rule.value.absolute_criteria
+        days_of_week      = retention_rule.value.days_of_week
+        weeks_of_month    = retention_rule.value.weeks_of_month
+        months_of_year    = retention_rule.value.months_of_year
+      }
+
+      life_cycle {
+        duration        = retention_rule.value.duration
+        data_store_type = "VaultStore"
+      }
+    }
+  }
+}
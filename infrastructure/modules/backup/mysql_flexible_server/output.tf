@@ -0,0 +1,7 @@
+output "backup_policy" {
+  value = azurerm_data_protection_backup_policy_mysql_flexible_server.backup_policy
+}
+
+output "backup_instance" {
+  value = azurerm_data_protection_backup_instance_mysql_flexible_server.backup_instance
+}
\ No newline at end of file
@@ -0,0 +1,49 @@
+variable "vault" {
+  type = any
+}
+
+variable "backup_name" {
+  type = string
+}
+
+variable "retention_period" {
+  type = string
+}
+
+variable "backup_intervals" {
+  type = list(string)
+}
+
+variable "server_id" {
+  type = string
+}
+
+variable "server_resource_group_id" {
+  type = string
+}
+
+variable "assign_resource_group_level_roles" {
+  type = bool
+}
+
+variable "backup_policy_naming_template" {
+  type    = string
+  default = "{resource_abbreviation}-{resource_type}-{backup_name}"
+}
+
+variable "backup_instance_naming_template" {
+  type    = string
+  default = "{resource_abbreviation}-{resource_type}-{backup_name}"
+}
+
+variable "retention_rules" {
+  type = list(object({
+    name              = string
+    duration          = string
+    absolute_criteria = optional(string)
+    days_of_week      = optional(list(string))
+    weeks_of_month    = optional(list(string))
+    months_of_year    = optional(list(string))
+  }))
+  default = []
+}
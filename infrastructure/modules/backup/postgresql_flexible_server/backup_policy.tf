@@ -9,4 +9,24 @@ resource "azurerm_data_protection_backup_policy_postgresql_flexible_server" "bac
       data_store_type = "VaultStore"
     }
   }
+
+  dynamic "retention_rule" {
+    for_each = var.retention_rules
+    content {
+      name     = retention_rule.value.name
+      priority = retention_rule.key + 1
+
+      criteria {
+        absolute_criteria = retention_rule.value.absolute_criteria
+        days_of_week      = retention_rule.value.days_of_week
+        weeks_of_month    = retention_rule.value.weeks_of_month
+        months_of_year    = retention_rule.value.months_of_year
+      }
+
+      life_cycle {
+        duration        = retention_rule.value.duration
+        data_store_type = "VaultStore"
+      }
+    }
+  }
 }
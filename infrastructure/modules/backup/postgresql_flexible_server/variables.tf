@@ -35,3 +35,15 @@ variable "backup_instance_naming_template" {
   type    = string
   default = "{resource_abbreviation}-{resource_type}-{backup_name}"
 }
+
+variable "retention_rules" {
+  type = list(object({
+    name              = string
+    duration          = string
+    absolute_criteria = optional(string)
+    days_of_week      = optional(list(string))
+    weeks_of_month    = optional(list(string))
+    months_of_year    = optional(list(string))
+  }))
+  default = []
+}
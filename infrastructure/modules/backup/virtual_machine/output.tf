@@ -0,0 +1,7 @@
+output "backup_policy" {
+  value = azurerm_backup_policy_vm.backup_policy
+}
+
+output "protected_item" {
+  value = azurerm_backup_protected_vm.protected_item
+}
\ No newline at end of file
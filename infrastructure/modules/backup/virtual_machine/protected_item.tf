@@ -0,0 +1,6 @@
+resource "azurerm_backup_protected_vm" "protected_item" {
+  resource_group_name = var.vault.resource_group_name
+  recovery_vault_name = var.vault.name
+  source_vm_id        = var.vm_id
+  backup_policy_id    = azurerm_backup_policy_vm.backup_policy.id
+}
@@ -1,3 +1,7 @@
 output "backup_vault" {
   value = azurerm_data_protection_backup_vault.backup_vault
+}
+
+output "recovery_services_vault" {
+  value = one(azurerm_recovery_services_vault.recovery_services_vault)
 }
\ No newline at end of file
@@ -0,0 +1,51 @@
+# The recovery services vault is optional, and is only created when a name is provided. It
+# protects the workloads which a backup vault can't - Azure virtual machines and Azure file
+# shares - so that they're backed up by the same module, with the same diagnostics, tags
+# and security settings as the backup vault.
+###########################################################################################
+
+resource "azurerm_recovery_services_vault" "recovery_services_vault" {
+  count               = var.recovery_services_vault_name != null ? 1 : 0
+  name                = var.recovery_services_vault_name
+  resource_group_name = local.resource_group.name
+  location            = local.resource_group.location
+  sku                 = "Standard"
+  storage_mode_type   = var.recovery_services_vault_redundancy
+  immutability        = var.recovery_services_vault_immutability
+  soft_delete_enabled = var.recovery_services_vault_soft_delete == "On"
+  tags                = var.tags
+  identity {
+    type = "SystemAssigned"
+  }
+}
+
+resource "azurerm_monitor_diagnostic_setting" "recovery_services_vault" {
+  count                      = length(azurerm_recovery_services_vault.recovery_services_vault)
+  name                       = "${var.recovery_services_vault_name}-diagnostic-settings"
+  target_resource_id         = azurerm_recovery_services_vault.recovery_services_vault[0].id
+  log_analytics_workspace_id = var.log_analytics_workspace_id
+
+  dynamic "enabled_log" {
+    for_each = toset(local.backup_vault_diagnostics_log_categories)
+    content {
+      category = enabled_log.key
+    }
+  }
+
+  dynamic "enabled_metric" {
+    for_each = toset(local.backup_vault_diagnostics_metric_categories)
+    content {
+      category = enabled_metric.key
+    }
+  }
+}
+
+# A storage account must be registered with the vault before its file shares can be
+# protected, and it can only be registered once - so each distinct account is registered
+# here rather than by the file_share module.
+resource "azurerm_backup_container_storage_account" "file_share" {
+  for_each            = toset(distinct([for k, v in var.file_share_backups : v.storage_account_id]))
+  resource_group_name = local.resource_group.name
+  recovery_vault_name = azurerm_recovery_services_vault.recovery_services_vault[0].name
+  storage_account_id  = each.value
+}
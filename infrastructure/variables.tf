@@ -4,14 +4,41 @@ locals {
 
   # Valid backup interval frequencies per resource type
   valid_blob_storage_intervals               = ["P1D", "P1W"]
+  valid_data_lake_storage_intervals          = ["P1D", "P1W"]
   valid_managed_disk_intervals               = ["PT1H", "PT2H", "PT4H", "PT6H", "PT8H", "PT12H", "P1D"]
   valid_postgresql_flexible_server_intervals = ["P1W"]
+  valid_mysql_flexible_server_intervals      = ["P1W"]
+  valid_aks_cluster_intervals                = ["PT4H", "PT6H", "PT8H", "PT12H", "P1D"]
+  valid_vm_intervals                         = ["P1D"]
+  valid_file_share_intervals                 = ["P1D"]
+
+  # The tagging criteria of retention_rules, which keep some backups for longer than others (e.g. weekly, monthly and yearly copies)
+  valid_retention_rule_absolute_criteria              = ["AllBackup", "FirstOfDay", "FirstOfWeek", "FirstOfMonth", "FirstOfYear"]
+  valid_managed_disk_retention_rule_absolute_criteria = ["FirstOfDay", "FirstOfWeek"]
+  retention_rule_duration_pattern                     = "^P[0-9]+[DWMY]$"
+
+  # Recovery services vault policies keep daily recovery points, and virtual machines need at least 7 of them
+  daily_retention_period_pattern = "^P([0-9]+)D$"
+  minimum_vm_retention_days      = 7
+
+  # The retention_rules of recovery services vault policies keep a number of weekly, monthly and yearly recovery points,
+  # from the days, weeks and months given, up to the most that each datasource allows
+  valid_retention_rule_weekdays            = ["Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"]
+  valid_retention_rule_weeks               = ["First", "Second", "Third", "Fourth", "Last"]
+  valid_retention_rule_months              = ["January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"]
+  vm_retention_rule_maximum_counts         = { weekly = 5163, monthly = 1188, yearly = 99 }
+  file_share_retention_rule_maximum_counts = { weekly = 200, monthly = 120, yearly = 10 }
 
   # Repeating interval format: R/<RFC3339 timestamp>/<duration>
-  backup_interval_timestamp_pattern = "[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(Z|[+-][0-9]{2}:[0-9]{2})"
-  blob_storage_interval_pattern     = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_blob_storage_intervals)})$"
-  managed_disk_interval_pattern     = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_managed_disk_intervals)})$"
-  postgresql_interval_pattern       = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_postgresql_flexible_server_intervals)})$"
+  backup_interval_timestamp_pattern  = "[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(Z|[+-][0-9]{2}:[0-9]{2})"
+  blob_storage_interval_pattern      = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_blob_storage_intervals)})$"
+  data_lake_storage_interval_pattern = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_data_lake_storage_intervals)})$"
+  managed_disk_interval_pattern      = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_managed_disk_intervals)})$"
+  postgresql_interval_pattern        = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_postgresql_flexible_server_intervals)})$"
+  mysql_interval_pattern             = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_mysql_flexible_server_intervals)})$"
+  aks_cluster_interval_pattern       = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_aks_cluster_intervals)})$"
+  vm_interval_pattern                = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_vm_intervals)})$"
+  file_share_interval_pattern        = "^R/${local.backup_interval_timestamp_pattern}/(${join("|", local.valid_file_share_intervals)})$"
 }
 
 variable "resource_group_name" {
@@ -77,6 +104,14 @@ variable "blob_storage_backups" {
     backup_instance_naming_template = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
     time_zone                       = optional(string)
     enable_daily_retention_rule     = optional(bool)
+    retention_rules = optional(list(object({
+      name              = string
+      duration          = string
+      absolute_criteria = optional(string)
+      days_of_week      = optional(list(string))
+      weeks_of_month    = optional(list(string))
+      months_of_year    = optional(list(string))
+    })), [])
   }))
 
   default = {}
@@ -104,6 +139,110 @@ variable "blob_storage_backups" {
     condition     = var.use_extended_retention ? true : alltrue([for k, v in var.blob_storage_backups : contains(local.valid_retention_periods, v.retention_period)])
     error_message = "Invalid retention period: valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
   }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.blob_storage_backups : length(distinct([for rule in v.retention_rules : rule.name])) == length(v.retention_rules) && length(setintersection([for rule in v.retention_rules : rule.name], ["Default", "daily-retention"])) == 0
+    ])
+    error_message = "Invalid retention rule name: rule names must be unique within a backup, and can't be Default or daily-retention as those are the rules for the retention_period."
+  }
+
+  # The retention rules are prioritised ahead of the daily-retention rule at priority 9, when it's enabled
+  validation {
+    condition     = alltrue([for k, v in var.blob_storage_backups : !coalesce(v.enable_daily_retention_rule, false) || length(v.retention_rules) <= 8])
+    error_message = "Too many retention rules for blob storage: at most 8 rules can be given with enable_daily_retention_rule, as the daily-retention rule has priority 9."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.blob_storage_backups : alltrue([
+        for rule in v.retention_rules : (rule.absolute_criteria != null || rule.days_of_week != null) && contains(local.valid_retention_rule_absolute_criteria, coalesce(rule.absolute_criteria, "AllBackup"))
+      ])
+    ])
+    error_message = "Invalid retention rule for blob storage: each rule must have an absolute_criteria of AllBackup, FirstOfDay, FirstOfWeek, FirstOfMonth or FirstOfYear, or the days_of_week that it applies to."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.blob_storage_backups : alltrue([
+        for rule in v.retention_rules : can(regex(local.retention_rule_duration_pattern, rule.duration)) && (var.use_extended_retention || contains(local.valid_retention_periods, rule.duration))
+      ])
+    ])
+    error_message = "Invalid retention rule duration: durations must be a number of days, weeks, months or years (e.g. P4W), and valid durations are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
+}
+
+variable "data_lake_storage_backups" {
+  description = "A map of data lake storage (ADLS Gen2) backups to create"
+  type = map(object({
+    backup_name                     = string
+    retention_period                = string
+    backup_intervals                = list(string)
+    storage_account_id              = string
+    storage_account_containers      = list(string)
+    backup_policy_naming_template   = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
+    backup_instance_naming_template = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
+    time_zone                       = optional(string)
+    retention_rules = optional(list(object({
+      name              = string
+      duration          = string
+      absolute_criteria = optional(string)
+      days_of_week      = optional(list(string))
+      weeks_of_month    = optional(list(string))
+      months_of_year    = optional(list(string))
+    })), [])
+  }))
+
+  default = {}
+
+  validation {
+    condition     = alltrue([for k, v in var.data_lake_storage_backups : length(v.backup_intervals) > 0])
+    error_message = "At least one backup interval must be provided."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.data_lake_storage_backups : alltrue([
+        for interval in v.backup_intervals : can(regex(local.data_lake_storage_interval_pattern, interval))
+      ])
+    ])
+    error_message = "Invalid backup interval for data lake storage: allowed frequencies are P1D (daily) or P1W (weekly). See https://learn.microsoft.com/en-us/azure/backup/azure-data-lake-storage-backup-support-matrix for details."
+  }
+
+  validation {
+    condition     = alltrue([for k, v in var.data_lake_storage_backups : length(v.storage_account_containers) > 0])
+    error_message = "At least one storage account container must be provided."
+  }
+
+  validation {
+    condition     = var.use_extended_retention ? true : alltrue([for k, v in var.data_lake_storage_backups : contains(local.valid_retention_periods, v.retention_period)])
+    error_message = "Invalid retention period: valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.data_lake_storage_backups : length(distinct([for rule in v.retention_rules : rule.name])) == length(v.retention_rules) && !contains([for rule in v.retention_rules : rule.name], "Default")
+    ])
+    error_message = "Invalid retention rule name: rule names must be unique within a backup, and can't be Default as that's the rule for the retention_period."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.data_lake_storage_backups : alltrue([
+        for rule in v.retention_rules : (rule.absolute_criteria != null || rule.days_of_week != null) && contains(local.valid_retention_rule_absolute_criteria, coalesce(rule.absolute_criteria, "AllBackup"))
+      ])
+    ])
+    error_message = "Invalid retention rule for data lake storage: each rule must have an absolute_criteria of AllBackup, FirstOfDay, FirstOfWeek, FirstOfMonth or FirstOfYear, or the days_of_week that it applies to."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.data_lake_storage_backups : alltrue([
+        for rule in v.retention_rules : can(regex(local.retention_rule_duration_pattern, rule.duration)) && (var.use_extended_retention || contains(local.valid_retention_periods, rule.duration))
+      ])
+    ])
+    error_message = "Invalid retention rule duration: durations must be a number of days, weeks, months or years (e.g. P4W), and valid durations are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
 }
 
 variable "managed_disk_backups" {
@@ -119,6 +258,14 @@ variable "managed_disk_backups" {
     })
     backup_policy_naming_template   = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
     backup_instance_naming_template = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
+    retention_rules = optional(list(object({
+      name              = string
+      duration          = string
+      absolute_criteria = optional(string)
+      days_of_week      = optional(list(string))
+      weeks_of_month    = optional(list(string))
+      months_of_year    = optional(list(string))
+    })), [])
   }))
 
   default = {}
@@ -141,6 +288,31 @@ variable "managed_disk_backups" {
     condition     = var.use_extended_retention ? true : alltrue([for k, v in var.managed_disk_backups : contains(local.valid_retention_periods, v.retention_period)])
     error_message = "Invalid retention period: valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
   }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.managed_disk_backups : length(distinct([for rule in v.retention_rules : rule.name])) == length(v.retention_rules) && !contains([for rule in v.retention_rules : rule.name], "Default")
+    ])
+    error_message = "Invalid retention rule name: rule names must be unique within a backup, and can't be Default as that's the rule for the retention_period."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.managed_disk_backups : alltrue([
+        for rule in v.retention_rules : contains(local.valid_managed_disk_retention_rule_absolute_criteria, coalesce(rule.absolute_criteria, "None")) && rule.days_of_week == null && rule.weeks_of_month == null && rule.months_of_year == null
+      ])
+    ])
+    error_message = "Invalid retention rule for managed disk: each rule must have an absolute_criteria of FirstOfDay or FirstOfWeek, as managed disk backups can't be tagged by days_of_week, weeks_of_month or months_of_year."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.managed_disk_backups : alltrue([
+        for rule in v.retention_rules : can(regex(local.retention_rule_duration_pattern, rule.duration)) && (var.use_extended_retention || contains(local.valid_retention_periods, rule.duration))
+      ])
+    ])
+    error_message = "Invalid retention rule duration: durations must be a number of days, weeks, months or years (e.g. P4W), and valid durations are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
 }
 
 variable "postgresql_flexible_server_backups" {
@@ -153,6 +325,14 @@ variable "postgresql_flexible_server_backups" {
     server_resource_group_id        = string
     backup_policy_naming_template   = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
     backup_instance_naming_template = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
+    retention_rules = optional(list(object({
+      name              = string
+      duration          = string
+      absolute_criteria = optional(string)
+      days_of_week      = optional(list(string))
+      weeks_of_month    = optional(list(string))
+      months_of_year    = optional(list(string))
+    })), [])
   }))
 
   default = {}
@@ -175,9 +355,392 @@ variable "postgresql_flexible_server_backups" {
     condition     = var.use_extended_retention ? true : alltrue([for k, v in var.postgresql_flexible_server_backups : contains(local.valid_retention_periods, v.retention_period)])
     error_message = "Invalid retention period: valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
   }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.postgresql_flexible_server_backups : length(distinct([for rule in v.retention_rules : rule.name])) == length(v.retention_rules) && !contains([for rule in v.retention_rules : rule.name], "Default")
+    ])
+    error_message = "Invalid retention rule name: rule names must be unique within a backup, and can't be Default as that's the rule for the retention_period."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.postgresql_flexible_server_backups : alltrue([
+        for rule in v.retention_rules : (rule.absolute_criteria != null || rule.days_of_week != null) && contains(local.valid_retention_rule_absolute_criteria, coalesce(rule.absolute_criteria, "AllBackup"))
+      ])
+    ])
+    error_message = "Invalid retention rule for PostgreSQL flexible server: each rule must have an absolute_criteria of AllBackup, FirstOfDay, FirstOfWeek, FirstOfMonth or FirstOfYear, or the days_of_week that it applies to."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.postgresql_flexible_server_backups : alltrue([
+        for rule in v.retention_rules : can(regex(local.retention_rule_duration_pattern, rule.duration)) && (var.use_extended_retention || contains(local.valid_retention_periods, rule.duration))
+      ])
+    ])
+    error_message = "Invalid retention rule duration: durations must be a number of days, weeks, months or years (e.g. P4W), and valid durations are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
+}
+
+variable "mysql_flexible_server_backups" {
+  description = "A map of mysql flexible server backups to create"
+  type = map(object({
+    backup_name                     = string
+    retention_period                = string
+    backup_intervals                = list(string)
+    server_id                       = string
+    server_resource_group_id        = string
+    backup_policy_naming_template   = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
+    backup_instance_naming_template = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
+    retention_rules = optional(list(object({
+      name              = string
+      duration          = string
+      absolute_criteria = optional(string)
+      days_of_week      = optional(list(string))
+      weeks_of_month    = optional(list(string))
+      months_of_year    = optional(list(string))
+    })), [])
+  }))
+
+  default = {}
+
+  validation {
+    condition     = alltrue([for k, v in var.mysql_flexible_server_backups : length(v.backup_intervals) > 0])
+    error_message = "At least one backup interval must be provided."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.mysql_flexible_server_backups : alltrue([
+        for interval in v.backup_intervals : can(regex(local.mysql_interval_pattern, interval))
+      ])
+    ])
+    error_message = "Invalid backup interval for MySQL flexible server: only P1W (weekly) is allowed. See https://learn.microsoft.com/en-us/azure/backup/backup-azure-mysql-flexible-server-support-matrix for details."
+  }
+
+  validation {
+    condition     = var.use_extended_retention ? true : alltrue([for k, v in var.mysql_flexible_server_backups : contains(local.valid_retention_periods, v.retention_period)])
+    error_message = "Invalid retention period: valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.mysql_flexible_server_backups : length(distinct([for rule in v.retention_rules : rule.name])) == length(v.retention_rules) && !contains([for rule in v.retention_rules : rule.name], "Default")
+    ])
+    error_message = "Invalid retention rule name: rule names must be unique within a backup, and can't be Default as that's the rule for the retention_period."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.mysql_flexible_server_backups : alltrue([
+        for rule in v.retention_rules : (rule.absolute_criteria != null || rule.days_of_week != null) && contains(local.valid_retention_rule_absolute_criteria, coalesce(rule.absolute_criteria, "AllBackup"))
+      ])
+    ])
+    error_message = "Invalid retention rule for MySQL flexible server: each rule must have an absolute_criteria of AllBackup, FirstOfDay, FirstOfWeek, FirstOfMonth or FirstOfYear, or the days_of_week that it applies to."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.mysql_flexible_server_backups : alltrue([
+        for rule in v.retention_rules : can(regex(local.retention_rule_duration_pattern, rule.duration)) && (var.use_extended_retention || contains(local.valid_retention_periods, rule.duration))
+      ])
+    ])
+    error_message = "Invalid retention rule duration: durations must be a number of days, weeks, months or years (e.g. P4W), and valid durations are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
+}
+
+variable "aks_cluster_backups" {
+  description = "A map of AKS cluster backups to create"
+  type = map(object({
+    backup_name                   = string
+    retention_period              = string
+    backup_intervals              = list(string)
+    cluster_id                    = string
+    cluster_identity_principal_id = string
+    snapshot_resource_group = object({
+      id   = string
+      name = string
+    })
+    storage_account_id              = string
+    storage_account_container       = string
+    included_namespaces             = optional(list(string))
+    excluded_namespaces             = optional(list(string))
+    backup_policy_naming_template   = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
+    backup_instance_naming_template = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
+    retention_rules = optional(list(object({
+      name              = string
+      duration          = string
+      absolute_criteria = optional(string)
+      days_of_week      = optional(list(string))
+      weeks_of_month    = optional(list(string))
+      months_of_year    = optional(list(string))
+    })), [])
+  }))
+
+  default = {}
+
+  validation {
+    condition     = alltrue([for k, v in var.aks_cluster_backups : length(v.backup_intervals) > 0])
+    error_message = "At least one backup interval must be provided."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.aks_cluster_backups : alltrue([
+        for interval in v.backup_intervals : can(regex(local.aks_cluster_interval_pattern, interval))
+      ])
+    ])
+    error_message = "Invalid backup interval for AKS cluster: allowed frequencies are PT4H, PT6H, PT8H, PT12H (hourly) or P1D (daily). See https://learn.microsoft.com/en-us/azure/backup/azure-kubernetes-service-cluster-backup-support-matrix for details."
+  }
+
+  validation {
+    condition     = length(distinct([for k, v in var.aks_cluster_backups : lower(v.cluster_id)])) == length(var.aks_cluster_backups)
+    error_message = "Each AKS cluster can only be backed up once, as the backup extension is installed on the cluster by its backup."
+  }
+
+  validation {
+    condition     = var.use_extended_retention ? true : alltrue([for k, v in var.aks_cluster_backups : contains(local.valid_retention_periods, v.retention_period)])
+    error_message = "Invalid retention period: valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.aks_cluster_backups : length(distinct([for rule in v.retention_rules : rule.name])) == length(v.retention_rules) && !contains([for rule in v.retention_rules : rule.name], "Default")
+    ])
+    error_message = "Invalid retention rule name: rule names must be unique within a backup, and can't be Default as that's the rule for the retention_period."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.aks_cluster_backups : alltrue([
+        for rule in v.retention_rules : (rule.absolute_criteria != null || rule.days_of_week != null) && contains(local.valid_retention_rule_absolute_criteria, coalesce(rule.absolute_criteria, "AllBackup"))
+      ])
+    ])
+    error_message = "Invalid retention rule for AKS cluster: each rule must have an absolute_criteria of AllBackup, FirstOfDay, FirstOfWeek, FirstOfMonth or FirstOfYear, or the days_of_week that it applies to."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.aks_cluster_backups : alltrue([
+        for rule in v.retention_rules : can(regex(local.retention_rule_duration_pattern, rule.duration)) && (var.use_extended_retention || contains(local.valid_retention_periods, rule.duration))
+      ])
+    ])
+    error_message = "Invalid retention rule duration: durations must be a number of days, weeks, months or years (e.g. P4W), and valid durations are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
 }
 
 variable "backup_vault_soft_delete" {
   type    = string
   default = "Off"
 }
+
+
+variable "recovery_services_vault_name" {
+  description = "The name of the recovery services vault, which protects virtual machines and file shares - no recovery services vault is created when it's not set"
+  type        = string
+  default     = null
+}
+
+variable "recovery_services_vault_redundancy" {
+  description = "The redundancy of the recovery services vault"
+  type        = string
+  default     = "LocallyRedundant"
+
+  validation {
+    condition     = contains(["LocallyRedundant", "GeoRedundant", "ZoneRedundant"], var.recovery_services_vault_redundancy)
+    error_message = "Invalid redundancy setting for the recovery services vault: allowed values are LocallyRedundant, GeoRedundant or ZoneRedundant."
+  }
+}
+
+variable "recovery_services_vault_immutability" {
+  description = "The immutability setting of the recovery services vault"
+  type        = string
+  default     = "Disabled"
+
+  validation {
+    condition     = contains(["Disabled", "Unlocked", "Locked"], var.recovery_services_vault_immutability)
+    error_message = "Invalid immutability setting for the recovery services vault: allowed values are Disabled, Unlocked or Locked."
+  }
+}
+
+variable "recovery_services_vault_soft_delete" {
+  description = "The soft delete setting of the recovery services vault"
+  type        = string
+  default     = "Off"
+
+  validation {
+    condition     = contains(["On", "Off"], var.recovery_services_vault_soft_delete)
+    error_message = "Invalid soft delete setting for the recovery services vault: allowed values are On or Off."
+  }
+}
+
+variable "vm_backups" {
+  description = "A map of virtual machine backups to create in the recovery services vault"
+  type = map(object({
+    backup_name                   = string
+    retention_period              = string
+    backup_intervals              = list(string)
+    vm_id                         = string
+    backup_policy_naming_template = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
+    retention_rules = optional(object({
+      weekly = optional(object({
+        count    = number
+        weekdays = list(string)
+      }))
+      monthly = optional(object({
+        count    = number
+        weekdays = list(string)
+        weeks    = list(string)
+      }))
+      yearly = optional(object({
+        count    = number
+        weekdays = list(string)
+        weeks    = list(string)
+        months   = list(string)
+      }))
+    }), {})
+  }))
+
+  default = {}
+
+  validation {
+    condition     = length(var.vm_backups) == 0 || var.recovery_services_vault_name != null
+    error_message = "A recovery services vault name must be provided to back up virtual machines."
+  }
+
+  validation {
+    condition     = alltrue([for k, v in var.vm_backups : length(v.backup_intervals) == 1])
+    error_message = "Exactly one backup interval must be provided for a virtual machine, as recovery services vault policies run once a day."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.vm_backups : alltrue([
+        for interval in v.backup_intervals : can(regex(local.vm_interval_pattern, interval))
+      ])
+    ])
+    error_message = "Invalid backup interval for virtual machine: only P1D (daily) is allowed. See https://learn.microsoft.com/en-us/azure/backup/backup-support-matrix-iaas for details."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.vm_backups : try(tonumber(regex(local.daily_retention_period_pattern, v.retention_period)[0]) >= local.minimum_vm_retention_days, false)
+    ])
+    error_message = "Invalid retention period for virtual machine: the period must be a number of days, and at least 7 days (P7D)."
+  }
+
+  validation {
+    condition     = var.use_extended_retention ? true : alltrue([for k, v in var.vm_backups : contains(local.valid_retention_periods, v.retention_period)])
+    error_message = "Invalid retention period: valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
+
+  validation {
+    condition     = var.use_extended_retention ? true : alltrue([for k, v in var.vm_backups : alltrue([for schedule, rule in v.retention_rules : rule == null])])
+    error_message = "Invalid retention rules: weekly, monthly and yearly recovery points are kept for longer than 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.vm_backups : alltrue([
+        for schedule, rule in v.retention_rules : floor(rule.count) == rule.count && rule.count >= 1 && rule.count <= local.vm_retention_rule_maximum_counts[schedule] if rule != null
+      ])
+    ])
+    error_message = "Invalid retention rule count for virtual machine: the weekly rule can keep 1 to 5163 recovery points, the monthly rule 1 to 1188 and the yearly rule 1 to 99."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.vm_backups : alltrue(concat(
+        [for schedule, rule in v.retention_rules : length(rule.weekdays) > 0 && length(setsubtract(rule.weekdays, local.valid_retention_rule_weekdays)) == 0 if rule != null],
+        [for rule in [v.retention_rules.monthly, v.retention_rules.yearly] : length(rule.weeks) > 0 && length(setsubtract(rule.weeks, local.valid_retention_rule_weeks)) == 0 if rule != null],
+        [for rule in [v.retention_rules.yearly] : length(rule.months) > 0 && length(setsubtract(rule.months, local.valid_retention_rule_months)) == 0 if rule != null]
+      ))
+    ])
+    error_message = "Invalid retention rule for virtual machine: each rule must have the weekdays (e.g. Sunday) that it keeps recovery points from, the monthly and yearly rules the weeks of the month (First, Second, Third, Fourth or Last), and the yearly rule the months (e.g. January)."
+  }
+}
+
+variable "file_share_backups" {
+  description = "A map of file share backups to create in the recovery services vault"
+  type = map(object({
+    backup_name                   = string
+    retention_period              = string
+    backup_intervals              = list(string)
+    storage_account_id            = string
+    file_share_name               = string
+    backup_policy_naming_template = optional(string, "{resource_abbreviation}-{resource_type}-{backup_name}")
+    retention_rules = optional(object({
+      weekly = optional(object({
+        count    = number
+        weekdays = list(string)
+      }))
+      monthly = optional(object({
+        count    = number
+        weekdays = list(string)
+        weeks    = list(string)
+      }))
+      yearly = optional(object({
+        count    = number
+        weekdays = list(string)
+        weeks    = list(string)
+        months   = list(string)
+      }))
+    }), {})
+  }))
+
+  default = {}
+
+  validation {
+    condition     = length(var.file_share_backups) == 0 || var.recovery_services_vault_name != null
+    error_message = "A recovery services vault name must be provided to back up file shares."
+  }
+
+  validation {
+    condition     = alltrue([for k, v in var.file_share_backups : length(v.backup_intervals) == 1])
+    error_message = "Exactly one backup interval must be provided for a file share, as recovery services vault policies run once a day."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.file_share_backups : alltrue([
+        for interval in v.backup_intervals : can(regex(local.file_share_interval_pattern, interval))
+      ])
+    ])
+    error_message = "Invalid backup interval for file share: only P1D (daily) is allowed. See https://learn.microsoft.com/en-us/azure/backup/azure-file-share-support-matrix for details."
+  }
+
+  validation {
+    condition     = alltrue([for k, v in var.file_share_backups : can(regex(local.daily_retention_period_pattern, v.retention_period))])
+    error_message = "Invalid retention period for file share: the period must be a number of days, e.g. P7D."
+  }
+
+  validation {
+    condition     = var.use_extended_retention ? true : alltrue([for k, v in var.file_share_backups : contains(local.valid_retention_periods, v.retention_period)])
+    error_message = "Invalid retention period: valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
+
+  validation {
+    condition     = var.use_extended_retention ? true : alltrue([for k, v in var.file_share_backups : alltrue([for schedule, rule in v.retention_rules : rule == null])])
+    error_message = "Invalid retention rules: weekly, monthly and yearly recovery points are kept for longer than 7 days. If you require a longer retention period then please set use_extended_retention to true."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.file_share_backups : alltrue([
+        for schedule, rule in v.retention_rules : floor(rule.count) == rule.count && rule.count >= 1 && rule.count <= local.file_share_retention_rule_maximum_counts[schedule] if rule != null
+      ])
+    ])
+    error_message = "Invalid retention rule count for file share: the weekly rule can keep 1 to 200 recovery points, the monthly rule 1 to 120 and the yearly rule 1 to 10."
+  }
+
+  validation {
+    condition = alltrue([
+      for k, v in var.file_share_backups : alltrue(concat(
+        [for schedule, rule in v.retention_rules : length(rule.weekdays) > 0 && length(setsubtract(rule.weekdays, local.valid_retention_rule_weekdays)) == 0 if rule != null],
+        [for rule in [v.retention_rules.monthly, v.retention_rules.yearly] : length(rule.weeks) > 0 && length(setsubtract(rule.weeks, local.valid_retention_rule_weeks)) == 0 if rule != null],
+        [for rule in [v.retention_rules.yearly] : length(rule.months) > 0 && length(setsubtract(rule.months, local.valid_retention_rule_months)) == 0 if rule != null]
+      ))
+    ])
+    error_message = "Invalid retention rule for file share: each rule must have the weekdays (e.g. Sunday) that it keeps recovery points from, the monthly and yearly rules the weeks of the month (First, Second, Third, Fourth or Last), and the yearly rule the months (e.g. January)."
+  }
+}
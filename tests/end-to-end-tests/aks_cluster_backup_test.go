@@ -0,0 +1,240 @@
+package e2e_tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"e2e_tests/audit"
+	"e2e_tests/azure"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestAksClusterBackupExternalResources struct {
+	ResourceGroup           armresources.ResourceGroup
+	LogAnalyticsWorkspace   armoperationalinsights.Workspace
+	StorageAccount          armstorage.Account
+	StorageAccountContainer armstorage.BlobContainer
+	AksCluster              armcontainerservice.ManagedCluster
+}
+
+/*
+ * Creates resources which are "external" to the az-backup module, and models
+ * what would be backed up in a real scenario.
+ */
+func setupExternalResourcesForAksClusterBackupTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestAksClusterBackupExternalResources {
+	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+
+	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+
+	// The backup extension writes the cluster's backups to a storage account container
+	storageAccountName := fmt.Sprintf("sa%sexternal", strings.ToLower(uniqueId))
+	storageAccount := MustCreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, resourceGroupLocation, nil)
+	storageAccountContainer := MustCreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, "aks-backups")
+
+	aksClusterName := fmt.Sprintf("aks-%s-external", strings.ToLower(uniqueId))
+	aksCluster := MustCreateAksCluster(t, credential, subscriptionID, externalResourceGroupName, aksClusterName, resourceGroupLocation)
+
+	externalResources := &TestAksClusterBackupExternalResources{
+		ResourceGroup:           resourceGroup,
+		LogAnalyticsWorkspace:   logAnalyticsWorkspace,
+		StorageAccount:          storageAccount,
+		StorageAccountContainer: storageAccountContainer,
+		AksCluster:              aksCluster,
+	}
+
+	return externalResources
+}
+
+/*
+ * TestAksClusterBackup tests the deployment of a backup vault and backup policies for AKS clusters.
+ */
+func TestAksClusterBackup(t *testing.T) {
+	t.Parallel()
+
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
+
+	uniqueId := random.UniqueId()
+	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
+	resourceGroupLocation := "uksouth"
+	backupVaultName := fmt.Sprintf("bvault-nhsbackup-%s", uniqueId)
+
+	externalResources := setupExternalResourcesForAksClusterBackupTest(t, credential, environment.SubscriptionID, resourceGroupName, resourceGroupLocation, uniqueId)
+
+	// A map of backups which we'll use to apply the TF module, and then validate the
+	// policies have been created correctly. A cluster can only be backed up once, as
+	// the module installs the backup extension on it.
+	aksClusterBackups := map[string]map[string]interface{}{
+		"backup1": {
+			"backup_name":                   "aks1",
+			"retention_period":              "P7D",
+			"backup_intervals":              []string{"R/2024-01-01T00:00:00+00:00/PT4H"},
+			"cluster_id":                    *externalResources.AksCluster.ID,
+			"cluster_identity_principal_id": *externalResources.AksCluster.Identity.PrincipalID,
+			"snapshot_resource_group": map[string]interface{}{
+				"id":   *externalResources.ResourceGroup.ID,
+				"name": *externalResources.ResourceGroup.Name,
+			},
+			"storage_account_id":        *externalResources.StorageAccount.ID,
+			"storage_account_container": *externalResources.StorageAccountContainer.Name,
+			"retention_rules": []map[string]interface{}{
+				{"name": "Weekly", "duration": "P4W", "absolute_criteria": "FirstOfWeek"},
+				{"name": "Daily", "duration": "P14D", "absolute_criteria": "FirstOfDay"},
+			},
+		},
+	}
+
+	// Teardown stage
+	// ...
+
+	defer test_structure.RunTestStage(t, "teardown", func() {
+		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
+
+		DestroyTerraform(t, environment, terraformOptions)
+
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+	})
+
+	// Setup stage
+	// ...
+
+	test_structure.RunTestStage(t, "setup", func() {
+		terraformOptions := &terraform.Options{
+			TerraformDir: environment.TerraformFolder,
+
+			Vars: map[string]interface{}{
+				"resource_group_name":        resourceGroupName,
+				"resource_group_location":    resourceGroupLocation,
+				"backup_vault_name":          backupVaultName,
+				"log_analytics_workspace_id": *externalResources.LogAnalyticsWorkspace.ID,
+				"use_extended_retention":     true,
+				"aks_cluster_backups":        aksClusterBackups,
+			},
+
+			BackendConfig: map[string]interface{}{
+				"resource_group_name":  environment.TerraformStateResourceGroup,
+				"storage_account_name": environment.TerraformStateStorageAccount,
+				"container_name":       environment.TerraformStateContainer,
+				"key":                  backupVaultName + ".tfstate",
+			},
+		}
+
+		// Save options for later test stages
+		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
+
+		ApplyTerraform(t, environment, terraformOptions)
+	})
+
+	// Validate stage
+	// ...
+
+	test_structure.RunTestStage(t, "validate", func() {
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupPolicies := MustGetBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupInstances := MustGetBackupInstances(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+
+		assert.Equal(t, len(aksClusterBackups), len(backupPolicies), "Expected to find %2 backup policies in vault", len(aksClusterBackups))
+		assert.Equal(t, len(aksClusterBackups), len(backupInstances), "Expected to find %2 backup instances in vault", len(aksClusterBackups))
+
+		for _, backup := range aksClusterBackups {
+			retentionPeriod := backup["retention_period"].(string)
+			backupIntervals := backup["backup_intervals"].([]string)
+			clusterId := backup["cluster_id"].(string)
+			clusterIdentityPrincipalId := backup["cluster_identity_principal_id"].(string)
+			snapshotResourceGroup := backup["snapshot_resource_group"].(map[string]interface{})
+			snapshotResourceGroupId := snapshotResourceGroup["id"].(string)
+			storageAccountId := backup["storage_account_id"].(string)
+
+			// Validate backup policy
+			backupNames := MustGetBackupNames(t, naming.ResourceTypeAksCluster, backup)
+			backupPolicyName := backupNames.PolicyName()
+			backupPolicy := azure.GetBackupPolicyForName(backupPolicies, backupPolicyName)
+			assert.NotNil(t, backupPolicy, "Expected to find a backup policy called %s", backupPolicyName)
+
+			// Validate retention period
+			backupPolicyProperties := backupPolicy.Properties.(*armdataprotection.BackupPolicy)
+			retentionRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "Default").(*armdataprotection.AzureRetentionRule)
+			deleteOption := retentionRule.Lifecycles[0].DeleteAfter.(*armdataprotection.AbsoluteDeleteOption)
+			assert.Equal(t, retentionPeriod, *deleteOption.Duration, "Expected the backup policy retention period to be %s", retentionPeriod)
+
+			// Validate retention tiers
+			if retentionRules, ok := backup["retention_rules"].([]map[string]interface{}); ok {
+				AssertRetentionTiersEqual(t, retentionRules, backupPolicyProperties, armdataprotection.DataStoreTypesOperationalStore)
+			}
+			assert.Equal(t, armdataprotection.DataStoreTypesOperationalStore, *retentionRule.Lifecycles[0].SourceDataStore.DataStoreType, "Expected the backup policy to retain backups in the operational store")
+
+			// Validate backup intervals
+			backupRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "BackupIntervals").(*armdataprotection.AzureBackupRule)
+			schedule := backupRule.Trigger.(*armdataprotection.ScheduleBasedTriggerContext).Schedule
+			AssertBackupIntervalsEqual(t, backupIntervals, schedule.RepeatingTimeIntervals)
+
+			// Validate backup instance
+			backupInstanceName := backupNames.InstanceName()
+			backupInstance := azure.GetBackupInstanceForName(backupInstances, backupInstanceName)
+			assert.NotNil(t, backupInstance, "Expected to find a backup policy called %s", backupInstanceName)
+			assert.Equal(t, clusterId, *backupInstance.Properties.DataSourceInfo.ResourceID, "Expected the backup instance source resource ID to be %s", clusterId)
+			assert.Equal(t, *backupPolicy.ID, *backupInstance.Properties.PolicyInfo.PolicyID, "Expected the backup instance policy ID to be %s", backupPolicy.ID)
+
+			// Validate trusted access
+			clusterName := clusterId[strings.LastIndex(clusterId, "/")+1:]
+			trustedAccessRoleBindings := MustGetTrustedAccessRoleBindings(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name, clusterName)
+			assert.True(t, hasTrustedAccessRoleBinding(trustedAccessRoleBindings, *backupVault.ID, "Microsoft.DataProtection/backupVaults/backup-operator"),
+				"Expected AKS cluster %s to grant trusted access to backup vault %s", clusterName, *backupVault.ID)
+
+			// Validate role assignments
+			readerRoleDefinition := MustGetRoleDefinition(t, credential, "Reader")
+			clusterReaderRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, readerRoleDefinition, clusterId)
+			assert.NotNil(t, clusterReaderRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", readerRoleDefinition.Name, *backupVault.Identity.PrincipalID, clusterId)
+
+			snapshotReaderRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, readerRoleDefinition, snapshotResourceGroupId)
+			assert.NotNil(t, snapshotReaderRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", readerRoleDefinition.Name, *backupVault.Identity.PrincipalID, snapshotResourceGroupId)
+
+			contributorRoleDefinition := MustGetRoleDefinition(t, credential, "Contributor")
+			snapshotContributorRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, clusterIdentityPrincipalId, contributorRoleDefinition, snapshotResourceGroupId)
+			assert.NotNil(t, snapshotContributorRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", contributorRoleDefinition.Name, clusterIdentityPrincipalId, snapshotResourceGroupId)
+
+			extensionPrincipalId := MustGetAksBackupExtensionPrincipalID(t, credential, environment.SubscriptionID, clusterId)
+			blobDataContributorRoleDefinition := MustGetRoleDefinition(t, credential, "Storage Blob Data Contributor")
+			blobDataContributorRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, extensionPrincipalId, blobDataContributorRoleDefinition, storageAccountId)
+			assert.NotNil(t, blobDataContributorRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", blobDataContributorRoleDefinition.Name, extensionPrincipalId, storageAccountId)
+		}
+
+		// Validate the backup vault against the rules that the module promises
+		auditOptions := audit.DefaultOptions()
+		auditOptions.ExtendedRetention = true
+		AssertVaultCompliance(t, credential, *backupVault.ID, auditOptions)
+	})
+}
+
+/*
+ * Reports whether a cluster grants the source resource a role through trusted access.
+ */
+func hasTrustedAccessRoleBinding(bindings []*armcontainerservice.TrustedAccessRoleBinding, sourceResourceID string, role string) bool {
+	for _, binding := range bindings {
+		if binding.Properties == nil || !strings.EqualFold(*binding.Properties.SourceResourceID, sourceResourceID) {
+			continue
+		}
+
+		for _, bindingRole := range binding.Properties.Roles {
+			if *bindingRole == role {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,684 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"e2e_tests/azure"
+	"e2e_tests/interval"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/monitor/armmonitor"
+)
+
+/*
+ * The log categories and metrics that the module enables in the backup vault diagnostic settings.
+ */
+var (
+	ExpectedLogCategories = []string{
+		"AddonAzureBackupJobs",
+		"AddonAzureBackupPolicy",
+		"AddonAzureBackupProtectedInstance",
+		"CoreAzureBackup",
+	}
+
+	ExpectedMetricCategories = []string{
+		"Health",
+	}
+)
+
+/*
+ * The retention periods that are valid without extended retention - up to 7 days, as per
+ * local.valid_retention_periods in infrastructure/variables.tf.
+ */
+var validRetentionPeriods = []string{"P1D", "P2D", "P3D", "P4D", "P5D", "P6D", "P7D"}
+
+/*
+ * Options holds the settings that a vault is expected to have been deployed with. The
+ * defaults mirror those in infrastructure/variables.tf.
+ */
+type Options struct {
+	Immutability      string
+	SoftDelete        string
+	Redundancy        string
+	ExtendedRetention bool
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Immutability: "Disabled",
+		SoftDelete:   "Off",
+		Redundancy:   "LocallyRedundant",
+	}
+}
+
+/*
+ * RoleRequirement is a role that the vault identity must hold at a scope in order to back up
+ * a datasource.
+ */
+type RoleRequirement struct {
+	Scope    string
+	RoleName string
+}
+
+/*
+ * Resources holds the state of a deployed vault that the rules are evaluated against.
+ * RoleAssignments records whether each role required by the backup instances is held by
+ * the vault identity.
+ */
+type Resources struct {
+	Vault              armdataprotection.BackupVaultResource
+	Policies           []*armdataprotection.BaseBackupPolicyResource
+	Instances          []*armdataprotection.BackupInstanceResource
+	DiagnosticSettings []*armmonitor.DiagnosticSettingsResource
+	RoleAssignments    map[RoleRequirement]bool
+}
+
+/*
+ * Finding is the outcome of checking a rule against a single resource.
+ */
+type Finding struct {
+	RuleID      string `json:"ruleId"`
+	Description string `json:"description"`
+	ResourceID  string `json:"resourceId"`
+	Expected    string `json:"expected"`
+	Actual      string `json:"actual"`
+	Passed      bool   `json:"passed"`
+	Message     string `json:"message"`
+}
+
+/*
+ * Report holds the findings of auditing a vault.
+ */
+type Report struct {
+	VaultID  string    `json:"vaultId"`
+	Findings []Finding `json:"findings"`
+}
+
+/*
+ * Passed returns true when every finding in the report passed.
+ */
+func (report *Report) Passed() bool {
+	return report.Failures() == 0
+}
+
+/*
+ * Failures returns the number of findings in the report that failed.
+ */
+func (report *Report) Failures() int {
+	failures := 0
+	for _, finding := range report.Findings {
+		if !finding.Passed {
+			failures++
+		}
+	}
+
+	return failures
+}
+
+/*
+ * Rule is a check that the module promises of every vault it deploys. Source is the path of
+ * the terraform, relative to the repository root, that implements the rule. The check returns
+ * a finding per resource that it applies to.
+ */
+type Rule struct {
+	ID          string
+	Description string
+	Source      string
+	check       func(resources *Resources, options Options) []Finding
+}
+
+/*
+ * Rules returns the rules that a vault is audited against, in the order they're reported.
+ */
+func Rules() []Rule {
+	return []Rule{
+		{ID: "vault-immutability", Description: "Backup vault immutability matches the expected state", Source: "infrastructure/backup_vault.tf", check: checkImmutability},
+		{ID: "vault-soft-delete", Description: "Backup vault soft delete matches the expected state", Source: "infrastructure/backup_vault.tf", check: checkSoftDelete},
+		{ID: "vault-redundancy", Description: "Backup vault redundancy matches the expected setting", Source: "infrastructure/backup_vault.tf", check: checkRedundancy},
+		{ID: "vault-identity", Description: "Backup vault has a SystemAssigned managed identity", Source: "infrastructure/backup_vault.tf", check: checkIdentity},
+		{ID: "diagnostic-logs", Description: "Backup vault diagnostic settings enable the expected log categories", Source: "infrastructure/backup_vault.tf", check: checkDiagnosticLogs},
+		{ID: "diagnostic-metrics", Description: "Backup vault diagnostic settings enable the expected metrics", Source: "infrastructure/backup_vault.tf", check: checkDiagnosticMetrics},
+		{ID: "policy-retention", Description: "Backup policy retention is no longer than P7D unless extended retention is intended", Source: "infrastructure/variables.tf", check: checkRetention},
+		{ID: "policy-backup-intervals", Description: "Backup policy intervals use a frequency supported for the datasource type", Source: "infrastructure/variables.tf", check: checkBackupIntervals},
+		{ID: "instance-policy", Description: "Backup instance uses a backup policy in the vault", Source: "infrastructure/backup_modules.tf", check: checkInstancePolicy},
+		{ID: "instance-role-assignments", Description: "Backup vault identity holds the roles required to back up the datasource", Source: "infrastructure/backup_modules.tf", check: checkRoleAssignments},
+	}
+}
+
+/*
+ * GetRule returns the rule with the provided ID.
+ */
+func GetRule(id string) (Rule, bool) {
+	for _, rule := range Rules() {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+/*
+ * AuditVault gets the vault with the provided resource ID, along with its backup policies,
+ * backup instances, diagnostic settings and the role assignments of its identity, and
+ * evaluates them against the rules.
+ */
+func AuditVault(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, vaultID string, options Options) (*Report, error) {
+	resourceID, err := arm.ParseResourceID(vaultID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vault resource ID: %w", err)
+	}
+
+	if !strings.EqualFold(resourceID.ResourceType.String(), "Microsoft.DataProtection/backupVaults") {
+		return nil, fmt.Errorf("resource '%s' is not a backup vault", vaultID)
+	}
+
+	subscriptionID, resourceGroupName, vaultName := resourceID.SubscriptionID, resourceID.ResourceGroupName, resourceID.Name
+
+	vault, err := azure.GetBackupVault(ctx, credential, clientOptions, subscriptionID, resourceGroupName, vaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := azure.GetBackupPolicies(ctx, credential, clientOptions, subscriptionID, resourceGroupName, vaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := azure.GetBackupInstances(ctx, credential, clientOptions, subscriptionID, resourceGroupName, vaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnosticSettings, err := azure.ListDiagnosticSettings(ctx, credential, clientOptions, *vault.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	roleAssignments, err := getRoleAssignments(ctx, credential, clientOptions, subscriptionID, vault, instances)
+	if err != nil {
+		return nil, err
+	}
+
+	report := Evaluate(&Resources{
+		Vault:              vault,
+		Policies:           policies,
+		Instances:          instances,
+		DiagnosticSettings: diagnosticSettings,
+		RoleAssignments:    roleAssignments,
+	}, options)
+	report.VaultID = *vault.ID
+
+	return report, nil
+}
+
+/*
+ * Evaluate evaluates the provided resources against the rules.
+ */
+func Evaluate(resources *Resources, options Options) *Report {
+	report := &Report{VaultID: valueOf(resources.Vault.ID)}
+
+	for _, rule := range Rules() {
+		for _, finding := range rule.check(resources, options) {
+			finding.RuleID = rule.ID
+			finding.Description = rule.Description
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	return report
+}
+
+/*
+ * RequiredRoles returns the roles that the vault identity must hold to back up the datasource
+ * of a backup instance, as assigned by the backup modules.
+ */
+func RequiredRoles(instance *armdataprotection.BackupInstanceResource) []RoleRequirement {
+	if instance.Properties == nil || instance.Properties.DataSourceInfo == nil {
+		return nil
+	}
+
+	dataSource := instance.Properties.DataSourceInfo
+	resourceID := valueOf(dataSource.ResourceID)
+
+	switch valueOf(dataSource.DatasourceType) {
+	case "Microsoft.Storage/storageAccounts/blobServices":
+		return []RoleRequirement{
+			{Scope: resourceID, RoleName: "Storage Account Backup Contributor"},
+		}
+	case "Microsoft.Storage/storageAccounts/adlsBlobServices":
+		return []RoleRequirement{
+			{Scope: resourceID, RoleName: "Storage Account Backup Contributor"},
+		}
+	case "Microsoft.Compute/disks":
+		return []RoleRequirement{
+			{Scope: snapshotResourceGroupID(instance), RoleName: "Disk Snapshot Contributor"},
+			{Scope: resourceID, RoleName: "Disk Backup Reader"},
+		}
+	case "Microsoft.DBforPostgreSQL/flexibleServers":
+		return []RoleRequirement{
+			{Scope: parentResourceGroupID(resourceID), RoleName: "Reader"},
+			{Scope: resourceID, RoleName: "PostgreSQL Flexible Server Long Term Retention Backup Role"},
+		}
+	case "Microsoft.DBforMySQL/flexibleServers":
+		return []RoleRequirement{
+			{Scope: parentResourceGroupID(resourceID), RoleName: "Reader"},
+			{Scope: resourceID, RoleName: "MySQL Backup And Export Operator"},
+		}
+	case "Microsoft.ContainerService/managedClusters":
+		return []RoleRequirement{
+			{Scope: snapshotResourceGroupID(instance), RoleName: "Reader"},
+			{Scope: resourceID, RoleName: "Reader"},
+		}
+	default:
+		return nil
+	}
+}
+
+/*
+ * Gets whether the vault identity holds each of the roles required by the backup instances.
+ */
+func getRoleAssignments(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	vault armdataprotection.BackupVaultResource, instances []*armdataprotection.BackupInstanceResource) (map[RoleRequirement]bool, error) {
+	roleAssignments := map[RoleRequirement]bool{}
+	if vault.Identity == nil || vault.Identity.PrincipalID == nil {
+		return roleAssignments, nil
+	}
+
+	for _, instance := range instances {
+		for _, requirement := range RequiredRoles(instance) {
+			if _, exists := roleAssignments[requirement]; exists {
+				continue
+			}
+
+			roleDefinition, err := azure.GetRoleDefinition(ctx, credential, clientOptions, requirement.RoleName)
+			if err != nil {
+				return nil, err
+			}
+			if roleDefinition == nil {
+				roleAssignments[requirement] = false
+				continue
+			}
+
+			roleAssignment, err := azure.GetRoleAssignment(ctx, credential, clientOptions, subscriptionID, *vault.Identity.PrincipalID, roleDefinition, requirement.Scope)
+			if err != nil {
+				return nil, err
+			}
+
+			roleAssignments[requirement] = roleAssignment != nil
+		}
+	}
+
+	return roleAssignments, nil
+}
+
+func checkImmutability(resources *Resources, options Options) []Finding {
+	state := "Disabled"
+	if settings := securitySettings(resources.Vault); settings != nil && settings.ImmutabilitySettings != nil && settings.ImmutabilitySettings.State != nil {
+		state = string(*settings.ImmutabilitySettings.State)
+	}
+
+	return []Finding{compareSetting(resources.Vault.ID, "Immutability", options.Immutability, state)}
+}
+
+func checkSoftDelete(resources *Resources, options Options) []Finding {
+	state := "Off"
+	if settings := securitySettings(resources.Vault); settings != nil && settings.SoftDeleteSettings != nil && settings.SoftDeleteSettings.State != nil {
+		state = string(*settings.SoftDeleteSettings.State)
+	}
+
+	return []Finding{compareSetting(resources.Vault.ID, "Soft delete", options.SoftDelete, state)}
+}
+
+func checkRedundancy(resources *Resources, options Options) []Finding {
+	if resources.Vault.Properties == nil || len(resources.Vault.Properties.StorageSettings) == 0 {
+		return []Finding{{
+			ResourceID: valueOf(resources.Vault.ID),
+			Expected:   options.Redundancy,
+			Message:    "Backup vault has no storage settings",
+		}}
+	}
+
+	var findings []Finding
+	for _, setting := range resources.Vault.Properties.StorageSettings {
+		findings = append(findings, compareSetting(resources.Vault.ID, "Redundancy", options.Redundancy, valueOf(setting.Type)))
+	}
+
+	return findings
+}
+
+func checkIdentity(resources *Resources, options Options) []Finding {
+	finding := Finding{ResourceID: valueOf(resources.Vault.ID), Expected: "SystemAssigned"}
+
+	identity := resources.Vault.Identity
+	switch {
+	case identity == nil || identity.Type == nil:
+		finding.Message = "Backup vault has no managed identity"
+	case *identity.Type != "SystemAssigned":
+		finding.Actual = *identity.Type
+		finding.Message = fmt.Sprintf("Identity type is %s, expected SystemAssigned", *identity.Type)
+	case identity.PrincipalID == nil || *identity.PrincipalID == "":
+		finding.Actual = *identity.Type
+		finding.Message = "SystemAssigned identity has no principal ID"
+	default:
+		finding.Actual = *identity.Type
+		finding.Passed = true
+		finding.Message = fmt.Sprintf("SystemAssigned identity with principal ID %s", *identity.PrincipalID)
+	}
+
+	return []Finding{finding}
+}
+
+func checkDiagnosticLogs(resources *Resources, options Options) []Finding {
+	enabled := map[string]bool{}
+	for _, setting := range resources.DiagnosticSettings {
+		if setting.Properties == nil {
+			continue
+		}
+
+		for _, log := range setting.Properties.Logs {
+			if log.Category != nil && (log.Enabled == nil || *log.Enabled) {
+				enabled[*log.Category] = true
+			}
+		}
+	}
+
+	return checkCategories(resources, "Log category", ExpectedLogCategories, enabled)
+}
+
+func checkDiagnosticMetrics(resources *Resources, options Options) []Finding {
+	enabled := map[string]bool{}
+	for _, setting := range resources.DiagnosticSettings {
+		if setting.Properties == nil {
+			continue
+		}
+
+		for _, metric := range setting.Properties.Metrics {
+			if metric.Category != nil && (metric.Enabled == nil || *metric.Enabled) {
+				enabled[*metric.Category] = true
+			}
+		}
+	}
+
+	return checkCategories(resources, "Metric", ExpectedMetricCategories, enabled)
+}
+
+func checkRetention(resources *Resources, options Options) []Finding {
+	expected := "P7D or less"
+	if options.ExtendedRetention {
+		expected = "any period (extended retention)"
+	}
+
+	var findings []Finding
+	for _, policy := range resources.Policies {
+		for _, duration := range retentionDurations(policy) {
+			finding := Finding{
+				ResourceID: valueOf(policy.ID),
+				Expected:   expected,
+				Actual:     duration,
+				Passed:     options.ExtendedRetention || slices.Contains(validRetentionPeriods, duration),
+			}
+
+			if finding.Passed {
+				finding.Message = fmt.Sprintf("Policy %s retains backups for %s", valueOf(policy.Name), duration)
+			} else {
+				finding.Message = fmt.Sprintf("Policy %s retains backups for %s, which is longer than P7D", valueOf(policy.Name), duration)
+			}
+
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings
+}
+
+func checkBackupIntervals(resources *Resources, options Options) []Finding {
+	var findings []Finding
+	for _, policy := range resources.Policies {
+		backupPolicy, ok := policy.Properties.(*armdataprotection.BackupPolicy)
+		if !ok {
+			continue
+		}
+
+		datasourceTypes := valuesOf(backupPolicy.DatasourceTypes)
+
+		var allowed []string
+		for _, datasourceType := range datasourceTypes {
+			allowed = append(allowed, interval.AllowedPeriods(datasourceType)...)
+		}
+
+		for _, backupInterval := range backupIntervals(backupPolicy) {
+			finding := Finding{
+				ResourceID: valueOf(policy.ID),
+				Expected:   "R/<timestamp>/" + strings.Join(allowed, "|"),
+				Actual:     backupInterval,
+			}
+
+			valid := slices.ContainsFunc(datasourceTypes, func(datasourceType string) bool {
+				_, err := interval.Validate(backupInterval, datasourceType)
+				return err == nil
+			})
+
+			if valid {
+				finding.Passed = true
+				finding.Message = fmt.Sprintf("Policy %s backs up on the interval %s", valueOf(policy.Name), backupInterval)
+			} else {
+				finding.Message = fmt.Sprintf("Policy %s backs up on the interval %s, which isn't supported for %s", valueOf(policy.Name), backupInterval, strings.Join(datasourceTypes, ", "))
+			}
+
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings
+}
+
+func checkInstancePolicy(resources *Resources, options Options) []Finding {
+	var findings []Finding
+	for _, instance := range resources.Instances {
+		policyID := ""
+		if instance.Properties != nil && instance.Properties.PolicyInfo != nil {
+			policyID = valueOf(instance.Properties.PolicyInfo.PolicyID)
+		}
+
+		finding := Finding{
+			ResourceID: valueOf(instance.ID),
+			Expected:   "a backup policy in the vault",
+			Actual:     policyID,
+		}
+
+		if policy := getPolicyForID(resources.Policies, policyID); policy != nil {
+			finding.Passed = true
+			finding.Actual = valueOf(policy.Name)
+			finding.Message = fmt.Sprintf("Instance %s uses policy %s", valueOf(instance.Name), valueOf(policy.Name))
+		} else {
+			finding.Message = fmt.Sprintf("Instance %s uses policy '%s', which isn't in the vault", valueOf(instance.Name), policyID)
+		}
+
+		findings = append(findings, finding)
+	}
+
+	return findings
+}
+
+func checkRoleAssignments(resources *Resources, options Options) []Finding {
+	var findings []Finding
+	for _, instance := range resources.Instances {
+		for _, requirement := range RequiredRoles(instance) {
+			finding := Finding{
+				ResourceID: requirement.Scope,
+				Expected:   requirement.RoleName,
+				Passed:     resources.RoleAssignments[requirement],
+			}
+
+			if finding.Passed {
+				finding.Actual = requirement.RoleName
+				finding.Message = fmt.Sprintf("Vault identity holds %s for instance %s", requirement.RoleName, valueOf(instance.Name))
+			} else {
+				finding.Actual = "not assigned"
+				finding.Message = fmt.Sprintf("Vault identity doesn't hold %s for instance %s", requirement.RoleName, valueOf(instance.Name))
+			}
+
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings
+}
+
+func checkCategories(resources *Resources, kind string, expected []string, enabled map[string]bool) []Finding {
+	var findings []Finding
+	for _, category := range expected {
+		finding := Finding{
+			ResourceID: valueOf(resources.Vault.ID),
+			Expected:   category,
+			Passed:     enabled[category],
+		}
+
+		switch {
+		case finding.Passed:
+			finding.Actual = category
+			finding.Message = fmt.Sprintf("%s %s is enabled", kind, category)
+		case len(resources.DiagnosticSettings) == 0:
+			finding.Actual = "no diagnostic settings"
+			finding.Message = "Backup vault has no diagnostic settings"
+		default:
+			finding.Actual = "not enabled"
+			finding.Message = fmt.Sprintf("%s %s is not enabled", kind, category)
+		}
+
+		findings = append(findings, finding)
+	}
+
+	return findings
+}
+
+func compareSetting(resourceID *string, name string, expected string, actual string) Finding {
+	finding := Finding{
+		ResourceID: valueOf(resourceID),
+		Expected:   expected,
+		Actual:     actual,
+		Passed:     strings.EqualFold(actual, expected),
+	}
+
+	if finding.Passed {
+		finding.Message = fmt.Sprintf("%s is %s", name, actual)
+	} else {
+		finding.Message = fmt.Sprintf("%s is %s, expected %s", name, actual, expected)
+	}
+
+	return finding
+}
+
+/*
+ * Gets the durations of the retention rules in a backup policy.
+ */
+func retentionDurations(policy *armdataprotection.BaseBackupPolicyResource) []string {
+	backupPolicy, ok := policy.Properties.(*armdataprotection.BackupPolicy)
+	if !ok {
+		return nil
+	}
+
+	var durations []string
+	for _, policyRule := range backupPolicy.PolicyRules {
+		retentionRule, ok := policyRule.(*armdataprotection.AzureRetentionRule)
+		if !ok {
+			continue
+		}
+
+		for _, lifecycle := range retentionRule.Lifecycles {
+			if deleteOption, ok := lifecycle.DeleteAfter.(*armdataprotection.AbsoluteDeleteOption); ok && deleteOption.Duration != nil {
+				durations = append(durations, *deleteOption.Duration)
+			}
+		}
+	}
+
+	return durations
+}
+
+/*
+ * Gets the repeating time intervals of the backup rules in a backup policy.
+ */
+func backupIntervals(backupPolicy *armdataprotection.BackupPolicy) []string {
+	var intervals []string
+	for _, policyRule := range backupPolicy.PolicyRules {
+		backupRule, ok := policyRule.(*armdataprotection.AzureBackupRule)
+		if !ok {
+			continue
+		}
+
+		trigger, ok := backupRule.Trigger.(*armdataprotection.ScheduleBasedTriggerContext)
+		if !ok || trigger.Schedule == nil {
+			continue
+		}
+
+		intervals = append(intervals, valuesOf(trigger.Schedule.RepeatingTimeIntervals)...)
+	}
+
+	return intervals
+}
+
+func getPolicyForID(policies []*armdataprotection.BaseBackupPolicyResource, policyID string) *armdataprotection.BaseBackupPolicyResource {
+	for _, policy := range policies {
+		if policy.ID != nil && strings.EqualFold(*policy.ID, policyID) {
+			return policy
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Gets the ID of the resource group that a managed disk's snapshots are created in, which
+ * defaults to the disk's own resource group.
+ */
+func snapshotResourceGroupID(instance *armdataprotection.BackupInstanceResource) string {
+	if policyInfo := instance.Properties.PolicyInfo; policyInfo != nil && policyInfo.PolicyParameters != nil {
+		for _, parameters := range policyInfo.PolicyParameters.DataStoreParametersList {
+			if operationalStore, ok := parameters.(*armdataprotection.AzureOperationalStoreParameters); ok && operationalStore.ResourceGroupID != nil {
+				return *operationalStore.ResourceGroupID
+			}
+		}
+	}
+
+	return parentResourceGroupID(valueOf(instance.Properties.DataSourceInfo.ResourceID))
+}
+
+func parentResourceGroupID(resourceID string) string {
+	parsed, err := arm.ParseResourceID(resourceID)
+	if err != nil {
+		return resourceID
+	}
+
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", parsed.SubscriptionID, parsed.ResourceGroupName)
+}
+
+func securitySettings(vault armdataprotection.BackupVaultResource) *armdataprotection.SecuritySettings {
+	if vault.Properties == nil {
+		return nil
+	}
+
+	return vault.Properties.SecuritySettings
+}
+
+func valueOf[T ~string](value *T) string {
+	if value == nil {
+		return ""
+	}
+
+	return string(*value)
+}
+
+func valuesOf(values []*string) []string {
+	var result []string
+	for _, value := range values {
+		result = append(result, valueOf(value))
+	}
+
+	return result
+}
@@ -0,0 +1,209 @@
+package audit
+
+import (
+	"fmt"
+	"testing"
+
+	"e2e_tests/emulator"
+	"e2e_tests/emulator/emulatortest"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+ * Deploys the module into the emulator with the provided vault settings and retention
+ * period, and returns the ID of the vault along with a credential for the emulator.
+ */
+func deployTestVault(t *testing.T, backupVaultName string, vars map[string]interface{}, retentionPeriod string) (string, azcore.TokenCredential) {
+	credential, err := emulatortest.Shared().Credential()
+	require.NoError(t, err)
+
+	diskBackup := emulatortest.ManagedDiskBackup("disk1", emulatortest.ResourceID("rg-audit", "Microsoft.Compute/disks", "disk1"))
+	diskBackup["retention_period"] = retentionPeriod
+	diskBackup["backup_intervals"] = []string{"R/2024-01-01T00:00:00+00:00/P1D"}
+
+	moduleVars := emulatortest.Variables("rg-audit", backupVaultName)
+	moduleVars["managed_disk_backups"] = map[string]map[string]interface{}{
+		"backup1": diskBackup,
+	}
+	for key, value := range vars {
+		moduleVars[key] = value
+	}
+
+	require.NoError(t, emulatortest.Shared().Apply(emulator.SubscriptionID, moduleVars))
+
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/rg-audit/providers/Microsoft.DataProtection/backupVaults/%s", emulator.SubscriptionID, backupVaultName), credential
+}
+
+func findingsByRule(report *Report) map[string][]Finding {
+	findings := map[string][]Finding{}
+	for _, finding := range report.Findings {
+		findings[finding.RuleID] = append(findings[finding.RuleID], finding)
+	}
+
+	return findings
+}
+
+func allPassed(findings []Finding) bool {
+	for _, finding := range findings {
+		if !finding.Passed {
+			return false
+		}
+	}
+
+	return len(findings) > 0
+}
+
+/*
+ * TestAuditVaultPasses tests that a vault deployed with the module defaults passes every rule.
+ */
+func TestAuditVaultPasses(t *testing.T) {
+	vaultID, credential := deployTestVault(t, "bvault-audit-pass", nil, "P7D")
+
+	report, err := AuditVault(t.Context(), credential, emulatortest.Shared().ClientOptions(), vaultID, DefaultOptions())
+	require.NoError(t, err)
+
+	assert.Equal(t, vaultID, report.VaultID)
+	assert.True(t, report.Passed(), "Expected all findings to pass: %+v", report.Findings)
+
+	findings := findingsByRule(report)
+	for _, rule := range Rules() {
+		assert.True(t, allPassed(findings[rule.ID]), "Expected findings for rule %s", rule.ID)
+	}
+
+	assert.Len(t, findings["diagnostic-logs"], len(ExpectedLogCategories))
+	assert.Len(t, findings["instance-role-assignments"], 2)
+	assert.Equal(t, "P7D", findings["policy-retention"][0].Actual)
+	assert.Equal(t, "R/2024-01-01T00:00:00+00:00/P1D", findings["policy-backup-intervals"][0].Actual)
+	assert.Equal(t, "Disk Backup Reader", findings["instance-role-assignments"][1].Expected)
+	assert.Contains(t, findings["instance-role-assignments"][1].ResourceID, "/disks/disk1")
+}
+
+/*
+ * TestAuditVaultViolations tests that settings which differ from those expected are reported
+ * as violations, and that extended retention allows longer retention periods.
+ */
+func TestAuditVaultViolations(t *testing.T) {
+	vaultID, credential := deployTestVault(t, "bvault-audit-fail", map[string]interface{}{
+		"backup_vault_immutability": "Unlocked",
+		"backup_vault_soft_delete":  "On",
+		"backup_vault_redundancy":   "GeoRedundant",
+	}, "P30D")
+
+	report, err := AuditVault(t.Context(), credential, emulatortest.Shared().ClientOptions(), vaultID, DefaultOptions())
+	require.NoError(t, err)
+
+	findings := findingsByRule(report)
+	assert.False(t, report.Passed())
+	assert.Equal(t, 4, report.Failures())
+	assert.Equal(t, "Immutability is Unlocked, expected Disabled", findings["vault-immutability"][0].Message)
+	assert.Equal(t, "Soft delete is On, expected Off", findings["vault-soft-delete"][0].Message)
+	assert.Equal(t, Finding{
+		RuleID:      "vault-redundancy",
+		Description: "Backup vault redundancy matches the expected setting",
+		ResourceID:  vaultID,
+		Expected:    "LocallyRedundant",
+		Actual:      "GeoRedundant",
+		Message:     "Redundancy is GeoRedundant, expected LocallyRedundant",
+	}, findings["vault-redundancy"][0])
+	assert.False(t, findings["policy-retention"][0].Passed)
+	assert.Equal(t, "P30D", findings["policy-retention"][0].Actual)
+	assert.Contains(t, findings["policy-retention"][0].ResourceID, "/backupPolicies/")
+	assert.True(t, allPassed(findings["vault-identity"]))
+	assert.True(t, allPassed(findings["diagnostic-logs"]))
+	assert.True(t, allPassed(findings["diagnostic-metrics"]))
+	assert.True(t, allPassed(findings["instance-role-assignments"]))
+
+	report, err = AuditVault(t.Context(), credential, emulatortest.Shared().ClientOptions(), vaultID, Options{
+		Immutability:      "Unlocked",
+		SoftDelete:        "On",
+		Redundancy:        "GeoRedundant",
+		ExtendedRetention: true,
+	})
+	require.NoError(t, err)
+	assert.True(t, report.Passed(), "Expected all findings to pass: %+v", report.Findings)
+}
+
+/*
+ * TestAuditVaultInvalidID tests that a resource ID which isn't a backup vault is rejected.
+ */
+func TestAuditVaultInvalidID(t *testing.T) {
+	_, err := AuditVault(t.Context(), nil, nil, "not-a-resource-id", DefaultOptions())
+	assert.ErrorContains(t, err, "failed to parse vault resource ID")
+
+	_, err = AuditVault(t.Context(), nil, nil, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/sa", DefaultOptions())
+	assert.ErrorContains(t, err, "is not a backup vault")
+}
+
+/*
+ * TestEvaluateMissingSettings tests that a vault without an identity or diagnostic settings,
+ * and with an instance whose roles haven't been assigned, fails the corresponding rules.
+ */
+func TestEvaluateMissingSettings(t *testing.T) {
+	diskID := "/subscriptions/sub/resourceGroups/rg-disks/providers/Microsoft.Compute/disks/disk1"
+	instance := &armdataprotection.BackupInstanceResource{
+		ID:   to.Ptr("instance-id"),
+		Name: to.Ptr("bkinst-disk-disk1"),
+		Properties: &armdataprotection.BackupInstance{
+			DataSourceInfo: &armdataprotection.Datasource{
+				ResourceID:     to.Ptr(diskID),
+				DatasourceType: to.Ptr("Microsoft.Compute/disks"),
+			},
+			PolicyInfo: &armdataprotection.PolicyInfo{PolicyID: to.Ptr("missing-policy-id")},
+		},
+	}
+
+	report := Evaluate(&Resources{
+		Vault: armdataprotection.BackupVaultResource{
+			ID: to.Ptr("vault-id"),
+			Properties: &armdataprotection.BackupVault{
+				StorageSettings: []*armdataprotection.StorageSetting{
+					{Type: to.Ptr(armdataprotection.StorageSettingTypesLocallyRedundant)},
+				},
+			},
+		},
+		Policies: []*armdataprotection.BaseBackupPolicyResource{
+			{
+				ID:   to.Ptr("policy-id"),
+				Name: to.Ptr("bkpol-disk-disk1"),
+				Properties: &armdataprotection.BackupPolicy{
+					DatasourceTypes: []*string{to.Ptr("Microsoft.Compute/disks")},
+					PolicyRules: []armdataprotection.BasePolicyRuleClassification{
+						&armdataprotection.AzureBackupRule{
+							Trigger: &armdataprotection.ScheduleBasedTriggerContext{
+								Schedule: &armdataprotection.BackupSchedule{
+									RepeatingTimeIntervals: []*string{to.Ptr("R/2024-01-01T00:00:00+00:00/P1W")},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Instances: []*armdataprotection.BackupInstanceResource{instance},
+	}, DefaultOptions())
+
+	findings := findingsByRule(report)
+	assert.Equal(t, "vault-id", report.VaultID)
+	assert.True(t, allPassed(findings["vault-immutability"]))
+	assert.True(t, allPassed(findings["vault-soft-delete"]))
+	assert.True(t, allPassed(findings["vault-redundancy"]))
+	assert.Equal(t, "Backup vault has no managed identity", findings["vault-identity"][0].Message)
+	assert.Equal(t, "Backup vault has no diagnostic settings", findings["diagnostic-logs"][0].Message)
+	assert.Equal(t, "Backup vault has no diagnostic settings", findings["diagnostic-metrics"][0].Message)
+	assert.Empty(t, findings["policy-retention"])
+	assert.False(t, findings["policy-backup-intervals"][0].Passed)
+	assert.Equal(t, "R/<timestamp>/PT1H|PT2H|PT4H|PT6H|PT8H|PT12H|P1D", findings["policy-backup-intervals"][0].Expected)
+	assert.Equal(t, "Instance bkinst-disk-disk1 uses policy 'missing-policy-id', which isn't in the vault", findings["instance-policy"][0].Message)
+	assert.Equal(t, []RoleRequirement{
+		{Scope: "/subscriptions/sub/resourceGroups/rg-disks", RoleName: "Disk Snapshot Contributor"},
+		{Scope: diskID, RoleName: "Disk Backup Reader"},
+	}, RequiredRoles(instance))
+	assert.Len(t, findings["instance-role-assignments"], 2)
+	assert.False(t, findings["instance-role-assignments"][0].Passed)
+	assert.Equal(t, "not assigned", findings["instance-role-assignments"][0].Actual)
+}
@@ -0,0 +1,288 @@
+package audit
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+ * The formats that a report can be written in.
+ */
+type Format string
+
+const (
+	FormatText  Format = "text"
+	FormatJSON  Format = "json"
+	FormatJUnit Format = "junit"
+	FormatSARIF Format = "sarif"
+)
+
+/*
+ * Formats returns the formats that a report can be written in.
+ */
+func Formats() []Format {
+	return []Format{FormatText, FormatJSON, FormatJUnit, FormatSARIF}
+}
+
+/*
+ * Extension returns the file extension used for reports written in the format.
+ */
+func (format Format) Extension() string {
+	switch format {
+	case FormatJSON:
+		return ".json"
+	case FormatJUnit:
+		return ".junit.xml"
+	case FormatSARIF:
+		return ".sarif"
+	default:
+		return ".txt"
+	}
+}
+
+/*
+ * Write writes the report in the provided format.
+ */
+func (report *Report) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatText:
+		return report.WriteText(w)
+	case FormatJSON:
+		return report.WriteJSON(w)
+	case FormatJUnit:
+		return report.WriteJUnit(w)
+	case FormatSARIF:
+		return report.WriteSARIF(w)
+	default:
+		return fmt.Errorf("format %q is not supported", format)
+	}
+}
+
+/*
+ * WriteText writes the report as a line per finding, followed by a summary.
+ */
+func (report *Report) WriteText(w io.Writer) error {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "Audit of %s\n\n", report.VaultID)
+
+	for _, finding := range report.Findings {
+		status := "PASS"
+		if !finding.Passed {
+			status = "FAIL"
+		}
+
+		fmt.Fprintf(&builder, "%s  %-26s %s\n", status, finding.RuleID, finding.Message)
+	}
+
+	fmt.Fprintf(&builder, "\n%d of %d checks passed\n", len(report.Findings)-report.Failures(), len(report.Findings))
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+/*
+ * WriteJSON writes the report as indented JSON.
+ */
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(struct {
+		VaultID  string    `json:"vaultId"`
+		Passed   bool      `json:"passed"`
+		Failures int       `json:"failures"`
+		Findings []Finding `json:"findings"`
+	}{
+		VaultID:  report.VaultID,
+		Passed:   report.Passed(),
+		Failures: report.Failures(),
+		Findings: report.Findings,
+	})
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+/*
+ * WriteJUnit writes the report as JUnit XML, with a test case per finding grouped into a
+ * suite for the vault, so that findings show up in CI test reports.
+ */
+func (report *Report) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:     report.VaultID,
+		Tests:    len(report.Findings),
+		Failures: report.Failures(),
+	}
+
+	for _, finding := range report.Findings {
+		testCase := junitTestCase{
+			ClassName: finding.RuleID,
+			Name:      finding.ResourceID + " " + finding.Expected,
+		}
+
+		details := fmt.Sprintf("Resource: %s\nExpected: %s\nActual: %s", finding.ResourceID, finding.Expected, finding.Actual)
+		if finding.Passed {
+			testCase.SystemOut = details
+		} else {
+			testCase.Failure = &junitFailure{
+				Message: finding.Message,
+				Type:    finding.RuleID,
+				Text:    details,
+			}
+		}
+
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Tests: suite.Tests, Failures: suite.Failures, Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "vault-audit"
+	toolURI      = "https://github.com/NHSDigital/az-backup"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+/*
+ * WriteSARIF writes the failed findings in the report as SARIF 2.1.0, for upload to the GitHub
+ * security tab. Each result is located at the terraform that implements its rule, with the
+ * Azure resource that failed the rule as a logical location.
+ */
+func (report *Report) WriteSARIF(w io.Writer) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           toolName,
+			InformationURI: toolURI,
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, rule := range Rules() {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: rule.ID, ShortDescription: sarifMessage{Text: rule.Description}})
+	}
+
+	for _, finding := range report.Findings {
+		if finding.Passed {
+			continue
+		}
+
+		rule, _ := GetRule(finding.RuleID)
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  finding.RuleID,
+			Level:   "error",
+			Message: sarifMessage{Text: fmt.Sprintf("%s (resource: %s, expected: %s, actual: %s)", finding.Message, finding.ResourceID, finding.Expected, finding.Actual)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: rule.Source}},
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: finding.ResourceID, Kind: "resource"}},
+			}},
+			Properties: map[string]any{
+				"resourceId": finding.ResourceID,
+				"expected":   finding.Expected,
+				"actual":     finding.Actual,
+			},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testVaultID = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.DataProtection/backupVaults/vault"
+
+func newTestReport() *Report {
+	return &Report{
+		VaultID: testVaultID,
+		Findings: []Finding{
+			{
+				RuleID:      "vault-identity",
+				Description: "Backup vault has a SystemAssigned managed identity",
+				ResourceID:  testVaultID,
+				Expected:    "SystemAssigned",
+				Actual:      "SystemAssigned",
+				Passed:      true,
+				Message:     "SystemAssigned identity with principal ID abc",
+			},
+			{
+				RuleID:      "policy-retention",
+				Description: "Backup policy retention is no longer than P7D unless extended retention is intended",
+				ResourceID:  testVaultID + "/backupPolicies/bkpol-blob-blob1",
+				Expected:    "P7D or less",
+				Actual:      "P30D",
+				Message:     "Policy bkpol-blob-blob1 retains backups for P30D, which is longer than P7D",
+			},
+		},
+	}
+}
+
+/*
+ * TestWriteJSON tests that the report is written as JSON with a summary of the findings.
+ */
+func TestWriteJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, newTestReport().Write(&buffer, FormatJSON))
+
+	var output struct {
+		VaultID  string    `json:"vaultId"`
+		Passed   bool      `json:"passed"`
+		Failures int       `json:"failures"`
+		Findings []Finding `json:"findings"`
+	}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &output))
+
+	assert.Equal(t, testVaultID, output.VaultID)
+	assert.False(t, output.Passed)
+	assert.Equal(t, 1, output.Failures)
+	assert.Len(t, output.Findings, len(newTestReport().Findings))
+}
+
+/*
+ * TestWriteJUnit tests that the report is written as JUnit XML, with a failure for each
+ * failed finding.
+ */
+func TestWriteJUnit(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, newTestReport().Write(&buffer, FormatJUnit))
+
+	var output junitTestSuites
+	require.NoError(t, xml.Unmarshal(buffer.Bytes(), &output))
+
+	assert.Equal(t, 2, output.Tests)
+	assert.Equal(t, 1, output.Failures)
+	require.Len(t, output.Suites, 1)
+	assert.Equal(t, testVaultID, output.Suites[0].Name)
+
+	cases := output.Suites[0].Cases
+	require.Len(t, cases, 2)
+	assert.Equal(t, "vault-identity", cases[0].ClassName)
+	assert.Nil(t, cases[0].Failure)
+	assert.Equal(t, "policy-retention", cases[1].ClassName)
+	require.NotNil(t, cases[1].Failure)
+	assert.Equal(t, "Policy bkpol-blob-blob1 retains backups for P30D, which is longer than P7D", cases[1].Failure.Message)
+	assert.Contains(t, cases[1].Failure.Text, "Expected: P7D or less\nActual: P30D")
+}
+
+/*
+ * TestWriteSARIF tests that the failed findings are written as SARIF results, located at
+ * the terraform that implements their rule.
+ */
+func TestWriteSARIF(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, newTestReport().Write(&buffer, FormatSARIF))
+
+	var output sarifLog
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &output))
+
+	assert.Equal(t, "2.1.0", output.Version)
+	require.Len(t, output.Runs, 1)
+
+	run := output.Runs[0]
+	assert.Equal(t, "vault-audit", run.Tool.Driver.Name)
+	assert.Len(t, run.Tool.Driver.Rules, len(Rules()))
+
+	require.Len(t, run.Results, 1)
+	result := run.Results[0]
+	assert.Equal(t, "policy-retention", result.RuleID)
+	assert.Equal(t, "error", result.Level)
+	assert.Equal(t, "infrastructure/variables.tf", result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, testVaultID+"/backupPolicies/bkpol-blob-blob1", result.Locations[0].LogicalLocations[0].FullyQualifiedName)
+	assert.Equal(t, "P30D", result.Properties["actual"])
+	assert.Equal(t, "P7D or less", result.Properties["expected"])
+}
+
+/*
+ * TestWriteText tests that the report is written as a line per finding and a summary.
+ */
+func TestWriteText(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, newTestReport().Write(&buffer, FormatText))
+
+	assert.Contains(t, buffer.String(), "PASS  vault-identity")
+	assert.Contains(t, buffer.String(), "FAIL  policy-retention")
+	assert.Contains(t, buffer.String(), "1 of 2 checks passed")
+
+	assert.ErrorContains(t, newTestReport().Write(&buffer, "yaml"), `format "yaml" is not supported`)
+}
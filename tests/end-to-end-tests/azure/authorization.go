@@ -0,0 +1,140 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization"
+	"github.com/google/uuid"
+)
+
+/*
+ * Gets a role definition for the provided role name, or nil if there isn't one.
+ */
+func GetRoleDefinition(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, roleName string) (*armauthorization.RoleDefinition, error) {
+	roleDefinitionsClient, err := armauthorization.NewRoleDefinitionsClient(credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role definition client: %w", err)
+	}
+
+	// Create a pager to list role definitions
+	filter := fmt.Sprintf("roleName eq '%s'", roleName)
+	pager := roleDefinitionsClient.NewListPager("", &armauthorization.RoleDefinitionsClientListOptions{Filter: &filter})
+
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list role definitions: %w", err)
+		}
+
+		for _, roleDefinition := range page.RoleDefinitionListResult.Value {
+			if *roleDefinition.Properties.RoleName == roleName {
+				return roleDefinition, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+/*
+ * Gets a role assignment in the provided scope for the provided role definition,
+ * that's been assigned to the provided principal id, or nil if there isn't one.
+ */
+func GetRoleAssignment(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	principalId string, roleDefinition *armauthorization.RoleDefinition, scope string) (*armauthorization.RoleAssignment, error) {
+	roleAssignmentsClient, err := armauthorization.NewRoleAssignmentsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role assignments client: %w", err)
+	}
+
+	// List role assignments for the given scope
+	filter := fmt.Sprintf("principalId eq '%s'", principalId)
+	pager := roleAssignmentsClient.NewListForScopePager(scope, &armauthorization.RoleAssignmentsClientListForScopeOptions{Filter: &filter})
+
+	// Find the role assignment for the given definition
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list role assignments: %w", err)
+		}
+
+		// Check if the role definition is among the assigned roles
+		for _, roleAssignment := range page.RoleAssignmentListResult.Value {
+			// Use string.contains, as the role definition ID on a role assignment
+			// is a longer URI which includes the subscription scope
+			if strings.Contains(*roleAssignment.Properties.RoleDefinitionID, *roleDefinition.ID) {
+				return roleAssignment, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+/*
+ * Assigns a built-in role to a principal in the provided scope.
+ */
+func CreateRoleAssignment(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, scope string, roleName string, principalId string) (armauthorization.RoleAssignment, error) {
+	roleDefinition, err := GetRoleDefinition(ctx, credential, clientOptions, roleName)
+	if err != nil {
+		return armauthorization.RoleAssignment{}, err
+	}
+	if roleDefinition == nil {
+		return armauthorization.RoleAssignment{}, fmt.Errorf("role definition '%s' not found", roleName)
+	}
+
+	client, err := armauthorization.NewRoleAssignmentsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armauthorization.RoleAssignment{}, fmt.Errorf("failed to create role assignments client: %w", err)
+	}
+
+	resp, err := client.Create(ctx, scope, uuid.NewString(), armauthorization.RoleAssignmentCreateParameters{
+		Properties: &armauthorization.RoleAssignmentProperties{
+			PrincipalID:      &principalId,
+			RoleDefinitionID: roleDefinition.ID,
+		},
+	}, nil)
+	if err != nil {
+		return armauthorization.RoleAssignment{}, fmt.Errorf("failed to create role assignment: %w", err)
+	}
+
+	log.Printf("Role '%s' assigned to principal '%s' in scope '%s'", roleName, principalId, scope)
+
+	return resp.RoleAssignment, nil
+}
+
+/*
+ * Waits for a role assignment to be visible through the role assignments API. A new assignment
+ * can take several minutes to propagate, and until then an operation which relies on it (such
+ * as a restore) usually fails.
+ */
+func WaitForRoleAssignment(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, scope string, roleName string, principalId string) error {
+	roleDefinition, err := GetRoleDefinition(ctx, credential, clientOptions, roleName)
+	if err != nil {
+		return err
+	}
+	if roleDefinition == nil {
+		return fmt.Errorf("role definition '%s' not found", roleName)
+	}
+
+	err = wait.Until(ctx, &wait.Options{Timeout: 10 * time.Minute, InitialInterval: 10 * time.Second, MaxInterval: time.Minute}, func(ctx context.Context) (bool, error) {
+		roleAssignment, err := GetRoleAssignment(ctx, credential, clientOptions, subscriptionID, principalId, roleDefinition, scope)
+
+		return roleAssignment != nil, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed waiting for role '%s' to be assigned to principal '%s': %w", roleName, principalId, err)
+	}
+
+	log.Printf("Role '%s' assignment to principal '%s' in scope '%s' is visible", roleName, principalId, scope)
+
+	return nil
+}
@@ -0,0 +1,318 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"time"
+
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/pageblob"
+	"github.com/google/uuid"
+)
+
+/*
+ * Creates an empty managed disk.
+ */
+func CreateManagedDisk(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, diskName string, diskLocation string, diskSizeGB int32) (armcompute.Disk, error) {
+	client, err := armcompute.NewDisksClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armcompute.Disk{}, fmt.Errorf("failed to create disks client: %w", err)
+	}
+
+	log.Printf("Creating managed disk %s in location %s", diskName, diskLocation)
+
+	pollerResp, err := client.BeginCreateOrUpdate(
+		ctx,
+		resourceGroupName,
+		diskName,
+		armcompute.Disk{
+			Location: &diskLocation,
+			SKU: &armcompute.DiskSKU{
+				Name: to.Ptr(armcompute.DiskStorageAccountTypesStandardLRS),
+			},
+			Properties: &armcompute.DiskProperties{
+				DiskSizeGB:   &diskSizeGB,
+				CreationData: &armcompute.CreationData{CreateOption: to.Ptr(armcompute.DiskCreateOptionEmpty)},
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		return armcompute.Disk{}, fmt.Errorf("failed to begin creating managed disk: %w", err)
+	}
+
+	// Wait for the creation to complete
+	resp, err := wait.ForOperation(ctx, pollerResp, nil)
+	if err != nil {
+		return armcompute.Disk{}, fmt.Errorf("failed to create managed disk: %w", err)
+	}
+
+	log.Printf("Managed disk %s created successfully", diskName)
+
+	return resp.Disk, nil
+}
+
+/*
+ * Creates a managed disk holding the provided data.
+ *
+ * Azure only allows a managed disk to be written to directly while it's being uploaded, so the
+ * disk is created for upload, the data is written from the start of the disk followed by the
+ * fixed VHD footer that Azure requires, and access is then revoked to make the disk usable.
+ */
+func CreateManagedDiskWithData(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, diskName string, diskLocation string, diskSizeGB int32, data []byte) (armcompute.Disk, error) {
+	client, err := armcompute.NewDisksClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armcompute.Disk{}, fmt.Errorf("failed to create disks client: %w", err)
+	}
+
+	diskSizeBytes := int64(diskSizeGB) << 30
+
+	// Pages must be written in multiples of 512 bytes, and the data must end before the footer
+	padded := append(slices.Clone(data), make([]byte, (vhdFooterSize-len(data)%vhdFooterSize)%vhdFooterSize)...)
+	if int64(len(padded)) > diskSizeBytes {
+		return armcompute.Disk{}, fmt.Errorf("failed to create managed disk: %d bytes of data don't fit on a %d GB disk", len(data), diskSizeGB)
+	}
+
+	log.Printf("Creating managed disk %s in location %s for upload", diskName, diskLocation)
+
+	pollerResp, err := client.BeginCreateOrUpdate(
+		ctx,
+		resourceGroupName,
+		diskName,
+		armcompute.Disk{
+			Location: &diskLocation,
+			SKU: &armcompute.DiskSKU{
+				Name: to.Ptr(armcompute.DiskStorageAccountTypesStandardLRS),
+			},
+			Properties: &armcompute.DiskProperties{
+				CreationData: &armcompute.CreationData{
+					CreateOption:    to.Ptr(armcompute.DiskCreateOptionUpload),
+					UploadSizeBytes: to.Ptr(diskSizeBytes + vhdFooterSize),
+				},
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		return armcompute.Disk{}, fmt.Errorf("failed to begin creating managed disk: %w", err)
+	}
+
+	if _, err = wait.ForOperation(ctx, pollerResp, nil); err != nil {
+		return armcompute.Disk{}, fmt.Errorf("failed to create managed disk: %w", err)
+	}
+
+	if err := writeManagedDiskData(ctx, client, clientOptions, resourceGroupName, diskName, diskSizeBytes, padded); err != nil {
+		return armcompute.Disk{}, err
+	}
+
+	log.Printf("Managed disk %s created successfully with %d bytes of data", diskName, len(data))
+
+	return GetManagedDisk(ctx, credential, clientOptions, subscriptionID, resourceGroupName, diskName)
+}
+
+/*
+ * Writes padded data from the start of a managed disk that's been created for upload, followed
+ * by the VHD footer. Write access is always revoked afterwards, as a disk that's left in the
+ * ActiveUpload state can't be deleted.
+ */
+func writeManagedDiskData(ctx context.Context, client *armcompute.DisksClient, clientOptions *arm.ClientOptions, resourceGroupName string, diskName string, diskSizeBytes int64, padded []byte) (err error) {
+	accessURI, err := grantManagedDiskAccess(ctx, client, resourceGroupName, diskName, armcompute.AccessLevelWrite)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if revokeErr := revokeManagedDiskAccess(ctx, client, resourceGroupName, diskName); err == nil {
+			err = revokeErr
+		}
+	}()
+
+	pageBlobClient, err := pageblob.NewClientWithNoCredential(accessURI, (*pageblob.ClientOptions)(blobClientOptions(clientOptions)))
+	if err != nil {
+		return fmt.Errorf("failed to create page blob client: %w", err)
+	}
+
+	// Pages can be written at most 4MiB at a time
+	for offset := 0; offset < len(padded); offset += 4 << 20 {
+		page := padded[offset:min(offset+(4<<20), len(padded))]
+
+		_, err = pageBlobClient.UploadPages(ctx, streaming.NopCloser(bytes.NewReader(page)), blob.HTTPRange{Offset: int64(offset), Count: int64(len(page))}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to write data to managed disk: %w", err)
+		}
+	}
+
+	_, err = pageBlobClient.UploadPages(ctx, streaming.NopCloser(bytes.NewReader(newFixedVHDFooter(diskSizeBytes))), blob.HTTPRange{Offset: diskSizeBytes, Count: vhdFooterSize}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write VHD footer to managed disk: %w", err)
+	}
+
+	return nil
+}
+
+/*
+ * Gets a managed disk for the provided name.
+ */
+func GetManagedDisk(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, diskName string) (armcompute.Disk, error) {
+	client, err := armcompute.NewDisksClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armcompute.Disk{}, fmt.Errorf("failed to create disks client: %w", err)
+	}
+
+	resp, err := client.Get(ctx, resourceGroupName, diskName, nil)
+	if err != nil {
+		return armcompute.Disk{}, fmt.Errorf("failed to get managed disk: %w", err)
+	}
+
+	return resp.Disk, nil
+}
+
+/*
+ * Gets the SHA-256 checksum (hex encoded) of the first length bytes of a managed disk's data.
+ */
+func GetManagedDiskChecksum(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, diskName string, length int64) (checksum string, err error) {
+	client, err := armcompute.NewDisksClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return "", fmt.Errorf("failed to create disks client: %w", err)
+	}
+
+	accessURI, err := grantManagedDiskAccess(ctx, client, resourceGroupName, diskName, armcompute.AccessLevelRead)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if revokeErr := revokeManagedDiskAccess(ctx, client, resourceGroupName, diskName); err == nil {
+			err = revokeErr
+		}
+	}()
+
+	blobClient, err := blob.NewClientWithNoCredential(accessURI, (*blob.ClientOptions)(blobClientOptions(clientOptions)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create blob client: %w", err)
+	}
+
+	resp, err := blobClient.DownloadStream(ctx, &blob.DownloadStreamOptions{
+		Range: blob.HTTPRange{Offset: 0, Count: length},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read managed disk data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to read managed disk data: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/*
+ * Grants SAS access to a managed disk's data, and returns the access URI.
+ */
+func grantManagedDiskAccess(ctx context.Context, client *armcompute.DisksClient, resourceGroupName string, diskName string, accessLevel armcompute.AccessLevel) (string, error) {
+	pollerResp, err := client.BeginGrantAccess(ctx, resourceGroupName, diskName, armcompute.GrantAccessData{
+		Access:            to.Ptr(accessLevel),
+		DurationInSeconds: to.Ptr(int32(3600)),
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin granting access to managed disk: %w", err)
+	}
+
+	resp, err := wait.ForOperation(ctx, pollerResp, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to grant access to managed disk: %w", err)
+	}
+
+	return *resp.AccessSAS, nil
+}
+
+/*
+ * Revokes SAS access to a managed disk's data.
+ */
+func revokeManagedDiskAccess(ctx context.Context, client *armcompute.DisksClient, resourceGroupName string, diskName string) error {
+	pollerResp, err := client.BeginRevokeAccess(ctx, resourceGroupName, diskName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin revoking access to managed disk: %w", err)
+	}
+
+	if _, err = wait.ForOperation(ctx, pollerResp, nil); err != nil {
+		return fmt.Errorf("failed to revoke access to managed disk: %w", err)
+	}
+
+	return nil
+}
+
+const vhdFooterSize = 512
+
+/*
+ * Creates the footer of a fixed size VHD for a disk of the provided size, as described by
+ * the Virtual Hard Disk Image Format Specification.
+ */
+func newFixedVHDFooter(diskSizeBytes int64) []byte {
+	footer := make([]byte, vhdFooterSize)
+
+	copy(footer[0:8], "conectix")
+	binary.BigEndian.PutUint32(footer[8:12], 0x00000002)  // Features (reserved bit always set)
+	binary.BigEndian.PutUint32(footer[12:16], 0x00010000) // File format version
+	binary.BigEndian.PutUint64(footer[16:24], 0xFFFFFFFFFFFFFFFF)
+	binary.BigEndian.PutUint32(footer[24:28], uint32(time.Now().Unix()-time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Unix()))
+	copy(footer[28:32], "azbk")
+	binary.BigEndian.PutUint32(footer[32:36], 0x00010000)
+	copy(footer[36:40], "Wi2k")
+	binary.BigEndian.PutUint64(footer[40:48], uint64(diskSizeBytes))
+	binary.BigEndian.PutUint64(footer[48:56], uint64(diskSizeBytes))
+
+	// Disk geometry, calculated as per the appendix of the specification
+	totalSectors := min(diskSizeBytes/512, 65535*16*255)
+	var sectorsPerTrack, heads, cylinderTimesHeads int64
+	if totalSectors >= 65535*16*63 {
+		sectorsPerTrack, heads = 255, 16
+		cylinderTimesHeads = totalSectors / sectorsPerTrack
+	} else {
+		sectorsPerTrack = 17
+		cylinderTimesHeads = totalSectors / sectorsPerTrack
+		heads = max((cylinderTimesHeads+1023)/1024, 4)
+
+		if cylinderTimesHeads >= heads*1024 || heads > 16 {
+			sectorsPerTrack, heads = 31, 16
+			cylinderTimesHeads = totalSectors / sectorsPerTrack
+		}
+
+		if cylinderTimesHeads >= heads*1024 {
+			sectorsPerTrack, heads = 63, 16
+			cylinderTimesHeads = totalSectors / sectorsPerTrack
+		}
+	}
+
+	binary.BigEndian.PutUint16(footer[56:58], uint16(cylinderTimesHeads/heads))
+	footer[58] = byte(heads)
+	footer[59] = byte(sectorsPerTrack)
+
+	binary.BigEndian.PutUint32(footer[60:64], 2) // Fixed hard disk
+	id := uuid.New()
+	copy(footer[68:84], id[:])
+
+	var checksum uint32
+	for _, b := range footer {
+		checksum += uint32(b)
+	}
+	binary.BigEndian.PutUint32(footer[64:68], ^checksum)
+
+	return footer
+}
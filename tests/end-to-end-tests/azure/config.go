@@ -0,0 +1,158 @@
+package azure
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+)
+
+/*
+ * The types of credential that can be used to authenticate with Azure.
+ */
+type CredentialType string
+
+const (
+	CredentialTypeClientSecret      CredentialType = "client_secret"
+	CredentialTypeClientCertificate CredentialType = "client_certificate"
+	CredentialTypeOIDC              CredentialType = "oidc"
+	CredentialTypeManagedIdentity   CredentialType = "managed_identity"
+	CredentialTypeAzureCLI          CredentialType = "azure_cli"
+)
+
+/*
+ * Config holds the settings needed to connect to Azure.
+ */
+type Config struct {
+	CredentialType            CredentialType
+	TenantID                  string
+	SubscriptionID            string
+	ClientID                  string
+	ClientSecret              string
+	ClientCertificatePath     string
+	ClientCertificatePassword string
+	OIDCToken                 string
+	OIDCTokenFilePath         string
+	OIDCRequestURL            string
+	OIDCRequestToken          string
+}
+
+/*
+ * LoadConfig loads the config for connecting to Azure from the environment.
+ */
+func LoadConfig() (*Config, error) {
+	credentialType, err := GetCredentialType()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		CredentialType:            credentialType,
+		TenantID:                  os.Getenv("ARM_TENANT_ID"),
+		SubscriptionID:            os.Getenv("ARM_SUBSCRIPTION_ID"),
+		ClientID:                  os.Getenv("ARM_CLIENT_ID"),
+		ClientSecret:              os.Getenv("ARM_CLIENT_SECRET"),
+		ClientCertificatePath:     os.Getenv("ARM_CLIENT_CERTIFICATE_PATH"),
+		ClientCertificatePassword: os.Getenv("ARM_CLIENT_CERTIFICATE_PASSWORD"),
+		OIDCToken:                 os.Getenv("ARM_OIDC_TOKEN"),
+		OIDCTokenFilePath:         getFirstEnv("ARM_OIDC_TOKEN_FILE_PATH", "AZURE_FEDERATED_TOKEN_FILE"),
+		OIDCRequestURL:            getFirstEnv("ARM_OIDC_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_URL"),
+		OIDCRequestToken:          getFirstEnv("ARM_OIDC_REQUEST_TOKEN", "ACTIONS_ID_TOKEN_REQUEST_TOKEN"),
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+/*
+ * Gets the type of credential to authenticate with. ARM_CREDENTIAL_TYPE selects the type
+ * explicitly, otherwise it's inferred from the same environment variables that the azurerm
+ * terraform provider uses, falling back to a client secret.
+ */
+func GetCredentialType() (CredentialType, error) {
+	if credentialType := os.Getenv("ARM_CREDENTIAL_TYPE"); credentialType != "" {
+		switch CredentialType(credentialType) {
+		case CredentialTypeClientSecret, CredentialTypeClientCertificate, CredentialTypeOIDC, CredentialTypeManagedIdentity, CredentialTypeAzureCLI:
+			return CredentialType(credentialType), nil
+		default:
+			return "", fmt.Errorf("ARM_CREDENTIAL_TYPE %q is not supported, must be one of: %s, %s, %s, %s, %s", credentialType,
+				CredentialTypeClientSecret, CredentialTypeClientCertificate, CredentialTypeOIDC, CredentialTypeManagedIdentity, CredentialTypeAzureCLI)
+		}
+	}
+
+	switch {
+	case os.Getenv("ARM_USE_OIDC") == "true":
+		return CredentialTypeOIDC, nil
+	case os.Getenv("ARM_USE_MSI") == "true":
+		return CredentialTypeManagedIdentity, nil
+	case os.Getenv("ARM_USE_CLI") == "true":
+		return CredentialTypeAzureCLI, nil
+	case os.Getenv("ARM_CLIENT_CERTIFICATE_PATH") != "":
+		return CredentialTypeClientCertificate, nil
+	default:
+		return CredentialTypeClientSecret, nil
+	}
+}
+
+/*
+ * Validates that the settings required by the config's credential type have been provided.
+ */
+func (config *Config) Validate() error {
+	required := map[string]string{
+		"ARM_SUBSCRIPTION_ID": config.SubscriptionID,
+	}
+
+	switch config.CredentialType {
+	case CredentialTypeClientSecret:
+		required["ARM_TENANT_ID"] = config.TenantID
+		required["ARM_CLIENT_ID"] = config.ClientID
+		required["ARM_CLIENT_SECRET"] = config.ClientSecret
+	case CredentialTypeClientCertificate:
+		required["ARM_TENANT_ID"] = config.TenantID
+		required["ARM_CLIENT_ID"] = config.ClientID
+		required["ARM_CLIENT_CERTIFICATE_PATH"] = config.ClientCertificatePath
+	case CredentialTypeOIDC:
+		required["ARM_TENANT_ID"] = config.TenantID
+		required["ARM_CLIENT_ID"] = config.ClientID
+
+		if config.OIDCToken == "" && config.OIDCTokenFilePath == "" && (config.OIDCRequestURL == "" || config.OIDCRequestToken == "") {
+			return fmt.Errorf("ARM_OIDC_TOKEN, ARM_OIDC_TOKEN_FILE_PATH or ARM_OIDC_REQUEST_URL and ARM_OIDC_REQUEST_TOKEN must be set")
+		}
+	case CredentialTypeManagedIdentity, CredentialTypeAzureCLI:
+		// The tenant and client id are optional, and select the tenant or user assigned identity
+	default:
+		return fmt.Errorf("credential type %q is not supported", config.CredentialType)
+	}
+
+	return RequireSettings(required)
+}
+
+/*
+ * RequireSettings returns an error naming the first (in name order) of the provided settings
+ * which hasn't been set.
+ */
+func RequireSettings(settings map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		if settings[name] == "" {
+			return fmt.Errorf("%s must be set", name)
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Gets the value of the first of the provided environment variables that is set.
+ */
+func getFirstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,169 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+ * TestCredentialTypeSelection tests that the credential type is selected from the
+ * environment, either explicitly or inferred from the azurerm provider settings.
+ */
+func TestCredentialTypeSelection(t *testing.T) {
+	testCases := map[string]struct {
+		env      map[string]string
+		expected CredentialType
+	}{
+		"DefaultsToClientSecret":    {env: map[string]string{}, expected: CredentialTypeClientSecret},
+		"ExplicitType":              {env: map[string]string{"ARM_CREDENTIAL_TYPE": "azure_cli", "ARM_USE_OIDC": "true"}, expected: CredentialTypeAzureCLI},
+		"InferredOIDC":              {env: map[string]string{"ARM_USE_OIDC": "true"}, expected: CredentialTypeOIDC},
+		"InferredManagedIdentity":   {env: map[string]string{"ARM_USE_MSI": "true"}, expected: CredentialTypeManagedIdentity},
+		"InferredAzureCLI":          {env: map[string]string{"ARM_USE_CLI": "true"}, expected: CredentialTypeAzureCLI},
+		"InferredClientCertificate": {env: map[string]string{"ARM_CLIENT_CERTIFICATE_PATH": "cert.pem"}, expected: CredentialTypeClientCertificate},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"ARM_CREDENTIAL_TYPE", "ARM_USE_OIDC", "ARM_USE_MSI", "ARM_USE_CLI", "ARM_CLIENT_CERTIFICATE_PATH"} {
+				t.Setenv(key, testCase.env[key])
+			}
+
+			credentialType, err := GetCredentialType()
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, credentialType)
+		})
+	}
+
+	t.Run("UnsupportedType", func(t *testing.T) {
+		t.Setenv("ARM_CREDENTIAL_TYPE", "password")
+
+		_, err := GetCredentialType()
+		assert.ErrorContains(t, err, "ARM_CREDENTIAL_TYPE \"password\" is not supported")
+	})
+}
+
+/*
+ * TestConfigValidation tests that each credential type requires its own settings.
+ */
+func TestConfigValidation(t *testing.T) {
+	newConfig := func(credentialType CredentialType) *Config {
+		return &Config{
+			CredentialType: credentialType,
+			SubscriptionID: "subscription",
+		}
+	}
+
+	config := newConfig(CredentialTypeClientSecret)
+	config.TenantID = "tenant"
+	config.ClientID = "client"
+	assert.EqualError(t, config.Validate(), "ARM_CLIENT_SECRET must be set")
+
+	config = newConfig(CredentialTypeClientCertificate)
+	config.TenantID = "tenant"
+	config.ClientID = "client"
+	assert.EqualError(t, config.Validate(), "ARM_CLIENT_CERTIFICATE_PATH must be set")
+
+	config = newConfig(CredentialTypeOIDC)
+	config.TenantID = "tenant"
+	config.ClientID = "client"
+	assert.ErrorContains(t, config.Validate(), "ARM_OIDC_TOKEN")
+
+	config.OIDCTokenFilePath = "/var/run/secrets/azure/tokens/azure-identity-token"
+	assert.NoError(t, config.Validate())
+
+	assert.NoError(t, newConfig(CredentialTypeManagedIdentity).Validate())
+	assert.NoError(t, newConfig(CredentialTypeAzureCLI).Validate())
+
+	config = newConfig(CredentialTypeAzureCLI)
+	config.SubscriptionID = ""
+	assert.EqualError(t, config.Validate(), "ARM_SUBSCRIPTION_ID must be set")
+}
+
+/*
+ * TestNewCredential tests that a credential of the configured type is created.
+ */
+func TestNewCredential(t *testing.T) {
+	config := &Config{CredentialType: CredentialTypeClientSecret, TenantID: "tenant", ClientID: "client", ClientSecret: "secret"}
+	credential, err := NewCredential(config)
+	assert.NoError(t, err)
+	assert.IsType(t, &azidentity.ClientSecretCredential{}, credential)
+
+	config = &Config{CredentialType: CredentialTypeOIDC, TenantID: "tenant", ClientID: "client", OIDCToken: "token"}
+	credential, err = NewCredential(config)
+	assert.NoError(t, err)
+	assert.IsType(t, &azidentity.ClientAssertionCredential{}, credential)
+
+	config = &Config{CredentialType: CredentialTypeManagedIdentity, ClientID: "client"}
+	credential, err = NewCredential(config)
+	assert.NoError(t, err)
+	assert.IsType(t, &azidentity.ManagedIdentityCredential{}, credential)
+
+	config = &Config{CredentialType: CredentialTypeAzureCLI}
+	credential, err = NewCredential(config)
+	assert.NoError(t, err)
+	assert.IsType(t, &azidentity.AzureCLICredential{}, credential)
+
+	config = &Config{CredentialType: CredentialTypeClientCertificate, TenantID: "tenant", ClientID: "client", ClientCertificatePath: filepath.Join(t.TempDir(), "missing.pem")}
+	_, err = NewCredential(config)
+	assert.ErrorContains(t, err, "failed to read client certificate")
+}
+
+/*
+ * TestOIDCTokenSources tests that the federated token can be provided directly, read
+ * from a file or requested from the CI provider.
+ */
+func TestOIDCTokenSources(t *testing.T) {
+	token, err := getOIDCToken(context.Background(), &Config{OIDCToken: "direct-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "direct-token", token)
+
+	tokenFilePath := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFilePath, []byte("file-token\n"), 0600))
+
+	token, err = getOIDCToken(context.Background(), &Config{OIDCTokenFilePath: tokenFilePath})
+	assert.NoError(t, err)
+	assert.Equal(t, "file-token", token)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != "api://AzureADTokenExchange" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"value":"requested-token"}`))
+	}))
+	defer server.Close()
+
+	token, err = getOIDCToken(context.Background(), &Config{OIDCRequestURL: server.URL + "?api-version=2.0", OIDCRequestToken: "request-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "requested-token", token)
+
+	_, err = getOIDCToken(context.Background(), &Config{OIDCRequestURL: server.URL, OIDCRequestToken: "wrong-token"})
+	assert.ErrorContains(t, err, "401")
+}
+
+/*
+ * TestOIDCTokenRequestTimeout tests that a token endpoint which never responds fails the
+ * request once the client times out, rather than hanging.
+ */
+func TestOIDCTokenRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	timeout := oidcClient.Timeout
+	oidcClient.Timeout = 100 * time.Millisecond
+	defer func() { oidcClient.Timeout = timeout }()
+
+	_, err := getOIDCToken(context.Background(), &Config{OIDCRequestURL: server.URL, OIDCRequestToken: "request-token"})
+	assert.ErrorContains(t, err, "failed to request OIDC token")
+}
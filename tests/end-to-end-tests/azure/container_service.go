@@ -0,0 +1,126 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+/*
+ * The name that the aks_cluster module gives the backup extension it installs on a cluster.
+ */
+const AksBackupExtensionName = "azure-aks-backup"
+
+/*
+ * The API version used to read cluster extensions, which have no client of their own here.
+ */
+const clusterExtensionAPIVersion = "2023-05-01"
+
+/*
+ * Creates an AKS cluster with a single, small system node pool and a system assigned identity.
+ */
+func CreateAksCluster(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, clusterName string, clusterLocation string) (armcontainerservice.ManagedCluster, error) {
+	client, err := armcontainerservice.NewManagedClustersClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armcontainerservice.ManagedCluster{}, fmt.Errorf("failed to create managed clusters client: %w", err)
+	}
+
+	log.Printf("Creating AKS cluster %s in location %s", clusterName, clusterLocation)
+
+	pollerResp, err := client.BeginCreateOrUpdate(
+		ctx,
+		resourceGroupName,
+		clusterName,
+		armcontainerservice.ManagedCluster{
+			Location: &clusterLocation,
+			Identity: &armcontainerservice.ManagedClusterIdentity{
+				Type: to.Ptr(armcontainerservice.ResourceIdentityTypeSystemAssigned),
+			},
+			Properties: &armcontainerservice.ManagedClusterProperties{
+				DNSPrefix: &clusterName,
+				AgentPoolProfiles: []*armcontainerservice.ManagedClusterAgentPoolProfile{
+					{
+						Name:   to.Ptr("system"),
+						Count:  to.Ptr[int32](1),
+						VMSize: to.Ptr("Standard_D2s_v3"),
+						Mode:   to.Ptr(armcontainerservice.AgentPoolModeSystem),
+						OSType: to.Ptr(armcontainerservice.OSTypeLinux),
+					},
+				},
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		return armcontainerservice.ManagedCluster{}, fmt.Errorf("failed to begin creating AKS cluster: %w", err)
+	}
+
+	// Wait for the creation to complete
+	resp, err := wait.ForOperation(ctx, pollerResp, nil)
+	if err != nil {
+		return armcontainerservice.ManagedCluster{}, fmt.Errorf("failed to create AKS cluster: %w", err)
+	}
+
+	log.Printf("AKS cluster %s created successfully", clusterName)
+
+	return resp.ManagedCluster, nil
+}
+
+/*
+ * Gets the trusted access role bindings of an AKS cluster.
+ */
+func GetTrustedAccessRoleBindings(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, clusterName string) ([]*armcontainerservice.TrustedAccessRoleBinding, error) {
+	client, err := armcontainerservice.NewTrustedAccessRoleBindingsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trusted access role bindings client: %w", err)
+	}
+
+	var bindings []*armcontainerservice.TrustedAccessRoleBinding
+
+	pager := client.NewListPager(resourceGroupName, clusterName, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list trusted access role bindings: %w", err)
+		}
+
+		bindings = append(bindings, page.Value...)
+	}
+
+	return bindings, nil
+}
+
+/*
+ * Gets the principal ID of the identity that AKS assigns to the backup extension on a cluster.
+ */
+func GetAksBackupExtensionPrincipalID(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, clusterID string) (string, error) {
+	client, err := armresources.NewClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return "", fmt.Errorf("failed to create resources client: %w", err)
+	}
+
+	extensionID := fmt.Sprintf("%s/providers/Microsoft.KubernetesConfiguration/extensions/%s", clusterID, AksBackupExtensionName)
+
+	resp, err := client.GetByID(ctx, extensionID, clusterExtensionAPIVersion, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get backup extension: %w", err)
+	}
+
+	properties, _ := resp.Properties.(map[string]any)
+	identity, _ := properties["aksAssignedIdentity"].(map[string]any)
+	principalID, _ := identity["principalId"].(string)
+	if principalID == "" {
+		return "", fmt.Errorf("backup extension %s has no assigned identity", extensionID)
+	}
+
+	return principalID, nil
+}
@@ -0,0 +1,119 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+/*
+ * Creates a credential for authenticating with Azure Resource Manager, of the type
+ * selected by the config.
+ */
+func NewCredential(config *Config) (azcore.TokenCredential, error) {
+	switch config.CredentialType {
+	case CredentialTypeClientSecret:
+		return azidentity.NewClientSecretCredential(config.TenantID, config.ClientID, config.ClientSecret, nil)
+	case CredentialTypeClientCertificate:
+		data, err := os.ReadFile(config.ClientCertificatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client certificate: %w", err)
+		}
+
+		var password []byte
+		if config.ClientCertificatePassword != "" {
+			password = []byte(config.ClientCertificatePassword)
+		}
+
+		certificates, key, err := azidentity.ParseCertificates(data, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse client certificate: %w", err)
+		}
+
+		return azidentity.NewClientCertificateCredential(config.TenantID, config.ClientID, certificates, key, nil)
+	case CredentialTypeOIDC:
+		return azidentity.NewClientAssertionCredential(config.TenantID, config.ClientID, func(ctx context.Context) (string, error) {
+			return getOIDCToken(ctx, config)
+		}, nil)
+	case CredentialTypeManagedIdentity:
+		var options *azidentity.ManagedIdentityCredentialOptions
+		if config.ClientID != "" {
+			options = &azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ClientID(config.ClientID)}
+		}
+
+		return azidentity.NewManagedIdentityCredential(options)
+	case CredentialTypeAzureCLI:
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: config.TenantID})
+	default:
+		return nil, fmt.Errorf("credential type %q is not supported", config.CredentialType)
+	}
+}
+
+/*
+ * The client that requests OIDC tokens from the CI provider, which gives up on a stalled token
+ * endpoint rather than hanging the tests, as the SDK's own pipeline does.
+ */
+var oidcClient = &http.Client{Timeout: 30 * time.Second}
+
+/*
+ * Gets the federated OIDC token to exchange for an Entra ID token - either provided directly,
+ * read from a file (as projected by AKS workload identity) or requested from the CI provider
+ * (as with GitHub Actions).
+ */
+func getOIDCToken(ctx context.Context, config *Config) (string, error) {
+	if config.OIDCToken != "" {
+		return config.OIDCToken, nil
+	}
+
+	if config.OIDCTokenFilePath != "" {
+		token, err := os.ReadFile(config.OIDCTokenFilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read OIDC token file: %w", err)
+		}
+
+		return strings.TrimSpace(string(token)), nil
+	}
+
+	requestURL, err := url.Parse(config.OIDCRequestURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse OIDC request url: %w", err)
+	}
+
+	query := requestURL.Query()
+	query.Set("audience", "api://AzureADTokenExchange")
+	requestURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+config.OIDCRequestToken)
+
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request OIDC token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request OIDC token: %s", resp.Status)
+	}
+
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode OIDC token response: %w", err)
+	}
+
+	return body.Value, nil
+}
@@ -0,0 +1,696 @@
+package azure
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+)
+
+/*
+ * Gets a backup vault for the provided name.
+ */
+func GetBackupVault(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string) (armdataprotection.BackupVaultResource, error) {
+	client, err := armdataprotection.NewBackupVaultsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armdataprotection.BackupVaultResource{}, fmt.Errorf("failed to create data protection client: %w", err)
+	}
+
+	resp, err := client.Get(ctx, resourceGroupName, backupVaultName, nil)
+	if err != nil {
+		return armdataprotection.BackupVaultResource{}, fmt.Errorf("failed to get backup vault: %w", err)
+	}
+
+	return resp.BackupVaultResource, nil
+}
+
+/*
+ * Gets the backup policies for the provided backup vault.
+ */
+func GetBackupPolicies(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string) ([]*armdataprotection.BaseBackupPolicyResource, error) {
+	client, err := armdataprotection.NewBackupPoliciesClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data protection client: %w", err)
+	}
+
+	policyPager := client.NewListPager(resourceGroupName, backupVaultName, nil)
+
+	var policies []*armdataprotection.BaseBackupPolicyResource
+
+	for policyPager.More() {
+		page, err := policyPager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get backup policies: %w", err)
+		}
+
+		policies = append(policies, page.Value...)
+	}
+
+	return policies, nil
+}
+
+/*
+ * Gets the backup instances for the provided backup vault.
+ */
+func GetBackupInstances(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string) ([]*armdataprotection.BackupInstanceResource, error) {
+	client, err := armdataprotection.NewBackupInstancesClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data protection client: %w", err)
+	}
+
+	instancePager := client.NewListPager(resourceGroupName, backupVaultName, nil)
+
+	var instances []*armdataprotection.BackupInstanceResource
+
+	for instancePager.More() {
+		page, err := instancePager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get backup instances: %w", err)
+		}
+
+		instances = append(instances, page.Value...)
+	}
+
+	return instances, nil
+}
+
+/*
+ * Gets a backup policy from the provided list for the provided name
+ */
+func GetBackupPolicyForName(policies []*armdataprotection.BaseBackupPolicyResource, name string) *armdataprotection.BaseBackupPolicyResource {
+	for _, policy := range policies {
+		if *policy.Name == name {
+			return policy
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Gets a backup policy rules from the provided list for the provided name
+ */
+func GetBackupPolicyRuleForName(policyRules []armdataprotection.BasePolicyRuleClassification, name string) armdataprotection.BasePolicyRuleClassification {
+	for _, policyRule := range policyRules {
+		if *policyRule.GetBasePolicyRule().Name == name {
+			return policyRule
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Gets the schedule of the BackupIntervals backup rule of a backup policy, which the module sets
+ * from backup_intervals, or nil if the policy doesn't have one.
+ */
+func GetBackupPolicySchedule(backupPolicy *armdataprotection.BackupPolicy) *armdataprotection.BackupSchedule {
+	backupRule, ok := GetBackupPolicyRuleForName(backupPolicy.PolicyRules, "BackupIntervals").(*armdataprotection.AzureBackupRule)
+	if !ok {
+		return nil
+	}
+
+	trigger, ok := backupRule.Trigger.(*armdataprotection.ScheduleBasedTriggerContext)
+	if !ok {
+		return nil
+	}
+
+	return trigger.Schedule
+}
+
+/*
+ * RetentionTier is a retention rule of a backup policy, along with the tagging criteria that
+ * the policy's backup rule uses to tag backups for it. The module sets the Default tier from
+ * retention_period, and the others from retention_rules.
+ */
+type RetentionTier struct {
+	Name             string
+	IsDefault        bool
+	Priority         int64
+	Duration         string
+	DataStoreType    string
+	AbsoluteCriteria []string
+	DaysOfWeek       []string
+	WeeksOfMonth     []string
+	MonthsOfYear     []string
+}
+
+/*
+ * Gets the retention tiers of a backup policy, decoding each AzureRetentionRule and the
+ * TaggingCriteria with the same tag name, ordered by tagging priority.
+ */
+func GetBackupPolicyRetentionTiers(backupPolicy *armdataprotection.BackupPolicy) []RetentionTier {
+	taggingCriteria := map[string]*armdataprotection.TaggingCriteria{}
+	for _, policyRule := range backupPolicy.PolicyRules {
+		backupRule, ok := policyRule.(*armdataprotection.AzureBackupRule)
+		if !ok {
+			continue
+		}
+
+		trigger, ok := backupRule.Trigger.(*armdataprotection.ScheduleBasedTriggerContext)
+		if !ok {
+			continue
+		}
+
+		for _, criteria := range trigger.TaggingCriteria {
+			if criteria.TagInfo != nil && criteria.TagInfo.TagName != nil {
+				taggingCriteria[*criteria.TagInfo.TagName] = criteria
+			}
+		}
+	}
+
+	var tiers []RetentionTier
+	for _, policyRule := range backupPolicy.PolicyRules {
+		retentionRule, ok := policyRule.(*armdataprotection.AzureRetentionRule)
+		if !ok {
+			continue
+		}
+
+		tier := RetentionTier{
+			Name:      *retentionRule.Name,
+			IsDefault: retentionRule.IsDefault != nil && *retentionRule.IsDefault,
+		}
+
+		if len(retentionRule.Lifecycles) > 0 {
+			lifecycle := retentionRule.Lifecycles[0]
+			if deleteOption, ok := lifecycle.DeleteAfter.(*armdataprotection.AbsoluteDeleteOption); ok && deleteOption.Duration != nil {
+				tier.Duration = *deleteOption.Duration
+			}
+			if lifecycle.SourceDataStore != nil && lifecycle.SourceDataStore.DataStoreType != nil {
+				tier.DataStoreType = string(*lifecycle.SourceDataStore.DataStoreType)
+			}
+		}
+
+		if criteria, ok := taggingCriteria[tier.Name]; ok {
+			if criteria.TaggingPriority != nil {
+				tier.Priority = *criteria.TaggingPriority
+			}
+
+			for _, backupCriteria := range criteria.Criteria {
+				scheduleCriteria, ok := backupCriteria.(*armdataprotection.ScheduleBasedBackupCriteria)
+				if !ok {
+					continue
+				}
+
+				tier.AbsoluteCriteria = append(tier.AbsoluteCriteria, stringsOf(scheduleCriteria.AbsoluteCriteria)...)
+				tier.DaysOfWeek = append(tier.DaysOfWeek, stringsOf(scheduleCriteria.DaysOfTheWeek)...)
+				tier.WeeksOfMonth = append(tier.WeeksOfMonth, stringsOf(scheduleCriteria.WeeksOfTheMonth)...)
+				tier.MonthsOfYear = append(tier.MonthsOfYear, stringsOf(scheduleCriteria.MonthsOfYear)...)
+			}
+		}
+
+		tiers = append(tiers, tier)
+	}
+
+	slices.SortStableFunc(tiers, func(a RetentionTier, b RetentionTier) int {
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+
+	return tiers
+}
+
+/*
+ * Gets a retention tier from the provided list for the provided name
+ */
+func GetRetentionTierForName(tiers []RetentionTier, name string) *RetentionTier {
+	for index := range tiers {
+		if tiers[index].Name == name {
+			return &tiers[index]
+		}
+	}
+
+	return nil
+}
+
+func valueOf[T ~string](value *T) string {
+	if value == nil {
+		return ""
+	}
+
+	return string(*value)
+}
+
+func stringsOf[T ~string](values []*T) []string {
+	var result []string
+	for _, value := range values {
+		if value != nil {
+			result = append(result, string(*value))
+		}
+	}
+
+	return result
+}
+
+/*
+ * Gets a backup instance from the provided list for the provided name
+ */
+func GetBackupInstanceForName(instances []*armdataprotection.BackupInstanceResource, name string) *armdataprotection.BackupInstanceResource {
+	for _, instance := range instances {
+		if *instance.Name == name {
+			return instance
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Lists the backup vaults in a resource group.
+ */
+func ListBackupVaults(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string) ([]*armdataprotection.BackupVaultResource, error) {
+	client, err := armdataprotection.NewBackupVaultsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data protection client: %w", err)
+	}
+
+	vaultPager := client.NewGetInResourceGroupPager(resourceGroupName, nil)
+
+	var vaults []*armdataprotection.BackupVaultResource
+
+	for vaultPager.More() {
+		page, err := vaultPager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list backup vaults: %w", err)
+		}
+
+		vaults = append(vaults, page.Value...)
+	}
+
+	return vaults, nil
+}
+
+/*
+ * Updates the immutability setting on a backup vault, and waits for the update to complete.
+ */
+func UpdateBackupVaultImmutability(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string, immutabilitySettings armdataprotection.ImmutabilitySettings) error {
+	client, err := armdataprotection.NewBackupVaultsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create data protection client: %w", err)
+	}
+
+	// Set the immutability setting on the backup vault
+	poller, err := client.BeginUpdate(ctx, resourceGroupName, backupVaultName, armdataprotection.PatchResourceRequestInput{
+		Properties: &armdataprotection.PatchBackupVaultInput{
+			SecuritySettings: &armdataprotection.SecuritySettings{
+				ImmutabilitySettings: &immutabilitySettings,
+			},
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to set immutability setting on backup vault: %w", err)
+	}
+
+	if _, err = wait.ForOperation(ctx, poller, nil); err != nil {
+		return fmt.Errorf("failed to set immutability setting on backup vault: %w", err)
+	}
+
+	log.Printf("Immutability setting updated on backup vault '%s'", backupVaultName)
+
+	return nil
+}
+
+/*
+ * Updates the soft delete setting on a backup vault, and waits for the update to complete.
+ */
+func UpdateBackupVaultSoftDelete(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string, softDeleteSettings armdataprotection.SoftDeleteSettings) error {
+	client, err := armdataprotection.NewBackupVaultsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create data protection client: %w", err)
+	}
+
+	poller, err := client.BeginUpdate(ctx, resourceGroupName, backupVaultName, armdataprotection.PatchResourceRequestInput{
+		Properties: &armdataprotection.PatchBackupVaultInput{
+			SecuritySettings: &armdataprotection.SecuritySettings{
+				SoftDeleteSettings: &softDeleteSettings,
+			},
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to set soft delete setting on backup vault: %w", err)
+	}
+
+	if _, err = wait.ForOperation(ctx, poller, nil); err != nil {
+		return fmt.Errorf("failed to set soft delete setting on backup vault: %w", err)
+	}
+
+	log.Printf("Soft delete setting updated on backup vault '%s'", backupVaultName)
+
+	return nil
+}
+
+/*
+ * Deletes a backup vault. The vault must not hold any backup instances.
+ */
+func DeleteBackupVault(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string) error {
+	client, err := armdataprotection.NewBackupVaultsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create data protection client: %w", err)
+	}
+
+	poller, err := client.BeginDelete(ctx, resourceGroupName, backupVaultName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete backup vault: %w", err)
+	}
+
+	if _, err = wait.ForOperation(ctx, poller, nil); err != nil {
+		return fmt.Errorf("failed to delete backup vault: %w", err)
+	}
+
+	log.Printf("Backup vault '%s' deleted successfully", backupVaultName)
+
+	return nil
+}
+
+/*
+ * Deletes the backup instance for the provided backup vault and instance name.
+ */
+func DeleteBackupInstance(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string, backupInstanceName string) error {
+	client, err := armdataprotection.NewBackupInstancesClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create data protection client: %w", err)
+	}
+
+	poller, err := client.BeginDelete(ctx, resourceGroupName, backupVaultName, backupInstanceName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete backup instance: %w", err)
+	}
+
+	if _, err = wait.ForOperation(ctx, poller, nil); err != nil {
+		return fmt.Errorf("failed to delete backup instance: %w", err)
+	}
+
+	log.Printf("Backup instance '%s' deleted successfully", backupInstanceName)
+
+	return nil
+}
+
+/*
+ * Triggers an ad-hoc backup for the provided backup instance name, and waits for the backup
+ * job to finish.
+ */
+func BeginAdHocBackup(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string, backupInstanceName string) (*wait.JobResult, error) {
+	instancesClient, err := armdataprotection.NewBackupInstancesClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup instances client: %w", err)
+	}
+
+	poller, err := instancesClient.BeginAdhocBackup(ctx, resourceGroupName, backupVaultName, backupInstanceName, armdataprotection.TriggerBackupRequest{
+		BackupRuleOptions: &armdataprotection.AdHocBackupRuleOptions{
+			RuleName:      to.Ptr("BackupIntervals"),
+			TriggerOption: &armdataprotection.AdhocBackupTriggerOption{},
+		},
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin ad-hoc backup: %w", err)
+	}
+
+	resp, err := wait.ForOperation(ctx, poller, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to poll ad-hoc backup status: %w", err)
+	}
+	if resp.JobID == nil {
+		return nil, fmt.Errorf("no job ID was returned for the ad-hoc backup of '%s'", backupInstanceName)
+	}
+
+	result, err := WaitForBackupJob(ctx, credential, clientOptions, subscriptionID, resourceGroupName, backupVaultName, *resp.JobID)
+	if err != nil {
+		return result, err
+	}
+
+	log.Printf("Ad-hoc backup '%s' completed successfully", backupInstanceName)
+
+	return result, nil
+}
+
+/*
+ * Waits for a backup vault job (such as a backup or restore) to finish. Jobs which completed
+ * with warnings are treated as successful, and their warnings logged. The details of a job
+ * which didn't succeed are in the returned *wait.JobError instead.
+ */
+func WaitForBackupJob(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string, jobID string) (*wait.JobResult, error) {
+	jobClient, err := armdataprotection.NewJobsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup jobs client: %w", err)
+	}
+
+	result, err := wait.ForJob(ctx, jobClient, resourceGroupName, backupVaultName, jobID, nil)
+	if result != nil {
+		if result.Status == wait.JobStatusCompletedWithWarnings {
+			for _, warning := range result.ErrorDetails {
+				log.Printf("Backup job '%s' reported a warning: %s: %s", result.JobID, valueOf(warning.Code), valueOf(warning.Message))
+			}
+		}
+
+		log.Printf("Backup job '%s' finished with status %s in %s", result.JobID, result.Status, result.Duration)
+	}
+
+	return result, err
+}
+
+/*
+ * Lists the jobs (such as backups and restores) that have run in a backup vault. The service
+ * keeps the jobs of roughly the last 30 days.
+ */
+func ListBackupJobs(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string) ([]*armdataprotection.AzureBackupJobResource, error) {
+	client, err := armdataprotection.NewJobsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup jobs client: %w", err)
+	}
+
+	var jobs []*armdataprotection.AzureBackupJobResource
+	pager := client.NewListPager(resourceGroupName, backupVaultName, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list backup jobs: %w", err)
+		}
+
+		jobs = append(jobs, page.Value...)
+	}
+
+	return jobs, nil
+}
+
+/*
+ * Gets the recovery points for the provided backup instance, ordered from newest to oldest.
+ */
+func GetRecoveryPoints(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string, backupInstanceName string) ([]*armdataprotection.AzureBackupRecoveryPointResource, error) {
+	client, err := armdataprotection.NewRecoveryPointsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recovery points client: %w", err)
+	}
+
+	pager := client.NewListPager(resourceGroupName, backupVaultName, backupInstanceName, nil)
+
+	var recoveryPoints []*armdataprotection.AzureBackupRecoveryPointResource
+
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get recovery points: %w", err)
+		}
+
+		recoveryPoints = append(recoveryPoints, page.Value...)
+	}
+
+	slices.SortStableFunc(recoveryPoints, func(a, b *armdataprotection.AzureBackupRecoveryPointResource) int {
+		return getRecoveryPointTime(b).Compare(getRecoveryPointTime(a))
+	})
+
+	return recoveryPoints, nil
+}
+
+func getRecoveryPointTime(recoveryPoint *armdataprotection.AzureBackupRecoveryPointResource) time.Time {
+	if discrete, ok := recoveryPoint.Properties.(*armdataprotection.AzureBackupDiscreteRecoveryPoint); ok && discrete.RecoveryPointTime != nil {
+		return *discrete.RecoveryPointTime
+	}
+
+	return time.Time{}
+}
+
+/*
+ * Begins a restore of a blob storage backup instance from the provided recovery point into
+ * a target storage account, and returns the ID of the restore job. When container names are
+ * provided an item level restore of just those containers is performed, otherwise every
+ * container in the recovery point is restored.
+ *
+ * The backup vault identity must hold the Storage Account Backup Contributor role on the
+ * target storage account.
+ */
+func BeginBlobStorageRestore(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string,
+	backupInstanceName string, recoveryPointID string, targetStorageAccount armstorage.Account, containerNames []string) (string, error) {
+	targetDatasource := &armdataprotection.Datasource{
+		ObjectType:       to.Ptr("Datasource"),
+		ResourceID:       targetStorageAccount.ID,
+		ResourceName:     targetStorageAccount.Name,
+		ResourceLocation: targetStorageAccount.Location,
+		ResourceType:     to.Ptr("Microsoft.Storage/storageAccounts"),
+		DatasourceType:   to.Ptr("Microsoft.Storage/storageAccounts/blobServices"),
+	}
+
+	var restoreTargetInfo armdataprotection.RestoreTargetInfoBaseClassification
+
+	if len(containerNames) > 0 {
+		var restoreCriteria []armdataprotection.ItemLevelRestoreCriteriaClassification
+		for _, containerName := range containerNames {
+			restoreCriteria = append(restoreCriteria, &armdataprotection.ItemPathBasedRestoreCriteria{
+				ObjectType:                 to.Ptr("ItemPathBasedRestoreCriteria"),
+				ItemPath:                   to.Ptr(containerName),
+				IsPathRelativeToBackupItem: to.Ptr(true),
+			})
+		}
+
+		restoreTargetInfo = &armdataprotection.ItemLevelRestoreTargetInfo{
+			ObjectType:      to.Ptr("ItemLevelRestoreTargetInfo"),
+			RecoveryOption:  to.Ptr(armdataprotection.RecoveryOptionFailIfExists),
+			RestoreLocation: targetStorageAccount.Location,
+			DatasourceInfo:  targetDatasource,
+			RestoreCriteria: restoreCriteria,
+		}
+	} else {
+		restoreTargetInfo = &armdataprotection.RestoreTargetInfo{
+			ObjectType:      to.Ptr("RestoreTargetInfo"),
+			RecoveryOption:  to.Ptr(armdataprotection.RecoveryOptionFailIfExists),
+			RestoreLocation: targetStorageAccount.Location,
+			DatasourceInfo:  targetDatasource,
+		}
+	}
+
+	restoreRequest := &armdataprotection.AzureBackupRecoveryPointBasedRestoreRequest{
+		ObjectType:          to.Ptr("AzureBackupRecoveryPointBasedRestoreRequest"),
+		RecoveryPointID:     &recoveryPointID,
+		SourceDataStoreType: to.Ptr(armdataprotection.SourceDataStoreTypeVaultStore),
+		RestoreTargetInfo:   restoreTargetInfo,
+	}
+
+	jobID, err := beginRestore(ctx, credential, clientOptions, subscriptionID, resourceGroupName, backupVaultName, backupInstanceName, restoreRequest)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Restore of backup instance '%s' to storage account '%s' started", backupInstanceName, *targetStorageAccount.Name)
+
+	return jobID, nil
+}
+
+/*
+ * Begins a restore of a managed disk backup instance from the provided recovery point to a
+ * new managed disk in the target resource group, and returns the ID of the restore job.
+ *
+ * The backup vault identity must hold the Disk Restore Operator role on the target resource group.
+ */
+func BeginManagedDiskRestore(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string,
+	backupInstanceName string, recoveryPointID string, targetResourceGroup armresources.ResourceGroup, targetDiskName string) (string, error) {
+	restoreRequest := &armdataprotection.AzureBackupRecoveryPointBasedRestoreRequest{
+		ObjectType:          to.Ptr("AzureBackupRecoveryPointBasedRestoreRequest"),
+		RecoveryPointID:     &recoveryPointID,
+		SourceDataStoreType: to.Ptr(armdataprotection.SourceDataStoreTypeOperationalStore),
+		RestoreTargetInfo: &armdataprotection.RestoreTargetInfo{
+			ObjectType:      to.Ptr("RestoreTargetInfo"),
+			RecoveryOption:  to.Ptr(armdataprotection.RecoveryOptionFailIfExists),
+			RestoreLocation: targetResourceGroup.Location,
+			DatasourceInfo: &armdataprotection.Datasource{
+				ObjectType:       to.Ptr("Datasource"),
+				ResourceID:       to.Ptr(fmt.Sprintf("%s/providers/Microsoft.Compute/disks/%s", *targetResourceGroup.ID, targetDiskName)),
+				ResourceName:     &targetDiskName,
+				ResourceLocation: targetResourceGroup.Location,
+				ResourceType:     to.Ptr("Microsoft.Compute/disks"),
+				DatasourceType:   to.Ptr("Microsoft.Compute/disks"),
+			},
+		},
+	}
+
+	jobID, err := beginRestore(ctx, credential, clientOptions, subscriptionID, resourceGroupName, backupVaultName, backupInstanceName, restoreRequest)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Restore of backup instance '%s' to managed disk '%s' started", backupInstanceName, targetDiskName)
+
+	return jobID, nil
+}
+
+/*
+ * Begins a restore of a postgresql flexible server backup instance from the provided recovery
+ * point as files (a pg_dump archive per database) into a storage account container, and returns
+ * the ID of the restore job. The name of every restored file starts with the provided prefix.
+ *
+ * The backup vault identity must hold the Storage Blob Data Contributor role on the target
+ * storage account.
+ */
+func BeginPostgresqlFlexibleServerRestoreAsFiles(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string,
+	backupInstanceName string, recoveryPointID string, targetStorageAccount armstorage.Account, targetContainer armstorage.BlobContainer, filePrefix string) (string, error) {
+	restoreRequest := &armdataprotection.AzureBackupRecoveryPointBasedRestoreRequest{
+		ObjectType:          to.Ptr("AzureBackupRecoveryPointBasedRestoreRequest"),
+		RecoveryPointID:     &recoveryPointID,
+		SourceDataStoreType: to.Ptr(armdataprotection.SourceDataStoreTypeVaultStore),
+		RestoreTargetInfo: &armdataprotection.RestoreFilesTargetInfo{
+			ObjectType:      to.Ptr("RestoreFilesTargetInfo"),
+			RecoveryOption:  to.Ptr(armdataprotection.RecoveryOptionFailIfExists),
+			RestoreLocation: targetStorageAccount.Location,
+			TargetDetails: &armdataprotection.TargetDetails{
+				FilePrefix:                &filePrefix,
+				RestoreTargetLocationType: to.Ptr(armdataprotection.RestoreTargetLocationTypeAzureBlobs),
+				URL:                       to.Ptr(fmt.Sprintf("https://%s.blob.core.windows.net/%s", *targetStorageAccount.Name, *targetContainer.Name)),
+				TargetResourceArmID:       targetContainer.ID,
+			},
+		},
+	}
+
+	jobID, err := beginRestore(ctx, credential, clientOptions, subscriptionID, resourceGroupName, backupVaultName, backupInstanceName, restoreRequest)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Restore of backup instance '%s' as files to container '%s' in storage account '%s' started", backupInstanceName, *targetContainer.Name, *targetStorageAccount.Name)
+
+	return jobID, nil
+}
+
+/*
+ * Validates and then triggers a restore of a backup instance, returning the ID of the restore job.
+ */
+func beginRestore(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string,
+	backupInstanceName string, restoreRequest *armdataprotection.AzureBackupRecoveryPointBasedRestoreRequest) (string, error) {
+	instancesClient, err := armdataprotection.NewBackupInstancesClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup instances client: %w", err)
+	}
+
+	validatePoller, err := instancesClient.BeginValidateForRestore(ctx, resourceGroupName, backupVaultName, backupInstanceName, armdataprotection.ValidateRestoreRequestObject{
+		RestoreRequestObject: restoreRequest,
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin restore validation: %w", err)
+	}
+
+	if _, err = wait.ForOperation(ctx, validatePoller, nil); err != nil {
+		return "", fmt.Errorf("failed to validate restore: %w", err)
+	}
+
+	restorePoller, err := instancesClient.BeginTriggerRestore(ctx, resourceGroupName, backupVaultName, backupInstanceName, restoreRequest, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin restore: %w", err)
+	}
+
+	resp, err := wait.ForOperation(ctx, restorePoller, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to poll restore status: %w", err)
+	}
+	if resp.JobID == nil {
+		return "", fmt.Errorf("no job ID was returned for the restore of '%s'", backupInstanceName)
+	}
+
+	return *resp.JobID, nil
+}
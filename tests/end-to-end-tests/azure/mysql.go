@@ -0,0 +1,62 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mysql/armmysqlflexibleservers"
+)
+
+/*
+ * Creates a mysql flexible server.
+ */
+func CreateMysqlFlexibleServer(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, serverName string, serverLocation string, storageSizeGB int32) (armmysqlflexibleservers.Server, error) {
+	client, err := armmysqlflexibleservers.NewServersClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armmysqlflexibleservers.Server{}, fmt.Errorf("failed to create servers client: %w", err)
+	}
+
+	log.Printf("Creating mysql flexible server %s in location %s", serverName, serverLocation)
+
+	pollerResp, err := client.BeginCreate(
+		ctx,
+		resourceGroupName,
+		serverName,
+		armmysqlflexibleservers.Server{
+			Location: &serverLocation,
+			SKU: &armmysqlflexibleservers.SKU{
+				Name: to.Ptr("Standard_B1ms"),
+				Tier: to.Ptr(armmysqlflexibleservers.SKUTierBurstable),
+			},
+			Properties: &armmysqlflexibleservers.ServerProperties{
+				AdministratorLogin:         to.Ptr("supersecurelogin"),
+				AdministratorLoginPassword: to.Ptr("supersecurepassword"),
+				Version:                    to.Ptr(armmysqlflexibleservers.ServerVersionEight021),
+				Storage: &armmysqlflexibleservers.Storage{
+					StorageSizeGB: &storageSizeGB,
+				},
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		return armmysqlflexibleservers.Server{}, fmt.Errorf("failed to begin creating mysql flexible server: %w", err)
+	}
+
+	// Wait for the creation to complete
+	resp, err := wait.ForOperation(ctx, pollerResp, nil)
+	if err != nil {
+		return armmysqlflexibleservers.Server{}, fmt.Errorf("failed to create mysql flexible server: %w", err)
+	}
+
+	log.Printf("Mysql flexible server %s created successfully", serverName)
+
+	return resp.Server, nil
+}
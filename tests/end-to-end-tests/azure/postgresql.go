@@ -0,0 +1,82 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/postgresql/armpostgresqlflexibleservers"
+)
+
+/*
+ * Creates a postgresql flexible server.
+ */
+func CreatePostgresqlFlexibleServer(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, serverName string, serverLocation string, storageSizeGB int32) (armpostgresqlflexibleservers.Server, error) {
+	client, err := armpostgresqlflexibleservers.NewServersClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armpostgresqlflexibleservers.Server{}, fmt.Errorf("failed to create servers client: %w", err)
+	}
+
+	log.Printf("Creating postgresql flexible server %s in location %s", serverName, serverLocation)
+
+	pollerResp, err := client.BeginCreate(
+		ctx,
+		resourceGroupName,
+		serverName,
+		armpostgresqlflexibleservers.Server{
+			Location: &serverLocation,
+			SKU: &armpostgresqlflexibleservers.SKU{
+				Name: to.Ptr("Standard_B1ms"),
+				Tier: to.Ptr(armpostgresqlflexibleservers.SKUTierBurstable),
+			},
+			Properties: &armpostgresqlflexibleservers.ServerProperties{
+				AdministratorLogin:         to.Ptr("supersecurelogin"),
+				AdministratorLoginPassword: to.Ptr("supersecurepassword"),
+				Version:                    to.Ptr(armpostgresqlflexibleservers.ServerVersionFourteen),
+				Storage: &armpostgresqlflexibleservers.Storage{
+					StorageSizeGB: &storageSizeGB,
+				},
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		return armpostgresqlflexibleservers.Server{}, fmt.Errorf("failed to begin creating postgresql flexible server: %w", err)
+	}
+
+	// Wait for the creation to complete
+	resp, err := wait.ForOperation(ctx, pollerResp, nil)
+	if err != nil {
+		return armpostgresqlflexibleservers.Server{}, fmt.Errorf("failed to create postgresql flexible server: %w", err)
+	}
+
+	log.Printf("Postgresql flexible server %s created successfully", serverName)
+
+	return resp.Server, nil
+}
+
+/*
+ * Checks that a file is a valid pg_dump archive by listing its table of contents with
+ * pg_restore, and returns the listing. An error wrapping exec.ErrNotFound is returned when
+ * pg_restore isn't installed locally.
+ */
+func VerifyPostgresqlDumpArchive(ctx context.Context, archivePath string) (string, error) {
+	pgRestorePath, err := exec.LookPath("pg_restore")
+	if err != nil {
+		return "", fmt.Errorf("failed to find pg_restore: %w", err)
+	}
+
+	output, err := exec.CommandContext(ctx, pgRestorePath, "--list", archivePath).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to list archive '%s' with pg_restore: %w\n%s", archivePath, err, output)
+	}
+
+	return string(output), nil
+}
@@ -0,0 +1,276 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+/*
+ * The recovery services vault and backup APIs are called directly, as the armrecoveryservices
+ * and armrecoveryservicesbackup modules aren't dependencies of the tests. The models follow
+ * those of the SDK, but only have the properties that the tests check.
+ */
+const (
+	recoveryServicesAPIVersion = "2024-04-01"
+
+	recoveryServicesModuleName    = "e2e_tests/azure"
+	recoveryServicesModuleVersion = "v1.0.0"
+)
+
+/*
+ * RecoveryServicesVault is a recovery services vault, as armrecoveryservices.Vault.
+ */
+type RecoveryServicesVault struct {
+	ID         string                          `json:"id"`
+	Name       string                          `json:"name"`
+	Location   string                          `json:"location"`
+	Tags       map[string]string               `json:"tags"`
+	SKU        RecoveryServicesVaultSKU        `json:"sku"`
+	Identity   *RecoveryServicesVaultIdentity  `json:"identity"`
+	Properties RecoveryServicesVaultProperties `json:"properties"`
+}
+
+type RecoveryServicesVaultSKU struct {
+	Name string `json:"name"`
+}
+
+type RecoveryServicesVaultIdentity struct {
+	Type        string `json:"type"`
+	PrincipalID string `json:"principalId"`
+}
+
+type RecoveryServicesVaultProperties struct {
+	RedundancySettings struct {
+		StandardTierStorageRedundancy string `json:"standardTierStorageRedundancy"`
+	} `json:"redundancySettings"`
+	SecuritySettings struct {
+		ImmutabilitySettings struct {
+			State string `json:"state"`
+		} `json:"immutabilitySettings"`
+		SoftDeleteSettings struct {
+			SoftDeleteState string `json:"softDeleteState"`
+		} `json:"softDeleteSettings"`
+	} `json:"securitySettings"`
+}
+
+/*
+ * RecoveryServicesBackupPolicy is a backup policy in a recovery services vault, as
+ * armrecoveryservicesbackup.ProtectionPolicyResource with a simple daily schedule and
+ * long term retention.
+ */
+type RecoveryServicesBackupPolicy struct {
+	ID         string                                 `json:"id"`
+	Name       string                                 `json:"name"`
+	Properties RecoveryServicesBackupPolicyProperties `json:"properties"`
+}
+
+type RecoveryServicesBackupPolicyProperties struct {
+	BackupManagementType string `json:"backupManagementType"`
+	TimeZone             string `json:"timeZone"`
+	SchedulePolicy       struct {
+		SchedulePolicyType   string   `json:"schedulePolicyType"`
+		ScheduleRunFrequency string   `json:"scheduleRunFrequency"`
+		ScheduleRunTimes     []string `json:"scheduleRunTimes"`
+	} `json:"schedulePolicy"`
+	RetentionPolicy struct {
+		RetentionPolicyType string `json:"retentionPolicyType"`
+		DailySchedule       *struct {
+			RetentionDuration RecoveryServicesRetentionDuration `json:"retentionDuration"`
+		} `json:"dailySchedule"`
+		WeeklySchedule *struct {
+			DaysOfTheWeek     []string                          `json:"daysOfTheWeek"`
+			RetentionDuration RecoveryServicesRetentionDuration `json:"retentionDuration"`
+		} `json:"weeklySchedule"`
+		MonthlySchedule *struct {
+			RetentionScheduleWeekly *RecoveryServicesWeeklyRetentionFormat `json:"retentionScheduleWeekly"`
+			RetentionDuration       RecoveryServicesRetentionDuration      `json:"retentionDuration"`
+		} `json:"monthlySchedule"`
+		YearlySchedule *struct {
+			MonthsOfYear            []string                               `json:"monthsOfYear"`
+			RetentionScheduleWeekly *RecoveryServicesWeeklyRetentionFormat `json:"retentionScheduleWeekly"`
+			RetentionDuration       RecoveryServicesRetentionDuration      `json:"retentionDuration"`
+		} `json:"yearlySchedule"`
+	} `json:"retentionPolicy"`
+}
+
+/*
+ * RecoveryServicesRetentionDuration is how long a recovery services policy keeps recovery
+ * points, as a count of the duration type (Days, Weeks, Months or Years).
+ */
+type RecoveryServicesRetentionDuration struct {
+	Count        int    `json:"count"`
+	DurationType string `json:"durationType"`
+}
+
+/*
+ * RecoveryServicesWeeklyRetentionFormat is the days of the week, and weeks of the month, that
+ * monthly and yearly recovery points are taken from.
+ */
+type RecoveryServicesWeeklyRetentionFormat struct {
+	DaysOfTheWeek   []string `json:"daysOfTheWeek"`
+	WeeksOfTheMonth []string `json:"weeksOfTheMonth"`
+}
+
+/*
+ * RecoveryServicesProtectedItem is an item protected by a recovery services vault, as
+ * armrecoveryservicesbackup.ProtectedItemResource.
+ */
+type RecoveryServicesProtectedItem struct {
+	ID         string                                  `json:"id"`
+	Name       string                                  `json:"name"`
+	Properties RecoveryServicesProtectedItemProperties `json:"properties"`
+}
+
+type RecoveryServicesProtectedItemProperties struct {
+	ProtectedItemType    string `json:"protectedItemType"`
+	BackupManagementType string `json:"backupManagementType"`
+	WorkloadType         string `json:"workloadType"`
+	SourceResourceID     string `json:"sourceResourceId"`
+	FriendlyName         string `json:"friendlyName"`
+	PolicyID             string `json:"policyId"`
+	ProtectionState      string `json:"protectionState"`
+}
+
+/*
+ * Gets a recovery services vault for the provided name.
+ */
+func GetRecoveryServicesVault(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, vaultName string) (RecoveryServicesVault, error) {
+	var vault RecoveryServicesVault
+	if err := getRecoveryServicesResource(ctx, credential, clientOptions, recoveryServicesVaultPath(subscriptionID, resourceGroupName, vaultName), &vault); err != nil {
+		return RecoveryServicesVault{}, fmt.Errorf("failed to get recovery services vault: %w", err)
+	}
+
+	return vault, nil
+}
+
+/*
+ * Gets the backup policies for the provided recovery services vault.
+ */
+func GetRecoveryServicesBackupPolicies(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, vaultName string) ([]*RecoveryServicesBackupPolicy, error) {
+	policies, err := listRecoveryServicesResources[RecoveryServicesBackupPolicy](ctx, credential, clientOptions, recoveryServicesVaultPath(subscriptionID, resourceGroupName, vaultName)+"/backupPolicies")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recovery services backup policies: %w", err)
+	}
+
+	return policies, nil
+}
+
+/*
+ * Gets the protected items for the provided recovery services vault.
+ */
+func GetRecoveryServicesProtectedItems(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, vaultName string) ([]*RecoveryServicesProtectedItem, error) {
+	items, err := listRecoveryServicesResources[RecoveryServicesProtectedItem](ctx, credential, clientOptions, recoveryServicesVaultPath(subscriptionID, resourceGroupName, vaultName)+"/backupProtectedItems")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recovery services protected items: %w", err)
+	}
+
+	return items, nil
+}
+
+/*
+ * Gets a recovery services backup policy from the provided list for the provided name
+ */
+func GetRecoveryServicesBackupPolicyForName(policies []*RecoveryServicesBackupPolicy, name string) *RecoveryServicesBackupPolicy {
+	for _, policy := range policies {
+		if policy.Name == name {
+			return policy
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Gets a protected item from the provided list for the resource it protects. File shares are
+ * protected as items of their storage account, so are also matched on the share name.
+ */
+func GetRecoveryServicesProtectedItemForSource(items []*RecoveryServicesProtectedItem, sourceResourceID string, friendlyName string) *RecoveryServicesProtectedItem {
+	for _, item := range items {
+		if strings.EqualFold(item.Properties.SourceResourceID, sourceResourceID) && (friendlyName == "" || item.Properties.FriendlyName == friendlyName) {
+			return item
+		}
+	}
+
+	return nil
+}
+
+func recoveryServicesVaultPath(subscriptionID string, resourceGroupName string, vaultName string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.RecoveryServices/vaults/%s",
+		url.PathEscape(subscriptionID), url.PathEscape(resourceGroupName), url.PathEscape(vaultName))
+}
+
+/*
+ * Gets a resource from the recovery services APIs, decoding it into the provided model.
+ */
+func getRecoveryServicesResource(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, path string, result any) error {
+	client, err := arm.NewClient(recoveryServicesModuleName, recoveryServicesModuleVersion, credential, clientOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create recovery services client: %w", err)
+	}
+
+	requestURL := fmt.Sprintf("%s%s?api-version=%s", client.Endpoint(), path, recoveryServicesAPIVersion)
+
+	return getRecoveryServicesPage(ctx, client, requestURL, result)
+}
+
+/*
+ * Lists the resources in a recovery services collection, following the next links of each page.
+ */
+func listRecoveryServicesResources[T any](ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, path string) ([]*T, error) {
+	client, err := arm.NewClient(recoveryServicesModuleName, recoveryServicesModuleVersion, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recovery services client: %w", err)
+	}
+
+	var resources []*T
+
+	requestURL := fmt.Sprintf("%s%s?api-version=%s", client.Endpoint(), path, recoveryServicesAPIVersion)
+	for requestURL != "" {
+		var page struct {
+			Value    []*T   `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		if err := getRecoveryServicesPage(ctx, client, requestURL, &page); err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, page.Value...)
+		requestURL = page.NextLink
+	}
+
+	return resources, nil
+}
+
+func getRecoveryServicesPage(ctx context.Context, client *arm.Client, requestURL string, result any) error {
+	request, err := runtime.NewRequest(ctx, http.MethodGet, requestURL)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	response, err := client.Pipeline().Do(request)
+	if err != nil {
+		return err
+	}
+	if !runtime.HasStatusCode(response, http.StatusOK) {
+		return runtime.NewResponseError(response)
+	}
+
+	payload, err := runtime.Payload(response)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if err := json.Unmarshal(payload, result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
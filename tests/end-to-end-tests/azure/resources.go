@@ -0,0 +1,226 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/monitor/armmonitor"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+// The tag that CreateResourceGroup records the time it created a resource group in, as an
+// RFC 3339 timestamp, so that its age is known while it holds no resources
+const CreatedTimeTag = "created_time"
+
+/*
+ * Gets a resource group for the provided name.
+ */
+func GetResourceGroup(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, name string) (armresources.ResourceGroup, error) {
+	client, err := armresources.NewResourceGroupsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armresources.ResourceGroup{}, fmt.Errorf("failed to create resource group client: %w", err)
+	}
+
+	resp, err := client.Get(ctx, name, nil)
+	if err != nil {
+		return armresources.ResourceGroup{}, fmt.Errorf("failed to get resource group: %w", err)
+	}
+
+	return resp.ResourceGroup, nil
+}
+
+/*
+ * Creates a resource group, tagging it with the time it was created.
+ */
+func CreateResourceGroup(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, resourceGroupLocation string) (armresources.ResourceGroup, error) {
+	client, err := armresources.NewResourceGroupsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armresources.ResourceGroup{}, fmt.Errorf("failed to create resource group client: %w", err)
+	}
+
+	log.Printf("Creating resource group %s in location %s", resourceGroupName, resourceGroupLocation)
+
+	resp, err := client.CreateOrUpdate(
+		ctx,
+		resourceGroupName,
+		armresources.ResourceGroup{
+			Location: &resourceGroupLocation,
+			Tags: map[string]*string{
+				CreatedTimeTag: to.Ptr(time.Now().UTC().Format(time.RFC3339)),
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		return armresources.ResourceGroup{}, fmt.Errorf("failed to create resource group: %w", err)
+	}
+
+	log.Printf("Resource group %s created successfully", resourceGroupName)
+
+	return resp.ResourceGroup, nil
+}
+
+/*
+ * Deletes a resource group.
+ */
+func DeleteResourceGroup(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string) error {
+	client, err := armresources.NewResourceGroupsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create resource group client: %w", err)
+	}
+
+	log.Printf("Deleting resource group %s", resourceGroupName)
+
+	pollerResp, err := client.BeginDelete(ctx, resourceGroupName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete resource group: %w", err)
+	}
+
+	// Wait for the deletion to complete
+	if _, err = wait.ForOperation(ctx, pollerResp, nil); err != nil {
+		return fmt.Errorf("failed to delete resource group: %w", err)
+	}
+
+	log.Printf("Resource group %s deleted successfully", resourceGroupName)
+
+	return nil
+}
+
+/*
+ * Lists the resource groups in the subscription.
+ */
+func ListResourceGroups(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string) ([]*armresources.ResourceGroup, error) {
+	client, err := armresources.NewResourceGroupsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource group client: %w", err)
+	}
+
+	pager := client.NewListPager(nil)
+
+	var resourceGroups []*armresources.ResourceGroup
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list resource groups: %w", err)
+		}
+
+		resourceGroups = append(resourceGroups, page.Value...)
+	}
+
+	return resourceGroups, nil
+}
+
+/*
+ * Lists the resources in a resource group, including the time each resource was created.
+ */
+func ListResourcesInResourceGroup(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string) ([]*armresources.GenericResourceExpanded, error) {
+	client, err := armresources.NewClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resources client: %w", err)
+	}
+
+	pager := client.NewListByResourceGroupPager(resourceGroupName, &armresources.ClientListByResourceGroupOptions{
+		Expand: to.Ptr("createdTime"),
+	})
+
+	var resources []*armresources.GenericResourceExpanded
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list resources in resource group: %w", err)
+		}
+
+		resources = append(resources, page.Value...)
+	}
+
+	return resources, nil
+}
+
+/*
+ * Creates a Log Analytics workspace.
+ */
+func CreateLogAnalyticsWorkspace(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, workspaceName string, workspaceLocation string) (armoperationalinsights.Workspace, error) {
+	client, err := armoperationalinsights.NewWorkspacesClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armoperationalinsights.Workspace{}, fmt.Errorf("failed to create Log Analytics workspace client: %w", err)
+	}
+
+	log.Printf("Creating log analytics workspace %s in location %s", workspaceName, workspaceLocation)
+
+	pollerResp, err := client.BeginCreateOrUpdate(
+		ctx,
+		resourceGroupName,
+		workspaceName,
+		armoperationalinsights.Workspace{
+			Location: &workspaceLocation,
+		},
+		nil,
+	)
+	if err != nil {
+		return armoperationalinsights.Workspace{}, fmt.Errorf("failed to begin creating log analytics workspace: %w", err)
+	}
+
+	// Wait for the creation to complete
+	resp, err := wait.ForOperation(ctx, pollerResp, nil)
+	if err != nil {
+		return armoperationalinsights.Workspace{}, fmt.Errorf("failed to create log analytics workspace: %w", err)
+	}
+
+	log.Printf("Log analytics workspace %s created successfully", workspaceName)
+
+	return resp.Workspace, nil
+}
+
+/*
+ * Lists the diagnostic settings for the provided resource.
+ */
+func ListDiagnosticSettings(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, resourceID string) ([]*armmonitor.DiagnosticSettingsResource, error) {
+	client, err := armmonitor.NewDiagnosticSettingsClient(credential, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create diagnostic settings client: %w", err)
+	}
+
+	var diagnosticSettings []*armmonitor.DiagnosticSettingsResource
+
+	pager := client.NewListPager(resourceID, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list diagnostic settings: %w", err)
+		}
+
+		diagnosticSettings = append(diagnosticSettings, page.Value...)
+	}
+
+	return diagnosticSettings, nil
+}
+
+/*
+ * Gets the diagnostic setting for the provided resource. We currently only handle when
+ * there's exactly one diagnostic setting per resource, so anything else is an error.
+ */
+func GetDiagnosticSettings(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, resourceID string) (*armmonitor.DiagnosticSettingsResource, error) {
+	diagnosticSettings, err := ListDiagnosticSettings(ctx, credential, clientOptions, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(diagnosticSettings) {
+	case 0:
+		return nil, fmt.Errorf("no diagnostic settings found for resource: %s", resourceID)
+	case 1:
+		return diagnosticSettings[0], nil
+	default:
+		return nil, fmt.Errorf("multiple diagnostic settings found for resource: %s", resourceID)
+	}
+}
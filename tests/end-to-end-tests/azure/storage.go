@@ -0,0 +1,289 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+/*
+ * Options for creating a storage account. A nil value creates a standard StorageV2 account
+ * with a flat namespace.
+ */
+type StorageAccountOptions struct {
+	// Enables the hierarchical namespace, which makes the account an ADLS Gen2 account
+	HierarchicalNamespace bool
+}
+
+/*
+ * Creates a storage account.
+ */
+func CreateStorageAccount(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, storageAccountName string, storageAccountLocation string, options *StorageAccountOptions) (armstorage.Account, error) {
+	if options == nil {
+		options = &StorageAccountOptions{}
+	}
+
+	client, err := armstorage.NewAccountsClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armstorage.Account{}, fmt.Errorf("failed to create storage account client: %w", err)
+	}
+
+	log.Printf("Creating storage account %s in location %s", storageAccountName, storageAccountLocation)
+
+	pollerResp, err := client.BeginCreate(
+		ctx,
+		resourceGroupName,
+		storageAccountName,
+		armstorage.AccountCreateParameters{
+			SKU: &armstorage.SKU{
+				Name: to.Ptr(armstorage.SKUNameStandardLRS),
+			},
+			Kind:     to.Ptr(armstorage.KindStorageV2),
+			Location: &storageAccountLocation,
+			Properties: &armstorage.AccountPropertiesCreateParameters{
+				IsHnsEnabled: to.Ptr(options.HierarchicalNamespace),
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		return armstorage.Account{}, fmt.Errorf("failed to begin creating storage account: %w", err)
+	}
+
+	// Wait for the creation to complete
+	resp, err := wait.ForOperation(ctx, pollerResp, nil)
+	if err != nil {
+		return armstorage.Account{}, fmt.Errorf("failed to create storage account: %w", err)
+	}
+
+	log.Printf("Storage account %s created successfully", storageAccountName)
+
+	return resp.Account, nil
+}
+
+/*
+ * Creates a storage account container.
+ */
+func CreateStorageAccountContainer(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, storageAccountName string, containerName string) (armstorage.BlobContainer, error) {
+	containerClient, err := armstorage.NewBlobContainersClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armstorage.BlobContainer{}, fmt.Errorf("failed to create container client: %w", err)
+	}
+
+	resp, err := containerClient.Create(
+		ctx,
+		resourceGroupName,
+		storageAccountName,
+		containerName,
+		armstorage.BlobContainer{},
+		nil,
+	)
+	if err != nil {
+		return armstorage.BlobContainer{}, fmt.Errorf("failed to create container: %w", err)
+	}
+
+	log.Printf("Container '%s' created successfully in storage account %s", containerName, storageAccountName)
+
+	return resp.BlobContainer, nil
+}
+
+/*
+ * Creates a file share in a storage account.
+ */
+func CreateStorageAccountFileShare(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, storageAccountName string, shareName string, shareQuotaGB int32) (armstorage.FileShare, error) {
+	fileShareClient, err := armstorage.NewFileSharesClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armstorage.FileShare{}, fmt.Errorf("failed to create file share client: %w", err)
+	}
+
+	resp, err := fileShareClient.Create(
+		ctx,
+		resourceGroupName,
+		storageAccountName,
+		shareName,
+		armstorage.FileShare{
+			FileShareProperties: &armstorage.FileShareProperties{
+				ShareQuota: &shareQuotaGB,
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		return armstorage.FileShare{}, fmt.Errorf("failed to create file share: %w", err)
+	}
+
+	log.Printf("File share '%s' created successfully in storage account %s", shareName, storageAccountName)
+
+	return resp.FileShare, nil
+}
+
+/*
+ * Creates a directory in a container of a storage account with a hierarchical namespace. The
+ * directory is created through the blob endpoint as an empty blob marked as a folder, which
+ * ADLS Gen2 treats the same as a directory created through the dfs endpoint.
+ */
+func CreateStorageAccountDirectory(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, storageAccountName string, containerName string, directoryPath string) error {
+	serviceClient, err := newBlobServiceClient(credential, clientOptions, storageAccountName)
+	if err != nil {
+		return err
+	}
+
+	_, err = serviceClient.UploadBuffer(ctx, containerName, directoryPath, []byte{}, &azblob.UploadBufferOptions{
+		Metadata: map[string]*string{"hdi_isfolder": to.Ptr("true")},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	log.Printf("Directory '%s' created successfully in container %s", directoryPath, containerName)
+
+	return nil
+}
+
+/*
+ * Creates a test file that can be used for test purposes.
+ */
+func CreateTestFile() (*os.File, error) {
+	testFile, err := os.CreateTemp("", "test-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create test file: %w", err)
+	}
+	defer testFile.Close()
+
+	if _, err := testFile.Write([]byte("This is a test file for upload.")); err != nil {
+		return nil, fmt.Errorf("failed to write test file: %w", err)
+	}
+
+	return testFile, nil
+}
+
+/*
+ * Uploads a file to blob storage account
+ */
+func UploadFileToStorageAccount(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, storageAccountName string, containerName string, filePath string) error {
+	serviceClient, err := newBlobServiceClient(credential, clientOptions, storageAccountName)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	fileName := filepath.Base(filePath)
+
+	if _, err = serviceClient.UploadFile(ctx, containerName, fileName, file, nil); err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	log.Printf("File '%s' uploaded successfully to container '%s' in storage account '%s'", filePath, containerName, storageAccountName)
+
+	return nil
+}
+
+/*
+ * Downloads a blob from a blob storage account
+ */
+func DownloadFileFromStorageAccount(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, storageAccountName string, containerName string, blobName string) ([]byte, error) {
+	serviceClient, err := newBlobServiceClient(credential, clientOptions, storageAccountName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := serviceClient.DownloadStream(ctx, containerName, blobName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download blob: %w", err)
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+
+	log.Printf("Blob '%s' downloaded successfully from container '%s' in storage account '%s'", blobName, containerName, storageAccountName)
+
+	return content, nil
+}
+
+/*
+ * Lists the blobs in a blob storage account container whose names start with the provided prefix.
+ */
+func ListBlobsInStorageAccountContainer(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, storageAccountName string, containerName string, prefix string) ([]*container.BlobItem, error) {
+	serviceClient, err := newBlobServiceClient(credential, clientOptions, storageAccountName)
+	if err != nil {
+		return nil, err
+	}
+
+	pager := serviceClient.NewListBlobsFlatPager(containerName, &azblob.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+
+	var blobs []*container.BlobItem
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs: %w", err)
+		}
+
+		blobs = append(blobs, page.Segment.BlobItems...)
+	}
+
+	return blobs, nil
+}
+
+/*
+ * Deletes a blob from a blob storage account container.
+ */
+func DeleteBlobFromStorageAccount(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, storageAccountName string, containerName string, blobName string) error {
+	serviceClient, err := newBlobServiceClient(credential, clientOptions, storageAccountName)
+	if err != nil {
+		return err
+	}
+
+	if _, err = serviceClient.DeleteBlob(ctx, containerName, blobName, nil); err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+
+	log.Printf("Blob '%s' deleted successfully from container '%s' in storage account '%s'", blobName, containerName, storageAccountName)
+
+	return nil
+}
+
+func newBlobServiceClient(credential azcore.TokenCredential, clientOptions *arm.ClientOptions, storageAccountName string) (*azblob.Client, error) {
+	serviceClient, err := azblob.NewClient(fmt.Sprintf("https://%s.blob.core.windows.net/", storageAccountName), credential, blobClientOptions(clientOptions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service client: %w", err)
+	}
+
+	return serviceClient, nil
+}
+
+/*
+ * Gets the options of the blob clients, which share the transport of the Azure Resource
+ * Manager clients.
+ */
+func blobClientOptions(clientOptions *arm.ClientOptions) *azblob.ClientOptions {
+	if clientOptions == nil {
+		return nil
+	}
+
+	return &azblob.ClientOptions{ClientOptions: clientOptions.ClientOptions}
+}
@@ -0,0 +1,163 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/google/uuid"
+)
+
+/*
+ * The API version used to create the network that virtual machines are attached to, which has
+ * no client of its own here.
+ */
+const networkAPIVersion = "2023-09-01"
+
+/*
+ * Creates a small linux virtual machine, along with a virtual network and network interface
+ * for it to attach to. The admin password is random, as the tests never sign in.
+ */
+func CreateVirtualMachine(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, vmName string, vmLocation string) (armcompute.VirtualMachine, error) {
+	networkInterfaceID, err := createNetworkInterface(ctx, credential, clientOptions, subscriptionID, resourceGroupName, vmName, vmLocation)
+	if err != nil {
+		return armcompute.VirtualMachine{}, err
+	}
+
+	client, err := armcompute.NewVirtualMachinesClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return armcompute.VirtualMachine{}, fmt.Errorf("failed to create virtual machines client: %w", err)
+	}
+
+	log.Printf("Creating virtual machine %s in location %s", vmName, vmLocation)
+
+	pollerResp, err := client.BeginCreateOrUpdate(
+		ctx,
+		resourceGroupName,
+		vmName,
+		armcompute.VirtualMachine{
+			Location: &vmLocation,
+			Properties: &armcompute.VirtualMachineProperties{
+				HardwareProfile: &armcompute.HardwareProfile{
+					VMSize: to.Ptr(armcompute.VirtualMachineSizeTypesStandardB1S),
+				},
+				StorageProfile: &armcompute.StorageProfile{
+					ImageReference: &armcompute.ImageReference{
+						Publisher: to.Ptr("Canonical"),
+						Offer:     to.Ptr("0001-com-ubuntu-server-jammy"),
+						SKU:       to.Ptr("22_04-lts-gen2"),
+						Version:   to.Ptr("latest"),
+					},
+					OSDisk: &armcompute.OSDisk{
+						CreateOption: to.Ptr(armcompute.DiskCreateOptionTypesFromImage),
+						DeleteOption: to.Ptr(armcompute.DiskDeleteOptionTypesDelete),
+						ManagedDisk: &armcompute.ManagedDiskParameters{
+							StorageAccountType: to.Ptr(armcompute.StorageAccountTypesStandardLRS),
+						},
+					},
+				},
+				OSProfile: &armcompute.OSProfile{
+					ComputerName:  &vmName,
+					AdminUsername: to.Ptr("azureuser"),
+					AdminPassword: to.Ptr("Aa1!" + uuid.NewString()),
+				},
+				NetworkProfile: &armcompute.NetworkProfile{
+					NetworkInterfaces: []*armcompute.NetworkInterfaceReference{
+						{
+							ID: &networkInterfaceID,
+							Properties: &armcompute.NetworkInterfaceReferenceProperties{
+								Primary:      to.Ptr(true),
+								DeleteOption: to.Ptr(armcompute.DeleteOptionsDelete),
+							},
+						},
+					},
+				},
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		return armcompute.VirtualMachine{}, fmt.Errorf("failed to begin creating virtual machine: %w", err)
+	}
+
+	// Wait for the creation to complete
+	resp, err := wait.ForOperation(ctx, pollerResp, nil)
+	if err != nil {
+		return armcompute.VirtualMachine{}, fmt.Errorf("failed to create virtual machine: %w", err)
+	}
+
+	log.Printf("Virtual machine %s created successfully", vmName)
+
+	return resp.VirtualMachine, nil
+}
+
+/*
+ * Creates a virtual network with a single subnet, and a network interface in it for a virtual
+ * machine, returning the ID of the network interface.
+ */
+func createNetworkInterface(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string,
+	resourceGroupName string, vmName string, location string) (string, error) {
+	client, err := armresources.NewClient(subscriptionID, credential, clientOptions)
+	if err != nil {
+		return "", fmt.Errorf("failed to create resources client: %w", err)
+	}
+
+	networkID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/vnet-%s", subscriptionID, resourceGroupName, vmName)
+	networkInterfaceID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/networkInterfaces/nic-%s", subscriptionID, resourceGroupName, vmName)
+
+	resources := []struct {
+		id       string
+		resource armresources.GenericResource
+	}{
+		{
+			id: networkID,
+			resource: armresources.GenericResource{
+				Location: &location,
+				Properties: map[string]any{
+					"addressSpace": map[string]any{"addressPrefixes": []string{"10.0.0.0/16"}},
+					"subnets": []any{
+						map[string]any{"name": "default", "properties": map[string]any{"addressPrefix": "10.0.0.0/24"}},
+					},
+				},
+			},
+		},
+		{
+			id: networkInterfaceID,
+			resource: armresources.GenericResource{
+				Location: &location,
+				Properties: map[string]any{
+					"ipConfigurations": []any{
+						map[string]any{
+							"name": "ipconfig1",
+							"properties": map[string]any{
+								"subnet":                    map[string]any{"id": networkID + "/subnets/default"},
+								"privateIPAllocationMethod": "Dynamic",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, resource := range resources {
+		pollerResp, err := client.BeginCreateOrUpdateByID(ctx, resource.id, networkAPIVersion, resource.resource, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to begin creating %s: %w", resource.id, err)
+		}
+
+		if _, err := wait.ForOperation(ctx, pollerResp, nil); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", resource.id, err)
+		}
+	}
+
+	return networkInterfaceID, nil
+}
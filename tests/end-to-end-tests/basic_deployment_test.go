@@ -5,7 +5,9 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"e2e_tests/audit"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
@@ -24,12 +26,12 @@ type TestBasicDeploymentExternalResources struct {
  * Creates resources which are "external" to the az-backup module, and models
  * what would be backed up in a real scenario.
  */
-func setupExternalResourcesForBasicDeploymentTest(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestDiagnosticSettingsExternalResources {
+func setupExternalResourcesForBasicDeploymentTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestDiagnosticSettingsExternalResources {
 	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
-	resourceGroup := CreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
 
 	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
-	logAnalyticsWorkspace := CreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
 
 	externalResources := &TestDiagnosticSettingsExternalResources{
 		ResourceGroup:         resourceGroup,
@@ -45,8 +47,8 @@ func setupExternalResourcesForBasicDeploymentTest(t *testing.T, credential *azid
 func TestBasicDeployment(t *testing.T) {
 	t.Parallel()
 
-	environment := GetEnvironmentConfiguration(t)
-	credential := GetAzureCredential(t, environment)
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
 
 	uniqueId := random.UniqueId()
 	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
@@ -68,9 +70,9 @@ func TestBasicDeployment(t *testing.T) {
 	defer test_structure.RunTestStage(t, "teardown", func() {
 		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
 
-		terraform.Destroy(t, terraformOptions)
+		DestroyTerraform(t, environment, terraformOptions)
 
-		DeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
 	})
 
 	// Setup stage
@@ -99,7 +101,7 @@ func TestBasicDeployment(t *testing.T) {
 
 		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
 
-		terraform.InitAndApply(t, terraformOptions)
+		ApplyTerraform(t, environment, terraformOptions)
 	})
 
 	// Validate stage
@@ -107,7 +109,7 @@ func TestBasicDeployment(t *testing.T) {
 
 	test_structure.RunTestStage(t, "validate", func() {
 		// Validate resource group
-		resourceGroup := GetResourceGroup(t, environment.SubscriptionID, credential, resourceGroupName)
+		resourceGroup := MustGetResourceGroup(t, credential, environment.SubscriptionID, resourceGroupName)
 		assert.NotNil(t, resourceGroup, "Resource group does not exist")
 		assert.Equal(t, resourceGroupName, *resourceGroup.Name, "Resource group name does not match")
 		assert.Equal(t, resourceGroupLocation, *resourceGroup.Location, "Resource group location does not match")
@@ -122,7 +124,7 @@ func TestBasicDeployment(t *testing.T) {
 		}
 
 		// Validate backup vault
-		backupVault := GetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
 		assert.NotNil(t, backupVault, "Backup vault does not exist")
 		assert.Equal(t, backupVaultName, *backupVault.Name, "Backup vault name does not match")
 		assert.Equal(t, resourceGroupLocation, *backupVault.Location, "Backup vault location does not match")
@@ -139,5 +141,8 @@ func TestBasicDeployment(t *testing.T) {
 			assert.True(t, exists, "Tag %s does not exist", key)
 			assert.Equal(t, expectedValue, *value, "Tag %s value does not match", key)
 		}
+
+		// Validate the backup vault against the rules that the module promises
+		AssertVaultCompliance(t, credential, *backupVault.ID, audit.DefaultOptions())
 	})
 }
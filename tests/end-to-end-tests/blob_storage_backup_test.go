@@ -5,7 +5,11 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"e2e_tests/audit"
+	"e2e_tests/azure"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
@@ -29,20 +33,20 @@ type TestBlobStorageBackupExternalResources struct {
  * Creates resources which are "external" to the az-backup module, and models
  * what would be backed up in a real scenario.
  */
-func setupExternalResourcesForBlobStorageBackupTest(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestBlobStorageBackupExternalResources {
+func setupExternalResourcesForBlobStorageBackupTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestBlobStorageBackupExternalResources {
 	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
-	resourceGroup := CreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
 
 	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
-	logAnalyticsWorkspace := CreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
 
 	storageAccountOneName := fmt.Sprintf("sa%sexternal1", strings.ToLower(uniqueId))
-	storageAccountOne := CreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountOneName, resourceGroupLocation)
-	storageAccountOneContainer := CreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, storageAccountOneName, "test-container")
+	storageAccountOne := MustCreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountOneName, resourceGroupLocation, nil)
+	storageAccountOneContainer := MustCreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, storageAccountOneName, "test-container")
 
 	storageAccountTwoName := fmt.Sprintf("sa%sexternal2", strings.ToLower(uniqueId))
-	storageAccountTwo := CreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountTwoName, resourceGroupLocation)
-	storageAccountTwoContainer := CreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, storageAccountTwoName, "test-container")
+	storageAccountTwo := MustCreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountTwoName, resourceGroupLocation, nil)
+	storageAccountTwoContainer := MustCreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, storageAccountTwoName, "test-container")
 
 	externalResources := &TestBlobStorageBackupExternalResources{
 		ResourceGroup:              resourceGroup,
@@ -62,8 +66,8 @@ func setupExternalResourcesForBlobStorageBackupTest(t *testing.T, credential *az
 func TestBlobStorageBackup(t *testing.T) {
 	t.Parallel()
 
-	environment := GetEnvironmentConfiguration(t)
-	credential := GetAzureCredential(t, environment)
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
 
 	uniqueId := random.UniqueId()
 	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
@@ -88,6 +92,13 @@ func TestBlobStorageBackup(t *testing.T) {
 			"backup_intervals":           []string{"R/2024-01-01T00:00:00+00:00/P1W"},
 			"storage_account_id":         *externalResources.StorageAccountTwo.ID,
 			"storage_account_containers": []string{*externalResources.StorageAccountTwoContainer.Name},
+			// Custom templates, to check that the names are rendered as the module renders them
+			"backup_policy_naming_template":   "{resource_abbreviation}-{backup_name}-{resource_type}",
+			"backup_instance_naming_template": "{backup_name}-instance",
+			"retention_rules": []map[string]interface{}{
+				{"name": "Monthly", "duration": "P12M", "absolute_criteria": "FirstOfMonth"},
+				{"name": "Weekly", "duration": "P8W", "days_of_week": []string{"Monday"}},
+			},
 		},
 	}
 
@@ -97,9 +108,9 @@ func TestBlobStorageBackup(t *testing.T) {
 	defer test_structure.RunTestStage(t, "teardown", func() {
 		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
 
-		terraform.Destroy(t, terraformOptions)
+		DestroyTerraform(t, environment, terraformOptions)
 
-		DeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
 	})
 
 	// Setup stage
@@ -114,6 +125,7 @@ func TestBlobStorageBackup(t *testing.T) {
 				"resource_group_location":    resourceGroupLocation,
 				"backup_vault_name":          backupVaultName,
 				"log_analytics_workspace_id": *externalResources.LogAnalyticsWorkspace.ID,
+				"use_extended_retention":     true,
 				"blob_storage_backups":       blobStorageBackups,
 			},
 
@@ -128,55 +140,63 @@ func TestBlobStorageBackup(t *testing.T) {
 		// Save options for later test stages
 		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
 
-		terraform.InitAndApply(t, terraformOptions)
+		ApplyTerraform(t, environment, terraformOptions)
 	})
 
 	// Validate stage
 	// ...
 
 	test_structure.RunTestStage(t, "validate", func() {
-		backupVault := GetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
-		backupPolicies := GetBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
-		backupInstances := GetBackupInstances(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupPolicies := MustGetBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupInstances := MustGetBackupInstances(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
 
 		assert.Equal(t, len(blobStorageBackups), len(backupPolicies), "Expected to find %2 backup policies in vault", len(blobStorageBackups))
 		assert.Equal(t, len(blobStorageBackups), len(backupInstances), "Expected to find %2 backup instances in vault", len(blobStorageBackups))
 
 		for _, backup := range blobStorageBackups {
-			backupName := backup["backup_name"].(string)
 			retentionPeriod := backup["retention_period"].(string)
 			backupIntervals := backup["backup_intervals"].([]string)
 			storageAccountId := backup["storage_account_id"].(string)
 
 			// Validate backup policy
-			backupPolicyName := fmt.Sprintf("bkpol-blob-%s", backupName)
-			backupPolicy := GetBackupPolicyForName(backupPolicies, backupPolicyName)
+			backupNames := MustGetBackupNames(t, naming.ResourceTypeBlobStorage, backup)
+			backupPolicyName := backupNames.PolicyName()
+			backupPolicy := azure.GetBackupPolicyForName(backupPolicies, backupPolicyName)
 			assert.NotNil(t, backupPolicy, "Expected to find a backup policy called %s", backupPolicyName)
 
 			// Validate retention period
 			backupPolicyProperties := backupPolicy.Properties.(*armdataprotection.BackupPolicy)
-			retentionRule := GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "Default").(*armdataprotection.AzureRetentionRule)
+			retentionRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "Default").(*armdataprotection.AzureRetentionRule)
 			deleteOption := retentionRule.Lifecycles[0].DeleteAfter.(*armdataprotection.AbsoluteDeleteOption)
 			assert.Equal(t, retentionPeriod, *deleteOption.Duration, "Expected the backup policy retention period to be %s", retentionPeriod)
 
+			// Validate retention tiers
+			if retentionRules, ok := backup["retention_rules"].([]map[string]interface{}); ok {
+				AssertRetentionTiersEqual(t, retentionRules, backupPolicyProperties, armdataprotection.DataStoreTypesVaultStore)
+			}
+
 			// Validate backup intervals
-			backupRule := GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "BackupIntervals").(*armdataprotection.AzureBackupRule)
+			backupRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "BackupIntervals").(*armdataprotection.AzureBackupRule)
 			schedule := backupRule.Trigger.(*armdataprotection.ScheduleBasedTriggerContext).Schedule
-			for index, interval := range schedule.RepeatingTimeIntervals {
-				assert.Equal(t, backupIntervals[index], *interval, "Expected backup policy repeating interval %s to be %s", index, backupIntervals[index])
-			}
+			AssertBackupIntervalsEqual(t, backupIntervals, schedule.RepeatingTimeIntervals)
 
 			// Validate backup instance
-			backupInstanceName := fmt.Sprintf("bkinst-blob-%s", backupName)
-			backupInstance := GetBackupInstanceForName(backupInstances, backupInstanceName)
+			backupInstanceName := backupNames.InstanceName()
+			backupInstance := azure.GetBackupInstanceForName(backupInstances, backupInstanceName)
 			assert.NotNil(t, backupInstance, "Expected to find a backup policy called %s", backupInstanceName)
 			assert.Equal(t, storageAccountId, *backupInstance.Properties.DataSourceInfo.ResourceID, "Expected the backup instance source resource ID to be %s", storageAccountId)
 			assert.Equal(t, *backupPolicy.ID, *backupInstance.Properties.PolicyInfo.PolicyID, "Expected the backup instance policy ID to be %s", backupPolicy.ID)
 
 			// Validate role assignment
-			backupContributorRoleDefinition := GetRoleDefinition(t, credential, "Storage Account Backup Contributor")
-			backupContributorRoleAssignment := GetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, backupContributorRoleDefinition, storageAccountId)
+			backupContributorRoleDefinition := MustGetRoleDefinition(t, credential, "Storage Account Backup Contributor")
+			backupContributorRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, backupContributorRoleDefinition, storageAccountId)
 			assert.NotNil(t, backupContributorRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", backupContributorRoleDefinition.Name, *backupVault.Identity.PrincipalID, storageAccountId)
 		}
+
+		// Validate the backup vault against the rules that the module promises
+		auditOptions := audit.DefaultOptions()
+		auditOptions.ExtendedRetention = true
+		AssertVaultCompliance(t, credential, *backupVault.ID, auditOptions)
 	})
 }
@@ -0,0 +1,165 @@
+package e2e_tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestBlobStorageRestoreExternalResources struct {
+	ResourceGroup           armresources.ResourceGroup
+	LogAnalyticsWorkspace   armoperationalinsights.Workspace
+	StorageAccount          armstorage.Account
+	StorageAccountContainer armstorage.BlobContainer
+	RestoreStorageAccount   armstorage.Account
+}
+
+/*
+ * Creates resources which are "external" to the az-backup module, and models
+ * what would be backed up in a real scenario.
+ */
+func setupExternalResourcesForBlobStorageRestoreTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestBlobStorageRestoreExternalResources {
+	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+
+	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+
+	storageAccountName := fmt.Sprintf("sa%sexternal", strings.ToLower(uniqueId))
+	storageAccount := MustCreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, resourceGroupLocation, nil)
+	storageAccountContainer := MustCreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, "test-container")
+
+	restoreStorageAccountName := fmt.Sprintf("sa%srestore", strings.ToLower(uniqueId))
+	restoreStorageAccount := MustCreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, restoreStorageAccountName, resourceGroupLocation, nil)
+
+	externalResources := &TestBlobStorageRestoreExternalResources{
+		ResourceGroup:           resourceGroup,
+		LogAnalyticsWorkspace:   logAnalyticsWorkspace,
+		StorageAccount:          storageAccount,
+		StorageAccountContainer: storageAccountContainer,
+		RestoreStorageAccount:   restoreStorageAccount,
+	}
+
+	return externalResources
+}
+
+/*
+ * TestBlobStorageRestore tests that a blob storage backup can be restored into a new
+ * storage account, and that the restored data matches what was backed up.
+ */
+func TestBlobStorageRestore(t *testing.T) {
+	t.Parallel()
+
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
+
+	uniqueId := random.UniqueId()
+	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
+	resourceGroupLocation := "uksouth"
+	backupVaultName := fmt.Sprintf("bvault-nhsbackup-%s", uniqueId)
+
+	externalResources := setupExternalResourcesForBlobStorageRestoreTest(t, credential, environment.SubscriptionID, resourceGroupName, resourceGroupLocation, uniqueId)
+
+	// A map of backups which we'll use to apply the TF module, and then restore from
+	blobStorageBackups := map[string]map[string]interface{}{
+		"backup1": {
+			"backup_name":                "blob1",
+			"retention_period":           "P7D",
+			"backup_intervals":           []string{"R/2024-01-01T00:00:00+00:00/P1D"},
+			"storage_account_id":         *externalResources.StorageAccount.ID,
+			"storage_account_containers": []string{*externalResources.StorageAccountContainer.Name},
+		},
+	}
+
+	// Teardown stage
+	// ...
+
+	defer test_structure.RunTestStage(t, "teardown", func() {
+		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
+
+		DestroyTerraform(t, environment, terraformOptions)
+
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+	})
+
+	// Setup stage
+	// ...
+
+	test_structure.RunTestStage(t, "setup", func() {
+		terraformOptions := &terraform.Options{
+			TerraformDir: environment.TerraformFolder,
+
+			Vars: map[string]interface{}{
+				"resource_group_name":        resourceGroupName,
+				"resource_group_location":    resourceGroupLocation,
+				"backup_vault_name":          backupVaultName,
+				"log_analytics_workspace_id": *externalResources.LogAnalyticsWorkspace.ID,
+				"blob_storage_backups":       blobStorageBackups,
+			},
+
+			BackendConfig: map[string]interface{}{
+				"resource_group_name":  environment.TerraformStateResourceGroup,
+				"storage_account_name": environment.TerraformStateStorageAccount,
+				"container_name":       environment.TerraformStateContainer,
+				"key":                  backupVaultName + ".tfstate",
+			},
+		}
+
+		// Save options for later test stages
+		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
+
+		ApplyTerraform(t, environment, terraformOptions)
+	})
+
+	// Validate stage
+	// ...
+
+	test_structure.RunTestStage(t, "validate", func() {
+		testFile := MustCreateTestFile(t)
+		defer os.Remove(testFile.Name())
+
+		expectedContent, err := os.ReadFile(testFile.Name())
+		assert.NoError(t, err, "Failed to read test file: %v", err)
+
+		MustUploadFileToStorageAccount(t, credential, *externalResources.StorageAccount.Name, *externalResources.StorageAccountContainer.Name, testFile.Name())
+
+		backupInstanceName := MustGetBackupNames(t, naming.ResourceTypeBlobStorage, blobStorageBackups["backup1"]).InstanceName()
+		MustBeginAdHocBackup(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+
+		recoveryPoints := MustGetRecoveryPoints(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+		assert.NotEmpty(t, recoveryPoints, "Expected the ad-hoc backup to create a recovery point")
+
+		if len(recoveryPoints) == 0 {
+			return
+		}
+
+		// The vault identity needs to be able to write to the storage account it's restoring into
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		MustCreateRoleAssignment(t, credential, environment.SubscriptionID, *externalResources.RestoreStorageAccount.ID,
+			"Storage Account Backup Contributor", *backupVault.Identity.PrincipalID)
+		MustWaitForRoleAssignment(t, credential, environment.SubscriptionID, *externalResources.RestoreStorageAccount.ID,
+			"Storage Account Backup Contributor", *backupVault.Identity.PrincipalID)
+
+		restoreJobID := MustBeginBlobStorageRestore(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName,
+			*recoveryPoints[0].Name, externalResources.RestoreStorageAccount, []string{*externalResources.StorageAccountContainer.Name})
+
+		MustWaitForBackupJob(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, restoreJobID)
+
+		restoredContent := MustDownloadFileFromStorageAccount(t, credential, *externalResources.RestoreStorageAccount.Name, *externalResources.StorageAccountContainer.Name, filepath.Base(testFile.Name()))
+
+		assert.Equal(t, expectedContent, restoredContent, "Expected the restored blob to match the blob that was backed up")
+	})
+}
@@ -0,0 +1,118 @@
+/*
+ * backup-exporter serves the backup posture of one or more backup vaults as Prometheus metrics
+ * on /metrics, reading the vaults at an interval until interrupted.
+ *
+ * Usage:
+ *
+ *	go run ./cmd/backup-exporter -vault <resource-group>/<vault-name> [-vault ...] [-listen :9090] [-interval 5m]
+ *
+ * Azure credentials and the subscription are read from the same environment variables as the
+ * end-to-end tests.
+ */
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"e2e_tests/azure"
+	"e2e_tests/exporter"
+)
+
+const (
+	exitStopped = 0
+	exitError   = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("backup-exporter", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var vaults []exporter.Vault
+	flags.Func("vault", "A vault to export metrics for, as <resource-group>/<vault-name> (required, can be repeated)", func(value string) error {
+		vault, err := exporter.ParseVault(value)
+		if err != nil {
+			return err
+		}
+
+		vaults = append(vaults, vault)
+		return nil
+	})
+	listen := flags.String("listen", ":9090", "The address to serve metrics on")
+	interval := flags.Duration("interval", 5*time.Minute, "How often to read the vaults")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if len(vaults) == 0 {
+		fmt.Fprintln(stderr, "-vault must be set")
+		flags.Usage()
+		return exitError
+	}
+
+	if *interval <= 0 {
+		fmt.Fprintln(stderr, "-interval must be greater than zero")
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config, err := azure.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	credential, err := azure.NewCredential(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to obtain a credential: %v\n", err)
+		return exitError
+	}
+
+	vaultExporter := exporter.New(exporter.NewClient(credential, nil, config.SubscriptionID), vaults)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", vaultExporter.Handler())
+	server := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.ListenAndServe() }()
+
+	go vaultExporter.Run(ctx, *interval, func(errs []error) {
+		for _, err := range errs {
+			fmt.Fprintln(stderr, err)
+		}
+	})
+
+	fmt.Fprintf(stderr, "Serving metrics for %d vaults on %s/metrics\n", len(vaults), *listen)
+
+	select {
+	case err := <-serverErr:
+		fmt.Fprintf(stderr, "Failed to serve metrics: %v\n", err)
+		return exitError
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(stderr, "Failed to stop serving metrics: %v\n", err)
+		return exitError
+	}
+
+	return exitStopped
+}
@@ -0,0 +1,161 @@
+/*
+ * backup-sla reports on the backup jobs of a vault over a time window, writing the success
+ * rate, last successful backup, average duration and missed schedules of each backup instance,
+ * and exiting non-zero when any scheduled backup was missed. Reports can be written as CSV,
+ * JSON or Markdown.
+ *
+ * Usage:
+ *
+ *	go run ./cmd/backup-sla -resource-group <name> -vault-name <name> [-days 7 | -from <time> -to <time>] [flags]
+ *
+ * Azure credentials and the subscription are read from the same environment variables as the
+ * end-to-end tests.
+ */
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"time"
+
+	"e2e_tests/azure"
+	"e2e_tests/sla"
+)
+
+const (
+	exitMet    = 0
+	exitMissed = 1
+	exitError  = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("backup-sla", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	resourceGroupName := flags.String("resource-group", "", "The resource group of the backup vault (required)")
+	backupVaultName := flags.String("vault-name", "", "The name of the backup vault (required)")
+	days := flags.Int("days", 7, "The number of days up to now to report on, when -from isn't set")
+	from := flags.String("from", "", "The start of the window to report on, in RFC 3339 format")
+	to := flags.String("to", "", "The end of the window to report on, in RFC 3339 format (defaults to now)")
+	format := flags.String("format", string(sla.FormatMarkdown), "The format to write the report in (csv, json or markdown)")
+	output := flags.String("output", "", "The file to write the report to (defaults to stdout)")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *resourceGroupName == "" || *backupVaultName == "" {
+		fmt.Fprintln(stderr, "-resource-group and -vault-name must be set")
+		flags.Usage()
+		return exitError
+	}
+
+	if !slices.Contains(sla.Formats(), sla.Format(*format)) {
+		fmt.Fprintf(stderr, "-format %q is not supported\n", *format)
+		return exitError
+	}
+
+	windowStart, windowEnd, err := parseWindow(*from, *to, *days, time.Now().UTC())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config, err := azure.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	credential, err := azure.NewCredential(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to obtain a credential: %v\n", err)
+		return exitError
+	}
+
+	report, err := sla.Generate(ctx, credential, nil, config.SubscriptionID, *resourceGroupName, *backupVaultName, windowStart, windowEnd)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to generate report: %v\n", err)
+		return exitError
+	}
+
+	if err := writeReport(report, sla.Format(*format), *output, stdout); err != nil {
+		fmt.Fprintf(stderr, "Failed to write report: %v\n", err)
+		return exitError
+	}
+
+	for _, instance := range report.Instances {
+		if instance.MissedSchedules > 0 {
+			return exitMissed
+		}
+	}
+
+	return exitMet
+}
+
+/*
+ * Works out the window to report on from the flags: from -from to -to when -from is set, and
+ * otherwise the number of days up to -to.
+ */
+func parseWindow(from string, to string, days int, now time.Time) (time.Time, time.Time, error) {
+	windowEnd := now
+	if to != "" {
+		parsed, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("-to %q is not a valid time: %w", to, err)
+		}
+		windowEnd = parsed
+	}
+
+	if from == "" {
+		if days <= 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("-days must be greater than zero")
+		}
+		return windowEnd.AddDate(0, 0, -days), windowEnd, nil
+	}
+
+	windowStart, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("-from %q is not a valid time: %w", from, err)
+	}
+
+	if !windowStart.Before(windowEnd) {
+		return time.Time{}, time.Time{}, fmt.Errorf("-from must be before -to")
+	}
+
+	return windowStart, windowEnd, nil
+}
+
+/*
+ * Writes the report in the provided format, to the output file if one is provided or
+ * otherwise to stdout.
+ */
+func writeReport(report *sla.Report, format sla.Format, output string, stdout io.Writer) error {
+	if output == "" {
+		return report.Write(stdout, format)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+
+	if err := report.Write(file, format); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
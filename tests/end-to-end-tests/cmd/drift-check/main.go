@@ -0,0 +1,101 @@
+/*
+ * drift-check compares the backups described by a .tfvars or .tfvars.json file for the module
+ * with the backup policies and backup instances in the deployed vault, writing the policies and
+ * instances that are missing, extra or changed, and exiting non-zero when the vault has drifted.
+ *
+ * Usage:
+ *
+ *	go run ./cmd/drift-check -var-file <file> [-format text|json]
+ *
+ * Azure credentials and the subscription are read from the same environment variables as the
+ * end-to-end tests.
+ */
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"e2e_tests/azure"
+	"e2e_tests/drift"
+)
+
+const (
+	exitMatched = 0
+	exitDrifted = 1
+	exitError   = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("drift-check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	varFile := flags.String("var-file", "", "The .tfvars or .tfvars.json file the vault was deployed with (required)")
+	format := flags.String("format", "text", "The format to write the differences in (text or json)")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *varFile == "" {
+		fmt.Fprintln(stderr, "-var-file must be set")
+		flags.Usage()
+		return exitError
+	}
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "-format %q is not supported\n", *format)
+		return exitError
+	}
+
+	variables, err := drift.LoadVariables(*varFile)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config, err := azure.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	credential, err := azure.NewCredential(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to obtain a credential: %v\n", err)
+		return exitError
+	}
+
+	report, err := drift.Detect(ctx, credential, nil, config.SubscriptionID, variables)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to detect drift: %v\n", err)
+		return exitError
+	}
+
+	if *format == "json" {
+		err = report.WriteJSON(stdout)
+	} else {
+		err = report.WriteText(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to write differences: %v\n", err)
+		return exitError
+	}
+
+	if report.Drifted() {
+		return exitDrifted
+	}
+
+	return exitMatched
+}
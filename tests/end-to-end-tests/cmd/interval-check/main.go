@@ -0,0 +1,95 @@
+/*
+ * interval-check validates backup intervals against the rules that the module applies for a
+ * datasource type before they're deployed, and shows when each valid interval will next run.
+ *
+ * Usage:
+ *
+ *	go run ./cmd/interval-check -datasource <blob|adls|disk|pgflex|mysqlflex|aks|vm|afs> [flags] <interval>...
+ *
+ * For example:
+ *
+ *	go run ./cmd/interval-check -datasource disk -time-zone Europe/London R/2024-01-01T00:00:00+00:00/PT4H
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"e2e_tests/interval"
+)
+
+const (
+	exitValid   = 0
+	exitInvalid = 1
+	exitError   = 2
+)
+
+var datasourceTypes = map[string]string{
+	"blob":      interval.DatasourceBlobStorage,
+	"adls":      interval.DatasourceDataLakeStorage,
+	"disk":      interval.DatasourceManagedDisk,
+	"pgflex":    interval.DatasourcePostgresqlFlexibleServer,
+	"mysqlflex": interval.DatasourceMysqlFlexibleServer,
+	"aks":       interval.DatasourceAksCluster,
+	"vm":        interval.DatasourceVirtualMachine,
+	"afs":       interval.DatasourceFileShare,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], time.Now(), os.Stdout, os.Stderr))
+}
+
+func run(args []string, now time.Time, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("interval-check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	datasource := flags.String("datasource", "", "The datasource the intervals are for (blob, adls, disk, pgflex, mysqlflex, aks, vm or afs)")
+	timeZone := flags.String("time-zone", "UTC", "The IANA time zone to show the next runs in, e.g. Europe/London")
+	next := flags.Int("next", 5, "The number of upcoming runs to show for each interval")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	datasourceType, ok := datasourceTypes[*datasource]
+	if !ok {
+		fmt.Fprintf(stderr, "-datasource must be one of %s\n", strings.Join(slices.Sorted(maps.Keys(datasourceTypes)), ", "))
+		return exitError
+	}
+
+	location, err := time.LoadLocation(*timeZone)
+	if err != nil {
+		fmt.Fprintf(stderr, "-time-zone %q is not a valid time zone: %v\n", *timeZone, err)
+		return exitError
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "At least one interval must be provided")
+		flags.Usage()
+		return exitError
+	}
+
+	exitCode := exitValid
+	for _, value := range flags.Args() {
+		backupInterval, err := interval.Validate(value, datasourceType)
+		if err != nil {
+			fmt.Fprintf(stdout, "INVALID  %s\n         %v\n", value, err)
+			exitCode = exitInvalid
+			continue
+		}
+
+		fmt.Fprintf(stdout, "VALID    %s\n", value)
+		for _, run := range backupInterval.NextRuns(now, *next, location) {
+			fmt.Fprintf(stdout, "         %s\n", run.Format(time.RFC3339))
+		}
+	}
+
+	return exitCode
+}
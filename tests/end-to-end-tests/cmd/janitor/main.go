@@ -0,0 +1,102 @@
+/*
+ * janitor removes the resource groups and terraform state blobs that end to end tests leave
+ * behind when they fail between setup and teardown, writing a report of what it removed or
+ * couldn't remove and exiting non-zero when anything couldn't be removed.
+ *
+ * Usage:
+ *
+ *	go run ./cmd/janitor [-dry-run] [-min-age 24h] [flags]
+ *
+ * Azure credentials are read from the same environment variables as the end-to-end tests, and
+ * the terraform state storage account and container default to TF_STATE_STORAGE_ACCOUNT and
+ * TF_STATE_STORAGE_CONTAINER.
+ */
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"e2e_tests/azure"
+	"e2e_tests/janitor"
+)
+
+const (
+	exitCleaned = 0
+	exitFailed  = 1
+	exitError   = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	defaults := janitor.DefaultOptions()
+
+	flags := flag.NewFlagSet("janitor", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	minAge := flags.Duration("min-age", defaults.MinAge, "Leave resources younger than this alone, as they may belong to a running test")
+	dryRun := flags.Bool("dry-run", false, "Report what would be removed without removing anything")
+	stateStorageAccount := flags.String("state-storage-account", os.Getenv("TF_STATE_STORAGE_ACCOUNT"), "The storage account holding the terraform state (state blobs are kept if not set)")
+	stateContainer := flags.String("state-container", os.Getenv("TF_STATE_STORAGE_CONTAINER"), "The container holding the terraform state")
+	format := flags.String("format", "text", "The format to write the report in (text or json)")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "-format %q is not supported\n", *format)
+		return exitError
+	}
+
+	options := janitor.Options{
+		MinAge:              *minAge,
+		DryRun:              *dryRun,
+		StateStorageAccount: *stateStorageAccount,
+		StateContainer:      *stateContainer,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config, err := azure.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	credential, err := azure.NewCredential(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to obtain a credential: %v\n", err)
+		return exitError
+	}
+
+	report, err := janitor.Run(ctx, credential, nil, config.SubscriptionID, options)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to clean up test resources: %v\n", err)
+		return exitError
+	}
+
+	if *format == "json" {
+		err = report.WriteJSON(stdout)
+	} else {
+		err = report.WriteText(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to write report: %v\n", err)
+		return exitError
+	}
+
+	if report.Failed() {
+		return exitFailed
+	}
+
+	return exitCleaned
+}
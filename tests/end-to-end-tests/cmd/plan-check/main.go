@@ -0,0 +1,93 @@
+/*
+ * plan-check classifies the changes that a terraform plan of the module makes to backup vaults,
+ * backup policies, backup instances and role assignments as safe, risky or destructive, and
+ * exits non-zero if any change is at least as severe as -fail-on, so it can gate an apply in CI.
+ *
+ * Usage:
+ *
+ *	terraform plan -out tfplan
+ *	terraform show -json tfplan > tfplan.json
+ *	go run ./cmd/plan-check [-fail-on risky|destructive] [-format text|json] tfplan.json
+ *
+ * The plan is read from stdin when the path is -.
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"e2e_tests/plancheck"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+const (
+	exitPassed = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("plan-check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	failOn := flags.String("fail-on", plancheck.SeverityDestructive.String(), "Exit with 1 if any change is at least this severe (risky or destructive)")
+	format := flags.String("format", "text", "The format to write the findings in (text or json)")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	threshold, err := plancheck.ParseSeverity(*failOn)
+	if err != nil || threshold == plancheck.SeveritySafe {
+		fmt.Fprintf(stderr, "-fail-on %q must be risky or destructive\n", *failOn)
+		return exitError
+	}
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "-format %q is not supported\n", *format)
+		return exitError
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "The path to a plan written by `terraform show -json` must be provided")
+		flags.Usage()
+		return exitError
+	}
+
+	var plan *tfjson.Plan
+	if path := flags.Arg(0); path == "-" {
+		plan, err = plancheck.ReadPlan(stdin)
+	} else {
+		plan, err = plancheck.LoadPlan(path)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	report := plancheck.Analyze(plan)
+
+	if *format == "json" {
+		err = report.WriteJSON(stdout)
+	} else {
+		err = report.WriteText(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to write findings: %v\n", err)
+		return exitError
+	}
+
+	if report.Highest() >= threshold {
+		return exitFailed
+	}
+
+	return exitPassed
+}
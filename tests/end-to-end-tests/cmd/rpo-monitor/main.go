@@ -0,0 +1,133 @@
+/*
+ * rpo-monitor checks that every backup instance in a vault has a recovery point within the RPO
+ * set by its backup policy's backup intervals, writing the status of each instance. By default
+ * it checks once, exiting non-zero when any instance has breached its RPO. With -every it keeps
+ * running, writing a report at each interval until interrupted.
+ *
+ * Usage:
+ *
+ *	go run ./cmd/rpo-monitor -resource-group <name> -vault-name <name> [-every 15m] [flags]
+ *
+ * Azure credentials and the subscription are read from the same environment variables as the
+ * end-to-end tests.
+ */
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"e2e_tests/azure"
+	"e2e_tests/rpo"
+)
+
+const (
+	exitHealthy  = 0
+	exitBreached = 1
+	exitError    = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	defaults := rpo.DefaultThresholds()
+
+	flags := flag.NewFlagSet("rpo-monitor", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	resourceGroupName := flags.String("resource-group", "", "The resource group of the backup vault (required)")
+	backupVaultName := flags.String("vault-name", "", "The name of the backup vault (required)")
+	warning := flags.Float64("warning", defaults.Warning, "The fraction of the RPO after which an instance is flagged as a warning (0 to disable)")
+	grace := flags.Duration("grace", defaults.Grace, "The time allowed on top of the RPO for backups to complete before it's breached")
+	every := flags.Duration("every", 0, "Keep running and check at this interval, rather than checking once")
+	format := flags.String("format", "text", "The format to write reports in (text or json)")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *resourceGroupName == "" || *backupVaultName == "" {
+		fmt.Fprintln(stderr, "-resource-group and -vault-name must be set")
+		flags.Usage()
+		return exitError
+	}
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "-format %q is not supported\n", *format)
+		return exitError
+	}
+
+	if *every < 0 {
+		fmt.Fprintln(stderr, "-every must not be negative")
+		return exitError
+	}
+
+	thresholds := rpo.Thresholds{Warning: *warning, Grace: *grace}
+	if err := thresholds.Validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config, err := azure.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	credential, err := azure.NewCredential(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to obtain a credential: %v\n", err)
+		return exitError
+	}
+
+	check := func(ctx context.Context) (*rpo.Report, error) {
+		return rpo.Check(ctx, credential, nil, config.SubscriptionID, *resourceGroupName, *backupVaultName, thresholds)
+	}
+
+	write := func(report *rpo.Report) error {
+		if *format == "json" {
+			return report.WriteJSON(stdout)
+		}
+		return report.WriteText(stdout)
+	}
+
+	if *every > 0 {
+		// Failed checks are reported and retried at the next interval, so the monitor keeps
+		// running through transient errors
+		rpo.Watch(ctx, *every, check, func(report *rpo.Report, err error) {
+			if err != nil {
+				fmt.Fprintf(stderr, "Failed to check recovery points: %v\n", err)
+			} else if err := write(report); err != nil {
+				fmt.Fprintf(stderr, "Failed to write report: %v\n", err)
+			}
+		})
+
+		return exitHealthy
+	}
+
+	report, err := check(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to check recovery points: %v\n", err)
+		return exitError
+	}
+
+	if err := write(report); err != nil {
+		fmt.Fprintf(stderr, "Failed to write report: %v\n", err)
+		return exitError
+	}
+
+	if report.Breached() {
+		return exitBreached
+	}
+
+	return exitHealthy
+}
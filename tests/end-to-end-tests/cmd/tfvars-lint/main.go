@@ -0,0 +1,80 @@
+/*
+ * tfvars-lint checks .tfvars and .tfvars.json files for the module before they're planned,
+ * reporting every problem with its position in the file and exiting non-zero if any are found.
+ *
+ * Usage:
+ *
+ *	go run ./cmd/tfvars-lint [-format text|json] <file>...
+ */
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"e2e_tests/lint"
+)
+
+const (
+	exitValid    = 0
+	exitProblems = 1
+	exitError    = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("tfvars-lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	format := flags.String("format", "text", "The format to write the problems in (text or json)")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "-format %q is not supported\n", *format)
+		return exitError
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "At least one variables file must be provided")
+		flags.Usage()
+		return exitError
+	}
+
+	problems := []lint.Problem{}
+	for _, path := range flags.Args() {
+		fileProblems, err := lint.LintFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			return exitError
+		}
+		problems = append(problems, fileProblems...)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(problems); err != nil {
+			fmt.Fprintf(stderr, "Failed to write problems: %v\n", err)
+			return exitError
+		}
+	} else {
+		for _, problem := range problems {
+			fmt.Fprintln(stdout, problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return exitProblems
+	}
+
+	return exitValid
+}
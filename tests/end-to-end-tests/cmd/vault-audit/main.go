@@ -0,0 +1,126 @@
+/*
+ * vault-audit checks a deployed az-backup vault against the rules that the module promises,
+ * writing a pass/fail finding per rule and resource and exiting non-zero when any rule is
+ * violated. Findings can be written as text, JSON, JUnit XML or SARIF.
+ *
+ * Usage:
+ *
+ *	go run ./cmd/vault-audit -vault-id <backup-vault-resource-id> [flags]
+ *
+ * Azure credentials are read from the same environment variables as the end-to-end tests.
+ */
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+
+	"e2e_tests/audit"
+	"e2e_tests/azure"
+)
+
+const (
+	exitPassed    = 0
+	exitViolation = 1
+	exitError     = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	defaults := audit.DefaultOptions()
+
+	flags := flag.NewFlagSet("vault-audit", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	vaultID := flags.String("vault-id", "", "The resource ID of the backup vault to audit (required)")
+	immutability := flags.String("immutability", defaults.Immutability, "The expected immutability state of the vault (Disabled, Unlocked or Locked)")
+	softDelete := flags.String("soft-delete", defaults.SoftDelete, "The expected soft delete state of the vault (Off, On or AlwaysOn)")
+	redundancy := flags.String("redundancy", defaults.Redundancy, "The expected redundancy of the vault")
+	extendedRetention := flags.Bool("extended-retention", defaults.ExtendedRetention, "Allow retention periods beyond P7D, as when use_extended_retention is set")
+	format := flags.String("format", string(audit.FormatText), "The format to write findings in (text, json, junit or sarif)")
+	output := flags.String("output", "", "The file to write findings to (defaults to stdout)")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *vaultID == "" {
+		fmt.Fprintln(stderr, "-vault-id must be set")
+		flags.Usage()
+		return exitError
+	}
+
+	if !slices.Contains(audit.Formats(), audit.Format(*format)) {
+		fmt.Fprintf(stderr, "-format %q is not supported\n", *format)
+		return exitError
+	}
+
+	options := audit.Options{
+		Immutability:      *immutability,
+		SoftDelete:        *softDelete,
+		Redundancy:        *redundancy,
+		ExtendedRetention: *extendedRetention,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config, err := azure.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	credential, err := azure.NewCredential(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to obtain a credential: %v\n", err)
+		return exitError
+	}
+
+	report, err := audit.AuditVault(ctx, credential, nil, *vaultID, options)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to audit vault: %v\n", err)
+		return exitError
+	}
+
+	if err := writeReport(report, audit.Format(*format), *output, stdout); err != nil {
+		fmt.Fprintf(stderr, "Failed to write findings: %v\n", err)
+		return exitError
+	}
+
+	if !report.Passed() {
+		return exitViolation
+	}
+
+	return exitPassed
+}
+
+/*
+ * Writes the report in the provided format, to the output file if one is provided or
+ * otherwise to stdout.
+ */
+func writeReport(report *audit.Report, format audit.Format, output string, stdout io.Writer) error {
+	if output == "" {
+		return report.Write(stdout, format)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+
+	if err := report.Write(file, format); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,126 @@
+/*
+ * vault-immutability moves a backup vault between the Disabled, Unlocked and Locked
+ * immutability states, confirming the vault reached each state along the way. Without -state
+ * it writes the vault's current state. Transitions that lock the vault can't be reversed, and
+ * are refused unless -confirm is given the token that the refusal names.
+ *
+ * Usage:
+ *
+ *	go run ./cmd/vault-immutability -resource-group <name> -vault-name <name> [-state Unlocked] [-confirm <token>] [-dry-run]
+ *
+ * Azure credentials and the subscription are read from the same environment variables as the
+ * end-to-end tests.
+ */
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"e2e_tests/azure"
+	"e2e_tests/immutability"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+)
+
+const (
+	exitChanged = 0
+	exitRefused = 1
+	exitError   = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("vault-immutability", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	resourceGroupName := flags.String("resource-group", "", "The resource group of the backup vault (required)")
+	backupVaultName := flags.String("vault-name", "", "The name of the backup vault (required)")
+	state := flags.String("state", "", "The immutability state to move the vault to (Disabled, Unlocked or Locked)")
+	confirm := flags.String("confirm", "", "The confirmation token for a transition that can't be reversed")
+	dryRun := flags.Bool("dry-run", false, "Write the steps the transition would take without changing the vault")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *resourceGroupName == "" || *backupVaultName == "" {
+		fmt.Fprintln(stderr, "-resource-group and -vault-name must be set")
+		flags.Usage()
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config, err := azure.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	credential, err := azure.NewCredential(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to obtain a credential: %v\n", err)
+		return exitError
+	}
+
+	machine := immutability.New(immutability.NewClient(credential, nil, config.SubscriptionID), *resourceGroupName, *backupVaultName)
+
+	if *state == "" {
+		current, err := machine.Current(ctx)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to read the immutability of the backup vault: %v\n", err)
+			return exitError
+		}
+
+		fmt.Fprintf(stdout, "%s: %s\n", *backupVaultName, current)
+		return exitChanged
+	}
+
+	target := armdataprotection.ImmutabilityState(*state)
+
+	var result *immutability.Result
+	if *dryRun {
+		result, err = machine.Plan(ctx, target)
+	} else {
+		result, err = machine.TransitionTo(ctx, target, *confirm)
+	}
+
+	if errors.Is(err, immutability.ErrIllegalTransition) || errors.Is(err, immutability.ErrConfirmationRequired) {
+		fmt.Fprintf(stderr, "Refused: %v\n", err)
+		return exitRefused
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to change the immutability of the backup vault: %v\n", err)
+		return exitError
+	}
+
+	if len(result.Steps) == 0 {
+		fmt.Fprintf(stdout, "%s: already %s\n", *backupVaultName, result.To)
+		return exitChanged
+	}
+
+	for _, step := range result.Steps {
+		note := ""
+		if step.Irreversible {
+			note = " (irreversible)"
+		}
+
+		if *dryRun {
+			fmt.Fprintf(stdout, "%s: would change %s -> %s%s\n", *backupVaultName, step.From, step.To, note)
+		} else {
+			fmt.Fprintf(stdout, "%s: changed %s -> %s%s\n", *backupVaultName, step.From, step.To, note)
+		}
+	}
+
+	return exitChanged
+}
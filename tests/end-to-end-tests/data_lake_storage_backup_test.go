@@ -0,0 +1,201 @@
+package e2e_tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"e2e_tests/audit"
+	"e2e_tests/azure"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestDataLakeStorageBackupExternalResources struct {
+	ResourceGroup           armresources.ResourceGroup
+	LogAnalyticsWorkspace   armoperationalinsights.Workspace
+	StorageAccount          armstorage.Account
+	StorageAccountContainer armstorage.BlobContainer
+}
+
+/*
+ * Creates resources which are "external" to the az-backup module, and models
+ * what would be backed up in a real scenario.
+ */
+func setupExternalResourcesForDataLakeStorageBackupTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestDataLakeStorageBackupExternalResources {
+	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+
+	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+
+	storageAccountName := fmt.Sprintf("sa%sexternal", strings.ToLower(uniqueId))
+	storageAccount := MustCreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, resourceGroupLocation,
+		&azure.StorageAccountOptions{HierarchicalNamespace: true})
+	storageAccountContainer := MustCreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, "test-filesystem")
+
+	// Model an analytics layout, with nested directories
+	MustCreateStorageAccountDirectory(t, credential, storageAccountName, *storageAccountContainer.Name, "raw")
+	MustCreateStorageAccountDirectory(t, credential, storageAccountName, *storageAccountContainer.Name, "raw/2024")
+
+	testFile := MustCreateTestFile(t)
+	MustUploadFileToStorageAccount(t, credential, storageAccountName, *storageAccountContainer.Name, testFile.Name())
+
+	externalResources := &TestDataLakeStorageBackupExternalResources{
+		ResourceGroup:           resourceGroup,
+		LogAnalyticsWorkspace:   logAnalyticsWorkspace,
+		StorageAccount:          storageAccount,
+		StorageAccountContainer: storageAccountContainer,
+	}
+
+	return externalResources
+}
+
+/*
+ * TestDataLakeStorageBackup tests the deployment of a backup vault and backup policies for
+ * storage accounts with a hierarchical namespace (ADLS Gen2), and takes an ad-hoc backup.
+ */
+func TestDataLakeStorageBackup(t *testing.T) {
+	t.Parallel()
+
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
+
+	uniqueId := random.UniqueId()
+	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
+	resourceGroupLocation := "uksouth"
+	backupVaultName := fmt.Sprintf("bvault-nhsbackup-%s", uniqueId)
+
+	externalResources := setupExternalResourcesForDataLakeStorageBackupTest(t, credential, environment.SubscriptionID, resourceGroupName, resourceGroupLocation, uniqueId)
+
+	// A map of backups which we'll use to apply the TF module, and then validate the
+	// policies have been created correctly
+	dataLakeStorageBackups := map[string]map[string]interface{}{
+		"backup1": {
+			"backup_name":                "datalake1",
+			"retention_period":           "P7D",
+			"backup_intervals":           []string{"R/2024-01-01T00:00:00+00:00/P1D"},
+			"storage_account_id":         *externalResources.StorageAccount.ID,
+			"storage_account_containers": []string{*externalResources.StorageAccountContainer.Name},
+			"retention_rules": []map[string]interface{}{
+				{"name": "Yearly", "duration": "P7Y", "absolute_criteria": "FirstOfYear"},
+				{"name": "Monthly", "duration": "P12M", "absolute_criteria": "FirstOfMonth"},
+				{"name": "Weekly", "duration": "P4W", "absolute_criteria": "FirstOfWeek"},
+			},
+		},
+	}
+
+	// Teardown stage
+	// ...
+
+	defer test_structure.RunTestStage(t, "teardown", func() {
+		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
+
+		DestroyTerraform(t, environment, terraformOptions)
+
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+	})
+
+	// Setup stage
+	// ...
+
+	test_structure.RunTestStage(t, "setup", func() {
+		terraformOptions := &terraform.Options{
+			TerraformDir: environment.TerraformFolder,
+
+			Vars: map[string]interface{}{
+				"resource_group_name":        resourceGroupName,
+				"resource_group_location":    resourceGroupLocation,
+				"backup_vault_name":          backupVaultName,
+				"log_analytics_workspace_id": *externalResources.LogAnalyticsWorkspace.ID,
+				"use_extended_retention":     true,
+				"data_lake_storage_backups":  dataLakeStorageBackups,
+			},
+
+			BackendConfig: map[string]interface{}{
+				"resource_group_name":  environment.TerraformStateResourceGroup,
+				"storage_account_name": environment.TerraformStateStorageAccount,
+				"container_name":       environment.TerraformStateContainer,
+				"key":                  backupVaultName + ".tfstate",
+			},
+		}
+
+		// Save options for later test stages
+		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
+
+		ApplyTerraform(t, environment, terraformOptions)
+	})
+
+	// Validate stage
+	// ...
+
+	test_structure.RunTestStage(t, "validate", func() {
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupPolicies := MustGetBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupInstances := MustGetBackupInstances(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+
+		assert.Equal(t, len(dataLakeStorageBackups), len(backupPolicies), "Expected to find %d backup policies in vault", len(dataLakeStorageBackups))
+		assert.Equal(t, len(dataLakeStorageBackups), len(backupInstances), "Expected to find %d backup instances in vault", len(dataLakeStorageBackups))
+
+		for _, backup := range dataLakeStorageBackups {
+			retentionPeriod := backup["retention_period"].(string)
+			backupIntervals := backup["backup_intervals"].([]string)
+			storageAccountId := backup["storage_account_id"].(string)
+
+			// Validate backup policy
+			backupNames := MustGetBackupNames(t, naming.ResourceTypeDataLakeStorage, backup)
+			backupPolicyName := backupNames.PolicyName()
+			backupPolicy := azure.GetBackupPolicyForName(backupPolicies, backupPolicyName)
+			assert.NotNil(t, backupPolicy, "Expected to find a backup policy called %s", backupPolicyName)
+
+			// Validate retention period
+			backupPolicyProperties := backupPolicy.Properties.(*armdataprotection.BackupPolicy)
+			retentionRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "Default").(*armdataprotection.AzureRetentionRule)
+			deleteOption := retentionRule.Lifecycles[0].DeleteAfter.(*armdataprotection.AbsoluteDeleteOption)
+			assert.Equal(t, retentionPeriod, *deleteOption.Duration, "Expected the backup policy retention period to be %s", retentionPeriod)
+
+			// Validate retention tiers
+			if retentionRules, ok := backup["retention_rules"].([]map[string]interface{}); ok {
+				AssertRetentionTiersEqual(t, retentionRules, backupPolicyProperties, armdataprotection.DataStoreTypesVaultStore)
+			}
+
+			// Validate backup intervals
+			backupRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "BackupIntervals").(*armdataprotection.AzureBackupRule)
+			schedule := backupRule.Trigger.(*armdataprotection.ScheduleBasedTriggerContext).Schedule
+			AssertBackupIntervalsEqual(t, backupIntervals, schedule.RepeatingTimeIntervals)
+
+			// Validate backup instance
+			backupInstanceName := backupNames.InstanceName()
+			backupInstance := azure.GetBackupInstanceForName(backupInstances, backupInstanceName)
+			assert.NotNil(t, backupInstance, "Expected to find a backup instance called %s", backupInstanceName)
+			assert.Equal(t, storageAccountId, *backupInstance.Properties.DataSourceInfo.ResourceID, "Expected the backup instance source resource ID to be %s", storageAccountId)
+			assert.Equal(t, "Microsoft.Storage/storageAccounts/adlsBlobServices", *backupInstance.Properties.DataSourceInfo.DatasourceType, "Expected the backup instance to be a data lake storage backup")
+			assert.Equal(t, *backupPolicy.ID, *backupInstance.Properties.PolicyInfo.PolicyID, "Expected the backup instance policy ID to be %s", backupPolicy.ID)
+
+			// Validate role assignment
+			backupContributorRoleDefinition := MustGetRoleDefinition(t, credential, "Storage Account Backup Contributor")
+			backupContributorRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, backupContributorRoleDefinition, storageAccountId)
+			assert.NotNil(t, backupContributorRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", backupContributorRoleDefinition.Name, *backupVault.Identity.PrincipalID, storageAccountId)
+
+			// Validate an ad-hoc backup of the directories creates a recovery point
+			MustBeginAdHocBackup(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+
+			recoveryPoints := MustGetRecoveryPoints(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+			assert.NotEmpty(t, recoveryPoints, "Expected the ad-hoc backup to create a recovery point")
+		}
+
+		// Validate the backup vault against the rules that the module promises
+		auditOptions := audit.DefaultOptions()
+		auditOptions.ExtendedRetention = true
+		AssertVaultCompliance(t, credential, *backupVault.ID, auditOptions)
+	})
+}
@@ -0,0 +1,137 @@
+package e2e_tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestDiagnosticLogsExternalResources struct {
+	ResourceGroup           armresources.ResourceGroup
+	LogAnalyticsWorkspace   armoperationalinsights.Workspace
+	StorageAccount          armstorage.Account
+	StorageAccountContainer armstorage.BlobContainer
+}
+
+/*
+ * Creates resources which are "external" to the az-backup module, and models
+ * what would be backed up in a real scenario.
+ */
+func setupExternalResourcesForDiagnosticLogsTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestDiagnosticLogsExternalResources {
+	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+
+	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+
+	storageAccountName := fmt.Sprintf("sa%sexternal", strings.ToLower(uniqueId))
+	storageAccount := MustCreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, resourceGroupLocation, nil)
+	storageAccountContainer := MustCreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, "test-container")
+
+	externalResources := &TestDiagnosticLogsExternalResources{
+		ResourceGroup:           resourceGroup,
+		LogAnalyticsWorkspace:   logAnalyticsWorkspace,
+		StorageAccount:          storageAccount,
+		StorageAccountContainer: storageAccountContainer,
+	}
+
+	return externalResources
+}
+
+/*
+ * TestDiagnosticLogs tests that the backup vaults diagnostic settings deliver logs to the external
+ * log analytics workspace, by taking an ad-hoc backup and querying the workspace for its job.
+ */
+func TestDiagnosticLogs(t *testing.T) {
+	t.Parallel()
+
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
+
+	uniqueId := random.UniqueId()
+	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
+	resourceGroupLocation := "uksouth"
+	backupVaultName := fmt.Sprintf("bvault-nhsbackup-%s", uniqueId)
+
+	externalResources := setupExternalResourcesForDiagnosticLogsTest(t, credential, environment.SubscriptionID, resourceGroupName, resourceGroupLocation, uniqueId)
+
+	blobStorageBackups := map[string]map[string]interface{}{
+		"backup1": {
+			"backup_name":                "blob1",
+			"retention_period":           "P7D",
+			"backup_intervals":           []string{"R/2024-01-01T00:00:00+00:00/P1D"},
+			"storage_account_id":         *externalResources.StorageAccount.ID,
+			"storage_account_containers": []string{*externalResources.StorageAccountContainer.Name},
+		},
+	}
+
+	// Teardown stage
+	// ...
+
+	defer test_structure.RunTestStage(t, "teardown", func() {
+		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
+
+		DestroyTerraform(t, environment, terraformOptions)
+
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+	})
+
+	// Setup stage
+	// ...
+
+	test_structure.RunTestStage(t, "setup", func() {
+		terraformOptions := &terraform.Options{
+			TerraformDir: environment.TerraformFolder,
+
+			Vars: map[string]interface{}{
+				"resource_group_name":        resourceGroupName,
+				"resource_group_location":    resourceGroupLocation,
+				"backup_vault_name":          backupVaultName,
+				"log_analytics_workspace_id": *externalResources.LogAnalyticsWorkspace.ID,
+				"blob_storage_backups":       blobStorageBackups,
+			},
+
+			BackendConfig: map[string]interface{}{
+				"resource_group_name":  environment.TerraformStateResourceGroup,
+				"storage_account_name": environment.TerraformStateStorageAccount,
+				"container_name":       environment.TerraformStateContainer,
+				"key":                  backupVaultName + ".tfstate",
+			},
+		}
+
+		// Save options for later test stages
+		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
+
+		ApplyTerraform(t, environment, terraformOptions)
+	})
+
+	// Validate stage
+	// ...
+
+	test_structure.RunTestStage(t, "validate", func() {
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+
+		backupInstanceName := MustGetBackupNames(t, naming.ResourceTypeBlobStorage, blobStorageBackups["backup1"]).InstanceName()
+		job := MustBeginAdHocBackup(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+
+		// Logs are addressed by the workspace's customer ID rather than its resource ID
+		workspaceID := *externalResources.LogAnalyticsWorkspace.Properties.CustomerID
+		jobLogs := MustWaitForBackupJobLogs(t, credential, workspaceID, job.JobID)
+
+		for _, jobLog := range jobLogs {
+			assert.Equal(t, "Backup", jobLog.JobOperation, "Expected the job log to be for a backup")
+			assert.True(t, strings.EqualFold(*backupVault.ID, jobLog.ResourceID), "Expected the job log to be from backup vault %s, got %s", *backupVault.ID, jobLog.ResourceID)
+		}
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"e2e_tests/audit"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/gruntwork-io/terratest/modules/random"
@@ -23,12 +25,12 @@ type TestDiagnosticSettingsExternalResources struct {
  * Creates resources which are "external" to the az-backup module, and models
  * what would be backed up in a real scenario.
  */
-func setupExternalResourcesForDiagnosticSettingsTest(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestDiagnosticSettingsExternalResources {
+func setupExternalResourcesForDiagnosticSettingsTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestDiagnosticSettingsExternalResources {
 	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
-	resourceGroup := CreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
 
 	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
-	logAnalyticsWorkspace := CreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
 
 	externalResources := &TestDiagnosticSettingsExternalResources{
 		ResourceGroup:         resourceGroup,
@@ -45,8 +47,8 @@ func setupExternalResourcesForDiagnosticSettingsTest(t *testing.T, credential *a
 func TestDiagnosticSettings(t *testing.T) {
 	t.Parallel()
 
-	environment := GetEnvironmentConfiguration(t)
-	credential := GetAzureCredential(t, environment)
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
 
 	uniqueId := random.UniqueId()
 	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
@@ -61,9 +63,9 @@ func TestDiagnosticSettings(t *testing.T) {
 	defer test_structure.RunTestStage(t, "teardown", func() {
 		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
 
-		terraform.Destroy(t, terraformOptions)
+		DestroyTerraform(t, environment, terraformOptions)
 
-		DeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
 	})
 
 	// Setup stage
@@ -91,7 +93,7 @@ func TestDiagnosticSettings(t *testing.T) {
 		// Save options for later test stages
 		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
 
-		terraform.InitAndApply(t, terraformOptions)
+		ApplyTerraform(t, environment, terraformOptions)
 	})
 
 	// Validate stage
@@ -111,8 +113,8 @@ func TestDiagnosticSettings(t *testing.T) {
 			"Health",
 		}
 
-		backupVault := GetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
-		diagnosticSettings := GetDiagnosticSettings(t, credential, *backupVault.ID, *backupVault.Name)
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		diagnosticSettings := MustGetDiagnosticSettings(t, credential, *backupVault.ID)
 
 		assert.Equal(t, len(diagnosticSettings.Properties.Logs), len(expectedLogCategories), "Expected to find %2 log categories in diagnostic settings", len(expectedLogCategories))
 		assert.Equal(t, len(diagnosticSettings.Properties.Metrics), len(expectedMetricCategories), "Expected to find %2 metric categories in diagnostic settings", len(expectedMetricCategories))
@@ -138,5 +140,8 @@ func TestDiagnosticSettings(t *testing.T) {
 			}
 			assert.True(t, found, "Expected metric category %s not found in diagnostic settings", expectedCategory)
 		}
+
+		// Validate the backup vault against the rules that the module promises
+		AssertVaultCompliance(t, credential, *backupVault.ID, audit.DefaultOptions())
 	})
 }
@@ -0,0 +1,317 @@
+/*
+ * Package drift compares the backups described by the module input variables with the backup
+ * policies and backup instances in the live vault, finding the changes made outside terraform,
+ * e.g. in the portal.
+ */
+package drift
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"e2e_tests/azure"
+	"e2e_tests/interval"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+)
+
+/*
+ * The attributes that are compared between the variables and the vault.
+ */
+const (
+	AttributeRetentionPeriod = "retention_period"
+	AttributeBackupIntervals = "backup_intervals"
+	AttributeDatasourceID    = "datasource_id"
+	AttributeBackupPolicy    = "backup_policy"
+)
+
+/*
+ * What the vault should contain for an entry in the backup variables.
+ */
+type expectedBackup struct {
+	path            string
+	names           naming.Backup
+	retentionPeriod string
+	backupIntervals []string
+	datasourceID    string
+}
+
+/*
+ * Detect reads the backup policies and backup instances in the vault described by the
+ * variables, and compares them with the variables.
+ */
+func Detect(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, variables *Variables) (*Report, error) {
+	policies, err := azure.GetBackupPolicies(ctx, credential, clientOptions, subscriptionID, variables.ResourceGroupName, variables.BackupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := azure.GetBackupInstances(ctx, credential, clientOptions, subscriptionID, variables.ResourceGroupName, variables.BackupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	return Compare(variables, policies, instances), nil
+}
+
+/*
+ * Compare compares the backups described by the variables with the backup policies and backup
+ * instances in a vault. Names are compared case-insensitively, as Azure resource names are.
+ */
+func Compare(variables *Variables, policies []*armdataprotection.BaseBackupPolicyResource, instances []*armdataprotection.BackupInstanceResource) *Report {
+	report := &Report{ResourceGroupName: variables.ResourceGroupName, BackupVaultName: variables.BackupVaultName}
+
+	expectedPolicies := map[string]bool{}
+	expectedInstances := map[string]bool{}
+
+	for _, expected := range expectedBackups(variables) {
+		policyName, instanceName := expected.names.PolicyName(), expected.names.InstanceName()
+		expectedPolicies[strings.ToLower(policyName)] = true
+		expectedInstances[strings.ToLower(instanceName)] = true
+
+		policy := getPolicyForName(policies, policyName)
+		if policy == nil {
+			report.add(Difference{Kind: KindMissing, Resource: naming.ResourceBackupPolicy, Name: policyName, Backup: expected.path})
+		} else {
+			comparePolicy(report, expected, policy)
+		}
+
+		instance := getInstanceForName(instances, instanceName)
+		if instance == nil {
+			report.add(Difference{Kind: KindMissing, Resource: naming.ResourceBackupInstance, Name: instanceName, Backup: expected.path})
+		} else {
+			compareInstance(report, expected, instance)
+		}
+	}
+
+	for _, policy := range policies {
+		if name := valueOf(policy.Name); !expectedPolicies[strings.ToLower(name)] {
+			report.add(Difference{Kind: KindExtra, Resource: naming.ResourceBackupPolicy, Name: name})
+		}
+	}
+
+	for _, instance := range instances {
+		if name := valueOf(instance.Name); !expectedInstances[strings.ToLower(name)] {
+			report.add(Difference{Kind: KindExtra, Resource: naming.ResourceBackupInstance, Name: name})
+		}
+	}
+
+	return report
+}
+
+/*
+ * Gets what the vault should contain for each entry in the backup variables, in the order
+ * terraform would plan them.
+ */
+func expectedBackups(variables *Variables) []expectedBackup {
+	var expected []expectedBackup
+
+	add := func(variable string, resourceType string, backups map[string]Backup, datasourceID func(Backup) string) {
+		for _, key := range slices.Sorted(maps.Keys(backups)) {
+			backup := backups[key]
+			expected = append(expected, expectedBackup{
+				path: fmt.Sprintf("%s[%q]", variable, key),
+				names: naming.Backup{
+					ResourceType:           resourceType,
+					BackupName:             backup.BackupName,
+					PolicyNamingTemplate:   backup.BackupPolicyNamingTemplate,
+					InstanceNamingTemplate: backup.BackupInstanceNamingTemplate,
+				},
+				retentionPeriod: backup.RetentionPeriod,
+				backupIntervals: backup.BackupIntervals,
+				datasourceID:    datasourceID(backup),
+			})
+		}
+	}
+
+	add("blob_storage_backups", naming.ResourceTypeBlobStorage, variables.BlobStorageBackups, func(backup Backup) string { return backup.StorageAccountID })
+	add("data_lake_storage_backups", naming.ResourceTypeDataLakeStorage, variables.DataLakeStorageBackups, func(backup Backup) string { return backup.StorageAccountID })
+	add("managed_disk_backups", naming.ResourceTypeManagedDisk, variables.ManagedDiskBackups, func(backup Backup) string { return backup.ManagedDiskID })
+	add("postgresql_flexible_server_backups", naming.ResourceTypePostgresqlFlexibleServer, variables.PostgresqlFlexibleServerBackups, func(backup Backup) string { return backup.ServerID })
+	add("mysql_flexible_server_backups", naming.ResourceTypeMysqlFlexibleServer, variables.MysqlFlexibleServerBackups, func(backup Backup) string { return backup.ServerID })
+	add("aks_cluster_backups", naming.ResourceTypeAksCluster, variables.AksClusterBackups, func(backup Backup) string { return backup.ClusterID })
+
+	return expected
+}
+
+func comparePolicy(report *Report, expected expectedBackup, policy *armdataprotection.BaseBackupPolicyResource) {
+	changed := func(attribute string, expectedValue string, actualValue string) {
+		report.add(Difference{
+			Kind:      KindChanged,
+			Resource:  naming.ResourceBackupPolicy,
+			Name:      valueOf(policy.Name),
+			Backup:    expected.path,
+			Attribute: attribute,
+			Expected:  expectedValue,
+			Actual:    actualValue,
+		})
+	}
+
+	backupPolicy, ok := policy.Properties.(*armdataprotection.BackupPolicy)
+	if !ok {
+		changed(AttributeRetentionPeriod, expected.retentionPeriod, "")
+		changed(AttributeBackupIntervals, strings.Join(expected.backupIntervals, ", "), "")
+		return
+	}
+
+	if retentionPeriod := defaultRetentionPeriod(backupPolicy); !durationsEqual(expected.retentionPeriod, retentionPeriod) {
+		changed(AttributeRetentionPeriod, expected.retentionPeriod, retentionPeriod)
+	}
+
+	if backupIntervals := backupIntervals(backupPolicy); !intervalsEqual(expected.backupIntervals, backupIntervals) {
+		changed(AttributeBackupIntervals, strings.Join(expected.backupIntervals, ", "), strings.Join(backupIntervals, ", "))
+	}
+}
+
+func compareInstance(report *Report, expected expectedBackup, instance *armdataprotection.BackupInstanceResource) {
+	changed := func(attribute string, expectedValue string, actualValue string) {
+		report.add(Difference{
+			Kind:      KindChanged,
+			Resource:  naming.ResourceBackupInstance,
+			Name:      valueOf(instance.Name),
+			Backup:    expected.path,
+			Attribute: attribute,
+			Expected:  expectedValue,
+			Actual:    actualValue,
+		})
+	}
+
+	var datasourceID, policyID string
+	if properties := instance.Properties; properties != nil {
+		if properties.DataSourceInfo != nil {
+			datasourceID = valueOf(properties.DataSourceInfo.ResourceID)
+		}
+		if properties.PolicyInfo != nil {
+			policyID = valueOf(properties.PolicyInfo.PolicyID)
+		}
+	}
+
+	if !strings.EqualFold(expected.datasourceID, datasourceID) {
+		changed(AttributeDatasourceID, expected.datasourceID, datasourceID)
+	}
+
+	policyName := expected.names.PolicyName()
+	if actualPolicyName := policyID[strings.LastIndex(policyID, "/")+1:]; !strings.EqualFold(policyName, actualPolicyName) {
+		changed(AttributeBackupPolicy, policyName, actualPolicyName)
+	}
+}
+
+/*
+ * Gets the retention period of the Default retention rule, which the module sets from
+ * retention_period.
+ */
+func defaultRetentionPeriod(backupPolicy *armdataprotection.BackupPolicy) string {
+	retentionRule, ok := azure.GetBackupPolicyRuleForName(backupPolicy.PolicyRules, "Default").(*armdataprotection.AzureRetentionRule)
+	if !ok || len(retentionRule.Lifecycles) == 0 {
+		return ""
+	}
+
+	deleteOption, ok := retentionRule.Lifecycles[0].DeleteAfter.(*armdataprotection.AbsoluteDeleteOption)
+	if !ok {
+		return ""
+	}
+
+	return valueOf(deleteOption.Duration)
+}
+
+/*
+ * Gets the repeating time intervals of the BackupIntervals backup rule, which the module sets
+ * from backup_intervals.
+ */
+func backupIntervals(backupPolicy *armdataprotection.BackupPolicy) []string {
+	schedule := azure.GetBackupPolicySchedule(backupPolicy)
+	if schedule == nil {
+		return nil
+	}
+
+	var intervals []string
+	for _, value := range schedule.RepeatingTimeIntervals {
+		intervals = append(intervals, valueOf(value))
+	}
+
+	return intervals
+}
+
+/*
+ * Compares durations by what they mean rather than how they're written, as Azure may
+ * normalise them (e.g. P1W to P7D).
+ */
+func durationsEqual(expected string, actual string) bool {
+	if expected == actual {
+		return true
+	}
+
+	expectedDuration, err := interval.ParseDuration(expected)
+	if err != nil {
+		return false
+	}
+
+	actualDuration, err := interval.ParseDuration(actual)
+	if err != nil {
+		return false
+	}
+
+	return expectedDuration.Equal(actualDuration)
+}
+
+/*
+ * Compares intervals by the runs they schedule rather than how they're written, as Azure may
+ * write them differently (e.g. with a Z rather than a +00:00 offset).
+ */
+func intervalsEqual(expected []string, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+
+	for index := range expected {
+		if expected[index] == actual[index] {
+			continue
+		}
+
+		expectedInterval, err := interval.Parse(expected[index])
+		if err != nil {
+			return false
+		}
+
+		actualInterval, err := interval.Parse(actual[index])
+		if err != nil || !expectedInterval.Equal(actualInterval) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func getPolicyForName(policies []*armdataprotection.BaseBackupPolicyResource, name string) *armdataprotection.BaseBackupPolicyResource {
+	for _, policy := range policies {
+		if strings.EqualFold(valueOf(policy.Name), name) {
+			return policy
+		}
+	}
+
+	return nil
+}
+
+func getInstanceForName(instances []*armdataprotection.BackupInstanceResource, name string) *armdataprotection.BackupInstanceResource {
+	for _, instance := range instances {
+		if strings.EqualFold(valueOf(instance.Name), name) {
+			return instance
+		}
+	}
+
+	return nil
+}
+
+func valueOf(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
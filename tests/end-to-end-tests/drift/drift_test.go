@@ -0,0 +1,209 @@
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"e2e_tests/emulator"
+	"e2e_tests/emulator/emulatortest"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testResourceGroupName = "rg-drift"
+	testBackupVaultName   = "bvault-drift"
+)
+
+var (
+	testStorageAccountID = emulatortest.ResourceID("rg-data", "Microsoft.Storage/storageAccounts", "sadrift")
+	testManagedDiskID    = emulatortest.ResourceID("rg-data", "Microsoft.Compute/disks", "disk-drift")
+)
+
+func testVariables() map[string]interface{} {
+	diskBackup := emulatortest.ManagedDiskBackup("disk1", testManagedDiskID)
+	diskBackup["backup_instance_naming_template"] = "{backup_name}-{resource_type}"
+
+	variables := emulatortest.Variables(testResourceGroupName, testBackupVaultName)
+	variables["blob_storage_backups"] = map[string]map[string]interface{}{
+		"backup1": emulatortest.BlobStorageBackup("blob1", testStorageAccountID),
+	}
+	variables["managed_disk_backups"] = map[string]map[string]interface{}{
+		"backup1": diskBackup,
+	}
+
+	return variables
+}
+
+/*
+ * Applies the module for the variables to the emulator, and returns a credential for it along
+ * with the variables as read from a .tfvars.json file.
+ */
+func deployVault(t *testing.T, vars map[string]interface{}) (azcore.TokenCredential, *Variables) {
+	credential, err := emulatortest.Shared().Credential()
+	require.NoError(t, err)
+
+	require.NoError(t, emulatortest.Shared().Apply(emulator.SubscriptionID, vars))
+
+	data, err := json.Marshal(vars)
+	require.NoError(t, err)
+
+	variables, err := ReadVariables("test.tfvars.json", data)
+	require.NoError(t, err)
+
+	return credential, variables
+}
+
+func vaultID() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataProtection/backupVaults/%s", emulator.SubscriptionID, testResourceGroupName, testBackupVaultName)
+}
+
+/*
+ * Changes a policy rule of a backup policy in the emulator, as if it had been edited in the portal.
+ */
+func editPolicyRule(t *testing.T, policyName string, ruleName string, edit func(rule map[string]any)) {
+	policyID := vaultID() + "/backupPolicies/" + policyName
+	policy := emulatortest.Shared().Get(policyID)
+	require.NotNil(t, policy, "Expected to find backup policy %s", policyName)
+
+	properties := policy["properties"].(map[string]any)
+	for _, rule := range properties["policyRules"].([]any) {
+		if rule := rule.(map[string]any); rule["name"] == ruleName {
+			edit(rule)
+		}
+	}
+
+	emulatortest.Shared().Put(policyID, policy)
+}
+
+/*
+ * TestDetectNoDrift tests that a vault deployed from the variables matches them, including
+ * names from custom naming templates.
+ */
+func TestDetectNoDrift(t *testing.T) {
+	credential, variables := deployVault(t, testVariables())
+	defer func() { require.NoError(t, emulatortest.Shared().Destroy(emulator.SubscriptionID, testVariables())) }()
+
+	report, err := Detect(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, variables)
+	require.NoError(t, err)
+
+	assert.False(t, report.Drifted())
+	assert.Empty(t, report.Differences)
+}
+
+/*
+ * TestDetectDrift tests that policies and instances changed outside terraform are reported as
+ * missing, extra or changed.
+ */
+func TestDetectDrift(t *testing.T) {
+	credential, variables := deployVault(t, testVariables())
+	defer func() { require.NoError(t, emulatortest.Shared().Destroy(emulator.SubscriptionID, testVariables())) }()
+
+	// Change the retention period and backup intervals of the policies
+	editPolicyRule(t, "bkpol-disk-disk1", "Default", func(rule map[string]any) {
+		lifecycle := rule["lifecycles"].([]any)[0].(map[string]any)
+		lifecycle["deleteAfter"].(map[string]any)["duration"] = "P30D"
+	})
+	editPolicyRule(t, "bkpol-blob-blob1", "BackupIntervals", func(rule map[string]any) {
+		schedule := rule["trigger"].(map[string]any)["schedule"].(map[string]any)
+		schedule["repeatingTimeIntervals"] = []any{"R/2024-01-01T00:00:00+00:00/P1W"}
+	})
+
+	// Add a policy, and replace the blob instance with one backing up another storage account
+	policy := emulatortest.Shared().Get(vaultID() + "/backupPolicies/bkpol-disk-disk1")
+	emulatortest.Shared().Put(vaultID()+"/backupPolicies/bkpol-manual", policy)
+
+	instance := emulatortest.Shared().Get(vaultID() + "/backupInstances/bkinst-blob-blob1")
+	emulatortest.Shared().Delete(instance["id"].(string))
+	otherStorageAccountID := testStorageAccountID + "other"
+	instance["properties"].(map[string]any)["dataSourceInfo"].(map[string]any)["resourceID"] = otherStorageAccountID
+	emulatortest.Shared().Put(vaultID()+"/backupInstances/bkinst-blob-manual", instance)
+
+	report, err := Detect(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, variables)
+	require.NoError(t, err)
+
+	blobBackup, diskBackup := `blob_storage_backups["backup1"]`, `managed_disk_backups["backup1"]`
+	assert.True(t, report.Drifted())
+	assert.Equal(t, []Difference{
+		{Kind: KindChanged, Resource: naming.ResourceBackupPolicy, Name: "bkpol-blob-blob1", Backup: blobBackup, Attribute: AttributeBackupIntervals,
+			Expected: "R/2024-01-01T00:00:00+00:00/P1D", Actual: "R/2024-01-01T00:00:00+00:00/P1W"},
+		{Kind: KindMissing, Resource: naming.ResourceBackupInstance, Name: "bkinst-blob-blob1", Backup: blobBackup},
+		{Kind: KindChanged, Resource: naming.ResourceBackupPolicy, Name: "bkpol-disk-disk1", Backup: diskBackup, Attribute: AttributeRetentionPeriod,
+			Expected: "P7D", Actual: "P30D"},
+		{Kind: KindExtra, Resource: naming.ResourceBackupPolicy, Name: "bkpol-manual"},
+		{Kind: KindExtra, Resource: naming.ResourceBackupInstance, Name: "bkinst-blob-manual"},
+	}, report.Differences)
+}
+
+/*
+ * TestCompareInstance tests that an instance backing up another datasource, or using another
+ * policy, is reported as changed.
+ */
+func TestCompareInstance(t *testing.T) {
+	credential, variables := deployVault(t, testVariables())
+	defer func() { require.NoError(t, emulatortest.Shared().Destroy(emulator.SubscriptionID, testVariables())) }()
+
+	instanceID := vaultID() + "/backupInstances/disk1-disk"
+	instance := emulatortest.Shared().Get(instanceID)
+	properties := instance["properties"].(map[string]any)
+	properties["dataSourceInfo"].(map[string]any)["resourceID"] = testManagedDiskID + "-restored"
+	properties["policyInfo"].(map[string]any)["policyId"] = vaultID() + "/backupPolicies/bkpol-blob-blob1"
+	emulatortest.Shared().Put(instanceID, instance)
+
+	report, err := Detect(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, variables)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Difference{
+		{Kind: KindChanged, Resource: naming.ResourceBackupInstance, Name: "disk1-disk", Backup: `managed_disk_backups["backup1"]`, Attribute: AttributeDatasourceID,
+			Expected: testManagedDiskID, Actual: testManagedDiskID + "-restored"},
+		{Kind: KindChanged, Resource: naming.ResourceBackupInstance, Name: "disk1-disk", Backup: `managed_disk_backups["backup1"]`, Attribute: AttributeBackupPolicy,
+			Expected: "bkpol-disk-disk1", Actual: "bkpol-blob-blob1"},
+	}, report.Differences)
+}
+
+/*
+ * TestReadVariables tests that a .tfvars file is read the same as the equivalent .tfvars.json.
+ */
+func TestReadVariables(t *testing.T) {
+	hclVariables, err := ReadVariables("test.tfvars", []byte(`
+resource_group_name = "rg"
+backup_vault_name   = "vault"
+
+postgresql_flexible_server_backups = {
+  backup1 = {
+    backup_name      = "server1"
+    retention_period = "P7D"
+    backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1W"]
+    server_id        = "server-id"
+  }
+}
+`))
+	require.NoError(t, err)
+
+	jsonVariables, err := ReadVariables("test.tfvars.json", []byte(`{
+  "resource_group_name": "rg",
+  "backup_vault_name": "vault",
+  "postgresql_flexible_server_backups": {
+    "backup1": {
+      "backup_name": "server1",
+      "retention_period": "P7D",
+      "backup_intervals": ["R/2024-01-01T00:00:00+00:00/P1W"],
+      "server_id": "server-id"
+    }
+  }
+}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, jsonVariables, hclVariables)
+	assert.Equal(t, "server-id", hclVariables.PostgresqlFlexibleServerBackups["backup1"].ServerID)
+
+	_, err = ReadVariables("test.tfvars", []byte(`resource_group_name = var.name`))
+	assert.Error(t, err)
+
+	_, err = ReadVariables("test.tfvars.json", []byte(`{"resource_group_name": "rg"}`))
+	assert.EqualError(t, err, "resource_group_name and backup_vault_name must be set")
+}
@@ -0,0 +1,102 @@
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+ * Kind is how a resource in the vault differs from the variables.
+ */
+type Kind string
+
+const (
+	KindMissing Kind = "missing"
+	KindExtra   Kind = "extra"
+	KindChanged Kind = "changed"
+)
+
+/*
+ * Difference is a backup policy or backup instance that's missing from the vault, in the vault
+ * but not in the variables, or has an attribute that's been changed.
+ */
+type Difference struct {
+	Kind     Kind   `json:"kind"`
+	Resource string `json:"resource"`
+	Name     string `json:"name"`
+	// Backup is the entry in the variables that the resource is deployed for, which extra
+	// resources don't have
+	Backup    string `json:"backup,omitempty"`
+	Attribute string `json:"attribute,omitempty"`
+	Expected  string `json:"expected,omitempty"`
+	Actual    string `json:"actual,omitempty"`
+}
+
+/*
+ * Report holds the differences between the variables and a vault.
+ */
+type Report struct {
+	ResourceGroupName string       `json:"resourceGroupName"`
+	BackupVaultName   string       `json:"backupVaultName"`
+	Differences       []Difference `json:"differences"`
+}
+
+func (report *Report) add(difference Difference) {
+	report.Differences = append(report.Differences, difference)
+}
+
+/*
+ * Drifted reports whether the vault differs from the variables.
+ */
+func (report *Report) Drifted() bool {
+	return len(report.Differences) > 0
+}
+
+/*
+ * WriteText writes the differences as a unified diff style listing, with - for what's in the
+ * variables but not the vault and + for what's in the vault but not the variables.
+ */
+func (report *Report) WriteText(w io.Writer) error {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "--- variables\n+++ %s/%s\n", report.ResourceGroupName, report.BackupVaultName)
+
+	for _, difference := range report.Differences {
+		resource := fmt.Sprintf("%s %s", difference.Resource, difference.Name)
+		if difference.Backup != "" {
+			resource += fmt.Sprintf(" (%s)", difference.Backup)
+		}
+
+		switch difference.Kind {
+		case KindMissing:
+			fmt.Fprintf(&builder, "- %s\n", resource)
+		case KindExtra:
+			fmt.Fprintf(&builder, "+ %s\n", resource)
+		case KindChanged:
+			fmt.Fprintf(&builder, "~ %s\n", resource)
+			fmt.Fprintf(&builder, "    - %s = %s\n", difference.Attribute, difference.Expected)
+			fmt.Fprintf(&builder, "    + %s = %s\n", difference.Attribute, difference.Actual)
+		}
+	}
+
+	if report.Drifted() {
+		fmt.Fprintf(&builder, "\n%d differences between the variables and the vault\n", len(report.Differences))
+	} else {
+		builder.WriteString("\nThe vault matches the variables\n")
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+/*
+ * WriteJSON writes the report as an indented JSON document.
+ */
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
@@ -0,0 +1,47 @@
+package drift
+
+import (
+	"bytes"
+	"testing"
+
+	"e2e_tests/naming"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() *Report {
+	return &Report{
+		ResourceGroupName: "rg-drift",
+		BackupVaultName:   "bvault-drift",
+		Differences: []Difference{
+			{Kind: KindMissing, Resource: naming.ResourceBackupInstance, Name: "bkinst-blob-blob1", Backup: `blob_storage_backups["backup1"]`},
+			{Kind: KindChanged, Resource: naming.ResourceBackupPolicy, Name: "bkpol-disk-disk1", Backup: `managed_disk_backups["backup1"]`,
+				Attribute: AttributeRetentionPeriod, Expected: "P7D", Actual: "P30D"},
+			{Kind: KindExtra, Resource: naming.ResourceBackupPolicy, Name: "bkpol-manual"},
+		},
+	}
+}
+
+/*
+ * TestWriteText tests that differences are written as a diff of the variables against the vault.
+ */
+func TestWriteText(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, testReport().WriteText(&output))
+
+	assert.Equal(t, `--- variables
++++ rg-drift/bvault-drift
+- backup instance bkinst-blob-blob1 (blob_storage_backups["backup1"])
+~ backup policy bkpol-disk-disk1 (managed_disk_backups["backup1"])
+    - retention_period = P7D
+    + retention_period = P30D
++ backup policy bkpol-manual
+
+3 differences between the variables and the vault
+`, output.String())
+
+	output.Reset()
+	require.NoError(t, (&Report{ResourceGroupName: "rg-drift", BackupVaultName: "bvault-drift"}).WriteText(&output))
+	assert.Contains(t, output.String(), "The vault matches the variables")
+}
@@ -0,0 +1,111 @@
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+/*
+ * Variables are the module input variables that describe what should be in the vault.
+ */
+type Variables struct {
+	ResourceGroupName               string            `json:"resource_group_name"`
+	BackupVaultName                 string            `json:"backup_vault_name"`
+	BlobStorageBackups              map[string]Backup `json:"blob_storage_backups"`
+	DataLakeStorageBackups          map[string]Backup `json:"data_lake_storage_backups"`
+	ManagedDiskBackups              map[string]Backup `json:"managed_disk_backups"`
+	PostgresqlFlexibleServerBackups map[string]Backup `json:"postgresql_flexible_server_backups"`
+	MysqlFlexibleServerBackups      map[string]Backup `json:"mysql_flexible_server_backups"`
+	AksClusterBackups               map[string]Backup `json:"aks_cluster_backups"`
+}
+
+/*
+ * Backup is an entry in one of the backup variables. Only the datasource attribute for the
+ * variable it's in is set.
+ */
+type Backup struct {
+	BackupName                   string   `json:"backup_name"`
+	RetentionPeriod              string   `json:"retention_period"`
+	BackupIntervals              []string `json:"backup_intervals"`
+	BackupPolicyNamingTemplate   string   `json:"backup_policy_naming_template"`
+	BackupInstanceNamingTemplate string   `json:"backup_instance_naming_template"`
+	StorageAccountID             string   `json:"storage_account_id"`
+	ManagedDiskID                string   `json:"managed_disk_id"`
+	ServerID                     string   `json:"server_id"`
+	ClusterID                    string   `json:"cluster_id"`
+}
+
+/*
+ * LoadVariables reads the module input variables from a .tfvars or .tfvars.json file.
+ */
+func LoadVariables(path string) (*Variables, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read variables file: %w", err)
+	}
+
+	return ReadVariables(path, src)
+}
+
+/*
+ * ReadVariables reads the module input variables from the content of a variables file, which
+ * is parsed as JSON if its name ends with .json and as HCL otherwise.
+ */
+func ReadVariables(filename string, src []byte) (*Variables, error) {
+	data := src
+	if !strings.HasSuffix(filename, ".json") {
+		var err error
+		if data, err = hclToJSON(filename, src); err != nil {
+			return nil, err
+		}
+	}
+
+	variables := &Variables{}
+	if err := json.Unmarshal(data, variables); err != nil {
+		return nil, fmt.Errorf("failed to decode variables file: %w", err)
+	}
+
+	if variables.ResourceGroupName == "" || variables.BackupVaultName == "" {
+		return nil, fmt.Errorf("resource_group_name and backup_vault_name must be set")
+	}
+
+	return variables, nil
+}
+
+/*
+ * Converts the attributes of a .tfvars file to a JSON object, as they'd be written in a
+ * .tfvars.json file.
+ */
+func hclToJSON(filename string, src []byte) ([]byte, error) {
+	file, diagnostics := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diagnostics.HasErrors() {
+		return nil, fmt.Errorf("failed to parse variables file: %w", diagnostics)
+	}
+
+	attributes, diagnostics := file.Body.JustAttributes()
+	if diagnostics.HasErrors() {
+		return nil, fmt.Errorf("failed to parse variables file: %w", diagnostics)
+	}
+
+	values := map[string]json.RawMessage{}
+	for name, attribute := range attributes {
+		value, diagnostics := attribute.Expr.Value(nil)
+		if diagnostics.HasErrors() {
+			return nil, fmt.Errorf("failed to read variable %s: %w", name, diagnostics)
+		}
+
+		data, err := ctyjson.SimpleJSONValue{Value: value}.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert variable %s: %w", name, err)
+		}
+		values[name] = data
+	}
+
+	return json.Marshal(values)
+}
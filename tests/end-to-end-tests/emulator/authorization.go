@@ -0,0 +1,186 @@
+package emulator
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+/*
+ * The built-in role definitions which the az-backup module assigns to the backup vault identity,
+ * and to the identities that AKS cluster backups rely on.
+ */
+var builtInRoleDefinitions = map[string]string{
+	"Contributor":                      "b24988ac-6180-42a0-ab88-20f7382dd24c",
+	"Disk Backup Reader":               "3e5e47e6-65f7-47ef-90b5-e5dd4d455f24",
+	"Disk Restore Operator":            "b50d9833-a0cb-478e-945f-707fcc997c13",
+	"Disk Snapshot Contributor":        "7efff54f-a5b4-42b5-a1c5-5411624893ce",
+	"MySQL Backup And Export Operator": "d18ad5f3-1baf-4119-b49b-d944edb1f9d0",
+	"PostgreSQL Flexible Server Long Term Retention Backup Role": "c088a766-074b-43ba-90d4-1fb21feae531",
+	"Reader":                             "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+	"Storage Account Backup Contributor": "e5e2a7ff-d759-4cd2-bb51-3152d37e2eb1",
+	"Storage Blob Data Contributor":      "ba92f5b4-2d11-453d-a403-e96b0029c9fe",
+}
+
+var (
+	roleNameFilterPattern    = regexp.MustCompile(`roleName eq '([^']*)'`)
+	principalIdFilterPattern = regexp.MustCompile(`principalId eq '([^']*)'`)
+)
+
+/*
+ * Gets the ID of a built-in role definition for the provided role name, scoped to the
+ * subscription in the same way ARM reports it on a role assignment.
+ */
+func roleDefinitionID(subscriptionID string, roleName string) (string, error) {
+	id, ok := builtInRoleDefinitions[roleName]
+	if !ok {
+		return "", fmt.Errorf("role definition %q is not known to the emulator", roleName)
+	}
+
+	return fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s", subscriptionID, id), nil
+}
+
+func (e *Emulator) listRoleDefinitions(w http.ResponseWriter, r *http.Request) {
+	roleName := ""
+	if match := roleNameFilterPattern.FindStringSubmatch(r.URL.Query().Get("$filter")); match != nil {
+		roleName = match[1]
+	}
+
+	definitions := []map[string]any{}
+	for _, name := range sortedKeys(builtInRoleDefinitions) {
+		if roleName != "" && name != roleName {
+			continue
+		}
+
+		id := builtInRoleDefinitions[name]
+		definitions = append(definitions, map[string]any{
+			"id":   "/providers/Microsoft.Authorization/roleDefinitions/" + id,
+			"name": id,
+			"type": "Microsoft.Authorization/roleDefinitions",
+			"properties": map[string]any{
+				"roleName": name,
+				"type":     "BuiltInRole",
+			},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"value": definitions})
+}
+
+/*
+ * Lists the role assignments which apply at, above or below the provided scope, optionally
+ * filtered to a single principal.
+ */
+func (e *Emulator) listRoleAssignments(w http.ResponseWriter, r *http.Request, scope string) {
+	principalID := ""
+	if match := principalIdFilterPattern.FindStringSubmatch(r.URL.Query().Get("$filter")); match != nil {
+		principalID = match[1]
+	}
+
+	scope = strings.ToLower(cleanPath(scope))
+
+	assignments := []map[string]any{}
+	for _, assignment := range e.resourcesOfType(roleAssignmentType) {
+		properties := assignment["properties"].(map[string]any)
+
+		if principalID != "" && !strings.EqualFold(properties["principalId"].(string), principalID) {
+			continue
+		}
+
+		assignmentScope := strings.ToLower(cleanPath(properties["scope"].(string)))
+		if scope != "/" && assignmentScope != scope && !strings.HasPrefix(scope, assignmentScope+"/") && !strings.HasPrefix(assignmentScope, scope+"/") {
+			continue
+		}
+
+		assignments = append(assignments, assignment)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"value": assignments})
+}
+
+/*
+ * Assigns a built-in role to a principal at the provided scope.
+ */
+func (e *Emulator) assignRole(subscriptionID string, scope string, roleName string, principalID string) error {
+	definitionID, err := roleDefinitionID(subscriptionID, roleName)
+	if err != nil {
+		return err
+	}
+
+	e.Put(fmt.Sprintf("%s/providers/Microsoft.Authorization/roleAssignments/%s", scope, newUUID()), map[string]any{
+		"properties": map[string]any{
+			"principalId":      principalID,
+			"principalType":    "ServicePrincipal",
+			"roleDefinitionId": definitionID,
+			"scope":            scope,
+		},
+	})
+
+	return nil
+}
+
+/*
+ * Removes the assignments of a built-in role to a principal at exactly the provided scope.
+ */
+func (e *Emulator) unassignRole(scope string, roleName string, principalID string) {
+	id := builtInRoleDefinitions[roleName]
+	scope = strings.ToLower(cleanPath(scope))
+
+	for _, assignment := range e.resourcesOfType(roleAssignmentType) {
+		properties := assignment["properties"].(map[string]any)
+
+		if strings.EqualFold(properties["principalId"].(string), principalID) &&
+			strings.HasSuffix(strings.ToLower(properties["roleDefinitionId"].(string)), id) &&
+			strings.ToLower(cleanPath(properties["scope"].(string))) == scope {
+			e.Delete(assignment["id"].(string))
+		}
+	}
+}
+
+/*
+ * Creates a role assignment. Role assignments are extension resources, so the scope is
+ * taken from the path rather than the request body.
+ */
+func (e *Emulator) createRoleAssignment(w http.ResponseWriter, r *http.Request, path string) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	properties, _ := body["properties"].(map[string]any)
+	if properties == nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", "The role assignment properties must be provided.")
+		return
+	}
+
+	properties["scope"] = path[:strings.LastIndex(strings.ToLower(path), "/providers/microsoft.authorization/roleassignments/")]
+
+	writeJSON(w, http.StatusCreated, e.Put(path, body))
+}
+
+/*
+ * Reports whether a principal has been assigned a built-in role at, or above, the provided scope.
+ */
+func (e *Emulator) hasRoleAssignment(scope string, roleName string, principalID string) bool {
+	id, ok := builtInRoleDefinitions[roleName]
+	if !ok {
+		return false
+	}
+
+	scope = strings.ToLower(cleanPath(scope))
+
+	for _, assignment := range e.resourcesOfType(roleAssignmentType) {
+		properties := assignment["properties"].(map[string]any)
+		assignmentScope := strings.ToLower(cleanPath(properties["scope"].(string)))
+
+		if strings.EqualFold(properties["principalId"].(string), principalID) &&
+			strings.HasSuffix(strings.ToLower(properties["roleDefinitionId"].(string)), id) &&
+			(assignmentScope == scope || strings.HasPrefix(scope, assignmentScope+"/")) {
+			return true
+		}
+	}
+
+	return false
+}
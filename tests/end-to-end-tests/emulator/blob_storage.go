@@ -0,0 +1,239 @@
+package emulator
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*
+ * Serves the blob storage data plane for the provided storage account. Single shot uploads,
+ * staged block uploads, page writes, (ranged) downloads, deletes and flat blob listings are
+ * supported.
+ */
+func (e *Emulator) serveBlobStorage(w http.ResponseWriter, r *http.Request, storageAccountName string) {
+	blobPath := strings.Trim(r.URL.Path, "/")
+	key := storageAccountName + "/" + blobPath
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeBlobError(w, http.StatusBadRequest, "InvalidInput")
+			return
+		}
+
+		e.mu.Lock()
+		e.blocks[key+"#"+query.Get("blockid")] = data
+		e.mu.Unlock()
+
+		writeBlobResponse(w, http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		var blockList struct {
+			Latest      []string `xml:"Latest"`
+			Committed   []string `xml:"Committed"`
+			Uncommitted []string `xml:"Uncommitted"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&blockList); err != nil {
+			writeBlobError(w, http.StatusBadRequest, "InvalidXmlDocument")
+			return
+		}
+
+		e.mu.Lock()
+		var data []byte
+		for _, blockID := range append(append(blockList.Committed, blockList.Uncommitted...), blockList.Latest...) {
+			data = append(data, e.blocks[key+"#"+blockID]...)
+		}
+		e.blobs[key] = data
+		e.mu.Unlock()
+
+		writeBlobResponse(w, http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "page":
+		start, end, ok := parseRange(r)
+		if !ok || r.Header.Get("x-ms-page-write") != "update" {
+			writeBlobError(w, http.StatusBadRequest, "InvalidHeaderValue")
+			return
+		}
+
+		data, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != end-start+1 {
+			writeBlobError(w, http.StatusBadRequest, "InvalidInput")
+			return
+		}
+
+		e.mu.Lock()
+		blob := e.blobs[key]
+		if int64(len(blob)) < end+1 {
+			blob = append(blob, make([]byte, end+1-int64(len(blob)))...)
+		}
+		copy(blob[start:], data)
+		e.blobs[key] = blob
+		e.mu.Unlock()
+
+		writeBlobResponse(w, http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "":
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeBlobError(w, http.StatusBadRequest, "InvalidInput")
+			return
+		}
+
+		e.mu.Lock()
+		e.blobs[key] = data
+		e.mu.Unlock()
+
+		writeBlobResponse(w, http.StatusCreated)
+	case r.Method == http.MethodGet && query.Get("restype") == "container" && query.Get("comp") == "list":
+		e.listBlobs(w, storageAccountName, blobPath, query.Get("prefix"))
+	case r.Method == http.MethodGet && query.Get("comp") == "":
+		e.mu.Lock()
+		data, ok := e.blobs[key]
+		data = slices.Clone(data)
+		e.mu.Unlock()
+
+		if !ok {
+			writeBlobError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+
+		status := http.StatusOK
+		if start, end, ok := parseRange(r); ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, max(int64(len(data)), end+1)))
+
+			// Pages which haven't been written read back as zeros
+			if end+1 > int64(len(data)) {
+				data = append(data, make([]byte, end+1-int64(len(data)))...)
+			}
+
+			data = data[start : end+1]
+			status = http.StatusPartialContent
+		}
+
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		writeBlobResponse(w, status)
+		_, _ = w.Write(data)
+	case r.Method == http.MethodDelete && query.Get("comp") == "":
+		e.mu.Lock()
+		_, ok := e.blobs[key]
+		delete(e.blobs, key)
+		e.mu.Unlock()
+
+		if !ok {
+			writeBlobError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+
+		writeBlobResponse(w, http.StatusAccepted)
+	default:
+		writeBlobError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+/*
+ * Lists the blobs in a container whose names start with the provided prefix, in a single page.
+ */
+func (e *Emulator) listBlobs(w http.ResponseWriter, storageAccountName string, containerName string, prefix string) {
+	type blobProperties struct {
+		LastModified  string `xml:"Last-Modified"`
+		Etag          string `xml:"Etag"`
+		ContentLength int    `xml:"Content-Length"`
+		BlobType      string `xml:"BlobType"`
+	}
+
+	type blobItem struct {
+		Name       string         `xml:"Name"`
+		Properties blobProperties `xml:"Properties"`
+	}
+
+	containerPrefix := storageAccountName + "/" + containerName + "/"
+
+	e.mu.Lock()
+	var blobs []blobItem
+	for key, data := range e.blobs {
+		if name, ok := strings.CutPrefix(key, containerPrefix); ok && strings.HasPrefix(name, prefix) {
+			blobs = append(blobs, blobItem{
+				Name: name,
+				Properties: blobProperties{
+					LastModified:  time.Now().UTC().Format(http.TimeFormat),
+					Etag:          fmt.Sprintf("0x%X", len(data)),
+					ContentLength: len(data),
+					BlobType:      "BlockBlob",
+				},
+			})
+		}
+	}
+	e.mu.Unlock()
+
+	slices.SortFunc(blobs, func(a, b blobItem) int { return strings.Compare(a.Name, b.Name) })
+
+	result := struct {
+		XMLName         xml.Name   `xml:"EnumerationResults"`
+		ServiceEndpoint string     `xml:"ServiceEndpoint,attr"`
+		ContainerName   string     `xml:"ContainerName,attr"`
+		Prefix          string     `xml:"Prefix"`
+		Blobs           []blobItem `xml:"Blobs>Blob"`
+		NextMarker      string     `xml:"NextMarker"`
+	}{
+		ServiceEndpoint: fmt.Sprintf("https://%s%s/", storageAccountName, blobStorageHost),
+		ContainerName:   containerName,
+		Prefix:          prefix,
+		Blobs:           blobs,
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	writeBlobResponse(w, http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+/*
+ * Gets the content of a blob held by the emulator, and whether it exists.
+ */
+func (e *Emulator) Blob(storageAccountName string, containerName string, blobName string) ([]byte, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	data, ok := e.blobs[storageAccountName+"/"+containerName+"/"+blobName]
+
+	return data, ok
+}
+
+/*
+ * Parses the inclusive byte range of a request from the x-ms-range (or Range) header.
+ */
+func parseRange(r *http.Request) (int64, int64, bool) {
+	header := r.Header.Get("x-ms-range")
+	if header == "" {
+		header = r.Header.Get("Range")
+	}
+
+	var start, end int64
+	if _, err := fmt.Sscanf(header, "bytes=%d-%d", &start, &end); err != nil || start > end {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
+
+func writeBlobResponse(w http.ResponseWriter, status int) {
+	w.Header().Set("ETag", fmt.Sprintf("\"0x%X\"", time.Now().UnixNano()))
+	w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	w.Header().Set("x-ms-request-id", newUUID())
+	w.Header().Set("x-ms-request-server-encrypted", "true")
+	w.Header().Set("x-ms-version", "2025-01-05")
+	w.WriteHeader(status)
+}
+
+func writeBlobError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
@@ -0,0 +1,118 @@
+package emulator
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const diskStorageHost = ".blob.storage.azure.net"
+
+/*
+ * Creates or updates a managed disk. As with Azure, disks which are created for upload take
+ * their size from the upload size (less the VHD footer), and every disk is given a unique ID
+ * which the emulator uses to address the disk's data.
+ */
+func (e *Emulator) putDisk(w http.ResponseWriter, r *http.Request, path string) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	if e.Get(resourceGroupID(path)) == nil {
+		writeError(w, http.StatusNotFound, "ResourceGroupNotFound", fmt.Sprintf("Resource group for '%s' could not be found.", path))
+		return
+	}
+
+	properties, _ := body["properties"].(map[string]any)
+	if properties == nil {
+		properties = map[string]any{}
+		body["properties"] = properties
+	}
+
+	if existing := e.Get(path); existing != nil {
+		properties["uniqueId"] = nestedString(existing, "properties", "uniqueId")
+		properties["diskState"] = nestedString(existing, "properties", "diskState")
+	} else {
+		properties["uniqueId"] = newUUID()
+		properties["diskState"] = "Unattached"
+
+		if nestedString(body, "properties", "creationData", "createOption") == "Upload" {
+			uploadSizeBytes, _ := nestedValue(body, "properties", "creationData", "uploadSizeBytes").(float64)
+			properties["diskSizeGB"] = int((uploadSizeBytes - 512) / (1 << 30))
+			properties["diskState"] = "ReadyToUpload"
+		}
+	}
+
+	writeJSON(w, http.StatusOK, e.Put(path, body))
+}
+
+/*
+ * Grants SAS access to a managed disk's data. Write access is only granted to disks which
+ * are ready to be uploaded.
+ */
+func (e *Emulator) grantDiskAccess(w http.ResponseWriter, r *http.Request, diskID string) {
+	disk := e.Get(diskID)
+	if disk == nil {
+		writeNotFound(w, diskID)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	state := "ActiveSAS"
+	if body["access"] == "Write" {
+		if nestedString(disk, "properties", "diskState") != "ReadyToUpload" {
+			writeError(w, http.StatusConflict, "OperationNotAllowed", fmt.Sprintf("Write access can only be granted to disk '%s' while it is ready to upload.", diskID))
+			return
+		}
+		state = "ActiveUpload"
+	}
+
+	disk["properties"].(map[string]any)["diskState"] = state
+	e.Put(diskID, disk)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"accessSAS": "https://" + diskDataURL(nestedString(disk, "properties", "uniqueId")) + "?sv=2018-03-28&sr=b&si=emulator&sig=emulator",
+	})
+}
+
+/*
+ * Revokes SAS access to a managed disk's data. An uploaded disk becomes unattached, and
+ * so usable, once its access is revoked.
+ */
+func (e *Emulator) revokeDiskAccess(w http.ResponseWriter, diskID string) {
+	disk := e.Get(diskID)
+	if disk == nil {
+		writeNotFound(w, diskID)
+		return
+	}
+
+	disk["properties"].(map[string]any)["diskState"] = "Unattached"
+	e.Put(diskID, disk)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+/*
+ * Gets the host and path of the page blob that holds a disk's data, which is also the key
+ * the data is held under in the emulator's blob store.
+ */
+func diskDataURL(uniqueID string) string {
+	id := strings.ReplaceAll(uniqueID, "-", "")
+
+	return fmt.Sprintf("md-%s.z1%s/%s/abcd", id, diskStorageHost, id)
+}
+
+func diskDataKey(uniqueID string) string {
+	return strings.Replace(diskDataURL(uniqueID), diskStorageHost, "", 1)
+}
+
+func isManagedDiskBackup(backupInstance map[string]any) bool {
+	return strings.EqualFold(nestedString(backupInstance, "properties", "dataSourceInfo", "datasourceType"), diskType)
+}
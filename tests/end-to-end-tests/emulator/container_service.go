@@ -0,0 +1,83 @@
+package emulator
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	managedClusterType = "Microsoft.ContainerService/managedClusters"
+
+	// The name that the aks_cluster module gives the backup extension
+	aksBackupExtensionName = "azure-aks-backup"
+)
+
+/*
+ * Creates or updates an AKS cluster. As with Azure, a cluster with a system assigned identity
+ * is given a principal, which is kept when the cluster is updated.
+ */
+func (e *Emulator) putManagedCluster(w http.ResponseWriter, r *http.Request, path string) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	if e.Get(resourceGroupID(path)) == nil {
+		writeError(w, http.StatusNotFound, "ResourceGroupNotFound", fmt.Sprintf("Resource group for '%s' could not be found.", path))
+		return
+	}
+
+	if identity, ok := body["identity"].(map[string]any); ok && strings.EqualFold(fmt.Sprint(identity["type"]), "SystemAssigned") {
+		principalID := newUUID()
+		if existing := e.Get(path); existing != nil && nestedString(existing, "identity", "principalId") != "" {
+			principalID = nestedString(existing, "identity", "principalId")
+		}
+
+		identity["principalId"] = principalID
+		identity["tenantId"] = TenantID
+	}
+
+	writeJSON(w, http.StatusOK, e.Put(path, body))
+}
+
+/*
+ * Installs the AKS backup extension on a cluster, as the aks_cluster module does, and returns
+ * the principal ID of the identity that AKS assigns to the extension.
+ */
+func (e *Emulator) putBackupExtension(clusterID string, name string, configurationSettings map[string]any) string {
+	extensionID := fmt.Sprintf("%s/providers/Microsoft.KubernetesConfiguration/extensions/%s", clusterID, name)
+
+	principalID := newUUID()
+	if existing := e.Get(extensionID); existing != nil {
+		principalID = nestedString(existing, "properties", "aksAssignedIdentity", "principalId")
+	}
+
+	e.Put(extensionID, map[string]any{
+		"properties": map[string]any{
+			"extensionType":         "Microsoft.DataProtection.Kubernetes",
+			"releaseTrain":          "stable",
+			"configurationSettings": configurationSettings,
+			"aksAssignedIdentity": map[string]any{
+				"principalId": principalID,
+				"tenantId":    TenantID,
+				"type":        "SystemAssigned",
+			},
+		},
+	})
+
+	return principalID
+}
+
+/*
+ * Gets the ID of the trusted access role binding which lets a backup vault operate on a cluster,
+ * named in the same way as local.trusted_access_role_binding_name in the aks_cluster module.
+ */
+func trustedAccessRoleBindingID(clusterID string, vaultID string) string {
+	hash := sha1.Sum([]byte(vaultID))
+
+	return fmt.Sprintf("%s/trustedAccessRoleBindings/bkp-%s", clusterID, hex.EncodeToString(hash[:])[:16])
+}
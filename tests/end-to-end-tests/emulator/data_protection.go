@@ -0,0 +1,522 @@
+package emulator
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*
+ * Triggers an ad-hoc backup of a backup instance. The emulator completes the backup job
+ * immediately, and records a recovery point against the backup instance.
+ */
+func (e *Emulator) adhocBackup(w http.ResponseWriter, backupInstanceID string) {
+	backupInstance := e.Get(backupInstanceID)
+	if backupInstance == nil {
+		writeNotFound(w, backupInstanceID)
+		return
+	}
+
+	vaultID := parentID(parentID(backupInstanceID))
+	now := time.Now().UTC()
+
+	job := e.recordJob(vaultID, backupInstance, "Backup", "Completed", now)
+
+	recoveryPointID := fmt.Sprintf("%s/recoveryPoints/%s", backupInstance["id"], newUUID())
+	e.snapshot(recoveryPointID, backupInstance)
+
+	e.Put(recoveryPointID, map[string]any{
+		"properties": map[string]any{
+			"objectType":         "AzureBackupDiscreteRecoveryPoint",
+			"recoveryPointTime":  now.Format(time.RFC3339),
+			"recoveryPointType":  "Full",
+			"friendlyName":       job["name"],
+			"policyName":         policyName(backupInstance),
+			"retentionTagName":   "Default",
+			"expiryTime":         now.Add(7 * 24 * time.Hour).Format(time.RFC3339),
+			"recoveryPointState": "Completed",
+		},
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"objectType": "OperationJobExtendedInfo",
+		"jobId":      job["id"],
+	})
+}
+
+/*
+ * Validates a restore request for a backup instance, without triggering the restore.
+ */
+func (e *Emulator) validateRestore(w http.ResponseWriter, r *http.Request, backupInstanceID string) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	restoreRequest, _ := body["restoreRequestObject"].(map[string]any)
+	if _, ok := e.checkRestoreRequest(w, backupInstanceID, restoreRequest); !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"objectType": "OperationJobExtendedInfo"})
+}
+
+/*
+ * Triggers a restore from a recovery point. The emulator completes the restore job immediately.
+ */
+func (e *Emulator) restore(w http.ResponseWriter, r *http.Request, backupInstanceID string) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	backupInstance, ok := e.checkRestoreRequest(w, backupInstanceID, body)
+	if !ok {
+		return
+	}
+
+	recoveryPointID := fmt.Sprintf("%s/recoveryPoints/%s", backupInstance["id"], body["recoveryPointId"])
+	targetResourceID := restoreTargetID(body)
+
+	switch {
+	case isPostgresqlFlexibleServerBackup(backupInstance):
+		e.restoreFiles(recoveryPointID, body)
+	case isBlobStorageBackup(backupInstance):
+		e.restoreBlobs(recoveryPointID, targetResourceID, body)
+	case isManagedDiskBackup(backupInstance):
+		if err := e.restoreDisk(backupInstance, recoveryPointID, targetResourceID); err != nil {
+			writeError(w, http.StatusBadRequest, "UserErrorInvalidRestoreTarget", err.Error())
+			return
+		}
+	}
+
+	job := e.recordJob(parentID(parentID(backupInstanceID)), backupInstance, "Restore", "Completed", time.Now().UTC())
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"objectType": "OperationJobExtendedInfo",
+		"jobId":      job["id"],
+	})
+}
+
+/*
+ * Checks a restore request against the backup instance, writing an error response and
+ * returning false if it isn't valid. As with a real vault, the vault identity must hold a
+ * role on the restore target - Storage Account Backup Contributor to restore blobs, Disk
+ * Restore Operator to restore a managed disk, and Storage Blob Data Contributor to restore a
+ * postgresql flexible server as files.
+ */
+func (e *Emulator) checkRestoreRequest(w http.ResponseWriter, backupInstanceID string, request map[string]any) (map[string]any, bool) {
+	backupInstance := e.Get(backupInstanceID)
+	if backupInstance == nil {
+		writeNotFound(w, backupInstanceID)
+		return nil, false
+	}
+
+	recoveryPointID, _ := request["recoveryPointId"].(string)
+	if e.Get(fmt.Sprintf("%s/recoveryPoints/%s", backupInstance["id"], recoveryPointID)) == nil {
+		writeError(w, http.StatusBadRequest, "UserErrorRecoveryPointNotFound", fmt.Sprintf("The recovery point '%s' could not be found.", recoveryPointID))
+		return nil, false
+	}
+
+	targetResourceID := restoreTargetID(request)
+	if targetResourceID == "" {
+		writeError(w, http.StatusBadRequest, "UserErrorInvalidRestoreTarget", "The restore target datasource or location must be provided.")
+		return nil, false
+	}
+
+	vault := e.Get(parentID(parentID(backupInstanceID)))
+	principalID := nestedString(vault, "identity", "principalId")
+
+	requiredRole := ""
+	switch {
+	case isBlobStorageBackup(backupInstance):
+		requiredRole = "Storage Account Backup Contributor"
+	case isManagedDiskBackup(backupInstance):
+		requiredRole = "Disk Restore Operator"
+	case isPostgresqlFlexibleServerBackup(backupInstance):
+		requiredRole = "Storage Blob Data Contributor"
+	}
+
+	if requiredRole != "" && !e.hasRoleAssignment(targetResourceID, requiredRole, principalID) {
+		writeError(w, http.StatusBadRequest, "UserErrorMissingRequiredPermissions",
+			fmt.Sprintf("The backup vault identity does not have the %s role on '%s'.", requiredRole, targetResourceID))
+		return nil, false
+	}
+
+	return backupInstance, true
+}
+
+/*
+ * Gets the ID of the resource a restore request targets - the datasource for a restore to a
+ * resource, or the container for a restore as files.
+ */
+func restoreTargetID(request map[string]any) string {
+	if nestedString(request, "restoreTargetInfo", "objectType") == "RestoreFilesTargetInfo" {
+		return nestedString(request, "restoreTargetInfo", "targetDetails", "targetResourceArmId")
+	}
+
+	return nestedString(request, "restoreTargetInfo", "datasourceInfo", "resourceID")
+}
+
+/*
+ * Captures the data protected by a backup instance against a recovery point, so it can later
+ * be restored - the blobs in the protected containers of a storage account, or the content
+ * of a managed disk.
+ */
+func (e *Emulator) snapshot(recoveryPointID string, backupInstance map[string]any) {
+	switch {
+	case isBlobStorageBackup(backupInstance), isDataLakeStorageBackup(backupInstance):
+		e.snapshotBlobs(recoveryPointID, backupInstance)
+	case isManagedDiskBackup(backupInstance):
+		e.snapshotDisk(recoveryPointID, backupInstance)
+	case isPostgresqlFlexibleServerBackup(backupInstance):
+		e.snapshotPostgresqlFlexibleServer(recoveryPointID, backupInstance)
+	}
+}
+
+func (e *Emulator) snapshotBlobs(recoveryPointID string, backupInstance map[string]any) {
+	storageAccountID := nestedString(backupInstance, "properties", "dataSourceInfo", "resourceID")
+	storageAccountName := storageAccountID[strings.LastIndex(storageAccountID, "/")+1:]
+
+	var containers []string
+	datasourceParameters, _ := nestedValue(backupInstance, "properties", "policyInfo", "policyParameters", "backupDatasourceParametersList").([]any)
+	for _, datasourceParameter := range datasourceParameters {
+		containersList, _ := datasourceParameter.(map[string]any)["containersList"].([]any)
+		for _, container := range containersList {
+			containers = append(containers, container.(string))
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	snapshot := map[string][]byte{}
+	for key, data := range e.blobs {
+		for _, container := range containers {
+			if strings.HasPrefix(key, storageAccountName+"/"+container+"/") {
+				snapshot[key[len(storageAccountName)+1:]] = append([]byte(nil), data...)
+			}
+		}
+	}
+
+	e.snapshots[strings.ToLower(recoveryPointID)] = snapshot
+}
+
+func (e *Emulator) snapshotDisk(recoveryPointID string, backupInstance map[string]any) {
+	disk := e.Get(nestedString(backupInstance, "properties", "dataSourceInfo", "resourceID"))
+	if disk == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.snapshots[strings.ToLower(recoveryPointID)] = map[string][]byte{
+		"disk": slices.Clone(e.blobs[diskDataKey(nestedString(disk, "properties", "uniqueId"))]),
+	}
+}
+
+/*
+ * Captures a dump of each database on a postgresql flexible server. The emulator doesn't run
+ * postgres, so the dumps only carry the pg_dump custom archive signature and are not valid
+ * archives.
+ */
+func (e *Emulator) snapshotPostgresqlFlexibleServer(recoveryPointID string, backupInstance map[string]any) {
+	serverID := nestedString(backupInstance, "properties", "dataSourceInfo", "resourceID")
+
+	databases := []string{"postgres"}
+	for _, database := range e.List(serverID + "/databases") {
+		if name, _ := database["name"].(string); !slices.Contains(databases, name) {
+			databases = append(databases, name)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	snapshot := map[string][]byte{}
+	for _, database := range databases {
+		snapshot[database] = []byte(fmt.Sprintf("PGDMP emulated dump of database %s on %s\n", database, serverID))
+	}
+
+	e.snapshots[strings.ToLower(recoveryPointID)] = snapshot
+}
+
+/*
+ * Restores the blobs captured by a recovery point to the target storage account, either in
+ * full or for the containers (and blob prefixes) selected by the restore criteria.
+ */
+func (e *Emulator) restoreBlobs(recoveryPointID string, targetStorageAccountID string, request map[string]any) {
+	targetStorageAccountName := targetStorageAccountID[strings.LastIndex(targetStorageAccountID, "/")+1:]
+
+	var criteria []map[string]any
+	if restoreCriteria, ok := nestedValue(request, "restoreTargetInfo", "restoreCriteria").([]any); ok {
+		for _, criterion := range restoreCriteria {
+			criteria = append(criteria, criterion.(map[string]any))
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, data := range e.snapshots[strings.ToLower(recoveryPointID)] {
+		if len(criteria) > 0 && !matchesRestoreCriteria(key, criteria) {
+			continue
+		}
+
+		e.blobs[targetStorageAccountName+"/"+key] = slices.Clone(data)
+	}
+}
+
+/*
+ * Restores the database dumps captured by a recovery point as files to the target container,
+ * named with the requested prefix.
+ */
+func (e *Emulator) restoreFiles(recoveryPointID string, request map[string]any) {
+	targetURL, err := url.Parse(nestedString(request, "restoreTargetInfo", "targetDetails", "url"))
+	if err != nil {
+		return
+	}
+
+	storageAccountName := strings.TrimSuffix(strings.ToLower(targetURL.Host), blobStorageHost)
+	containerName := strings.Trim(targetURL.Path, "/")
+	filePrefix := nestedString(request, "restoreTargetInfo", "targetDetails", "filePrefix")
+	timestamp := time.Now().UTC().Format("20060102150405")
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for database, data := range e.snapshots[strings.ToLower(recoveryPointID)] {
+		e.blobs[fmt.Sprintf("%s/%s/%s_%s_%s.sql", storageAccountName, containerName, filePrefix, database, timestamp)] = slices.Clone(data)
+	}
+}
+
+/*
+ * Restores a managed disk from a recovery point as a new disk, with the same SKU and size
+ * as the source disk and the content captured by the recovery point.
+ */
+func (e *Emulator) restoreDisk(backupInstance map[string]any, recoveryPointID string, targetDiskID string) error {
+	sourceDisk := e.Get(nestedString(backupInstance, "properties", "dataSourceInfo", "resourceID"))
+	if sourceDisk == nil {
+		return fmt.Errorf("the source disk for '%s' could not be found", backupInstance["id"])
+	}
+
+	if e.Get(resourceGroupID(targetDiskID)) == nil {
+		return fmt.Errorf("the target resource group for '%s' could not be found", targetDiskID)
+	}
+
+	if e.Get(targetDiskID) != nil {
+		return fmt.Errorf("the target disk '%s' already exists", targetDiskID)
+	}
+
+	uniqueID := newUUID()
+
+	e.Put(targetDiskID, map[string]any{
+		"location": sourceDisk["location"],
+		"sku":      sourceDisk["sku"],
+		"properties": map[string]any{
+			"creationData": map[string]any{"createOption": "Restore", "sourceResourceId": recoveryPointID},
+			"diskSizeGB":   nestedValue(sourceDisk, "properties", "diskSizeGB"),
+			"diskState":    "Unattached",
+			"uniqueId":     uniqueID,
+		},
+	})
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.blobs[diskDataKey(uniqueID)] = slices.Clone(e.snapshots[strings.ToLower(recoveryPointID)]["disk"])
+
+	return nil
+}
+
+func isBlobStorageBackup(backupInstance map[string]any) bool {
+	return strings.EqualFold(nestedString(backupInstance, "properties", "dataSourceInfo", "datasourceType"), "Microsoft.Storage/storageAccounts/blobServices")
+}
+
+func isDataLakeStorageBackup(backupInstance map[string]any) bool {
+	return strings.EqualFold(nestedString(backupInstance, "properties", "dataSourceInfo", "datasourceType"), "Microsoft.Storage/storageAccounts/adlsBlobServices")
+}
+
+func isPostgresqlFlexibleServerBackup(backupInstance map[string]any) bool {
+	return strings.EqualFold(nestedString(backupInstance, "properties", "dataSourceInfo", "datasourceType"), "Microsoft.DBforPostgreSQL/flexibleServers")
+}
+
+/*
+ * Reports whether a blob (keyed by container/blob name) is selected by item level restore
+ * criteria, which select a container and optionally a set of blob name prefixes within it.
+ */
+func matchesRestoreCriteria(key string, criteria []map[string]any) bool {
+	container, blobName, _ := strings.Cut(key, "/")
+
+	for _, criterion := range criteria {
+		if itemPath, _ := criterion["itemPath"].(string); itemPath != container {
+			continue
+		}
+
+		prefixes, _ := criterion["subItemPathPrefix"].([]any)
+		if len(prefixes) == 0 {
+			return true
+		}
+
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(blobName, prefix.(string)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+ * Updates a backup vault. As with a real vault, locked immutability and always on soft delete
+ * are irreversible, so any attempt to change them is rejected.
+ */
+func (e *Emulator) patchBackupVault(w http.ResponseWriter, r *http.Request, backupVaultID string) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	vault := e.Get(backupVaultID)
+	if vault == nil {
+		writeNotFound(w, backupVaultID)
+		return
+	}
+
+	irreversible := []struct {
+		setting string
+		state   string
+	}{
+		{"immutabilitySettings", "Locked"},
+		{"softDeleteSettings", "AlwaysOn"},
+	}
+
+	for _, check := range irreversible {
+		current := nestedString(vault, "properties", "securitySettings", check.setting, "state")
+		requested := nestedString(body, "properties", "securitySettings", check.setting, "state")
+
+		if current == check.state && requested != "" && requested != current {
+			writeError(w, http.StatusBadRequest, "UserErrorInvalidSecuritySettingsUpdate",
+				fmt.Sprintf("The %s of backup vault '%s' is %s, which can't be changed.", check.setting, backupVaultID, current))
+			return
+		}
+	}
+
+	mergeMaps(vault, body)
+
+	writeJSON(w, http.StatusOK, e.Put(backupVaultID, vault))
+}
+
+/*
+ * Deletes a backup instance. As with a real vault, instances which hold recovery points
+ * can't be deleted while immutability is enabled (Unlocked or Locked).
+ */
+func (e *Emulator) deleteBackupInstance(w http.ResponseWriter, backupInstanceID string) {
+	if e.Get(backupInstanceID) == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	vault := e.Get(parentID(parentID(backupInstanceID)))
+	immutability := nestedString(vault, "properties", "securitySettings", "immutabilitySettings", "state")
+
+	if (immutability == "Unlocked" || immutability == "Locked") && len(e.List(backupInstanceID+"/recoveryPoints")) > 0 {
+		writeError(w, http.StatusConflict, "UserErrorImmutableVaultOperationNotAllowed",
+			fmt.Sprintf("The backup instance '%s' holds recovery points which can't be deleted while vault immutability is %s.", backupInstanceID, immutability))
+		return
+	}
+
+	e.Delete(backupInstanceID)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+/*
+ * Records a data protection job against a backup vault and returns it, logging it to the
+ * vault's diagnostic settings.
+ */
+func (e *Emulator) recordJob(vaultID string, backupInstance map[string]any, operation string, status string, startTime time.Time) map[string]any {
+	properties := backupInstance["properties"].(map[string]any)
+	dataSourceInfo, _ := properties["dataSourceInfo"].(map[string]any)
+	vaultSegments := strings.Split(vaultID, "/")
+
+	job := e.Put(fmt.Sprintf("%s/backupJobs/%s", vaultID, newUUID()), map[string]any{
+		"properties": map[string]any{
+			"activityID":                 newUUID(),
+			"backupInstanceFriendlyName": backupInstance["name"],
+			"backupInstanceId":           backupInstance["id"],
+			"dataSourceId":               dataSourceInfo["resourceID"],
+			"dataSourceLocation":         dataSourceInfo["resourceLocation"],
+			"dataSourceName":             dataSourceInfo["resourceName"],
+			"dataSourceType":             dataSourceInfo["datasourceType"],
+			"isUserTriggered":            true,
+			"operation":                  operation,
+			"operationCategory":          operation,
+			"policyId":                   nestedString(backupInstance, "properties", "policyInfo", "policyId"),
+			"policyName":                 policyName(backupInstance),
+			"progressEnabled":            false,
+			"sourceResourceGroup":        vaultSegments[4],
+			"sourceSubscriptionID":       vaultSegments[2],
+			"startTime":                  startTime.Format(time.RFC3339),
+			"endTime":                    startTime.Format(time.RFC3339),
+			"duration":                   "PT0S",
+			"status":                     status,
+			"subscriptionId":             vaultSegments[2],
+			"supportedActions":           []string{""},
+			"vaultName":                  vaultSegments[len(vaultSegments)-1],
+		},
+	})
+
+	e.emitJobLog(vaultID, job, backupInstance)
+
+	return job
+}
+
+func policyName(backupInstance map[string]any) string {
+	policyID := nestedString(backupInstance, "properties", "policyInfo", "policyId")
+
+	return policyID[strings.LastIndex(policyID, "/")+1:]
+}
+
+/*
+ * Gets the ID of the resource (or collection) one level up from the provided ID.
+ */
+func parentID(id string) string {
+	return id[:strings.LastIndex(id, "/")]
+}
+
+/*
+ * Reads a string from a nested decoded JSON object, returning an empty string if any part
+ * of the path is missing.
+ */
+func nestedString(value map[string]any, path ...string) string {
+	result, _ := nestedValue(value, path...).(string)
+
+	return result
+}
+
+/*
+ * Reads a value from a nested decoded JSON object, returning nil if any part of the path
+ * is missing.
+ */
+func nestedValue(value map[string]any, path ...string) any {
+	current := any(value)
+
+	for _, key := range path {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = object[key]
+	}
+
+	return current
+}
@@ -0,0 +1,162 @@
+package emulator
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+const (
+	// The fake tenant, subscription and client used when running against the emulator.
+	TenantID       = "00000000-0000-0000-0000-000000000001"
+	SubscriptionID = "00000000-0000-0000-0000-000000000002"
+	ClientID       = "00000000-0000-0000-0000-000000000003"
+	ClientSecret   = "emulator-client-secret"
+
+	identityHost    = "login.microsoftonline.com"
+	blobStorageHost = ".blob.core.windows.net"
+)
+
+/*
+ * Emulator is an in-process stand-in for the subset of Azure Resource Manager, Entra ID,
+ * blob storage and Log Analytics that the end to end tests depend on. Resources are held in
+ * memory and keyed by their (case-insensitive) resource ID.
+ */
+type Emulator struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	resources map[string]map[string]any
+	created   map[string]time.Time
+	blobs     map[string][]byte
+	blocks    map[string][]byte
+	snapshots map[string]map[string][]byte
+	logs      map[string][]map[string]any
+}
+
+/*
+ * Starts a new emulator listening on a local TLS endpoint.
+ */
+func New() *Emulator {
+	e := &Emulator{
+		resources: map[string]map[string]any{},
+		created:   map[string]time.Time{},
+		blobs:     map[string][]byte{},
+		blocks:    map[string][]byte{},
+		snapshots: map[string]map[string][]byte{},
+		logs:      map[string][]map[string]any{},
+	}
+
+	e.server = httptest.NewTLSServer(http.HandlerFunc(e.serveHTTP))
+
+	return e
+}
+
+/*
+ * Stops the emulator.
+ */
+func (e *Emulator) Close() {
+	e.server.Close()
+}
+
+/*
+ * Gets a transport which redirects every request to the emulator, regardless of the
+ * host it was addressed to.
+ */
+func (e *Emulator) Transport() policy.Transporter {
+	target, _ := url.Parse(e.server.URL)
+
+	return &transport{client: e.server.Client(), target: target}
+}
+
+/*
+ * Gets the client options that point an Azure Resource Manager client at the emulator.
+ */
+func (e *Emulator) ClientOptions() *arm.ClientOptions {
+	return &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: e.Transport(),
+		},
+	}
+}
+
+/*
+ * Gets a credential for the emulator's fake client, which obtains its tokens from the emulator.
+ */
+func (e *Emulator) Credential() (azcore.TokenCredential, error) {
+	return azidentity.NewClientSecretCredential(TenantID, ClientID, ClientSecret, e.CredentialOptions())
+}
+
+/*
+ * Gets the credential options that point a client secret credential at the emulator.
+ */
+func (e *Emulator) CredentialOptions() *azidentity.ClientSecretCredentialOptions {
+	return &azidentity.ClientSecretCredentialOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: e.Transport(),
+		},
+		DisableInstanceDiscovery: true,
+	}
+}
+
+type transport struct {
+	client *http.Client
+	target *url.URL
+}
+
+func (t *transport) Do(req *http.Request) (*http.Response, error) {
+	redirected := req.Clone(req.Context())
+	redirected.Host = req.URL.Host
+	redirected.URL.Scheme = t.target.Scheme
+	redirected.URL.Host = t.target.Host
+
+	resp, err := t.client.Do(redirected)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pollers build their follow up requests from the original request, so hide the redirect
+	resp.Request = req
+
+	return resp, nil
+}
+
+func (e *Emulator) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(r.Host)
+
+	switch {
+	case host == identityHost:
+		e.serveIdentity(w, r)
+	case host == logAnalyticsHost:
+		e.serveLogAnalytics(w, r)
+	case strings.HasSuffix(host, blobStorageHost):
+		e.serveBlobStorage(w, r, strings.TrimSuffix(host, blobStorageHost))
+	case strings.HasSuffix(host, diskStorageHost):
+		e.serveBlobStorage(w, r, strings.TrimSuffix(host, diskStorageHost))
+	default:
+		e.serveResourceManager(w, r)
+	}
+}
+
+/*
+ * Generates a random UUID (version 4).
+ */
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,496 @@
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/monitor/armmonitor"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	testResourceGroupName = "rg-emulator"
+	testBackupVaultName   = "bvault-emulator"
+	testStorageAccountID  = "/subscriptions/" + SubscriptionID + "/resourceGroups/rg-external/providers/Microsoft.Storage/storageAccounts/saexternal"
+)
+
+func newTestEmulator(t *testing.T) (*Emulator, *azidentity.ClientSecretCredential) {
+	e := New()
+	t.Cleanup(e.Close)
+
+	credential, err := azidentity.NewClientSecretCredential(TenantID, ClientID, ClientSecret, e.CredentialOptions())
+	require.NoError(t, err)
+
+	return e, credential
+}
+
+func applyTestModule(t *testing.T, e *Emulator, immutability string) {
+	err := e.Apply(SubscriptionID, map[string]interface{}{
+		"resource_group_name":        testResourceGroupName,
+		"backup_vault_name":          testBackupVaultName,
+		"backup_vault_immutability":  immutability,
+		"log_analytics_workspace_id": "/subscriptions/" + SubscriptionID + "/resourceGroups/rg-external/providers/Microsoft.OperationalInsights/workspaces/law",
+		"blob_storage_backups": map[string]map[string]interface{}{
+			"backup1": {
+				"backup_name":                "blob1",
+				"retention_period":           "P7D",
+				"backup_intervals":           []string{"R/2024-01-01T00:00:00+00:00/P1D"},
+				"storage_account_id":         testStorageAccountID,
+				"storage_account_containers": []string{"container1"},
+			},
+		},
+	})
+	require.NoError(t, err)
+}
+
+func TestApplyDeploysModuleResources(t *testing.T) {
+	e, credential := newTestEmulator(t)
+	applyTestModule(t, e, "Disabled")
+
+	vaultsClient, err := armdataprotection.NewBackupVaultsClient(SubscriptionID, credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	vault, err := vaultsClient.Get(context.Background(), testResourceGroupName, testBackupVaultName, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "SystemAssigned", *vault.Identity.Type)
+	assert.Equal(t, armdataprotection.StorageSettingTypesLocallyRedundant, *vault.Properties.StorageSettings[0].Type)
+
+	policiesClient, err := armdataprotection.NewBackupPoliciesClient(SubscriptionID, credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	page, err := policiesClient.NewListPager(testResourceGroupName, testBackupVaultName, nil).NextPage(context.Background())
+	require.NoError(t, err)
+	require.Len(t, page.Value, 1)
+	assert.Equal(t, "bkpol-blob-blob1", *page.Value[0].Name)
+
+	policy := page.Value[0].Properties.(*armdataprotection.BackupPolicy)
+	retentionRule := policy.PolicyRules[1].(*armdataprotection.AzureRetentionRule)
+	assert.Equal(t, "P7D", *retentionRule.Lifecycles[0].DeleteAfter.(*armdataprotection.AbsoluteDeleteOption).Duration)
+
+	diagnosticSettingsClient, err := armmonitor.NewDiagnosticSettingsClient(credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	settings, err := diagnosticSettingsClient.NewListPager(*vault.ID, nil).NextPage(context.Background())
+	require.NoError(t, err)
+	require.Len(t, settings.Value, 1)
+	assert.Len(t, settings.Value[0].Properties.Logs, 4)
+}
+
+func TestRoleAssignmentsAreListedForScope(t *testing.T) {
+	e, credential := newTestEmulator(t)
+	applyTestModule(t, e, "Disabled")
+
+	principalID := nestedString(e.Get(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataProtection/backupVaults/%s", SubscriptionID, testResourceGroupName, testBackupVaultName)), "identity", "principalId")
+
+	definitionsClient, err := armauthorization.NewRoleDefinitionsClient(credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	filter := "roleName eq 'Storage Account Backup Contributor'"
+	definitions, err := definitionsClient.NewListPager("", &armauthorization.RoleDefinitionsClientListOptions{Filter: &filter}).NextPage(context.Background())
+	require.NoError(t, err)
+	require.Len(t, definitions.Value, 1)
+
+	assignmentsClient, err := armauthorization.NewRoleAssignmentsClient(SubscriptionID, credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	filter = fmt.Sprintf("principalId eq '%s'", principalID)
+	assignments, err := assignmentsClient.NewListForScopePager(testStorageAccountID, &armauthorization.RoleAssignmentsClientListForScopeOptions{Filter: &filter}).NextPage(context.Background())
+	require.NoError(t, err)
+	require.Len(t, assignments.Value, 1)
+	assert.True(t, strings.Contains(*assignments.Value[0].Properties.RoleDefinitionID, *definitions.Value[0].ID))
+}
+
+func TestAksClusterBackupIsRemovedOnDestroy(t *testing.T) {
+	e, _ := newTestEmulator(t)
+
+	clusterID := "/subscriptions/" + SubscriptionID + "/resourceGroups/rg-external/providers/Microsoft.ContainerService/managedClusters/aks"
+	snapshotResourceGroupID := "/subscriptions/" + SubscriptionID + "/resourceGroups/rg-snapshots"
+
+	vars := map[string]interface{}{
+		"resource_group_name":        testResourceGroupName,
+		"backup_vault_name":          testBackupVaultName,
+		"log_analytics_workspace_id": "/subscriptions/" + SubscriptionID + "/resourceGroups/rg-external/providers/Microsoft.OperationalInsights/workspaces/law",
+		"aks_cluster_backups": map[string]map[string]interface{}{
+			"backup1": {
+				"backup_name":                   "aks1",
+				"retention_period":              "P7D",
+				"backup_intervals":              []string{"R/2024-01-01T00:00:00+00:00/PT4H"},
+				"cluster_id":                    clusterID,
+				"cluster_identity_principal_id": "cluster-principal",
+				"snapshot_resource_group":       map[string]interface{}{"id": snapshotResourceGroupID, "name": "rg-snapshots"},
+				"storage_account_id":            testStorageAccountID,
+				"storage_account_container":     "aks1",
+			},
+		},
+	}
+	require.NoError(t, e.Apply(SubscriptionID, vars))
+
+	extension := e.Get(clusterID + "/providers/Microsoft.KubernetesConfiguration/extensions/" + aksBackupExtensionName)
+	require.NotNil(t, extension)
+	extensionPrincipalID := nestedString(extension, "properties", "aksAssignedIdentity", "principalId")
+
+	assert.True(t, e.hasRoleAssignment(testStorageAccountID, "Storage Blob Data Contributor", extensionPrincipalID))
+	assert.True(t, e.hasRoleAssignment(snapshotResourceGroupID, "Contributor", "cluster-principal"))
+	assert.Len(t, e.List(clusterID+"/trustedAccessRoleBindings"), 1)
+
+	require.NoError(t, e.Destroy(SubscriptionID, vars))
+
+	assert.Nil(t, e.Get(clusterID+"/providers/Microsoft.KubernetesConfiguration/extensions/"+aksBackupExtensionName))
+	assert.False(t, e.hasRoleAssignment(testStorageAccountID, "Storage Blob Data Contributor", extensionPrincipalID))
+	assert.False(t, e.hasRoleAssignment(snapshotResourceGroupID, "Contributor", "cluster-principal"))
+	assert.Empty(t, e.List(clusterID+"/trustedAccessRoleBindings"))
+}
+
+func TestImmutableVaultBlocksBackupInstanceDeletion(t *testing.T) {
+	e, credential := newTestEmulator(t)
+	applyTestModule(t, e, "Unlocked")
+
+	instancesClient, err := armdataprotection.NewBackupInstancesClient(SubscriptionID, credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	poller, err := instancesClient.BeginAdhocBackup(context.Background(), testResourceGroupName, testBackupVaultName, "bkinst-blob-blob1", armdataprotection.TriggerBackupRequest{
+		BackupRuleOptions: &armdataprotection.AdHocBackupRuleOptions{
+			RuleName:      to.Ptr("BackupIntervals"),
+			TriggerOption: &armdataprotection.AdhocBackupTriggerOption{},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	resp, err := poller.PollUntilDone(context.Background(), nil)
+	require.NoError(t, err)
+
+	jobsClient, err := armdataprotection.NewJobsClient(SubscriptionID, credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	jobID := (*resp.JobID)[strings.LastIndex(*resp.JobID, "/")+1:]
+	job, err := jobsClient.Get(context.Background(), testResourceGroupName, testBackupVaultName, jobID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Completed", *job.Properties.Status)
+
+	_, err = instancesClient.BeginDelete(context.Background(), testResourceGroupName, testBackupVaultName, "bkinst-blob-blob1", nil)
+	assert.Error(t, err, "Expected deletion to be blocked while the vault is immutable")
+
+	vaultsClient, err := armdataprotection.NewBackupVaultsClient(SubscriptionID, credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	updatePoller, err := vaultsClient.BeginUpdate(context.Background(), testResourceGroupName, testBackupVaultName, armdataprotection.PatchResourceRequestInput{
+		Properties: &armdataprotection.PatchBackupVaultInput{
+			SecuritySettings: &armdataprotection.SecuritySettings{
+				ImmutabilitySettings: &armdataprotection.ImmutabilitySettings{State: to.Ptr(armdataprotection.ImmutabilityStateDisabled)},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	vault, err := updatePoller.PollUntilDone(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, armdataprotection.ImmutabilityStateDisabled, *vault.Properties.SecuritySettings.ImmutabilitySettings.State)
+	assert.Equal(t, armdataprotection.StorageSettingTypesLocallyRedundant, *vault.Properties.StorageSettings[0].Type, "Expected the update to merge with the existing vault")
+
+	deletePoller, err := instancesClient.BeginDelete(context.Background(), testResourceGroupName, testBackupVaultName, "bkinst-blob-blob1", nil)
+	require.NoError(t, err)
+
+	_, err = deletePoller.PollUntilDone(context.Background(), nil)
+	assert.NoError(t, err)
+}
+
+func TestLockedImmutabilityCantBeChanged(t *testing.T) {
+	e, credential := newTestEmulator(t)
+	applyTestModule(t, e, "Locked")
+
+	vaultsClient, err := armdataprotection.NewBackupVaultsClient(SubscriptionID, credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	_, err = vaultsClient.BeginUpdate(context.Background(), testResourceGroupName, testBackupVaultName, armdataprotection.PatchResourceRequestInput{
+		Properties: &armdataprotection.PatchBackupVaultInput{
+			SecuritySettings: &armdataprotection.SecuritySettings{
+				ImmutabilitySettings: &armdataprotection.ImmutabilitySettings{State: to.Ptr(armdataprotection.ImmutabilityStateDisabled)},
+			},
+		},
+	}, nil)
+	assert.ErrorContains(t, err, "UserErrorInvalidSecuritySettingsUpdate")
+
+	vault, err := vaultsClient.Get(context.Background(), testResourceGroupName, testBackupVaultName, nil)
+	require.NoError(t, err)
+	assert.Equal(t, armdataprotection.ImmutabilityStateLocked, *vault.Properties.SecuritySettings.ImmutabilitySettings.State)
+}
+
+func TestResourceGroupDeletionRemovesNestedResources(t *testing.T) {
+	e, credential := newTestEmulator(t)
+	applyTestModule(t, e, "Disabled")
+
+	client, err := armresources.NewResourceGroupsClient(SubscriptionID, credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	poller, err := client.BeginDelete(context.Background(), testResourceGroupName, nil)
+	require.NoError(t, err)
+
+	_, err = poller.PollUntilDone(context.Background(), nil)
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background(), testResourceGroupName, nil)
+	assert.Error(t, err)
+	assert.Empty(t, e.resourcesOfType(backupVaultType))
+}
+
+func TestBlobStorageRoundTrip(t *testing.T) {
+	e, credential := newTestEmulator(t)
+
+	client, err := azblob.NewClient("https://saexternal.blob.core.windows.net/", credential, &azblob.ClientOptions{ClientOptions: e.ClientOptions().ClientOptions})
+	require.NoError(t, err)
+
+	content := []byte("This is a test file for upload.")
+	_, err = client.UploadBuffer(context.Background(), "container1", "test.txt", content, nil)
+	require.NoError(t, err)
+
+	stored, ok := e.Blob("saexternal", "container1", "test.txt")
+	require.True(t, ok)
+	assert.Equal(t, content, stored)
+
+	resp, err := client.DownloadStream(context.Background(), "container1", "test.txt", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	downloaded, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func TestBlobStorageRestoreRequiresRoleOnTarget(t *testing.T) {
+	e, credential := newTestEmulator(t)
+	applyTestModule(t, e, "Disabled")
+
+	e.blobs["saexternal/container1/test.txt"] = []byte("backed up")
+	e.blobs["saexternal/container2/other.txt"] = []byte("not backed up")
+
+	instancesClient, err := armdataprotection.NewBackupInstancesClient(SubscriptionID, credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	poller, err := instancesClient.BeginAdhocBackup(context.Background(), testResourceGroupName, testBackupVaultName, "bkinst-blob-blob1", armdataprotection.TriggerBackupRequest{
+		BackupRuleOptions: &armdataprotection.AdHocBackupRuleOptions{
+			RuleName:      to.Ptr("BackupIntervals"),
+			TriggerOption: &armdataprotection.AdhocBackupTriggerOption{},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = poller.PollUntilDone(context.Background(), nil)
+	require.NoError(t, err)
+
+	// The blob is changed after the backup, so the restore must come from the recovery point
+	e.blobs["saexternal/container1/test.txt"] = []byte("changed")
+
+	recoveryPoints := e.List(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataProtection/backupVaults/%s/backupInstances/bkinst-blob-blob1/recoveryPoints", SubscriptionID, testResourceGroupName, testBackupVaultName))
+	require.Len(t, recoveryPoints, 1)
+
+	targetStorageAccountID := "/subscriptions/" + SubscriptionID + "/resourceGroups/rg-external/providers/Microsoft.Storage/storageAccounts/sarestore"
+	restoreRequest := &armdataprotection.AzureBackupRecoveryPointBasedRestoreRequest{
+		ObjectType:          to.Ptr("AzureBackupRecoveryPointBasedRestoreRequest"),
+		RecoveryPointID:     to.Ptr(recoveryPoints[0]["name"].(string)),
+		SourceDataStoreType: to.Ptr(armdataprotection.SourceDataStoreTypeVaultStore),
+		RestoreTargetInfo: &armdataprotection.RestoreTargetInfo{
+			ObjectType:     to.Ptr("RestoreTargetInfo"),
+			RecoveryOption: to.Ptr(armdataprotection.RecoveryOptionFailIfExists),
+			DatasourceInfo: &armdataprotection.Datasource{ResourceID: to.Ptr(targetStorageAccountID)},
+		},
+	}
+
+	_, err = instancesClient.BeginTriggerRestore(context.Background(), testResourceGroupName, testBackupVaultName, "bkinst-blob-blob1", restoreRequest, nil)
+	assert.ErrorContains(t, err, "UserErrorMissingRequiredPermissions")
+
+	principalID := nestedString(e.Get(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataProtection/backupVaults/%s", SubscriptionID, testResourceGroupName, testBackupVaultName)), "identity", "principalId")
+	require.NoError(t, e.assignRole(SubscriptionID, targetStorageAccountID, "Storage Account Backup Contributor", principalID))
+
+	restorePoller, err := instancesClient.BeginTriggerRestore(context.Background(), testResourceGroupName, testBackupVaultName, "bkinst-blob-blob1", restoreRequest, nil)
+	require.NoError(t, err)
+
+	_, err = restorePoller.PollUntilDone(context.Background(), nil)
+	require.NoError(t, err)
+
+	restored, ok := e.Blob("sarestore", "container1", "test.txt")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("backed up"), restored)
+
+	_, ok = e.Blob("sarestore", "container2", "other.txt")
+	assert.False(t, ok, "Expected only the protected containers to be restored")
+}
+
+func TestPostgresqlFlexibleServerRestoreAsFiles(t *testing.T) {
+	e, credential := newTestEmulator(t)
+
+	err := e.Apply(SubscriptionID, map[string]interface{}{
+		"resource_group_name":        testResourceGroupName,
+		"backup_vault_name":          testBackupVaultName,
+		"log_analytics_workspace_id": "/subscriptions/" + SubscriptionID + "/resourceGroups/rg-external/providers/Microsoft.OperationalInsights/workspaces/law",
+		"postgresql_flexible_server_backups": map[string]map[string]interface{}{
+			"backup1": {
+				"backup_name":              "server1",
+				"retention_period":         "P7D",
+				"backup_intervals":         []string{"R/2024-01-01T00:00:00+00:00/P1W"},
+				"server_id":                "/subscriptions/" + SubscriptionID + "/resourceGroups/rg-external/providers/Microsoft.DBforPostgreSQL/flexibleServers/pgflex",
+				"server_resource_group_id": "/subscriptions/" + SubscriptionID + "/resourceGroups/rg-external",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	instancesClient, err := armdataprotection.NewBackupInstancesClient(SubscriptionID, credential, e.ClientOptions())
+	require.NoError(t, err)
+
+	poller, err := instancesClient.BeginAdhocBackup(context.Background(), testResourceGroupName, testBackupVaultName, "bkinst-pgflex-server1", armdataprotection.TriggerBackupRequest{
+		BackupRuleOptions: &armdataprotection.AdHocBackupRuleOptions{
+			RuleName:      to.Ptr("BackupIntervals"),
+			TriggerOption: &armdataprotection.AdhocBackupTriggerOption{},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = poller.PollUntilDone(context.Background(), nil)
+	require.NoError(t, err)
+
+	recoveryPoints := e.List(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataProtection/backupVaults/%s/backupInstances/bkinst-pgflex-server1/recoveryPoints", SubscriptionID, testResourceGroupName, testBackupVaultName))
+	require.Len(t, recoveryPoints, 1)
+
+	targetStorageAccountID := "/subscriptions/" + SubscriptionID + "/resourceGroups/rg-external/providers/Microsoft.Storage/storageAccounts/sarestore"
+	restoreRequest := &armdataprotection.AzureBackupRecoveryPointBasedRestoreRequest{
+		ObjectType:          to.Ptr("AzureBackupRecoveryPointBasedRestoreRequest"),
+		RecoveryPointID:     to.Ptr(recoveryPoints[0]["name"].(string)),
+		SourceDataStoreType: to.Ptr(armdataprotection.SourceDataStoreTypeVaultStore),
+		RestoreTargetInfo: &armdataprotection.RestoreFilesTargetInfo{
+			ObjectType:     to.Ptr("RestoreFilesTargetInfo"),
+			RecoveryOption: to.Ptr(armdataprotection.RecoveryOptionFailIfExists),
+			TargetDetails: &armdataprotection.TargetDetails{
+				FilePrefix:                to.Ptr("server1"),
+				RestoreTargetLocationType: to.Ptr(armdataprotection.RestoreTargetLocationTypeAzureBlobs),
+				URL:                       to.Ptr("https://sarestore.blob.core.windows.net/dumps"),
+				TargetResourceArmID:       to.Ptr(targetStorageAccountID + "/blobServices/default/containers/dumps"),
+			},
+		},
+	}
+
+	_, err = instancesClient.BeginTriggerRestore(context.Background(), testResourceGroupName, testBackupVaultName, "bkinst-pgflex-server1", restoreRequest, nil)
+	assert.ErrorContains(t, err, "UserErrorMissingRequiredPermissions")
+
+	principalID := nestedString(e.Get(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataProtection/backupVaults/%s", SubscriptionID, testResourceGroupName, testBackupVaultName)), "identity", "principalId")
+	require.NoError(t, e.assignRole(SubscriptionID, targetStorageAccountID, "Storage Blob Data Contributor", principalID))
+
+	restorePoller, err := instancesClient.BeginTriggerRestore(context.Background(), testResourceGroupName, testBackupVaultName, "bkinst-pgflex-server1", restoreRequest, nil)
+	require.NoError(t, err)
+
+	_, err = restorePoller.PollUntilDone(context.Background(), nil)
+	require.NoError(t, err)
+
+	blobClient, err := azblob.NewClient("https://sarestore.blob.core.windows.net/", credential, &azblob.ClientOptions{ClientOptions: e.ClientOptions().ClientOptions})
+	require.NoError(t, err)
+
+	page, err := blobClient.NewListBlobsFlatPager("dumps", &azblob.ListBlobsFlatOptions{Prefix: to.Ptr("server1_")}).NextPage(context.Background())
+	require.NoError(t, err)
+	require.Len(t, page.Segment.BlobItems, 1)
+	assert.True(t, strings.HasPrefix(*page.Segment.BlobItems[0].Name, "server1_postgres_"))
+	assert.Greater(t, *page.Segment.BlobItems[0].Properties.ContentLength, int64(0))
+
+	dump, ok := e.Blob("sarestore", "dumps", *page.Segment.BlobItems[0].Name)
+	assert.True(t, ok)
+	assert.True(t, strings.HasPrefix(string(dump), "PGDMP"), "Expected the dump to carry the pg_dump archive signature")
+}
+
+/*
+ * TestModuleVariablesMatchTerraform tests that the emulator decodes every variable of the
+ * module, and every attribute of the backups, so that it fails here rather than deploying
+ * the wrong resources when infrastructure/variables.tf changes without it.
+ */
+func TestModuleVariablesMatchTerraform(t *testing.T) {
+	file, diagnostics := hclparse.NewParser().ParseHCLFile("../../../infrastructure/variables.tf")
+	require.False(t, diagnostics.HasErrors(), diagnostics.Error())
+
+	var config struct {
+		Variables []struct {
+			Name string         `hcl:"name,label"`
+			Type hcl.Expression `hcl:"type,attr"`
+			Body hcl.Body       `hcl:",remain"`
+		} `hcl:"variable,block"`
+		Body hcl.Body `hcl:",remain"`
+	}
+	diagnostics = gohcl.DecodeBody(file.Body, nil, &config)
+	require.False(t, diagnostics.HasErrors(), diagnostics.Error())
+
+	variables := map[string]cty.Type{}
+	for _, variable := range config.Variables {
+		variableType, _, diagnostics := typeexpr.TypeConstraintWithDefaults(variable.Type)
+		require.False(t, diagnostics.HasErrors(), diagnostics.Error())
+
+		variables[variable.Name] = variableType
+	}
+
+	assertTypeMatches(t, "variables.tf", cty.Object(variables), reflect.TypeFor[moduleVariables]())
+}
+
+/*
+ * Asserts that a go type decodes the same attributes as a terraform type, down through its
+ * collections and objects.
+ */
+func assertTypeMatches(t *testing.T, path string, terraformType cty.Type, goType reflect.Type) {
+	for goType.Kind() == reflect.Pointer {
+		goType = goType.Elem()
+	}
+
+	switch {
+	case terraformType.IsObjectType():
+		if !assert.Equal(t, reflect.Struct, goType.Kind(), "%s is an object, but is decoded into a %s", path, goType) {
+			return
+		}
+
+		fields := jsonFields(goType)
+		attributes := terraformType.AttributeTypes()
+
+		for name, attributeType := range attributes {
+			if field, ok := fields[name]; assert.True(t, ok, "%s.%s isn't decoded by the emulator", path, name) {
+				assertTypeMatches(t, path+"."+name, attributeType, field.Type)
+			}
+		}
+		for name := range fields {
+			_, ok := attributes[name]
+			assert.True(t, ok, "%s.%s is decoded by the emulator, but isn't in the module", path, name)
+		}
+	case terraformType.IsMapType():
+		if assert.Equal(t, reflect.Map, goType.Kind(), "%s is a map, but is decoded into a %s", path, goType) {
+			assertTypeMatches(t, path+"[*]", terraformType.ElementType(), goType.Elem())
+		}
+	case terraformType.IsListType() || terraformType.IsSetType():
+		if assert.Equal(t, reflect.Slice, goType.Kind(), "%s is a list, but is decoded into a %s", path, goType) {
+			assertTypeMatches(t, path+"[*]", terraformType.ElementType(), goType.Elem())
+		}
+	}
+}
+
+/*
+ * Gets the fields of a struct by their json names, including those of embedded structs.
+ */
+func jsonFields(structType reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for field := range slices.Values(reflect.VisibleFields(structType)) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = field
+		}
+	}
+
+	return fields
+}
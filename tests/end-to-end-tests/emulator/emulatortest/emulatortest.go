@@ -0,0 +1,76 @@
+/*
+ * Package emulatortest holds what the emulator backed tests of the other packages share: the
+ * emulator that they run against, and the module variables that they deploy to it.
+ */
+package emulatortest
+
+import (
+	"fmt"
+	"sync"
+
+	"e2e_tests/emulator"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+)
+
+/*
+ * Gets the emulator that a package's tests run against, starting it on first use so that it's
+ * shared by every test in the package.
+ */
+var Shared = sync.OnceValue(emulator.New)
+
+/*
+ * Gets the ID of a resource in the emulator's subscription, where the resource type includes
+ * its provider, e.g. Microsoft.Storage/storageAccounts.
+ */
+func ResourceID(resourceGroupName string, resourceType string, name string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s/%s", emulator.SubscriptionID, resourceGroupName, resourceType, name)
+}
+
+/*
+ * Gets the module variables for a backup vault with no backups, which send its logs to a
+ * workspace in the rg-data resource group.
+ */
+func Variables(resourceGroupName string, backupVaultName string) map[string]interface{} {
+	return map[string]interface{}{
+		"resource_group_name":        resourceGroupName,
+		"backup_vault_name":          backupVaultName,
+		"log_analytics_workspace_id": ResourceID("rg-data", "Microsoft.OperationalInsights/workspaces", "law"),
+	}
+}
+
+/*
+ * Gets a daily backup of a storage account's container1, which keeps its backups for 7 days,
+ * for the blob_storage_backups variable.
+ */
+func BlobStorageBackup(backupName string, storageAccountID string) map[string]interface{} {
+	return map[string]interface{}{
+		"backup_name":                backupName,
+		"retention_period":           "P7D",
+		"backup_intervals":           []string{"R/2024-01-01T00:00:00+00:00/P1D"},
+		"storage_account_id":         storageAccountID,
+		"storage_account_containers": []string{"container1"},
+	}
+}
+
+/*
+ * Gets a 4 hourly backup of a managed disk, which keeps its backups for 7 days and its
+ * snapshots in the disk's own resource group, for the managed_disk_backups variable.
+ */
+func ManagedDiskBackup(backupName string, managedDiskID string) map[string]interface{} {
+	resourceGroupName := ""
+	if id, err := arm.ParseResourceID(managedDiskID); err == nil {
+		resourceGroupName = id.ResourceGroupName
+	}
+
+	return map[string]interface{}{
+		"backup_name":      backupName,
+		"retention_period": "P7D",
+		"backup_intervals": []string{"R/2024-01-01T00:00:00+00:00/PT4H"},
+		"managed_disk_id":  managedDiskID,
+		"managed_disk_resource_group": map[string]interface{}{
+			"id":   fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", emulator.SubscriptionID, resourceGroupName),
+			"name": resourceGroupName,
+		},
+	}
+}
@@ -0,0 +1,35 @@
+package emulator
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+ * Serves the Entra ID endpoints that a client secret credential uses to obtain a token. Any
+ * client and secret is accepted, and every token is valid for an hour.
+ */
+func (e *Emulator) serveIdentity(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	tenantID := segments[0]
+	authority := fmt.Sprintf("https://%s/%s", identityHost, tenantID)
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/.well-known/openid-configuration"):
+		writeJSON(w, http.StatusOK, map[string]any{
+			"authorization_endpoint": authority + "/oauth2/v2.0/authorize",
+			"token_endpoint":         authority + "/oauth2/v2.0/token",
+			"issuer":                 authority + "/v2.0",
+		})
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/oauth2/v2.0/token"):
+		writeJSON(w, http.StatusOK, map[string]any{
+			"token_type":     "Bearer",
+			"access_token":   "emulator-access-token",
+			"expires_in":     3600,
+			"ext_expires_in": 3600,
+		})
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("The identity endpoint %s is not supported by the emulator", r.URL.Path))
+	}
+}
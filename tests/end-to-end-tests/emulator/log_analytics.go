@@ -0,0 +1,358 @@
+package emulator
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	logAnalyticsHost = "api.loganalytics.io"
+	workspaceType    = "Microsoft.OperationalInsights/workspaces"
+)
+
+/*
+ * The columns of the log tables that the emulator writes to, in the order the query API
+ * returns them. A row that doesn't set a column returns null for it.
+ */
+var logTableColumns = map[string][]logColumn{
+	"AddonAzureBackupJobs": {
+		{"TimeGenerated", "datetime"},
+		{"JobUniqueId", "string"},
+		{"JobOperation", "string"},
+		{"JobOperationSubType", "string"},
+		{"JobStatus", "string"},
+		{"JobFailureCode", "string"},
+		{"JobStartDateTime", "datetime"},
+		{"JobDurationInSecs", "real"},
+		{"BackupItemUniqueId", "string"},
+		{"DatasourceType", "string"},
+		{"ProtectionGroupName", "string"},
+		{"VaultUniqueId", "string"},
+		{"OperationName", "string"},
+		{"_ResourceId", "string"},
+		{"Type", "string"},
+	},
+}
+
+type logColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+var (
+	letStatementPattern = regexp.MustCompile(`^let\s+([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.+)$`)
+	comparisonPattern   = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*(==|!=|=~)\s*(.+)$`)
+	projectPattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\s*,\s*[A-Za-z_][A-Za-z0-9_]*)*$`)
+)
+
+/*
+ * Puts a Log Analytics workspace, assigning it the customer ID that queries address it by.
+ * The customer ID is kept when the workspace is updated.
+ */
+func (e *Emulator) putWorkspace(w http.ResponseWriter, r *http.Request, path string) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	if e.Get(resourceGroupID(path)) == nil {
+		writeError(w, http.StatusNotFound, "ResourceGroupNotFound", fmt.Sprintf("Resource group for '%s' could not be found.", path))
+		return
+	}
+
+	customerID := newUUID()
+	if existing := e.Get(path); existing != nil {
+		customerID = nestedString(existing, "properties", "customerId")
+	}
+
+	properties, ok := body["properties"].(map[string]any)
+	if !ok {
+		properties = map[string]any{}
+		body["properties"] = properties
+	}
+	properties["customerId"] = customerID
+
+	writeJSON(w, http.StatusOK, e.Put(path, body))
+}
+
+/*
+ * Sends a log record for a resource to every workspace that the resource's diagnostic
+ * settings deliver the category to, as Azure Monitor would (without the ingestion delay).
+ */
+func (e *Emulator) emitLog(resourceID string, category string, record map[string]any) {
+	for _, settings := range e.List(resourceID + "/providers/Microsoft.Insights/diagnosticSettings") {
+		workspaceID := nestedString(settings, "properties", "workspaceId")
+		if workspaceID == "" {
+			continue
+		}
+
+		logs, _ := nestedValue(settings, "properties", "logs").([]any)
+		enabled := slices.ContainsFunc(logs, func(log any) bool {
+			entry, _ := log.(map[string]any)
+			return entry["category"] == category && entry["enabled"] == true
+		})
+		if !enabled {
+			continue
+		}
+
+		row := copyMap(record)
+		row["TimeGenerated"] = time.Now().UTC().Format(time.RFC3339Nano)
+		row["_ResourceId"] = strings.ToLower(resourceID)
+		row["Type"] = category
+
+		e.mu.Lock()
+		key := strings.ToLower(cleanPath(workspaceID))
+		e.logs[key] = append(e.logs[key], row)
+		e.mu.Unlock()
+	}
+}
+
+/*
+ * Sends the AddonAzureBackupJobs record for a job recorded against a backup vault.
+ */
+func (e *Emulator) emitJobLog(vaultID string, job map[string]any, backupInstance map[string]any) {
+	properties := job["properties"].(map[string]any)
+	startTime, _ := time.Parse(time.RFC3339, properties["startTime"].(string))
+	endTime, _ := time.Parse(time.RFC3339, properties["endTime"].(string))
+	vault := e.Get(vaultID)
+
+	e.emitLog(vaultID, "AddonAzureBackupJobs", map[string]any{
+		"JobUniqueId":         job["name"],
+		"JobOperation":        properties["operation"],
+		"JobStatus":           properties["status"],
+		"JobFailureCode":      "Success",
+		"JobStartDateTime":    startTime.Format(time.RFC3339Nano),
+		"JobDurationInSecs":   endTime.Sub(startTime).Seconds(),
+		"BackupItemUniqueId":  strings.ToLower(fmt.Sprint(backupInstance["id"])),
+		"DatasourceType":      properties["dataSourceType"],
+		"ProtectionGroupName": properties["policyName"],
+		"VaultUniqueId":       nestedString(vault, "identity", "principalId"),
+		"OperationName":       "Job",
+	})
+}
+
+/*
+ * Serves the Log Analytics query API. The emulator understands enough KQL to filter the logs it
+ * has recorded: a table, then any number of where (==, != and =~ against a literal or a let
+ * variable, joined by and), project and take/limit operators. Anything else is rejected as the
+ * real API rejects a query it can't parse, so tests don't pass against queries the emulator
+ * can't actually evaluate.
+ */
+func (e *Emulator) serveLogAnalytics(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodPost || len(segments) != 4 || segments[0] != "v1" || segments[1] != "workspaces" || segments[3] != "query" {
+		writeError(w, http.StatusNotFound, "PathNotFoundError", fmt.Sprintf("The requested path %s does not exist", r.URL.Path))
+		return
+	}
+
+	workspace := e.findWorkspace(segments[2])
+	if workspace == nil {
+		writeError(w, http.StatusNotFound, "WorkspaceNotFoundError", fmt.Sprintf("Workspace %s not found", segments[2]))
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadArgumentError", err.Error())
+		return
+	}
+
+	query, _ := body["query"].(string)
+	since := time.Time{}
+	if timespan, ok := body["timespan"].(string); ok {
+		duration, err := time.ParseDuration(strings.ToLower(strings.TrimPrefix(timespan, "PT")))
+		if err != nil || !strings.HasPrefix(timespan, "PT") {
+			writeError(w, http.StatusBadRequest, "BadArgumentError", fmt.Sprintf("The emulator doesn't support the timespan %s", timespan))
+			return
+		}
+		since = time.Now().UTC().Add(-duration)
+	}
+
+	e.mu.Lock()
+	records := slices.Clone(e.logs[strings.ToLower(fmt.Sprint(workspace["id"]))])
+	e.mu.Unlock()
+
+	columns, rows, err := evaluateQuery(query, records, since)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadArgumentError", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tables": []any{
+			map[string]any{"name": "PrimaryResult", "columns": columns, "rows": rows},
+		},
+	})
+}
+
+func (e *Emulator) findWorkspace(customerID string) map[string]any {
+	for _, workspace := range e.resourcesOfType(workspaceType) {
+		if strings.EqualFold(nestedString(workspace, "properties", "customerId"), customerID) {
+			return workspace
+		}
+	}
+
+	return nil
+}
+
+func evaluateQuery(query string, records []map[string]any, since time.Time) ([]logColumn, [][]any, error) {
+	variables := map[string]string{}
+	var pipeline []string
+
+	for _, statement := range strings.Split(stripComments(query), ";") {
+		statement = strings.TrimSpace(statement)
+		if statement == "" {
+			continue
+		}
+
+		if match := letStatementPattern.FindStringSubmatch(statement); match != nil {
+			variables[match[1]] = strings.TrimSpace(match[2])
+			continue
+		}
+
+		if pipeline != nil {
+			return nil, nil, fmt.Errorf("the emulator supports a single tabular expression")
+		}
+		pipeline = strings.Split(statement, "|")
+	}
+
+	if pipeline == nil {
+		return nil, nil, fmt.Errorf("the query has no tabular expression")
+	}
+
+	table := strings.TrimSpace(pipeline[0])
+	columns, ok := logTableColumns[table]
+	if !ok {
+		return nil, nil, fmt.Errorf("'%s' could not be resolved to a table", table)
+	}
+
+	var matched []map[string]any
+	for _, record := range records {
+		generated, _ := time.Parse(time.RFC3339Nano, fmt.Sprint(record["TimeGenerated"]))
+		if record["Type"] == table && !generated.Before(since) {
+			matched = append(matched, record)
+		}
+	}
+
+	for _, operator := range pipeline[1:] {
+		name, argument, _ := strings.Cut(strings.TrimSpace(operator), " ")
+		argument = strings.TrimSpace(argument)
+
+		switch name {
+		case "where":
+			filtered, err := where(matched, columns, argument, variables)
+			if err != nil {
+				return nil, nil, err
+			}
+			matched = filtered
+		case "project":
+			projected, err := project(columns, argument)
+			if err != nil {
+				return nil, nil, err
+			}
+			columns = projected
+		case "take", "limit":
+			count, err := strconv.Atoi(argument)
+			if err != nil || count < 0 {
+				return nil, nil, fmt.Errorf("%s expects a row count, got '%s'", name, argument)
+			}
+			matched = matched[:min(count, len(matched))]
+		default:
+			return nil, nil, fmt.Errorf("the emulator doesn't support the '%s' operator", name)
+		}
+	}
+
+	rows := [][]any{}
+	for _, record := range matched {
+		row := make([]any, len(columns))
+		for i, column := range columns {
+			row[i] = record[column.Name]
+		}
+		rows = append(rows, row)
+	}
+
+	return columns, rows, nil
+}
+
+func where(records []map[string]any, columns []logColumn, predicate string, variables map[string]string) ([]map[string]any, error) {
+	filtered := records
+
+	for _, condition := range strings.Split(predicate, " and ") {
+		match := comparisonPattern.FindStringSubmatch(strings.TrimSpace(condition))
+		if match == nil {
+			return nil, fmt.Errorf("the emulator doesn't support the predicate '%s'", condition)
+		}
+
+		column, operator := match[1], match[2]
+		if !slices.ContainsFunc(columns, func(c logColumn) bool { return c.Name == column }) {
+			return nil, fmt.Errorf("'%s' could not be resolved to a column", column)
+		}
+
+		literal := strings.TrimSpace(match[3])
+		if value, ok := variables[literal]; ok {
+			literal = value
+		}
+		value, err := strconv.Unquote(literal)
+		if err != nil {
+			return nil, fmt.Errorf("the emulator only compares against string literals, got '%s'", literal)
+		}
+
+		var next []map[string]any
+		for _, record := range filtered {
+			actual, _ := record[column].(string)
+
+			var keep bool
+			switch operator {
+			case "==":
+				keep = actual == value
+			case "!=":
+				keep = actual != value
+			case "=~":
+				keep = strings.EqualFold(actual, value)
+			}
+
+			if keep {
+				next = append(next, record)
+			}
+		}
+		filtered = next
+	}
+
+	return filtered, nil
+}
+
+func project(columns []logColumn, argument string) ([]logColumn, error) {
+	if !projectPattern.MatchString(argument) {
+		return nil, fmt.Errorf("the emulator only supports projecting columns by name, got '%s'", argument)
+	}
+
+	var projected []logColumn
+	for _, name := range strings.Split(argument, ",") {
+		name = strings.TrimSpace(name)
+
+		index := slices.IndexFunc(columns, func(c logColumn) bool { return c.Name == name })
+		if index == -1 {
+			return nil, fmt.Errorf("'%s' could not be resolved to a column", name)
+		}
+		projected = append(projected, columns[index])
+	}
+
+	return projected, nil
+}
+
+func stripComments(query string) string {
+	var lines []string
+	for _, line := range strings.Split(query, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "//") {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
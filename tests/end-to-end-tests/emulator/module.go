@@ -0,0 +1,724 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+)
+
+/*
+ * The az-backup module input variables, decoded from the terraform options vars.
+ */
+type moduleVariables struct {
+	ResourceGroupName                 string                                    `json:"resource_group_name"`
+	ResourceGroupLocation             string                                    `json:"resource_group_location"`
+	CreateResourceGroup               *bool                                     `json:"create_resource_group"`
+	BackupVaultName                   string                                    `json:"backup_vault_name"`
+	BackupVaultRedundancy             string                                    `json:"backup_vault_redundancy"`
+	BackupVaultImmutability           string                                    `json:"backup_vault_immutability"`
+	BackupVaultSoftDelete             string                                    `json:"backup_vault_soft_delete"`
+	LogAnalyticsWorkspaceID           string                                    `json:"log_analytics_workspace_id"`
+	Tags                              map[string]string                         `json:"tags"`
+	UseExtendedRetention              bool                                      `json:"use_extended_retention"`
+	BlobStorageBackups                map[string]blobStorageBackup              `json:"blob_storage_backups"`
+	DataLakeStorageBackups            map[string]dataLakeStorageBackup          `json:"data_lake_storage_backups"`
+	ManagedDiskBackups                map[string]managedDiskBackup              `json:"managed_disk_backups"`
+	PostgresqlFlexibleServerBackups   map[string]postgresqlFlexibleServerBackup `json:"postgresql_flexible_server_backups"`
+	MysqlFlexibleServerBackups        map[string]mysqlFlexibleServerBackup      `json:"mysql_flexible_server_backups"`
+	AksClusterBackups                 map[string]aksClusterBackup               `json:"aks_cluster_backups"`
+	RecoveryServicesVaultName         string                                    `json:"recovery_services_vault_name"`
+	RecoveryServicesVaultRedundancy   string                                    `json:"recovery_services_vault_redundancy"`
+	RecoveryServicesVaultImmutability string                                    `json:"recovery_services_vault_immutability"`
+	RecoveryServicesVaultSoftDelete   string                                    `json:"recovery_services_vault_soft_delete"`
+	VMBackups                         map[string]vmBackup                       `json:"vm_backups"`
+	FileShareBackups                  map[string]fileShareBackup                `json:"file_share_backups"`
+}
+
+type backupCommon struct {
+	BackupName                   string          `json:"backup_name"`
+	RetentionPeriod              string          `json:"retention_period"`
+	BackupIntervals              []string        `json:"backup_intervals"`
+	BackupPolicyNamingTemplate   string          `json:"backup_policy_naming_template"`
+	BackupInstanceNamingTemplate string          `json:"backup_instance_naming_template"`
+	RetentionRules               []retentionRule `json:"retention_rules"`
+}
+
+type retentionRule struct {
+	Name             string   `json:"name"`
+	Duration         string   `json:"duration"`
+	AbsoluteCriteria string   `json:"absolute_criteria"`
+	DaysOfWeek       []string `json:"days_of_week"`
+	WeeksOfMonth     []string `json:"weeks_of_month"`
+	MonthsOfYear     []string `json:"months_of_year"`
+}
+
+type blobStorageBackup struct {
+	backupCommon
+	StorageAccountID         string   `json:"storage_account_id"`
+	StorageAccountContainers []string `json:"storage_account_containers"`
+	TimeZone                 string   `json:"time_zone"`
+	EnableDailyRetentionRule bool     `json:"enable_daily_retention_rule"`
+}
+
+type dataLakeStorageBackup struct {
+	backupCommon
+	StorageAccountID         string   `json:"storage_account_id"`
+	StorageAccountContainers []string `json:"storage_account_containers"`
+	TimeZone                 string   `json:"time_zone"`
+}
+
+/*
+ * The datasource parameters of a data lake storage backup instance, which the SDK has no model
+ * for. Only what's needed to marshal the backup instance is implemented.
+ */
+type adlsBlobBackupDatasourceParameters struct {
+	ContainersList []*string
+}
+
+func (parameters *adlsBlobBackupDatasourceParameters) GetBackupDatasourceParameters() *armdataprotection.BackupDatasourceParameters {
+	return &armdataprotection.BackupDatasourceParameters{ObjectType: to.Ptr("AdlsBlobBackupDatasourceParameters")}
+}
+
+func (parameters adlsBlobBackupDatasourceParameters) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"objectType":     "AdlsBlobBackupDatasourceParameters",
+		"containersList": parameters.ContainersList,
+	})
+}
+
+type managedDiskBackup struct {
+	backupCommon
+	ManagedDiskID            string `json:"managed_disk_id"`
+	ManagedDiskResourceGroup struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"managed_disk_resource_group"`
+}
+
+type postgresqlFlexibleServerBackup struct {
+	backupCommon
+	ServerID              string `json:"server_id"`
+	ServerResourceGroupID string `json:"server_resource_group_id"`
+}
+
+type mysqlFlexibleServerBackup struct {
+	backupCommon
+	ServerID              string `json:"server_id"`
+	ServerResourceGroupID string `json:"server_resource_group_id"`
+}
+
+type aksClusterBackup struct {
+	backupCommon
+	ClusterID                  string `json:"cluster_id"`
+	ClusterIdentityPrincipalID string `json:"cluster_identity_principal_id"`
+	SnapshotResourceGroup      struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"snapshot_resource_group"`
+	StorageAccountID        string   `json:"storage_account_id"`
+	StorageAccountContainer string   `json:"storage_account_container"`
+	IncludedNamespaces      []string `json:"included_namespaces"`
+	ExcludedNamespaces      []string `json:"excluded_namespaces"`
+}
+
+/*
+ * Deploys the resources that the az-backup terraform module would create for the provided
+ * input variables, so that tests can validate them without running terraform. Defaults
+ * mirror those in infrastructure/variables.tf.
+ */
+func (e *Emulator) Apply(subscriptionID string, vars map[string]interface{}) error {
+	variables, err := decodeVariables(vars)
+	if err != nil {
+		return err
+	}
+
+	resourceGroupID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", subscriptionID, variables.ResourceGroupName)
+
+	if *variables.CreateResourceGroup {
+		e.Put(resourceGroupID, map[string]any{
+			"location": variables.ResourceGroupLocation,
+			"tags":     variables.Tags,
+		})
+	} else if e.Get(resourceGroupID) == nil {
+		return fmt.Errorf("resource group %s does not exist", variables.ResourceGroupName)
+	}
+
+	location, _ := e.Get(resourceGroupID)["location"].(string)
+
+	vaultID := fmt.Sprintf("%s/providers/Microsoft.DataProtection/backupVaults/%s", resourceGroupID, variables.BackupVaultName)
+	principalID := newUUID()
+
+	e.Put(vaultID, map[string]any{
+		"location": location,
+		"tags":     variables.Tags,
+		"identity": map[string]any{
+			"type":        "SystemAssigned",
+			"principalId": principalID,
+			"tenantId":    TenantID,
+		},
+		"properties": map[string]any{
+			"storageSettings": []any{
+				map[string]any{"datastoreType": "VaultStore", "type": variables.BackupVaultRedundancy},
+			},
+			"securitySettings": map[string]any{
+				"immutabilitySettings": map[string]any{"state": variables.BackupVaultImmutability},
+				"softDeleteSettings":   map[string]any{"state": variables.BackupVaultSoftDelete, "retentionDurationInDays": 14},
+			},
+		},
+	})
+
+	e.putVaultDiagnosticSettings(vaultID, variables.BackupVaultName, variables.LogAnalyticsWorkspaceID)
+
+	for _, key := range sortedKeys(variables.BlobStorageBackups) {
+		backup := variables.BlobStorageBackups[key]
+
+		if err := e.assignRole(subscriptionID, backup.StorageAccountID, "Storage Account Backup Contributor", principalID); err != nil {
+			return err
+		}
+
+		policy := newBackupPolicy("Microsoft.Storage/storageAccounts/blobServices", armdataprotection.DataStoreTypesVaultStore, backup.backupCommon, backup.TimeZone)
+		if backup.EnableDailyRetentionRule {
+			addDailyRetentionRule(policy, backup.RetentionPeriod)
+		}
+
+		policyID, err := e.putBackupPolicy(vaultID, backup.names(naming.ResourceTypeBlobStorage).PolicyName(), policy)
+		if err != nil {
+			return err
+		}
+
+		instance := newBackupInstance(backup.StorageAccountID, "Microsoft.Storage/storageAccounts/blobServices", location, policyID)
+		instance.PolicyInfo.PolicyParameters = &armdataprotection.PolicyParameters{
+			BackupDatasourceParametersList: []armdataprotection.BackupDatasourceParametersClassification{
+				&armdataprotection.BlobBackupDatasourceParameters{
+					ObjectType:     to.Ptr("BlobBackupDatasourceParameters"),
+					ContainersList: to.SliceOfPtrs(backup.StorageAccountContainers...),
+				},
+			},
+		}
+
+		if err := e.putBackupInstance(vaultID, backup.names(naming.ResourceTypeBlobStorage).InstanceName(), instance); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range sortedKeys(variables.DataLakeStorageBackups) {
+		backup := variables.DataLakeStorageBackups[key]
+
+		if err := e.assignRole(subscriptionID, backup.StorageAccountID, "Storage Account Backup Contributor", principalID); err != nil {
+			return err
+		}
+
+		policy := newBackupPolicy("Microsoft.Storage/storageAccounts/adlsBlobServices", armdataprotection.DataStoreTypesVaultStore, backup.backupCommon, backup.TimeZone)
+
+		policyID, err := e.putBackupPolicy(vaultID, backup.names(naming.ResourceTypeDataLakeStorage).PolicyName(), policy)
+		if err != nil {
+			return err
+		}
+
+		instance := newBackupInstance(backup.StorageAccountID, "Microsoft.Storage/storageAccounts/adlsBlobServices", location, policyID)
+		instance.PolicyInfo.PolicyParameters = &armdataprotection.PolicyParameters{
+			BackupDatasourceParametersList: []armdataprotection.BackupDatasourceParametersClassification{
+				&adlsBlobBackupDatasourceParameters{
+					ContainersList: to.SliceOfPtrs(backup.StorageAccountContainers...),
+				},
+			},
+		}
+
+		if err := e.putBackupInstance(vaultID, backup.names(naming.ResourceTypeDataLakeStorage).InstanceName(), instance); err != nil {
+			return err
+		}
+	}
+
+	for index, key := range sortedKeys(variables.ManagedDiskBackups) {
+		backup := variables.ManagedDiskBackups[key]
+
+		// The module only assigns resource group level roles once, for the first backup
+		if index == 0 {
+			if err := e.assignRole(subscriptionID, backup.ManagedDiskResourceGroup.ID, "Disk Snapshot Contributor", principalID); err != nil {
+				return err
+			}
+		}
+
+		if err := e.assignRole(subscriptionID, backup.ManagedDiskID, "Disk Backup Reader", principalID); err != nil {
+			return err
+		}
+
+		policy := newBackupPolicy("Microsoft.Compute/disks", armdataprotection.DataStoreTypesOperationalStore, backup.backupCommon, "")
+
+		policyID, err := e.putBackupPolicy(vaultID, backup.names(naming.ResourceTypeManagedDisk).PolicyName(), policy)
+		if err != nil {
+			return err
+		}
+
+		instance := newBackupInstance(backup.ManagedDiskID, "Microsoft.Compute/disks", location, policyID)
+		instance.PolicyInfo.PolicyParameters = &armdataprotection.PolicyParameters{
+			DataStoreParametersList: []armdataprotection.DataStoreParametersClassification{
+				&armdataprotection.AzureOperationalStoreParameters{
+					ObjectType:      to.Ptr("AzureOperationalStoreParameters"),
+					DataStoreType:   to.Ptr(armdataprotection.DataStoreTypesOperationalStore),
+					ResourceGroupID: to.Ptr(backup.ManagedDiskResourceGroup.ID),
+				},
+			},
+		}
+
+		if err := e.putBackupInstance(vaultID, backup.names(naming.ResourceTypeManagedDisk).InstanceName(), instance); err != nil {
+			return err
+		}
+	}
+
+	for index, key := range sortedKeys(variables.PostgresqlFlexibleServerBackups) {
+		backup := variables.PostgresqlFlexibleServerBackups[key]
+
+		if index == 0 {
+			if err := e.assignRole(subscriptionID, backup.ServerResourceGroupID, "Reader", principalID); err != nil {
+				return err
+			}
+		}
+
+		if err := e.assignRole(subscriptionID, backup.ServerID, "PostgreSQL Flexible Server Long Term Retention Backup Role", principalID); err != nil {
+			return err
+		}
+
+		policy := newBackupPolicy("Microsoft.DBforPostgreSQL/flexibleServers", armdataprotection.DataStoreTypesVaultStore, backup.backupCommon, "")
+
+		policyID, err := e.putBackupPolicy(vaultID, backup.names(naming.ResourceTypePostgresqlFlexibleServer).PolicyName(), policy)
+		if err != nil {
+			return err
+		}
+
+		instance := newBackupInstance(backup.ServerID, "Microsoft.DBforPostgreSQL/flexibleServers", location, policyID)
+
+		if err := e.putBackupInstance(vaultID, backup.names(naming.ResourceTypePostgresqlFlexibleServer).InstanceName(), instance); err != nil {
+			return err
+		}
+	}
+
+	for index, key := range sortedKeys(variables.MysqlFlexibleServerBackups) {
+		backup := variables.MysqlFlexibleServerBackups[key]
+
+		if index == 0 {
+			if err := e.assignRole(subscriptionID, backup.ServerResourceGroupID, "Reader", principalID); err != nil {
+				return err
+			}
+		}
+
+		if err := e.assignRole(subscriptionID, backup.ServerID, "MySQL Backup And Export Operator", principalID); err != nil {
+			return err
+		}
+
+		policy := newBackupPolicy("Microsoft.DBforMySQL/flexibleServers", armdataprotection.DataStoreTypesVaultStore, backup.backupCommon, "")
+
+		policyID, err := e.putBackupPolicy(vaultID, backup.names(naming.ResourceTypeMysqlFlexibleServer).PolicyName(), policy)
+		if err != nil {
+			return err
+		}
+
+		instance := newBackupInstance(backup.ServerID, "Microsoft.DBforMySQL/flexibleServers", location, policyID)
+
+		if err := e.putBackupInstance(vaultID, backup.names(naming.ResourceTypeMysqlFlexibleServer).InstanceName(), instance); err != nil {
+			return err
+		}
+	}
+
+	for index, key := range sortedKeys(variables.AksClusterBackups) {
+		backup := variables.AksClusterBackups[key]
+		storageAccount := strings.Split(backup.StorageAccountID, "/")
+
+		extensionPrincipalID := e.putBackupExtension(backup.ClusterID, aksBackupExtensionName, map[string]any{
+			"configuration.backupStorageLocation.bucket":                backup.StorageAccountContainer,
+			"configuration.backupStorageLocation.config.resourceGroup":  storageAccount[4],
+			"configuration.backupStorageLocation.config.storageAccount": storageAccount[8],
+			"configuration.backupStorageLocation.config.subscriptionId": storageAccount[2],
+			"credentials.tenantId": TenantID,
+		})
+
+		if err := e.assignRole(subscriptionID, backup.StorageAccountID, "Storage Blob Data Contributor", extensionPrincipalID); err != nil {
+			return err
+		}
+
+		e.Put(trustedAccessRoleBindingID(backup.ClusterID, vaultID), map[string]any{
+			"properties": map[string]any{
+				"sourceResourceId": vaultID,
+				"roles":            []any{"Microsoft.DataProtection/backupVaults/backup-operator"},
+			},
+		})
+
+		if index == 0 {
+			if err := e.assignRole(subscriptionID, backup.SnapshotResourceGroup.ID, "Reader", principalID); err != nil {
+				return err
+			}
+		}
+
+		if err := e.assignRole(subscriptionID, backup.ClusterID, "Reader", principalID); err != nil {
+			return err
+		}
+
+		if err := e.assignRole(subscriptionID, backup.SnapshotResourceGroup.ID, "Contributor", backup.ClusterIdentityPrincipalID); err != nil {
+			return err
+		}
+
+		policy := newBackupPolicy("Microsoft.ContainerService/managedClusters", armdataprotection.DataStoreTypesOperationalStore, backup.backupCommon, "")
+
+		policyID, err := e.putBackupPolicy(vaultID, backup.names(naming.ResourceTypeAksCluster).PolicyName(), policy)
+		if err != nil {
+			return err
+		}
+
+		instance := newBackupInstance(backup.ClusterID, "Microsoft.ContainerService/managedClusters", location, policyID)
+		instance.PolicyInfo.PolicyParameters = &armdataprotection.PolicyParameters{
+			DataStoreParametersList: []armdataprotection.DataStoreParametersClassification{
+				&armdataprotection.AzureOperationalStoreParameters{
+					ObjectType:      to.Ptr("AzureOperationalStoreParameters"),
+					DataStoreType:   to.Ptr(armdataprotection.DataStoreTypesOperationalStore),
+					ResourceGroupID: to.Ptr(backup.SnapshotResourceGroup.ID),
+				},
+			},
+			BackupDatasourceParametersList: []armdataprotection.BackupDatasourceParametersClassification{
+				&armdataprotection.KubernetesClusterBackupDatasourceParameters{
+					ObjectType:                   to.Ptr("KubernetesClusterBackupDatasourceParameters"),
+					IncludeClusterScopeResources: to.Ptr(true),
+					SnapshotVolumes:              to.Ptr(true),
+					IncludedNamespaces:           to.SliceOfPtrs(backup.IncludedNamespaces...),
+					ExcludedNamespaces:           to.SliceOfPtrs(backup.ExcludedNamespaces...),
+				},
+			},
+		}
+
+		if err := e.putBackupInstance(vaultID, backup.names(naming.ResourceTypeAksCluster).InstanceName(), instance); err != nil {
+			return err
+		}
+	}
+
+	if variables.RecoveryServicesVaultName != "" {
+		if err := e.applyRecoveryServicesVault(resourceGroupID, location, variables); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Puts the diagnostic settings that the module gives both the backup vault and the recovery
+ * services vault.
+ */
+func (e *Emulator) putVaultDiagnosticSettings(vaultID string, vaultName string, workspaceID string) {
+	e.Put(fmt.Sprintf("%s/providers/Microsoft.Insights/diagnosticSettings/%s-diagnostic-settings", vaultID, vaultName), map[string]any{
+		"properties": map[string]any{
+			"workspaceId": workspaceID,
+			"logs": []any{
+				map[string]any{"category": "AddonAzureBackupJobs", "enabled": true},
+				map[string]any{"category": "AddonAzureBackupPolicy", "enabled": true},
+				map[string]any{"category": "AddonAzureBackupProtectedInstance", "enabled": true},
+				map[string]any{"category": "CoreAzureBackup", "enabled": true},
+			},
+			"metrics": []any{
+				map[string]any{"category": "Health", "enabled": true},
+			},
+		},
+	})
+}
+
+/*
+ * Removes the resources that Apply deployed for the provided input variables.
+ */
+func (e *Emulator) Destroy(subscriptionID string, vars map[string]interface{}) error {
+	variables, err := decodeVariables(vars)
+	if err != nil {
+		return err
+	}
+
+	resourceGroupID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", subscriptionID, variables.ResourceGroupName)
+	vaultID := fmt.Sprintf("%s/providers/Microsoft.DataProtection/backupVaults/%s", resourceGroupID, variables.BackupVaultName)
+
+	vault := e.Get(vaultID)
+	if vault != nil {
+		principalID := nestedString(vault, "identity", "principalId")
+
+		for _, assignment := range e.resourcesOfType(roleAssignmentType) {
+			if nestedString(assignment, "properties", "principalId") == principalID {
+				e.Delete(assignment["id"].(string))
+			}
+		}
+
+		e.Delete(vaultID)
+	}
+
+	// The AKS cluster backups also change the clusters, and grant roles to identities other than the vault's
+	for _, key := range sortedKeys(variables.AksClusterBackups) {
+		backup := variables.AksClusterBackups[key]
+		extensionID := fmt.Sprintf("%s/providers/Microsoft.KubernetesConfiguration/extensions/%s", backup.ClusterID, aksBackupExtensionName)
+
+		if extension := e.Get(extensionID); extension != nil {
+			e.unassignRole(backup.StorageAccountID, "Storage Blob Data Contributor", nestedString(extension, "properties", "aksAssignedIdentity", "principalId"))
+			e.Delete(extensionID)
+		}
+
+		e.Delete(trustedAccessRoleBindingID(backup.ClusterID, vaultID))
+		e.unassignRole(backup.SnapshotResourceGroup.ID, "Contributor", backup.ClusterIdentityPrincipalID)
+	}
+
+	if variables.RecoveryServicesVaultName != "" {
+		e.Delete(fmt.Sprintf("%s/providers/Microsoft.RecoveryServices/vaults/%s", resourceGroupID, variables.RecoveryServicesVaultName))
+	}
+
+	if *variables.CreateResourceGroup {
+		e.Delete(resourceGroupID)
+	}
+
+	return nil
+}
+
+func decodeVariables(vars map[string]interface{}) (*moduleVariables, error) {
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode module variables: %w", err)
+	}
+
+	// Variables the emulator doesn't know are rejected, rather than deploying resources which
+	// don't match what the module would
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	variables := &moduleVariables{}
+	if err := decoder.Decode(variables); err != nil {
+		return nil, fmt.Errorf("failed to decode module variables, which may not be supported by the emulator yet: %w", err)
+	}
+
+	if variables.ResourceGroupName == "" || variables.BackupVaultName == "" {
+		return nil, fmt.Errorf("resource_group_name and backup_vault_name must be set")
+	}
+
+	if variables.ResourceGroupLocation == "" {
+		variables.ResourceGroupLocation = "uksouth"
+	}
+	if variables.CreateResourceGroup == nil {
+		variables.CreateResourceGroup = to.Ptr(true)
+	}
+	if variables.BackupVaultRedundancy == "" {
+		variables.BackupVaultRedundancy = "LocallyRedundant"
+	}
+	if variables.BackupVaultImmutability == "" {
+		variables.BackupVaultImmutability = "Disabled"
+	}
+	if variables.BackupVaultSoftDelete == "" {
+		variables.BackupVaultSoftDelete = "Off"
+	}
+	if variables.RecoveryServicesVaultRedundancy == "" {
+		variables.RecoveryServicesVaultRedundancy = "LocallyRedundant"
+	}
+	if variables.RecoveryServicesVaultImmutability == "" {
+		variables.RecoveryServicesVaultImmutability = "Disabled"
+	}
+	if variables.RecoveryServicesVaultSoftDelete == "" {
+		variables.RecoveryServicesVaultSoftDelete = "Off"
+	}
+
+	return variables, nil
+}
+
+/*
+ * Gets the backup's names, rendered in the same way as the backup modules' locals.tf.
+ */
+func (backup backupCommon) names(resourceType string) naming.Backup {
+	return naming.Backup{
+		ResourceType:           resourceType,
+		BackupName:             backup.BackupName,
+		PolicyNamingTemplate:   backup.BackupPolicyNamingTemplate,
+		InstanceNamingTemplate: backup.BackupInstanceNamingTemplate,
+	}
+}
+
+func newBackupPolicy(datasourceType string, dataStoreType armdataprotection.DataStoreTypes, backup backupCommon, timeZone string) *armdataprotection.BackupPolicy {
+	dataStore := &armdataprotection.DataStoreInfoBase{
+		ObjectType:    to.Ptr("DataStoreInfoBase"),
+		DataStoreType: to.Ptr(dataStoreType),
+	}
+
+	schedule := &armdataprotection.BackupSchedule{
+		RepeatingTimeIntervals: to.SliceOfPtrs(backup.BackupIntervals...),
+	}
+	if timeZone != "" {
+		schedule.TimeZone = to.Ptr(timeZone)
+	}
+
+	policy := &armdataprotection.BackupPolicy{
+		ObjectType:      to.Ptr("BackupPolicy"),
+		DatasourceTypes: []*string{to.Ptr(datasourceType)},
+		PolicyRules: []armdataprotection.BasePolicyRuleClassification{
+			&armdataprotection.AzureBackupRule{
+				ObjectType: to.Ptr("AzureBackupRule"),
+				Name:       to.Ptr("BackupIntervals"),
+				DataStore:  dataStore,
+				BackupParameters: &armdataprotection.AzureBackupParams{
+					ObjectType: to.Ptr("AzureBackupParams"),
+					BackupType: to.Ptr("Discrete"),
+				},
+				Trigger: &armdataprotection.ScheduleBasedTriggerContext{
+					ObjectType: to.Ptr("ScheduleBasedTriggerContext"),
+					Schedule:   schedule,
+					TaggingCriteria: []*armdataprotection.TaggingCriteria{
+						{
+							IsDefault:       to.Ptr(true),
+							TaggingPriority: to.Ptr[int64](99),
+							TagInfo:         &armdataprotection.RetentionTag{TagName: to.Ptr("Default")},
+						},
+					},
+				},
+			},
+			&armdataprotection.AzureRetentionRule{
+				ObjectType: to.Ptr("AzureRetentionRule"),
+				Name:       to.Ptr("Default"),
+				IsDefault:  to.Ptr(true),
+				Lifecycles: []*armdataprotection.SourceLifeCycle{
+					{
+						SourceDataStore: dataStore,
+						DeleteAfter: &armdataprotection.AbsoluteDeleteOption{
+							ObjectType: to.Ptr("AbsoluteDeleteOption"),
+							Duration:   to.Ptr(backup.RetentionPeriod),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// The backup modules prioritise the retention rules in the order that they're given
+	for index, rule := range backup.RetentionRules {
+		addRetentionRule(policy, rule.Name, int64(index+1), rule.Duration, rule.criteria())
+	}
+
+	return policy
+}
+
+/*
+ * Adds the blob storage module's optional "daily-retention" rule to a policy.
+ */
+func addDailyRetentionRule(policy *armdataprotection.BackupPolicy, retentionPeriod string) {
+	addRetentionRule(policy, "daily-retention", 9, retentionPeriod, &armdataprotection.ScheduleBasedBackupCriteria{
+		ObjectType:       to.Ptr("ScheduleBasedBackupCriteria"),
+		AbsoluteCriteria: []*armdataprotection.AbsoluteMarker{to.Ptr(armdataprotection.AbsoluteMarkerAllBackup)},
+	})
+}
+
+/*
+ * Adds a retention rule to a policy, in the same data store as its Default rule, along with the
+ * tagging criteria that tags backups for it.
+ */
+func addRetentionRule(policy *armdataprotection.BackupPolicy, name string, priority int64, duration string, criteria *armdataprotection.ScheduleBasedBackupCriteria) {
+	backupRule := policy.PolicyRules[0].(*armdataprotection.AzureBackupRule)
+
+	policy.PolicyRules = append(policy.PolicyRules, &armdataprotection.AzureRetentionRule{
+		ObjectType: to.Ptr("AzureRetentionRule"),
+		Name:       to.Ptr(name),
+		IsDefault:  to.Ptr(false),
+		Lifecycles: []*armdataprotection.SourceLifeCycle{
+			{
+				SourceDataStore: backupRule.DataStore,
+				DeleteAfter: &armdataprotection.AbsoluteDeleteOption{
+					ObjectType: to.Ptr("AbsoluteDeleteOption"),
+					Duration:   to.Ptr(duration),
+				},
+			},
+		},
+	})
+
+	trigger := backupRule.Trigger.(*armdataprotection.ScheduleBasedTriggerContext)
+	trigger.TaggingCriteria = append(trigger.TaggingCriteria, &armdataprotection.TaggingCriteria{
+		IsDefault:       to.Ptr(false),
+		TaggingPriority: to.Ptr(priority),
+		TagInfo:         &armdataprotection.RetentionTag{TagName: to.Ptr(name)},
+		Criteria:        []armdataprotection.BackupCriteriaClassification{criteria},
+	})
+}
+
+/*
+ * Gets the tagging criteria of a retention rule, as the backup modules' criteria blocks do.
+ */
+func (rule retentionRule) criteria() *armdataprotection.ScheduleBasedBackupCriteria {
+	criteria := &armdataprotection.ScheduleBasedBackupCriteria{
+		ObjectType: to.Ptr("ScheduleBasedBackupCriteria"),
+	}
+
+	if rule.AbsoluteCriteria != "" {
+		criteria.AbsoluteCriteria = []*armdataprotection.AbsoluteMarker{to.Ptr(armdataprotection.AbsoluteMarker(rule.AbsoluteCriteria))}
+	}
+	for _, day := range rule.DaysOfWeek {
+		criteria.DaysOfTheWeek = append(criteria.DaysOfTheWeek, to.Ptr(armdataprotection.DayOfWeek(day)))
+	}
+	for _, week := range rule.WeeksOfMonth {
+		criteria.WeeksOfTheMonth = append(criteria.WeeksOfTheMonth, to.Ptr(armdataprotection.WeekNumber(week)))
+	}
+	for _, month := range rule.MonthsOfYear {
+		criteria.MonthsOfYear = append(criteria.MonthsOfYear, to.Ptr(armdataprotection.Month(month)))
+	}
+
+	return criteria
+}
+
+func newBackupInstance(resourceID string, datasourceType string, location string, policyID string) *armdataprotection.BackupInstance {
+	segments := strings.Split(resourceID, "/")
+
+	dataSource := &armdataprotection.Datasource{
+		ObjectType:       to.Ptr("Datasource"),
+		ResourceID:       to.Ptr(resourceID),
+		ResourceName:     to.Ptr(segments[len(segments)-1]),
+		ResourceType:     to.Ptr(resourceType(resourceID)),
+		ResourceLocation: to.Ptr(location),
+		ResourceURI:      to.Ptr(resourceID),
+		DatasourceType:   to.Ptr(datasourceType),
+	}
+
+	return &armdataprotection.BackupInstance{
+		ObjectType:     to.Ptr("BackupInstance"),
+		DataSourceInfo: dataSource,
+		PolicyInfo: &armdataprotection.PolicyInfo{
+			PolicyID: to.Ptr(policyID),
+		},
+	}
+}
+
+func (e *Emulator) putBackupPolicy(vaultID string, name string, policy *armdataprotection.BackupPolicy) (string, error) {
+	properties, err := toMap(policy)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode backup policy %s: %w", name, err)
+	}
+
+	stored := e.Put(fmt.Sprintf("%s/backupPolicies/%s", vaultID, name), map[string]any{"properties": properties})
+
+	return stored["id"].(string), nil
+}
+
+func (e *Emulator) putBackupInstance(vaultID string, name string, instance *armdataprotection.BackupInstance) error {
+	instance.FriendlyName = to.Ptr(name)
+	instance.CurrentProtectionState = to.Ptr(armdataprotection.CurrentProtectionStateProtectionConfigured)
+	instance.ProtectionStatus = &armdataprotection.ProtectionStatusDetails{
+		Status: to.Ptr(armdataprotection.StatusProtectionConfigured),
+	}
+
+	properties, err := toMap(instance)
+	if err != nil {
+		return fmt.Errorf("failed to encode backup instance %s: %w", name, err)
+	}
+
+	e.Put(fmt.Sprintf("%s/backupInstances/%s", vaultID, name), map[string]any{"properties": properties})
+
+	return nil
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,297 @@
+package emulator
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"e2e_tests/interval"
+	"e2e_tests/naming"
+)
+
+const (
+	recoveryServicesVaultType = "Microsoft.RecoveryServices/vaults"
+	protectedItemType         = "Microsoft.RecoveryServices/vaults/backupFabrics/protectionContainers/protectedItems"
+)
+
+/*
+ * The attributes shared by the backups in the recovery services vault, which have a policy
+ * but no backup instance.
+ */
+type recoveryServicesBackupCommon struct {
+	BackupName                 string                         `json:"backup_name"`
+	RetentionPeriod            string                         `json:"retention_period"`
+	BackupIntervals            []string                       `json:"backup_intervals"`
+	BackupPolicyNamingTemplate string                         `json:"backup_policy_naming_template"`
+	RetentionRules             recoveryServicesRetentionRules `json:"retention_rules"`
+}
+
+/*
+ * The retention rules of a backup in the recovery services vault, which keep a number of
+ * weekly, monthly and yearly recovery points on top of the daily ones.
+ */
+type recoveryServicesRetentionRules struct {
+	Weekly  *weeklyRetentionRule  `json:"weekly"`
+	Monthly *monthlyRetentionRule `json:"monthly"`
+	Yearly  *yearlyRetentionRule  `json:"yearly"`
+}
+
+type weeklyRetentionRule struct {
+	Count    int      `json:"count"`
+	Weekdays []string `json:"weekdays"`
+}
+
+type monthlyRetentionRule struct {
+	Count    int      `json:"count"`
+	Weekdays []string `json:"weekdays"`
+	Weeks    []string `json:"weeks"`
+}
+
+type yearlyRetentionRule struct {
+	monthlyRetentionRule
+	Months []string `json:"months"`
+}
+
+type vmBackup struct {
+	recoveryServicesBackupCommon
+	VMID string `json:"vm_id"`
+}
+
+type fileShareBackup struct {
+	recoveryServicesBackupCommon
+	StorageAccountID string `json:"storage_account_id"`
+	FileShareName    string `json:"file_share_name"`
+}
+
+/*
+ * Deploys the recovery services vault, with the same diagnostic settings as the backup vault,
+ * and the policies and protected items of the virtual machine and file share backups.
+ */
+func (e *Emulator) applyRecoveryServicesVault(resourceGroupID string, location string, variables *moduleVariables) error {
+	vaultID := fmt.Sprintf("%s/providers/Microsoft.RecoveryServices/vaults/%s", resourceGroupID, variables.RecoveryServicesVaultName)
+
+	softDeleteState := "Disabled"
+	if variables.RecoveryServicesVaultSoftDelete == "On" {
+		softDeleteState = "Enabled"
+	}
+
+	e.Put(vaultID, map[string]any{
+		"location": location,
+		"tags":     variables.Tags,
+		"sku":      map[string]any{"name": "Standard"},
+		"identity": map[string]any{
+			"type":        "SystemAssigned",
+			"principalId": newUUID(),
+			"tenantId":    TenantID,
+		},
+		"properties": map[string]any{
+			"redundancySettings": map[string]any{
+				"standardTierStorageRedundancy": variables.RecoveryServicesVaultRedundancy,
+			},
+			"securitySettings": map[string]any{
+				"immutabilitySettings": map[string]any{"state": variables.RecoveryServicesVaultImmutability},
+				"softDeleteSettings":   map[string]any{"softDeleteState": softDeleteState, "softDeleteRetentionPeriodInDays": 14},
+			},
+		},
+	})
+
+	e.putVaultDiagnosticSettings(vaultID, variables.RecoveryServicesVaultName, variables.LogAnalyticsWorkspaceID)
+
+	for _, key := range sortedKeys(variables.VMBackups) {
+		backup := variables.VMBackups[key]
+
+		policyID, err := e.putRecoveryServicesPolicy(vaultID, backup.names(naming.ResourceTypeVirtualMachine).PolicyName(), "AzureIaasVM", backup.recoveryServicesBackupCommon)
+		if err != nil {
+			return err
+		}
+
+		segments := strings.Split(backup.VMID, "/")
+		resourceGroupName, vmName := segments[4], segments[len(segments)-1]
+
+		e.Put(protectedItemID(vaultID, "iaasvmcontainer;iaasvmcontainerv2;"+resourceGroupName+";"+vmName, "vm;iaasvmcontainerv2;"+resourceGroupName+";"+vmName), map[string]any{
+			"properties": map[string]any{
+				"protectedItemType":    "Microsoft.Compute/virtualMachines",
+				"backupManagementType": "AzureIaasVM",
+				"workloadType":         "VM",
+				"sourceResourceId":     backup.VMID,
+				"virtualMachineId":     backup.VMID,
+				"friendlyName":         vmName,
+				"policyId":             policyID,
+				"protectionState":      "Protected",
+				"protectionStatus":     "Healthy",
+			},
+		})
+	}
+
+	for _, key := range sortedKeys(variables.FileShareBackups) {
+		backup := variables.FileShareBackups[key]
+
+		policyID, err := e.putRecoveryServicesPolicy(vaultID, backup.names(naming.ResourceTypeFileShare).PolicyName(), "AzureStorage", backup.recoveryServicesBackupCommon)
+		if err != nil {
+			return err
+		}
+
+		segments := strings.Split(backup.StorageAccountID, "/")
+		resourceGroupName, storageAccountName := segments[4], segments[len(segments)-1]
+		containerName := "StorageContainer;Storage;" + resourceGroupName + ";" + storageAccountName
+
+		// The module registers each storage account with the vault once, whichever share is backed up
+		e.Put(fmt.Sprintf("%s/backupFabrics/Azure/protectionContainers/%s", vaultID, containerName), map[string]any{
+			"properties": map[string]any{
+				"containerType":        "StorageContainer",
+				"backupManagementType": "AzureStorage",
+				"sourceResourceId":     backup.StorageAccountID,
+				"friendlyName":         storageAccountName,
+				"registrationStatus":   "Registered",
+			},
+		})
+
+		e.Put(protectedItemID(vaultID, containerName, "AzureFileShare;"+backup.FileShareName), map[string]any{
+			"properties": map[string]any{
+				"protectedItemType":    "AzureFileShareProtectedItem",
+				"backupManagementType": "AzureStorage",
+				"workloadType":         "AzureFileShare",
+				"sourceResourceId":     backup.StorageAccountID,
+				"friendlyName":         backup.FileShareName,
+				"policyId":             policyID,
+				"protectionState":      "Protected",
+				"protectionStatus":     "Healthy",
+			},
+		})
+	}
+
+	return nil
+}
+
+/*
+ * Puts a recovery services policy which runs once a day, at the time of day that the backup's
+ * interval starts (in UTC), and keeps a number of daily recovery points, as the
+ * virtual_machine and file_share modules do.
+ */
+func (e *Emulator) putRecoveryServicesPolicy(vaultID string, name string, backupManagementType string, backup recoveryServicesBackupCommon) (string, error) {
+	if len(backup.BackupIntervals) != 1 {
+		return "", fmt.Errorf("backup policy %s must have exactly one backup interval", name)
+	}
+
+	backupInterval, err := interval.Parse(backup.BackupIntervals[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to parse backup interval of backup policy %s: %w", name, err)
+	}
+
+	days, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(backup.RetentionPeriod, "P"), "D"))
+	if err != nil {
+		return "", fmt.Errorf("backup policy %s has a retention period which isn't a number of days: %s", name, backup.RetentionPeriod)
+	}
+
+	runTime := backupInterval.Start.UTC().Format(time.RFC3339)
+
+	properties := map[string]any{
+		"backupManagementType": backupManagementType,
+		"timeZone":             "UTC",
+		"schedulePolicy": map[string]any{
+			"schedulePolicyType":   "SimpleSchedulePolicy",
+			"scheduleRunFrequency": "Daily",
+			"scheduleRunTimes":     []any{runTime},
+		},
+		"retentionPolicy": retentionPolicy(runTime, days, backup.RetentionRules),
+	}
+	if backupManagementType == "AzureStorage" {
+		properties["workLoadType"] = "AzureFileShare"
+	}
+
+	stored := e.Put(fmt.Sprintf("%s/backupPolicies/%s", vaultID, name), map[string]any{"properties": properties})
+
+	return stored["id"].(string), nil
+}
+
+/*
+ * Builds the long term retention policy of a recovery services policy, which keeps the daily
+ * recovery points and any weekly, monthly and yearly ones that the retention rules keep, as
+ * the retention_* blocks of the recovery services modules' backup_policy.tf do.
+ */
+func retentionPolicy(runTime string, days int, rules recoveryServicesRetentionRules) map[string]any {
+	policy := map[string]any{
+		"retentionPolicyType": "LongTermRetentionPolicy",
+		"dailySchedule": map[string]any{
+			"retentionTimes":    []any{runTime},
+			"retentionDuration": map[string]any{"count": days, "durationType": "Days"},
+		},
+	}
+
+	if rule := rules.Weekly; rule != nil {
+		policy["weeklySchedule"] = map[string]any{
+			"daysOfTheWeek":     rule.Weekdays,
+			"retentionTimes":    []any{runTime},
+			"retentionDuration": map[string]any{"count": rule.Count, "durationType": "Weeks"},
+		}
+	}
+
+	if rule := rules.Monthly; rule != nil {
+		policy["monthlySchedule"] = map[string]any{
+			"retentionScheduleFormatType": "Weekly",
+			"retentionScheduleWeekly":     map[string]any{"daysOfTheWeek": rule.Weekdays, "weeksOfTheMonth": rule.Weeks},
+			"retentionTimes":              []any{runTime},
+			"retentionDuration":           map[string]any{"count": rule.Count, "durationType": "Months"},
+		}
+	}
+
+	if rule := rules.Yearly; rule != nil {
+		policy["yearlySchedule"] = map[string]any{
+			"retentionScheduleFormatType": "Weekly",
+			"monthsOfYear":                rule.Months,
+			"retentionScheduleWeekly":     map[string]any{"daysOfTheWeek": rule.Weekdays, "weeksOfTheMonth": rule.Weeks},
+			"retentionTimes":              []any{runTime},
+			"retentionDuration":           map[string]any{"count": rule.Count, "durationType": "Years"},
+		}
+	}
+
+	return policy
+}
+
+/*
+ * Gets the backup's names, rendered in the same way as the recovery services modules' locals.tf.
+ */
+func (backup recoveryServicesBackupCommon) names(resourceType string) naming.Backup {
+	return naming.Backup{
+		ResourceType:         resourceType,
+		BackupName:           backup.BackupName,
+		PolicyNamingTemplate: backup.BackupPolicyNamingTemplate,
+	}
+}
+
+func protectedItemID(vaultID string, containerName string, itemName string) string {
+	return fmt.Sprintf("%s/backupFabrics/Azure/protectionContainers/%s/protectedItems/%s", vaultID, containerName, itemName)
+}
+
+/*
+ * Lists every protected item in a recovery services vault, across its fabrics and protection
+ * containers, as the backupProtectedItems API does.
+ */
+func (e *Emulator) listProtectedItems(w http.ResponseWriter, vaultID string) {
+	if e.Get(vaultID) == nil {
+		writeNotFound(w, vaultID)
+		return
+	}
+
+	prefix := strings.ToLower(cleanPath(vaultID)) + "/"
+
+	items := []map[string]any{}
+	for _, item := range e.resourcesOfType(protectedItemType) {
+		if strings.HasPrefix(strings.ToLower(item["id"].(string)), prefix) {
+			items = append(items, item)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"value": items})
+}
+
+/*
+ * Reports whether a path is the protected items collection of a recovery services vault.
+ */
+func isProtectedItems(path string) bool {
+	parent, ok := strings.CutSuffix(strings.ToLower(cleanPath(path)), "/backupprotecteditems")
+
+	return ok && strings.EqualFold(resourceType(parent), recoveryServicesVaultType)
+}
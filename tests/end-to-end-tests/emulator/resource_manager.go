@@ -0,0 +1,493 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+ * Serves Azure Resource Manager requests. Anything without a dedicated handler falls through
+ * to a generic resource store, so that PUT, GET, PATCH and DELETE behave as ARM does for
+ * any resource type, and a GET on a collection lists the resources within it.
+ */
+func (e *Emulator) serveResourceManager(w http.ResponseWriter, r *http.Request) {
+	path := cleanPath(r.URL.Path)
+	lowerPath := strings.ToLower(path)
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(lowerPath, "/providers/microsoft.authorization/roledefinitions"):
+		e.listRoleDefinitions(w, r)
+		return
+	case r.Method == http.MethodGet && strings.HasSuffix(lowerPath, "/providers/microsoft.authorization/roleassignments"):
+		e.listRoleAssignments(w, r, path[:len(path)-len("/providers/microsoft.authorization/roleassignments")])
+		return
+	case r.Method == http.MethodPut && strings.EqualFold(resourceType(path), roleAssignmentType):
+		e.createRoleAssignment(w, r, path)
+		return
+	case r.Method == http.MethodPut && strings.EqualFold(resourceType(path), workspaceType):
+		e.putWorkspace(w, r, path)
+		return
+	case r.Method == http.MethodPut && strings.EqualFold(resourceType(path), diskType):
+		e.putDisk(w, r, path)
+		return
+	case r.Method == http.MethodPut && strings.EqualFold(resourceType(path), managedClusterType):
+		e.putManagedCluster(w, r, path)
+		return
+	case r.Method == http.MethodPost && isResourceAction(path, diskType, "beginGetAccess"):
+		e.grantDiskAccess(w, r, path[:len(path)-len("/beginGetAccess")])
+		return
+	case r.Method == http.MethodPost && isResourceAction(path, diskType, "endGetAccess"):
+		e.revokeDiskAccess(w, path[:len(path)-len("/endGetAccess")])
+		return
+	case r.Method == http.MethodPost && isBackupInstanceAction(path, "backup"):
+		e.adhocBackup(w, path[:len(path)-len("/backup")])
+		return
+	case r.Method == http.MethodPost && isBackupInstanceAction(path, "validateRestore"):
+		e.validateRestore(w, r, path[:len(path)-len("/validateRestore")])
+		return
+	case r.Method == http.MethodPost && isBackupInstanceAction(path, "restore"):
+		e.restore(w, r, path[:len(path)-len("/restore")])
+		return
+	case r.Method == http.MethodDelete && strings.EqualFold(resourceType(path), backupInstanceType):
+		e.deleteBackupInstance(w, path)
+		return
+	case r.Method == http.MethodPatch && strings.EqualFold(resourceType(path), backupVaultType):
+		e.patchBackupVault(w, r, path)
+		return
+	case r.Method == http.MethodGet && isProtectedItems(path):
+		e.listProtectedItems(w, path[:len(path)-len("/backupProtectedItems")])
+		return
+	case r.Method == http.MethodGet && isResourceGroupResources(path):
+		e.listResourceGroupResources(w, r, path[:len(path)-len("/resources")])
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		e.getResource(w, path)
+	case http.MethodPut:
+		e.putResource(w, r, path)
+	case http.MethodPatch:
+		e.patchResource(w, r, path)
+	case http.MethodDelete:
+		e.deleteResource(w, path)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", fmt.Sprintf("%s is not supported by the emulator for %s", r.Method, path))
+	}
+}
+
+func (e *Emulator) getResource(w http.ResponseWriter, path string) {
+	if resource := e.Get(path); resource != nil {
+		writeJSON(w, http.StatusOK, resource)
+		return
+	}
+
+	if isCollection(path) {
+		writeJSON(w, http.StatusOK, map[string]any{"value": e.List(path)})
+		return
+	}
+
+	writeNotFound(w, path)
+}
+
+func (e *Emulator) putResource(w http.ResponseWriter, r *http.Request, path string) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	if parent := resourceGroupID(path); parent != "" && parent != strings.ToLower(path) && e.Get(parent) == nil {
+		writeError(w, http.StatusNotFound, "ResourceGroupNotFound", fmt.Sprintf("Resource group for '%s' could not be found.", path))
+		return
+	}
+
+	// Every client used by the tests accepts a 200 for a create, whereas not all of them accept a 201
+	writeJSON(w, http.StatusOK, e.Put(path, body))
+}
+
+func (e *Emulator) patchResource(w http.ResponseWriter, r *http.Request, path string) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+		return
+	}
+
+	resource := e.Get(path)
+	if resource == nil {
+		writeNotFound(w, path)
+		return
+	}
+
+	mergeMaps(resource, body)
+
+	writeJSON(w, http.StatusOK, e.Put(path, resource))
+}
+
+func (e *Emulator) deleteResource(w http.ResponseWriter, path string) {
+	if !e.Delete(path) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+/*
+ * Gets a copy of the resource for the provided ID, or nil if it doesn't exist.
+ */
+func (e *Emulator) Get(id string) map[string]any {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	resource, ok := e.resources[strings.ToLower(cleanPath(id))]
+	if !ok {
+		return nil
+	}
+
+	return copyMap(resource)
+}
+
+/*
+ * Lists copies of the resources that are direct children of the provided collection path.
+ */
+func (e *Emulator) List(collection string) []map[string]any {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	prefix := strings.ToLower(cleanPath(collection)) + "/"
+
+	var keys []string
+	for key := range e.resources {
+		if strings.HasPrefix(key, prefix) && !strings.Contains(key[len(prefix):], "/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	resources := []map[string]any{}
+	for _, key := range keys {
+		resources = append(resources, copyMap(e.resources[key]))
+	}
+
+	return resources
+}
+
+/*
+ * Creates or replaces the resource for the provided ID, filling in the read-only
+ * properties that ARM would return, and returns a copy of the stored resource.
+ */
+func (e *Emulator) Put(id string, resource map[string]any) map[string]any {
+	id = cleanPath(id)
+	segments := strings.Split(strings.Trim(id, "/"), "/")
+
+	stored := copyMap(resource)
+	stored["id"] = id
+	stored["name"] = segments[len(segments)-1]
+	stored["type"] = resourceType(id)
+
+	properties, ok := stored["properties"].(map[string]any)
+	if !ok || stored["type"] == resourceGroupType {
+		properties = map[string]any{}
+		stored["properties"] = properties
+	}
+	properties["provisioningState"] = "Succeeded"
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := strings.ToLower(id)
+	e.resources[key] = stored
+	if _, ok := e.created[key]; !ok {
+		e.created[key] = time.Now().UTC()
+	}
+
+	return copyMap(stored)
+}
+
+/*
+ * Deletes the resource for the provided ID along with every resource nested beneath it,
+ * and reports whether anything was deleted.
+ */
+func (e *Emulator) Delete(id string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := strings.ToLower(cleanPath(id))
+	deleted := false
+
+	for existing := range e.resources {
+		if existing == key || strings.HasPrefix(existing, key+"/") {
+			delete(e.resources, existing)
+			delete(e.created, existing)
+			deleted = true
+		}
+	}
+
+	return deleted
+}
+
+/*
+ * Finds copies of every stored resource of the provided type.
+ */
+func (e *Emulator) resourcesOfType(typeName string) []map[string]any {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var keys []string
+	for key, resource := range e.resources {
+		if strings.EqualFold(resource["type"].(string), typeName) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var resources []map[string]any
+	for _, key := range keys {
+		resources = append(resources, copyMap(e.resources[key]))
+	}
+
+	return resources
+}
+
+/*
+ * Lists the top level resources within a resource group, as the resources API does. The time
+ * each resource was created is included when it's requested with $expand=createdTime.
+ */
+func (e *Emulator) listResourceGroupResources(w http.ResponseWriter, r *http.Request, resourceGroupID string) {
+	if e.Get(resourceGroupID) == nil {
+		writeError(w, http.StatusNotFound, "ResourceGroupNotFound", fmt.Sprintf("Resource group '%s' could not be found.", resourceGroupID))
+		return
+	}
+
+	expandCreatedTime := strings.Contains(strings.ToLower(r.URL.Query().Get("$expand")), "createdtime")
+	prefix := strings.ToLower(cleanPath(resourceGroupID)) + "/providers/"
+
+	e.mu.Lock()
+	var keys []string
+	for key := range e.resources {
+		// Top level resources are a provider namespace, type and name beneath the group
+		if name, ok := strings.CutPrefix(key, prefix); ok && strings.Count(name, "/") == 2 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	resources := []map[string]any{}
+	for _, key := range keys {
+		resource := map[string]any{
+			"id":       e.resources[key]["id"],
+			"name":     e.resources[key]["name"],
+			"type":     e.resources[key]["type"],
+			"location": e.resources[key]["location"],
+		}
+		if expandCreatedTime {
+			resource["createdTime"] = e.created[key].Format(time.RFC3339Nano)
+		}
+		resources = append(resources, resource)
+	}
+	e.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{"value": resources})
+}
+
+/*
+ * Reports whether a path is the resources collection of a resource group.
+ */
+func isResourceGroupResources(path string) bool {
+	segments := strings.Split(strings.Trim(cleanPath(path), "/"), "/")
+
+	return len(segments) == 5 && strings.EqualFold(segments[0], "subscriptions") &&
+		strings.EqualFold(segments[2], "resourceGroups") && strings.EqualFold(segments[4], "resources")
+}
+
+const (
+	resourceGroupType  = "Microsoft.Resources/resourceGroups"
+	backupVaultType    = "Microsoft.DataProtection/backupVaults"
+	backupInstanceType = "Microsoft.DataProtection/backupVaults/backupInstances"
+	backupJobType      = "Microsoft.DataProtection/backupVaults/backupJobs"
+	recoveryPointType  = "Microsoft.DataProtection/backupVaults/backupInstances/recoveryPoints"
+	roleAssignmentType = "Microsoft.Authorization/roleAssignments"
+	diskType           = "Microsoft.Compute/disks"
+)
+
+/*
+ * Works out the ARM resource type for a resource ID, e.g. Microsoft.Storage/storageAccounts.
+ * Extension resources (such as diagnostic settings) take the type of the last provider.
+ */
+func resourceType(id string) string {
+	segments := strings.Split(strings.Trim(cleanPath(id), "/"), "/")
+
+	providerIndex := -1
+	for i, segment := range segments {
+		if strings.EqualFold(segment, "providers") {
+			providerIndex = i
+		}
+	}
+
+	if providerIndex == -1 || providerIndex+1 >= len(segments) {
+		if len(segments) >= 4 && strings.EqualFold(segments[2], "resourceGroups") {
+			return resourceGroupType
+		}
+		return "Microsoft.Resources/subscriptions"
+	}
+
+	typeName := segments[providerIndex+1]
+	for i := providerIndex + 2; i < len(segments); i += 2 {
+		typeName += "/" + segments[i]
+	}
+
+	return typeName
+}
+
+/*
+ * Reports whether a path refers to a collection of resources rather than a single resource.
+ */
+func isCollection(path string) bool {
+	segments := strings.Split(strings.Trim(cleanPath(path), "/"), "/")
+
+	start := 0
+	for i, segment := range segments {
+		if strings.EqualFold(segment, "providers") {
+			start = i + 2
+		}
+	}
+
+	return (len(segments)-start)%2 == 1
+}
+
+/*
+ * Gets the lower-cased ID of the resource group that contains the provided resource, or an
+ * empty string if the resource isn't within a resource group.
+ */
+func resourceGroupID(id string) string {
+	segments := strings.Split(strings.Trim(cleanPath(id), "/"), "/")
+	if len(segments) < 4 || !strings.EqualFold(segments[0], "subscriptions") || !strings.EqualFold(segments[2], "resourceGroups") {
+		return ""
+	}
+
+	return strings.ToLower("/" + strings.Join(segments[:4], "/"))
+}
+
+/*
+ * Reports whether a path is an action (e.g. /backup) on a backup instance.
+ */
+func isBackupInstanceAction(path string, action string) bool {
+	return isResourceAction(path, backupInstanceType, action)
+}
+
+/*
+ * Reports whether a path is an action (e.g. /beginGetAccess) on a resource of the provided type.
+ */
+func isResourceAction(path string, typeName string, action string) bool {
+	suffix := "/" + action
+	if len(path) <= len(suffix) || !strings.EqualFold(path[len(path)-len(suffix):], suffix) {
+		return false
+	}
+
+	return strings.EqualFold(resourceType(path[:len(path)-len(suffix)]), typeName)
+}
+
+/*
+ * Collapses repeated slashes - clients join an empty scope onto the endpoint, which
+ * produces paths such as //providers/Microsoft.Authorization/roleDefinitions.
+ */
+func cleanPath(path string) string {
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+
+	return "/" + strings.Trim(path, "/")
+}
+
+func readBody(r *http.Request) (map[string]any, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]any{}
+	if len(data) == 0 {
+		return body, nil
+	}
+
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("request body is not valid JSON: %w", err)
+	}
+
+	return body, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+func writeNotFound(w http.ResponseWriter, path string) {
+	if resourceType(path) == resourceGroupType {
+		writeError(w, http.StatusNotFound, "ResourceGroupNotFound", fmt.Sprintf("Resource group '%s' could not be found.", path))
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("The resource '%s' was not found.", path))
+}
+
+/*
+ * Deep copies a decoded JSON object, so callers can't mutate the store.
+ */
+func copyMap(source map[string]any) map[string]any {
+	data, _ := json.Marshal(source)
+
+	copied := map[string]any{}
+	_ = json.Unmarshal(data, &copied)
+
+	return copied
+}
+
+/*
+ * Merges a JSON merge-patch style document into the target, as ARM does for PATCH requests.
+ */
+func mergeMaps(target map[string]any, patch map[string]any) {
+	for key, value := range patch {
+		patchObject, isObject := value.(map[string]any)
+		targetObject, targetIsObject := target[key].(map[string]any)
+
+		if isObject && targetIsObject {
+			mergeMaps(targetObject, patchObject)
+		} else {
+			target[key] = value
+		}
+	}
+}
+
+/*
+ * Converts a typed SDK model into the decoded JSON form held by the store.
+ */
+func toMap(model any) (map[string]any, error) {
+	data, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := map[string]any{}
+	if err := json.Unmarshal(data, &converted); err != nil {
+		return nil, err
+	}
+
+	return converted, nil
+}
@@ -5,7 +5,7 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/gruntwork-io/terratest/modules/random"
@@ -22,11 +22,11 @@ type TestExistingResourceGroupExternalResources struct {
 /*
  * Creates resources which are "external" to the az-backup module.
  */
-func setupExternalResourcesForExistingResourceGroupTest(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestExistingResourceGroupExternalResources {
-	resourceGroup := CreateResourceGroup(t, credential, subscriptionID, resourceGroupName, resourceGroupLocation)
+func setupExternalResourcesForExistingResourceGroupTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestExistingResourceGroupExternalResources {
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, resourceGroupName, resourceGroupLocation)
 
 	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s", strings.ToLower(uniqueId))
-	logAnalyticsWorkspace := CreateLogAnalyticsWorkspace(t, credential, subscriptionID, resourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, resourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
 
 	externalResources := &TestExistingResourceGroupExternalResources{
 		ResourceGroup:         resourceGroup,
@@ -42,8 +42,8 @@ func setupExternalResourcesForExistingResourceGroupTest(t *testing.T, credential
 func TestExistingResourceGroup(t *testing.T) {
 	t.Parallel()
 
-	environment := GetEnvironmentConfiguration(t)
-	credential := GetAzureCredential(t, environment)
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
 
 	uniqueId := random.UniqueId()
 	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
@@ -58,9 +58,9 @@ func TestExistingResourceGroup(t *testing.T) {
 	defer test_structure.RunTestStage(t, "teardown", func() {
 		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
 
-		terraform.Destroy(t, terraformOptions)
+		DestroyTerraform(t, environment, terraformOptions)
 
-		DeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
 	})
 
 	// Setup stage
@@ -89,7 +89,7 @@ func TestExistingResourceGroup(t *testing.T) {
 		// Save options for later test stages
 		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
 
-		terraform.InitAndApply(t, terraformOptions)
+		ApplyTerraform(t, environment, terraformOptions)
 	})
 
 	// Validate stage
@@ -97,7 +97,7 @@ func TestExistingResourceGroup(t *testing.T) {
 
 	test_structure.RunTestStage(t, "validate", func() {
 		// Validate resource group
-		resourceGroup := GetResourceGroup(t, environment.SubscriptionID, credential, resourceGroupName)
+		resourceGroup := MustGetResourceGroup(t, credential, environment.SubscriptionID, resourceGroupName)
 		assert.NotNil(t, resourceGroup, "Resource group does not exist")
 		assert.Equal(t, resourceGroupName, *resourceGroup.Name, "Resource group name does not match")
 		assert.Equal(t, resourceGroupLocation, *resourceGroup.Location, "Resource group location does not match")
@@ -0,0 +1,61 @@
+package exporter
+
+import (
+	"context"
+
+	"e2e_tests/azure"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+)
+
+/*
+ * Client reads the state of backup vaults, and is implemented against Azure by NewClient.
+ */
+type Client interface {
+	GetBackupVault(ctx context.Context, resourceGroupName string, backupVaultName string) (*armdataprotection.BackupVaultResource, error)
+	ListBackupPolicies(ctx context.Context, resourceGroupName string, backupVaultName string) ([]*armdataprotection.BaseBackupPolicyResource, error)
+	ListBackupInstances(ctx context.Context, resourceGroupName string, backupVaultName string) ([]*armdataprotection.BackupInstanceResource, error)
+	ListBackupJobs(ctx context.Context, resourceGroupName string, backupVaultName string) ([]*armdataprotection.AzureBackupJobResource, error)
+	ListRecoveryPoints(ctx context.Context, resourceGroupName string, backupVaultName string, backupInstanceName string) ([]*armdataprotection.AzureBackupRecoveryPointResource, error)
+}
+
+type azureClient struct {
+	credential     azcore.TokenCredential
+	clientOptions  *arm.ClientOptions
+	subscriptionID string
+}
+
+/*
+ * NewClient creates a client which reads backup vaults in the provided subscription through
+ * the armdataprotection clients.
+ */
+func NewClient(credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string) Client {
+	return &azureClient{credential: credential, clientOptions: clientOptions, subscriptionID: subscriptionID}
+}
+
+func (client *azureClient) GetBackupVault(ctx context.Context, resourceGroupName string, backupVaultName string) (*armdataprotection.BackupVaultResource, error) {
+	vault, err := azure.GetBackupVault(ctx, client.credential, client.clientOptions, client.subscriptionID, resourceGroupName, backupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vault, nil
+}
+
+func (client *azureClient) ListBackupPolicies(ctx context.Context, resourceGroupName string, backupVaultName string) ([]*armdataprotection.BaseBackupPolicyResource, error) {
+	return azure.GetBackupPolicies(ctx, client.credential, client.clientOptions, client.subscriptionID, resourceGroupName, backupVaultName)
+}
+
+func (client *azureClient) ListBackupInstances(ctx context.Context, resourceGroupName string, backupVaultName string) ([]*armdataprotection.BackupInstanceResource, error) {
+	return azure.GetBackupInstances(ctx, client.credential, client.clientOptions, client.subscriptionID, resourceGroupName, backupVaultName)
+}
+
+func (client *azureClient) ListBackupJobs(ctx context.Context, resourceGroupName string, backupVaultName string) ([]*armdataprotection.AzureBackupJobResource, error) {
+	return azure.ListBackupJobs(ctx, client.credential, client.clientOptions, client.subscriptionID, resourceGroupName, backupVaultName)
+}
+
+func (client *azureClient) ListRecoveryPoints(ctx context.Context, resourceGroupName string, backupVaultName string, backupInstanceName string) ([]*armdataprotection.AzureBackupRecoveryPointResource, error) {
+	return azure.GetRecoveryPoints(ctx, client.credential, client.clientOptions, client.subscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+}
@@ -0,0 +1,420 @@
+/*
+ * Package exporter exposes the backup posture of backup vaults as Prometheus metrics. The
+ * vaults are read in the background at an interval, rather than on every scrape, so that
+ * frequent scrapes don't run into Azure's request limits.
+ */
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "azbackup"
+
+// The operation category of backup jobs, whether scheduled or ad-hoc
+const operationCategoryBackup = "Backup"
+
+var (
+	vaultLabels    = []string{"resource_group", "vault"}
+	instanceLabels = []string{"resource_group", "vault", "backup_instance"}
+
+	refreshSuccessDesc = prometheus.NewDesc(namespace+"_refresh_success",
+		"Whether the vault was read successfully at the last refresh (1) or not (0).", vaultLabels, nil)
+	refreshTimestampDesc = prometheus.NewDesc(namespace+"_refresh_timestamp_seconds",
+		"When the vault was last read, successfully or not.", vaultLabels, nil)
+	vaultHealthyDesc = prometheus.NewDesc(namespace+"_vault_healthy",
+		"Whether the vault is provisioned and every backup instance has protection configured (1) or not (0).", vaultLabels, nil)
+	vaultProvisioningStateDesc = prometheus.NewDesc(namespace+"_vault_provisioning_state",
+		"The provisioning state of the vault, as 1 for the current state and 0 for the others.", append(vaultLabels, "state"), nil)
+	vaultImmutabilityStateDesc = prometheus.NewDesc(namespace+"_vault_immutability_state",
+		"The immutability state of the vault, as 1 for the current state and 0 for the others.", append(vaultLabels, "state"), nil)
+	vaultSoftDeleteStateDesc = prometheus.NewDesc(namespace+"_vault_soft_delete_state",
+		"The soft delete state of the vault, as 1 for the current state and 0 for the others.", append(vaultLabels, "state"), nil)
+	backupPoliciesDesc = prometheus.NewDesc(namespace+"_backup_policies",
+		"The number of backup policies in the vault.", vaultLabels, nil)
+	backupInstancesDesc = prometheus.NewDesc(namespace+"_backup_instances",
+		"The number of backup instances in the vault.", vaultLabels, nil)
+	protectionStatusDesc = prometheus.NewDesc(namespace+"_backup_instance_protection_status",
+		"The protection status of the backup instance, as 1 for the current status and 0 for the others.", append(instanceLabels, "status"), nil)
+	lastJobStatusDesc = prometheus.NewDesc(namespace+"_backup_instance_last_backup_job_status",
+		"The status of the most recent backup job of the backup instance, which is always 1.", append(instanceLabels, "status"), nil)
+	lastJobSucceededDesc = prometheus.NewDesc(namespace+"_backup_instance_last_backup_job_succeeded",
+		"Whether the most recent completed backup job of the backup instance succeeded (1) or not (0).", instanceLabels, nil)
+	lastJobDurationDesc = prometheus.NewDesc(namespace+"_backup_instance_last_backup_job_duration_seconds",
+		"How long the most recent completed backup job of the backup instance took.", instanceLabels, nil)
+	recoveryPointTimestampDesc = prometheus.NewDesc(namespace+"_backup_instance_latest_recovery_point_timestamp_seconds",
+		"When the newest recovery point of the backup instance was taken.", instanceLabels, nil)
+	recoveryPointAgeDesc = prometheus.NewDesc(namespace+"_backup_instance_latest_recovery_point_age_seconds",
+		"How old the newest recovery point of the backup instance is.", instanceLabels, nil)
+)
+
+/*
+ * Vault identifies a backup vault to export metrics for.
+ */
+type Vault struct {
+	ResourceGroupName string
+	BackupVaultName   string
+}
+
+/*
+ * ParseVault parses a vault written as <resource-group>/<vault-name>.
+ */
+func ParseVault(value string) (Vault, error) {
+	resourceGroupName, backupVaultName, ok := strings.Cut(value, "/")
+	if !ok || resourceGroupName == "" || backupVaultName == "" || strings.Contains(backupVaultName, "/") {
+		return Vault{}, fmt.Errorf("vault '%s' must be written as <resource-group>/<vault-name>", value)
+	}
+
+	return Vault{ResourceGroupName: resourceGroupName, BackupVaultName: backupVaultName}, nil
+}
+
+func (vault Vault) String() string {
+	return vault.ResourceGroupName + "/" + vault.BackupVaultName
+}
+
+/*
+ * The state of a vault as of the last refresh. When the vault couldn't be read, err is set and
+ * the rest is left as it was at the last successful refresh, so that a transient error doesn't
+ * make metrics disappear from dashboards.
+ */
+type vaultState struct {
+	refreshedAt       time.Time
+	err               error
+	read              bool
+	provisioningState string
+	immutabilityState string
+	softDeleteState   string
+	policies          int
+	instances         []instanceState
+}
+
+type instanceState struct {
+	name             string
+	protectionStatus string
+	lastJob          *armdataprotection.AzureBackupJob
+	lastCompletedJob *armdataprotection.AzureBackupJob
+	newestRecovery   *time.Time
+}
+
+/*
+ * Exporter reads the configured vaults through a client, and collects metrics from what it
+ * last read. It implements prometheus.Collector.
+ */
+type Exporter struct {
+	client Client
+	vaults []Vault
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	states map[Vault]*vaultState
+}
+
+/*
+ * New creates an exporter for the provided vaults. Nothing is read until Refresh or Run is
+ * called.
+ */
+func New(client Client, vaults []Vault) *Exporter {
+	return &Exporter{
+		client: client,
+		vaults: vaults,
+		now:    time.Now,
+		states: map[Vault]*vaultState{},
+	}
+}
+
+/*
+ * Refresh reads every vault, returning the errors for vaults that couldn't be read. Vaults are
+ * read one after the other to keep within Azure's request limits.
+ */
+func (exporter *Exporter) Refresh(ctx context.Context) []error {
+	var errs []error
+
+	for _, vault := range exporter.vaults {
+		refreshedAt := exporter.now()
+		state, err := exporter.readVault(ctx, vault)
+
+		exporter.mu.Lock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read vault %s: %w", vault, err))
+
+			previous, ok := exporter.states[vault]
+			if !ok {
+				previous = &vaultState{}
+			}
+			state = previous
+		}
+		state.refreshedAt = refreshedAt
+		state.err = err
+		exporter.states[vault] = state
+		exporter.mu.Unlock()
+	}
+
+	return errs
+}
+
+/*
+ * Run refreshes the vaults straight away and then at every interval until the context is done,
+ * passing the errors from each refresh to the handler. It returns the context's error once it's
+ * done.
+ */
+func (exporter *Exporter) Run(ctx context.Context, every time.Duration, handle func(errs []error)) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		handle(exporter.Refresh(ctx))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+ * Handler returns an HTTP handler which serves the exporter's metrics.
+ */
+func (exporter *Exporter) Handler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exporter)
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func (exporter *Exporter) readVault(ctx context.Context, vault Vault) (*vaultState, error) {
+	backupVault, err := exporter.client.GetBackupVault(ctx, vault.ResourceGroupName, vault.BackupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := exporter.client.ListBackupPolicies(ctx, vault.ResourceGroupName, vault.BackupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := exporter.client.ListBackupInstances(ctx, vault.ResourceGroupName, vault.BackupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := exporter.client.ListBackupJobs(ctx, vault.ResourceGroupName, vault.BackupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &vaultState{read: true, policies: len(policies)}
+
+	if properties := backupVault.Properties; properties != nil {
+		state.provisioningState = stringOf(properties.ProvisioningState)
+
+		if security := properties.SecuritySettings; security != nil {
+			if security.ImmutabilitySettings != nil {
+				state.immutabilityState = stringOf(security.ImmutabilitySettings.State)
+			}
+			if security.SoftDeleteSettings != nil {
+				state.softDeleteState = stringOf(security.SoftDeleteSettings.State)
+			}
+		}
+	}
+
+	for _, instance := range instances {
+		instanceState := instanceState{name: stringOf(instance.Name)}
+
+		if instance.Properties != nil && instance.Properties.ProtectionStatus != nil {
+			instanceState.protectionStatus = stringOf(instance.Properties.ProtectionStatus.Status)
+		}
+
+		instanceState.lastJob, instanceState.lastCompletedJob = lastBackupJobs(jobs, stringOf(instance.ID))
+
+		recoveryPoints, err := exporter.client.ListRecoveryPoints(ctx, vault.ResourceGroupName, vault.BackupVaultName, instanceState.name)
+		if err != nil {
+			return nil, err
+		}
+		instanceState.newestRecovery = newestRecoveryPointTime(recoveryPoints)
+
+		state.instances = append(state.instances, instanceState)
+	}
+
+	return state, nil
+}
+
+/*
+ * Gets the most recent backup job of a backup instance, and the most recent one that has
+ * finished.
+ */
+func lastBackupJobs(jobs []*armdataprotection.AzureBackupJobResource, backupInstanceID string) (last *armdataprotection.AzureBackupJob, lastCompleted *armdataprotection.AzureBackupJob) {
+	for _, job := range jobs {
+		properties := job.Properties
+		if properties == nil || properties.StartTime == nil ||
+			!strings.EqualFold(stringOf(properties.OperationCategory), operationCategoryBackup) ||
+			!strings.EqualFold(stringOf(properties.BackupInstanceID), backupInstanceID) {
+			continue
+		}
+
+		if last == nil || properties.StartTime.After(*last.StartTime) {
+			last = properties
+		}
+
+		if wait.JobStatus(stringOf(properties.Status)).Terminal() && (lastCompleted == nil || properties.StartTime.After(*lastCompleted.StartTime)) {
+			lastCompleted = properties
+		}
+	}
+
+	return last, lastCompleted
+}
+
+func newestRecoveryPointTime(recoveryPoints []*armdataprotection.AzureBackupRecoveryPointResource) *time.Time {
+	var newest *time.Time
+
+	for _, recoveryPoint := range recoveryPoints {
+		if discrete, ok := recoveryPoint.Properties.(*armdataprotection.AzureBackupDiscreteRecoveryPoint); ok && discrete.RecoveryPointTime != nil {
+			if newest == nil || discrete.RecoveryPointTime.After(*newest) {
+				newest = discrete.RecoveryPointTime
+			}
+		}
+	}
+
+	return newest
+}
+
+/*
+ * Describe sends the descriptions of every metric the exporter collects.
+ */
+func (exporter *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		refreshSuccessDesc, refreshTimestampDesc, vaultHealthyDesc, vaultProvisioningStateDesc, vaultImmutabilityStateDesc,
+		vaultSoftDeleteStateDesc, backupPoliciesDesc, backupInstancesDesc, protectionStatusDesc, lastJobStatusDesc,
+		lastJobSucceededDesc, lastJobDurationDesc, recoveryPointTimestampDesc, recoveryPointAgeDesc,
+	} {
+		ch <- desc
+	}
+}
+
+/*
+ * Collect sends the metrics for every vault that's been refreshed, as of its last refresh.
+ * Recovery point ages are measured at the time of collection.
+ */
+func (exporter *Exporter) Collect(ch chan<- prometheus.Metric) {
+	exporter.mu.RLock()
+	defer exporter.mu.RUnlock()
+
+	now := exporter.now()
+
+	for _, vault := range exporter.vaults {
+		state, ok := exporter.states[vault]
+		if !ok {
+			continue
+		}
+
+		labels := []string{vault.ResourceGroupName, vault.BackupVaultName}
+		gauge := func(desc *prometheus.Desc, value float64, labelValues ...string) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, append(labels, labelValues...)...)
+		}
+
+		gauge(refreshSuccessDesc, boolValue(state.err == nil))
+		gauge(refreshTimestampDesc, timestampValue(state.refreshedAt))
+
+		if !state.read {
+			continue
+		}
+
+		gauge(vaultHealthyDesc, boolValue(state.healthy()))
+		gauge(backupPoliciesDesc, float64(state.policies))
+		gauge(backupInstancesDesc, float64(len(state.instances)))
+
+		stateSet(gauge, vaultProvisioningStateDesc, state.provisioningState, armdataprotection.PossibleProvisioningStateValues())
+		stateSet(gauge, vaultImmutabilityStateDesc, state.immutabilityState, armdataprotection.PossibleImmutabilityStateValues())
+		stateSet(gauge, vaultSoftDeleteStateDesc, state.softDeleteState, armdataprotection.PossibleSoftDeleteStateValues())
+
+		for _, instance := range state.instances {
+			instanceGauge := func(desc *prometheus.Desc, value float64, labelValues ...string) {
+				gauge(desc, value, append([]string{instance.name}, labelValues...)...)
+			}
+
+			stateSet(instanceGauge, protectionStatusDesc, instance.protectionStatus, armdataprotection.PossibleStatusValues())
+
+			if instance.lastJob != nil {
+				instanceGauge(lastJobStatusDesc, 1, stringOf(instance.lastJob.Status))
+			}
+
+			if job := instance.lastCompletedJob; job != nil {
+				instanceGauge(lastJobSucceededDesc, boolValue(wait.JobStatus(stringOf(job.Status)).Succeeded()))
+				if job.EndTime != nil {
+					instanceGauge(lastJobDurationDesc, job.EndTime.Sub(*job.StartTime).Seconds())
+				}
+			}
+
+			if instance.newestRecovery != nil {
+				instanceGauge(recoveryPointTimestampDesc, timestampValue(*instance.newestRecovery))
+				instanceGauge(recoveryPointAgeDesc, now.Sub(*instance.newestRecovery).Seconds())
+			}
+		}
+	}
+}
+
+/*
+ * The vault is healthy when it's provisioned and every backup instance has protection
+ * configured.
+ */
+func (state *vaultState) healthy() bool {
+	if state.provisioningState != string(armdataprotection.ProvisioningStateSucceeded) {
+		return false
+	}
+
+	for _, instance := range state.instances {
+		if instance.protectionStatus != string(armdataprotection.StatusProtectionConfigured) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+ * Sends a gauge per possible value of an enum, set to 1 for the current value and 0 for the
+ * others, so that changes show up on dashboards as one series dropping and another rising. A
+ * current value which isn't one of the possible values is sent as well.
+ */
+func stateSet[T ~string](gauge func(desc *prometheus.Desc, value float64, labelValues ...string), desc *prometheus.Desc, current string, possible []T) {
+	found := false
+	for _, value := range possible {
+		found = found || string(value) == current
+		gauge(desc, boolValue(string(value) == current), string(value))
+	}
+
+	if !found && current != "" {
+		gauge(desc, 1, current)
+	}
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+func timestampValue(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+func stringOf[T ~string](value *T) string {
+	if value == nil {
+		return ""
+	}
+
+	return string(*value)
+}
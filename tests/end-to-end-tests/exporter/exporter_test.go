@@ -0,0 +1,292 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+/*
+ * fakeClient serves vaults from memory, keyed by <resource-group>/<vault-name>, and fails for
+ * vaults in errs.
+ */
+type fakeClient struct {
+	vaults         map[string]*armdataprotection.BackupVaultResource
+	policies       map[string][]*armdataprotection.BaseBackupPolicyResource
+	instances      map[string][]*armdataprotection.BackupInstanceResource
+	jobs           map[string][]*armdataprotection.AzureBackupJobResource
+	recoveryPoints map[string][]*armdataprotection.AzureBackupRecoveryPointResource
+	errs           map[string]error
+}
+
+func (client *fakeClient) GetBackupVault(ctx context.Context, resourceGroupName string, backupVaultName string) (*armdataprotection.BackupVaultResource, error) {
+	key := resourceGroupName + "/" + backupVaultName
+	if err := client.errs[key]; err != nil {
+		return nil, err
+	}
+
+	vault, ok := client.vaults[key]
+	if !ok {
+		return nil, fmt.Errorf("vault %s not found", key)
+	}
+
+	return vault, nil
+}
+
+func (client *fakeClient) ListBackupPolicies(ctx context.Context, resourceGroupName string, backupVaultName string) ([]*armdataprotection.BaseBackupPolicyResource, error) {
+	return client.policies[resourceGroupName+"/"+backupVaultName], nil
+}
+
+func (client *fakeClient) ListBackupInstances(ctx context.Context, resourceGroupName string, backupVaultName string) ([]*armdataprotection.BackupInstanceResource, error) {
+	return client.instances[resourceGroupName+"/"+backupVaultName], nil
+}
+
+func (client *fakeClient) ListBackupJobs(ctx context.Context, resourceGroupName string, backupVaultName string) ([]*armdataprotection.AzureBackupJobResource, error) {
+	return client.jobs[resourceGroupName+"/"+backupVaultName], nil
+}
+
+func (client *fakeClient) ListRecoveryPoints(ctx context.Context, resourceGroupName string, backupVaultName string, backupInstanceName string) ([]*armdataprotection.AzureBackupRecoveryPointResource, error) {
+	return client.recoveryPoints[resourceGroupName+"/"+backupVaultName+"/"+backupInstanceName], nil
+}
+
+func testInstanceID(name string) string {
+	return "/subscriptions/sub/resourceGroups/rg-prod/providers/Microsoft.DataProtection/backupVaults/bvault-prod/backupInstances/" + name
+}
+
+func testInstance(name string, status armdataprotection.Status) *armdataprotection.BackupInstanceResource {
+	return &armdataprotection.BackupInstanceResource{
+		ID:   to.Ptr(testInstanceID(name)),
+		Name: to.Ptr(name),
+		Properties: &armdataprotection.BackupInstance{
+			ProtectionStatus: &armdataprotection.ProtectionStatusDetails{Status: to.Ptr(status)},
+		},
+	}
+}
+
+func testJob(instanceName string, status string, startTime time.Time, duration time.Duration) *armdataprotection.AzureBackupJobResource {
+	job := &armdataprotection.AzureBackupJob{
+		BackupInstanceID:  to.Ptr(testInstanceID(instanceName)),
+		OperationCategory: to.Ptr("Backup"),
+		Status:            to.Ptr(status),
+		StartTime:         to.Ptr(startTime),
+	}
+	if duration > 0 {
+		job.EndTime = to.Ptr(startTime.Add(duration))
+	}
+
+	return &armdataprotection.AzureBackupJobResource{Properties: job}
+}
+
+func testRecoveryPoint(recoveryPointTime time.Time) *armdataprotection.AzureBackupRecoveryPointResource {
+	return &armdataprotection.AzureBackupRecoveryPointResource{
+		Properties: &armdataprotection.AzureBackupDiscreteRecoveryPoint{
+			ObjectType:        to.Ptr("AzureBackupDiscreteRecoveryPoint"),
+			RecoveryPointTime: to.Ptr(recoveryPointTime),
+		},
+	}
+}
+
+func testClient() *fakeClient {
+	return &fakeClient{
+		vaults: map[string]*armdataprotection.BackupVaultResource{
+			"rg-prod/bvault-prod": {
+				Name: to.Ptr("bvault-prod"),
+				Properties: &armdataprotection.BackupVault{
+					ProvisioningState: to.Ptr(armdataprotection.ProvisioningStateSucceeded),
+					SecuritySettings: &armdataprotection.SecuritySettings{
+						ImmutabilitySettings: &armdataprotection.ImmutabilitySettings{State: to.Ptr(armdataprotection.ImmutabilityStateLocked)},
+						SoftDeleteSettings:   &armdataprotection.SoftDeleteSettings{State: to.Ptr(armdataprotection.SoftDeleteStateAlwaysOn)},
+					},
+				},
+			},
+		},
+		policies: map[string][]*armdataprotection.BaseBackupPolicyResource{
+			"rg-prod/bvault-prod": {{Name: to.Ptr("bkpol-blob-blob1")}, {Name: to.Ptr("bkpol-disk-disk1")}},
+		},
+		instances: map[string][]*armdataprotection.BackupInstanceResource{
+			"rg-prod/bvault-prod": {
+				testInstance("bkinst-blob-blob1", armdataprotection.StatusProtectionConfigured),
+				testInstance("bkinst-disk-disk1", armdataprotection.StatusProtectionConfigured),
+			},
+		},
+		jobs: map[string][]*armdataprotection.AzureBackupJobResource{
+			"rg-prod/bvault-prod": {
+				testJob("bkinst-blob-blob1", "Failed", testNow.Add(-26*time.Hour), 5*time.Minute),
+				testJob("bkinst-blob-blob1", "Completed", testNow.Add(-2*time.Hour), 90*time.Second),
+				testJob("bkinst-disk-disk1", "Failed", testNow.Add(-3*time.Hour), 10*time.Minute),
+				// The most recent job of disk1 is still running, so the last completed job is the failed one
+				testJob("bkinst-disk-disk1", "InProgress", testNow.Add(-5*time.Minute), 0),
+			},
+		},
+		recoveryPoints: map[string][]*armdataprotection.AzureBackupRecoveryPointResource{
+			"rg-prod/bvault-prod/bkinst-blob-blob1": {
+				testRecoveryPoint(testNow.Add(-26 * time.Hour)),
+				testRecoveryPoint(testNow.Add(-2 * time.Hour)),
+			},
+		},
+		errs: map[string]error{},
+	}
+}
+
+func newTestExporter(client Client, vaults ...Vault) *Exporter {
+	exporter := New(client, vaults)
+	exporter.now = func() time.Time { return testNow }
+
+	return exporter
+}
+
+/*
+ * TestCollect tests the metrics for a vault, its instances, their last backup jobs and their
+ * newest recovery points.
+ */
+func TestCollect(t *testing.T) {
+	exporter := newTestExporter(testClient(), Vault{ResourceGroupName: "rg-prod", BackupVaultName: "bvault-prod"})
+	require.Empty(t, exporter.Refresh(t.Context()))
+
+	expected := `
+# HELP azbackup_backup_instance_last_backup_job_duration_seconds How long the most recent completed backup job of the backup instance took.
+# TYPE azbackup_backup_instance_last_backup_job_duration_seconds gauge
+azbackup_backup_instance_last_backup_job_duration_seconds{backup_instance="bkinst-blob-blob1",resource_group="rg-prod",vault="bvault-prod"} 90
+azbackup_backup_instance_last_backup_job_duration_seconds{backup_instance="bkinst-disk-disk1",resource_group="rg-prod",vault="bvault-prod"} 600
+# HELP azbackup_backup_instance_last_backup_job_status The status of the most recent backup job of the backup instance, which is always 1.
+# TYPE azbackup_backup_instance_last_backup_job_status gauge
+azbackup_backup_instance_last_backup_job_status{backup_instance="bkinst-blob-blob1",resource_group="rg-prod",status="Completed",vault="bvault-prod"} 1
+azbackup_backup_instance_last_backup_job_status{backup_instance="bkinst-disk-disk1",resource_group="rg-prod",status="InProgress",vault="bvault-prod"} 1
+# HELP azbackup_backup_instance_last_backup_job_succeeded Whether the most recent completed backup job of the backup instance succeeded (1) or not (0).
+# TYPE azbackup_backup_instance_last_backup_job_succeeded gauge
+azbackup_backup_instance_last_backup_job_succeeded{backup_instance="bkinst-blob-blob1",resource_group="rg-prod",vault="bvault-prod"} 1
+azbackup_backup_instance_last_backup_job_succeeded{backup_instance="bkinst-disk-disk1",resource_group="rg-prod",vault="bvault-prod"} 0
+# HELP azbackup_backup_instance_latest_recovery_point_age_seconds How old the newest recovery point of the backup instance is.
+# TYPE azbackup_backup_instance_latest_recovery_point_age_seconds gauge
+azbackup_backup_instance_latest_recovery_point_age_seconds{backup_instance="bkinst-blob-blob1",resource_group="rg-prod",vault="bvault-prod"} 7200
+# HELP azbackup_backup_policies The number of backup policies in the vault.
+# TYPE azbackup_backup_policies gauge
+azbackup_backup_policies{resource_group="rg-prod",vault="bvault-prod"} 2
+# HELP azbackup_vault_healthy Whether the vault is provisioned and every backup instance has protection configured (1) or not (0).
+# TYPE azbackup_vault_healthy gauge
+azbackup_vault_healthy{resource_group="rg-prod",vault="bvault-prod"} 1
+# HELP azbackup_vault_immutability_state The immutability state of the vault, as 1 for the current state and 0 for the others.
+# TYPE azbackup_vault_immutability_state gauge
+azbackup_vault_immutability_state{resource_group="rg-prod",state="Disabled",vault="bvault-prod"} 0
+azbackup_vault_immutability_state{resource_group="rg-prod",state="Locked",vault="bvault-prod"} 1
+azbackup_vault_immutability_state{resource_group="rg-prod",state="Unlocked",vault="bvault-prod"} 0
+# HELP azbackup_vault_soft_delete_state The soft delete state of the vault, as 1 for the current state and 0 for the others.
+# TYPE azbackup_vault_soft_delete_state gauge
+azbackup_vault_soft_delete_state{resource_group="rg-prod",state="AlwaysOn",vault="bvault-prod"} 1
+azbackup_vault_soft_delete_state{resource_group="rg-prod",state="Off",vault="bvault-prod"} 0
+azbackup_vault_soft_delete_state{resource_group="rg-prod",state="On",vault="bvault-prod"} 0
+# HELP azbackup_refresh_success Whether the vault was read successfully at the last refresh (1) or not (0).
+# TYPE azbackup_refresh_success gauge
+azbackup_refresh_success{resource_group="rg-prod",vault="bvault-prod"} 1
+`
+
+	require.NoError(t, testutil.CollectAndCompare(exporter, strings.NewReader(expected),
+		"azbackup_backup_instance_last_backup_job_duration_seconds",
+		"azbackup_backup_instance_last_backup_job_status",
+		"azbackup_backup_instance_last_backup_job_succeeded",
+		"azbackup_backup_instance_latest_recovery_point_age_seconds",
+		"azbackup_backup_policies",
+		"azbackup_vault_healthy",
+		"azbackup_vault_immutability_state",
+		"azbackup_vault_soft_delete_state",
+		"azbackup_refresh_success",
+	))
+
+	// Every metric is described, and the exporter passes the registry's consistency checks
+	problems, err := testutil.CollectAndLint(exporter)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+}
+
+/*
+ * TestCollectProtectionStatus tests that an instance whose protection isn't configured is
+ * reported, and makes the vault unhealthy.
+ */
+func TestCollectProtectionStatus(t *testing.T) {
+	client := testClient()
+	client.instances["rg-prod/bvault-prod"][1] = testInstance("bkinst-disk-disk1", armdataprotection.StatusConfiguringProtectionFailed)
+
+	exporter := newTestExporter(client, Vault{ResourceGroupName: "rg-prod", BackupVaultName: "bvault-prod"})
+	require.Empty(t, exporter.Refresh(t.Context()))
+
+	output := scrape(t, exporter)
+	assert.Contains(t, output, `azbackup_vault_healthy{resource_group="rg-prod",vault="bvault-prod"} 0`)
+	assert.Contains(t, output, `azbackup_backup_instance_protection_status{backup_instance="bkinst-disk-disk1",resource_group="rg-prod",status="ConfiguringProtectionFailed",vault="bvault-prod"} 1`)
+	assert.Contains(t, output, `azbackup_backup_instance_protection_status{backup_instance="bkinst-disk-disk1",resource_group="rg-prod",status="ProtectionConfigured",vault="bvault-prod"} 0`)
+	assert.Contains(t, output, `azbackup_backup_instance_protection_status{backup_instance="bkinst-blob-blob1",resource_group="rg-prod",status="ProtectionConfigured",vault="bvault-prod"} 1`)
+}
+
+/*
+ * TestRefreshError tests that a vault which can't be read is reported as failing to refresh,
+ * while keeping the metrics from its last successful refresh and still refreshing other vaults.
+ */
+func TestRefreshError(t *testing.T) {
+	client := testClient()
+	client.vaults["rg-dev/bvault-dev"] = client.vaults["rg-prod/bvault-prod"]
+
+	prod := Vault{ResourceGroupName: "rg-prod", BackupVaultName: "bvault-prod"}
+	dev := Vault{ResourceGroupName: "rg-dev", BackupVaultName: "bvault-dev"}
+	missing := Vault{ResourceGroupName: "rg-missing", BackupVaultName: "bvault-missing"}
+	exporter := newTestExporter(client, prod, dev, missing)
+
+	errs := exporter.Refresh(t.Context())
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "failed to read vault rg-missing/bvault-missing: vault rg-missing/bvault-missing not found")
+
+	client.errs["rg-prod/bvault-prod"] = errors.New("too many requests")
+	errs = exporter.Refresh(t.Context())
+	require.Len(t, errs, 2)
+
+	output := scrape(t, exporter)
+	assert.Contains(t, output, `azbackup_refresh_success{resource_group="rg-prod",vault="bvault-prod"} 0`)
+	assert.Contains(t, output, `azbackup_refresh_success{resource_group="rg-dev",vault="bvault-dev"} 1`)
+	assert.Contains(t, output, `azbackup_refresh_success{resource_group="rg-missing",vault="bvault-missing"} 0`)
+	// The metrics of the last successful refresh are kept
+	assert.Contains(t, output, `azbackup_backup_policies{resource_group="rg-prod",vault="bvault-prod"} 2`)
+	assert.NotContains(t, output, `azbackup_backup_policies{resource_group="rg-missing"`)
+}
+
+/*
+ * TestParseVault tests that vaults are parsed from <resource-group>/<vault-name>.
+ */
+func TestParseVault(t *testing.T) {
+	vault, err := ParseVault("rg-prod/bvault-prod")
+	require.NoError(t, err)
+	assert.Equal(t, Vault{ResourceGroupName: "rg-prod", BackupVaultName: "bvault-prod"}, vault)
+	assert.Equal(t, "rg-prod/bvault-prod", vault.String())
+
+	for _, value := range []string{"bvault-prod", "/bvault-prod", "rg-prod/", "rg-prod/bvault-prod/extra"} {
+		_, err := ParseVault(value)
+		assert.Error(t, err, value)
+	}
+}
+
+/*
+ * Serves the exporter's metrics over HTTP and returns the response body.
+ */
+func scrape(t *testing.T, exporter *Exporter) string {
+	server := httptest.NewServer(exporter.Handler())
+	defer server.Close()
+
+	response, err := server.Client().Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
@@ -0,0 +1,201 @@
+package e2e_tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"e2e_tests/azure"
+	"e2e_tests/interval"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestFileShareBackupExternalResources struct {
+	ResourceGroup         armresources.ResourceGroup
+	LogAnalyticsWorkspace armoperationalinsights.Workspace
+	StorageAccount        armstorage.Account
+	FileShare             armstorage.FileShare
+}
+
+/*
+ * Creates resources which are "external" to the az-backup module, and models
+ * what would be backed up in a real scenario.
+ */
+func setupExternalResourcesForFileShareBackupTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestFileShareBackupExternalResources {
+	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+
+	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+
+	storageAccountName := fmt.Sprintf("sa%sexternal", strings.ToLower(uniqueId))
+	storageAccount := MustCreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, resourceGroupLocation, nil)
+	fileShare := MustCreateStorageAccountFileShare(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, "test-share", 10)
+
+	externalResources := &TestFileShareBackupExternalResources{
+		ResourceGroup:         resourceGroup,
+		LogAnalyticsWorkspace: logAnalyticsWorkspace,
+		StorageAccount:        storageAccount,
+		FileShare:             fileShare,
+	}
+
+	return externalResources
+}
+
+/*
+ * TestFileShareBackup tests the deployment of a recovery services vault alongside the backup
+ * vault, and the backup policy and protected item for an Azure file share.
+ */
+func TestFileShareBackup(t *testing.T) {
+	t.Parallel()
+
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
+
+	uniqueId := random.UniqueId()
+	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
+	resourceGroupLocation := "uksouth"
+	backupVaultName := fmt.Sprintf("bvault-nhsbackup-%s", uniqueId)
+	recoveryServicesVaultName := fmt.Sprintf("rsvault-nhsbackup-%s", uniqueId)
+
+	externalResources := setupExternalResourcesForFileShareBackupTest(t, credential, environment.SubscriptionID, resourceGroupName, resourceGroupLocation, uniqueId)
+
+	// A map of backups which we'll use to apply the TF module, and then validate the
+	// policies have been created correctly
+	fileShareBackups := map[string]map[string]interface{}{
+		"backup1": {
+			"backup_name":        "share1",
+			"retention_period":   "P7D",
+			"backup_intervals":   []string{"R/2024-01-01T01:30:00+00:00/P1D"},
+			"storage_account_id": *externalResources.StorageAccount.ID,
+			"file_share_name":    *externalResources.FileShare.Name,
+			"retention_rules": map[string]map[string]interface{}{
+				"weekly":  {"count": 4, "weekdays": []string{"Sunday"}},
+				"monthly": {"count": 12, "weekdays": []string{"Sunday"}, "weeks": []string{"First"}},
+				"yearly":  {"count": 5, "weekdays": []string{"Sunday"}, "weeks": []string{"First"}, "months": []string{"January"}},
+			},
+		},
+	}
+
+	// Teardown stage
+	// ...
+
+	defer test_structure.RunTestStage(t, "teardown", func() {
+		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
+
+		DestroyTerraform(t, environment, terraformOptions)
+
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+	})
+
+	// Setup stage
+	// ...
+
+	test_structure.RunTestStage(t, "setup", func() {
+		terraformOptions := &terraform.Options{
+			TerraformDir: environment.TerraformFolder,
+
+			Vars: map[string]interface{}{
+				"resource_group_name":          resourceGroupName,
+				"resource_group_location":      resourceGroupLocation,
+				"backup_vault_name":            backupVaultName,
+				"recovery_services_vault_name": recoveryServicesVaultName,
+				"use_extended_retention":       true,
+				"log_analytics_workspace_id":   *externalResources.LogAnalyticsWorkspace.ID,
+				"file_share_backups":           fileShareBackups,
+			},
+
+			BackendConfig: map[string]interface{}{
+				"resource_group_name":  environment.TerraformStateResourceGroup,
+				"storage_account_name": environment.TerraformStateStorageAccount,
+				"container_name":       environment.TerraformStateContainer,
+				"key":                  backupVaultName + ".tfstate",
+			},
+		}
+
+		// Save options for later test stages
+		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
+
+		ApplyTerraform(t, environment, terraformOptions)
+	})
+
+	// Validate stage
+	// ...
+
+	test_structure.RunTestStage(t, "validate", func() {
+		recoveryServicesVault := MustGetRecoveryServicesVault(t, credential, environment.SubscriptionID, resourceGroupName, recoveryServicesVaultName)
+		backupPolicies := MustGetRecoveryServicesBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, recoveryServicesVaultName)
+		protectedItems := MustGetRecoveryServicesProtectedItems(t, credential, environment.SubscriptionID, resourceGroupName, recoveryServicesVaultName)
+
+		// Validate the recovery services vault has the module's default settings
+		assert.Equal(t, "Standard", recoveryServicesVault.SKU.Name, "Expected the recovery services vault to be a standard vault")
+		assert.Equal(t, "LocallyRedundant", recoveryServicesVault.Properties.RedundancySettings.StandardTierStorageRedundancy, "Expected the recovery services vault to be locally redundant")
+		assert.Equal(t, "Disabled", recoveryServicesVault.Properties.SecuritySettings.ImmutabilitySettings.State, "Expected the recovery services vault immutability to be disabled")
+		assert.Equal(t, "Disabled", recoveryServicesVault.Properties.SecuritySettings.SoftDeleteSettings.SoftDeleteState, "Expected the recovery services vault soft delete to be disabled")
+		assert.NotNil(t, recoveryServicesVault.Identity, "Expected the recovery services vault to have a system assigned identity")
+
+		// Validate the recovery services vault sends its logs to the same workspace as the backup vault
+		diagnosticSettings := MustGetDiagnosticSettings(t, credential, recoveryServicesVault.ID)
+		assert.Equal(t, 4, len(diagnosticSettings.Properties.Logs), "Expected to find %d log categories in diagnostic settings", 4)
+		assert.True(t, strings.EqualFold(*externalResources.LogAnalyticsWorkspace.ID, *diagnosticSettings.Properties.WorkspaceID), "Expected the diagnostic settings to use workspace %s", *externalResources.LogAnalyticsWorkspace.ID)
+
+		assert.Equal(t, len(fileShareBackups), len(backupPolicies), "Expected to find %d backup policies in vault", len(fileShareBackups))
+		assert.Equal(t, len(fileShareBackups), len(protectedItems), "Expected to find %d protected items in vault", len(fileShareBackups))
+
+		for _, backup := range fileShareBackups {
+			storageAccountId := backup["storage_account_id"].(string)
+			fileShareName := backup["file_share_name"].(string)
+			backupInterval, err := interval.Parse(backup["backup_intervals"].([]string)[0])
+			assert.NoError(t, err)
+
+			// Validate backup policy
+			backupPolicyName := MustGetBackupNames(t, naming.ResourceTypeFileShare, backup).PolicyName()
+			backupPolicy := azure.GetRecoveryServicesBackupPolicyForName(backupPolicies, backupPolicyName)
+			if !assert.NotNil(t, backupPolicy, "Expected to find a backup policy called %s", backupPolicyName) {
+				continue
+			}
+
+			assert.Equal(t, "AzureStorage", backupPolicy.Properties.BackupManagementType, "Expected the backup policy to be for file shares")
+			assert.Equal(t, "Daily", backupPolicy.Properties.SchedulePolicy.ScheduleRunFrequency, "Expected the backup policy to run daily")
+
+			// Validate the backup runs at the time of day that the interval starts
+			if assert.Len(t, backupPolicy.Properties.SchedulePolicy.ScheduleRunTimes, 1) {
+				runTime, err := time.Parse(time.RFC3339, backupPolicy.Properties.SchedulePolicy.ScheduleRunTimes[0])
+				assert.NoError(t, err)
+				assert.Equal(t, backupInterval.Start.UTC().Format("15:04"), runTime.UTC().Format("15:04"), "Expected the backup policy to run at the start time of the backup interval")
+			}
+
+			// Validate retention period
+			if assert.NotNil(t, backupPolicy.Properties.RetentionPolicy.DailySchedule, "Expected the backup policy to keep daily recovery points") {
+				assert.Equal(t, 7, backupPolicy.Properties.RetentionPolicy.DailySchedule.RetentionDuration.Count, "Expected the backup policy to keep %d daily recovery points", 7)
+			}
+
+			// Validate retention rules
+			if retentionRules, ok := backup["retention_rules"].(map[string]map[string]interface{}); ok {
+				AssertRecoveryServicesRetentionRulesEqual(t, retentionRules, backupPolicy)
+			}
+
+			// Validate protected item
+			protectedItem := azure.GetRecoveryServicesProtectedItemForSource(protectedItems, storageAccountId, fileShareName)
+			if !assert.NotNil(t, protectedItem, "Expected to find a protected item for file share %s", fileShareName) {
+				continue
+			}
+
+			assert.Equal(t, "AzureFileShare", protectedItem.Properties.WorkloadType, "Expected the protected item to be a file share")
+			assert.True(t, strings.EqualFold(backupPolicy.ID, protectedItem.Properties.PolicyID), "Expected the protected item policy ID to be %s", backupPolicy.ID)
+
+			// The initial backup is pending until the policy first runs
+			assert.Contains(t, []string{"IRPending", "Protected"}, protectedItem.Properties.ProtectionState, "Expected the file share to be protected")
+		}
+	})
+}
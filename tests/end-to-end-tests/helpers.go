@@ -2,622 +2,867 @@ package e2e_tests
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"e2e_tests/audit"
+	"e2e_tests/azure"
+	"e2e_tests/emulator"
+	"e2e_tests/immutability"
+	"e2e_tests/interval"
+	"e2e_tests/kql"
+	"e2e_tests/naming"
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/monitor/armmonitor"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mysql/armmysqlflexibleservers"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/postgresql/armpostgresqlflexibleservers"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
-	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	"github.com/gruntwork-io/go-commons/files"
+	"github.com/gruntwork-io/terratest/modules/terraform"
 	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
 	"github.com/stretchr/testify/assert"
 )
 
+/*
+ * Config holds the settings needed to connect to Azure, along with the terraform folder and
+ * state backend that the tests deploy the module with.
+ */
 type Config struct {
+	azure.Config
+	UseEmulator                  bool
 	TerraformFolder              string
-	TenantID                     string
-	SubscriptionID               string
-	ClientID                     string
-	ClientSecret                 string
 	TerraformStateResourceGroup  string
 	TerraformStateStorageAccount string
 	TerraformStateContainer      string
 }
 
 /*
- * GetEnvironmentConfiguration gets the environment config that is required to execute a test.
+ * Validates that the terraform state backend settings, and the settings required by the
+ * config's credential type, have been provided.
  */
-func GetEnvironmentConfiguration(t *testing.T) *Config {
-	terraformFolder := test_structure.CopyTerraformFolderToTemp(t, "../../infrastructure", "")
-
-	files.CopyFile("./provider.tf", terraformFolder+"/provider.tf")
-
-	tenantID := os.Getenv("ARM_TENANT_ID")
-	if tenantID == "" {
-		t.Fatalf("ARM_TENANT_ID must be set")
-	}
-
-	subscriptionID := os.Getenv("ARM_SUBSCRIPTION_ID")
-	if subscriptionID == "" {
-		t.Fatalf("ARM_SUBSCRIPTION_ID must be set")
+func (config *Config) Validate() error {
+	if err := config.Config.Validate(); err != nil {
+		return err
 	}
 
-	clientID := os.Getenv("ARM_CLIENT_ID")
-	if clientID == "" {
-		t.Fatalf("ARM_CLIENT_ID must be set")
-	}
+	return azure.RequireSettings(map[string]string{
+		"TF_STATE_RESOURCE_GROUP":    config.TerraformStateResourceGroup,
+		"TF_STATE_STORAGE_ACCOUNT":   config.TerraformStateStorageAccount,
+		"TF_STATE_STORAGE_CONTAINER": config.TerraformStateContainer,
+	})
+}
 
-	clientSecret := os.Getenv("ARM_CLIENT_SECRET")
-	if clientSecret == "" {
-		t.Fatalf("ARM_CLIENT_SECRET must be set")
-	}
+/*
+ * MustGetEnvironmentConfiguration gets the environment config that is required to execute a test.
+ */
+func MustGetEnvironmentConfiguration(t *testing.T) *Config {
+	terraformFolder := test_structure.CopyTerraformFolderToTemp(t, "../../infrastructure", "")
 
-	terraformStateResourceGroup := os.Getenv("TF_STATE_RESOURCE_GROUP")
-	if terraformStateResourceGroup == "" {
-		t.Fatalf("TF_STATE_RESOURCE_GROUP must be set")
-	}
+	files.CopyFile("./provider.tf", terraformFolder+"/provider.tf")
 
-	terraformStateStorageAccount := os.Getenv("TF_STATE_STORAGE_ACCOUNT")
-	if terraformStateStorageAccount == "" {
-		t.Fatalf("TF_STATE_STORAGE_ACCOUNT must be set")
+	if getEmulator() != nil {
+		return &Config{
+			Config: azure.Config{
+				CredentialType: azure.CredentialTypeClientSecret,
+				TenantID:       emulator.TenantID,
+				SubscriptionID: emulator.SubscriptionID,
+				ClientID:       emulator.ClientID,
+				ClientSecret:   emulator.ClientSecret,
+			},
+			UseEmulator:                  true,
+			TerraformFolder:              terraformFolder,
+			TerraformStateResourceGroup:  "rg-emulator",
+			TerraformStateStorageAccount: "saemulator",
+			TerraformStateContainer:      "tfstate",
+		}
 	}
 
-	terraformStateContainer := os.Getenv("TF_STATE_STORAGE_CONTAINER")
-	if terraformStateContainer == "" {
-		t.Fatalf("TF_STATE_STORAGE_CONTAINER must be set")
+	azureConfig, err := azure.LoadConfig()
+	if err != nil {
+		t.Fatalf("%v", err)
 	}
 
 	config := &Config{
+		Config:                       *azureConfig,
 		TerraformFolder:              terraformFolder,
-		TenantID:                     tenantID,
-		SubscriptionID:               subscriptionID,
-		ClientID:                     clientID,
-		ClientSecret:                 clientSecret,
-		TerraformStateResourceGroup:  terraformStateResourceGroup,
-		TerraformStateStorageAccount: terraformStateStorageAccount,
-		TerraformStateContainer:      terraformStateContainer,
+		TerraformStateResourceGroup:  os.Getenv("TF_STATE_RESOURCE_GROUP"),
+		TerraformStateStorageAccount: os.Getenv("TF_STATE_STORAGE_ACCOUNT"),
+		TerraformStateContainer:      os.Getenv("TF_STATE_STORAGE_CONTAINER"),
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("%v", err)
 	}
 
 	return config
 }
 
 /*
- * Gets a credential for authenticating with Azure Resource Manager.
+ * Gets a credential for authenticating with Azure Resource Manager, of the type
+ * selected by the environment config.
  */
-func GetAzureCredential(t *testing.T, environment *Config) *azidentity.ClientSecretCredential {
-	credential, err := azidentity.NewClientSecretCredential(environment.TenantID, environment.ClientID, environment.ClientSecret, nil)
-	assert.NoError(t, err, "Failed to obtain a credential: %v", err)
+func MustGetAzureCredential(t *testing.T, environment *Config) azcore.TokenCredential {
+	var credential azcore.TokenCredential
+	var err error
+	if environment.UseEmulator {
+		credential, err = getEmulator().Credential()
+	} else {
+		credential, err = azure.NewCredential(&environment.Config)
+	}
+	if err != nil {
+		t.Fatalf("Failed to obtain a credential: %v", err)
+	}
 
 	return credential
 }
 
 /*
- * Gets a resource group for the provided name.
+ * Gets the local emulator when AZ_BACKUP_EMULATOR is set to true, starting it on first use so
+ * that it's shared by every test. Returns nil when the tests are running against Azure.
  */
-func GetResourceGroup(t *testing.T, subscriptionID string,
-	credential *azidentity.ClientSecretCredential, name string) armresources.ResourceGroup {
-	// Create a new resource groups client
-	client, err := armresources.NewResourceGroupsClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create resource group client: %v", err)
+var getEmulator = sync.OnceValue(func() *emulator.Emulator {
+	if os.Getenv("AZ_BACKUP_EMULATOR") != "true" {
+		return nil
+	}
+
+	log.Printf("Running against the local emulator")
 
-	// Get the resource group
-	resp, err := client.Get(context.Background(), name, nil)
-	assert.NoError(t, err, "Failed to get resource group: %v", err)
+	return emulator.New()
+})
+
+/*
+ * Gets the options of the clients that the tests create - nil (the defaults) unless the tests
+ * are running against the emulator.
+ */
+func clientOptions() *arm.ClientOptions {
+	if e := getEmulator(); e != nil {
+		return e.ClientOptions()
+	}
 
-	return resp.ResourceGroup
+	return nil
 }
 
 /*
- * Gets a role definition for the provided role name.
+ * Applies the terraform module, or deploys the equivalent resources to the emulator when
+ * the tests are running against it.
  */
-func GetRoleDefinition(t *testing.T, credential *azidentity.ClientSecretCredential, roleName string) *armauthorization.RoleDefinition {
-	roleDefinitionsClient, err := armauthorization.NewRoleDefinitionsClient(credential, nil)
-	assert.NoError(t, err, "Failed to create role definition client: %v", err)
-
-	// Create a pager to list role definitions
-	filter := fmt.Sprintf("roleName eq '%s'", roleName)
-	pager := roleDefinitionsClient.NewListPager("", &armauthorization.RoleDefinitionsClientListOptions{Filter: &filter})
-
-	for pager.More() {
-		page, err := pager.NextPage(context.Background())
-		assert.NoError(t, err, "Failed to list role definitions")
-
-		for _, roleDefinition := range page.RoleDefinitionListResult.Value {
-			if *roleDefinition.Properties.RoleName == roleName {
-				return roleDefinition
-			}
+func ApplyTerraform(t *testing.T, environment *Config, terraformOptions *terraform.Options) {
+	if environment.UseEmulator {
+		if err := getEmulator().Apply(environment.SubscriptionID, terraformOptions.Vars); err != nil {
+			t.Fatalf("Failed to apply module to the emulator: %v", err)
 		}
+		return
 	}
 
-	return nil
+	terraform.InitAndApply(t, terraformOptions)
 }
 
 /*
- * Gets a role assignment in the provided scope for the provided role definition,
- * that's been assigned to the provided principal id.
+ * Destroys the terraform module, or removes the equivalent resources from the emulator when
+ * the tests are running against it.
  */
-func GetRoleAssignment(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string,
-	principalId string, roleDefinition *armauthorization.RoleDefinition, scope string) *armauthorization.RoleAssignment {
-	roleAssignmentsClient, err := armauthorization.NewRoleAssignmentsClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create role assignments client: %v", err)
-
-	// List role assignments for the given scope
-	filter := fmt.Sprintf("principalId eq '%s'", principalId)
-	pager := roleAssignmentsClient.NewListForScopePager(scope, &armauthorization.RoleAssignmentsClientListForScopeOptions{Filter: &filter})
-
-	// Find the role assignment for the given definition
-	for pager.More() {
-		page, err := pager.NextPage(context.Background())
-		assert.NoError(t, err, "Failed to list role assignments")
-
-		// Check if the role definition is among the assigned roles
-		for _, roleAssignment := range page.RoleAssignmentListResult.Value {
-			// Use string.contains, as the role definition ID on a role assignment
-			// is a longer URI which includes the subscription scope
-			if strings.Contains(*roleAssignment.Properties.RoleDefinitionID, *roleDefinition.ID) {
-				return roleAssignment
-			}
+func DestroyTerraform(t *testing.T, environment *Config, terraformOptions *terraform.Options) {
+	if environment.UseEmulator {
+		if err := getEmulator().Destroy(environment.SubscriptionID, terraformOptions.Vars); err != nil {
+			t.Fatalf("Failed to destroy module in the emulator: %v", err)
 		}
+		return
 	}
 
-	return nil
+	terraform.Destroy(t, terraformOptions)
 }
 
-func GetDiagnosticSettings(t *testing.T, credential *azidentity.ClientSecretCredential, resourceID string, resourceName string) *armmonitor.DiagnosticSettingsResource {
-	client, err := armmonitor.NewDiagnosticSettingsClient(credential, nil)
-	assert.NoError(t, err, "Failed to create diagnostic settings client: %v", err)
+/*
+ * The Must* functions below wrap the helpers in the azure package for use in tests, failing
+ * the test immediately when a helper returns an error.
+ */
 
-	// List the diagnostic settings for the given resource
-	pager := client.NewListPager(resourceID, nil)
+func MustGetResourceGroup(t *testing.T, credential azcore.TokenCredential, subscriptionID string, name string) armresources.ResourceGroup {
+	t.Helper()
+
+	resourceGroup, err := azure.GetResourceGroup(t.Context(), credential, clientOptions(), subscriptionID, name)
+	if err != nil {
+		t.Fatalf("Failed to get resource group '%s': %v", name, err)
+	}
 
-	for pager.More() {
-		page, err := pager.NextPage(context.Background())
-		assert.NoError(t, err, "Failed to list diagnostic settings")
+	return resourceGroup
+}
 
-		// We currently only handle when there's only one diagnostic setting per resource
-		// ...
+func MustCreateResourceGroup(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string) armresources.ResourceGroup {
+	t.Helper()
 
-		if len(page.Value) == 0 {
-			assert.Fail(t, "No diagnostic settings found for resource: %s", resourceName)
-		} else if len(page.Value) > 1 {
-			assert.Fail(t, "Multiple diagnostic settings found for resource: %s", resourceName)
-		} else {
-			return page.Value[0]
-		}
+	resourceGroup, err := azure.CreateResourceGroup(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, resourceGroupLocation)
+	if err != nil {
+		t.Fatalf("Failed to create resource group '%s': %v", resourceGroupName, err)
 	}
 
-	return nil
+	return resourceGroup
 }
 
-/*
- * Gets a backup vault for the provided name.
- */
-func GetBackupVault(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, backupVaultName string) armdataprotection.BackupVaultResource {
-	client, err := armdataprotection.NewBackupVaultsClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create data protection client: %v", err)
+func MustDeleteResourceGroup(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string) {
+	t.Helper()
+
+	if err := azure.DeleteResourceGroup(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName); err != nil {
+		t.Fatalf("Failed to delete resource group '%s': %v", resourceGroupName, err)
+	}
+}
 
-	// Get the backup vault
-	resp, err := client.Get(context.Background(), resourceGroupName, backupVaultName, nil)
-	assert.NoError(t, err, "Failed to get backup vault: %v", err)
+func MustCreateLogAnalyticsWorkspace(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, workspaceName string, workspaceLocation string) armoperationalinsights.Workspace {
+	t.Helper()
 
-	return resp.BackupVaultResource
+	workspace, err := azure.CreateLogAnalyticsWorkspace(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, workspaceName, workspaceLocation)
+	if err != nil {
+		t.Fatalf("Failed to create log analytics workspace '%s': %v", workspaceName, err)
+	}
+
+	return workspace
 }
 
-/*
- * Gets the backup policies for the provided backup vault.
- */
-func GetBackupPolicies(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, backupVaultName string) []*armdataprotection.BaseBackupPolicyResource {
-	client, err := armdataprotection.NewBackupPoliciesClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create data protection client: %v", err)
+func MustGetDiagnosticSettings(t *testing.T, credential azcore.TokenCredential, resourceID string) *armmonitor.DiagnosticSettingsResource {
+	t.Helper()
 
-	policyPager := client.NewListPager(resourceGroupName, backupVaultName, nil)
+	diagnosticSettings, err := azure.GetDiagnosticSettings(t.Context(), credential, clientOptions(), resourceID)
+	if err != nil {
+		t.Fatalf("Failed to get diagnostic settings: %v", err)
+	}
 
-	var policies []*armdataprotection.BaseBackupPolicyResource
+	return diagnosticSettings
+}
 
-	for policyPager.More() {
-		page, err := policyPager.NextPage(context.Background())
-		assert.NoError(t, err, "Failed to get backup policies: %v", err)
+func MustGetRoleDefinition(t *testing.T, credential azcore.TokenCredential, roleName string) *armauthorization.RoleDefinition {
+	t.Helper()
 
-		policies = append(policies, page.Value...)
+	roleDefinition, err := azure.GetRoleDefinition(t.Context(), credential, clientOptions(), roleName)
+	if err != nil {
+		t.Fatalf("Failed to get role definition '%s': %v", roleName, err)
+	}
+	if roleDefinition == nil {
+		t.Fatalf("Role definition '%s' not found", roleName)
 	}
 
-	return policies
+	return roleDefinition
 }
 
-/*
- * Gets the backup instances for the provided backup vault.
- */
-func GetBackupInstances(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, backupVaultName string) []*armdataprotection.BackupInstanceResource {
-	client, err := armdataprotection.NewBackupInstancesClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create data protection client: %v", err)
+func MustGetRoleAssignment(t *testing.T, credential azcore.TokenCredential, subscriptionID string, principalId string, roleDefinition *armauthorization.RoleDefinition, scope string) *armauthorization.RoleAssignment {
+	t.Helper()
 
-	policyPager := client.NewListPager(resourceGroupName, backupVaultName, nil)
+	roleAssignment, err := azure.GetRoleAssignment(t.Context(), credential, clientOptions(), subscriptionID, principalId, roleDefinition, scope)
+	if err != nil {
+		t.Fatalf("Failed to get role assignment: %v", err)
+	}
 
-	var instances []*armdataprotection.BackupInstanceResource
+	return roleAssignment
+}
 
-	for policyPager.More() {
-		page, err := policyPager.NextPage(context.Background())
-		assert.NoError(t, err, "Failed to get backup policies: %v", err)
+func MustCreateRoleAssignment(t *testing.T, credential azcore.TokenCredential, subscriptionID string, scope string, roleName string, principalId string) armauthorization.RoleAssignment {
+	t.Helper()
 
-		instances = append(instances, page.Value...)
+	roleAssignment, err := azure.CreateRoleAssignment(t.Context(), credential, clientOptions(), subscriptionID, scope, roleName, principalId)
+	if err != nil {
+		t.Fatalf("Failed to assign role '%s': %v", roleName, err)
 	}
 
-	return instances
+	return roleAssignment
 }
 
-/*
- * Gets a backup policy from the provided list for the provided name
- */
-func GetBackupPolicyForName(policies []*armdataprotection.BaseBackupPolicyResource, name string) *armdataprotection.BaseBackupPolicyResource {
-	for _, policy := range policies {
-		if *policy.Name == name {
-			return policy
-		}
+func MustWaitForRoleAssignment(t *testing.T, credential azcore.TokenCredential, subscriptionID string, scope string, roleName string, principalId string) {
+	t.Helper()
+
+	if err := azure.WaitForRoleAssignment(t.Context(), credential, clientOptions(), subscriptionID, scope, roleName, principalId); err != nil {
+		t.Fatalf("Failed to wait for role '%s' to be assigned: %v", roleName, err)
 	}
+}
 
-	return nil
+func MustCreateStorageAccount(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, storageAccountName string, storageAccountLocation string, options *azure.StorageAccountOptions) armstorage.Account {
+	t.Helper()
+
+	storageAccount, err := azure.CreateStorageAccount(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, storageAccountName, storageAccountLocation, options)
+	if err != nil {
+		t.Fatalf("Failed to create storage account '%s': %v", storageAccountName, err)
+	}
+
+	return storageAccount
 }
 
-/*
- * Gets a backup policy rules from the provided list for the provided name
- */
-func GetBackupPolicyRuleForName(policyRules []armdataprotection.BasePolicyRuleClassification, name string) armdataprotection.BasePolicyRuleClassification {
-	for _, policyRule := range policyRules {
-		if *policyRule.GetBasePolicyRule().Name == name {
-			return policyRule
-		}
+func MustCreateStorageAccountContainer(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, storageAccountName string, containerName string) armstorage.BlobContainer {
+	t.Helper()
+
+	blobContainer, err := azure.CreateStorageAccountContainer(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, storageAccountName, containerName)
+	if err != nil {
+		t.Fatalf("Failed to create container '%s': %v", containerName, err)
 	}
 
-	return nil
+	return blobContainer
 }
 
-/*
- * Gets a backup instance from the provided list for the provided name
- */
-func GetBackupInstanceForName(instances []*armdataprotection.BackupInstanceResource, name string) *armdataprotection.BackupInstanceResource {
-	for _, instance := range instances {
-		if *instance.Name == name {
-			return instance
-		}
+func MustCreateStorageAccountFileShare(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, storageAccountName string, shareName string, shareQuotaGB int32) armstorage.FileShare {
+	t.Helper()
+
+	fileShare, err := azure.CreateStorageAccountFileShare(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, storageAccountName, shareName, shareQuotaGB)
+	if err != nil {
+		t.Fatalf("Failed to create file share '%s': %v", shareName, err)
 	}
 
-	return nil
+	return fileShare
 }
 
-/*
- * Creates a resource group that can be used for testing purposes.
- */
-func CreateResourceGroup(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string,
-	resourceGroupName string, resourceGroupLocation string) armresources.ResourceGroup {
-	client, err := armresources.NewResourceGroupsClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create resource group client: %v", err)
+func MustCreateStorageAccountDirectory(t *testing.T, credential azcore.TokenCredential, storageAccountName string, containerName string, directoryPath string) {
+	t.Helper()
 
-	log.Printf("Creating resource group %s in location %s", resourceGroupName, resourceGroupLocation)
+	err := azure.CreateStorageAccountDirectory(t.Context(), credential, clientOptions(), storageAccountName, containerName, directoryPath)
+	if err != nil {
+		t.Fatalf("Failed to create directory '%s': %v", directoryPath, err)
+	}
+}
 
-	resp, err := client.CreateOrUpdate(
-		context.Background(),
-		resourceGroupName,
-		armresources.ResourceGroup{
-			Location: &resourceGroupLocation,
-		},
-		nil,
-	)
-	assert.NoError(t, err, "Failed to create resource group: %v", err)
+func MustCreateTestFile(t *testing.T) *os.File {
+	t.Helper()
 
-	log.Printf("Resource group %s created successfully", resourceGroupName)
+	testFile, err := azure.CreateTestFile()
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
 
-	return resp.ResourceGroup
+	return testFile
 }
 
-/*
- * Creates a Log Analytics workspace that can be used for testing purposes.
- */
-func CreateLogAnalyticsWorkspace(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string,
-	resourceGroupName string, workspaceName string, workspaceLocation string) armoperationalinsights.Workspace {
-	client, err := armoperationalinsights.NewWorkspacesClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create Log Analytics workspace client: %v", err)
+func MustUploadFileToStorageAccount(t *testing.T, credential azcore.TokenCredential, storageAccountName string, containerName string, filePath string) {
+	t.Helper()
 
-	log.Printf("Creating log analytics workspace %s in location %s", workspaceName, workspaceLocation)
+	if err := azure.UploadFileToStorageAccount(t.Context(), credential, clientOptions(), storageAccountName, containerName, filePath); err != nil {
+		t.Fatalf("Failed to upload file '%s': %v", filePath, err)
+	}
+}
+
+func MustDownloadFileFromStorageAccount(t *testing.T, credential azcore.TokenCredential, storageAccountName string, containerName string, blobName string) []byte {
+	t.Helper()
+
+	content, err := azure.DownloadFileFromStorageAccount(t.Context(), credential, clientOptions(), storageAccountName, containerName, blobName)
+	if err != nil {
+		t.Fatalf("Failed to download blob '%s': %v", blobName, err)
+	}
 
-	pollerResp, err := client.BeginCreateOrUpdate(
-		context.Background(),
-		resourceGroupName,
-		workspaceName,
-		armoperationalinsights.Workspace{
-			Location: &workspaceLocation,
-		},
-		nil,
-	)
-	assert.NoError(t, err, "Failed to begin creating log analytics workspace: %v", err)
+	return content
+}
 
-	// Wait for the creation to complete
-	resp, err := pollerResp.PollUntilDone(context.Background(), nil)
-	assert.NoError(t, err, "Failed to create log analytics workspace: %v", err)
+func MustListBlobsInStorageAccountContainer(t *testing.T, credential azcore.TokenCredential, storageAccountName string, containerName string, prefix string) []*container.BlobItem {
+	t.Helper()
 
-	log.Printf("Log analytics workspace %s created successfully", workspaceName)
+	blobs, err := azure.ListBlobsInStorageAccountContainer(t.Context(), credential, clientOptions(), storageAccountName, containerName, prefix)
+	if err != nil {
+		t.Fatalf("Failed to list blobs in container '%s': %v", containerName, err)
+	}
 
-	return resp.Workspace
+	return blobs
 }
 
-/*
- * Creates a storage account that can be used for testing purposes.
- */
-func CreateStorageAccount(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string,
-	resourceGroupName string, storageAccountName string, storageAccountLocation string) armstorage.Account {
-	client, err := armstorage.NewAccountsClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create storage account client: %v", err)
-
-	log.Printf("Creating storage account %s in location %s", storageAccountName, storageAccountLocation)
-
-	pollerResp, err := client.BeginCreate(
-		context.Background(),
-		resourceGroupName,
-		storageAccountName,
-		armstorage.AccountCreateParameters{
-			SKU: &armstorage.SKU{
-				Name: to.Ptr(armstorage.SKUNameStandardLRS),
-			},
-			Kind:     to.Ptr(armstorage.KindStorageV2),
-			Location: &storageAccountLocation,
-		},
-		nil,
-	)
-	assert.NoError(t, err, "Failed to begin creating storage account: %v", err)
+func MustCreateManagedDisk(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, diskName string, diskLocation string, diskSizeGB int32) armcompute.Disk {
+	t.Helper()
+
+	disk, err := azure.CreateManagedDisk(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, diskName, diskLocation, diskSizeGB)
+	if err != nil {
+		t.Fatalf("Failed to create managed disk '%s': %v", diskName, err)
+	}
+
+	return disk
+}
 
-	// Wait for the creation to complete
-	resp, err := pollerResp.PollUntilDone(context.Background(), nil)
-	assert.NoError(t, err, "Failed to create storage account: %v", err)
+func MustCreateManagedDiskWithData(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, diskName string, diskLocation string, diskSizeGB int32, data []byte) armcompute.Disk {
+	t.Helper()
 
-	log.Printf("Storage account %s created successfully", storageAccountName)
+	disk, err := azure.CreateManagedDiskWithData(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, diskName, diskLocation, diskSizeGB, data)
+	if err != nil {
+		t.Fatalf("Failed to create managed disk '%s': %v", diskName, err)
+	}
 
-	return resp.Account
+	return disk
 }
 
-/*
- * Creates a storage account container that can be used for testing purposes.
- */
-func CreateStorageAccountContainer(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string,
-	resourceGroupName string, storageAccountName string, containerName string) armstorage.BlobContainer {
-	containerClient, err := armstorage.NewBlobContainersClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create container client: %v", err)
+func MustGetManagedDisk(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, diskName string) armcompute.Disk {
+	t.Helper()
 
-	resp, err := containerClient.Create(
-		context.Background(),
-		resourceGroupName,
-		storageAccountName,
-		containerName,
-		armstorage.BlobContainer{},
-		nil,
-	)
-	assert.NoError(t, err, "Failed to create container: %v", err)
+	disk, err := azure.GetManagedDisk(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, diskName)
+	if err != nil {
+		t.Fatalf("Failed to get managed disk '%s': %v", diskName, err)
+	}
 
-	log.Printf("Container '%s' created successfully in storage account %s", containerName, storageAccountName)
+	return disk
+}
 
-	return resp.BlobContainer
+func MustGetManagedDiskChecksum(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, diskName string, length int64) string {
+	t.Helper()
+
+	checksum, err := azure.GetManagedDiskChecksum(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, diskName, length)
+	if err != nil {
+		t.Fatalf("Failed to get checksum of managed disk '%s': %v", diskName, err)
+	}
+
+	return checksum
 }
 
-/*
- * Creates a managed disk that can be used for testing purposes.
- */
-func CreateManagedDisk(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string,
-	resourceGroupName string, diskName string, diskLocation string, diskSizeGB int32) armcompute.Disk {
-	client, err := armcompute.NewDisksClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create disks client: %v", err)
-
-	log.Printf("Creating managed disk %s in location %s", diskName, diskLocation)
-
-	pollerResp, err := client.BeginCreateOrUpdate(
-		context.Background(),
-		resourceGroupName,
-		diskName,
-		armcompute.Disk{
-			Location: &diskLocation,
-			SKU: &armcompute.DiskSKU{
-				Name: to.Ptr(armcompute.DiskStorageAccountTypesStandardLRS),
-			},
-			Properties: &armcompute.DiskProperties{
-				DiskSizeGB:   &diskSizeGB,
-				CreationData: &armcompute.CreationData{CreateOption: to.Ptr(armcompute.DiskCreateOptionEmpty)},
-			},
-		},
-		nil,
-	)
-	assert.NoError(t, err, "Failed to begin creating managed disk: %v", err)
+func MustCreateVirtualMachine(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, vmName string, vmLocation string) armcompute.VirtualMachine {
+	t.Helper()
 
-	// Wait for the creation to complete
-	resp, err := pollerResp.PollUntilDone(context.Background(), nil)
-	assert.NoError(t, err, "Failed to create managed disk: %v", err)
+	vm, err := azure.CreateVirtualMachine(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, vmName, vmLocation)
+	if err != nil {
+		t.Fatalf("Failed to create virtual machine '%s': %v", vmName, err)
+	}
+
+	return vm
+}
+
+func MustCreatePostgresqlFlexibleServer(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, serverName string, serverLocation string, storageSizeGB int32) armpostgresqlflexibleservers.Server {
+	t.Helper()
+
+	server, err := azure.CreatePostgresqlFlexibleServer(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, serverName, serverLocation, storageSizeGB)
+	if err != nil {
+		t.Fatalf("Failed to create postgresql flexible server '%s': %v", serverName, err)
+	}
+
+	return server
+}
 
-	log.Printf("Managed disk %s created successfully", diskName)
+func MustCreateMysqlFlexibleServer(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, serverName string, serverLocation string, storageSizeGB int32) armmysqlflexibleservers.Server {
+	t.Helper()
 
-	return resp.Disk
+	server, err := azure.CreateMysqlFlexibleServer(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, serverName, serverLocation, storageSizeGB)
+	if err != nil {
+		t.Fatalf("Failed to create mysql flexible server '%s': %v", serverName, err)
+	}
+
+	return server
 }
 
 /*
- * Creates a postgresql flexible server that can be used for testing purposes.
+ * Checks that a file is a valid pg_dump archive, skipping the check (and returning false) when
+ * pg_restore isn't installed locally.
  */
-func CreatePostgresqlFlexibleServer(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string,
-	resourceGroupName string, serverName string, serverLocation string, storageSizeGB int32) armpostgresqlflexibleservers.Server {
-	client, err := armpostgresqlflexibleservers.NewServersClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create servers client: %v", err)
-
-	log.Printf("Creating postgresql flexible server %s in location %s", serverName, serverLocation)
-
-	pollerResp, err := client.BeginCreate(
-		context.Background(),
-		resourceGroupName,
-		serverName,
-		armpostgresqlflexibleservers.Server{
-			Location: &serverLocation,
-			SKU: &armpostgresqlflexibleservers.SKU{
-				Name: to.Ptr("Standard_B1ms"),
-				Tier: to.Ptr(armpostgresqlflexibleservers.SKUTierBurstable),
-			},
-			Properties: &armpostgresqlflexibleservers.ServerProperties{
-				AdministratorLogin:         to.Ptr("supersecurelogin"),
-				AdministratorLoginPassword: to.Ptr("supersecurepassword"),
-				Version:                    to.Ptr(armpostgresqlflexibleservers.ServerVersionFourteen),
-				Storage: &armpostgresqlflexibleservers.Storage{
-					StorageSizeGB: &storageSizeGB,
-				},
-			},
-		},
-		nil,
-	)
-	assert.NoError(t, err, "Failed to begin creating postgresql flexible server: %v", err)
+func MustVerifyPostgresqlDumpArchive(t *testing.T, archivePath string) bool {
+	t.Helper()
 
-	// Wait for the creation to complete
-	resp, err := pollerResp.PollUntilDone(context.Background(), nil)
-	assert.NoError(t, err, "Failed to create postgresql flexible server: %v", err)
+	_, err := azure.VerifyPostgresqlDumpArchive(t.Context(), archivePath)
+	if errors.Is(err, exec.ErrNotFound) {
+		log.Printf("Skipping verification of archive '%s' as pg_restore is not installed", archivePath)
+		return false
+	}
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
 
-	log.Printf("Postgresql flexible server %s created successfully", serverName)
+	return true
+}
 
-	return resp.Server
+func MustCreateAksCluster(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, clusterName string, clusterLocation string) armcontainerservice.ManagedCluster {
+	t.Helper()
+
+	cluster, err := azure.CreateAksCluster(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, clusterName, clusterLocation)
+	if err != nil {
+		t.Fatalf("Failed to create AKS cluster '%s': %v", clusterName, err)
+	}
+
+	return cluster
 }
 
-/*
- * Deletes a resource group.
- */
-func DeleteResourceGroup(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string) {
-	client, err := armresources.NewResourceGroupsClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create resource group client: %v", err)
+func MustGetTrustedAccessRoleBindings(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, clusterName string) []*armcontainerservice.TrustedAccessRoleBinding {
+	t.Helper()
+
+	bindings, err := azure.GetTrustedAccessRoleBindings(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, clusterName)
+	if err != nil {
+		t.Fatalf("Failed to get trusted access role bindings for AKS cluster '%s': %v", clusterName, err)
+	}
+
+	return bindings
+}
+
+func MustGetAksBackupExtensionPrincipalID(t *testing.T, credential azcore.TokenCredential, subscriptionID string, clusterID string) string {
+	t.Helper()
+
+	principalID, err := azure.GetAksBackupExtensionPrincipalID(t.Context(), credential, clientOptions(), subscriptionID, clusterID)
+	if err != nil {
+		t.Fatalf("Failed to get the backup extension identity for AKS cluster '%s': %v", clusterID, err)
+	}
+
+	return principalID
+}
+
+func MustGetBackupVault(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string) armdataprotection.BackupVaultResource {
+	t.Helper()
+
+	backupVault, err := azure.GetBackupVault(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, backupVaultName)
+	if err != nil {
+		t.Fatalf("Failed to get backup vault '%s': %v", backupVaultName, err)
+	}
+
+	return backupVault
+}
+
+func MustGetBackupPolicies(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string) []*armdataprotection.BaseBackupPolicyResource {
+	t.Helper()
+
+	policies, err := azure.GetBackupPolicies(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, backupVaultName)
+	if err != nil {
+		t.Fatalf("Failed to get backup policies for vault '%s': %v", backupVaultName, err)
+	}
+
+	return policies
+}
+
+func MustGetBackupInstances(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string) []*armdataprotection.BackupInstanceResource {
+	t.Helper()
+
+	instances, err := azure.GetBackupInstances(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, backupVaultName)
+	if err != nil {
+		t.Fatalf("Failed to get backup instances for vault '%s': %v", backupVaultName, err)
+	}
+
+	return instances
+}
 
-	log.Printf("Deleting resource group %s", resourceGroupName)
+func MustGetRecoveryServicesVault(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, vaultName string) azure.RecoveryServicesVault {
+	t.Helper()
 
-	pollerResp, err := client.BeginDelete(context.Background(), resourceGroupName, nil)
-	assert.NoError(t, err, "Failed to delete resource group: %v", err)
+	vault, err := azure.GetRecoveryServicesVault(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, vaultName)
+	if err != nil {
+		t.Fatalf("Failed to get recovery services vault '%s': %v", vaultName, err)
+	}
+
+	return vault
+}
+
+func MustGetRecoveryServicesBackupPolicies(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, vaultName string) []*azure.RecoveryServicesBackupPolicy {
+	t.Helper()
 
-	// Wait for the creation to complete
-	_, err = pollerResp.PollUntilDone(context.Background(), nil)
-	assert.NoError(t, err, "Failed to create storage account: %v", err)
+	policies, err := azure.GetRecoveryServicesBackupPolicies(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, vaultName)
+	if err != nil {
+		t.Fatalf("Failed to get backup policies for recovery services vault '%s': %v", vaultName, err)
+	}
+
+	return policies
+}
+
+func MustGetRecoveryServicesProtectedItems(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, vaultName string) []*azure.RecoveryServicesProtectedItem {
+	t.Helper()
+
+	items, err := azure.GetRecoveryServicesProtectedItems(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, vaultName)
+	if err != nil {
+		t.Fatalf("Failed to get protected items for recovery services vault '%s': %v", vaultName, err)
+	}
+
+	return items
+}
+
+func MustUpdateBackupVaultImmutability(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string, immutabilitySettings armdataprotection.ImmutabilitySettings) {
+	t.Helper()
+
+	if err := azure.UpdateBackupVaultImmutability(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, backupVaultName, immutabilitySettings); err != nil {
+		t.Fatalf("Failed to update immutability of backup vault '%s': %v", backupVaultName, err)
+	}
+}
+
+func MustTransitionBackupVaultImmutability(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string, state armdataprotection.ImmutabilityState, confirmation string) *immutability.Result {
+	t.Helper()
+
+	machine := immutability.New(immutability.NewClient(credential, clientOptions(), subscriptionID), resourceGroupName, backupVaultName)
+
+	result, err := machine.TransitionTo(t.Context(), state, confirmation)
+	if err != nil {
+		t.Fatalf("Failed to move backup vault '%s' to immutability %s: %v", backupVaultName, state, err)
+	}
+
+	return result
+}
+
+func MustBeginAdHocBackup(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string, backupInstanceName string) *wait.JobResult {
+	t.Helper()
+
+	result, err := azure.BeginAdHocBackup(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+	if err != nil {
+		t.Fatalf("Ad-hoc backup of '%s' did not succeed: %v", backupInstanceName, err)
+	}
+
+	return result
+}
+
+func MustWaitForBackupJob(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string, jobID string) *wait.JobResult {
+	t.Helper()
+
+	result, err := azure.WaitForBackupJob(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, backupVaultName, jobID)
+	if err != nil {
+		t.Fatalf("Backup job did not succeed: %v", err)
+	}
 
-	log.Printf("Resource group %s deleted successfully", resourceGroupName)
+	return result
 }
 
 /*
- * Deletes the backup instance for the provided backup vault and instance name.
+ * Waits for the log analytics workspace with the provided workspace (customer) ID to receive the
+ * AddonAzureBackupJobs records for a job, which can take up to half an hour after the job ends.
  */
-func DeleteBackupInstance(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, backupVaultName string, backupInstanceName string) error {
-	client, err := armdataprotection.NewBackupInstancesClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create data protection client: %v", err)
+func MustWaitForBackupJobLogs(t *testing.T, credential azcore.TokenCredential, workspaceID string, jobID string) []kql.Job {
+	t.Helper()
 
-	poller, err := client.BeginDelete(context.Background(), resourceGroupName, backupVaultName, backupInstanceName, nil)
+	var queryOptions *azcore.ClientOptions
+	if options := clientOptions(); options != nil {
+		queryOptions = &options.ClientOptions
+	}
+
+	runner := kql.NewRunner(credential, queryOptions)
+	query := fmt.Sprintf("AddonAzureBackupJobs | where JobUniqueId =~ %q", jobID[strings.LastIndex(jobID, "/")+1:])
+
+	var jobs []kql.Job
+	err := wait.Until(t.Context(), &wait.Options{Timeout: 45 * time.Minute, InitialInterval: 30 * time.Second, MaxInterval: 2 * time.Minute}, func(ctx context.Context) (bool, error) {
+		table, err := runner.Execute(ctx, workspaceID, query, 24*time.Hour)
+		if err != nil {
+			return false, err
+		}
+
+		jobs, err = kql.Decode[kql.Job](table)
+		return len(jobs) > 0, err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete backup instance: %w", err)
+		t.Fatalf("Logs for backup job '%s' were not delivered to workspace '%s': %v", jobID, workspaceID, err)
 	}
 
-	_, err = poller.PollUntilDone(context.Background(), nil)
+	return jobs
+}
+
+func MustGetRecoveryPoints(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string, backupInstanceName string) []*armdataprotection.AzureBackupRecoveryPointResource {
+	t.Helper()
+
+	recoveryPoints, err := azure.GetRecoveryPoints(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
 	if err != nil {
-		return fmt.Errorf("failed to delete backup instance: %w", err)
+		t.Fatalf("Failed to get recovery points for '%s': %v", backupInstanceName, err)
 	}
 
-	log.Printf("Backup instance '%s' deleted successfully", backupInstanceName)
-	return nil
+	return recoveryPoints
+}
+
+func MustBeginBlobStorageRestore(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string,
+	backupInstanceName string, recoveryPointID string, targetStorageAccount armstorage.Account, containerNames []string) string {
+	t.Helper()
+
+	jobID, err := azure.BeginBlobStorageRestore(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, backupVaultName, backupInstanceName, recoveryPointID, targetStorageAccount, containerNames)
+	if err != nil {
+		t.Fatalf("Failed to restore '%s': %v", backupInstanceName, err)
+	}
+
+	return jobID
+}
+
+func MustBeginManagedDiskRestore(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string,
+	backupInstanceName string, recoveryPointID string, targetResourceGroup armresources.ResourceGroup, targetDiskName string) string {
+	t.Helper()
+
+	jobID, err := azure.BeginManagedDiskRestore(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, backupVaultName, backupInstanceName, recoveryPointID, targetResourceGroup, targetDiskName)
+	if err != nil {
+		t.Fatalf("Failed to restore '%s': %v", backupInstanceName, err)
+	}
+
+	return jobID
+}
+
+func MustBeginPostgresqlFlexibleServerRestoreAsFiles(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, backupVaultName string,
+	backupInstanceName string, recoveryPointID string, targetStorageAccount armstorage.Account, targetContainer armstorage.BlobContainer, filePrefix string) string {
+	t.Helper()
+
+	jobID, err := azure.BeginPostgresqlFlexibleServerRestoreAsFiles(t.Context(), credential, clientOptions(), subscriptionID, resourceGroupName, backupVaultName, backupInstanceName, recoveryPointID, targetStorageAccount, targetContainer, filePrefix)
+	if err != nil {
+		t.Fatalf("Failed to restore '%s' as files: %v", backupInstanceName, err)
+	}
+
+	return jobID
 }
 
 /*
- * Creates a test file that can be used for test purposes.
+ * Gets the names of the backup policy and backup instance that a backup module creates for a
+ * backup passed to the module, using its naming templates if it sets them. Fails the test if
+ * the templates or the names they render to are invalid.
  */
-func CreateTestFile(t *testing.T) *os.File {
-	testFile, err := os.CreateTemp("", "test-*.txt")
-	assert.NoError(t, err, "Failed to test file: %v", err)
+func MustGetBackupNames(t *testing.T, resourceType string, backup map[string]interface{}) naming.Backup {
+	t.Helper()
 
-	content := []byte("This is a test file for upload.")
-	testFile.Write(content)
-	testFile.Close()
+	names := naming.Backup{ResourceType: resourceType, BackupName: backup["backup_name"].(string)}
+	names.PolicyNamingTemplate, _ = backup["backup_policy_naming_template"].(string)
+	names.InstanceNamingTemplate, _ = backup["backup_instance_naming_template"].(string)
 
-	return testFile
+	if err := names.Validate(); err != nil {
+		t.Fatalf("Failed to render the names for backup %s: %v", names.BackupName, err)
+	}
+
+	return names
 }
 
 /*
- * Uploads a file to blob storage account
+ * Asserts that the repeating intervals of a backup policy schedule the same runs as the
+ * expected backup intervals, however Azure has written them (e.g. with a Z rather than a
+ * +00:00 offset).
  */
-func UploadFileToStorageAccount(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, storageAccountName string, containerName string, filePath string) {
-	serviceClient, err := azblob.NewClient(fmt.Sprintf("https://%s.blob.core.windows.net/", storageAccountName), credential, nil)
-	assert.NoError(t, err, "Failed to create service client: %v", err)
+func AssertBackupIntervalsEqual(t *testing.T, expected []string, actual []*string) {
+	t.Helper()
 
-	file, err := os.Open(filePath)
-	assert.NoError(t, err, "Failed to open file: %v", err)
-	defer file.Close()
+	if !assert.Len(t, actual, len(expected), "Expected the backup policy to have %d repeating intervals", len(expected)) {
+		return
+	}
 
-	fileName := filepath.Base(filePath)
+	for index, value := range actual {
+		expectedInterval, err := interval.Parse(expected[index])
+		if !assert.NoError(t, err, "Expected backup interval %d to be valid", index) {
+			continue
+		}
 
-	_, err = serviceClient.UploadFile(context.Background(), containerName, fileName, file, nil)
-	assert.NoError(t, err, "Failed to upload file: %v", err)
+		actualInterval, err := interval.Parse(*value)
+		if !assert.NoError(t, err, "Expected backup policy repeating interval %d to be valid", index) {
+			continue
+		}
 
-	log.Printf("File '%s' uploaded successfully to container '%s' in storage account '%s'", filePath, containerName, storageAccountName)
+		assert.True(t, expectedInterval.Equal(actualInterval), "Expected backup policy repeating interval %s to be equivalent to %s", *value, expected[index])
+	}
 }
 
 /*
- * Updates the immutability setting on a backup vault.
+ * Asserts that a backup policy has a retention tier for each of the expected retention rules,
+ * with the same duration and tagging criteria, and that the tiers are prioritised in the order
+ * that the rules were given.
  */
-func UpdateBackupVaultImmutability(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, backupVaultName string, immutabilitySettings armdataprotection.ImmutabilitySettings) {
-	client, err := armdataprotection.NewBackupVaultsClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create data protection client: %v", err)
-
-	// Set the immutability setting on the backup vault
-	_, err = client.BeginUpdate(context.Background(), resourceGroupName, backupVaultName, armdataprotection.PatchResourceRequestInput{
-		Properties: &armdataprotection.PatchBackupVaultInput{
-			SecuritySettings: &armdataprotection.SecuritySettings{
-				ImmutabilitySettings: &immutabilitySettings,
-			},
-		},
-	}, nil)
-	assert.NoError(t, err, "Failed to set immutability setting on backup vault: %v", err)
+func AssertRetentionTiersEqual(t *testing.T, expected []map[string]interface{}, backupPolicy *armdataprotection.BackupPolicy, dataStoreType armdataprotection.DataStoreTypes) {
+	t.Helper()
+
+	tiers := azure.GetBackupPolicyRetentionTiers(backupPolicy)
+
+	var previous *azure.RetentionTier
+	for _, rule := range expected {
+		name := rule["name"].(string)
 
-	log.Printf("Immutability setting updated on backup vault '%s'", backupVaultName)
+		tier := azure.GetRetentionTierForName(tiers, name)
+		if !assert.NotNil(t, tier, "Expected the backup policy to have a retention tier called %s", name) {
+			continue
+		}
+
+		assert.False(t, tier.IsDefault, "Expected retention tier %s not to be the default", name)
+		assert.Equal(t, rule["duration"], tier.Duration, "Expected retention tier %s to keep backups for %s", name, rule["duration"])
+		assert.Equal(t, string(dataStoreType), tier.DataStoreType, "Expected retention tier %s to keep backups in the %s", name, dataStoreType)
+
+		if absoluteCriteria, ok := rule["absolute_criteria"].(string); ok {
+			assert.Equal(t, []string{absoluteCriteria}, tier.AbsoluteCriteria, "Expected retention tier %s to tag %s backups", name, absoluteCriteria)
+		}
+		if daysOfWeek, ok := rule["days_of_week"].([]string); ok {
+			assert.ElementsMatch(t, daysOfWeek, tier.DaysOfWeek, "Expected retention tier %s to tag backups on %v", name, daysOfWeek)
+		}
+		if weeksOfMonth, ok := rule["weeks_of_month"].([]string); ok {
+			assert.ElementsMatch(t, weeksOfMonth, tier.WeeksOfMonth, "Expected retention tier %s to tag backups in weeks %v", name, weeksOfMonth)
+		}
+		if monthsOfYear, ok := rule["months_of_year"].([]string); ok {
+			assert.ElementsMatch(t, monthsOfYear, tier.MonthsOfYear, "Expected retention tier %s to tag backups in %v", name, monthsOfYear)
+		}
+
+		if previous != nil {
+			assert.Less(t, previous.Priority, tier.Priority, "Expected retention tier %s to take priority over %s", previous.Name, name)
+		}
+		previous = tier
+	}
 }
 
 /*
- * Begins an ad-hoc backup for the provided backup instance name.
+ * Asserts that a recovery services backup policy keeps the weekly, monthly and yearly recovery
+ * points that the expected retention rules (keyed by schedule, as in the retention_rules variable)
+ * give, and none that they don't.
  */
-func BeginAdHocBackup(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, backupVaultName string, backupInstanceName string) {
-	instancesClient, err := armdataprotection.NewBackupInstancesClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create backup instances client: %v", err)
-
-	poller, err := instancesClient.BeginAdhocBackup(context.Background(), resourceGroupName, backupVaultName, backupInstanceName, armdataprotection.TriggerBackupRequest{
-		BackupRuleOptions: &armdataprotection.AdHocBackupRuleOptions{
-			RuleName:      to.Ptr("BackupIntervals"),
-			TriggerOption: &armdataprotection.AdhocBackupTriggerOption{},
-		},
-	}, nil)
-	assert.NoError(t, err, "Failed to begin ad-hoc backup: %v", err)
-
-	resp, err := poller.PollUntilDone(context.Background(), nil)
-	assert.NoError(t, err, "Failed to poll ad-hoc backup status: %v", err)
-	assert.NotNil(t, *resp.JobID, "Expected a job ID to be returned")
-
-	jobClient, err := armdataprotection.NewJobsClient(subscriptionID, credential, nil)
-	assert.NoError(t, err, "Failed to create backup jobs client: %v", err)
-
-	jobId := strings.Split(*resp.JobID, "/")[len(strings.Split(*resp.JobID, "/"))-1]
-
-	for {
-		jobResp, err := jobClient.Get(context.Background(), resourceGroupName, backupVaultName, jobId, nil)
-		assert.NoError(t, err, "Failed to get backup job status: %v", err)
-
-		if *jobResp.Properties.Status != "InProgress" {
-			assert.Equal(t, "Completed", *jobResp.Properties.Status, "Backup job did not succeed")
-			break
+func AssertRecoveryServicesRetentionRulesEqual(t *testing.T, expected map[string]map[string]interface{}, backupPolicy *azure.RecoveryServicesBackupPolicy) {
+	t.Helper()
+
+	retentionPolicy := backupPolicy.Properties.RetentionPolicy
+
+	if rule, ok := expected["weekly"]; !ok {
+		assert.Nil(t, retentionPolicy.WeeklySchedule, "Expected the backup policy not to keep weekly recovery points")
+	} else if assert.NotNil(t, retentionPolicy.WeeklySchedule, "Expected the backup policy to keep weekly recovery points") {
+		assert.Equal(t, rule["count"], retentionPolicy.WeeklySchedule.RetentionDuration.Count, "Expected the backup policy to keep %d weekly recovery points", rule["count"])
+		assert.ElementsMatch(t, rule["weekdays"], retentionPolicy.WeeklySchedule.DaysOfTheWeek, "Expected the backup policy to keep weekly recovery points from %v", rule["weekdays"])
+	}
+
+	if rule, ok := expected["monthly"]; !ok {
+		assert.Nil(t, retentionPolicy.MonthlySchedule, "Expected the backup policy not to keep monthly recovery points")
+	} else if assert.NotNil(t, retentionPolicy.MonthlySchedule, "Expected the backup policy to keep monthly recovery points") {
+		assert.Equal(t, rule["count"], retentionPolicy.MonthlySchedule.RetentionDuration.Count, "Expected the backup policy to keep %d monthly recovery points", rule["count"])
+		if assert.NotNil(t, retentionPolicy.MonthlySchedule.RetentionScheduleWeekly, "Expected the backup policy to keep monthly recovery points from days of the week") {
+			assert.ElementsMatch(t, rule["weekdays"], retentionPolicy.MonthlySchedule.RetentionScheduleWeekly.DaysOfTheWeek, "Expected the backup policy to keep monthly recovery points from %v", rule["weekdays"])
+			assert.ElementsMatch(t, rule["weeks"], retentionPolicy.MonthlySchedule.RetentionScheduleWeekly.WeeksOfTheMonth, "Expected the backup policy to keep monthly recovery points from weeks %v", rule["weeks"])
 		}
+	}
+
+	if rule, ok := expected["yearly"]; !ok {
+		assert.Nil(t, retentionPolicy.YearlySchedule, "Expected the backup policy not to keep yearly recovery points")
+	} else if assert.NotNil(t, retentionPolicy.YearlySchedule, "Expected the backup policy to keep yearly recovery points") {
+		assert.Equal(t, rule["count"], retentionPolicy.YearlySchedule.RetentionDuration.Count, "Expected the backup policy to keep %d yearly recovery points", rule["count"])
+		assert.ElementsMatch(t, rule["months"], retentionPolicy.YearlySchedule.MonthsOfYear, "Expected the backup policy to keep yearly recovery points from %v", rule["months"])
+		if assert.NotNil(t, retentionPolicy.YearlySchedule.RetentionScheduleWeekly, "Expected the backup policy to keep yearly recovery points from days of the week") {
+			assert.ElementsMatch(t, rule["weekdays"], retentionPolicy.YearlySchedule.RetentionScheduleWeekly.DaysOfTheWeek, "Expected the backup policy to keep yearly recovery points from %v", rule["weekdays"])
+			assert.ElementsMatch(t, rule["weeks"], retentionPolicy.YearlySchedule.RetentionScheduleWeekly.WeeksOfTheMonth, "Expected the backup policy to keep yearly recovery points from weeks %v", rule["weeks"])
+		}
+	}
+}
+
+/*
+ * Audits a vault against the rules the module promises, and asserts that every finding passed.
+ * When AUDIT_REPORT_DIR is set, the findings are also written to that directory as JSON,
+ * JUnit XML and SARIF, in files named after the test.
+ */
+func AssertVaultCompliance(t *testing.T, credential azcore.TokenCredential, vaultID string, options audit.Options) *audit.Report {
+	t.Helper()
+
+	report, err := audit.AuditVault(t.Context(), credential, clientOptions(), vaultID, options)
+	if err != nil {
+		t.Fatalf("Failed to audit vault '%s': %v", vaultID, err)
+	}
+
+	if reportDir := os.Getenv("AUDIT_REPORT_DIR"); reportDir != "" {
+		writeAuditReports(t, report, reportDir)
+	}
 
-		log.Printf("Backup job '%s' is still in progress...", jobId)
+	for _, finding := range report.Findings {
+		assert.True(t, finding.Passed, "[%s] %s (resource: %s, expected: %s, actual: %s)",
+			finding.RuleID, finding.Message, finding.ResourceID, finding.Expected, finding.Actual)
+	}
+
+	return report
+}
 
-		time.Sleep(10 * time.Second)
+func writeAuditReports(t *testing.T, report *audit.Report, reportDir string) {
+	t.Helper()
+
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		t.Fatalf("Failed to create audit report directory: %v", err)
 	}
 
-	log.Printf("Ad-hoc backup '%s' completed successfully", backupInstanceName)
+	baseName := strings.ReplaceAll(t.Name(), "/", "_")
+
+	for _, format := range []audit.Format{audit.FormatJSON, audit.FormatJUnit, audit.FormatSARIF} {
+		file, err := os.Create(filepath.Join(reportDir, baseName+format.Extension()))
+		if err != nil {
+			t.Fatalf("Failed to create audit report: %v", err)
+		}
+
+		err = report.Write(file, format)
+		file.Close()
+		if err != nil {
+			t.Fatalf("Failed to write audit report: %v", err)
+		}
+	}
 }
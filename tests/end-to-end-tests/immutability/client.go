@@ -0,0 +1,47 @@
+package immutability
+
+import (
+	"context"
+
+	"e2e_tests/azure"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+)
+
+/*
+ * Client reads and updates the immutability of backup vaults, and is implemented against Azure
+ * by NewClient. UpdateImmutability returns once the update has finished.
+ */
+type Client interface {
+	GetBackupVault(ctx context.Context, resourceGroupName string, backupVaultName string) (*armdataprotection.BackupVaultResource, error)
+	UpdateImmutability(ctx context.Context, resourceGroupName string, backupVaultName string, state armdataprotection.ImmutabilityState) error
+}
+
+type azureClient struct {
+	credential     azcore.TokenCredential
+	clientOptions  *arm.ClientOptions
+	subscriptionID string
+}
+
+/*
+ * NewClient creates a client which updates backup vaults in the provided subscription through
+ * the armdataprotection clients.
+ */
+func NewClient(credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string) Client {
+	return &azureClient{credential: credential, clientOptions: clientOptions, subscriptionID: subscriptionID}
+}
+
+func (client *azureClient) GetBackupVault(ctx context.Context, resourceGroupName string, backupVaultName string) (*armdataprotection.BackupVaultResource, error) {
+	vault, err := azure.GetBackupVault(ctx, client.credential, client.clientOptions, client.subscriptionID, resourceGroupName, backupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vault, nil
+}
+
+func (client *azureClient) UpdateImmutability(ctx context.Context, resourceGroupName string, backupVaultName string, state armdataprotection.ImmutabilityState) error {
+	return azure.UpdateBackupVaultImmutability(ctx, client.credential, client.clientOptions, client.subscriptionID, resourceGroupName, backupVaultName, armdataprotection.ImmutabilitySettings{State: &state})
+}
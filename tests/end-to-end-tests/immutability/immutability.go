@@ -0,0 +1,200 @@
+/*
+ * Package immutability moves backup vaults through the immutability lifecycle that Azure
+ * allows: Disabled ⇄ Unlocked → Locked.
+ *
+ * A vault can't be created Locked, so locking a Disabled vault goes through Unlocked first.
+ * Locking is irreversible - a Locked vault can't be unlocked or disabled, and its recovery
+ * points can't be removed until they expire - so a transition which locks a vault is refused
+ * unless it's confirmed with the vault's confirmation token. Transitions out of Locked are
+ * always refused.
+ */
+package immutability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+)
+
+var (
+	// ErrIllegalTransition is returned for transitions that Azure doesn't allow
+	ErrIllegalTransition = errors.New("illegal immutability transition")
+
+	// ErrConfirmationRequired is returned for irreversible transitions without a valid confirmation token
+	ErrConfirmationRequired = errors.New("irreversible immutability transition requires confirmation")
+
+	// ErrStateMismatch is returned when a vault doesn't report the state it was just moved to
+	ErrStateMismatch = errors.New("backup vault did not reach the requested immutability state")
+)
+
+/*
+ * Step is a single update of a vault's immutability.
+ */
+type Step struct {
+	From         armdataprotection.ImmutabilityState
+	To           armdataprotection.ImmutabilityState
+	Irreversible bool
+}
+
+/*
+ * The transitions that Azure allows, each of which is a single update of the vault.
+ */
+var transitions = []Step{
+	{From: armdataprotection.ImmutabilityStateDisabled, To: armdataprotection.ImmutabilityStateUnlocked},
+	{From: armdataprotection.ImmutabilityStateUnlocked, To: armdataprotection.ImmutabilityStateDisabled},
+	{From: armdataprotection.ImmutabilityStateUnlocked, To: armdataprotection.ImmutabilityStateLocked, Irreversible: true},
+}
+
+/*
+ * ConfirmationToken gets the token which confirms an irreversible transition of a vault. It
+ * names the vault, so that a token can't be reused to lock a different vault by mistake.
+ */
+func ConfirmationToken(backupVaultName string) string {
+	return "lock:" + backupVaultName
+}
+
+/*
+ * Plan works out the steps which move a vault from one immutability state to another, which
+ * is empty when the vault is already in the target state. An error wrapping
+ * ErrIllegalTransition is returned when there's no way to reach the target state.
+ */
+func Plan(from armdataprotection.ImmutabilityState, to armdataprotection.ImmutabilityState) ([]Step, error) {
+	for _, state := range []armdataprotection.ImmutabilityState{from, to} {
+		if !slices.Contains(armdataprotection.PossibleImmutabilityStateValues(), state) {
+			return nil, fmt.Errorf("%w: %q is not an immutability state", ErrIllegalTransition, state)
+		}
+	}
+
+	if from == to {
+		return nil, nil
+	}
+
+	// The states form a short chain, so a direct step or one via Unlocked covers every path
+	if step, ok := findStep(from, to); ok {
+		return []Step{step}, nil
+	}
+
+	first, firstOK := findStep(from, armdataprotection.ImmutabilityStateUnlocked)
+	second, secondOK := findStep(armdataprotection.ImmutabilityStateUnlocked, to)
+	if firstOK && secondOK {
+		return []Step{first, second}, nil
+	}
+
+	if from == armdataprotection.ImmutabilityStateLocked {
+		return nil, fmt.Errorf("%w: %s to %s, as Locked can't be reversed", ErrIllegalTransition, from, to)
+	}
+
+	return nil, fmt.Errorf("%w: %s to %s", ErrIllegalTransition, from, to)
+}
+
+func findStep(from armdataprotection.ImmutabilityState, to armdataprotection.ImmutabilityState) (Step, bool) {
+	index := slices.IndexFunc(transitions, func(step Step) bool { return step.From == from && step.To == to })
+	if index == -1 {
+		return Step{}, false
+	}
+
+	return transitions[index], true
+}
+
+/*
+ * Result records the transition of a vault, with the steps that were taken.
+ */
+type Result struct {
+	From  armdataprotection.ImmutabilityState
+	To    armdataprotection.ImmutabilityState
+	Steps []Step
+}
+
+/*
+ * Machine moves a single backup vault between immutability states.
+ */
+type Machine struct {
+	client            Client
+	resourceGroupName string
+	backupVaultName   string
+}
+
+/*
+ * New creates a machine for the provided backup vault.
+ */
+func New(client Client, resourceGroupName string, backupVaultName string) *Machine {
+	return &Machine{client: client, resourceGroupName: resourceGroupName, backupVaultName: backupVaultName}
+}
+
+/*
+ * Current reads the immutability state of the vault. A vault without immutability settings
+ * is Disabled.
+ */
+func (machine *Machine) Current(ctx context.Context) (armdataprotection.ImmutabilityState, error) {
+	vault, err := machine.client.GetBackupVault(ctx, machine.resourceGroupName, machine.backupVaultName)
+	if err != nil {
+		return "", err
+	}
+
+	if vault.Properties != nil && vault.Properties.SecuritySettings != nil {
+		if settings := vault.Properties.SecuritySettings.ImmutabilitySettings; settings != nil && settings.State != nil {
+			return *settings.State, nil
+		}
+	}
+
+	return armdataprotection.ImmutabilityStateDisabled, nil
+}
+
+/*
+ * Plan reads the vault's current state, and works out the steps which move it to the target
+ * state, without changing the vault.
+ */
+func (machine *Machine) Plan(ctx context.Context, target armdataprotection.ImmutabilityState) (*Result, error) {
+	current, err := machine.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := Plan(current, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{From: current, To: target, Steps: steps}, nil
+}
+
+/*
+ * TransitionTo moves the vault to the target state, one step at a time. The vault is re-read
+ * after each step to confirm that it reached the step's state. If any step is irreversible,
+ * the confirmation must be the vault's ConfirmationToken, and no steps are taken otherwise.
+ *
+ * If a step fails, the vault is left in the state reached by the previous step.
+ */
+func (machine *Machine) TransitionTo(ctx context.Context, target armdataprotection.ImmutabilityState, confirmation string) (*Result, error) {
+	result, err := machine.Plan(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	irreversible := slices.ContainsFunc(result.Steps, func(step Step) bool { return step.Irreversible })
+	if irreversible && confirmation != ConfirmationToken(machine.backupVaultName) {
+		return nil, fmt.Errorf("%w: %s to %s can't be reversed, confirm it with %q", ErrConfirmationRequired, result.From, result.To, ConfirmationToken(machine.backupVaultName))
+	}
+
+	for _, step := range result.Steps {
+		if err := machine.client.UpdateImmutability(ctx, machine.resourceGroupName, machine.backupVaultName, step.To); err != nil {
+			return nil, err
+		}
+
+		state, err := machine.Current(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if state != step.To {
+			return nil, fmt.Errorf("%w: expected %s after updating from %s, but the vault is %s", ErrStateMismatch, step.To, step.From, state)
+		}
+
+		log.Printf("Immutability of backup vault '%s' changed from %s to %s", machine.backupVaultName, step.From, step.To)
+	}
+
+	return result, nil
+}
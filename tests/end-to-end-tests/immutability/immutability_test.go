@@ -0,0 +1,153 @@
+package immutability
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	disabled = armdataprotection.ImmutabilityStateDisabled
+	unlocked = armdataprotection.ImmutabilityStateUnlocked
+	locked   = armdataprotection.ImmutabilityStateLocked
+)
+
+type fakeClient struct {
+	state   armdataprotection.ImmutabilityState
+	updates []armdataprotection.ImmutabilityState
+
+	// When set, updates succeed without changing the state
+	ignoreUpdates bool
+	updateErr     error
+}
+
+func (client *fakeClient) GetBackupVault(ctx context.Context, resourceGroupName string, backupVaultName string) (*armdataprotection.BackupVaultResource, error) {
+	vault := &armdataprotection.BackupVaultResource{Properties: &armdataprotection.BackupVault{}}
+	if client.state != "" {
+		vault.Properties.SecuritySettings = &armdataprotection.SecuritySettings{
+			ImmutabilitySettings: &armdataprotection.ImmutabilitySettings{State: to.Ptr(client.state)},
+		}
+	}
+
+	return vault, nil
+}
+
+func (client *fakeClient) UpdateImmutability(ctx context.Context, resourceGroupName string, backupVaultName string, state armdataprotection.ImmutabilityState) error {
+	if client.updateErr != nil {
+		return client.updateErr
+	}
+
+	client.updates = append(client.updates, state)
+	if !client.ignoreUpdates {
+		client.state = state
+	}
+
+	return nil
+}
+
+/*
+ * TestPlan tests the steps planned between every pair of states.
+ */
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		from  armdataprotection.ImmutabilityState
+		to    armdataprotection.ImmutabilityState
+		steps []Step
+		err   error
+	}{
+		{disabled, disabled, nil, nil},
+		{disabled, unlocked, []Step{{From: disabled, To: unlocked}}, nil},
+		{disabled, locked, []Step{{From: disabled, To: unlocked}, {From: unlocked, To: locked, Irreversible: true}}, nil},
+		{unlocked, disabled, []Step{{From: unlocked, To: disabled}}, nil},
+		{unlocked, unlocked, nil, nil},
+		{unlocked, locked, []Step{{From: unlocked, To: locked, Irreversible: true}}, nil},
+		{locked, disabled, nil, ErrIllegalTransition},
+		{locked, unlocked, nil, ErrIllegalTransition},
+		{locked, locked, nil, nil},
+		{disabled, "Frozen", nil, ErrIllegalTransition},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.from)+"-"+string(test.to), func(t *testing.T) {
+			steps, err := Plan(test.from, test.to)
+
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.steps, steps)
+		})
+	}
+}
+
+/*
+ * TestTransitionTo tests that each step is applied and confirmed, and that a vault in the
+ * target state is left alone.
+ */
+func TestTransitionTo(t *testing.T) {
+	client := &fakeClient{state: unlocked}
+	machine := New(client, "rg", "bvault")
+
+	result, err := machine.TransitionTo(context.Background(), disabled, "")
+	require.NoError(t, err)
+	assert.Equal(t, unlocked, result.From)
+	assert.Equal(t, disabled, result.To)
+	assert.Equal(t, []armdataprotection.ImmutabilityState{disabled}, client.updates)
+
+	result, err = machine.TransitionTo(context.Background(), disabled, "")
+	require.NoError(t, err)
+	assert.Empty(t, result.Steps)
+	assert.Len(t, client.updates, 1, "Expected no update for a vault already in the target state")
+
+	// A vault without immutability settings is Disabled
+	client = &fakeClient{}
+	state, err := New(client, "rg", "bvault").Current(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, disabled, state)
+}
+
+/*
+ * TestTransitionToRequiresConfirmation tests that locking a vault is refused without the vault's
+ * confirmation token, and goes through Unlocked when the vault is Disabled.
+ */
+func TestTransitionToRequiresConfirmation(t *testing.T) {
+	client := &fakeClient{state: disabled}
+	machine := New(client, "rg", "bvault")
+
+	_, err := machine.TransitionTo(context.Background(), locked, "")
+	assert.ErrorIs(t, err, ErrConfirmationRequired)
+	assert.ErrorContains(t, err, `"lock:bvault"`)
+
+	_, err = machine.TransitionTo(context.Background(), locked, ConfirmationToken("other-vault"))
+	assert.ErrorIs(t, err, ErrConfirmationRequired)
+	assert.Empty(t, client.updates, "Expected no updates without confirmation")
+
+	result, err := machine.TransitionTo(context.Background(), locked, ConfirmationToken("bvault"))
+	require.NoError(t, err)
+	assert.Len(t, result.Steps, 2)
+	assert.Equal(t, []armdataprotection.ImmutabilityState{unlocked, locked}, client.updates)
+
+	// Once locked, nothing can undo it - not even with confirmation
+	_, err = machine.TransitionTo(context.Background(), unlocked, ConfirmationToken("bvault"))
+	assert.ErrorIs(t, err, ErrIllegalTransition)
+	assert.Len(t, client.updates, 2)
+}
+
+/*
+ * TestTransitionToConfirmsState tests that a vault which doesn't report the new state after an
+ * update fails the transition, and that update errors are returned.
+ */
+func TestTransitionToConfirmsState(t *testing.T) {
+	client := &fakeClient{state: disabled, ignoreUpdates: true}
+
+	_, err := New(client, "rg", "bvault").TransitionTo(context.Background(), unlocked, "")
+	assert.ErrorIs(t, err, ErrStateMismatch)
+
+	updateErr := errors.New("update failed")
+	client = &fakeClient{state: disabled, updateErr: updateErr}
+
+	_, err = New(client, "rg", "bvault").TransitionTo(context.Background(), unlocked, "")
+	assert.ErrorIs(t, err, updateErr)
+}
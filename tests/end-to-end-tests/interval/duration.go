@@ -0,0 +1,157 @@
+/*
+ * Package interval parses and validates the ISO 8601 repeating intervals that the module uses
+ * for backup_intervals, e.g. R/2024-01-01T00:00:00+00:00/P1D, and works out when backups
+ * scheduled with them will run.
+ */
+package interval
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Duration is an ISO 8601 duration, such as P1W or PT12H. Fractional components aren't
+ * supported, as the module never uses them.
+ */
+type Duration struct {
+	Years   int
+	Months  int
+	Weeks   int
+	Days    int
+	Hours   int
+	Minutes int
+	Seconds int
+}
+
+var durationPattern = regexp.MustCompile(`^P(?:([0-9]+)Y)?(?:([0-9]+)M)?(?:([0-9]+)W)?(?:([0-9]+)D)?(?:T(?:([0-9]+)H)?(?:([0-9]+)M)?(?:([0-9]+)S)?)?$`)
+
+/*
+ * ParseDuration parses an ISO 8601 duration, e.g. P1D, P1W, PT4H or P1DT12H.
+ */
+func ParseDuration(value string) (Duration, error) {
+	match := durationPattern.FindStringSubmatch(value)
+	if match == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return Duration{}, fmt.Errorf("'%s' is not an ISO 8601 duration", value)
+	}
+
+	components := make([]int, len(match)-1)
+	for i, component := range match[1:] {
+		if component == "" {
+			continue
+		}
+
+		number, err := strconv.Atoi(component)
+		if err != nil {
+			return Duration{}, fmt.Errorf("'%s' is not an ISO 8601 duration: %w", value, err)
+		}
+		components[i] = number
+	}
+
+	duration := Duration{
+		Years:   components[0],
+		Months:  components[1],
+		Weeks:   components[2],
+		Days:    components[3],
+		Hours:   components[4],
+		Minutes: components[5],
+		Seconds: components[6],
+	}
+
+	if duration.IsZero() {
+		return Duration{}, fmt.Errorf("'%s' is a zero length duration", value)
+	}
+
+	return duration, nil
+}
+
+/*
+ * FromDuration converts an elapsed time to a duration in days, hours, minutes and seconds,
+ * dropping any fraction of a second.
+ */
+func FromDuration(elapsed time.Duration) Duration {
+	return Duration{
+		Days:    int(elapsed / (24 * time.Hour)),
+		Hours:   int(elapsed % (24 * time.Hour) / time.Hour),
+		Minutes: int(elapsed % time.Hour / time.Minute),
+		Seconds: int(elapsed % time.Minute / time.Second),
+	}
+}
+
+/*
+ * IsZero reports whether the duration has no length.
+ */
+func (d Duration) IsZero() bool {
+	return d == Duration{}
+}
+
+/*
+ * String formats the duration in ISO 8601 form, omitting zero components.
+ */
+func (d Duration) String() string {
+	if d.IsZero() {
+		return "PT0S"
+	}
+
+	var builder strings.Builder
+	builder.WriteString("P")
+
+	for _, component := range []struct {
+		value  int
+		suffix string
+	}{{d.Years, "Y"}, {d.Months, "M"}, {d.Weeks, "W"}, {d.Days, "D"}} {
+		if component.value != 0 {
+			fmt.Fprintf(&builder, "%d%s", component.value, component.suffix)
+		}
+	}
+
+	if d.Hours != 0 || d.Minutes != 0 || d.Seconds != 0 {
+		builder.WriteString("T")
+		for _, component := range []struct {
+			value  int
+			suffix string
+		}{{d.Hours, "H"}, {d.Minutes, "M"}, {d.Seconds, "S"}} {
+			if component.value != 0 {
+				fmt.Fprintf(&builder, "%d%s", component.value, component.suffix)
+			}
+		}
+	}
+
+	return builder.String()
+}
+
+/*
+ * Equal reports whether two durations are the same length, so that P1W equals P7D and PT1H
+ * equals PT60M. Calendar components (years, months and days) are never equated with time
+ * components, as a day isn't always 24 hours long.
+ */
+func (d Duration) Equal(other Duration) bool {
+	return d.Years*12+d.Months == other.Years*12+other.Months &&
+		d.Weeks*7+d.Days == other.Weeks*7+other.Days &&
+		d.clockDuration() == other.clockDuration()
+}
+
+/*
+ * AddTo adds the duration to a time the provided number of times. Calendar components are
+ * added to the wall clock in the time's location, so a daily interval keeps the same local
+ * time across daylight saving changes, whereas time components are added as elapsed time.
+ */
+func (d Duration) AddTo(t time.Time, times int) time.Time {
+	return t.AddDate(d.Years*times, d.Months*times, (d.Weeks*7+d.Days)*times).Add(d.clockDuration() * time.Duration(times))
+}
+
+/*
+ * Approximate gets the nominal length of the duration, taking a day as 24 hours, a month as
+ * 30 days and a year as 365 days.
+ */
+func (d Duration) Approximate() time.Duration {
+	days := d.Years*365 + d.Months*30 + d.Weeks*7 + d.Days
+	return time.Duration(days)*24*time.Hour + d.clockDuration()
+}
+
+func (d Duration) clockDuration() time.Duration {
+	return time.Duration(d.Hours)*time.Hour + time.Duration(d.Minutes)*time.Minute + time.Duration(d.Seconds)*time.Second
+}
@@ -0,0 +1,86 @@
+package interval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+ * TestParseDuration tests that ISO 8601 durations are parsed into their components and
+ * formatted back to the same text.
+ */
+func TestParseDuration(t *testing.T) {
+	cases := map[string]Duration{
+		"P1D":       {Days: 1},
+		"P1W":       {Weeks: 1},
+		"P6D":       {Days: 6},
+		"PT12H":     {Hours: 12},
+		"P1Y2M":     {Years: 1, Months: 2},
+		"P1DT2H30M": {Days: 1, Hours: 2, Minutes: 30},
+		"PT45S":     {Seconds: 45},
+	}
+
+	for value, expected := range cases {
+		duration, err := ParseDuration(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, duration, value)
+		assert.Equal(t, value, duration.String())
+	}
+}
+
+/*
+ * TestParseDurationInvalid tests that text which isn't an ISO 8601 duration is rejected.
+ */
+func TestParseDurationInvalid(t *testing.T) {
+	for _, value := range []string{"", "P", "PT", "1D", "P1H", "PT1D", "P1.5D", "P-1D", "P0D", "p1d"} {
+		_, err := ParseDuration(value)
+		assert.Error(t, err, value)
+	}
+}
+
+/*
+ * TestDurationEqual tests that durations of the same length are equal however they're written,
+ * but that days and hours aren't equated.
+ */
+func TestDurationEqual(t *testing.T) {
+	parse := func(value string) Duration {
+		duration, err := ParseDuration(value)
+		require.NoError(t, err)
+		return duration
+	}
+
+	assert.True(t, parse("P1W").Equal(parse("P7D")))
+	assert.True(t, parse("PT1H").Equal(parse("PT60M")))
+	assert.True(t, parse("P1Y").Equal(parse("P12M")))
+	assert.False(t, parse("P1D").Equal(parse("PT24H")))
+	assert.False(t, parse("PT1H").Equal(parse("PT2H")))
+}
+
+/*
+ * TestDurationAddTo tests that calendar components keep the local time across a daylight
+ * saving change, whereas time components add elapsed time.
+ */
+func TestDurationAddTo(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	// The clocks go forward at 01:00 on 2024-03-31 in London
+	start := time.Date(2024, 3, 30, 12, 0, 0, 0, london)
+
+	assert.Equal(t, time.Date(2024, 3, 31, 12, 0, 0, 0, london), Duration{Days: 1}.AddTo(start, 1))
+	assert.Equal(t, time.Date(2024, 3, 31, 13, 0, 0, 0, london), Duration{Hours: 24}.AddTo(start, 1))
+	assert.Equal(t, time.Date(2024, 4, 13, 12, 0, 0, 0, london), Duration{Weeks: 1}.AddTo(start, 2))
+}
+
+/*
+ * TestFromDuration tests that an elapsed time is converted to days and time components.
+ */
+func TestFromDuration(t *testing.T) {
+	assert.Equal(t, "PT4H", FromDuration(4*time.Hour).String())
+	assert.Equal(t, "P4D", FromDuration(96*time.Hour).String())
+	assert.Equal(t, "P3DT12H30M", FromDuration(84*time.Hour+30*time.Minute).String())
+	assert.Equal(t, "PT0S", FromDuration(time.Millisecond).String())
+}
@@ -0,0 +1,250 @@
+package interval
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * The datasource types that the module backs up.
+ */
+const (
+	DatasourceBlobStorage              = "Microsoft.Storage/storageAccounts/blobServices"
+	DatasourceDataLakeStorage          = "Microsoft.Storage/storageAccounts/adlsBlobServices"
+	DatasourceManagedDisk              = "Microsoft.Compute/disks"
+	DatasourcePostgresqlFlexibleServer = "Microsoft.DBforPostgreSQL/flexibleServers"
+	DatasourceMysqlFlexibleServer      = "Microsoft.DBforMySQL/flexibleServers"
+	DatasourceAksCluster               = "Microsoft.ContainerService/managedClusters"
+	DatasourceVirtualMachine           = "Microsoft.Compute/virtualMachines"
+	DatasourceFileShare                = "Microsoft.Storage/storageAccounts/fileServices/shares"
+)
+
+/*
+ * The backup frequencies that are valid for each datasource type, as per
+ * local.valid_*_intervals in infrastructure/variables.tf.
+ */
+var allowedPeriods = map[string][]string{
+	DatasourceBlobStorage:              {"P1D", "P1W"},
+	DatasourceDataLakeStorage:          {"P1D", "P1W"},
+	DatasourceManagedDisk:              {"PT1H", "PT2H", "PT4H", "PT6H", "PT8H", "PT12H", "P1D"},
+	DatasourcePostgresqlFlexibleServer: {"P1W"},
+	DatasourceMysqlFlexibleServer:      {"P1W"},
+	DatasourceAksCluster:               {"PT4H", "PT6H", "PT8H", "PT12H", "P1D"},
+	DatasourceVirtualMachine:           {"P1D"},
+	DatasourceFileShare:                {"P1D"},
+}
+
+var datasourceNames = map[string]string{
+	DatasourceBlobStorage:              "blob storage",
+	DatasourceDataLakeStorage:          "data lake storage",
+	DatasourceManagedDisk:              "managed disk",
+	DatasourcePostgresqlFlexibleServer: "PostgreSQL flexible server",
+	DatasourceMysqlFlexibleServer:      "MySQL flexible server",
+	DatasourceAksCluster:               "AKS cluster",
+	DatasourceVirtualMachine:           "virtual machine",
+	DatasourceFileShare:                "file share",
+}
+
+/*
+ * Repeating interval format: R[n]/<timestamp>/<duration>. The timestamp has whole seconds and
+ * a Z or numeric offset, as per local.backup_interval_timestamp_pattern in
+ * infrastructure/variables.tf.
+ */
+var repeatingIntervalPattern = regexp.MustCompile(`^R([0-9]*)/([0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(?:Z|[+-][0-9]{2}:[0-9]{2}))/([^/]+)$`)
+
+const timestampLayout = "2006-01-02T15:04:05Z07:00"
+
+/*
+ * RepeatingInterval is an ISO 8601 repeating interval, e.g. R/2024-01-01T00:00:00+00:00/P1D,
+ * which repeats the period from the start time.
+ */
+type RepeatingInterval struct {
+	// The number of repetitions, or -1 when the interval repeats forever (R/)
+	Repetitions int
+	Start       time.Time
+	Period      Duration
+}
+
+/*
+ * Parse parses an ISO 8601 repeating interval of the form R[n]/<timestamp>/<duration>.
+ */
+func Parse(value string) (RepeatingInterval, error) {
+	match := repeatingIntervalPattern.FindStringSubmatch(value)
+	if match == nil {
+		return RepeatingInterval{}, fmt.Errorf("'%s' is not a repeating interval of the form R/<timestamp>/<duration>", value)
+	}
+
+	interval := RepeatingInterval{Repetitions: -1}
+
+	if match[1] != "" {
+		repetitions, err := strconv.Atoi(match[1])
+		if err != nil {
+			return RepeatingInterval{}, fmt.Errorf("'%s' has an invalid number of repetitions: %w", value, err)
+		}
+		interval.Repetitions = repetitions
+	}
+
+	start, err := time.Parse(timestampLayout, match[2])
+	if err != nil {
+		return RepeatingInterval{}, fmt.Errorf("'%s' has an invalid start time: %w", value, err)
+	}
+	interval.Start = start
+
+	period, err := ParseDuration(match[3])
+	if err != nil {
+		return RepeatingInterval{}, fmt.Errorf("'%s' has an invalid period: %w", value, err)
+	}
+	interval.Period = period
+
+	return interval, nil
+}
+
+/*
+ * String formats the interval as ISO 8601, with a numeric offset for the start time as the
+ * module's examples use, e.g. R/2024-01-01T00:00:00+00:00/P1D.
+ */
+func (interval RepeatingInterval) String() string {
+	repetitions := ""
+	if interval.Repetitions >= 0 {
+		repetitions = strconv.Itoa(interval.Repetitions)
+	}
+
+	return fmt.Sprintf("R%s/%s/%s", repetitions, interval.Start.Format("2006-01-02T15:04:05-07:00"), interval.Period)
+}
+
+/*
+ * Equal reports whether two intervals schedule the same runs - they start at the same instant,
+ * whatever the offset, and repeat equal periods the same number of times.
+ */
+func (interval RepeatingInterval) Equal(other RepeatingInterval) bool {
+	return interval.Repetitions == other.Repetitions && interval.Start.Equal(other.Start) && interval.Period.Equal(other.Period)
+}
+
+/*
+ * NextRuns gets the next runs of the interval after the provided time, in the provided time
+ * zone. Calendar periods keep the same wall clock time in that zone across daylight saving
+ * changes. Fewer than count runs are returned when the interval runs out of repetitions.
+ */
+func (interval RepeatingInterval) NextRuns(after time.Time, count int, location *time.Location) []time.Time {
+	if count <= 0 || interval.Period.IsZero() {
+		return nil
+	}
+
+	start := interval.Start.In(location)
+
+	// Skip ahead to about the first run, rather than stepping through every run since the start.
+	// Months and years are only approximately 30 and 365 days long, so the estimate can overshoot
+	// by more runs the further it skips, and it's stepped back until it's no later than after.
+	index := 0
+	if elapsed := after.Sub(start); elapsed > 0 {
+		index = int(elapsed / interval.Period.Approximate())
+		for index > 0 && interval.Period.AddTo(start, index).After(after) {
+			index--
+		}
+	}
+
+	var runs []time.Time
+	for ; len(runs) < count; index++ {
+		if interval.Repetitions >= 0 && index > interval.Repetitions {
+			break
+		}
+
+		if run := interval.Period.AddTo(start, index); run.After(after) {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs
+}
+
+/*
+ * RunsBetween gets the runs of the interval from the provided time up to, but not including,
+ * the end time, in the provided time zone.
+ */
+func (interval RepeatingInterval) RunsBetween(from time.Time, to time.Time, location *time.Location) []time.Time {
+	var runs []time.Time
+
+	after := from.Add(-time.Nanosecond)
+	for {
+		next := interval.NextRuns(after, 1, location)
+		if len(next) == 0 || !next[0].Before(to) {
+			return runs
+		}
+
+		runs = append(runs, next[0])
+		after = next[0]
+	}
+}
+
+/*
+ * MergedRunsBetween gets the runs of all the provided intervals from the provided time up to,
+ * but not including, the end time, in the provided time zone. The runs are in order, and runs
+ * of more than one interval at the same time appear once, as they're a single backup.
+ */
+func MergedRunsBetween(intervals []RepeatingInterval, from time.Time, to time.Time, location *time.Location) []time.Time {
+	var runs []time.Time
+	for _, interval := range intervals {
+		runs = append(runs, interval.RunsBetween(from, to, location)...)
+	}
+
+	slices.SortFunc(runs, func(a, b time.Time) int { return a.Compare(b) })
+
+	return slices.CompactFunc(runs, time.Time.Equal)
+}
+
+/*
+ * Location loads the time zone of a backup schedule, falling back to UTC when there isn't one
+ * or it can't be loaded. Azure also accepts Windows time zone names, which can't be loaded.
+ */
+func Location(timeZone string) *time.Location {
+	if timeZone == "" {
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+/*
+ * AllowedPeriods gets the backup frequencies that the module allows for the provided
+ * datasource type.
+ */
+func AllowedPeriods(datasourceType string) []string {
+	return slices.Clone(allowedPeriods[datasourceType])
+}
+
+/*
+ * Validate parses a backup interval and checks it against the rules that the module applies
+ * for the provided datasource type - it must repeat forever, and its period must be one of the
+ * allowed frequencies written exactly as the module expects (e.g. P1W rather than P7D).
+ */
+func Validate(value string, datasourceType string) (RepeatingInterval, error) {
+	allowed, ok := allowedPeriods[datasourceType]
+	if !ok {
+		return RepeatingInterval{}, fmt.Errorf("datasource type '%s' isn't supported by the module", datasourceType)
+	}
+
+	interval, err := Parse(value)
+	if err != nil {
+		return RepeatingInterval{}, err
+	}
+
+	if interval.Repetitions >= 0 {
+		return interval, fmt.Errorf("'%s' repeats %d times, but backup intervals must repeat forever (R/)", value, interval.Repetitions)
+	}
+
+	if !slices.Contains(allowed, interval.Period.String()) || !strings.HasSuffix(value, "/"+interval.Period.String()) {
+		return interval, fmt.Errorf("'%s' has the frequency %s, but the allowed frequencies for %s are %s",
+			value, value[strings.LastIndex(value, "/")+1:], datasourceNames[datasourceType], strings.Join(allowed, ", "))
+	}
+
+	return interval, nil
+}
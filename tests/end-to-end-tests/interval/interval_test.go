@@ -0,0 +1,204 @@
+package interval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+ * TestParse tests that a repeating interval is parsed into its repetitions, start time and
+ * period, and formatted back to the same text.
+ */
+func TestParse(t *testing.T) {
+	interval, err := Parse("R/2024-01-01T00:00:00+00:00/P1D")
+	require.NoError(t, err)
+
+	assert.Equal(t, -1, interval.Repetitions)
+	assert.True(t, interval.Start.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, Duration{Days: 1}, interval.Period)
+	assert.Equal(t, "R/2024-01-01T00:00:00+00:00/P1D", interval.String())
+
+	interval, err = Parse("R5/2024-06-01T22:30:00-05:00/PT4H")
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, interval.Repetitions)
+	assert.True(t, interval.Start.Equal(time.Date(2024, 6, 2, 3, 30, 0, 0, time.UTC)))
+	assert.Equal(t, "R5/2024-06-01T22:30:00-05:00/PT4H", interval.String())
+}
+
+/*
+ * TestParseInvalid tests that text which isn't a repeating interval is rejected.
+ */
+func TestParseInvalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"P1D",
+		"R/2024-01-01/P1D",
+		"R/2024-01-01T00:00:00/P1D",
+		"R/2024-01-01T00:00:00.5Z/P1D",
+		"R/2024-13-01T00:00:00Z/P1D",
+		"R/2024-01-01T00:00:00Z/1D",
+		"R/2024-01-01T00:00:00Z/P1D/P1D",
+	} {
+		_, err := Parse(value)
+		assert.Error(t, err, value)
+	}
+}
+
+/*
+ * TestEqual tests that intervals which schedule the same runs are equal however they're written.
+ */
+func TestEqual(t *testing.T) {
+	parse := func(value string) RepeatingInterval {
+		interval, err := Parse(value)
+		require.NoError(t, err)
+		return interval
+	}
+
+	assert.True(t, parse("R/2024-01-01T00:00:00+00:00/P1W").Equal(parse("R/2024-01-01T00:00:00Z/P7D")))
+	assert.True(t, parse("R/2024-01-01T01:00:00+01:00/PT1H").Equal(parse("R/2024-01-01T00:00:00Z/PT60M")))
+	assert.False(t, parse("R/2024-01-01T00:00:00Z/P1D").Equal(parse("R/2024-01-02T00:00:00Z/P1D")))
+	assert.False(t, parse("R/2024-01-01T00:00:00Z/P1D").Equal(parse("R3/2024-01-01T00:00:00Z/P1D")))
+}
+
+/*
+ * TestNextRuns tests that the next runs are worked out in the provided time zone, keeping the
+ * same local time across a daylight saving change.
+ */
+func TestNextRuns(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	interval, err := Parse("R/2024-01-01T02:00:00+00:00/P1D")
+	require.NoError(t, err)
+
+	runs := interval.NextRuns(time.Date(2024, 3, 29, 12, 0, 0, 0, time.UTC), 3, london)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 3, 30, 2, 0, 0, 0, london),
+		time.Date(2024, 3, 31, 2, 0, 0, 0, london),
+		time.Date(2024, 4, 1, 2, 0, 0, 0, london),
+	}, runs)
+
+	hourly, err := Parse("R/2024-01-01T00:00:00Z/PT4H")
+	require.NoError(t, err)
+
+	runs = hourly.NextRuns(time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC), 2, time.UTC)
+	assert.Equal(t, []time.Time{
+		time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 1, 16, 0, 0, 0, time.UTC),
+	}, runs)
+
+	// A century of months is over 16 runs more than a century of 30 day months
+	monthly, err := Parse("R/2000-01-01T00:00:00Z/P1M")
+	require.NoError(t, err)
+
+	runs = monthly.NextRuns(time.Date(2099, 12, 15, 0, 0, 0, 0, time.UTC), 2, time.UTC)
+	assert.Equal(t, []time.Time{
+		time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2100, 2, 1, 0, 0, 0, 0, time.UTC),
+	}, runs)
+
+	bounded, err := Parse("R2/2024-01-01T00:00:00Z/P1W")
+	require.NoError(t, err)
+
+	assert.Len(t, bounded.NextRuns(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 5, time.UTC), 3)
+	assert.Empty(t, bounded.NextRuns(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 5, time.UTC))
+}
+
+/*
+ * TestRunsBetween tests that the runs within a window are found, including a run at the start
+ * of the window but not one at the end.
+ */
+func TestRunsBetween(t *testing.T) {
+	interval, err := Parse("R/2024-01-01T00:00:00Z/PT12H")
+	require.NoError(t, err)
+
+	runs := interval.RunsBetween(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC), time.UTC)
+	assert.Equal(t, []time.Time{
+		time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+	}, runs)
+
+	assert.Empty(t, interval.RunsBetween(time.Date(2025, 6, 1, 1, 0, 0, 0, time.UTC), time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), time.UTC))
+}
+
+/*
+ * TestMergedRunsBetween tests that the runs of several intervals are ordered, and that runs at
+ * the same time appear once.
+ */
+func TestMergedRunsBetween(t *testing.T) {
+	var intervals []RepeatingInterval
+	for _, value := range []string{"R/2024-01-04T00:00:00Z/P1W", "R/2024-01-01T00:00:00Z/P1W", "R/2024-01-01T00:00:00Z/P1D"} {
+		interval, err := Parse(value)
+		require.NoError(t, err)
+		intervals = append(intervals, interval)
+	}
+
+	runs := MergedRunsBetween(intervals[:2], time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC), time.UTC)
+	assert.Equal(t, []time.Time{
+		time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 12, 0, 0, 0, 0, time.UTC),
+	}, runs)
+
+	runs = MergedRunsBetween(intervals[1:], time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC), time.UTC)
+	assert.Equal(t, []time.Time{
+		time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 4, 0, 0, 0, 0, time.UTC),
+	}, runs)
+}
+
+/*
+ * TestLocation tests that a schedule without a time zone, or with one that can't be loaded,
+ * falls back to UTC.
+ */
+func TestLocation(t *testing.T) {
+	assert.Equal(t, "Europe/London", Location("Europe/London").String())
+	assert.Equal(t, time.UTC, Location(""))
+	assert.Equal(t, time.UTC, Location("GMT Standard Time"))
+}
+
+/*
+ * TestValidate tests that intervals are checked against the frequencies the module allows for
+ * each datasource type.
+ */
+func TestValidate(t *testing.T) {
+	valid := map[string][]string{
+		DatasourceBlobStorage:              {"R/2024-01-01T00:00:00+00:00/P1D", "R/2024-01-01T00:00:00Z/P1W"},
+		DatasourceManagedDisk:              {"R/2024-01-01T00:00:00+00:00/PT1H", "R/2024-01-01T00:00:00+00:00/PT12H", "R/2024-01-01T00:00:00+00:00/P1D"},
+		DatasourcePostgresqlFlexibleServer: {"R/2024-01-01T00:00:00+00:00/P1W"},
+		DatasourceMysqlFlexibleServer:      {"R/2024-01-01T00:00:00+00:00/P1W"},
+		DatasourceDataLakeStorage:          {"R/2024-01-01T00:00:00+00:00/P1D"},
+		DatasourceAksCluster:               {"R/2024-01-01T00:00:00+00:00/PT4H", "R/2024-01-01T00:00:00+00:00/P1D"},
+		DatasourceVirtualMachine:           {"R/2024-01-01T00:00:00+00:00/P1D"},
+		DatasourceFileShare:                {"R/2024-01-01T22:30:00+01:00/P1D"},
+	}
+
+	for datasourceType, values := range valid {
+		for _, value := range values {
+			_, err := Validate(value, datasourceType)
+			assert.NoError(t, err, value)
+		}
+	}
+
+	_, err := Validate("R/2024-01-01T00:00:00+00:00/PT1H", DatasourceBlobStorage)
+	assert.EqualError(t, err, "'R/2024-01-01T00:00:00+00:00/PT1H' has the frequency PT1H, but the allowed frequencies for blob storage are P1D, P1W")
+
+	_, err = Validate("R/2024-01-01T00:00:00+00:00/P7D", DatasourcePostgresqlFlexibleServer)
+	assert.ErrorContains(t, err, "allowed frequencies for PostgreSQL flexible server are P1W")
+
+	_, err = Validate("R/2024-01-01T00:00:00+00:00/PT60M", DatasourceManagedDisk)
+	assert.ErrorContains(t, err, "has the frequency PT60M")
+
+	_, err = Validate("R3/2024-01-01T00:00:00+00:00/P1D", DatasourceManagedDisk)
+	assert.ErrorContains(t, err, "must repeat forever")
+
+	_, err = Validate("R/2024-01-01T00:00:00+00:00/P1D", "Microsoft.Sql/servers")
+	assert.ErrorContains(t, err, "isn't supported by the module")
+}
@@ -0,0 +1,331 @@
+/*
+ * Package janitor removes the resources that end to end tests leave behind when they fail
+ * between setup and teardown, e.g. because of a panic. Each test creates a resource group
+ * named rg-nhsbackup-<id>, an external resource group named rg-nhsbackup-<id>-external and
+ * a terraform state blob named bvault-nhsbackup-<id>.tfstate, where <id> is the unique ID
+ * generated for the test run.
+ */
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"e2e_tests/azure"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+var (
+	// Matches the resource groups created by the end to end tests, capturing the unique ID
+	resourceGroupPattern = regexp.MustCompile(`^rg-nhsbackup-([A-Za-z0-9]{6})(-external)?$`)
+
+	// Matches the terraform state blobs written by the end to end tests, capturing the unique ID
+	stateBlobPattern = regexp.MustCompile(`^bvault-nhsbackup-([A-Za-z0-9]{6})\.tfstate$`)
+)
+
+/*
+ * Options controls which resources the janitor removes.
+ */
+type Options struct {
+	// Resources younger than this are left alone, as they may belong to a test that's running
+	MinAge time.Duration
+
+	// Report what would be removed without removing anything
+	DryRun bool
+
+	// The storage account and container holding the terraform state. State blobs aren't
+	// removed when these aren't set.
+	StateStorageAccount string
+	StateContainer      string
+}
+
+/*
+ * DefaultOptions gets options which leave resources created in the last day alone.
+ */
+func DefaultOptions() Options {
+	return Options{
+		MinAge: 24 * time.Hour,
+	}
+}
+
+/*
+ * Run finds the resource groups and state blobs left behind by the end to end tests that are
+ * older than the minimum age, and removes them. Backup vaults are emptied and removed before
+ * their resource group, disabling immutability and soft delete where the vault allows it.
+ *
+ * A failure to remove one resource is recorded in the report and doesn't stop the others
+ * being removed. An error is only returned if the resources can't be listed.
+ */
+func Run(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, options Options) (*Report, error) {
+	janitor := &janitor{
+		ctx:            ctx,
+		credential:     credential,
+		clientOptions:  clientOptions,
+		subscriptionID: subscriptionID,
+		options:        options,
+		report:         &Report{DryRun: options.DryRun},
+	}
+
+	resourceGroups, err := azure.ListResourceGroups(ctx, credential, clientOptions, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort by name so that a test's resource group is cleaned before its external resource group
+	slices.SortFunc(resourceGroups, func(a, b *armresources.ResourceGroup) int { return strings.Compare(*a.Name, *b.Name) })
+
+	// The state blobs are listed up front, as they give the age of a resource group with nothing in it
+	if options.StateStorageAccount != "" && options.StateContainer != "" {
+		blobs, err := azure.ListBlobsInStorageAccountContainer(ctx, credential, clientOptions, options.StateStorageAccount, options.StateContainer, "bvault-nhsbackup-")
+		if err != nil {
+			return nil, err
+		}
+
+		janitor.stateBlobs = blobs
+	}
+
+	existingIDs := map[string]bool{}
+	cleanedIDs := map[string]bool{}
+
+	for _, resourceGroup := range resourceGroups {
+		match := resourceGroupPattern.FindStringSubmatch(*resourceGroup.Name)
+		if match == nil {
+			continue
+		}
+
+		isTestResourceGroup := match[2] == ""
+		if isTestResourceGroup {
+			existingIDs[match[1]] = true
+		}
+
+		if janitor.cleanResourceGroup(resourceGroup, match[1]) && isTestResourceGroup {
+			cleanedIDs[match[1]] = true
+		}
+	}
+
+	janitor.cleanStateBlobs(existingIDs, cleanedIDs)
+
+	return janitor.report, nil
+}
+
+type janitor struct {
+	ctx            context.Context
+	credential     azcore.TokenCredential
+	clientOptions  *arm.ClientOptions
+	subscriptionID string
+	options        Options
+	stateBlobs     []*container.BlobItem
+	report         *Report
+}
+
+/*
+ * Removes a resource group if it's old enough, emptying any backup vaults within it first,
+ * and reports whether the group was removed (or would be on a dry run).
+ */
+func (j *janitor) cleanResourceGroup(resourceGroup *armresources.ResourceGroup, id string) bool {
+	resourceGroupID := *resourceGroup.ID
+
+	resources, err := azure.ListResourcesInResourceGroup(j.ctx, j.credential, j.clientOptions, j.subscriptionID, *resourceGroup.Name)
+	if err != nil {
+		j.report.add(resourceGroupID, OperationDeleteResourceGroup, StatusFailed, err.Error())
+		return false
+	}
+
+	createdTime, ok := j.createdTime(resourceGroup, resources, id)
+	if !ok {
+		j.report.add(resourceGroupID, OperationDeleteResourceGroup, StatusSkipped, "Resource group has no creation tag, resources or state blob to work out its age from")
+		return false
+	}
+
+	if age := time.Since(createdTime); age < j.options.MinAge {
+		j.report.add(resourceGroupID, OperationDeleteResourceGroup, StatusSkipped,
+			fmt.Sprintf("Resource group is %s old, which is younger than %s", age.Round(time.Minute), j.options.MinAge))
+		return false
+	}
+
+	vaults, err := azure.ListBackupVaults(j.ctx, j.credential, j.clientOptions, j.subscriptionID, *resourceGroup.Name)
+	if err != nil {
+		j.report.add(resourceGroupID, OperationDeleteResourceGroup, StatusFailed, err.Error())
+		return false
+	}
+
+	for _, vault := range vaults {
+		if !j.cleanBackupVault(*resourceGroup.Name, vault) {
+			j.report.add(resourceGroupID, OperationDeleteResourceGroup, StatusFailed,
+				fmt.Sprintf("Resource group holds backup vault '%s', which couldn't be deleted", *vault.Name))
+			return false
+		}
+	}
+
+	return j.do(resourceGroupID, OperationDeleteResourceGroup, func() error {
+		return azure.DeleteResourceGroup(j.ctx, j.credential, j.clientOptions, j.subscriptionID, *resourceGroup.Name)
+	})
+}
+
+/*
+ * Removes a backup vault and its backup instances, first disabling immutability and soft
+ * delete so that the instances can be removed, and reports whether the vault was removed.
+ * A vault whose immutability is locked, or whose soft delete is always on, can't be removed.
+ */
+func (j *janitor) cleanBackupVault(resourceGroupName string, vault *armdataprotection.BackupVaultResource) bool {
+	vaultID := *vault.ID
+
+	var immutability armdataprotection.ImmutabilityState
+	var softDelete armdataprotection.SoftDeleteState
+	if vault.Properties != nil && vault.Properties.SecuritySettings != nil {
+		if settings := vault.Properties.SecuritySettings.ImmutabilitySettings; settings != nil && settings.State != nil {
+			immutability = *settings.State
+		}
+		if settings := vault.Properties.SecuritySettings.SoftDeleteSettings; settings != nil && settings.State != nil {
+			softDelete = *settings.State
+		}
+	}
+
+	switch immutability {
+	case armdataprotection.ImmutabilityStateLocked:
+		j.report.add(vaultID, OperationDisableImmutability, StatusFailed, "Immutability is Locked, which can't be reversed")
+		return false
+	case armdataprotection.ImmutabilityStateUnlocked:
+		disabled := j.do(vaultID, OperationDisableImmutability, func() error {
+			return azure.UpdateBackupVaultImmutability(j.ctx, j.credential, j.clientOptions, j.subscriptionID, resourceGroupName, *vault.Name, armdataprotection.ImmutabilitySettings{
+				State: to.Ptr(armdataprotection.ImmutabilityStateDisabled),
+			})
+		})
+		if !disabled {
+			return false
+		}
+	}
+
+	switch softDelete {
+	case armdataprotection.SoftDeleteStateAlwaysOn:
+		j.report.add(vaultID, OperationDisableSoftDelete, StatusFailed, "Soft delete is AlwaysOn, which can't be reversed")
+		return false
+	case armdataprotection.SoftDeleteStateOn:
+		disabled := j.do(vaultID, OperationDisableSoftDelete, func() error {
+			return azure.UpdateBackupVaultSoftDelete(j.ctx, j.credential, j.clientOptions, j.subscriptionID, resourceGroupName, *vault.Name, armdataprotection.SoftDeleteSettings{
+				State: to.Ptr(armdataprotection.SoftDeleteStateOff),
+			})
+		})
+		if !disabled {
+			return false
+		}
+	}
+
+	instances, err := azure.GetBackupInstances(j.ctx, j.credential, j.clientOptions, j.subscriptionID, resourceGroupName, *vault.Name)
+	if err != nil {
+		j.report.add(vaultID, OperationDeleteBackupVault, StatusFailed, err.Error())
+		return false
+	}
+
+	deletedInstances := true
+	for _, instance := range instances {
+		deletedInstances = j.do(*instance.ID, OperationDeleteBackupInstance, func() error {
+			return azure.DeleteBackupInstance(j.ctx, j.credential, j.clientOptions, j.subscriptionID, resourceGroupName, *vault.Name, *instance.Name)
+		}) && deletedInstances
+	}
+
+	if !deletedInstances {
+		j.report.add(vaultID, OperationDeleteBackupVault, StatusFailed, "Backup vault holds backup instances which couldn't be deleted")
+		return false
+	}
+
+	return j.do(vaultID, OperationDeleteBackupVault, func() error {
+		return azure.DeleteBackupVault(j.ctx, j.credential, j.clientOptions, j.subscriptionID, resourceGroupName, *vault.Name)
+	})
+}
+
+/*
+ * Removes the state blobs for the tests whose resource group was removed, along with those
+ * older than the minimum age whose resource group no longer exists.
+ */
+func (j *janitor) cleanStateBlobs(existingIDs map[string]bool, cleanedIDs map[string]bool) {
+	for _, blob := range j.stateBlobs {
+		match := stateBlobPattern.FindStringSubmatch(*blob.Name)
+		if match == nil {
+			continue
+		}
+
+		id := match[1]
+		orphaned := !existingIDs[id] && blob.Properties != nil && blob.Properties.LastModified != nil &&
+			time.Since(*blob.Properties.LastModified) >= j.options.MinAge
+
+		if !cleanedIDs[id] && !orphaned {
+			continue
+		}
+
+		blobID := fmt.Sprintf("%s/%s/%s", j.options.StateStorageAccount, j.options.StateContainer, *blob.Name)
+		j.do(blobID, OperationDeleteStateBlob, func() error {
+			return azure.DeleteBlobFromStorageAccount(j.ctx, j.credential, j.clientOptions, j.options.StateStorageAccount, j.options.StateContainer, *blob.Name)
+		})
+	}
+}
+
+/*
+ * Performs an operation against a resource unless this is a dry run, records the outcome in
+ * the report, and reports whether the operation succeeded (or would have been attempted).
+ */
+func (j *janitor) do(resourceID string, operation Operation, perform func() error) bool {
+	if j.options.DryRun {
+		j.report.add(resourceID, operation, StatusDryRun, "")
+		return true
+	}
+
+	if err := perform(); err != nil {
+		j.report.add(resourceID, operation, StatusFailed, err.Error())
+		return false
+	}
+
+	j.report.add(resourceID, operation, StatusDone, "")
+	return true
+}
+
+/*
+ * Gets the time that a resource group was created. This is recorded in a tag on the groups
+ * created through azure.CreateResourceGroup. For the groups created by terraform, it's taken
+ * as the time the earliest resource within the group was created or, where the group is
+ * empty, the time the test's state blob was last written.
+ */
+func (j *janitor) createdTime(resourceGroup *armresources.ResourceGroup, resources []*armresources.GenericResourceExpanded, id string) (time.Time, bool) {
+	if tag := resourceGroup.Tags[azure.CreatedTimeTag]; tag != nil {
+		if createdTime, err := time.Parse(time.RFC3339, *tag); err == nil {
+			return createdTime, true
+		}
+	}
+
+	if createdTime, ok := earliestCreatedTime(resources); ok {
+		return createdTime, true
+	}
+
+	for _, blob := range j.stateBlobs {
+		match := stateBlobPattern.FindStringSubmatch(*blob.Name)
+		if match != nil && match[1] == id && blob.Properties != nil && blob.Properties.LastModified != nil {
+			return *blob.Properties.LastModified, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+/*
+ * Gets the time that the earliest of the provided resources was created.
+ */
+func earliestCreatedTime(resources []*armresources.GenericResourceExpanded) (time.Time, bool) {
+	var earliest time.Time
+	for _, resource := range resources {
+		if resource.CreatedTime != nil && (earliest.IsZero() || resource.CreatedTime.Before(earliest)) {
+			earliest = *resource.CreatedTime
+		}
+	}
+
+	return earliest, !earliest.IsZero()
+}
@@ -0,0 +1,250 @@
+package janitor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"e2e_tests/azure"
+	"e2e_tests/emulator"
+	"e2e_tests/emulator/emulatortest"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testStateStorageAccount = "satfstate"
+	testStateContainer      = "tfstate"
+)
+
+/*
+ * Deploys the resources that an end to end test with the provided unique ID would leave
+ * behind into the emulator - a resource group holding a vault with a recovery point, an
+ * external resource group and a terraform state blob - and returns a credential for it.
+ */
+func deployOrphanedTestResources(t *testing.T, uniqueId string, immutability string, softDelete string) azcore.TokenCredential {
+	credential, err := emulatortest.Shared().Credential()
+	require.NoError(t, err)
+
+	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
+	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
+	backupVaultName := fmt.Sprintf("bvault-nhsbackup-%s", uniqueId)
+	storageAccountName := fmt.Sprintf("sa%sexternal", strings.ToLower(uniqueId))
+
+	_, err = azure.CreateResourceGroup(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, externalResourceGroupName, "uksouth")
+	require.NoError(t, err)
+
+	storageAccount, err := azure.CreateStorageAccount(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, externalResourceGroupName, storageAccountName, "uksouth", nil)
+	require.NoError(t, err)
+
+	variables := emulatortest.Variables(resourceGroupName, backupVaultName)
+	variables["backup_vault_immutability"] = immutability
+	variables["backup_vault_soft_delete"] = softDelete
+	variables["blob_storage_backups"] = map[string]map[string]interface{}{
+		"backup1": emulatortest.BlobStorageBackup("blob1", *storageAccount.ID),
+	}
+	require.NoError(t, emulatortest.Shared().Apply(emulator.SubscriptionID, variables))
+
+	// A recovery point stops the instance being deleted while the vault is immutable
+	instances, err := azure.GetBackupInstances(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, resourceGroupName, backupVaultName)
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	emulatortest.Shared().Put(*instances[0].ID+"/recoveryPoints/rp1", map[string]any{"properties": map[string]any{}})
+
+	statePath := filepath.Join(t.TempDir(), backupVaultName+".tfstate")
+	require.NoError(t, os.WriteFile(statePath, []byte("{}"), 0o600))
+	require.NoError(t, azure.UploadFileToStorageAccount(t.Context(), credential, emulatortest.Shared().ClientOptions(), testStateStorageAccount, testStateContainer, statePath))
+
+	return credential
+}
+
+/*
+ * Gets the actions in the report against the resources for the provided unique ID.
+ */
+func actionsFor(report *Report, uniqueId string) []Action {
+	var actions []Action
+	for _, action := range report.Actions {
+		if strings.Contains(action.ResourceID, "-"+uniqueId) {
+			actions = append(actions, action)
+		}
+	}
+
+	return actions
+}
+
+func testOptions(minAge time.Duration, dryRun bool) Options {
+	return Options{
+		MinAge:              minAge,
+		DryRun:              dryRun,
+		StateStorageAccount: testStateStorageAccount,
+		StateContainer:      testStateContainer,
+	}
+}
+
+func resourceGroupExists(t *testing.T, credential azcore.TokenCredential, name string) bool {
+	_, err := azure.GetResourceGroup(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, name)
+	return err == nil
+}
+
+/*
+ * TestRunRemovesOrphanedResources tests that an orphaned test's vault is unlocked and emptied,
+ * and its resource groups and state blob are removed, while other resource groups are left alone.
+ */
+func TestRunRemovesOrphanedResources(t *testing.T) {
+	credential := deployOrphanedTestResources(t, "Clean1", "Unlocked", "On")
+
+	_, err := azure.CreateResourceGroup(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, "rg-nhsbackup", "uksouth")
+	require.NoError(t, err)
+
+	report, err := Run(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, testOptions(0, false))
+	require.NoError(t, err)
+
+	var operations []Operation
+	for _, action := range actionsFor(report, "Clean1") {
+		assert.Equal(t, StatusDone, action.Status, "Expected %s of %s to be done: %s", action.Operation, action.ResourceID, action.Message)
+		operations = append(operations, action.Operation)
+	}
+
+	assert.Equal(t, []Operation{
+		OperationDisableImmutability,
+		OperationDisableSoftDelete,
+		OperationDeleteBackupInstance,
+		OperationDeleteBackupVault,
+		OperationDeleteResourceGroup,
+		OperationDeleteResourceGroup,
+		OperationDeleteStateBlob,
+	}, operations)
+
+	assert.False(t, resourceGroupExists(t, credential, "rg-nhsbackup-Clean1"))
+	assert.False(t, resourceGroupExists(t, credential, "rg-nhsbackup-Clean1-external"))
+	assert.True(t, resourceGroupExists(t, credential, "rg-nhsbackup"))
+
+	_, ok := emulatortest.Shared().Blob(testStateStorageAccount, testStateContainer, "bvault-nhsbackup-Clean1.tfstate")
+	assert.False(t, ok, "Expected the state blob to be deleted")
+}
+
+/*
+ * TestRunDryRun tests that a dry run reports what would be removed without removing it.
+ */
+func TestRunDryRun(t *testing.T) {
+	credential := deployOrphanedTestResources(t, "DryRun", "Unlocked", "Off")
+
+	report, err := Run(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, testOptions(0, true))
+	require.NoError(t, err)
+
+	actions := actionsFor(report, "DryRun")
+	require.Len(t, actions, 6)
+	for _, action := range actions {
+		assert.Equal(t, StatusDryRun, action.Status)
+	}
+
+	assert.True(t, report.DryRun)
+	assert.True(t, resourceGroupExists(t, credential, "rg-nhsbackup-DryRun"))
+	assert.True(t, resourceGroupExists(t, credential, "rg-nhsbackup-DryRun-external"))
+
+	_, ok := emulatortest.Shared().Blob(testStateStorageAccount, testStateContainer, "bvault-nhsbackup-DryRun.tfstate")
+	assert.True(t, ok, "Expected the state blob to be kept")
+}
+
+/*
+ * TestRunSkipsRecentResources tests that resource groups younger than the minimum age, and
+ * their state blobs, are left alone.
+ */
+func TestRunSkipsRecentResources(t *testing.T) {
+	credential := deployOrphanedTestResources(t, "Recent", "Disabled", "Off")
+
+	report, err := Run(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, testOptions(time.Hour, false))
+	require.NoError(t, err)
+
+	actions := actionsFor(report, "Recent")
+	require.Len(t, actions, 2)
+	assert.Equal(t, StatusSkipped, actions[0].Status)
+	assert.Contains(t, actions[0].Message, "which is younger than 1h0m0s")
+
+	assert.True(t, resourceGroupExists(t, credential, "rg-nhsbackup-Recent"))
+
+	_, ok := emulatortest.Shared().Blob(testStateStorageAccount, testStateContainer, "bvault-nhsbackup-Recent.tfstate")
+	assert.True(t, ok, "Expected the state blob to be kept")
+}
+
+/*
+ * TestRunReportsLockedVault tests that a vault with locked immutability is reported as a
+ * failure, and that its resource group and state blob are kept.
+ */
+func TestRunReportsLockedVault(t *testing.T) {
+	credential := deployOrphanedTestResources(t, "Locked", "Locked", "Off")
+
+	report, err := Run(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, testOptions(0, false))
+	require.NoError(t, err)
+
+	actions := actionsFor(report, "Locked")
+	require.Len(t, actions, 3)
+	assert.Equal(t, Action{
+		ResourceID: fmt.Sprintf("/subscriptions/%s/resourceGroups/rg-nhsbackup-Locked/providers/Microsoft.DataProtection/backupVaults/bvault-nhsbackup-Locked", emulator.SubscriptionID),
+		Operation:  OperationDisableImmutability,
+		Status:     StatusFailed,
+		Message:    "Immutability is Locked, which can't be reversed",
+	}, actions[0])
+	assert.Equal(t, OperationDeleteResourceGroup, actions[1].Operation)
+	assert.Equal(t, StatusFailed, actions[1].Status)
+	assert.Equal(t, "Resource group holds backup vault 'bvault-nhsbackup-Locked', which couldn't be deleted", actions[1].Message)
+
+	// The external resource group doesn't depend on the vault, so can still be removed
+	assert.Equal(t, StatusDone, actions[2].Status)
+	assert.True(t, report.Failed())
+
+	assert.True(t, resourceGroupExists(t, credential, "rg-nhsbackup-Locked"))
+	assert.False(t, resourceGroupExists(t, credential, "rg-nhsbackup-Locked-external"))
+
+	_, ok := emulatortest.Shared().Blob(testStateStorageAccount, testStateContainer, "bvault-nhsbackup-Locked.tfstate")
+	assert.True(t, ok, "Expected the state blob to be kept")
+}
+
+/*
+ * TestRunRemovesEmptyResourceGroups tests that the age of a resource group with no resources
+ * is taken from its creation tag or, where terraform created it, from its state blob.
+ */
+func TestRunRemovesEmptyResourceGroups(t *testing.T) {
+	credential, err := emulatortest.Shared().Credential()
+	require.NoError(t, err)
+
+	_, err = azure.CreateResourceGroup(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, "rg-nhsbackup-Empty1-external", "uksouth")
+	require.NoError(t, err)
+
+	// Created without the tag, as terraform would, and without a state blob
+	emulatortest.Shared().Put(fmt.Sprintf("/subscriptions/%s/resourceGroups/rg-nhsbackup-Empty2", emulator.SubscriptionID), map[string]any{"location": "uksouth"})
+
+	// Created without the tag, as terraform would, but with a state blob
+	emulatortest.Shared().Put(fmt.Sprintf("/subscriptions/%s/resourceGroups/rg-nhsbackup-Empty3", emulator.SubscriptionID), map[string]any{"location": "uksouth"})
+	statePath := filepath.Join(t.TempDir(), "bvault-nhsbackup-Empty3.tfstate")
+	require.NoError(t, os.WriteFile(statePath, []byte("{}"), 0o600))
+	require.NoError(t, azure.UploadFileToStorageAccount(t.Context(), credential, emulatortest.Shared().ClientOptions(), testStateStorageAccount, testStateContainer, statePath))
+
+	report, err := Run(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, testOptions(0, false))
+	require.NoError(t, err)
+
+	actions := actionsFor(report, "Empty1")
+	require.Len(t, actions, 1)
+	assert.Equal(t, StatusDone, actions[0].Status)
+
+	actions = actionsFor(report, "Empty2")
+	require.Len(t, actions, 1)
+	assert.Equal(t, StatusSkipped, actions[0].Status)
+	assert.Equal(t, "Resource group has no creation tag, resources or state blob to work out its age from", actions[0].Message)
+
+	actions = actionsFor(report, "Empty3")
+	require.Len(t, actions, 2)
+	assert.Equal(t, OperationDeleteResourceGroup, actions[0].Operation)
+	assert.Equal(t, StatusDone, actions[0].Status)
+	assert.Equal(t, OperationDeleteStateBlob, actions[1].Operation)
+	assert.Equal(t, StatusDone, actions[1].Status)
+
+	assert.False(t, resourceGroupExists(t, credential, "rg-nhsbackup-Empty1-external"))
+	assert.True(t, resourceGroupExists(t, credential, "rg-nhsbackup-Empty2"))
+	assert.False(t, resourceGroupExists(t, credential, "rg-nhsbackup-Empty3"))
+}
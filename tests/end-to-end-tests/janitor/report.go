@@ -0,0 +1,117 @@
+package janitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+ * Operation is something the janitor does to a resource in order to remove it.
+ */
+type Operation string
+
+const (
+	OperationDisableImmutability  Operation = "disable immutability"
+	OperationDisableSoftDelete    Operation = "disable soft delete"
+	OperationDeleteBackupInstance Operation = "delete backup instance"
+	OperationDeleteBackupVault    Operation = "delete backup vault"
+	OperationDeleteResourceGroup  Operation = "delete resource group"
+	OperationDeleteStateBlob      Operation = "delete state blob"
+)
+
+/*
+ * Status is the outcome of an operation.
+ */
+type Status string
+
+const (
+	StatusDone    Status = "done"
+	StatusDryRun  Status = "dry run"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+/*
+ * Action records an operation against a resource and its outcome.
+ */
+type Action struct {
+	ResourceID string    `json:"resourceId"`
+	Operation  Operation `json:"operation"`
+	Status     Status    `json:"status"`
+	Message    string    `json:"message,omitempty"`
+}
+
+/*
+ * Report records every action the janitor took, or would take on a dry run, in order.
+ */
+type Report struct {
+	DryRun  bool     `json:"dryRun"`
+	Actions []Action `json:"actions"`
+}
+
+func (report *Report) add(resourceID string, operation Operation, status Status, message string) {
+	report.Actions = append(report.Actions, Action{
+		ResourceID: resourceID,
+		Operation:  operation,
+		Status:     status,
+		Message:    message,
+	})
+}
+
+/*
+ * Count gets the number of actions with the provided status.
+ */
+func (report *Report) Count(status Status) int {
+	count := 0
+	for _, action := range report.Actions {
+		if action.Status == status {
+			count++
+		}
+	}
+
+	return count
+}
+
+/*
+ * Failed reports whether any action failed.
+ */
+func (report *Report) Failed() bool {
+	return report.Count(StatusFailed) > 0
+}
+
+/*
+ * WriteText writes a line per action followed by a summary.
+ */
+func (report *Report) WriteText(w io.Writer) error {
+	var builder strings.Builder
+
+	for _, action := range report.Actions {
+		fmt.Fprintf(&builder, "%-8s %-23s %s\n", strings.ToUpper(string(action.Status)), action.Operation, action.ResourceID)
+		if action.Message != "" {
+			fmt.Fprintf(&builder, "         %s\n", action.Message)
+		}
+	}
+
+	if report.DryRun {
+		fmt.Fprintf(&builder, "\nDry run: %d actions would be taken, %d skipped, %d failed\n",
+			report.Count(StatusDryRun), report.Count(StatusSkipped), report.Count(StatusFailed))
+	} else {
+		fmt.Fprintf(&builder, "\n%d actions done, %d skipped, %d failed\n",
+			report.Count(StatusDone), report.Count(StatusSkipped), report.Count(StatusFailed))
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+/*
+ * WriteJSON writes the report as an indented JSON document.
+ */
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
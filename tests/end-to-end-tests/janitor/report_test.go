@@ -0,0 +1,35 @@
+package janitor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestReport(dryRun bool) *Report {
+	report := &Report{DryRun: dryRun}
+	report.add("/subscriptions/sub/resourceGroups/rg-nhsbackup-abc123", OperationDeleteResourceGroup, StatusDone, "")
+	report.add("/subscriptions/sub/resourceGroups/rg-nhsbackup-def456", OperationDeleteResourceGroup, StatusSkipped, "Resource group is 1h0m0s old, which is younger than 24h0m0s")
+	report.add("satfstate/tfstate/bvault-nhsbackup-abc123.tfstate", OperationDeleteStateBlob, StatusFailed, "failed to delete blob")
+
+	return report
+}
+
+/*
+ * TestWriteText tests that the report is written as a line per action and a summary.
+ */
+func TestWriteText(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, newTestReport(false).WriteText(&buffer))
+
+	assert.Contains(t, buffer.String(), "DONE     delete resource group   /subscriptions/sub/resourceGroups/rg-nhsbackup-abc123\n")
+	assert.Contains(t, buffer.String(), "         Resource group is 1h0m0s old, which is younger than 24h0m0s\n")
+	assert.Contains(t, buffer.String(), "FAILED   delete state blob       satfstate/tfstate/bvault-nhsbackup-abc123.tfstate\n")
+	assert.Contains(t, buffer.String(), "1 actions done, 1 skipped, 1 failed")
+
+	buffer.Reset()
+	require.NoError(t, newTestReport(true).WriteText(&buffer))
+	assert.Contains(t, buffer.String(), "Dry run: 0 actions would be taken, 1 skipped, 1 failed")
+}
@@ -0,0 +1,211 @@
+package kql
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+/*
+ * Job is a row of AddonAzureBackupJobs, for ad-hoc queries over the jobs table.
+ */
+type Job struct {
+	TimeGenerated      time.Time `kql:"TimeGenerated"`
+	JobUniqueID        string    `kql:"JobUniqueId"`
+	JobOperation       string    `kql:"JobOperation"`
+	JobStatus          string    `kql:"JobStatus"`
+	JobStartDateTime   time.Time `kql:"JobStartDateTime"`
+	BackupItemUniqueID string    `kql:"BackupItemUniqueId"`
+	ResourceID         string    `kql:"_ResourceId"`
+}
+
+/*
+ * FailedJob is a row of the failed_jobs query.
+ */
+type FailedJob struct {
+	TimeGenerated      time.Time `kql:"TimeGenerated"`
+	JobUniqueID        string    `kql:"JobUniqueId"`
+	JobOperation       string    `kql:"JobOperation"`
+	JobStatus          string    `kql:"JobStatus"`
+	JobFailureCode     string    `kql:"JobFailureCode"`
+	JobStartDateTime   time.Time `kql:"JobStartDateTime"`
+	JobDurationInSecs  float64   `kql:"JobDurationInSecs"`
+	BackupItemUniqueID string    `kql:"BackupItemUniqueId"`
+	ResourceID         string    `kql:"_ResourceId"`
+}
+
+/*
+ * UnprotectedInstance is a row of the unprotected_instances query.
+ */
+type UnprotectedInstance struct {
+	TimeGenerated             time.Time `kql:"TimeGenerated"`
+	BackupItemUniqueID        string    `kql:"BackupItemUniqueId"`
+	BackupItemName            string    `kql:"BackupItemName"`
+	BackupItemProtectionState string    `kql:"BackupItemProtectionState"`
+	ResourceID                string    `kql:"_ResourceId"`
+}
+
+/*
+ * PolicyChange is a row of the policy_changes query.
+ */
+type PolicyChange struct {
+	TimeGenerated  time.Time `kql:"TimeGenerated"`
+	PolicyUniqueID string    `kql:"PolicyUniqueId"`
+	PolicyName     string    `kql:"PolicyName"`
+	ResourceID     string    `kql:"_ResourceId"`
+}
+
+/*
+ * LongRunningJob is a row of the long_running_jobs query.
+ */
+type LongRunningJob struct {
+	TimeGenerated      time.Time `kql:"TimeGenerated"`
+	JobUniqueID        string    `kql:"JobUniqueId"`
+	JobOperation       string    `kql:"JobOperation"`
+	JobStatus          string    `kql:"JobStatus"`
+	JobStartDateTime   time.Time `kql:"JobStartDateTime"`
+	DurationInSecs     float64   `kql:"DurationInSecs"`
+	BackupItemUniqueID string    `kql:"BackupItemUniqueId"`
+	ResourceID         string    `kql:"_ResourceId"`
+}
+
+/*
+ * Decode decodes the rows of a table into T, a struct whose fields are tagged with the name
+ * of the column they're read from, e.g. `kql:"JobUniqueId"`. Fields may be strings, bools,
+ * integers, floats or time.Times, and a null leaves a field as its zero value. Every tagged
+ * field must have a column, so that a query which no longer returns a column fails rather
+ * than silently decoding nothing; columns without a field are ignored.
+ */
+func Decode[T any](table *Table) ([]T, error) {
+	rowType := reflect.TypeFor[T]()
+	if rowType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot decode rows into %s, which is not a struct", rowType)
+	}
+
+	columns := map[string]int{}
+	for i, column := range table.Columns {
+		columns[column.Name] = i
+	}
+
+	// The column index for each tagged field
+	fields := map[int]int{}
+	for i := range rowType.NumField() {
+		name, ok := rowType.Field(i).Tag.Lookup("kql")
+		if !ok {
+			continue
+		}
+
+		column, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("table %s has no column %s for field %s", table.Name, name, rowType.Field(i).Name)
+		}
+		fields[i] = column
+	}
+
+	rows := make([]T, 0, len(table.Rows))
+	for r, values := range table.Rows {
+		if len(values) != len(table.Columns) {
+			return nil, fmt.Errorf("row %d of table %s has %d values for %d columns", r, table.Name, len(values), len(table.Columns))
+		}
+
+		var row T
+		value := reflect.ValueOf(&row).Elem()
+
+		for field, column := range fields {
+			if err := decodeValue(value.Field(field), values[column]); err != nil {
+				return nil, fmt.Errorf("failed to decode column %s of row %d: %w", table.Columns[column].Name, r, err)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func decodeValue(field reflect.Value, value any) error {
+	if value == nil {
+		return nil
+	}
+
+	if field.Type() == reflect.TypeFor[time.Time]() {
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a datetime, got %v", value)
+		}
+
+		parsed, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return err
+		}
+
+		field.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		// Dynamic columns are returned as JSON, and are left for the caller to decode
+		switch value := value.(type) {
+		case string:
+			field.SetString(value)
+		case json.Number:
+			field.SetString(value.String())
+		default:
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			field.SetString(string(encoded))
+		}
+	case reflect.Bool:
+		boolean, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("expected a bool, got %v", value)
+		}
+		field.SetBool(boolean)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, err := numberOf(value)
+		if err != nil {
+			return err
+		}
+		integer, err := number.Int64()
+		if err != nil {
+			return err
+		}
+		field.SetInt(integer)
+	case reflect.Float32, reflect.Float64:
+		number, err := numberOf(value)
+		if err != nil {
+			return err
+		}
+		float, err := number.Float64()
+		if err != nil {
+			return err
+		}
+		field.SetFloat(float)
+	default:
+		return fmt.Errorf("fields of type %s are not supported", field.Type())
+	}
+
+	return nil
+}
+
+/*
+ * Reads a number, which the query API returns as a string for values (such as NaN) that JSON
+ * can't represent.
+ */
+func numberOf(value any) (json.Number, error) {
+	switch value := value.(type) {
+	case json.Number:
+		return value, nil
+	case float64:
+		return json.Number(strconv.FormatFloat(value, 'f', -1, 64)), nil
+	case string:
+		return json.Number(value), nil
+	default:
+		return "", fmt.Errorf("expected a number, got %v", value)
+	}
+}
@@ -0,0 +1,87 @@
+package kql
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+ * TestDecode tests that rows are decoded into fields by column name, whatever the order of
+ * the columns, and that nulls leave fields as their zero value.
+ */
+func TestDecode(t *testing.T) {
+	table := &Table{
+		Name: "PrimaryResult",
+		Columns: []Column{
+			{Name: "JobUniqueId", Type: "string"},
+			{Name: "TimeGenerated", Type: "datetime"},
+			{Name: "JobOperation", Type: "string"},
+			{Name: "JobStatus", Type: "string"},
+			{Name: "JobFailureCode", Type: "string"},
+			{Name: "JobStartDateTime", Type: "datetime"},
+			{Name: "JobDurationInSecs", Type: "real"},
+			{Name: "BackupItemUniqueId", Type: "string"},
+			{Name: "_ResourceId", Type: "string"},
+			{Name: "Unused", Type: "dynamic"},
+		},
+		Rows: [][]any{
+			{"job1", "2024-06-01T02:00:05.123Z", "Backup", "Failed", "UserErrorDatasourceNotFound", "2024-06-01T01:00:00Z", json.Number("3600.5"), "instance1", "/subscriptions/sub/vault", map[string]any{"a": 1}},
+			{"job2", "2024-06-01T03:00:00Z", "Restore", "Failed", nil, nil, nil, "instance2", "/subscriptions/sub/vault", nil},
+		},
+	}
+
+	rows, err := Decode[FailedJob](table)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	assert.Equal(t, FailedJob{
+		TimeGenerated:      time.Date(2024, 6, 1, 2, 0, 5, 123000000, time.UTC),
+		JobUniqueID:        "job1",
+		JobOperation:       "Backup",
+		JobStatus:          "Failed",
+		JobFailureCode:     "UserErrorDatasourceNotFound",
+		JobStartDateTime:   time.Date(2024, 6, 1, 1, 0, 0, 0, time.UTC),
+		JobDurationInSecs:  3600.5,
+		BackupItemUniqueID: "instance1",
+		ResourceID:         "/subscriptions/sub/vault",
+	}, rows[0])
+
+	assert.Empty(t, rows[1].JobFailureCode)
+	assert.True(t, rows[1].JobStartDateTime.IsZero())
+	assert.Zero(t, rows[1].JobDurationInSecs)
+}
+
+/*
+ * TestDecodeRejectsMismatchedTables tests that a table missing a column that a field is read
+ * from, or holding a value of the wrong type, fails to decode.
+ */
+func TestDecodeRejectsMismatchedTables(t *testing.T) {
+	_, err := Decode[PolicyChange](&Table{
+		Name:    "PrimaryResult",
+		Columns: []Column{{Name: "TimeGenerated", Type: "datetime"}, {Name: "PolicyName", Type: "string"}},
+	})
+	assert.ErrorContains(t, err, "table PrimaryResult has no column PolicyUniqueId for field PolicyUniqueID")
+
+	type row struct {
+		Count   int64 `kql:"Count"`
+		Enabled bool  `kql:"Enabled"`
+	}
+
+	table := &Table{
+		Name:    "PrimaryResult",
+		Columns: []Column{{Name: "Count", Type: "long"}, {Name: "Enabled", Type: "bool"}},
+		Rows:    [][]any{{json.Number("12"), true}},
+	}
+
+	rows, err := Decode[row](table)
+	require.NoError(t, err)
+	assert.Equal(t, []row{{Count: 12, Enabled: true}}, rows)
+
+	table.Rows = [][]any{{"twelve", true}}
+	_, err = Decode[row](table)
+	assert.ErrorContains(t, err, "failed to decode column Count of row 0")
+}
@@ -0,0 +1,142 @@
+/*
+ * Package kql holds a versioned pack of KQL queries over the backup telemetry that the module
+ * sends to log_analytics_workspace_id, and runs them through the Log Analytics query API,
+ * decoding the rows into typed structs.
+ *
+ * Each query is a .kql file in queries/, starting with // comment lines: the first describes
+ * the query, and any of the form "// parameter: <name> = <default>" declare a parameter,
+ * which is defined with a let statement before the query is run.
+ */
+package kql
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+/*
+ * Version is the version of the query pack. It's bumped whenever a query changes, and its
+ * major version whenever the columns a query returns change, as rows are decoded by column.
+ */
+const Version = "1.0.0"
+
+/*
+ * The names of the queries in the pack.
+ */
+const (
+	QueryFailedJobs           = "failed_jobs"
+	QueryUnprotectedInstances = "unprotected_instances"
+	QueryPolicyChanges        = "policy_changes"
+	QueryLongRunningJobs      = "long_running_jobs"
+)
+
+//go:embed queries/*.kql
+var queryFiles embed.FS
+
+var parameterPattern = regexp.MustCompile(`^//\s*parameter:\s*([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.+?)\s*$`)
+
+/*
+ * Parameter is a value that a query refers to by name, with the default used when it isn't
+ * provided.
+ */
+type Parameter struct {
+	Name    string
+	Default string
+}
+
+/*
+ * Query is a KQL query from the pack.
+ */
+type Query struct {
+	Name        string
+	Description string
+	Parameters  []Parameter
+	Text        string
+}
+
+/*
+ * Queries returns every query in the pack, ordered by name.
+ */
+func Queries() []Query {
+	entries, err := queryFiles.ReadDir("queries")
+	if err != nil {
+		panic(fmt.Sprintf("failed to read the embedded queries: %v", err))
+	}
+
+	var queries []Query
+	for _, entry := range entries {
+		text, err := queryFiles.ReadFile(path.Join("queries", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("failed to read the embedded query %s: %v", entry.Name(), err))
+		}
+
+		queries = append(queries, parseQuery(strings.TrimSuffix(entry.Name(), ".kql"), string(text)))
+	}
+
+	slices.SortFunc(queries, func(a, b Query) int { return strings.Compare(a.Name, b.Name) })
+
+	return queries
+}
+
+/*
+ * GetQuery gets a query from the pack by name.
+ */
+func GetQuery(name string) (Query, bool) {
+	for _, query := range Queries() {
+		if query.Name == name {
+			return query, true
+		}
+	}
+
+	return Query{}, false
+}
+
+func parseQuery(name string, text string) Query {
+	query := Query{Name: name, Text: strings.TrimSpace(text)}
+
+	for _, line := range strings.Split(query.Text, "\n") {
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+
+		if match := parameterPattern.FindStringSubmatch(line); match != nil {
+			query.Parameters = append(query.Parameters, Parameter{Name: match[1], Default: match[2]})
+		} else if query.Description == "" {
+			query.Description = strings.TrimSpace(strings.TrimPrefix(line, "//"))
+		}
+	}
+
+	return query
+}
+
+/*
+ * Render returns the text to run for the query: a comment identifying it in the workspace's
+ * query audit log, a let statement for each parameter, and the query itself. Parameters that
+ * aren't provided take their default, and providing one the query doesn't declare is an error.
+ */
+func (query Query) Render(parameters map[string]string) (string, error) {
+	for name := range parameters {
+		if !slices.ContainsFunc(query.Parameters, func(parameter Parameter) bool { return parameter.Name == name }) {
+			return "", fmt.Errorf("query %s has no parameter %s", query.Name, name)
+		}
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "// az-backup query pack %s: %s\n", Version, query.Name)
+
+	for _, parameter := range query.Parameters {
+		value, ok := parameters[parameter.Name]
+		if !ok {
+			value = parameter.Default
+		}
+		fmt.Fprintf(&builder, "let %s = %s;\n", parameter.Name, value)
+	}
+
+	builder.WriteString(query.Text)
+
+	return builder.String(), nil
+}
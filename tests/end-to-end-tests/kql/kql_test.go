@@ -0,0 +1,53 @@
+package kql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+ * TestQueries tests that every query in the pack is embedded with a description, and that
+ * parameters are read from the query's header.
+ */
+func TestQueries(t *testing.T) {
+	queries := Queries()
+
+	var names []string
+	for _, query := range queries {
+		names = append(names, query.Name)
+		assert.NotEmpty(t, query.Description, "Expected query %s to have a description", query.Name)
+		assert.NotEmpty(t, query.Text, "Expected query %s to have text", query.Name)
+	}
+	assert.Equal(t, []string{QueryFailedJobs, QueryLongRunningJobs, QueryPolicyChanges, QueryUnprotectedInstances}, names)
+
+	query, ok := GetQuery(QueryLongRunningJobs)
+	require.True(t, ok)
+	assert.Equal(t, []Parameter{{Name: "MinimumDurationInSecs", Default: "7200"}}, query.Parameters)
+	assert.Equal(t, "Jobs that took, or have taken so far, longer than MinimumDurationInSecs, longest first.", query.Description)
+
+	_, ok = GetQuery("missing")
+	assert.False(t, ok)
+}
+
+/*
+ * TestRender tests that parameters are defined ahead of the query, taking their default when
+ * they aren't provided, and that unknown parameters are rejected.
+ */
+func TestRender(t *testing.T) {
+	query := parseQuery("example", "// An example.\n// parameter: Minimum = 10\n// parameter: Status = \"Failed\"\nAddonAzureBackupJobs\n| where JobStatus == Status\n")
+
+	text, err := query.Render(map[string]string{"Minimum": "60"})
+	require.NoError(t, err)
+
+	lines := strings.Split(text, "\n")
+	assert.Equal(t, "// az-backup query pack "+Version+": example", lines[0])
+	assert.Equal(t, "let Minimum = 60;", lines[1])
+	assert.Equal(t, "let Status = \"Failed\";", lines[2])
+	assert.Contains(t, text, "| where JobStatus == Status")
+
+	_, err = query.Render(map[string]string{"Maximum": "60"})
+	assert.ErrorContains(t, err, "query example has no parameter Maximum")
+}
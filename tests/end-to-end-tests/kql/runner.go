@@ -0,0 +1,173 @@
+package kql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+const (
+	DefaultEndpoint = "https://api.loganalytics.io"
+
+	moduleName    = "e2e_tests/kql"
+	moduleVersion = "v" + Version
+)
+
+/*
+ * Column is a column of a query result.
+ */
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+/*
+ * Table is the result of a query, with the values of each row in column order.
+ */
+type Table struct {
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+/*
+ * Runner runs queries against Log Analytics workspaces through the query API.
+ */
+type Runner struct {
+	pipeline runtime.Pipeline
+	endpoint string
+}
+
+/*
+ * NewRunner creates a runner which authenticates with the provided credential. The options
+ * may be nil for the defaults.
+ */
+func NewRunner(credential azcore.TokenCredential, options *azcore.ClientOptions) *Runner {
+	authentication := runtime.NewBearerTokenPolicy(credential, []string{DefaultEndpoint + "/.default"}, nil)
+
+	return &Runner{
+		pipeline: runtime.NewPipeline(moduleName, moduleVersion, runtime.PipelineOptions{PerRetry: []policy.Policy{authentication}}, options),
+		endpoint: DefaultEndpoint,
+	}
+}
+
+/*
+ * Execute runs the KQL text against the workspace with the provided workspace (customer) ID,
+ * over the timespan up to now, and returns the primary result.
+ */
+func (runner *Runner) Execute(ctx context.Context, workspaceID string, text string, timespan time.Duration) (*Table, error) {
+	request, err := runtime.NewRequest(ctx, http.MethodPost, fmt.Sprintf("%s/v1/workspaces/%s/query", runner.endpoint, url.PathEscape(workspaceID)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query request: %w", err)
+	}
+
+	body := map[string]string{"query": text}
+	if timespan > 0 {
+		body["timespan"] = fmt.Sprintf("PT%dS", int64(timespan.Seconds()))
+	}
+	if err := runtime.MarshalAsJSON(request, body); err != nil {
+		return nil, fmt.Errorf("failed to encode query request: %w", err)
+	}
+
+	response, err := runner.pipeline.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run query: %w", err)
+	}
+	if !runtime.HasStatusCode(response, http.StatusOK) {
+		return nil, fmt.Errorf("failed to run query: %w", runtime.NewResponseError(response))
+	}
+
+	payload, err := runtime.Payload(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read query response: %w", err)
+	}
+
+	// Numbers are decoded as json.Number so that long columns keep their precision
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var result struct {
+		Tables []*Table `json:"tables"`
+	}
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode query response: %w", err)
+	}
+
+	if len(result.Tables) == 0 {
+		return nil, fmt.Errorf("query response has no tables")
+	}
+
+	return result.Tables[0], nil
+}
+
+/*
+ * Run runs a query from the pack against the workspace over the timespan up to now, and
+ * decodes its rows into T.
+ */
+func Run[T any](ctx context.Context, runner *Runner, workspaceID string, query Query, timespan time.Duration, parameters map[string]string) ([]T, error) {
+	text, err := query.Render(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := runner.Execute(ctx, workspaceID, text, timespan)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", query.Name, err)
+	}
+
+	rows, err := Decode[T](table)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", query.Name, err)
+	}
+
+	return rows, nil
+}
+
+func runQuery[T any](ctx context.Context, runner *Runner, workspaceID string, name string, timespan time.Duration, parameters map[string]string) ([]T, error) {
+	query, ok := GetQuery(name)
+	if !ok {
+		return nil, fmt.Errorf("query %s is not in the query pack", name)
+	}
+
+	return Run[T](ctx, runner, workspaceID, query, timespan, parameters)
+}
+
+/*
+ * FailedJobs gets the jobs that failed within the timespan.
+ */
+func (runner *Runner) FailedJobs(ctx context.Context, workspaceID string, timespan time.Duration) ([]FailedJob, error) {
+	return runQuery[FailedJob](ctx, runner, workspaceID, QueryFailedJobs, timespan, nil)
+}
+
+/*
+ * UnprotectedInstances gets the backup instances which were last reported within the timespan
+ * as not being protected.
+ */
+func (runner *Runner) UnprotectedInstances(ctx context.Context, workspaceID string, timespan time.Duration) ([]UnprotectedInstance, error) {
+	return runQuery[UnprotectedInstance](ctx, runner, workspaceID, QueryUnprotectedInstances, timespan, nil)
+}
+
+/*
+ * PolicyChanges gets the changes to backup policies within the timespan.
+ */
+func (runner *Runner) PolicyChanges(ctx context.Context, workspaceID string, timespan time.Duration) ([]PolicyChange, error) {
+	return runQuery[PolicyChange](ctx, runner, workspaceID, QueryPolicyChanges, timespan, nil)
+}
+
+/*
+ * LongRunningJobs gets the jobs within the timespan that took, or have taken so far, longer
+ * than the minimum duration.
+ */
+func (runner *Runner) LongRunningJobs(ctx context.Context, workspaceID string, timespan time.Duration, minimum time.Duration) ([]LongRunningJob, error) {
+	parameters := map[string]string{"MinimumDurationInSecs": fmt.Sprintf("%d", int64(minimum.Seconds()))}
+
+	return runQuery[LongRunningJob](ctx, runner, workspaceID, QueryLongRunningJobs, timespan, parameters)
+}
@@ -0,0 +1,146 @@
+package kql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"e2e_tests/azure"
+	"e2e_tests/emulator"
+	"e2e_tests/emulator/emulatortest"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testResourceGroupName = "rg-kql"
+	testBackupVaultName   = "bvault-kql"
+)
+
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+/*
+ * A transport which records the query request and responds with a canned body.
+ */
+type fakeTransport struct {
+	status  int
+	body    string
+	request map[string]string
+}
+
+func (transport *fakeTransport) Do(req *http.Request) (*http.Response, error) {
+	if err := json.NewDecoder(req.Body).Decode(&transport.request); err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: transport.status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(transport.body)),
+		Request:    req,
+	}, nil
+}
+
+/*
+ * TestLongRunningJobs tests that the query is sent with its parameters and timespan, and that
+ * the rows come back typed.
+ */
+func TestLongRunningJobs(t *testing.T) {
+	transport := &fakeTransport{
+		status: http.StatusOK,
+		body: `{"tables":[{"name":"PrimaryResult","columns":[
+			{"name":"TimeGenerated","type":"datetime"},{"name":"JobUniqueId","type":"string"},{"name":"JobOperation","type":"string"},
+			{"name":"JobStatus","type":"string"},{"name":"JobStartDateTime","type":"datetime"},{"name":"DurationInSecs","type":"real"},
+			{"name":"BackupItemUniqueId","type":"string"},{"name":"_ResourceId","type":"string"}],
+			"rows":[["2024-06-01T05:00:00Z","job1","Backup","InProgress","2024-06-01T01:00:00Z",14400,"instance1","/vault"]]}]}`,
+	}
+	runner := NewRunner(fakeCredential{}, &azcore.ClientOptions{Transport: transport, Retry: policy.RetryOptions{MaxRetries: -1}})
+
+	jobs, err := runner.LongRunningJobs(t.Context(), "workspace", 24*time.Hour, 3*time.Hour)
+	require.NoError(t, err)
+
+	assert.Equal(t, "PT86400S", transport.request["timespan"])
+	assert.Contains(t, transport.request["query"], "let MinimumDurationInSecs = 10800;\n")
+
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "job1", jobs[0].JobUniqueID)
+	assert.Equal(t, 14400.0, jobs[0].DurationInSecs)
+	assert.Equal(t, time.Date(2024, 6, 1, 1, 0, 0, 0, time.UTC), jobs[0].JobStartDateTime)
+}
+
+/*
+ * TestExecuteReturnsQueryErrors tests that a query the API rejects is returned as a response
+ * error, carrying the API's error code.
+ */
+func TestExecuteReturnsQueryErrors(t *testing.T) {
+	transport := &fakeTransport{
+		status: http.StatusBadRequest,
+		body:   `{"error":{"code":"BadArgumentError","message":"The request had some invalid properties"}}`,
+	}
+	runner := NewRunner(fakeCredential{}, &azcore.ClientOptions{Transport: transport, Retry: policy.RetryOptions{MaxRetries: -1}})
+
+	_, err := runner.FailedJobs(t.Context(), "workspace", time.Hour)
+
+	var responseErr *azcore.ResponseError
+	require.True(t, errors.As(err, &responseErr), "Expected a response error, got %v", err)
+	assert.Equal(t, http.StatusBadRequest, responseErr.StatusCode)
+	assert.Equal(t, "BadArgumentError", responseErr.ErrorCode)
+	assert.ErrorContains(t, err, "query failed_jobs")
+}
+
+/*
+ * TestExecuteAgainstEmulator tests that a backup job is delivered to the workspace that the
+ * vault's diagnostic settings send logs to, and can be read back through the query API.
+ */
+func TestExecuteAgainstEmulator(t *testing.T) {
+	credential, err := emulatortest.Shared().Credential()
+	require.NoError(t, err)
+
+	externalResourceGroupID := fmt.Sprintf("/subscriptions/%s/resourceGroups/rg-kql-external", emulator.SubscriptionID)
+	emulatortest.Shared().Put(externalResourceGroupID, map[string]any{"location": "uksouth"})
+	defer emulatortest.Shared().Delete(externalResourceGroupID)
+
+	workspace, err := azure.CreateLogAnalyticsWorkspace(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, "rg-kql-external", "law-kql", "uksouth")
+	require.NoError(t, err)
+	require.NotNil(t, workspace.Properties.CustomerID)
+
+	variables := emulatortest.Variables(testResourceGroupName, testBackupVaultName)
+	variables["log_analytics_workspace_id"] = *workspace.ID
+	variables["blob_storage_backups"] = map[string]map[string]interface{}{
+		"backup1": emulatortest.BlobStorageBackup("blob1", emulatortest.ResourceID("rg-kql-external", "Microsoft.Storage/storageAccounts", "sakql")),
+	}
+	require.NoError(t, emulatortest.Shared().Apply(emulator.SubscriptionID, variables))
+	defer func() { require.NoError(t, emulatortest.Shared().Destroy(emulator.SubscriptionID, variables)) }()
+
+	job, err := azure.BeginAdHocBackup(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, testResourceGroupName, testBackupVaultName, "bkinst-blob-blob1")
+	require.NoError(t, err)
+
+	runner := NewRunner(credential, &emulatortest.Shared().ClientOptions().ClientOptions)
+
+	table, err := runner.Execute(t.Context(), *workspace.Properties.CustomerID, "AddonAzureBackupJobs | where JobStatus == \"Completed\"", time.Hour)
+	require.NoError(t, err)
+
+	rows, err := Decode[Job](table)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, job.JobID[strings.LastIndex(job.JobID, "/")+1:], rows[0].JobUniqueID)
+	assert.Equal(t, "Backup", rows[0].JobOperation)
+	assert.Contains(t, rows[0].BackupItemUniqueID, "/backupinstances/bkinst-blob-blob1")
+
+	// The emulator only evaluates simple queries, and rejects the rest as the API would
+	_, err = runner.FailedJobs(t.Context(), *workspace.Properties.CustomerID, time.Hour)
+	assert.ErrorContains(t, err, "the emulator doesn't support the 'summarize' operator")
+}
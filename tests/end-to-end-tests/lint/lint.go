@@ -0,0 +1,230 @@
+/*
+ * Package lint checks a .tfvars or .tfvars.json file for the module offline, before it's
+ * planned. It applies the same rules as the variable validations in infrastructure/variables.tf,
+ * along with checks across fields which terraform can't express, and reports every problem at
+ * once with its position in the file.
+ */
+package lint
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/json"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+/*
+ * Problem is a single problem found in a variables file.
+ */
+type Problem struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+}
+
+/*
+ * String formats the problem as <file>:<line>:<column>: <path>: <message>.
+ */
+func (problem Problem) String() string {
+	if problem.Path == "" {
+		return fmt.Sprintf("%s:%d:%d: %s", problem.Filename, problem.Line, problem.Column, problem.Message)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s: %s", problem.Filename, problem.Line, problem.Column, problem.Path, problem.Message)
+}
+
+/*
+ * LintFile reads and checks a variables file, which is parsed as JSON if its name ends with
+ * .json and as HCL otherwise.
+ */
+func LintFile(path string) ([]Problem, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read variables file: %w", err)
+	}
+
+	return Lint(path, src), nil
+}
+
+/*
+ * Lint checks the content of a variables file, returning the problems found ordered by their
+ * position in the file.
+ */
+func Lint(filename string, src []byte) []Problem {
+	var file *hcl.File
+	var diagnostics hcl.Diagnostics
+	if strings.HasSuffix(filename, ".json") {
+		file, diagnostics = json.Parse(src, filename)
+	} else {
+		file, diagnostics = hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	}
+
+	l := &linter{filename: filename}
+	l.addDiagnostics("", diagnostics)
+
+	if file != nil && !diagnostics.HasErrors() {
+		attributes, diagnostics := file.Body.JustAttributes()
+		l.addDiagnostics("", diagnostics)
+
+		l.checkVariables(attributes, file.Body.MissingItemRange())
+	}
+
+	slices.SortStableFunc(l.problems, func(a, b Problem) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Column - b.Column
+	})
+
+	return l.problems
+}
+
+type linter struct {
+	filename string
+	problems []Problem
+}
+
+func (l *linter) report(subject hcl.Range, path string, format string, args ...any) {
+	l.problems = append(l.problems, Problem{
+		Filename: l.filename,
+		Line:     subject.Start.Line,
+		Column:   subject.Start.Column,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+/*
+ * Gets a linter which doesn't record problems, for reading values that have already been checked.
+ */
+func (l *linter) quiet() *linter {
+	return &linter{filename: l.filename}
+}
+
+func (l *linter) addDiagnostics(path string, diagnostics hcl.Diagnostics) {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity != hcl.DiagError {
+			continue
+		}
+
+		subject := hcl.Range{Filename: l.filename, Start: hcl.InitialPos}
+		if diagnostic.Subject != nil {
+			subject = *diagnostic.Subject
+		}
+
+		message := diagnostic.Summary
+		if diagnostic.Detail != "" {
+			message += ": " + diagnostic.Detail
+		}
+
+		l.report(subject, path, "%s", message)
+	}
+}
+
+/*
+ * The attributes of an object in the variables file, keyed by name, with the range of each key.
+ */
+type object struct {
+	subject hcl.Range
+	keys    map[string]hcl.Range
+	values  map[string]hcl.Expression
+	order   []string
+}
+
+/*
+ * Reads an object (or map) expression, reporting a problem and returning false if the
+ * expression isn't one.
+ */
+func (l *linter) object(expr hcl.Expression, path string) (*object, bool) {
+	pairs, diagnostics := hcl.ExprMap(expr)
+	if diagnostics.HasErrors() {
+		l.report(expr.Range(), path, "must be an object")
+		return nil, false
+	}
+
+	result := &object{subject: expr.Range(), keys: map[string]hcl.Range{}, values: map[string]hcl.Expression{}}
+	for _, pair := range pairs {
+		key, diagnostics := pair.Key.Value(nil)
+		if diagnostics.HasErrors() || key.IsNull() || !key.IsKnown() {
+			l.report(pair.Key.Range(), path, "keys must be literal strings")
+			continue
+		}
+
+		key, err := convert.Convert(key, cty.String)
+		if err != nil {
+			l.report(pair.Key.Range(), path, "keys must be strings")
+			continue
+		}
+
+		name := key.AsString()
+		if _, ok := result.values[name]; ok {
+			l.report(pair.Key.Range(), path, "'%s' is defined more than once", name)
+			continue
+		}
+
+		result.keys[name] = pair.Key.Range()
+		result.values[name] = pair.Value
+		result.order = append(result.order, name)
+	}
+
+	return result, true
+}
+
+/*
+ * Reads a list expression, reporting a problem and returning false if the expression isn't one.
+ */
+func (l *linter) list(expr hcl.Expression, path string) ([]hcl.Expression, bool) {
+	items, diagnostics := hcl.ExprList(expr)
+	if diagnostics.HasErrors() {
+		l.report(expr.Range(), path, "must be a list")
+		return nil, false
+	}
+
+	return items, true
+}
+
+/*
+ * Reads a literal value of the provided type, converting it as terraform would (e.g. a number
+ * to a string), and reporting a problem and returning false if it can't be.
+ */
+func (l *linter) value(expr hcl.Expression, path string, valueType cty.Type) (cty.Value, bool) {
+	value, diagnostics := expr.Value(nil)
+	if diagnostics.HasErrors() {
+		l.report(expr.Range(), path, "must be a literal value - variables files can't refer to other values")
+		return cty.NilVal, false
+	}
+
+	converted, err := convert.Convert(value, valueType)
+	if err != nil || converted.IsNull() {
+		l.report(expr.Range(), path, "must be a %s", valueType.FriendlyName())
+		return cty.NilVal, false
+	}
+
+	return converted, true
+}
+
+func (l *linter) stringValue(expr hcl.Expression, path string) (string, bool) {
+	value, ok := l.value(expr, path, cty.String)
+	if !ok {
+		return "", false
+	}
+
+	return value.AsString(), true
+}
+
+func (l *linter) boolValue(expr hcl.Expression, path string) (bool, bool) {
+	value, ok := l.value(expr, path, cty.Bool)
+	if !ok {
+		return false, false
+	}
+
+	return value.True(), true
+}
@@ -0,0 +1,398 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func problemStrings(problems []Problem) []string {
+	var result []string
+	for _, problem := range problems {
+		result = append(result, problem.String())
+	}
+
+	return result
+}
+
+/*
+ * TestLintValid tests that a variables file which follows every rule has no problems.
+ */
+func TestLintValid(t *testing.T) {
+	problems, err := LintFile("testdata/valid.tfvars")
+	require.NoError(t, err)
+
+	assert.Empty(t, problemStrings(problems))
+}
+
+/*
+ * TestLintInvalid tests that every problem in a variables file is reported at once, in the
+ * order they appear, including those across fields.
+ */
+func TestLintInvalid(t *testing.T) {
+	problems, err := LintFile("testdata/invalid.tfvars")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`testdata/invalid.tfvars:1:1: backup_vault_name: is required by the module but isn't set`,
+		`testdata/invalid.tfvars:2:1: backup_vault_nmae: isn't a variable of the module`,
+		`testdata/invalid.tfvars:4:25: create_resource_group: must be a bool`,
+		`testdata/invalid.tfvars:9:34: blob_storage_backups["backup1"].retention_period: Invalid retention period 'P30D': valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true.`,
+		`testdata/invalid.tfvars:10:35: blob_storage_backups["backup1"].backup_intervals[0]: Invalid backup interval: 'R/2024-01-01T00:00:00+00:00/PT1H' has the frequency PT1H, but the allowed frequencies for blob storage are P1D, P1W`,
+		`testdata/invalid.tfvars:12:34: blob_storage_backups["backup1"].storage_account_containers: At least one storage account container must be provided.`,
+		`testdata/invalid.tfvars:14:13: blob_storage_backups["backup2"]: is missing the required attribute 'storage_account_containers'`,
+		`testdata/invalid.tfvars:15:26: blob_storage_backups["backup2"].backup_name: backup_name 'storage1' is also used by blob_storage_backups["backup1"] (line 8)`,
+		`testdata/invalid.tfvars:17:26: blob_storage_backups["backup2"].backup_intervals: At least one backup interval must be provided.`,
+		`testdata/invalid.tfvars:23:13: managed_disk_backups["backup1"]: renders to the backup policy name 'bkpol-blob-storage1', which is also used by blob_storage_backups["backup1"] (line 7)`,
+		`testdata/invalid.tfvars:28:37: managed_disk_backups["backup1"].managed_disk_resource_group: is missing the required attribute 'name'`,
+	}, problemStrings(problems))
+}
+
+/*
+ * TestLintJSON tests that .tfvars.json files are checked with the same rules, and positions
+ * within the JSON.
+ */
+func TestLintJSON(t *testing.T) {
+	problems, err := LintFile("testdata/invalid.tfvars.json")
+	require.NoError(t, err)
+
+	require.Len(t, problems, 3)
+	assert.Equal(t, Problem{
+		Filename: "testdata/invalid.tfvars.json",
+		Line:     8,
+		Column:   27,
+		Path:     `postgresql_flexible_server_backups["backup1"].retention_period`,
+		Message:  "Invalid retention period 'P14D': valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true.",
+	}, problems[0])
+	assert.Equal(t, "Invalid backup interval: 'R/2024-01-01T00:00:00+00:00/P1D' has the frequency P1D, but the allowed frequencies for PostgreSQL flexible server are P1W", problems[1].Message)
+	assert.Equal(t, "'time_zone' isn't a supported attribute", problems[2].Message)
+	assert.Equal(t, 12, problems[2].Line)
+}
+
+/*
+ * TestLintExtendedRetention tests that any retention period is allowed with extended retention.
+ */
+func TestLintExtendedRetention(t *testing.T) {
+	problems := Lint("extended.tfvars", []byte(`
+resource_group_name        = "rg"
+backup_vault_name          = "vault"
+log_analytics_workspace_id = "law"
+use_extended_retention     = true
+
+managed_disk_backups = {
+  backup1 = {
+    backup_name      = "disk1"
+    retention_period = "P30D"
+    backup_intervals = ["R/2024-01-01T00:00:00Z/P1D"]
+    managed_disk_id  = "id1"
+    managed_disk_resource_group = {
+      id   = "id1"
+      name = "rg1"
+    }
+  }
+}
+`))
+
+	assert.Empty(t, problemStrings(problems))
+}
+
+/*
+ * TestLintSyntaxError tests that a file which can't be parsed is reported with the position
+ * of the syntax error, and that references to other values are rejected.
+ */
+func TestLintSyntaxError(t *testing.T) {
+	problems := Lint("broken.tfvars", []byte("resource_group_name = \"rg\"\nbackup_vault_name = = \"vault\"\n"))
+	require.NotEmpty(t, problems)
+	assert.Equal(t, "broken.tfvars", problems[0].Filename)
+	assert.Equal(t, 2, problems[0].Line)
+
+	problems = Lint("reference.tfvars", []byte("resource_group_name = var.name\nbackup_vault_name = \"vault\"\nlog_analytics_workspace_id = \"law\"\n"))
+	assert.Equal(t, []string{
+		"reference.tfvars:1:23: resource_group_name: must be a literal value - variables files can't refer to other values",
+	}, problemStrings(problems))
+}
+
+/*
+ * TestLintNamingTemplates tests that naming templates with unknown placeholders, or which
+ * render to names that Azure rejects, are reported against the template.
+ */
+func TestLintNamingTemplates(t *testing.T) {
+	problems := Lint("naming.tfvars", []byte(`resource_group_name        = "rg"
+backup_vault_name          = "vault"
+log_analytics_workspace_id = "law"
+
+postgresql_flexible_server_backups = {
+  backup1 = {
+    backup_name                     = "server_1"
+    retention_period                = "P7D"
+    backup_intervals                = ["R/2024-01-01T00:00:00Z/P1W"]
+    server_id                       = "id1"
+    server_resource_group_id        = "rg1"
+    backup_policy_naming_template   = "{resource_abbreviation}-{environment}"
+  }
+}
+`))
+
+	assert.Equal(t, []string{
+		`naming.tfvars:7:39: postgresql_flexible_server_backups["backup1"].backup_instance_naming_template: renders an invalid backup instance name: name 'bkinst-pgflex-server_1' must only contain letters, numbers and hyphens, start with a letter and end with a letter or number`,
+		`naming.tfvars:12:39: postgresql_flexible_server_backups["backup1"].backup_policy_naming_template: naming template '{resource_abbreviation}-{environment}' uses the unknown placeholder '{environment}': supported placeholders are {resource_abbreviation}, {resource_type}, {backup_name}`,
+	}, problemStrings(problems))
+}
+
+/*
+ * TestLintAksClusterBackups tests that an AKS cluster which is backed up by more than one
+ * entry is reported, as the module installs the backup extension once per backup.
+ */
+func TestLintAksClusterBackups(t *testing.T) {
+	problems := Lint("aks.tfvars", []byte(`resource_group_name        = "rg"
+backup_vault_name          = "vault"
+log_analytics_workspace_id = "law"
+
+aks_cluster_backups = {
+  backup1 = {
+    backup_name                   = "aks1"
+    retention_period              = "P7D"
+    backup_intervals              = ["R/2024-01-01T00:00:00Z/P1D"]
+    cluster_id                    = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/aks1"
+    cluster_identity_principal_id = "principal1"
+    snapshot_resource_group       = { id = "id1", name = "rg1" }
+    storage_account_id            = "id1"
+    storage_account_container     = "aks1"
+  }
+  backup2 = {
+    backup_name                   = "aks2"
+    retention_period              = "P7D"
+    backup_intervals              = ["R/2024-01-01T00:00:00Z/PT1H"]
+    cluster_id                    = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/AKS1"
+    cluster_identity_principal_id = "principal1"
+    snapshot_resource_group       = { id = "id1", name = "rg1" }
+    storage_account_id            = "id1"
+    storage_account_container     = "aks2"
+  }
+}
+`))
+
+	assert.Equal(t, []string{
+		`aks.tfvars:19:38: aks_cluster_backups["backup2"].backup_intervals[0]: Invalid backup interval: 'R/2024-01-01T00:00:00Z/PT1H' has the frequency PT1H, but the allowed frequencies for AKS cluster are PT4H, PT6H, PT8H, PT12H, P1D`,
+		`aks.tfvars:20:37: aks_cluster_backups["backup2"].cluster_id: cluster_id '/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/AKS1' is also backed up by aks_cluster_backups["backup1"] (line 10), and each AKS cluster can only be backed up once`,
+	}, problemStrings(problems))
+}
+
+/*
+ * TestLintRecoveryServicesBackups tests that backups in the recovery services vault have a
+ * single daily interval, keep enough daily recovery points, and need the vault to be named.
+ */
+func TestLintRecoveryServicesBackups(t *testing.T) {
+	problems := Lint("rsv.tfvars", []byte(`resource_group_name        = "rg"
+backup_vault_name          = "vault"
+log_analytics_workspace_id = "law"
+
+vm_backups = {
+  backup1 = {
+    backup_name      = "vm1"
+    retention_period = "P3D"
+    backup_intervals = ["R/2024-01-01T00:00:00Z/P1D", "R/2024-01-01T12:00:00Z/P1D"]
+    vm_id            = "id1"
+  }
+}
+
+file_share_backups = {
+  backup1 = {
+    backup_name                     = "share1"
+    retention_period                = "P1W"
+    backup_intervals                = ["R/2024-01-01T00:00:00Z/P1W"]
+    storage_account_id              = "id1"
+    file_share_name                 = "share1"
+    backup_instance_naming_template = "{backup_name}"
+  }
+}
+`))
+
+	assert.Equal(t, []string{
+		`rsv.tfvars:5:1: vm_backups: A recovery services vault name must be provided, as these backups are made by the recovery services vault.`,
+		`rsv.tfvars:8:24: vm_backups["backup1"].retention_period: Invalid retention period 'P3D': the period must be at least 7 days.`,
+		`rsv.tfvars:9:24: vm_backups["backup1"].backup_intervals: Exactly one backup interval must be provided, as recovery services vault policies run once a day.`,
+		`rsv.tfvars:14:1: file_share_backups: A recovery services vault name must be provided, as these backups are made by the recovery services vault.`,
+		`rsv.tfvars:17:39: file_share_backups["backup1"].retention_period: Invalid retention period 'P1W': the period must be a number of days, e.g. P7D.`,
+		`rsv.tfvars:17:39: file_share_backups["backup1"].retention_period: Invalid retention period 'P1W': valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true.`,
+		`rsv.tfvars:18:40: file_share_backups["backup1"].backup_intervals[0]: Invalid backup interval: 'R/2024-01-01T00:00:00Z/P1W' has the frequency P1W, but the allowed frequencies for file share are P1D`,
+		`rsv.tfvars:21:5: file_share_backups["backup1"]: 'backup_instance_naming_template' isn't a supported attribute`,
+	}, problemStrings(problems))
+}
+
+/*
+ * TestLintRetentionRules tests that retention rules are checked for their names, criteria and
+ * durations, and that managed disk rules can only tag backups by absolute criteria.
+ */
+func TestLintRetentionRules(t *testing.T) {
+	problems := Lint("rules.tfvars", []byte(`resource_group_name        = "rg"
+backup_vault_name          = "vault"
+log_analytics_workspace_id = "law"
+
+blob_storage_backups = {
+  backup1 = {
+    backup_name                = "storage1"
+    retention_period           = "P7D"
+    backup_intervals           = ["R/2024-01-01T00:00:00Z/P1D"]
+    storage_account_id         = "id1"
+    storage_account_containers = ["container1"]
+    retention_rules = [
+      { name = "Default", duration = "P7D", absolute_criteria = "FirstOfWeek" },
+      { name = "Monthly", duration = "P12M", absolute_criteria = "FirstOfMonth" },
+      { name = "Monthly", duration = "P1D", absolute_criteria = "FirstOfFortnight" },
+      { name = "Weekly", duration = "7 days" },
+    ]
+  }
+}
+
+managed_disk_backups = {
+  backup1 = {
+    backup_name                 = "disk1"
+    retention_period            = "P7D"
+    backup_intervals            = ["R/2024-01-01T00:00:00Z/PT4H"]
+    managed_disk_id             = "id1"
+    managed_disk_resource_group = { id = "id1", name = "rg1" }
+    retention_rules = [
+      { name = "Monthly", duration = "P7D", absolute_criteria = "FirstOfMonth" },
+      { name = "Weekly", duration = "P7D", days_of_week = ["Sunday"] },
+    ]
+  }
+}
+`))
+
+	assert.Equal(t, []string{
+		`rules.tfvars:13:16: blob_storage_backups["backup1"].retention_rules[0].name: Invalid retention rule name 'Default': it's the name of the rule for the retention_period.`,
+		`rules.tfvars:14:38: blob_storage_backups["backup1"].retention_rules[1].duration: Invalid retention rule duration 'P12M': valid durations are up to 7 days. If you require a longer retention period then please set use_extended_retention to true.`,
+		`rules.tfvars:15:16: blob_storage_backups["backup1"].retention_rules[2].name: retention rule name 'Monthly' is also used by blob_storage_backups["backup1"].retention_rules[1]`,
+		`rules.tfvars:15:65: blob_storage_backups["backup1"].retention_rules[2].absolute_criteria: Invalid absolute criteria 'FirstOfFortnight': allowed criteria are AllBackup, FirstOfDay, FirstOfWeek, FirstOfMonth, FirstOfYear`,
+		`rules.tfvars:16:7: blob_storage_backups["backup1"].retention_rules[3]: Each retention rule must have an absolute_criteria, or the days_of_week that it applies to.`,
+		`rules.tfvars:16:37: blob_storage_backups["backup1"].retention_rules[3].duration: Invalid retention rule duration '7 days': the duration must be a number of days, weeks, months or years, e.g. P4W.`,
+		`rules.tfvars:29:65: managed_disk_backups["backup1"].retention_rules[0].absolute_criteria: Invalid absolute criteria 'FirstOfMonth': allowed criteria are FirstOfDay, FirstOfWeek`,
+		`rules.tfvars:30:7: managed_disk_backups["backup1"].retention_rules[1]: Each retention rule must have an absolute_criteria.`,
+		`rules.tfvars:30:59: managed_disk_backups["backup1"].retention_rules[1].days_of_week: 'days_of_week' isn't supported, as these backups can only be tagged by absolute_criteria`,
+	}, problemStrings(problems))
+}
+
+/*
+ * TestLintRetentionRulesDailyRetention tests that blob storage retention rules can't take the
+ * name or priority of the daily-retention rule, which other backup types don't have, and that
+ * more rules can be given when it isn't enabled.
+ */
+func TestLintRetentionRulesDailyRetention(t *testing.T) {
+	problems := Lint("rules.tfvars", []byte(`resource_group_name        = "rg"
+backup_vault_name          = "vault"
+log_analytics_workspace_id = "law"
+
+blob_storage_backups = {
+  backup1 = {
+    backup_name                 = "storage1"
+    retention_period            = "P7D"
+    backup_intervals            = ["R/2024-01-01T00:00:00Z/P1D"]
+    storage_account_id          = "id1"
+    storage_account_containers  = ["container1"]
+    enable_daily_retention_rule = true
+    retention_rules = [
+      { name = "daily-retention", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule2", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule3", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule4", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule5", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule6", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule7", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule8", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule9", duration = "P7D", absolute_criteria = "FirstOfDay" },
+    ]
+  }
+  backup2 = {
+    backup_name                = "storage2"
+    retention_period           = "P7D"
+    backup_intervals           = ["R/2024-01-01T00:00:00Z/P1D"]
+    storage_account_id         = "id2"
+    storage_account_containers = ["container1"]
+    retention_rules = [
+      { name = "rule1", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule2", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule3", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule4", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule5", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule6", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule7", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule8", duration = "P7D", absolute_criteria = "FirstOfDay" },
+      { name = "rule9", duration = "P7D", absolute_criteria = "FirstOfDay" },
+    ]
+  }
+}
+
+data_lake_storage_backups = {
+  backup1 = {
+    backup_name                = "storage1"
+    retention_period           = "P7D"
+    backup_intervals           = ["R/2024-01-01T00:00:00Z/P1D"]
+    storage_account_id         = "id1"
+    storage_account_containers = ["container1"]
+    retention_rules = [
+      { name = "daily-retention", duration = "P7D", absolute_criteria = "FirstOfDay" },
+    ]
+  }
+}
+`))
+
+	assert.Equal(t, []string{
+		`rules.tfvars:13:23: blob_storage_backups["backup1"].retention_rules: Too many retention rules: at most 8 rules can be given with enable_daily_retention_rule, as the daily-retention rule has priority 9.`,
+		`rules.tfvars:14:16: blob_storage_backups["backup1"].retention_rules[0].name: Invalid retention rule name 'daily-retention': it's the name of the rule that enable_daily_retention_rule adds.`,
+	}, problemStrings(problems))
+}
+
+/*
+ * TestLintRecoveryServicesRetentionRules tests that the retention rules of backups in the
+ * recovery services vault need extended retention, and are checked for their counts, weekdays,
+ * weeks and months.
+ */
+func TestLintRecoveryServicesRetentionRules(t *testing.T) {
+	problems := Lint("rules.tfvars", []byte(`resource_group_name          = "rg"
+backup_vault_name            = "vault"
+recovery_services_vault_name = "rsvault"
+log_analytics_workspace_id   = "law"
+
+vm_backups = {
+  backup1 = {
+    backup_name      = "vm1"
+    retention_period = "P7D"
+    backup_intervals = ["R/2024-01-01T00:00:00Z/P1D"]
+    vm_id            = "id1"
+    retention_rules = {
+      weekly  = { count = 4, weekdays = ["Sunday"] }
+      monthly = { count = 1.5, weekdays = ["Sunday"], weeks = ["Fifth"] }
+      yearly  = { count = 100, weekdays = [], weeks = ["First"], months = ["January"] }
+    }
+  }
+}
+
+file_share_backups = {
+  backup1 = {
+    backup_name        = "share1"
+    retention_period   = "P7D"
+    backup_intervals   = ["R/2024-01-01T00:00:00Z/P1D"]
+    storage_account_id = "id1"
+    file_share_name    = "share1"
+    retention_rules = {
+      weekly = { count = 201, weekdays = ["Sunday"], weeks = ["First"] }
+      daily  = { count = 7 }
+    }
+  }
+}
+`))
+
+	assert.Equal(t, []string{
+		`rules.tfvars:12:23: vm_backups["backup1"].retention_rules: Invalid retention rules: weekly, monthly and yearly recovery points are kept for longer than 7 days. If you require a longer retention period then please set use_extended_retention to true.`,
+		`rules.tfvars:14:27: vm_backups["backup1"].retention_rules.monthly.count: Invalid retention rule count 1.5: the monthly rule can keep 1 to 1188 recovery points.`,
+		`rules.tfvars:14:64: vm_backups["backup1"].retention_rules.monthly.weeks[0]: Invalid value 'Fifth': allowed values are First, Second, Third, Fourth, Last`,
+		`rules.tfvars:15:27: vm_backups["backup1"].retention_rules.yearly.count: Invalid retention rule count 100: the yearly rule can keep 1 to 99 recovery points.`,
+		`rules.tfvars:15:43: vm_backups["backup1"].retention_rules.yearly.weekdays: At least one value must be provided.`,
+		`rules.tfvars:27:23: file_share_backups["backup1"].retention_rules: Invalid retention rules: weekly, monthly and yearly recovery points are kept for longer than 7 days. If you require a longer retention period then please set use_extended_retention to true.`,
+		`rules.tfvars:28:26: file_share_backups["backup1"].retention_rules.weekly.count: Invalid retention rule count 201: the weekly rule can keep 1 to 200 recovery points.`,
+		`rules.tfvars:28:54: file_share_backups["backup1"].retention_rules.weekly: 'weeks' isn't a supported attribute`,
+		`rules.tfvars:29:7: file_share_backups["backup1"].retention_rules: 'daily' isn't a supported attribute`,
+	}, problemStrings(problems))
+}
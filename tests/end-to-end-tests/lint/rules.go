@@ -0,0 +1,817 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"e2e_tests/interval"
+	"e2e_tests/naming"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindBool
+	kindNumber
+	kindStringList
+	kindStringMap
+	kindResourceGroup
+	kindBackups
+	kindRetentionRules
+	kindRecoveryServicesRetentionRules
+)
+
+type field struct {
+	name     string
+	kind     valueKind
+	required bool
+}
+
+/*
+ * The variables declared in infrastructure/variables.tf.
+ */
+var variables = []field{
+	{"resource_group_name", kindString, true},
+	{"resource_group_location", kindString, false},
+	{"create_resource_group", kindBool, false},
+	{"backup_vault_name", kindString, true},
+	{"backup_vault_redundancy", kindString, false},
+	{"backup_vault_immutability", kindString, false},
+	{"backup_vault_soft_delete", kindString, false},
+	{"recovery_services_vault_name", kindString, false},
+	{"recovery_services_vault_redundancy", kindString, false},
+	{"recovery_services_vault_immutability", kindString, false},
+	{"recovery_services_vault_soft_delete", kindString, false},
+	{"log_analytics_workspace_id", kindString, true},
+	{"tags", kindStringMap, false},
+	{"use_extended_retention", kindBool, false},
+	{"blob_storage_backups", kindBackups, false},
+	{"data_lake_storage_backups", kindBackups, false},
+	{"managed_disk_backups", kindBackups, false},
+	{"postgresql_flexible_server_backups", kindBackups, false},
+	{"mysql_flexible_server_backups", kindBackups, false},
+	{"aks_cluster_backups", kindBackups, false},
+	{"vm_backups", kindBackups, false},
+	{"file_share_backups", kindBackups, false},
+}
+
+/*
+ * backupType describes one of the backup variables and the module that it's passed to.
+ */
+type backupType struct {
+	variable       string
+	resourceType   string
+	datasourceType string
+	fields         []field
+
+	// Backups in the recovery services vault have a single daily interval, and keep a number
+	// of daily recovery points rather than a duration
+	recoveryServices     bool
+	minimumRetentionDays int
+
+	// The most recovery points that the weekly, monthly and yearly retention rules of a backup
+	// in the recovery services vault can keep
+	retentionRuleMaximumCounts map[string]int
+
+	// The absolute criteria that retention rules can tag backups with, and whether they can
+	// also tag them by day of the week, week of the month and month of the year
+	retentionRuleCriteria  []string
+	retentionRuleSchedules bool
+
+	// Blob storage policies can also have a daily-retention rule at priority 9, when it's
+	// enabled, which the retention rules must be prioritised ahead of
+	dailyRetentionRule bool
+}
+
+var commonBackupFields = []field{
+	{"backup_name", kindString, true},
+	{"retention_period", kindString, true},
+	{"backup_intervals", kindStringList, true},
+	{"backup_policy_naming_template", kindString, false},
+	{"backup_instance_naming_template", kindString, false},
+	{"retention_rules", kindRetentionRules, false},
+}
+
+/*
+ * The fields of each retention rule, which keeps the backups that it tags for longer (or
+ * shorter) than the retention period.
+ */
+var retentionRuleFields = []field{
+	{"name", kindString, true},
+	{"duration", kindString, true},
+	{"absolute_criteria", kindString, false},
+	{"days_of_week", kindStringList, false},
+	{"weeks_of_month", kindStringList, false},
+	{"months_of_year", kindStringList, false},
+}
+
+/*
+ * The absolute criteria of retention rules, as per local.valid_retention_rule_absolute_criteria
+ * and local.valid_managed_disk_retention_rule_absolute_criteria in infrastructure/variables.tf.
+ */
+var (
+	retentionRuleCriteria            = []string{"AllBackup", "FirstOfDay", "FirstOfWeek", "FirstOfMonth", "FirstOfYear"}
+	managedDiskRetentionRuleCriteria = []string{"FirstOfDay", "FirstOfWeek"}
+)
+
+/*
+ * The durations of retention rules, as per local.retention_rule_duration_pattern in
+ * infrastructure/variables.tf.
+ */
+var retentionRuleDurationPattern = regexp.MustCompile(`^P[0-9]+[DWMY]$`)
+
+/*
+ * The fields of the backups in the recovery services vault, which have no backup instance to
+ * name.
+ */
+var recoveryServicesBackupFields = []field{
+	{"backup_name", kindString, true},
+	{"retention_period", kindString, true},
+	{"backup_intervals", kindStringList, true},
+	{"backup_policy_naming_template", kindString, false},
+	{"retention_rules", kindRecoveryServicesRetentionRules, false},
+}
+
+/*
+ * The fields of the weekly, monthly and yearly retention rules of the backups in the recovery
+ * services vault, which keep a number of recovery points from the days they apply to.
+ */
+var recoveryServicesRetentionRuleFields = map[string][]field{
+	"weekly": {
+		{"count", kindNumber, true},
+		{"weekdays", kindStringList, true},
+	},
+	"monthly": {
+		{"count", kindNumber, true},
+		{"weekdays", kindStringList, true},
+		{"weeks", kindStringList, true},
+	},
+	"yearly": {
+		{"count", kindNumber, true},
+		{"weekdays", kindStringList, true},
+		{"weeks", kindStringList, true},
+		{"months", kindStringList, true},
+	},
+}
+
+/*
+ * The days, weeks and months that recovery services retention rules can apply to, as per
+ * local.valid_retention_rule_weekdays, local.valid_retention_rule_weeks and
+ * local.valid_retention_rule_months in infrastructure/variables.tf.
+ */
+var recoveryServicesRetentionRuleValues = map[string][]string{
+	"weekdays": {"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	"weeks":    {"First", "Second", "Third", "Fourth", "Last"},
+	"months":   {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+}
+
+var backupTypes = []backupType{
+	{
+		variable:       "blob_storage_backups",
+		resourceType:   naming.ResourceTypeBlobStorage,
+		datasourceType: interval.DatasourceBlobStorage,
+		fields: append(slices.Clone(commonBackupFields),
+			field{"storage_account_id", kindString, true},
+			field{"storage_account_containers", kindStringList, true},
+			field{"time_zone", kindString, false},
+			field{"enable_daily_retention_rule", kindBool, false},
+		),
+		retentionRuleCriteria:  retentionRuleCriteria,
+		retentionRuleSchedules: true,
+		dailyRetentionRule:     true,
+	},
+	{
+		variable:       "data_lake_storage_backups",
+		resourceType:   naming.ResourceTypeDataLakeStorage,
+		datasourceType: interval.DatasourceDataLakeStorage,
+		fields: append(slices.Clone(commonBackupFields),
+			field{"storage_account_id", kindString, true},
+			field{"storage_account_containers", kindStringList, true},
+			field{"time_zone", kindString, false},
+		),
+		retentionRuleCriteria:  retentionRuleCriteria,
+		retentionRuleSchedules: true,
+	},
+	{
+		variable:       "managed_disk_backups",
+		resourceType:   naming.ResourceTypeManagedDisk,
+		datasourceType: interval.DatasourceManagedDisk,
+		fields: append(slices.Clone(commonBackupFields),
+			field{"managed_disk_id", kindString, true},
+			field{"managed_disk_resource_group", kindResourceGroup, true},
+		),
+		retentionRuleCriteria:  managedDiskRetentionRuleCriteria,
+		retentionRuleSchedules: false,
+	},
+	{
+		variable:       "postgresql_flexible_server_backups",
+		resourceType:   naming.ResourceTypePostgresqlFlexibleServer,
+		datasourceType: interval.DatasourcePostgresqlFlexibleServer,
+		fields: append(slices.Clone(commonBackupFields),
+			field{"server_id", kindString, true},
+			field{"server_resource_group_id", kindString, true},
+		),
+		retentionRuleCriteria:  retentionRuleCriteria,
+		retentionRuleSchedules: true,
+	},
+	{
+		variable:       "mysql_flexible_server_backups",
+		resourceType:   naming.ResourceTypeMysqlFlexibleServer,
+		datasourceType: interval.DatasourceMysqlFlexibleServer,
+		fields: append(slices.Clone(commonBackupFields),
+			field{"server_id", kindString, true},
+			field{"server_resource_group_id", kindString, true},
+		),
+		retentionRuleCriteria:  retentionRuleCriteria,
+		retentionRuleSchedules: true,
+	},
+	{
+		variable:       "aks_cluster_backups",
+		resourceType:   naming.ResourceTypeAksCluster,
+		datasourceType: interval.DatasourceAksCluster,
+		fields: append(slices.Clone(commonBackupFields),
+			field{"cluster_id", kindString, true},
+			field{"cluster_identity_principal_id", kindString, true},
+			field{"snapshot_resource_group", kindResourceGroup, true},
+			field{"storage_account_id", kindString, true},
+			field{"storage_account_container", kindString, true},
+			field{"included_namespaces", kindStringList, false},
+			field{"excluded_namespaces", kindStringList, false},
+		),
+		retentionRuleCriteria:  retentionRuleCriteria,
+		retentionRuleSchedules: true,
+	},
+	{
+		variable:       "vm_backups",
+		resourceType:   naming.ResourceTypeVirtualMachine,
+		datasourceType: interval.DatasourceVirtualMachine,
+		fields: append(slices.Clone(recoveryServicesBackupFields),
+			field{"vm_id", kindString, true},
+		),
+		recoveryServices:           true,
+		minimumRetentionDays:       7,
+		retentionRuleMaximumCounts: map[string]int{"weekly": 5163, "monthly": 1188, "yearly": 99},
+	},
+	{
+		variable:       "file_share_backups",
+		resourceType:   naming.ResourceTypeFileShare,
+		datasourceType: interval.DatasourceFileShare,
+		fields: append(slices.Clone(recoveryServicesBackupFields),
+			field{"storage_account_id", kindString, true},
+			field{"file_share_name", kindString, true},
+		),
+		recoveryServices:           true,
+		minimumRetentionDays:       1,
+		retentionRuleMaximumCounts: map[string]int{"weekly": 200, "monthly": 120, "yearly": 10},
+	},
+}
+
+/*
+ * The retention periods that are valid without extended retention - up to 7 days, as per
+ * local.valid_retention_periods in infrastructure/variables.tf.
+ */
+var validRetentionPeriods = []string{"P1D", "P2D", "P3D", "P4D", "P5D", "P6D", "P7D"}
+
+/*
+ * The retention periods of the backups in the recovery services vault, as per
+ * local.daily_retention_period_pattern in infrastructure/variables.tf.
+ */
+var dailyRetentionPeriodPattern = regexp.MustCompile(`^P([0-9]+)D$`)
+
+/*
+ * backup holds what's needed from a backup entry to check it against the other entries.
+ */
+type backup struct {
+	path        string
+	variable    string
+	subject     hcl.Range
+	backupName  string
+	nameSubject hcl.Range
+	names       naming.Backup
+}
+
+func (l *linter) checkVariables(attributes hcl.Attributes, missingSubject hcl.Range) {
+	for _, variable := range variables {
+		if _, ok := attributes[variable.name]; !ok && variable.required {
+			l.report(missingSubject, variable.name, "is required by the module but isn't set")
+		}
+	}
+
+	for name, attribute := range attributes {
+		if !slices.ContainsFunc(variables, func(variable field) bool { return variable.name == name }) {
+			l.report(attribute.NameRange, name, "isn't a variable of the module")
+		}
+	}
+
+	extendedRetention := false
+	if attribute, ok := attributes["use_extended_retention"]; ok {
+		extendedRetention, _ = l.boolValue(attribute.Expr, attribute.Name)
+	}
+
+	for _, variable := range variables {
+		if attribute, ok := attributes[variable.name]; ok && variable.kind != kindBackups {
+			l.checkField(variable, attribute.Expr, variable.name)
+		}
+	}
+
+	_, hasRecoveryServicesVault := attributes["recovery_services_vault_name"]
+
+	// Names only collide within a vault, so the backups in each vault are checked separately
+	var backups, recoveryServicesBackups []backup
+	for _, backupType := range backupTypes {
+		attribute, ok := attributes[backupType.variable]
+		if !ok {
+			continue
+		}
+
+		checked := l.checkBackups(backupType, attribute.Expr, extendedRetention)
+		if !backupType.recoveryServices {
+			backups = append(backups, checked...)
+			continue
+		}
+
+		if len(checked) > 0 && !hasRecoveryServicesVault {
+			l.report(attribute.NameRange, backupType.variable, "A recovery services vault name must be provided, as these backups are made by the recovery services vault.")
+		}
+		recoveryServicesBackups = append(recoveryServicesBackups, checked...)
+	}
+
+	l.checkBackupNames(backups)
+	l.checkBackupNames(recoveryServicesBackups)
+}
+
+/*
+ * Checks that a value has the type expected for the field.
+ */
+func (l *linter) checkField(expected field, expr hcl.Expression, path string) {
+	switch expected.kind {
+	case kindString:
+		l.stringValue(expr, path)
+	case kindBool:
+		l.boolValue(expr, path)
+	case kindNumber:
+		l.value(expr, path, cty.Number)
+	case kindStringList:
+		l.stringList(expr, path)
+	case kindStringMap:
+		l.value(expr, path, cty.Map(cty.String))
+	case kindResourceGroup:
+		resourceGroup, ok := l.object(expr, path)
+		if !ok {
+			return
+		}
+		l.checkAttributes(resourceGroup, []field{{"id", kindString, true}, {"name", kindString, true}}, path)
+	case kindRetentionRules:
+		items, ok := l.list(expr, path)
+		if !ok {
+			return
+		}
+		for index, item := range items {
+			itemPath := fmt.Sprintf("%s[%d]", path, index)
+			if rule, ok := l.object(item, itemPath); ok {
+				l.checkAttributes(rule, retentionRuleFields, itemPath)
+			}
+		}
+	case kindRecoveryServicesRetentionRules:
+		rules, ok := l.object(expr, path)
+		if !ok {
+			return
+		}
+		for _, schedule := range rules.order {
+			fields, ok := recoveryServicesRetentionRuleFields[schedule]
+			if !ok {
+				l.report(rules.keys[schedule], path, "'%s' isn't a supported attribute", schedule)
+				continue
+			}
+			if rule, ok := l.object(rules.values[schedule], path+"."+schedule); ok {
+				l.checkAttributes(rule, fields, path+"."+schedule)
+			}
+		}
+	}
+}
+
+/*
+ * Checks that an object has the required attributes and no others, and that each attribute
+ * has the expected type.
+ */
+func (l *linter) checkAttributes(object *object, fields []field, path string) {
+	for _, expected := range fields {
+		if _, ok := object.values[expected.name]; !ok && expected.required {
+			l.report(object.subject, path, "is missing the required attribute '%s'", expected.name)
+		}
+	}
+
+	for _, name := range object.order {
+		index := slices.IndexFunc(fields, func(expected field) bool { return expected.name == name })
+		if index == -1 {
+			l.report(object.keys[name], path, "'%s' isn't a supported attribute", name)
+			continue
+		}
+
+		if fields[index].kind != kindStringList {
+			l.checkField(fields[index], object.values[name], path+"."+name)
+		}
+	}
+}
+
+/*
+ * A string read from a list, along with its index and position.
+ */
+type listItem struct {
+	index   int
+	value   string
+	subject hcl.Range
+}
+
+/*
+ * Reads a list of strings, reporting a problem for the list or each item that isn't valid, and
+ * returning the valid items.
+ */
+func (l *linter) stringList(expr hcl.Expression, path string) ([]listItem, bool) {
+	items, ok := l.list(expr, path)
+	if !ok {
+		return nil, false
+	}
+
+	var values []listItem
+	for index, item := range items {
+		if value, ok := l.stringValue(item, fmt.Sprintf("%s[%d]", path, index)); ok {
+			values = append(values, listItem{index: index, value: value, subject: item.Range()})
+		}
+	}
+
+	return values, true
+}
+
+/*
+ * Checks each entry of a backup variable against the rules in infrastructure/variables.tf,
+ * and returns the entries for checking against each other.
+ */
+func (l *linter) checkBackups(backupType backupType, expr hcl.Expression, extendedRetention bool) []backup {
+	entries, ok := l.object(expr, backupType.variable)
+	if !ok {
+		return nil
+	}
+
+	// The backup extension is installed on an AKS cluster by its backup, so each cluster can only be backed up once
+	clusters := map[string]hcl.Range{}
+	clusterPaths := map[string]string{}
+
+	var backups []backup
+	for _, key := range entries.order {
+		path := fmt.Sprintf("%s[%q]", backupType.variable, key)
+
+		entry, ok := l.object(entries.values[key], path)
+		if !ok {
+			continue
+		}
+
+		l.checkAttributes(entry, backupType.fields, path)
+
+		if expr, ok := entry.values["backup_intervals"]; ok {
+			if intervals, ok := l.stringList(expr, path+".backup_intervals"); ok {
+				if items, _ := hcl.ExprList(expr); len(items) == 0 {
+					l.report(expr.Range(), path+".backup_intervals", "At least one backup interval must be provided.")
+				} else if len(items) > 1 && backupType.recoveryServices {
+					l.report(expr.Range(), path+".backup_intervals", "Exactly one backup interval must be provided, as recovery services vault policies run once a day.")
+				}
+
+				for _, item := range intervals {
+					if _, err := interval.Validate(item.value, backupType.datasourceType); err != nil {
+						l.report(item.subject, fmt.Sprintf("%s.backup_intervals[%d]", path, item.index), "Invalid backup interval: %v", err)
+					}
+				}
+			}
+		}
+
+		if expr, ok := entry.values["storage_account_containers"]; ok {
+			if _, ok := l.stringList(expr, path+".storage_account_containers"); ok {
+				if items, _ := hcl.ExprList(expr); len(items) == 0 {
+					l.report(expr.Range(), path+".storage_account_containers", "At least one storage account container must be provided.")
+				}
+			}
+		}
+
+		if expr, ok := entry.values["cluster_id"]; ok {
+			if clusterID, ok := l.quiet().stringValue(expr, ""); ok {
+				key := strings.ToLower(clusterID)
+				if previous, ok := clusters[key]; ok {
+					l.report(expr.Range(), path+".cluster_id",
+						"cluster_id '%s' is also backed up by %s (line %d), and each AKS cluster can only be backed up once", clusterID, clusterPaths[key], previous.Start.Line)
+				} else {
+					clusters[key] = expr.Range()
+					clusterPaths[key] = path
+				}
+			}
+		}
+
+		if expr, ok := entry.values["retention_period"]; ok && backupType.recoveryServices {
+			l.checkDailyRetentionPeriod(backupType, expr, path+".retention_period")
+		}
+
+		if expr, ok := entry.values["retention_period"]; ok && !extendedRetention {
+			if retentionPeriod, ok := l.quiet().stringValue(expr, ""); ok && !slices.Contains(validRetentionPeriods, retentionPeriod) {
+				l.report(expr.Range(), path+".retention_period",
+					"Invalid retention period '%s': valid periods are up to 7 days. If you require a longer retention period then please set use_extended_retention to true.", retentionPeriod)
+			}
+		}
+
+		if expr, ok := entry.values["retention_rules"]; ok && backupType.recoveryServices {
+			l.checkRecoveryServicesRetentionRules(backupType, expr, path+".retention_rules", extendedRetention)
+		} else if ok {
+			dailyRetentionRule := false
+			if expr, ok := entry.values["enable_daily_retention_rule"]; ok {
+				dailyRetentionRule, _ = l.quiet().boolValue(expr, "")
+			}
+
+			l.checkRetentionRules(backupType, expr, path+".retention_rules", extendedRetention, dailyRetentionRule)
+		}
+
+		if backup, ok := l.readBackup(backupType, entry, path); ok {
+			backups = append(backups, backup)
+		}
+	}
+
+	return backups
+}
+
+/*
+ * Checks the retention rules of a backup against the rules in infrastructure/variables.tf. Their
+ * types have already been checked, so values of the wrong type are skipped.
+ */
+func (l *linter) checkRetentionRules(backupType backupType, expr hcl.Expression, path string, extendedRetention bool, dailyRetentionRule bool) {
+	quiet := l.quiet()
+
+	items, ok := quiet.list(expr, path)
+	if !ok {
+		return
+	}
+
+	if dailyRetentionRule && len(items) > 8 {
+		l.report(expr.Range(), path, "Too many retention rules: at most 8 rules can be given with enable_daily_retention_rule, as the daily-retention rule has priority 9.")
+	}
+
+	names := map[string]string{}
+	for index, item := range items {
+		rulePath := fmt.Sprintf("%s[%d]", path, index)
+
+		rule, ok := quiet.object(item, rulePath)
+		if !ok {
+			continue
+		}
+
+		if expr, ok := rule.values["name"]; ok {
+			if name, ok := quiet.stringValue(expr, ""); ok {
+				if name == "Default" {
+					l.report(expr.Range(), rulePath+".name", "Invalid retention rule name 'Default': it's the name of the rule for the retention_period.")
+				} else if name == "daily-retention" && backupType.dailyRetentionRule {
+					l.report(expr.Range(), rulePath+".name", "Invalid retention rule name 'daily-retention': it's the name of the rule that enable_daily_retention_rule adds.")
+				} else if previous, ok := names[name]; ok {
+					l.report(expr.Range(), rulePath+".name", "retention rule name '%s' is also used by %s", name, previous)
+				} else {
+					names[name] = rulePath
+				}
+			}
+		}
+
+		criteriaExpr, hasAbsoluteCriteria := rule.values["absolute_criteria"]
+		if hasAbsoluteCriteria {
+			if criteria, ok := quiet.stringValue(criteriaExpr, ""); ok && !slices.Contains(backupType.retentionRuleCriteria, criteria) {
+				l.report(criteriaExpr.Range(), rulePath+".absolute_criteria",
+					"Invalid absolute criteria '%s': allowed criteria are %s", criteria, strings.Join(backupType.retentionRuleCriteria, ", "))
+			}
+		}
+
+		_, hasDaysOfWeek := rule.values["days_of_week"]
+		if !hasAbsoluteCriteria && !backupType.retentionRuleSchedules {
+			l.report(rule.subject, rulePath, "Each retention rule must have an absolute_criteria.")
+		} else if !hasAbsoluteCriteria && !hasDaysOfWeek {
+			l.report(rule.subject, rulePath, "Each retention rule must have an absolute_criteria, or the days_of_week that it applies to.")
+		}
+
+		for _, name := range []string{"days_of_week", "weeks_of_month", "months_of_year"} {
+			expr, ok := rule.values[name]
+			if !ok {
+				continue
+			}
+
+			if !backupType.retentionRuleSchedules {
+				l.report(expr.Range(), rulePath+"."+name, "'%s' isn't supported, as these backups can only be tagged by absolute_criteria", name)
+				continue
+			}
+
+			l.stringList(expr, rulePath+"."+name)
+		}
+
+		if expr, ok := rule.values["duration"]; ok {
+			if duration, ok := quiet.stringValue(expr, ""); ok {
+				if !retentionRuleDurationPattern.MatchString(duration) {
+					l.report(expr.Range(), rulePath+".duration", "Invalid retention rule duration '%s': the duration must be a number of days, weeks, months or years, e.g. P4W.", duration)
+				} else if !extendedRetention && !slices.Contains(validRetentionPeriods, duration) {
+					l.report(expr.Range(), rulePath+".duration",
+						"Invalid retention rule duration '%s': valid durations are up to 7 days. If you require a longer retention period then please set use_extended_retention to true.", duration)
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Checks the weekly, monthly and yearly retention rules of a backup in the recovery services
+ * vault against the rules in infrastructure/variables.tf. Their types have already been checked,
+ * other than the lists of weekdays, weeks and months, so values of the wrong type are skipped.
+ */
+func (l *linter) checkRecoveryServicesRetentionRules(backupType backupType, expr hcl.Expression, path string, extendedRetention bool) {
+	quiet := l.quiet()
+
+	rules, ok := quiet.object(expr, path)
+	if !ok {
+		return
+	}
+
+	if len(rules.order) > 0 && !extendedRetention {
+		l.report(expr.Range(), path,
+			"Invalid retention rules: weekly, monthly and yearly recovery points are kept for longer than 7 days. If you require a longer retention period then please set use_extended_retention to true.")
+	}
+
+	for _, schedule := range rules.order {
+		fields, ok := recoveryServicesRetentionRuleFields[schedule]
+		if !ok {
+			continue
+		}
+
+		rulePath := path + "." + schedule
+
+		rule, ok := quiet.object(rules.values[schedule], rulePath)
+		if !ok {
+			continue
+		}
+
+		if expr, ok := rule.values["count"]; ok {
+			if count, ok := quiet.value(expr, "", cty.Number); ok {
+				number := count.AsBigFloat()
+				value, _ := number.Int64()
+
+				maximum := backupType.retentionRuleMaximumCounts[schedule]
+				if !number.IsInt() || value < 1 || value > int64(maximum) {
+					l.report(expr.Range(), rulePath+".count", "Invalid retention rule count %s: the %s rule can keep 1 to %d recovery points.", number.Text('f', -1), schedule, maximum)
+				}
+			}
+		}
+
+		for _, field := range fields {
+			expr, ok := rule.values[field.name]
+			if !ok || field.kind != kindStringList {
+				continue
+			}
+
+			items, ok := l.stringList(expr, rulePath+"."+field.name)
+			if !ok {
+				continue
+			}
+
+			if len(items) == 0 {
+				l.report(expr.Range(), rulePath+"."+field.name, "At least one value must be provided.")
+			}
+
+			valid := recoveryServicesRetentionRuleValues[field.name]
+			for _, item := range items {
+				if !slices.Contains(valid, item.value) {
+					l.report(item.subject, fmt.Sprintf("%s.%s[%d]", rulePath, field.name, item.index),
+						"Invalid value '%s': allowed values are %s", item.value, strings.Join(valid, ", "))
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Checks that the retention period of a backup in the recovery services vault is a number of
+ * days, and keeps at least as many daily recovery points as the datasource needs.
+ */
+func (l *linter) checkDailyRetentionPeriod(backupType backupType, expr hcl.Expression, path string) {
+	retentionPeriod, ok := l.quiet().stringValue(expr, "")
+	if !ok {
+		return
+	}
+
+	match := dailyRetentionPeriodPattern.FindStringSubmatch(retentionPeriod)
+	if match == nil {
+		l.report(expr.Range(), path, "Invalid retention period '%s': the period must be a number of days, e.g. P7D.", retentionPeriod)
+		return
+	}
+
+	if days, err := strconv.Atoi(match[1]); err != nil || days < backupType.minimumRetentionDays {
+		l.report(expr.Range(), path, "Invalid retention period '%s': the period must be at least %d days.", retentionPeriod, backupType.minimumRetentionDays)
+	}
+}
+
+/*
+ * Reads the backup name and naming templates of an entry, reporting templates with unknown
+ * placeholders and names which break the Azure naming rules.
+ */
+func (l *linter) readBackup(backupType backupType, entry *object, path string) (backup, bool) {
+	expr, ok := entry.values["backup_name"]
+	if !ok {
+		return backup{}, false
+	}
+
+	// Problems with the values have already been reported, so they aren't reported again here
+	quiet := l.quiet()
+
+	backupName, ok := quiet.stringValue(expr, path)
+	if !ok {
+		return backup{}, false
+	}
+
+	names := naming.Backup{ID: path, ResourceType: backupType.resourceType, BackupName: backupName}
+	policySubject, instanceSubject := expr.Range(), expr.Range()
+
+	if templateExpr, ok := entry.values["backup_policy_naming_template"]; ok {
+		names.PolicyNamingTemplate, _ = quiet.stringValue(templateExpr, path)
+		policySubject = templateExpr.Range()
+	}
+
+	if templateExpr, ok := entry.values["backup_instance_naming_template"]; ok {
+		names.InstanceNamingTemplate, _ = quiet.stringValue(templateExpr, path)
+		instanceSubject = templateExpr.Range()
+	}
+
+	l.checkName(policySubject, path+".backup_policy_naming_template", names.PolicyNamingTemplate, names.PolicyName(), naming.ResourceBackupPolicy)
+	if !backupType.recoveryServices {
+		l.checkName(instanceSubject, path+".backup_instance_naming_template", names.InstanceNamingTemplate, names.InstanceName(), naming.ResourceBackupInstance)
+	}
+
+	return backup{
+		path:        path,
+		variable:    backupType.variable,
+		subject:     entry.subject,
+		backupName:  backupName,
+		nameSubject: expr.Range(),
+		names:       names,
+	}, true
+}
+
+/*
+ * Checks that a naming template only uses known placeholders, and that the name it renders to
+ * follows the Azure naming rules.
+ */
+func (l *linter) checkName(subject hcl.Range, path string, template string, name string, resource string) {
+	if err := naming.ValidateTemplate(template); err != nil {
+		l.report(subject, path, "%v", err)
+		return
+	}
+
+	if err := naming.ValidateName(name); err != nil {
+		l.report(subject, path, "renders an invalid %s name: %v", resource, err)
+	}
+}
+
+/*
+ * Checks that backup names are unique within each backup variable, and that no two backups
+ * render to the same backup policy or backup instance name, as they'd collide in the vault.
+ */
+func (l *linter) checkBackupNames(backups []backup) {
+	byPath := map[string]backup{}
+	duplicates := map[string]bool{}
+
+	for index, current := range backups {
+		byPath[current.path] = current
+
+		for _, previous := range backups[:index] {
+			if previous.variable == current.variable && previous.backupName == current.backupName {
+				l.report(current.nameSubject, current.path+".backup_name",
+					"backup_name '%s' is also used by %s (line %d)", current.backupName, previous.path, previous.nameSubject.Start.Line)
+				duplicates[current.path] = true
+				break
+			}
+		}
+	}
+
+	names := make([]naming.Backup, len(backups))
+	for index, backup := range backups {
+		names[index] = backup.names
+	}
+
+	// Duplicate backup names always collide, and have already been reported
+	for _, collision := range naming.FindCollisions(names) {
+		first := byPath[collision.Backups[0].ID]
+
+		for _, names := range collision.Backups[1:] {
+			if duplicates[names.ID] {
+				continue
+			}
+
+			current := byPath[names.ID]
+			l.report(current.subject, current.path,
+				"renders to the %s name '%s', which is also used by %s (line %d)", collision.Resource, collision.Name, first.path, first.subject.Start.Line)
+		}
+	}
+}
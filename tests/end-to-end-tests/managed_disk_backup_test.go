@@ -5,7 +5,11 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"e2e_tests/audit"
+	"e2e_tests/azure"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
@@ -27,18 +31,18 @@ type TestManagedDiskBackupExternalResources struct {
  * Creates resources which are "external" to the az-backup module, and models
  * what would be backed up in a real scenario.
  */
-func setupExternalResourcesForManagedDiskBackupTest(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestManagedDiskBackupExternalResources {
+func setupExternalResourcesForManagedDiskBackupTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestManagedDiskBackupExternalResources {
 	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
-	resourceGroup := CreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
 
 	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
-	logAnalyticsWorkspace := CreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
 
 	managedDiskOneName := fmt.Sprintf("disk-%s-external-1", strings.ToLower(uniqueId))
-	managedDiskOne := CreateManagedDisk(t, credential, subscriptionID, externalResourceGroupName, managedDiskOneName, resourceGroupLocation, int32(1))
+	managedDiskOne := MustCreateManagedDisk(t, credential, subscriptionID, externalResourceGroupName, managedDiskOneName, resourceGroupLocation, int32(1))
 
 	managedDiskTwoName := fmt.Sprintf("disk-%s-external-2", strings.ToLower(uniqueId))
-	managedDiskTwo := CreateManagedDisk(t, credential, subscriptionID, externalResourceGroupName, managedDiskTwoName, resourceGroupLocation, int32(1))
+	managedDiskTwo := MustCreateManagedDisk(t, credential, subscriptionID, externalResourceGroupName, managedDiskTwoName, resourceGroupLocation, int32(1))
 
 	externalResources := &TestManagedDiskBackupExternalResources{
 		ResourceGroup:         resourceGroup,
@@ -56,8 +60,8 @@ func setupExternalResourcesForManagedDiskBackupTest(t *testing.T, credential *az
 func TestManagedDiskBackup(t *testing.T) {
 	t.Parallel()
 
-	environment := GetEnvironmentConfiguration(t)
-	credential := GetAzureCredential(t, environment)
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
 
 	uniqueId := random.UniqueId()
 	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
@@ -88,6 +92,10 @@ func TestManagedDiskBackup(t *testing.T) {
 				"id":   *externalResources.ResourceGroup.ID,
 				"name": *externalResources.ResourceGroup.Name,
 			},
+			"retention_rules": []map[string]interface{}{
+				{"name": "Weekly", "duration": "P4W", "absolute_criteria": "FirstOfWeek"},
+				{"name": "Daily", "duration": "P14D", "absolute_criteria": "FirstOfDay"},
+			},
 		},
 	}
 
@@ -97,9 +105,9 @@ func TestManagedDiskBackup(t *testing.T) {
 	defer test_structure.RunTestStage(t, "teardown", func() {
 		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
 
-		terraform.Destroy(t, terraformOptions)
+		DestroyTerraform(t, environment, terraformOptions)
 
-		DeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
 	})
 
 	// Setup stage
@@ -114,6 +122,7 @@ func TestManagedDiskBackup(t *testing.T) {
 				"resource_group_location":    resourceGroupLocation,
 				"backup_vault_name":          backupVaultName,
 				"log_analytics_workspace_id": *externalResources.LogAnalyticsWorkspace.ID,
+				"use_extended_retention":     true,
 				"managed_disk_backups":       managedDiskBackups,
 			},
 
@@ -128,22 +137,21 @@ func TestManagedDiskBackup(t *testing.T) {
 		// Save options for later test stages
 		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
 
-		terraform.InitAndApply(t, terraformOptions)
+		ApplyTerraform(t, environment, terraformOptions)
 	})
 
 	// Validate stage
 	// ...
 
 	test_structure.RunTestStage(t, "validate", func() {
-		backupVault := GetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
-		backupPolicies := GetBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
-		backupInstances := GetBackupInstances(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupPolicies := MustGetBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupInstances := MustGetBackupInstances(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
 
 		assert.Equal(t, len(managedDiskBackups), len(backupPolicies), "Expected to find %2 backup policies in vault", len(managedDiskBackups))
 		assert.Equal(t, len(managedDiskBackups), len(backupInstances), "Expected to find %2 backup instances in vault", len(managedDiskBackups))
 
 		for _, backup := range managedDiskBackups {
-			backupName := backup["backup_name"].(string)
 			retentionPeriod := backup["retention_period"].(string)
 			backupIntervals := backup["backup_intervals"].([]string)
 			managedDiskId := backup["managed_disk_id"].(string)
@@ -151,38 +159,47 @@ func TestManagedDiskBackup(t *testing.T) {
 			managedDiskResourceGroupId := managedDiskResourceGroup["id"].(string)
 
 			// Validate backup policy
-			backupPolicyName := fmt.Sprintf("bkpol-disk-%s", backupName)
-			backupPolicy := GetBackupPolicyForName(backupPolicies, backupPolicyName)
+			backupNames := MustGetBackupNames(t, naming.ResourceTypeManagedDisk, backup)
+			backupPolicyName := backupNames.PolicyName()
+			backupPolicy := azure.GetBackupPolicyForName(backupPolicies, backupPolicyName)
 			assert.NotNil(t, backupPolicy, "Expected to find a backup policy called %s", backupPolicyName)
 
 			// Validate retention period
 			backupPolicyProperties := backupPolicy.Properties.(*armdataprotection.BackupPolicy)
-			retentionRule := GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "Default").(*armdataprotection.AzureRetentionRule)
+			retentionRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "Default").(*armdataprotection.AzureRetentionRule)
 			deleteOption := retentionRule.Lifecycles[0].DeleteAfter.(*armdataprotection.AbsoluteDeleteOption)
 			assert.Equal(t, retentionPeriod, *deleteOption.Duration, "Expected the backup policy retention period to be %s", retentionPeriod)
 
+			// Validate retention tiers
+			if retentionRules, ok := backup["retention_rules"].([]map[string]interface{}); ok {
+				AssertRetentionTiersEqual(t, retentionRules, backupPolicyProperties, armdataprotection.DataStoreTypesOperationalStore)
+			}
+
 			// Validate backup intervals
-			backupRule := GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "BackupIntervals").(*armdataprotection.AzureBackupRule)
+			backupRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "BackupIntervals").(*armdataprotection.AzureBackupRule)
 			schedule := backupRule.Trigger.(*armdataprotection.ScheduleBasedTriggerContext).Schedule
-			for index, interval := range schedule.RepeatingTimeIntervals {
-				assert.Equal(t, backupIntervals[index], *interval, "Expected backup policy repeating interval %s to be %s", index, backupIntervals[index])
-			}
+			AssertBackupIntervalsEqual(t, backupIntervals, schedule.RepeatingTimeIntervals)
 
 			// Validate backup instance
-			backupInstanceName := fmt.Sprintf("bkinst-disk-%s", backupName)
-			backupInstance := GetBackupInstanceForName(backupInstances, backupInstanceName)
+			backupInstanceName := backupNames.InstanceName()
+			backupInstance := azure.GetBackupInstanceForName(backupInstances, backupInstanceName)
 			assert.NotNil(t, backupInstance, "Expected to find a backup policy called %s", backupInstanceName)
 			assert.Equal(t, managedDiskId, *backupInstance.Properties.DataSourceInfo.ResourceID, "Expected the backup instance source resource ID to be %s", managedDiskId)
 			assert.Equal(t, *backupPolicy.ID, *backupInstance.Properties.PolicyInfo.PolicyID, "Expected the backup instance policy ID to be %s", backupPolicy.ID)
 
 			// Validate role assignments
-			snapshotContributorRoleDefinition := GetRoleDefinition(t, credential, "Disk Snapshot Contributor")
-			snapshotContributorRoleAssignment := GetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, snapshotContributorRoleDefinition, managedDiskResourceGroupId)
+			snapshotContributorRoleDefinition := MustGetRoleDefinition(t, credential, "Disk Snapshot Contributor")
+			snapshotContributorRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, snapshotContributorRoleDefinition, managedDiskResourceGroupId)
 			assert.NotNil(t, snapshotContributorRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", snapshotContributorRoleDefinition.Name, *backupVault.Identity.PrincipalID, managedDiskResourceGroupId)
 
-			backupReaderRoleDefinition := GetRoleDefinition(t, credential, "Disk Backup Reader")
-			backupReaderRoleAssignment := GetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, backupReaderRoleDefinition, managedDiskId)
+			backupReaderRoleDefinition := MustGetRoleDefinition(t, credential, "Disk Backup Reader")
+			backupReaderRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, backupReaderRoleDefinition, managedDiskId)
 			assert.NotNil(t, backupReaderRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", backupReaderRoleDefinition.Name, *backupVault.Identity.PrincipalID, managedDiskId)
 		}
+
+		// Validate the backup vault against the rules that the module promises
+		auditOptions := audit.DefaultOptions()
+		auditOptions.ExtendedRetention = true
+		AssertVaultCompliance(t, credential, *backupVault.ID, auditOptions)
 	})
 }
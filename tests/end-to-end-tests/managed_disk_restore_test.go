@@ -0,0 +1,172 @@
+package e2e_tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestManagedDiskRestoreExternalResources struct {
+	ResourceGroup         armresources.ResourceGroup
+	RestoreResourceGroup  armresources.ResourceGroup
+	LogAnalyticsWorkspace armoperationalinsights.Workspace
+	ManagedDisk           armcompute.Disk
+	ManagedDiskData       []byte
+}
+
+/*
+ * Creates resources which are "external" to the az-backup module, and models
+ * what would be backed up in a real scenario.
+ */
+func setupExternalResourcesForManagedDiskRestoreTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestManagedDiskRestoreExternalResources {
+	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+
+	restoreResourceGroupName := fmt.Sprintf("%s-restore", resourceGroupName)
+	restoreResourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, restoreResourceGroupName, resourceGroupLocation)
+
+	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+
+	// 1MiB of known data, which is checked once the disk has been restored
+	pattern := []byte(fmt.Sprintf("az-backup restore drill %s\n", uniqueId))
+	managedDiskData := bytes.Repeat(pattern, (1<<20)/len(pattern)+1)[:1<<20]
+
+	managedDiskName := fmt.Sprintf("disk-%s-external", strings.ToLower(uniqueId))
+	managedDisk := MustCreateManagedDiskWithData(t, credential, subscriptionID, externalResourceGroupName, managedDiskName, resourceGroupLocation, int32(1), managedDiskData)
+
+	externalResources := &TestManagedDiskRestoreExternalResources{
+		ResourceGroup:         resourceGroup,
+		RestoreResourceGroup:  restoreResourceGroup,
+		LogAnalyticsWorkspace: logAnalyticsWorkspace,
+		ManagedDisk:           managedDisk,
+		ManagedDiskData:       managedDiskData,
+	}
+
+	return externalResources
+}
+
+/*
+ * TestManagedDiskRestore tests that a managed disk backup can be restored to a new managed
+ * disk, and that the restored disk matches the disk that was backed up.
+ */
+func TestManagedDiskRestore(t *testing.T) {
+	t.Parallel()
+
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
+
+	uniqueId := random.UniqueId()
+	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
+	resourceGroupLocation := "uksouth"
+	backupVaultName := fmt.Sprintf("bvault-nhsbackup-%s", uniqueId)
+
+	externalResources := setupExternalResourcesForManagedDiskRestoreTest(t, credential, environment.SubscriptionID, resourceGroupName, resourceGroupLocation, uniqueId)
+
+	// A map of backups which we'll use to apply the TF module, and then restore from
+	managedDiskBackups := map[string]map[string]interface{}{
+		"backup1": {
+			"backup_name":      "disk1",
+			"retention_period": "P7D",
+			"backup_intervals": []string{"R/2024-01-01T00:00:00+00:00/P1D"},
+			"managed_disk_id":  *externalResources.ManagedDisk.ID,
+			"managed_disk_resource_group": map[string]interface{}{
+				"id":   *externalResources.ResourceGroup.ID,
+				"name": *externalResources.ResourceGroup.Name,
+			},
+		},
+	}
+
+	// Teardown stage
+	// ...
+
+	defer test_structure.RunTestStage(t, "teardown", func() {
+		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
+
+		DestroyTerraform(t, environment, terraformOptions)
+
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.RestoreResourceGroup.Name)
+	})
+
+	// Setup stage
+	// ...
+
+	test_structure.RunTestStage(t, "setup", func() {
+		terraformOptions := &terraform.Options{
+			TerraformDir: environment.TerraformFolder,
+
+			Vars: map[string]interface{}{
+				"resource_group_name":        resourceGroupName,
+				"resource_group_location":    resourceGroupLocation,
+				"backup_vault_name":          backupVaultName,
+				"log_analytics_workspace_id": *externalResources.LogAnalyticsWorkspace.ID,
+				"managed_disk_backups":       managedDiskBackups,
+			},
+
+			BackendConfig: map[string]interface{}{
+				"resource_group_name":  environment.TerraformStateResourceGroup,
+				"storage_account_name": environment.TerraformStateStorageAccount,
+				"container_name":       environment.TerraformStateContainer,
+				"key":                  backupVaultName + ".tfstate",
+			},
+		}
+
+		// Save options for later test stages
+		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
+
+		ApplyTerraform(t, environment, terraformOptions)
+	})
+
+	// Validate stage
+	// ...
+
+	test_structure.RunTestStage(t, "validate", func() {
+		backupInstanceName := MustGetBackupNames(t, naming.ResourceTypeManagedDisk, managedDiskBackups["backup1"]).InstanceName()
+		MustBeginAdHocBackup(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+
+		recoveryPoints := MustGetRecoveryPoints(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+		assert.NotEmpty(t, recoveryPoints, "Expected the ad-hoc backup to create a recovery point")
+
+		if len(recoveryPoints) == 0 {
+			return
+		}
+
+		// The vault identity needs to be able to create the restored disk in the target resource group
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		MustCreateRoleAssignment(t, credential, environment.SubscriptionID, *externalResources.RestoreResourceGroup.ID,
+			"Disk Restore Operator", *backupVault.Identity.PrincipalID)
+		MustWaitForRoleAssignment(t, credential, environment.SubscriptionID, *externalResources.RestoreResourceGroup.ID,
+			"Disk Restore Operator", *backupVault.Identity.PrincipalID)
+
+		restoredDiskName := fmt.Sprintf("disk-%s-restored", strings.ToLower(uniqueId))
+		restoreJobID := MustBeginManagedDiskRestore(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName,
+			*recoveryPoints[0].Name, externalResources.RestoreResourceGroup, restoredDiskName)
+
+		MustWaitForBackupJob(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, restoreJobID)
+
+		// Validate the restored disk matches the source disk
+		restoredDisk := MustGetManagedDisk(t, credential, environment.SubscriptionID, *externalResources.RestoreResourceGroup.Name, restoredDiskName)
+		assert.Equal(t, *externalResources.ManagedDisk.Properties.DiskSizeGB, *restoredDisk.Properties.DiskSizeGB, "Expected the restored disk size to match the source disk")
+		assert.Equal(t, *externalResources.ManagedDisk.SKU.Name, *restoredDisk.SKU.Name, "Expected the restored disk SKU to match the source disk")
+
+		expectedChecksum := sha256.Sum256(externalResources.ManagedDiskData)
+		actualChecksum := MustGetManagedDiskChecksum(t, credential, environment.SubscriptionID, *externalResources.RestoreResourceGroup.Name,
+			restoredDiskName, int64(len(externalResources.ManagedDiskData)))
+		assert.Equal(t, hex.EncodeToString(expectedChecksum[:]), actualChecksum, "Expected the restored disk data to match the data written to the source disk")
+	})
+}
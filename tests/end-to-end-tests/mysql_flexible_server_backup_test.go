@@ -0,0 +1,197 @@
+package e2e_tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"e2e_tests/audit"
+	"e2e_tests/azure"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mysql/armmysqlflexibleservers"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestMysqlFlexibleServerBackupExternalResources struct {
+	ResourceGroup          armresources.ResourceGroup
+	LogAnalyticsWorkspace  armoperationalinsights.Workspace
+	MysqlFlexibleServerOne armmysqlflexibleservers.Server
+	MysqlFlexibleServerTwo armmysqlflexibleservers.Server
+}
+
+/*
+ * Creates resources which are "external" to the az-backup module, and models
+ * what would be backed up in a real scenario.
+ */
+func setupExternalResourcesForMysqlFlexibleServerBackupTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestMysqlFlexibleServerBackupExternalResources {
+	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+
+	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+
+	MysqlFlexibleServerOneName := fmt.Sprintf("mysqlflexserver-%s-external-1", strings.ToLower(uniqueId))
+	MysqlFlexibleServerOne := MustCreateMysqlFlexibleServer(t, credential, subscriptionID, externalResourceGroupName, MysqlFlexibleServerOneName, resourceGroupLocation, int32(32))
+
+	MysqlFlexibleServerTwoName := fmt.Sprintf("mysqlflexserver-%s-external-2", strings.ToLower(uniqueId))
+	MysqlFlexibleServerTwo := MustCreateMysqlFlexibleServer(t, credential, subscriptionID, externalResourceGroupName, MysqlFlexibleServerTwoName, resourceGroupLocation, int32(32))
+
+	externalResources := &TestMysqlFlexibleServerBackupExternalResources{
+		ResourceGroup:          resourceGroup,
+		LogAnalyticsWorkspace:  logAnalyticsWorkspace,
+		MysqlFlexibleServerOne: MysqlFlexibleServerOne,
+		MysqlFlexibleServerTwo: MysqlFlexibleServerTwo,
+	}
+
+	return externalResources
+}
+
+/*
+ * TestMysqlFlexibleServerBackup tests the deployment of a backup vault and backup policies for mysql flexible servers.
+ */
+func TestMysqlFlexibleServerBackup(t *testing.T) {
+	t.Parallel()
+
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
+
+	uniqueId := random.UniqueId()
+	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
+	resourceGroupLocation := "uksouth"
+	backupVaultName := fmt.Sprintf("bvault-nhsbackup-%s", uniqueId)
+
+	externalResources := setupExternalResourcesForMysqlFlexibleServerBackupTest(t, credential, environment.SubscriptionID, resourceGroupName, resourceGroupLocation, uniqueId)
+
+	// A map of backups which we'll use to apply the TF module, and then validate the
+	// policies have been created correctly
+	MysqlFlexibleServerBackups := map[string]map[string]interface{}{
+		"backup1": {
+			"backup_name":              "server1",
+			"retention_period":         "P6D",
+			"backup_intervals":         []string{"R/2024-01-01T00:00:00+00:00/P1W"},
+			"server_id":                *externalResources.MysqlFlexibleServerOne.ID,
+			"server_resource_group_id": *externalResources.ResourceGroup.ID,
+		},
+		"backup2": {
+			"backup_name":              "server2",
+			"retention_period":         "P7D",
+			"backup_intervals":         []string{"R/2024-01-01T00:00:00+00:00/P1W"},
+			"server_id":                *externalResources.MysqlFlexibleServerTwo.ID,
+			"server_resource_group_id": *externalResources.ResourceGroup.ID,
+			"retention_rules": []map[string]interface{}{
+				{"name": "Monthly", "duration": "P12M", "absolute_criteria": "FirstOfMonth"},
+			},
+		},
+	}
+
+	// Teardown stage
+	// ...
+
+	defer test_structure.RunTestStage(t, "teardown", func() {
+		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
+
+		DestroyTerraform(t, environment, terraformOptions)
+
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+	})
+
+	// Setup stage
+	// ...
+
+	test_structure.RunTestStage(t, "setup", func() {
+		terraformOptions := &terraform.Options{
+			TerraformDir: environment.TerraformFolder,
+
+			Vars: map[string]interface{}{
+				"resource_group_name":           resourceGroupName,
+				"resource_group_location":       resourceGroupLocation,
+				"backup_vault_name":             backupVaultName,
+				"log_analytics_workspace_id":    *externalResources.LogAnalyticsWorkspace.ID,
+				"use_extended_retention":        true,
+				"mysql_flexible_server_backups": MysqlFlexibleServerBackups,
+			},
+
+			BackendConfig: map[string]interface{}{
+				"resource_group_name":  environment.TerraformStateResourceGroup,
+				"storage_account_name": environment.TerraformStateStorageAccount,
+				"container_name":       environment.TerraformStateContainer,
+				"key":                  backupVaultName + ".tfstate",
+			},
+		}
+
+		// Save options for later test stages
+		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
+
+		ApplyTerraform(t, environment, terraformOptions)
+	})
+
+	// Validate stage
+	// ...
+
+	test_structure.RunTestStage(t, "validate", func() {
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupPolicies := MustGetBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupInstances := MustGetBackupInstances(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+
+		assert.Equal(t, len(MysqlFlexibleServerBackups), len(backupPolicies), "Expected to find %d backup policies in vault", len(MysqlFlexibleServerBackups))
+		assert.Equal(t, len(MysqlFlexibleServerBackups), len(backupInstances), "Expected to find %d backup instances in vault", len(MysqlFlexibleServerBackups))
+
+		for _, backup := range MysqlFlexibleServerBackups {
+			retentionPeriod := backup["retention_period"].(string)
+			backupIntervals := backup["backup_intervals"].([]string)
+			ServerId := backup["server_id"].(string)
+			ServerResourceGroupId := backup["server_resource_group_id"].(string)
+
+			// Validate backup policy
+			backupNames := MustGetBackupNames(t, naming.ResourceTypeMysqlFlexibleServer, backup)
+			backupPolicyName := backupNames.PolicyName()
+			backupPolicy := azure.GetBackupPolicyForName(backupPolicies, backupPolicyName)
+			assert.NotNil(t, backupPolicy, "Expected to find a backup policy called %s", backupPolicyName)
+
+			// Validate retention period
+			backupPolicyProperties := backupPolicy.Properties.(*armdataprotection.BackupPolicy)
+			retentionRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "Default").(*armdataprotection.AzureRetentionRule)
+			deleteOption := retentionRule.Lifecycles[0].DeleteAfter.(*armdataprotection.AbsoluteDeleteOption)
+			assert.Equal(t, retentionPeriod, *deleteOption.Duration, "Expected the backup policy retention period to be %s", retentionPeriod)
+
+			// Validate retention tiers
+			if retentionRules, ok := backup["retention_rules"].([]map[string]interface{}); ok {
+				AssertRetentionTiersEqual(t, retentionRules, backupPolicyProperties, armdataprotection.DataStoreTypesVaultStore)
+			}
+
+			// Validate backup intervals
+			backupRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "BackupIntervals").(*armdataprotection.AzureBackupRule)
+			schedule := backupRule.Trigger.(*armdataprotection.ScheduleBasedTriggerContext).Schedule
+			AssertBackupIntervalsEqual(t, backupIntervals, schedule.RepeatingTimeIntervals)
+
+			// Validate backup instance
+			backupInstanceName := backupNames.InstanceName()
+			backupInstance := azure.GetBackupInstanceForName(backupInstances, backupInstanceName)
+			assert.NotNil(t, backupInstance, "Expected to find a backup policy called %s", backupInstanceName)
+			assert.Equal(t, ServerId, *backupInstance.Properties.DataSourceInfo.ResourceID, "Expected the backup instance source resource ID to be %s", ServerId)
+			assert.Equal(t, *backupPolicy.ID, *backupInstance.Properties.PolicyInfo.PolicyID, "Expected the backup instance policy ID to be %s", backupPolicy.ID)
+
+			// Validate role assignments
+			readerRoleDefinition := MustGetRoleDefinition(t, credential, "Reader")
+			readerRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, readerRoleDefinition, ServerResourceGroupId)
+			assert.NotNil(t, readerRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", readerRoleDefinition.Name, *backupVault.Identity.PrincipalID, ServerResourceGroupId)
+
+			backupAndExportOperatorRoleDefinition := MustGetRoleDefinition(t, credential, "MySQL Backup And Export Operator")
+			backupAndExportOperatorRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, backupAndExportOperatorRoleDefinition, ServerId)
+			assert.NotNil(t, backupAndExportOperatorRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", backupAndExportOperatorRoleDefinition.Name, *backupVault.Identity.PrincipalID, ServerId)
+		}
+
+		// Validate the backup vault against the rules that the module promises
+		auditOptions := audit.DefaultOptions()
+		auditOptions.ExtendedRetention = true
+		AssertVaultCompliance(t, credential, *backupVault.ID, auditOptions)
+	})
+}
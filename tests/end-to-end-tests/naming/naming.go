@@ -0,0 +1,233 @@
+/*
+ * Package naming renders the backup policy and backup instance naming templates exactly as the
+ * backup modules' locals.tf does, checks the rendered names against the Azure Data Protection
+ * naming rules, and finds backups whose names would collide in the same backup vault.
+ */
+package naming
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+/*
+ * The naming template used when a backup doesn't set one, as per the optional() defaults in
+ * infrastructure/variables.tf.
+ */
+const DefaultTemplate = "{resource_abbreviation}-{resource_type}-{backup_name}"
+
+/*
+ * The values of {resource_abbreviation} for each kind of resource the backup modules create.
+ */
+const (
+	AbbreviationBackupPolicy   = "bkpol"
+	AbbreviationBackupInstance = "bkinst"
+)
+
+/*
+ * The values of {resource_type} for each backup module, as per local.resource_type.
+ */
+const (
+	ResourceTypeBlobStorage              = "blob"
+	ResourceTypeDataLakeStorage          = "adls"
+	ResourceTypeManagedDisk              = "disk"
+	ResourceTypePostgresqlFlexibleServer = "pgflex"
+	ResourceTypeMysqlFlexibleServer      = "mysqlflex"
+	ResourceTypeAksCluster               = "aks"
+	ResourceTypeVirtualMachine           = "vm"
+	ResourceTypeFileShare                = "afs"
+)
+
+const (
+	placeholderResourceAbbreviation = "{resource_abbreviation}"
+	placeholderResourceType         = "{resource_type}"
+	placeholderBackupName           = "{backup_name}"
+)
+
+var placeholders = []string{placeholderResourceAbbreviation, placeholderResourceType, placeholderBackupName}
+
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+/*
+ * The rules that Azure Data Protection applies to backup policy and backup instance names.
+ */
+const (
+	minNameLength = 3
+	maxNameLength = 150
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*[A-Za-z0-9]$`)
+
+/*
+ * Render renders a naming template with the same nested replace() calls as the backup modules'
+ * locals.tf. Placeholders are replaced in order, so a value containing a later placeholder is
+ * replaced too, and unknown placeholders are left as they are.
+ */
+func Render(template string, resourceAbbreviation string, resourceType string, backupName string) string {
+	name := strings.ReplaceAll(template, placeholderResourceAbbreviation, resourceAbbreviation)
+	name = strings.ReplaceAll(name, placeholderResourceType, resourceType)
+
+	return strings.ReplaceAll(name, placeholderBackupName, backupName)
+}
+
+/*
+ * ValidateTemplate checks that a naming template only uses the placeholders the backup modules
+ * replace.
+ */
+func ValidateTemplate(template string) error {
+	var errs []error
+	for _, placeholder := range placeholderPattern.FindAllString(template, -1) {
+		if !slices.Contains(placeholders, placeholder) {
+			errs = append(errs, fmt.Errorf("naming template '%s' uses the unknown placeholder '%s': supported placeholders are %s",
+				template, placeholder, strings.Join(placeholders, ", ")))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+ * ValidateName checks a rendered name against the Azure Data Protection naming rules: it must
+ * be 3 to 150 characters of letters, numbers and hyphens, starting with a letter and ending with
+ * a letter or number.
+ */
+func ValidateName(name string) error {
+	if len(name) < minNameLength || len(name) > maxNameLength {
+		return fmt.Errorf("name '%s' is %d characters long, but must be between %d and %d characters", name, len(name), minNameLength, maxNameLength)
+	}
+
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("name '%s' must only contain letters, numbers and hyphens, start with a letter and end with a letter or number", name)
+	}
+
+	return nil
+}
+
+/*
+ * Backup is a backup passed to one of the backup modules, holding what's needed to render its
+ * names. Empty templates are treated as DefaultTemplate, as terraform does when they aren't set.
+ */
+type Backup struct {
+	// ID identifies the backup to the caller, e.g. its key in the module variables
+	ID                     string
+	ResourceType           string
+	BackupName             string
+	PolicyNamingTemplate   string
+	InstanceNamingTemplate string
+}
+
+/*
+ * PolicyName gets the name of the backup policy that the backup module creates.
+ */
+func (backup Backup) PolicyName() string {
+	return Render(orDefault(backup.PolicyNamingTemplate), AbbreviationBackupPolicy, backup.ResourceType, backup.BackupName)
+}
+
+/*
+ * InstanceName gets the name of the backup instance that the backup module creates.
+ */
+func (backup Backup) InstanceName() string {
+	return Render(orDefault(backup.InstanceNamingTemplate), AbbreviationBackupInstance, backup.ResourceType, backup.BackupName)
+}
+
+/*
+ * Validate checks the backup's naming templates and the names they render to, returning every
+ * problem found.
+ */
+func (backup Backup) Validate() error {
+	var errs []error
+	for _, template := range []string{orDefault(backup.PolicyNamingTemplate), orDefault(backup.InstanceNamingTemplate)} {
+		if err := ValidateTemplate(template); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// A name with unknown placeholders breaks the naming rules too, but that's already reported
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if err := ValidateName(backup.PolicyName()); err != nil {
+		errs = append(errs, fmt.Errorf("invalid backup policy name: %w", err))
+	}
+
+	if err := ValidateName(backup.InstanceName()); err != nil {
+		errs = append(errs, fmt.Errorf("invalid backup instance name: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func orDefault(template string) string {
+	if template == "" {
+		return DefaultTemplate
+	}
+
+	return template
+}
+
+/*
+ * The kinds of resource whose names can collide.
+ */
+const (
+	ResourceBackupPolicy   = "backup policy"
+	ResourceBackupInstance = "backup instance"
+)
+
+/*
+ * Collision is a name that more than one backup renders to, which would make the backups
+ * overwrite each other's resources in the vault.
+ */
+type Collision struct {
+	Resource string
+	Name     string
+	// Backups are the backups which render to the name, in the order they were provided
+	Backups []Backup
+}
+
+/*
+ * Error formats the collision for reporting.
+ */
+func (collision Collision) Error() string {
+	ids := make([]string, len(collision.Backups))
+	for index, backup := range collision.Backups {
+		ids[index] = backup.ID
+	}
+
+	return fmt.Sprintf("%s name '%s' is used by more than one backup: %s", collision.Resource, collision.Name, strings.Join(ids, ", "))
+}
+
+/*
+ * FindCollisions finds the backup policy and backup instance names that more than one of the
+ * backups deployed to a vault render to, across all of the backup modules. Names are compared
+ * case-insensitively, as Azure resource names are. Policy collisions come before instance
+ * collisions, and each are ordered by the first backup involved.
+ */
+func FindCollisions(backups []Backup) []Collision {
+	var collisions []Collision
+	collisions = append(collisions, findCollisions(backups, ResourceBackupPolicy, Backup.PolicyName)...)
+	collisions = append(collisions, findCollisions(backups, ResourceBackupInstance, Backup.InstanceName)...)
+
+	return collisions
+}
+
+func findCollisions(backups []Backup, resource string, name func(Backup) string) []Collision {
+	var collisions []Collision
+	indexes := map[string]int{}
+
+	for _, backup := range backups {
+		key := strings.ToLower(name(backup))
+		if index, ok := indexes[key]; ok {
+			collisions[index].Backups = append(collisions[index].Backups, backup)
+			continue
+		}
+
+		indexes[key] = len(collisions)
+		collisions = append(collisions, Collision{Resource: resource, Name: name(backup), Backups: []Backup{backup}})
+	}
+
+	return slices.DeleteFunc(collisions, func(collision Collision) bool { return len(collision.Backups) < 2 })
+}
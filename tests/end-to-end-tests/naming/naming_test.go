@@ -0,0 +1,89 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+ * TestRender tests that templates are rendered with the same nested replaces as terraform,
+ * including placeholders that appear in the replaced values.
+ */
+func TestRender(t *testing.T) {
+	assert.Equal(t, "bkpol-blob-storage1", Render(DefaultTemplate, AbbreviationBackupPolicy, ResourceTypeBlobStorage, "storage1"))
+	assert.Equal(t, "storage1-disk-storage1-bkinst", Render("{backup_name}-{resource_type}-{backup_name}-{resource_abbreviation}", AbbreviationBackupInstance, ResourceTypeManagedDisk, "storage1"))
+	assert.Equal(t, "fixed-name", Render("fixed-name", AbbreviationBackupPolicy, ResourceTypeManagedDisk, "disk1"))
+	assert.Equal(t, "{unknown}-pgflex", Render("{unknown}-{resource_type}", AbbreviationBackupPolicy, ResourceTypePostgresqlFlexibleServer, "server1"))
+
+	// {resource_type} is replaced after {resource_abbreviation}, so it's also replaced in the
+	// abbreviation, but {backup_name} is replaced last so isn't replaced in the resource type
+	assert.Equal(t, "pol-disk", Render("{resource_abbreviation}", "pol-{resource_type}", ResourceTypeManagedDisk, "disk1"))
+	assert.Equal(t, "{resource_type}", Render("{backup_name}", AbbreviationBackupPolicy, ResourceTypeManagedDisk, "{resource_type}"))
+}
+
+/*
+ * TestBackupNames tests that empty templates render the default names.
+ */
+func TestBackupNames(t *testing.T) {
+	backup := Backup{ResourceType: ResourceTypePostgresqlFlexibleServer, BackupName: "server1"}
+	assert.Equal(t, "bkpol-pgflex-server1", backup.PolicyName())
+	assert.Equal(t, "bkinst-pgflex-server1", backup.InstanceName())
+
+	backup.InstanceNamingTemplate = "{backup_name}-instance"
+	assert.Equal(t, "server1-instance", backup.InstanceName())
+}
+
+/*
+ * TestValidate tests that unknown placeholders and names breaking the naming rules are rejected.
+ */
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Backup{ResourceType: ResourceTypeBlobStorage, BackupName: "storage1"}.Validate())
+
+	err := Backup{ResourceType: ResourceTypeBlobStorage, BackupName: "storage1", PolicyNamingTemplate: "{resource_abbreviation}-{environment}-{backup_name}"}.Validate()
+	assert.EqualError(t, err, "naming template '{resource_abbreviation}-{environment}-{backup_name}' uses the unknown placeholder '{environment}': supported placeholders are {resource_abbreviation}, {resource_type}, {backup_name}")
+
+	err = Backup{ResourceType: ResourceTypeBlobStorage, BackupName: "storage_1"}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid backup policy name: name 'bkpol-blob-storage_1' must only contain letters, numbers and hyphens")
+	assert.Contains(t, err.Error(), "invalid backup instance name: name 'bkinst-blob-storage_1' must only contain letters, numbers and hyphens")
+
+	err = Backup{ResourceType: ResourceTypeBlobStorage, BackupName: "x", PolicyNamingTemplate: "{backup_name}"}.Validate()
+	assert.EqualError(t, err, "invalid backup policy name: name 'x' is 1 characters long, but must be between 3 and 150 characters")
+
+	assert.NoError(t, ValidateName(strings.Repeat("a", 150)))
+	assert.Error(t, ValidateName(strings.Repeat("a", 151)))
+	assert.Error(t, ValidateName("1-policy"))
+	assert.Error(t, ValidateName("policy-"))
+}
+
+/*
+ * TestFindCollisions tests that names rendered by more than one backup are found across
+ * backup types, ignoring case.
+ */
+func TestFindCollisions(t *testing.T) {
+	blob := Backup{ID: `blob_storage_backups["backup1"]`, ResourceType: ResourceTypeBlobStorage, BackupName: "backup1"}
+	disk := Backup{ID: `managed_disk_backups["backup1"]`, ResourceType: ResourceTypeManagedDisk, BackupName: "backup1"}
+	pgflex := Backup{ID: `postgresql_flexible_server_backups["backup1"]`, ResourceType: ResourceTypePostgresqlFlexibleServer, BackupName: "Backup1",
+		PolicyNamingTemplate: "{resource_abbreviation}-shared-{backup_name}", InstanceNamingTemplate: "{resource_abbreviation}-shared-{backup_name}"}
+
+	assert.Empty(t, FindCollisions([]Backup{blob, disk, pgflex}))
+
+	blob.PolicyNamingTemplate = "{resource_abbreviation}-shared-{backup_name}"
+	disk.PolicyNamingTemplate = "{resource_abbreviation}-shared-{backup_name}"
+	disk.InstanceNamingTemplate = "{resource_abbreviation}-blob-{backup_name}"
+
+	collisions := FindCollisions([]Backup{blob, disk, pgflex})
+	require.Len(t, collisions, 2)
+
+	assert.Equal(t, ResourceBackupPolicy, collisions[0].Resource)
+	assert.Equal(t, "bkpol-shared-backup1", collisions[0].Name)
+	assert.Equal(t, []Backup{blob, disk, pgflex}, collisions[0].Backups)
+
+	assert.Equal(t, ResourceBackupInstance, collisions[1].Resource)
+	assert.Equal(t, "bkinst-blob-backup1", collisions[1].Name)
+	assert.Equal(t, []Backup{blob, disk}, collisions[1].Backups)
+	assert.Equal(t, `backup instance name 'bkinst-blob-backup1' is used by more than one backup: blob_storage_backups["backup1"], managed_disk_backups["backup1"]`, collisions[1].Error())
+}
@@ -0,0 +1,336 @@
+/*
+ * Package plancheck reads the JSON output of `terraform show -json` for a plan of the module,
+ * and classifies the changes to backup vaults, backup policies, backup instances and role
+ * assignments by how much protection they could lose, before the plan is applied.
+ */
+package plancheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"e2e_tests/interval"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+const (
+	typeBackupVault    = "azurerm_data_protection_backup_vault"
+	typeRoleAssignment = "azurerm_role_assignment"
+
+	prefixBackupPolicy   = "azurerm_data_protection_backup_policy_"
+	prefixBackupInstance = "azurerm_data_protection_backup_instance_"
+)
+
+/*
+ * LoadPlan reads a plan from a file written by `terraform show -json`.
+ */
+func LoadPlan(path string) (*tfjson.Plan, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plan: %w", err)
+	}
+	defer file.Close()
+
+	return ReadPlan(file)
+}
+
+/*
+ * ReadPlan reads a plan written by `terraform show -json`.
+ */
+func ReadPlan(r io.Reader) (*tfjson.Plan, error) {
+	var plan tfjson.Plan
+	if err := json.NewDecoder(r).Decode(&plan); err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	return &plan, nil
+}
+
+/*
+ * The protection settings of a backup vault in the plan, which the changes to the resources in
+ * the same module are checked against.
+ */
+type vault struct {
+	moduleAddress string
+	immutability  string
+	softDelete    string
+}
+
+/*
+ * Analyze classifies every change in the plan to a backup vault, backup policy, backup instance
+ * or role assignment. Changes that do nothing are left out.
+ */
+func Analyze(plan *tfjson.Plan) *Report {
+	vaults := findVaults(plan)
+
+	report := &Report{}
+	for _, change := range plan.ResourceChanges {
+		if change.Mode != tfjson.ManagedResourceMode || change.Change == nil {
+			continue
+		}
+
+		actions := change.Change.Actions
+		if actions.NoOp() || actions.Read() {
+			continue
+		}
+
+		finding := Finding{
+			Address:  change.Address,
+			Type:     change.Type,
+			Action:   describeActions(actions),
+			Severity: SeveritySafe,
+		}
+
+		before, _ := change.Change.Before.(map[string]any)
+		after, _ := change.Change.After.(map[string]any)
+		vault := vaultFor(vaults, change.ModuleAddress)
+
+		switch {
+		case change.Type == typeBackupVault:
+			classifyBackupVault(&finding, actions, before, after, replacedBy(change))
+		case strings.HasPrefix(change.Type, prefixBackupPolicy):
+			classifyBackupPolicy(&finding, actions, before, after, vault)
+		case strings.HasPrefix(change.Type, prefixBackupInstance):
+			classifyBackupInstance(&finding, actions, before, after, vault)
+		case change.Type == typeRoleAssignment:
+			classifyRoleAssignment(&finding, actions, before)
+		default:
+			continue
+		}
+
+		report.Findings = append(report.Findings, finding)
+	}
+
+	return report
+}
+
+/*
+ * Finds the backup vaults in the plan, with the settings that are in force while the plan is
+ * applied - those before the apply, or those a new vault is created with.
+ */
+func findVaults(plan *tfjson.Plan) []vault {
+	var vaults []vault
+	for _, change := range plan.ResourceChanges {
+		if change.Type != typeBackupVault || change.Change == nil {
+			continue
+		}
+
+		values, ok := change.Change.Before.(map[string]any)
+		if !ok {
+			values, _ = change.Change.After.(map[string]any)
+		}
+
+		vaults = append(vaults, vault{
+			moduleAddress: change.ModuleAddress,
+			immutability:  stringValue(values, "immutability"),
+			softDelete:    stringValue(values, "soft_delete"),
+		})
+	}
+
+	return vaults
+}
+
+/*
+ * Finds the vault deployed by the same instance of the module as a resource, which is the one
+ * in the closest enclosing module (the backup modules are called from the module that deploys
+ * the vault).
+ */
+func vaultFor(vaults []vault, moduleAddress string) *vault {
+	var closest *vault
+	for index, vault := range vaults {
+		if vault.moduleAddress != "" && moduleAddress != vault.moduleAddress && !strings.HasPrefix(moduleAddress, vault.moduleAddress+".") {
+			continue
+		}
+
+		if closest == nil || len(vault.moduleAddress) > len(closest.moduleAddress) {
+			closest = &vaults[index]
+		}
+	}
+
+	return closest
+}
+
+func classifyBackupVault(finding *Finding, actions tfjson.Actions, before map[string]any, after map[string]any, replacedBy []string) {
+	switch {
+	case actions.Replace():
+		finding.add(SeverityDestructive, "Replaces the backup vault%s, which deletes every backup instance and recovery point in it", because(replacedBy))
+	case actions.Delete():
+		finding.add(SeverityDestructive, "Deletes the backup vault, along with every backup instance and recovery point in it")
+	case actions.Update():
+		beforeImmutability, afterImmutability := stringValue(before, "immutability"), stringValue(after, "immutability")
+		switch {
+		case beforeImmutability == afterImmutability:
+		case beforeImmutability == "Locked":
+			finding.add(SeverityRisky, "Changes immutability from Locked to %s, which Azure doesn't allow, so the apply will fail", afterImmutability)
+		case afterImmutability == "Locked":
+			finding.add(SeverityRisky, "Locks immutability, which can't be undone")
+		case afterImmutability == "Disabled":
+			finding.add(SeverityRisky, "Disables immutability, so recovery points can be deleted before they expire")
+		}
+
+		beforeSoftDelete, afterSoftDelete := stringValue(before, "soft_delete"), stringValue(after, "soft_delete")
+		switch {
+		case beforeSoftDelete == afterSoftDelete:
+		case beforeSoftDelete == "AlwaysOn":
+			finding.add(SeverityRisky, "Changes soft delete from AlwaysOn to %s, which Azure doesn't allow, so the apply will fail", afterSoftDelete)
+		case afterSoftDelete == "AlwaysOn":
+			finding.add(SeverityRisky, "Sets soft delete to AlwaysOn, which can't be undone")
+		case afterSoftDelete == "Off":
+			finding.add(SeverityRisky, "Turns off soft delete, so deleted backup data can't be recovered")
+		}
+	}
+}
+
+func classifyBackupPolicy(finding *Finding, actions tfjson.Actions, before map[string]any, after map[string]any, vault *vault) {
+	switch {
+	case actions.Replace():
+		finding.add(SeverityDestructive, "Replaces the backup policy, which Azure only allows once no backup instances use it")
+	case actions.Delete():
+		finding.add(SeverityDestructive, "Deletes the backup policy, which Azure only allows once no backup instances use it")
+	}
+
+	beforeRetention, afterRetention := retention(before), retention(after)
+	if beforeRetention == nil || afterRetention == nil || afterRetention.Approximate() >= beforeRetention.Approximate() {
+		return
+	}
+
+	finding.add(SeverityRisky, "Lowers retention from %s to %s, so recovery points older than %s will be deleted", beforeRetention, afterRetention, afterRetention)
+	if vault != nil && immutabilityEnabled(vault.immutability) {
+		finding.add(SeverityRisky, "The vault's immutability is %s, which blocks lowering retention, so the apply will fail", vault.immutability)
+	}
+}
+
+func classifyBackupInstance(finding *Finding, actions tfjson.Actions, before map[string]any, after map[string]any, vault *vault) {
+	switch {
+	case actions.Replace():
+		finding.add(SeverityDestructive, "Replaces the backup instance, which stops protection and deletes its recovery points")
+	case actions.Delete():
+		finding.add(SeverityDestructive, "Deletes the backup instance, which stops protection and deletes its recovery points")
+	case actions.Update():
+		if stringValue(before, "backup_policy_id") != stringValue(after, "backup_policy_id") {
+			finding.add(SeverityRisky, "Moves the backup instance to another backup policy, which changes its schedule and retention")
+		}
+		return
+	default:
+		return
+	}
+
+	if vault == nil {
+		return
+	}
+
+	if immutabilityEnabled(vault.immutability) {
+		finding.add(SeverityRisky, "The vault's immutability is %s, which blocks deleting recovery points before they expire, so the destroy will fail", vault.immutability)
+	}
+
+	if softDeleteEnabled(vault.softDelete) {
+		finding.add(SeverityRisky, "The vault's soft delete is %s, so the deleted backup instance is kept in a soft deleted state", vault.softDelete)
+		if actions.Replace() && stringValue(before, "name") == stringValue(after, "name") {
+			finding.add(SeverityRisky, "A backup instance can't be created with the name of a soft deleted one, so the replacement will fail")
+		}
+	}
+}
+
+func classifyRoleAssignment(finding *Finding, actions tfjson.Actions, before map[string]any) {
+	role, scope := stringValue(before, "role_definition_name"), stringValue(before, "scope")
+
+	switch {
+	case actions.Replace():
+		finding.add(SeverityRisky, "Replaces the '%s' role assignment on %s, so backups of it may fail while it's recreated", role, scope)
+	case actions.Delete():
+		finding.add(SeverityRisky, "Removes the '%s' role assignment on %s, so backups of it will fail if the vault still protects it", role, scope)
+	}
+}
+
+/*
+ * Gets the retention period of a backup policy, from the attribute that each type of policy
+ * sets it in.
+ */
+func retention(values map[string]any) *interval.Duration {
+	value := stringValue(values, "vault_default_retention_duration")
+	if value == "" {
+		value = stringValue(values, "default_retention_duration")
+	}
+	if value == "" {
+		value = stringValue(firstBlock(firstBlock(values, "default_retention_rule"), "life_cycle"), "duration")
+	}
+
+	duration, err := interval.ParseDuration(value)
+	if err != nil {
+		return nil
+	}
+
+	return &duration
+}
+
+/*
+ * Gets the names of the attributes that force a resource to be replaced.
+ */
+func replacedBy(change *tfjson.ResourceChange) []string {
+	var attributes []string
+	for _, path := range change.Change.ReplacePaths {
+		if steps, ok := path.([]any); ok && len(steps) > 0 {
+			if attribute, ok := steps[0].(string); ok && !slices.Contains(attributes, attribute) {
+				attributes = append(attributes, attribute)
+			}
+		}
+	}
+
+	return attributes
+}
+
+func because(attributes []string) string {
+	if len(attributes) == 0 {
+		return ""
+	}
+
+	return " because " + strings.Join(attributes, ", ") + " changed"
+}
+
+func describeActions(actions tfjson.Actions) string {
+	switch {
+	case actions.Replace():
+		return "replace"
+	case actions.Create():
+		return "create"
+	case actions.Update():
+		return "update"
+	case actions.Delete():
+		return "delete"
+	case actions.Forget():
+		return "forget"
+	}
+
+	return fmt.Sprint(actions)
+}
+
+func immutabilityEnabled(immutability string) bool {
+	return immutability == "Unlocked" || immutability == "Locked"
+}
+
+func softDeleteEnabled(softDelete string) bool {
+	return softDelete == "On" || softDelete == "AlwaysOn"
+}
+
+func stringValue(values map[string]any, name string) string {
+	value, _ := values[name].(string)
+	return value
+}
+
+/*
+ * Gets the first of a nested block's values, as terraform writes nested blocks as lists.
+ */
+func firstBlock(values map[string]any, name string) map[string]any {
+	blocks, _ := values[name].([]any)
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	block, _ := blocks[0].(map[string]any)
+	return block
+}
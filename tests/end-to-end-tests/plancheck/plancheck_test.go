@@ -0,0 +1,159 @@
+package plancheck
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	blobBackupModule   = `module.backup.module.blob_storage_backup["backup1"]`
+	diskBackupModule   = `module.backup.module.managed_disk_backup["backup1"]`
+	pgflexBackupModule = `module.backup.module.postgresql_flexible_server_backup["backup1"]`
+)
+
+func mustAnalyze(t *testing.T, path string) *Report {
+	t.Helper()
+
+	plan, err := LoadPlan(path)
+	require.NoError(t, err)
+
+	return Analyze(plan)
+}
+
+func findingFor(t *testing.T, report *Report, address string) Finding {
+	t.Helper()
+
+	for _, finding := range report.Findings {
+		if finding.Address == address {
+			return finding
+		}
+	}
+
+	require.Failf(t, "finding not found", "Expected a finding for %s", address)
+	return Finding{}
+}
+
+/*
+ * TestAnalyzeSafe tests that creating the module's resources is safe, and that changes to
+ * other resources, and changes which do nothing, are left out.
+ */
+func TestAnalyzeSafe(t *testing.T) {
+	report := mustAnalyze(t, "testdata/safe.json")
+
+	require.Len(t, report.Findings, 4)
+	for _, finding := range report.Findings {
+		assert.Equal(t, SeveritySafe, finding.Severity, finding.Address)
+		assert.Equal(t, "create", finding.Action, finding.Address)
+		assert.Empty(t, finding.Reasons, finding.Address)
+	}
+
+	assert.Equal(t, SeveritySafe, report.Highest())
+}
+
+/*
+ * TestAnalyzeProtectedVault tests that changes which lose protection are classified by how
+ * much they lose, with warnings specific to a vault with immutability and soft delete enabled.
+ */
+func TestAnalyzeProtectedVault(t *testing.T) {
+	report := mustAnalyze(t, "testdata/protected_vault.json")
+
+	require.Len(t, report.Findings, 7)
+	assert.Equal(t, SeverityDestructive, report.Highest())
+
+	vault := findingFor(t, report, "module.backup.azurerm_data_protection_backup_vault.backup_vault")
+	assert.Equal(t, SeverityRisky, vault.Severity)
+	assert.Equal(t, []string{
+		"Locks immutability, which can't be undone",
+		"Sets soft delete to AlwaysOn, which can't be undone",
+	}, vault.Reasons)
+
+	instance := findingFor(t, report, blobBackupModule+".azurerm_data_protection_backup_instance_blob_storage.backup_instance")
+	assert.Equal(t, SeverityDestructive, instance.Severity)
+	assert.Equal(t, "delete", instance.Action)
+	assert.Equal(t, []string{
+		"Deletes the backup instance, which stops protection and deletes its recovery points",
+		"The vault's immutability is Unlocked, which blocks deleting recovery points before they expire, so the destroy will fail",
+		"The vault's soft delete is On, so the deleted backup instance is kept in a soft deleted state",
+	}, instance.Reasons)
+
+	role := findingFor(t, report, blobBackupModule+".azurerm_role_assignment.role_assignment")
+	assert.Equal(t, SeverityRisky, role.Severity)
+	assert.Contains(t, role.Reasons[0], "Removes the 'Storage Account Backup Contributor' role assignment")
+
+	diskPolicy := findingFor(t, report, diskBackupModule+".azurerm_data_protection_backup_policy_disk.backup_policy")
+	assert.Equal(t, SeverityDestructive, diskPolicy.Severity)
+	assert.Equal(t, "replace", diskPolicy.Action)
+	assert.Contains(t, diskPolicy.Reasons, "Lowers retention from P7D to P3D, so recovery points older than P3D will be deleted")
+	assert.Contains(t, diskPolicy.Reasons, "The vault's immutability is Unlocked, which blocks lowering retention, so the apply will fail")
+
+	// Raising retention is only destructive because the policy is replaced
+	pgflexPolicy := findingFor(t, report, pgflexBackupModule+".azurerm_data_protection_backup_policy_postgresql_flexible_server.backup_policy")
+	assert.Equal(t, []string{"Replaces the backup policy, which Azure only allows once no backup instances use it"}, pgflexPolicy.Reasons)
+
+	diskInstance := findingFor(t, report, diskBackupModule+".azurerm_data_protection_backup_instance_disk.backup_instance")
+	assert.Equal(t, SeverityRisky, diskInstance.Severity)
+	assert.Equal(t, []string{"Moves the backup instance to another backup policy, which changes its schedule and retention"}, diskInstance.Reasons)
+}
+
+/*
+ * TestAnalyzeReplaceVault tests that replacing a vault names the attributes that force it,
+ * and that a vault in the root module is found for the backup modules.
+ */
+func TestAnalyzeReplaceVault(t *testing.T) {
+	report := mustAnalyze(t, "testdata/replace_vault.json")
+
+	require.Len(t, report.Findings, 2)
+
+	vault := findingFor(t, report, "azurerm_data_protection_backup_vault.backup_vault")
+	assert.Equal(t, SeverityDestructive, vault.Severity)
+	assert.Equal(t, []string{"Replaces the backup vault because redundancy changed, which deletes every backup instance and recovery point in it"}, vault.Reasons)
+
+	instance := findingFor(t, report, `module.blob_storage_backup["backup1"].azurerm_data_protection_backup_instance_blob_storage.backup_instance`)
+	assert.Equal(t, SeverityDestructive, instance.Severity)
+	assert.Len(t, instance.Reasons, 1, "Expected no warnings for a vault without immutability or soft delete")
+}
+
+/*
+ * TestReadPlanInvalid tests that input which isn't a plan is rejected.
+ */
+func TestReadPlanInvalid(t *testing.T) {
+	_, err := ReadPlan(strings.NewReader(`{"format_version": "2.0"}`))
+	assert.ErrorContains(t, err, "unsupported plan format version")
+
+	_, err = ReadPlan(strings.NewReader(`not json`))
+	assert.Error(t, err)
+}
+
+/*
+ * TestWriteText tests that findings are written most severe first, followed by a summary.
+ */
+func TestWriteText(t *testing.T) {
+	report := &Report{Findings: []Finding{
+		{Address: "a.safe", Action: "create", Severity: SeveritySafe},
+		{Address: "b.destructive", Action: "delete", Severity: SeverityDestructive, Reasons: []string{"Deletes it"}},
+	}}
+
+	var output bytes.Buffer
+	require.NoError(t, report.WriteText(&output))
+
+	assert.Equal(t, "DESTRUCTIVE delete  b.destructive\n"+
+		"            Deletes it\n"+
+		"SAFE        create  a.safe\n"+
+		"\n"+
+		"1 destructive, 0 risky and 1 safe changes to backup resources\n", output.String())
+}
+
+/*
+ * TestWriteJSON tests that severities are written by name.
+ */
+func TestWriteJSON(t *testing.T) {
+	report := &Report{Findings: []Finding{{Address: "a.risky", Action: "update", Severity: SeverityRisky}}}
+
+	var output bytes.Buffer
+	require.NoError(t, report.WriteJSON(&output))
+	assert.Contains(t, output.String(), `"severity": "risky"`)
+}
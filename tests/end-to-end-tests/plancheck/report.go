@@ -0,0 +1,141 @@
+package plancheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+/*
+ * Severity is how much protection a change could lose, from least to most.
+ */
+type Severity int
+
+const (
+	SeveritySafe Severity = iota
+	SeverityRisky
+	SeverityDestructive
+)
+
+var severityNames = []string{"safe", "risky", "destructive"}
+
+func (severity Severity) String() string {
+	return severityNames[severity]
+}
+
+/*
+ * ParseSeverity parses the name of a severity, e.g. for a command line flag.
+ */
+func ParseSeverity(name string) (Severity, error) {
+	index := slices.Index(severityNames, name)
+	if index == -1 {
+		return SeveritySafe, fmt.Errorf("severity %q is not one of %s", name, strings.Join(severityNames, ", "))
+	}
+
+	return Severity(index), nil
+}
+
+func (severity Severity) MarshalText() ([]byte, error) {
+	return []byte(severity.String()), nil
+}
+
+func (severity *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+
+	*severity = parsed
+	return nil
+}
+
+/*
+ * Finding classifies a planned change to a resource, with the reasons for its severity.
+ */
+type Finding struct {
+	Address  string   `json:"address"`
+	Type     string   `json:"type"`
+	Action   string   `json:"action"`
+	Severity Severity `json:"severity"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+/*
+ * Adds a reason to the finding, raising its severity if the reason is more severe.
+ */
+func (finding *Finding) add(severity Severity, format string, args ...any) {
+	finding.Severity = max(finding.Severity, severity)
+	finding.Reasons = append(finding.Reasons, fmt.Sprintf(format, args...))
+}
+
+/*
+ * Report holds a finding for each change in the plan, in the order terraform planned them.
+ */
+type Report struct {
+	Findings []Finding `json:"findings"`
+}
+
+/*
+ * Count gets the number of findings with the provided severity.
+ */
+func (report *Report) Count(severity Severity) int {
+	count := 0
+	for _, finding := range report.Findings {
+		if finding.Severity == severity {
+			count++
+		}
+	}
+
+	return count
+}
+
+/*
+ * Highest gets the highest severity of any finding, which is safe when there are none.
+ */
+func (report *Report) Highest() Severity {
+	highest := SeveritySafe
+	for _, finding := range report.Findings {
+		highest = max(highest, finding.Severity)
+	}
+
+	return highest
+}
+
+/*
+ * WriteText writes the findings, most severe first, followed by a summary.
+ */
+func (report *Report) WriteText(w io.Writer) error {
+	var builder strings.Builder
+
+	findings := slices.Clone(report.Findings)
+	slices.SortStableFunc(findings, func(a, b Finding) int { return int(b.Severity) - int(a.Severity) })
+
+	for _, finding := range findings {
+		fmt.Fprintf(&builder, "%-11s %-7s %s\n", strings.ToUpper(finding.Severity.String()), finding.Action, finding.Address)
+		for _, reason := range finding.Reasons {
+			fmt.Fprintf(&builder, "            %s\n", reason)
+		}
+	}
+
+	if len(findings) > 0 {
+		builder.WriteString("\n")
+	}
+
+	fmt.Fprintf(&builder, "%d destructive, %d risky and %d safe changes to backup resources\n",
+		report.Count(SeverityDestructive), report.Count(SeverityRisky), report.Count(SeveritySafe))
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+/*
+ * WriteJSON writes the report as an indented JSON document.
+ */
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
@@ -2,14 +2,21 @@ package e2e_tests
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"e2e_tests/audit"
+	"e2e_tests/azure"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/postgresql/armpostgresqlflexibleservers"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
@@ -21,30 +28,38 @@ type TestPostgresqlFlexibleServerBackupExternalResources struct {
 	LogAnalyticsWorkspace       armoperationalinsights.Workspace
 	PostgresqlFlexibleServerOne armpostgresqlflexibleservers.Server
 	PostgresqlFlexibleServerTwo armpostgresqlflexibleservers.Server
+	RestoreStorageAccount       armstorage.Account
+	RestoreContainer            armstorage.BlobContainer
 }
 
 /*
  * Creates resources which are "external" to the az-backup module, and models
  * what would be backed up in a real scenario.
  */
-func setupExternalResourcesForPostgresqlFlexibleServerBackupTest(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestPostgresqlFlexibleServerBackupExternalResources {
+func setupExternalResourcesForPostgresqlFlexibleServerBackupTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestPostgresqlFlexibleServerBackupExternalResources {
 	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
-	resourceGroup := CreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
 
 	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
-	logAnalyticsWorkspace := CreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
 
 	PostgresqlFlexibleServerOneName := fmt.Sprintf("pgflexserver-%s-external-1", strings.ToLower(uniqueId))
-	PostgresqlFlexibleServerOne := CreatePostgresqlFlexibleServer(t, credential, subscriptionID, externalResourceGroupName, PostgresqlFlexibleServerOneName, resourceGroupLocation, int32(32))
+	PostgresqlFlexibleServerOne := MustCreatePostgresqlFlexibleServer(t, credential, subscriptionID, externalResourceGroupName, PostgresqlFlexibleServerOneName, resourceGroupLocation, int32(32))
 
 	PostgresqlFlexibleServerTwoName := fmt.Sprintf("pgflexserver-%s-external-2", strings.ToLower(uniqueId))
-	PostgresqlFlexibleServerTwo := CreatePostgresqlFlexibleServer(t, credential, subscriptionID, externalResourceGroupName, PostgresqlFlexibleServerTwoName, resourceGroupLocation, int32(32))
+	PostgresqlFlexibleServerTwo := MustCreatePostgresqlFlexibleServer(t, credential, subscriptionID, externalResourceGroupName, PostgresqlFlexibleServerTwoName, resourceGroupLocation, int32(32))
+
+	restoreStorageAccountName := fmt.Sprintf("sa%srestore", strings.ToLower(uniqueId))
+	restoreStorageAccount := MustCreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, restoreStorageAccountName, resourceGroupLocation, nil)
+	restoreContainer := MustCreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, restoreStorageAccountName, "pgflex-restore")
 
 	externalResources := &TestPostgresqlFlexibleServerBackupExternalResources{
 		ResourceGroup:               resourceGroup,
 		LogAnalyticsWorkspace:       logAnalyticsWorkspace,
 		PostgresqlFlexibleServerOne: PostgresqlFlexibleServerOne,
 		PostgresqlFlexibleServerTwo: PostgresqlFlexibleServerTwo,
+		RestoreStorageAccount:       restoreStorageAccount,
+		RestoreContainer:            restoreContainer,
 	}
 
 	return externalResources
@@ -52,12 +67,15 @@ func setupExternalResourcesForPostgresqlFlexibleServerBackupTest(t *testing.T, c
 
 /*
  * TestPostgresqlFlexibleServerBackup tests the deployment of a backup vault and backup policies for postgresql flexible servers.
+ *
+ * The optional restore stage takes an ad-hoc backup of a server and restores it as files to a
+ * storage account container. It can be skipped by setting SKIP_restore=true.
  */
 func TestPostgresqlFlexibleServerBackup(t *testing.T) {
 	t.Parallel()
 
-	environment := GetEnvironmentConfiguration(t)
-	credential := GetAzureCredential(t, environment)
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
 
 	uniqueId := random.UniqueId()
 	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
@@ -82,6 +100,10 @@ func TestPostgresqlFlexibleServerBackup(t *testing.T) {
 			"backup_intervals":         []string{"R/2024-01-01T00:00:00+00:00/P1W"},
 			"server_id":                *externalResources.PostgresqlFlexibleServerTwo.ID,
 			"server_resource_group_id": *externalResources.ResourceGroup.ID,
+			"retention_rules": []map[string]interface{}{
+				{"name": "Yearly", "duration": "P10Y", "absolute_criteria": "FirstOfYear"},
+				{"name": "Monthly", "duration": "P12M", "absolute_criteria": "FirstOfMonth"},
+			},
 		},
 	}
 
@@ -91,9 +113,9 @@ func TestPostgresqlFlexibleServerBackup(t *testing.T) {
 	defer test_structure.RunTestStage(t, "teardown", func() {
 		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
 
-		terraform.Destroy(t, terraformOptions)
+		DestroyTerraform(t, environment, terraformOptions)
 
-		DeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
 	})
 
 	// Setup stage
@@ -108,6 +130,7 @@ func TestPostgresqlFlexibleServerBackup(t *testing.T) {
 				"resource_group_location":            resourceGroupLocation,
 				"backup_vault_name":                  backupVaultName,
 				"log_analytics_workspace_id":         *externalResources.LogAnalyticsWorkspace.ID,
+				"use_extended_retention":             true,
 				"postgresql_flexible_server_backups": PostgresqlFlexibleServerBackups,
 			},
 
@@ -122,60 +145,118 @@ func TestPostgresqlFlexibleServerBackup(t *testing.T) {
 		// Save options for later test stages
 		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
 
-		terraform.InitAndApply(t, terraformOptions)
+		ApplyTerraform(t, environment, terraformOptions)
 	})
 
 	// Validate stage
 	// ...
 
 	test_structure.RunTestStage(t, "validate", func() {
-		backupVault := GetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
-		backupPolicies := GetBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
-		backupInstances := GetBackupInstances(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupPolicies := MustGetBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		backupInstances := MustGetBackupInstances(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
 
 		assert.Equal(t, len(PostgresqlFlexibleServerBackups), len(backupPolicies), "Expected to find %2 backup policies in vault", len(PostgresqlFlexibleServerBackups))
 		assert.Equal(t, len(PostgresqlFlexibleServerBackups), len(backupInstances), "Expected to find %2 backup instances in vault", len(PostgresqlFlexibleServerBackups))
 
 		for _, backup := range PostgresqlFlexibleServerBackups {
-			backupName := backup["backup_name"].(string)
 			retentionPeriod := backup["retention_period"].(string)
 			backupIntervals := backup["backup_intervals"].([]string)
 			ServerId := backup["server_id"].(string)
 			ServerResourceGroupId := backup["server_resource_group_id"].(string)
 
 			// Validate backup policy
-			backupPolicyName := fmt.Sprintf("bkpol-pgflex-%s", backupName)
-			backupPolicy := GetBackupPolicyForName(backupPolicies, backupPolicyName)
+			backupNames := MustGetBackupNames(t, naming.ResourceTypePostgresqlFlexibleServer, backup)
+			backupPolicyName := backupNames.PolicyName()
+			backupPolicy := azure.GetBackupPolicyForName(backupPolicies, backupPolicyName)
 			assert.NotNil(t, backupPolicy, "Expected to find a backup policy called %s", backupPolicyName)
 
 			// Validate retention period
 			backupPolicyProperties := backupPolicy.Properties.(*armdataprotection.BackupPolicy)
-			retentionRule := GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "Default").(*armdataprotection.AzureRetentionRule)
+			retentionRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "Default").(*armdataprotection.AzureRetentionRule)
 			deleteOption := retentionRule.Lifecycles[0].DeleteAfter.(*armdataprotection.AbsoluteDeleteOption)
 			assert.Equal(t, retentionPeriod, *deleteOption.Duration, "Expected the backup policy retention period to be %s", retentionPeriod)
 
+			// Validate retention tiers
+			if retentionRules, ok := backup["retention_rules"].([]map[string]interface{}); ok {
+				AssertRetentionTiersEqual(t, retentionRules, backupPolicyProperties, armdataprotection.DataStoreTypesVaultStore)
+			}
+
 			// Validate backup intervals
-			backupRule := GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "BackupIntervals").(*armdataprotection.AzureBackupRule)
+			backupRule := azure.GetBackupPolicyRuleForName(backupPolicyProperties.PolicyRules, "BackupIntervals").(*armdataprotection.AzureBackupRule)
 			schedule := backupRule.Trigger.(*armdataprotection.ScheduleBasedTriggerContext).Schedule
-			for index, interval := range schedule.RepeatingTimeIntervals {
-				assert.Equal(t, backupIntervals[index], *interval, "Expected backup policy repeating interval %s to be %s", index, backupIntervals[index])
-			}
+			AssertBackupIntervalsEqual(t, backupIntervals, schedule.RepeatingTimeIntervals)
 
 			// Validate backup instance
-			backupInstanceName := fmt.Sprintf("bkinst-pgflex-%s", backupName)
-			backupInstance := GetBackupInstanceForName(backupInstances, backupInstanceName)
+			backupInstanceName := backupNames.InstanceName()
+			backupInstance := azure.GetBackupInstanceForName(backupInstances, backupInstanceName)
 			assert.NotNil(t, backupInstance, "Expected to find a backup policy called %s", backupInstanceName)
 			assert.Equal(t, ServerId, *backupInstance.Properties.DataSourceInfo.ResourceID, "Expected the backup instance source resource ID to be %s", ServerId)
 			assert.Equal(t, *backupPolicy.ID, *backupInstance.Properties.PolicyInfo.PolicyID, "Expected the backup instance policy ID to be %s", backupPolicy.ID)
 
 			// Validate role assignments
-			readerRoleDefinition := GetRoleDefinition(t, credential, "Reader")
-			readerRoleAssignment := GetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, readerRoleDefinition, ServerResourceGroupId)
+			readerRoleDefinition := MustGetRoleDefinition(t, credential, "Reader")
+			readerRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, readerRoleDefinition, ServerResourceGroupId)
 			assert.NotNil(t, readerRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", readerRoleDefinition.Name, *backupVault.Identity.PrincipalID, ServerResourceGroupId)
 
-			longTermRetentionBackupRoleDefinition := GetRoleDefinition(t, credential, "PostgreSQL Flexible Server Long Term Retention Backup Role")
-			longTermRetentionBackupRoleAssignment := GetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, longTermRetentionBackupRoleDefinition, ServerId)
+			longTermRetentionBackupRoleDefinition := MustGetRoleDefinition(t, credential, "PostgreSQL Flexible Server Long Term Retention Backup Role")
+			longTermRetentionBackupRoleAssignment := MustGetRoleAssignment(t, credential, environment.SubscriptionID, *backupVault.Identity.PrincipalID, longTermRetentionBackupRoleDefinition, ServerId)
 			assert.NotNil(t, longTermRetentionBackupRoleAssignment, "Expected to find role assignment %s for principal %s on scope %s", longTermRetentionBackupRoleDefinition.Name, *backupVault.Identity.PrincipalID, ServerId)
 		}
+
+		// Validate the backup vault against the rules that the module promises
+		auditOptions := audit.DefaultOptions()
+		auditOptions.ExtendedRetention = true
+		AssertVaultCompliance(t, credential, *backupVault.ID, auditOptions)
+	})
+
+	// Restore stage
+	// ...
+
+	test_structure.RunTestStage(t, "restore", func() {
+		backupName := PostgresqlFlexibleServerBackups["backup1"]["backup_name"].(string)
+		backupInstanceName := MustGetBackupNames(t, naming.ResourceTypePostgresqlFlexibleServer, PostgresqlFlexibleServerBackups["backup1"]).InstanceName()
+		MustBeginAdHocBackup(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+
+		recoveryPoints := MustGetRecoveryPoints(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+		assert.NotEmpty(t, recoveryPoints, "Expected the ad-hoc backup to create a recovery point")
+
+		if len(recoveryPoints) == 0 {
+			return
+		}
+
+		// The vault identity needs to be able to write the dump files to the target container
+		backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+		MustCreateRoleAssignment(t, credential, environment.SubscriptionID, *externalResources.RestoreStorageAccount.ID,
+			"Storage Blob Data Contributor", *backupVault.Identity.PrincipalID)
+		MustWaitForRoleAssignment(t, credential, environment.SubscriptionID, *externalResources.RestoreStorageAccount.ID,
+			"Storage Blob Data Contributor", *backupVault.Identity.PrincipalID)
+
+		filePrefix := fmt.Sprintf("%s-%s", backupName, strings.ToLower(uniqueId))
+		restoreJobID := MustBeginPostgresqlFlexibleServerRestoreAsFiles(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName,
+			*recoveryPoints[0].Name, externalResources.RestoreStorageAccount, externalResources.RestoreContainer, filePrefix)
+
+		MustWaitForBackupJob(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, restoreJobID)
+
+		// Validate the dump files have been restored
+		restoredBlobs := MustListBlobsInStorageAccountContainer(t, credential, *externalResources.RestoreStorageAccount.Name, *externalResources.RestoreContainer.Name, filePrefix)
+		assert.NotEmpty(t, restoredBlobs, "Expected the restore to create files with the prefix %s", filePrefix)
+
+		for _, restoredBlob := range restoredBlobs {
+			assert.Greater(t, *restoredBlob.Properties.ContentLength, int64(0), "Expected the restored file %s to be non-empty", *restoredBlob.Name)
+
+			// The emulator doesn't produce real pg_dump archives, so they can only be checked against Azure
+			if environment.UseEmulator {
+				continue
+			}
+
+			content := MustDownloadFileFromStorageAccount(t, credential, *externalResources.RestoreStorageAccount.Name, *externalResources.RestoreContainer.Name, *restoredBlob.Name)
+
+			archivePath := filepath.Join(t.TempDir(), filepath.Base(*restoredBlob.Name))
+			err := os.WriteFile(archivePath, content, 0600)
+			assert.NoError(t, err, "Failed to write restored file: %v", err)
+
+			MustVerifyPostgresqlDumpArchive(t, archivePath)
+		}
 	})
 }
@@ -0,0 +1,38 @@
+package rpo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+/*
+ * WriteText writes a line per backup instance with its status, followed by a summary.
+ */
+func (report *Report) WriteText(w io.Writer) error {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "RPO check of %s/%s at %s\n\n", report.ResourceGroupName, report.BackupVaultName, report.CheckedAt.UTC().Format(time.RFC3339))
+
+	for _, instance := range report.Instances {
+		fmt.Fprintf(&builder, "%-8s  %-40s %s\n", strings.ToUpper(string(instance.Status)), instance.BackupInstance, instance.Message)
+	}
+
+	fmt.Fprintf(&builder, "\n%d healthy, %d warning, %d breached, %d unknown\n",
+		report.Count(StatusHealthy), report.Count(StatusWarning), report.Count(StatusBreached), report.Count(StatusUnknown))
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+/*
+ * WriteJSON writes the report as indented JSON.
+ */
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
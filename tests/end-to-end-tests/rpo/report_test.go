@@ -0,0 +1,42 @@
+package rpo
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() *Report {
+	newest := time.Date(2024, time.March, 10, 6, 0, 0, 0, time.UTC)
+
+	return &Report{
+		ResourceGroupName: "rg-rpo",
+		BackupVaultName:   "bvault-rpo",
+		CheckedAt:         time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC),
+		Instances: []InstanceStatus{
+			{BackupInstance: "bkinst-blob-blob1", BackupPolicy: "bkpol-blob-blob1", RPO: "P1D", NewestRecoveryPoint: &newest, AgeSeconds: 21600,
+				Status: StatusHealthy, Message: "the newest recovery point is 6h0m old, within the RPO of P1D"},
+			{BackupInstance: "bkinst-disk-disk1", BackupPolicy: "bkpol-disk-disk1", RPO: "PT4H", NewestRecoveryPoint: &newest, AgeSeconds: 21600,
+				Status: StatusBreached, Message: "the newest recovery point is 6h0m old, beyond the RPO of PT4H"},
+		},
+	}
+}
+
+/*
+ * TestWriteText tests that the report is written as a line per instance, followed by a summary.
+ */
+func TestWriteText(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, testReport().WriteText(&output))
+
+	assert.Equal(t, `RPO check of rg-rpo/bvault-rpo at 2024-03-10T12:00:00Z
+
+HEALTHY   bkinst-blob-blob1                        the newest recovery point is 6h0m old, within the RPO of P1D
+BREACHED  bkinst-disk-disk1                        the newest recovery point is 6h0m old, beyond the RPO of PT4H
+
+1 healthy, 0 warning, 1 breached, 0 unknown
+`, output.String())
+}
@@ -0,0 +1,345 @@
+/*
+ * Package rpo checks that the backup instances in a vault have recovery points at the cadence
+ * that their backup policies promise. The recovery point objective (RPO) of an instance is the
+ * longest gap between consecutive runs of its policy's backup intervals, and the instance
+ * breaches it when its newest recovery point is older than that gap, plus a grace period for
+ * backups to complete.
+ */
+package rpo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"e2e_tests/azure"
+	"e2e_tests/interval"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+)
+
+/*
+ * Status is how fresh the newest recovery point of a backup instance is against its RPO.
+ */
+type Status string
+
+const (
+	StatusHealthy  Status = "healthy"
+	StatusWarning  Status = "warning"
+	StatusBreached Status = "breached"
+	// The RPO of the instance couldn't be worked out, e.g. as its policy has no backup intervals
+	StatusUnknown Status = "unknown"
+)
+
+/*
+ * Thresholds control when an instance is flagged.
+ */
+type Thresholds struct {
+	// Warning is the fraction of the RPO that the age of the newest recovery point can reach
+	// before the instance is flagged as a warning, or zero to never warn
+	Warning float64
+	// Grace is allowed on top of the RPO before it's breached, as backups take time to complete
+	Grace time.Duration
+}
+
+/*
+ * DefaultThresholds returns thresholds which warn at 80% of the RPO, and allow an hour for
+ * backups to complete.
+ */
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		Warning: 0.8,
+		Grace:   time.Hour,
+	}
+}
+
+/*
+ * Validate checks that the thresholds are within range.
+ */
+func (thresholds Thresholds) Validate() error {
+	if thresholds.Warning < 0 || thresholds.Warning > 1 {
+		return fmt.Errorf("the warning threshold must be between 0 and 1, got %g", thresholds.Warning)
+	}
+
+	if thresholds.Grace < 0 {
+		return fmt.Errorf("the grace period must not be negative, got %s", thresholds.Grace)
+	}
+
+	return nil
+}
+
+/*
+ * InstanceStatus is the freshness of the newest recovery point of a backup instance.
+ */
+type InstanceStatus struct {
+	BackupInstance      string     `json:"backupInstance"`
+	BackupInstanceID    string     `json:"backupInstanceId"`
+	BackupPolicy        string     `json:"backupPolicy,omitempty"`
+	RPO                 string     `json:"rpo,omitempty"`
+	NewestRecoveryPoint *time.Time `json:"newestRecoveryPoint"`
+	AgeSeconds          float64    `json:"ageSeconds,omitempty"`
+	Status              Status     `json:"status"`
+	Message             string     `json:"message"`
+}
+
+/*
+ * Report holds the status of every backup instance in a vault.
+ */
+type Report struct {
+	ResourceGroupName string           `json:"resourceGroupName"`
+	BackupVaultName   string           `json:"backupVaultName"`
+	CheckedAt         time.Time        `json:"checkedAt"`
+	Instances         []InstanceStatus `json:"instances"`
+}
+
+/*
+ * Count returns the number of instances with the provided status.
+ */
+func (report *Report) Count(status Status) int {
+	count := 0
+	for _, instance := range report.Instances {
+		if instance.Status == status {
+			count++
+		}
+	}
+
+	return count
+}
+
+/*
+ * Breached reports whether any instance has breached its RPO.
+ */
+func (report *Report) Breached() bool {
+	return report.Count(StatusBreached) > 0
+}
+
+/*
+ * Check lists the backup instances and backup policies of a vault along with the recovery
+ * points of each instance, and checks the newest recovery point of each against its RPO.
+ */
+func Check(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string, thresholds Thresholds) (*Report, error) {
+	instances, err := azure.GetBackupInstances(ctx, credential, clientOptions, subscriptionID, resourceGroupName, backupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := azure.GetBackupPolicies(ctx, credential, clientOptions, subscriptionID, resourceGroupName, backupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		ResourceGroupName: resourceGroupName,
+		BackupVaultName:   backupVaultName,
+		CheckedAt:         time.Now().UTC(),
+	}
+
+	for _, instance := range instances {
+		recoveryPoints, err := azure.GetRecoveryPoints(ctx, credential, clientOptions, subscriptionID, resourceGroupName, backupVaultName, valueOf(instance.Name))
+		if err != nil {
+			return nil, err
+		}
+
+		var policy *armdataprotection.BaseBackupPolicyResource
+		if instance.Properties != nil && instance.Properties.PolicyInfo != nil {
+			policy = getPolicyForID(policies, valueOf(instance.Properties.PolicyInfo.PolicyID))
+		}
+
+		report.Instances = append(report.Instances, Evaluate(instance, policy, newestRecoveryPointTime(recoveryPoints), thresholds, report.CheckedAt))
+	}
+
+	slices.SortStableFunc(report.Instances, func(a, b InstanceStatus) int { return strings.Compare(a.BackupInstance, b.BackupInstance) })
+
+	return report, nil
+}
+
+/*
+ * Evaluate checks the time of the newest recovery point of a backup instance, if it has one,
+ * against the RPO of its backup policy at the provided time. When the policy has more than one
+ * backup interval, the RPO is the longest gap between consecutive runs of any of them, so
+ * weekly backups on Mondays and Thursdays have an RPO of four days.
+ */
+func Evaluate(instance *armdataprotection.BackupInstanceResource, policy *armdataprotection.BaseBackupPolicyResource, newest *time.Time, thresholds Thresholds, now time.Time) InstanceStatus {
+	status := InstanceStatus{
+		BackupInstance:      valueOf(instance.Name),
+		BackupInstanceID:    valueOf(instance.ID),
+		NewestRecoveryPoint: newest,
+	}
+
+	if policy == nil {
+		status.Status = StatusUnknown
+		status.Message = "the backup policy of the instance wasn't found"
+		return status
+	}
+	status.BackupPolicy = valueOf(policy.Name)
+
+	intervals, location := backupIntervals(policy)
+	if len(intervals) == 0 {
+		status.Status = StatusUnknown
+		status.Message = "the backup policy has no valid backup intervals"
+		return status
+	}
+
+	// Periods such as P1M vary in length, so the gaps are measured around the newest recovery point
+	reference := now
+	if newest != nil {
+		reference = *newest
+	}
+
+	rpo, rpoPeriod := longestGap(intervals, reference, location)
+	status.RPO = rpoPeriod.String()
+
+	if newest == nil {
+		status.Status = StatusBreached
+		status.Message = "the instance has no recovery points"
+		return status
+	}
+
+	age := now.Sub(*newest)
+	status.AgeSeconds = age.Seconds()
+
+	switch {
+	case age > rpo+thresholds.Grace:
+		status.Status = StatusBreached
+		status.Message = fmt.Sprintf("the newest recovery point is %s old, beyond the RPO of %s", formatAge(age), status.RPO)
+	case thresholds.Warning > 0 && age > time.Duration(thresholds.Warning*float64(rpo)):
+		status.Status = StatusWarning
+		status.Message = fmt.Sprintf("the newest recovery point is %s old, %.0f%% of the RPO of %s", formatAge(age), 100*age.Seconds()/rpo.Seconds(), status.RPO)
+	default:
+		status.Status = StatusHealthy
+		status.Message = fmt.Sprintf("the newest recovery point is %s old, within the RPO of %s", formatAge(age), status.RPO)
+	}
+
+	return status
+}
+
+/*
+ * Gets the policy's backup intervals, skipping any that can't be parsed, and the time zone
+ * that they're scheduled in.
+ */
+func backupIntervals(policy *armdataprotection.BaseBackupPolicyResource) ([]interval.RepeatingInterval, *time.Location) {
+	backupPolicy, ok := policy.Properties.(*armdataprotection.BackupPolicy)
+	if !ok {
+		return nil, nil
+	}
+
+	schedule := azure.GetBackupPolicySchedule(backupPolicy)
+	if schedule == nil {
+		return nil, nil
+	}
+
+	var intervals []interval.RepeatingInterval
+	for _, value := range schedule.RepeatingTimeIntervals {
+		if repeatingInterval, err := interval.Parse(valueOf(value)); err == nil {
+			intervals = append(intervals, repeatingInterval)
+		}
+	}
+
+	return intervals, interval.Location(valueOf(schedule.TimeZone))
+}
+
+/*
+ * Gets the longest gap between consecutive runs of the intervals, looking at the runs within
+ * two of the longest periods either side of the reference time, which covers every gap in the
+ * schedule. The gap is described by the period of an interval when it's the same length, so a
+ * daily schedule has an RPO of P1D rather than PT24H. When the intervals run fewer than twice
+ * in that time, the longest period is taken as the gap.
+ */
+func longestGap(intervals []interval.RepeatingInterval, reference time.Time, location *time.Location) (time.Duration, interval.Duration) {
+	longest := intervals[0].Period
+	for _, repeatingInterval := range intervals[1:] {
+		if repeatingInterval.Period.Approximate() > longest.Approximate() {
+			longest = repeatingInterval.Period
+		}
+	}
+
+	runs := interval.MergedRunsBetween(intervals, longest.AddTo(reference, -2), longest.AddTo(reference, 2), location)
+	if len(runs) < 2 {
+		return longest.AddTo(reference, 1).Sub(reference), longest
+	}
+
+	var gap time.Duration
+	var gapStart time.Time
+	for i := 1; i < len(runs); i++ {
+		if length := runs[i].Sub(runs[i-1]); length > gap {
+			gap = length
+			gapStart = runs[i-1]
+		}
+	}
+
+	for _, repeatingInterval := range intervals {
+		if repeatingInterval.Period.AddTo(gapStart, 1).Sub(gapStart) == gap {
+			return gap, repeatingInterval.Period
+		}
+	}
+
+	return gap, interval.FromDuration(gap)
+}
+
+/*
+ * Gets the time of the newest recovery point from a list ordered from newest to oldest, as
+ * returned by azure.GetRecoveryPoints.
+ */
+func newestRecoveryPointTime(recoveryPoints []*armdataprotection.AzureBackupRecoveryPointResource) *time.Time {
+	for _, recoveryPoint := range recoveryPoints {
+		if discrete, ok := recoveryPoint.Properties.(*armdataprotection.AzureBackupDiscreteRecoveryPoint); ok && discrete.RecoveryPointTime != nil {
+			return discrete.RecoveryPointTime
+		}
+	}
+
+	return nil
+}
+
+func getPolicyForID(policies []*armdataprotection.BaseBackupPolicyResource, policyID string) *armdataprotection.BaseBackupPolicyResource {
+	for _, policy := range policies {
+		if strings.EqualFold(valueOf(policy.ID), policyID) {
+			return policy
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Formats an age to the minute, as recovery point times don't need to be any more precise.
+ */
+func formatAge(age time.Duration) string {
+	age = age.Round(time.Minute)
+	if age < time.Minute {
+		return "0m"
+	}
+
+	return strings.TrimSuffix(age.String(), "0s")
+}
+
+func valueOf(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
+
+/*
+ * Watch runs the check straight away and then at every interval until the context is done,
+ * passing each report, or the error from the check, to the handler. It returns the context's
+ * error once it's done.
+ */
+func Watch(ctx context.Context, every time.Duration, check func(ctx context.Context) (*Report, error), handle func(report *Report, err error)) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		handle(check(ctx))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package rpo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"e2e_tests/azure"
+	"e2e_tests/emulator"
+	"e2e_tests/emulator/emulatortest"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testResourceGroupName = "rg-rpo"
+	testBackupVaultName   = "bvault-rpo"
+)
+
+func testVariables() map[string]interface{} {
+	variables := emulatortest.Variables(testResourceGroupName, testBackupVaultName)
+	variables["blob_storage_backups"] = map[string]map[string]interface{}{
+		"backup1": emulatortest.BlobStorageBackup("blob1", emulatortest.ResourceID("rg-data", "Microsoft.Storage/storageAccounts", "sarpo")),
+	}
+	variables["managed_disk_backups"] = map[string]map[string]interface{}{
+		"backup1": emulatortest.ManagedDiskBackup("disk1", emulatortest.ResourceID("rg-data", "Microsoft.Compute/disks", "disk-rpo")),
+		"backup2": emulatortest.ManagedDiskBackup("disk2", emulatortest.ResourceID("rg-data", "Microsoft.Compute/disks", "disk-rpo2")),
+	}
+
+	return variables
+}
+
+/*
+ * TestCheck tests that an instance with a fresh recovery point is healthy, and that instances
+ * with a stale recovery point or none at all have breached their RPO.
+ */
+func TestCheck(t *testing.T) {
+	credential, err := emulatortest.Shared().Credential()
+	require.NoError(t, err)
+
+	require.NoError(t, emulatortest.Shared().Apply(emulator.SubscriptionID, testVariables()))
+	defer func() { require.NoError(t, emulatortest.Shared().Destroy(emulator.SubscriptionID, testVariables())) }()
+
+	_, err = azure.BeginAdHocBackup(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, testResourceGroupName, testBackupVaultName, "bkinst-blob-blob1")
+	require.NoError(t, err)
+
+	// The newest recovery point of disk1 is older than its RPO of 4 hours plus the grace period
+	vaultID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataProtection/backupVaults/%s", emulator.SubscriptionID, testResourceGroupName, testBackupVaultName)
+	for _, age := range []time.Duration{6 * time.Hour, 30 * time.Hour} {
+		emulatortest.Shared().Put(fmt.Sprintf("%s/backupInstances/bkinst-disk-disk1/recoveryPoints/rp-%d", vaultID, int(age.Hours())), map[string]any{
+			"properties": map[string]any{
+				"objectType":        "AzureBackupDiscreteRecoveryPoint",
+				"recoveryPointTime": time.Now().Add(-age).UTC().Format(time.RFC3339),
+			},
+		})
+	}
+
+	report, err := Check(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, testResourceGroupName, testBackupVaultName, DefaultThresholds())
+	require.NoError(t, err)
+
+	require.Len(t, report.Instances, 3)
+	assert.True(t, report.Breached())
+	assert.Equal(t, 1, report.Count(StatusHealthy))
+	assert.Equal(t, 2, report.Count(StatusBreached))
+
+	blob := report.Instances[0]
+	assert.Equal(t, "bkinst-blob-blob1", blob.BackupInstance)
+	assert.Equal(t, "bkpol-blob-blob1", blob.BackupPolicy)
+	assert.Equal(t, "P1D", blob.RPO)
+	assert.Equal(t, StatusHealthy, blob.Status)
+	assert.NotNil(t, blob.NewestRecoveryPoint)
+
+	disk1 := report.Instances[1]
+	assert.Equal(t, "bkinst-disk-disk1", disk1.BackupInstance)
+	assert.Equal(t, "PT4H", disk1.RPO)
+	assert.Equal(t, StatusBreached, disk1.Status)
+	assert.InDelta(t, (6 * time.Hour).Seconds(), disk1.AgeSeconds, 60)
+	assert.Equal(t, "the newest recovery point is 6h0m old, beyond the RPO of PT4H", disk1.Message)
+
+	disk2 := report.Instances[2]
+	assert.Equal(t, "bkinst-disk-disk2", disk2.BackupInstance)
+	assert.Equal(t, StatusBreached, disk2.Status)
+	assert.Nil(t, disk2.NewestRecoveryPoint)
+	assert.Equal(t, "the instance has no recovery points", disk2.Message)
+}
+
+func testPolicy(intervals ...string) *armdataprotection.BaseBackupPolicyResource {
+	var repeatingTimeIntervals []*string
+	for _, value := range intervals {
+		repeatingTimeIntervals = append(repeatingTimeIntervals, to.Ptr(value))
+	}
+
+	return &armdataprotection.BaseBackupPolicyResource{
+		Name: to.Ptr("bkpol-test"),
+		Properties: &armdataprotection.BackupPolicy{
+			PolicyRules: []armdataprotection.BasePolicyRuleClassification{
+				&armdataprotection.AzureBackupRule{
+					Name: to.Ptr("BackupIntervals"),
+					Trigger: &armdataprotection.ScheduleBasedTriggerContext{
+						Schedule: &armdataprotection.BackupSchedule{RepeatingTimeIntervals: repeatingTimeIntervals},
+					},
+				},
+			},
+		},
+	}
+}
+
+/*
+ * TestEvaluate tests the status of an instance against the thresholds, taking the RPO from the
+ * longest gap between the runs of its backup intervals.
+ */
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	instance := &armdataprotection.BackupInstanceResource{Name: to.Ptr("bkinst-test")}
+	daily := testPolicy("R/2024-01-01T00:00:00+00:00/P1D")
+
+	tests := []struct {
+		name       string
+		policy     *armdataprotection.BaseBackupPolicyResource
+		age        time.Duration
+		thresholds Thresholds
+		rpo        string
+		status     Status
+	}{
+		{"fresh", daily, 2 * time.Hour, DefaultThresholds(), "P1D", StatusHealthy},
+		{"approaching RPO", daily, 20 * time.Hour, DefaultThresholds(), "P1D", StatusWarning},
+		{"within grace period", daily, 24*time.Hour + 30*time.Minute, DefaultThresholds(), "P1D", StatusWarning},
+		{"beyond grace period", daily, 25*time.Hour + time.Minute, DefaultThresholds(), "P1D", StatusBreached},
+		{"warnings disabled", daily, 20 * time.Hour, Thresholds{Grace: time.Hour}, "P1D", StatusHealthy},
+		{"no grace period", daily, 24*time.Hour + time.Minute, Thresholds{}, "P1D", StatusBreached},
+		{"overlapping intervals", testPolicy("R/2024-01-01T00:00:00+00:00/P1W", "R/2024-01-01T00:00:00+00:00/PT12H"), 14 * time.Hour, DefaultThresholds(), "PT12H", StatusBreached},
+		{"interleaved intervals", testPolicy("R/2024-01-01T00:00:00+00:00/P1W", "R/2024-01-04T00:00:00+00:00/P1W"), 72 * time.Hour, DefaultThresholds(), "P4D", StatusHealthy},
+		{"beyond interleaved intervals", testPolicy("R/2024-01-01T00:00:00+00:00/P1W", "R/2024-01-04T00:00:00+00:00/P1W"), 98 * time.Hour, DefaultThresholds(), "P4D", StatusBreached},
+		{"invalid intervals", testPolicy("R/not-a-time/P1D"), time.Hour, DefaultThresholds(), "", StatusUnknown},
+		{"no policy", nil, time.Hour, DefaultThresholds(), "", StatusUnknown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newest := now.Add(-test.age)
+			status := Evaluate(instance, test.policy, &newest, test.thresholds, now)
+
+			assert.Equal(t, test.status, status.Status)
+			assert.Equal(t, test.rpo, status.RPO)
+			assert.NotEmpty(t, status.Message)
+		})
+	}
+}
+
+/*
+ * TestThresholdsValidate tests that thresholds out of range are rejected.
+ */
+func TestThresholdsValidate(t *testing.T) {
+	assert.NoError(t, DefaultThresholds().Validate())
+	assert.NoError(t, Thresholds{}.Validate())
+	assert.EqualError(t, Thresholds{Warning: 1.5}.Validate(), "the warning threshold must be between 0 and 1, got 1.5")
+	assert.EqualError(t, Thresholds{Grace: -time.Minute}.Validate(), "the grace period must not be negative, got -1m0s")
+}
+
+/*
+ * TestWatch tests that the check is run repeatedly until the context is done, with errors
+ * passed to the handler rather than stopping the loop.
+ */
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	checks := 0
+	var results []error
+
+	err := Watch(ctx, time.Millisecond, func(ctx context.Context) (*Report, error) {
+		checks++
+		if checks == 2 {
+			return nil, errors.New("check failed")
+		}
+		return &Report{}, nil
+	}, func(report *Report, err error) {
+		results = append(results, err)
+		if len(results) == 3 {
+			cancel()
+		}
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 3, checks)
+	assert.Equal(t, []error{nil, errors.New("check failed"), nil}, results)
+}
@@ -0,0 +1,154 @@
+package sla
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * The formats that a report can be written in.
+ */
+type Format string
+
+const (
+	FormatCSV      Format = "csv"
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "markdown"
+)
+
+/*
+ * Formats returns the formats that a report can be written in.
+ */
+func Formats() []Format {
+	return []Format{FormatCSV, FormatJSON, FormatMarkdown}
+}
+
+/*
+ * Write writes the report in the provided format.
+ */
+func (report *Report) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatCSV:
+		return report.WriteCSV(w)
+	case FormatJSON:
+		return report.WriteJSON(w)
+	case FormatMarkdown:
+		return report.WriteMarkdown(w)
+	default:
+		return fmt.Errorf("format %q is not supported", format)
+	}
+}
+
+var columns = []string{
+	"Backup Instance",
+	"Backup Policy",
+	"Jobs",
+	"Succeeded",
+	"Failed",
+	"Success Rate",
+	"Last Successful Backup",
+	"Average Duration",
+	"Scheduled Runs",
+	"Missed Schedules",
+}
+
+func (summary InstanceSummary) row() []string {
+	successRate := ""
+	if summary.SuccessRate != nil {
+		successRate = strconv.FormatFloat(*summary.SuccessRate*100, 'f', 1, 64) + "%"
+	}
+
+	lastSuccessfulBackup := ""
+	if summary.LastSuccessfulBackup != nil {
+		lastSuccessfulBackup = summary.LastSuccessfulBackup.UTC().Format(time.RFC3339)
+	}
+
+	averageDuration := ""
+	if summary.AverageDuration != 0 {
+		averageDuration = summary.AverageDuration.String()
+	}
+
+	return []string{
+		summary.BackupInstance,
+		summary.BackupPolicy,
+		strconv.Itoa(summary.Jobs),
+		strconv.Itoa(summary.Succeeded),
+		strconv.Itoa(summary.Failed),
+		successRate,
+		lastSuccessfulBackup,
+		averageDuration,
+		strconv.Itoa(summary.ScheduledRuns),
+		strconv.Itoa(summary.MissedSchedules),
+	}
+}
+
+/*
+ * WriteCSV writes the report as CSV, with a header row and a row per backup instance.
+ */
+func (report *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	for _, summary := range report.Instances {
+		if err := writer.Write(summary.row()); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+/*
+ * WriteJSON writes the report as indented JSON.
+ */
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
+
+/*
+ * WriteMarkdown writes the report as a heading and a table with a row per backup instance,
+ * for pasting into a wiki page or pull request.
+ */
+func (report *Report) WriteMarkdown(w io.Writer) error {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "# Backup SLA report for %s/%s\n\n", report.ResourceGroupName, report.BackupVaultName)
+	fmt.Fprintf(&builder, "%s to %s\n\n", report.From.UTC().Format(time.RFC3339), report.To.UTC().Format(time.RFC3339))
+
+	if len(report.Instances) == 0 {
+		builder.WriteString("No backup instances or backup jobs were found.\n")
+	} else {
+		writeMarkdownRow(&builder, columns)
+		builder.WriteString("|" + strings.Repeat(" --- |", len(columns)) + "\n")
+
+		for _, summary := range report.Instances {
+			writeMarkdownRow(&builder, summary.row())
+		}
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+func writeMarkdownRow(builder *strings.Builder, cells []string) {
+	builder.WriteString("|")
+	for _, cell := range cells {
+		if cell == "" {
+			cell = "-"
+		}
+		fmt.Fprintf(builder, " %s |", strings.ReplaceAll(cell, "|", `\|`))
+	}
+	builder.WriteString("\n")
+}
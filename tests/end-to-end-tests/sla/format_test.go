@@ -0,0 +1,88 @@
+package sla
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() *Report {
+	successRate := 0.75
+	lastSuccessfulBackup := time.Date(2024, time.March, 4, 1, 0, 0, 0, time.UTC)
+
+	return &Report{
+		ResourceGroupName: "rg-sla",
+		BackupVaultName:   "bvault-sla",
+		From:              time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+		To:                time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC),
+		Instances: []InstanceSummary{
+			{
+				BackupInstance:       "bkinst-blob-blob1",
+				BackupInstanceID:     "/subscriptions/sub/resourceGroups/rg-sla/providers/Microsoft.DataProtection/backupVaults/bvault-sla/backupInstances/bkinst-blob-blob1",
+				BackupPolicy:         "bkpol-blob-blob1",
+				Jobs:                 4,
+				Succeeded:            3,
+				Failed:               1,
+				SuccessRate:          &successRate,
+				LastSuccessfulBackup: &lastSuccessfulBackup,
+				AverageDuration:      Duration(90 * time.Second),
+				ScheduledRuns:        4,
+				MissedSchedules:      1,
+			},
+			{
+				BackupInstance:   "bkinst-disk-disk1",
+				BackupInstanceID: "/subscriptions/sub/resourceGroups/rg-sla/providers/Microsoft.DataProtection/backupVaults/bvault-sla/backupInstances/bkinst-disk-disk1",
+				BackupPolicy:     "bkpol-disk-disk1",
+				ScheduledRuns:    24,
+				MissedSchedules:  24,
+			},
+		},
+	}
+}
+
+/*
+ * TestWriteCSV tests that the report is written with a header and a row per backup instance.
+ */
+func TestWriteCSV(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, testReport().Write(&output, FormatCSV))
+
+	assert.Equal(t, `Backup Instance,Backup Policy,Jobs,Succeeded,Failed,Success Rate,Last Successful Backup,Average Duration,Scheduled Runs,Missed Schedules
+bkinst-blob-blob1,bkpol-blob-blob1,4,3,1,75.0%,2024-03-04T01:00:00Z,1m30s,4,1
+bkinst-disk-disk1,bkpol-disk-disk1,0,0,0,,,,24,24
+`, output.String())
+}
+
+/*
+ * TestWriteMarkdown tests that the report is written as a table, with a dash for values that
+ * can't be calculated.
+ */
+func TestWriteMarkdown(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, testReport().Write(&output, FormatMarkdown))
+
+	assert.Equal(t, `# Backup SLA report for rg-sla/bvault-sla
+
+2024-03-01T00:00:00Z to 2024-03-05T00:00:00Z
+
+| Backup Instance | Backup Policy | Jobs | Succeeded | Failed | Success Rate | Last Successful Backup | Average Duration | Scheduled Runs | Missed Schedules |
+| --- | --- | --- | --- | --- | --- | --- | --- | --- | --- |
+| bkinst-blob-blob1 | bkpol-blob-blob1 | 4 | 3 | 1 | 75.0% | 2024-03-04T01:00:00Z | 1m30s | 4 | 1 |
+| bkinst-disk-disk1 | bkpol-disk-disk1 | 0 | 0 | 0 | - | - | - | 24 | 24 |
+`, output.String())
+}
+
+/*
+ * TestWriteJSON tests that durations are written as JSON in Go's duration format, and that
+ * unsupported formats are rejected.
+ */
+func TestWriteJSON(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, testReport().Write(&output, FormatJSON))
+	assert.Contains(t, output.String(), `"averageDuration": "1m30s"`)
+
+	assert.EqualError(t, testReport().Write(&output, "html"), `format "html" is not supported`)
+}
@@ -0,0 +1,304 @@
+/*
+ * Package sla reports how reliably the backups in a vault ran over a time window, from the
+ * vault's job history: per backup instance, how many scheduled backups succeeded, when the last
+ * successful backup was, how long backups took, and how many scheduled runs had no successful
+ * backup.
+ */
+package sla
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"e2e_tests/azure"
+	"e2e_tests/interval"
+	"e2e_tests/wait"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+)
+
+// The operation category of backup jobs, whether scheduled or ad-hoc
+const operationCategoryBackup = "Backup"
+
+/*
+ * InstanceSummary summarises the backup jobs of a backup instance within the window. Jobs,
+ * success rate and durations only count scheduled backups; ad-hoc backups count towards the
+ * last successful backup and scheduled runs, as they also create recovery points.
+ */
+type InstanceSummary struct {
+	BackupInstance       string     `json:"backupInstance"`
+	BackupInstanceID     string     `json:"backupInstanceId"`
+	DatasourceID         string     `json:"datasourceId,omitempty"`
+	BackupPolicy         string     `json:"backupPolicy,omitempty"`
+	Jobs                 int        `json:"jobs"`
+	Succeeded            int        `json:"succeeded"`
+	Failed               int        `json:"failed"`
+	SuccessRate          *float64   `json:"successRate"`
+	LastSuccessfulBackup *time.Time `json:"lastSuccessfulBackup"`
+	AverageDuration      Duration   `json:"averageDuration"`
+	ScheduledRuns        int        `json:"scheduledRuns"`
+	MissedSchedules      int        `json:"missedSchedules"`
+}
+
+/*
+ * Report summarises the backup jobs of every backup instance in a vault within a window.
+ */
+type Report struct {
+	ResourceGroupName string            `json:"resourceGroupName"`
+	BackupVaultName   string            `json:"backupVaultName"`
+	From              time.Time         `json:"from"`
+	To                time.Time         `json:"to"`
+	Instances         []InstanceSummary `json:"instances"`
+}
+
+/*
+ * Generate lists the jobs, backup instances and backup policies of a vault, and summarises the
+ * backups of each instance from the start of the window up to, but not including, its end.
+ */
+func Generate(ctx context.Context, credential azcore.TokenCredential, clientOptions *arm.ClientOptions, subscriptionID string, resourceGroupName string, backupVaultName string, from time.Time, to time.Time) (*Report, error) {
+	jobs, err := azure.ListBackupJobs(ctx, credential, clientOptions, subscriptionID, resourceGroupName, backupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := azure.GetBackupInstances(ctx, credential, clientOptions, subscriptionID, resourceGroupName, backupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := azure.GetBackupPolicies(ctx, credential, clientOptions, subscriptionID, resourceGroupName, backupVaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	report := Summarise(jobs, instances, policies, from, to)
+	report.ResourceGroupName = resourceGroupName
+	report.BackupVaultName = backupVaultName
+
+	return report, nil
+}
+
+/*
+ * Summarise groups the backup jobs that started within the window by backup instance, and
+ * summarises them against the schedules of the instances' policies. Jobs for instances that
+ * are no longer in the vault are summarised without a schedule.
+ */
+func Summarise(jobs []*armdataprotection.AzureBackupJobResource, instances []*armdataprotection.BackupInstanceResource, policies []*armdataprotection.BaseBackupPolicyResource, from time.Time, to time.Time) *Report {
+	report := &Report{From: from, To: to}
+
+	jobsByInstance := map[string][]*armdataprotection.AzureBackupJob{}
+	var instanceIDs []string
+
+	for _, instance := range instances {
+		instanceIDs = append(instanceIDs, strings.ToLower(valueOf(instance.ID)))
+	}
+
+	for _, job := range jobs {
+		properties := job.Properties
+		if properties == nil || !strings.EqualFold(valueOf(properties.OperationCategory), operationCategoryBackup) || properties.StartTime == nil {
+			continue
+		}
+		if properties.StartTime.Before(from) || !properties.StartTime.Before(to) {
+			continue
+		}
+
+		instanceID := strings.ToLower(valueOf(properties.BackupInstanceID))
+		if !slices.Contains(instanceIDs, instanceID) {
+			instanceIDs = append(instanceIDs, instanceID)
+		}
+		jobsByInstance[instanceID] = append(jobsByInstance[instanceID], properties)
+	}
+
+	for _, instanceID := range instanceIDs {
+		summary := InstanceSummary{BackupInstanceID: instanceID}
+		var schedule *armdataprotection.BackupSchedule
+
+		if instance := getInstanceForID(instances, instanceID); instance != nil {
+			summary.BackupInstanceID = valueOf(instance.ID)
+			summary.BackupInstance = valueOf(instance.Name)
+
+			if properties := instance.Properties; properties != nil {
+				if properties.DataSourceInfo != nil {
+					summary.DatasourceID = valueOf(properties.DataSourceInfo.ResourceID)
+				}
+
+				if properties.PolicyInfo != nil {
+					if policy := getPolicyForID(policies, valueOf(properties.PolicyInfo.PolicyID)); policy != nil {
+						summary.BackupPolicy = valueOf(policy.Name)
+						schedule = policySchedule(policy)
+					}
+				}
+			}
+		}
+
+		instanceJobs := jobsByInstance[instanceID]
+		if summary.BackupInstance == "" && len(instanceJobs) > 0 {
+			summary.BackupInstance = valueOf(instanceJobs[0].BackupInstanceFriendlyName)
+		}
+
+		summariseJobs(&summary, instanceJobs)
+		if schedule != nil {
+			countScheduledRuns(&summary, instanceJobs, schedule, from, to)
+		}
+
+		report.Instances = append(report.Instances, summary)
+	}
+
+	slices.SortStableFunc(report.Instances, func(a, b InstanceSummary) int { return strings.Compare(a.BackupInstance, b.BackupInstance) })
+
+	return report
+}
+
+func summariseJobs(summary *InstanceSummary, jobs []*armdataprotection.AzureBackupJob) {
+	var totalDuration time.Duration
+	var timedJobs int
+
+	for _, job := range jobs {
+		status := wait.JobStatus(valueOf(job.Status))
+
+		if status.Succeeded() && (summary.LastSuccessfulBackup == nil || job.StartTime.After(*summary.LastSuccessfulBackup)) {
+			startTime := *job.StartTime
+			summary.LastSuccessfulBackup = &startTime
+		}
+
+		if isUserTriggered(job) || !status.Terminal() {
+			continue
+		}
+
+		summary.Jobs++
+		if status.Succeeded() {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+
+		if job.EndTime != nil {
+			totalDuration += job.EndTime.Sub(*job.StartTime)
+			timedJobs++
+		}
+	}
+
+	if summary.Jobs > 0 {
+		successRate := float64(summary.Succeeded) / float64(summary.Jobs)
+		summary.SuccessRate = &successRate
+	}
+
+	if timedJobs > 0 {
+		summary.AverageDuration = Duration(totalDuration / time.Duration(timedJobs))
+	}
+}
+
+/*
+ * Counts the runs of the schedule within the window, and those without a successful backup
+ * that started before the next run of any of the schedule's intervals. Runs whose slot hasn't
+ * ended by the end of the window aren't counted, as their backup may still be to come.
+ */
+func countScheduledRuns(summary *InstanceSummary, jobs []*armdataprotection.AzureBackupJob, schedule *armdataprotection.BackupSchedule, from time.Time, to time.Time) {
+	var intervals []interval.RepeatingInterval
+	var longest interval.Duration
+	for _, value := range schedule.RepeatingTimeIntervals {
+		repeatingInterval, err := interval.Parse(valueOf(value))
+		if err != nil {
+			continue
+		}
+
+		intervals = append(intervals, repeatingInterval)
+		if repeatingInterval.Period.Approximate() > longest.Approximate() {
+			longest = repeatingInterval.Period
+		}
+	}
+
+	// The slot of the last run in the window ends at the next run, which is after the window
+	runs := interval.MergedRunsBetween(intervals, from, longest.AddTo(to, 2), interval.Location(valueOf(schedule.TimeZone)))
+
+	for i, run := range runs {
+		if !run.Before(to) {
+			break
+		}
+
+		// An interval that's run out of repetitions leaves its last run without a next run
+		slotEnd := longest.AddTo(run, 1)
+		if i+1 < len(runs) {
+			slotEnd = runs[i+1]
+		}
+		if slotEnd.After(to) {
+			break
+		}
+
+		summary.ScheduledRuns++
+		if !slices.ContainsFunc(jobs, func(job *armdataprotection.AzureBackupJob) bool {
+			return wait.JobStatus(valueOf(job.Status)).Succeeded() && !job.StartTime.Before(run) && job.StartTime.Before(slotEnd)
+		}) {
+			summary.MissedSchedules++
+		}
+	}
+}
+
+func policySchedule(policy *armdataprotection.BaseBackupPolicyResource) *armdataprotection.BackupSchedule {
+	backupPolicy, ok := policy.Properties.(*armdataprotection.BackupPolicy)
+	if !ok {
+		return nil
+	}
+
+	return azure.GetBackupPolicySchedule(backupPolicy)
+}
+
+func getInstanceForID(instances []*armdataprotection.BackupInstanceResource, instanceID string) *armdataprotection.BackupInstanceResource {
+	for _, instance := range instances {
+		if strings.EqualFold(valueOf(instance.ID), instanceID) {
+			return instance
+		}
+	}
+
+	return nil
+}
+
+func getPolicyForID(policies []*armdataprotection.BaseBackupPolicyResource, policyID string) *armdataprotection.BaseBackupPolicyResource {
+	for _, policy := range policies {
+		if strings.EqualFold(valueOf(policy.ID), policyID) {
+			return policy
+		}
+	}
+
+	return nil
+}
+
+func isUserTriggered(job *armdataprotection.AzureBackupJob) bool {
+	return job.IsUserTriggered != nil && *job.IsUserTriggered
+}
+
+func valueOf(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
+
+/*
+ * Duration is a time.Duration which is written to JSON as a string, e.g. "1m30s".
+ */
+type Duration time.Duration
+
+func (duration Duration) String() string {
+	return time.Duration(duration).String()
+}
+
+func (duration Duration) MarshalText() ([]byte, error) {
+	return []byte(duration.String()), nil
+}
+
+func (duration *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", text, err)
+	}
+
+	*duration = Duration(parsed)
+	return nil
+}
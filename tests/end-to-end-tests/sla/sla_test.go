@@ -0,0 +1,152 @@
+package sla
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"e2e_tests/emulator"
+	"e2e_tests/emulator/emulatortest"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testResourceGroupName = "rg-sla"
+	testBackupVaultName   = "bvault-sla"
+)
+
+func testVariables() map[string]interface{} {
+	variables := emulatortest.Variables(testResourceGroupName, testBackupVaultName)
+	variables["blob_storage_backups"] = map[string]map[string]interface{}{
+		"backup1": emulatortest.BlobStorageBackup("blob1", emulatortest.ResourceID("rg-data", "Microsoft.Storage/storageAccounts", "sasla")),
+	}
+
+	return variables
+}
+
+func vaultID() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.DataProtection/backupVaults/%s", emulator.SubscriptionID, testResourceGroupName, testBackupVaultName)
+}
+
+/*
+ * Records a job in the emulator's vault for the named backup instance.
+ */
+func putJob(instanceName string, operation string, status string, userTriggered bool, startTime time.Time, duration time.Duration) {
+	emulatortest.Shared().Put(fmt.Sprintf("%s/backupJobs/job-%d", vaultID(), startTime.Unix()), map[string]any{
+		"properties": map[string]any{
+			"backupInstanceFriendlyName": instanceName,
+			"backupInstanceId":           vaultID() + "/backupInstances/" + instanceName,
+			"isUserTriggered":            userTriggered,
+			"operation":                  operation,
+			"operationCategory":          operation,
+			"startTime":                  startTime.Format(time.RFC3339),
+			"endTime":                    startTime.Add(duration).Format(time.RFC3339),
+			"status":                     status,
+		},
+	})
+}
+
+/*
+ * TestGenerate tests that the jobs of a vault are summarised per backup instance, counting the
+ * daily runs without a successful backup as missed.
+ */
+func TestGenerate(t *testing.T) {
+	credential, err := emulatortest.Shared().Credential()
+	require.NoError(t, err)
+
+	require.NoError(t, emulatortest.Shared().Apply(emulator.SubscriptionID, testVariables()))
+	defer func() { require.NoError(t, emulatortest.Shared().Destroy(emulator.SubscriptionID, testVariables())) }()
+
+	day := func(day int, hour int) time.Time { return time.Date(2024, time.March, day, hour, 0, 0, 0, time.UTC) }
+
+	putJob("bkinst-blob-blob1", "Backup", "Completed", false, day(1, 1), 10*time.Minute)
+	putJob("bkinst-blob-blob1", "Backup", "Failed", false, day(2, 1), 20*time.Minute)
+	putJob("bkinst-blob-blob1", "Backup", "CompletedWithWarnings", false, day(3, 1), 30*time.Minute)
+	// An ad-hoc backup covers the 4th, but isn't counted in the success rate
+	putJob("bkinst-blob-blob1", "Backup", "Completed", true, day(4, 12), time.Hour)
+	// Restores and jobs outside the window are ignored
+	putJob("bkinst-blob-blob1", "Restore", "Failed", true, day(4, 13), time.Minute)
+	putJob("bkinst-blob-blob1", "Backup", "Failed", false, day(5, 1), time.Minute)
+	// Jobs for an instance that's since been deleted are still summarised
+	putJob("bkinst-blob-old", "Backup", "Failed", false, day(1, 2), time.Minute)
+
+	report, err := Generate(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, testResourceGroupName, testBackupVaultName, day(1, 0), day(5, 0))
+	require.NoError(t, err)
+
+	require.Len(t, report.Instances, 2)
+	assert.Equal(t, testResourceGroupName, report.ResourceGroupName)
+	assert.Equal(t, testBackupVaultName, report.BackupVaultName)
+
+	instance := report.Instances[0]
+	assert.Equal(t, "bkinst-blob-blob1", instance.BackupInstance)
+	assert.Equal(t, "bkpol-blob-blob1", instance.BackupPolicy)
+	assert.Equal(t, 3, instance.Jobs)
+	assert.Equal(t, 2, instance.Succeeded)
+	assert.Equal(t, 1, instance.Failed)
+	require.NotNil(t, instance.SuccessRate)
+	assert.InDelta(t, 2.0/3.0, *instance.SuccessRate, 0.001)
+	require.NotNil(t, instance.LastSuccessfulBackup)
+	assert.True(t, day(4, 12).Equal(*instance.LastSuccessfulBackup))
+	assert.Equal(t, Duration(20*time.Minute), instance.AverageDuration)
+	assert.Equal(t, 4, instance.ScheduledRuns)
+	assert.Equal(t, 1, instance.MissedSchedules)
+
+	deleted := report.Instances[1]
+	assert.Equal(t, "bkinst-blob-old", deleted.BackupInstance)
+	assert.Empty(t, deleted.BackupPolicy)
+	assert.Equal(t, 1, deleted.Failed)
+	assert.Nil(t, deleted.LastSuccessfulBackup)
+	assert.Zero(t, deleted.ScheduledRuns)
+}
+
+/*
+ * TestGenerateNoJobs tests that an instance without any jobs in the window has missed every
+ * scheduled run, and has no success rate.
+ */
+func TestGenerateNoJobs(t *testing.T) {
+	credential, err := emulatortest.Shared().Credential()
+	require.NoError(t, err)
+
+	require.NoError(t, emulatortest.Shared().Apply(emulator.SubscriptionID, testVariables()))
+	defer func() { require.NoError(t, emulatortest.Shared().Destroy(emulator.SubscriptionID, testVariables())) }()
+
+	from := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	report, err := Generate(t.Context(), credential, emulatortest.Shared().ClientOptions(), emulator.SubscriptionID, testResourceGroupName, testBackupVaultName, from, from.Add(7*24*time.Hour))
+	require.NoError(t, err)
+
+	require.Len(t, report.Instances, 1)
+	instance := report.Instances[0]
+	assert.Zero(t, instance.Jobs)
+	assert.Nil(t, instance.SuccessRate)
+	// The run at midnight on the 8th is still in progress at midday
+	assert.Equal(t, 6, instance.ScheduledRuns)
+	assert.Equal(t, 6, instance.MissedSchedules)
+}
+
+/*
+ * TestCountScheduledRuns tests that each run of a schedule with several intervals lasts until
+ * the next run of any of them, so a Monday backup doesn't cover the previous Thursday's run.
+ */
+func TestCountScheduledRuns(t *testing.T) {
+	schedule := &armdataprotection.BackupSchedule{
+		RepeatingTimeIntervals: []*string{to.Ptr("R/2024-01-01T00:00:00+00:00/P1W"), to.Ptr("R/2024-01-04T00:00:00+00:00/P1W")},
+	}
+
+	day := func(day int, hour int) time.Time { return time.Date(2024, time.March, day, hour, 0, 0, 0, time.UTC) }
+
+	// Backups on the Mondays, but not the Thursdays
+	var jobs []*armdataprotection.AzureBackupJob
+	for _, startTime := range []time.Time{day(4, 1), day(11, 1)} {
+		jobs = append(jobs, &armdataprotection.AzureBackupJob{Status: to.Ptr("Completed"), StartTime: to.Ptr(startTime)})
+	}
+
+	var summary InstanceSummary
+	countScheduledRuns(&summary, jobs, schedule, day(4, 0), day(18, 0))
+
+	assert.Equal(t, 4, summary.ScheduledRuns)
+	assert.Equal(t, 2, summary.MissedSchedules)
+}
@@ -0,0 +1,141 @@
+package e2e_tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"e2e_tests/azure"
+	"e2e_tests/immutability"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestVaultImmutabilityLifecycleExternalResources struct {
+	ResourceGroup         armresources.ResourceGroup
+	LogAnalyticsWorkspace armoperationalinsights.Workspace
+}
+
+/*
+ * Creates resources which are "external" to the az-backup module, and models
+ * what would be backed up in a real scenario.
+ */
+func setupExternalResourcesForVaultImmutabilityLifecycleTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestVaultImmutabilityLifecycleExternalResources {
+	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+
+	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+
+	externalResources := &TestVaultImmutabilityLifecycleExternalResources{
+		ResourceGroup:         resourceGroup,
+		LogAnalyticsWorkspace: logAnalyticsWorkspace,
+	}
+
+	return externalResources
+}
+
+/*
+ * TestVaultImmutabilityLifecycle tests moving the backup vault through its immutability states,
+ * and that the transitions which Azure can't reverse are guarded.
+ */
+func TestVaultImmutabilityLifecycle(t *testing.T) {
+	t.Parallel()
+
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
+
+	uniqueId := random.UniqueId()
+	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
+	resourceGroupLocation := "uksouth"
+	backupVaultName := fmt.Sprintf("bvault-nhsbackup-%s", uniqueId)
+
+	externalResources := setupExternalResourcesForVaultImmutabilityLifecycleTest(t, credential, environment.SubscriptionID, resourceGroupName, resourceGroupLocation, uniqueId)
+
+	// Teardown stage
+	// ...
+
+	defer test_structure.RunTestStage(t, "teardown", func() {
+		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
+
+		DestroyTerraform(t, environment, terraformOptions)
+
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+	})
+
+	// Setup stage
+	// ...
+
+	test_structure.RunTestStage(t, "setup", func() {
+		terraformOptions := &terraform.Options{
+			TerraformDir: environment.TerraformFolder,
+
+			// The vault holds no backups, so it can still be destroyed once it's locked
+			Vars: map[string]interface{}{
+				"resource_group_name":        resourceGroupName,
+				"resource_group_location":    resourceGroupLocation,
+				"backup_vault_name":          backupVaultName,
+				"backup_vault_immutability":  "Unlocked",
+				"log_analytics_workspace_id": *externalResources.LogAnalyticsWorkspace.ID,
+			},
+
+			BackendConfig: map[string]interface{}{
+				"resource_group_name":  environment.TerraformStateResourceGroup,
+				"storage_account_name": environment.TerraformStateStorageAccount,
+				"container_name":       environment.TerraformStateContainer,
+				"key":                  backupVaultName + ".tfstate",
+			},
+		}
+
+		// Save options for later test stages
+		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
+
+		ApplyTerraform(t, environment, terraformOptions)
+	})
+
+	// Validate stage
+	// ...
+
+	test_structure.RunTestStage(t, "validate", func() {
+		machine := immutability.New(immutability.NewClient(credential, clientOptions(), environment.SubscriptionID), resourceGroupName, backupVaultName)
+
+		assertImmutability := func(expected armdataprotection.ImmutabilityState) {
+			backupVault := MustGetBackupVault(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName)
+			actual := *backupVault.Properties.SecuritySettings.ImmutabilitySettings.State
+			assert.Equal(t, expected, actual, "Expected the backup vault immutability to be %s", expected)
+		}
+
+		// Unlocked -> Disabled is reversible, so needs no confirmation
+		MustTransitionBackupVaultImmutability(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, armdataprotection.ImmutabilityStateDisabled, "")
+		assertImmutability(armdataprotection.ImmutabilityStateDisabled)
+
+		// Disabled -> Locked goes through Unlocked, and is refused without confirmation
+		_, err := machine.TransitionTo(t.Context(), armdataprotection.ImmutabilityStateLocked, "")
+		assert.ErrorIs(t, err, immutability.ErrConfirmationRequired, "Expected locking the vault without confirmation to be refused")
+		assertImmutability(armdataprotection.ImmutabilityStateDisabled)
+
+		result := MustTransitionBackupVaultImmutability(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, armdataprotection.ImmutabilityStateLocked, immutability.ConfirmationToken(backupVaultName))
+		assert.Len(t, result.Steps, 2, "Expected locking a disabled vault to go through Unlocked")
+		assertImmutability(armdataprotection.ImmutabilityStateLocked)
+
+		// Locked -> Unlocked is refused before the vault is updated, even with confirmation
+		_, err = machine.TransitionTo(t.Context(), armdataprotection.ImmutabilityStateUnlocked, immutability.ConfirmationToken(backupVaultName))
+		assert.ErrorIs(t, err, immutability.ErrIllegalTransition, "Expected unlocking a locked vault to be refused")
+		assertImmutability(armdataprotection.ImmutabilityStateLocked)
+
+		// Azure rejects the same transition when it's sent without the guard
+		err = azure.UpdateBackupVaultImmutability(t.Context(), credential, clientOptions(), environment.SubscriptionID, resourceGroupName, backupVaultName, armdataprotection.ImmutabilitySettings{
+			State: to.Ptr(armdataprotection.ImmutabilityStateUnlocked),
+		})
+		assert.Error(t, err, "Expected Azure to reject unlocking a locked vault")
+		assertImmutability(armdataprotection.ImmutabilityStateLocked)
+	})
+}
@@ -6,7 +6,10 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"e2e_tests/azure"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
@@ -28,16 +31,16 @@ type TestVaultImmutabilityExternalResources struct {
  * Creates resources which are "external" to the az-backup module, and models
  * what would be backed up in a real scenario.
  */
-func setupExternalResourcesForVaultImmutabilityTest(t *testing.T, credential *azidentity.ClientSecretCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestVaultImmutabilityExternalResources {
+func setupExternalResourcesForVaultImmutabilityTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestVaultImmutabilityExternalResources {
 	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
-	resourceGroup := CreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
 
 	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
-	logAnalyticsWorkspace := CreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
 
 	storageAccountName := fmt.Sprintf("sa%sexternal", strings.ToLower(uniqueId))
-	storageAccount := CreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, resourceGroupLocation)
-	storageAccountContainer := CreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, "test-container")
+	storageAccount := MustCreateStorageAccount(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, resourceGroupLocation, nil)
+	storageAccountContainer := MustCreateStorageAccountContainer(t, credential, subscriptionID, externalResourceGroupName, storageAccountName, "test-container")
 
 	externalResources := &TestVaultImmutabilityExternalResources{
 		ResourceGroup:           resourceGroup,
@@ -55,8 +58,8 @@ func setupExternalResourcesForVaultImmutabilityTest(t *testing.T, credential *az
 func TestVaultImmutability(t *testing.T) {
 	t.Parallel()
 
-	environment := GetEnvironmentConfiguration(t)
-	credential := GetAzureCredential(t, environment)
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
 
 	uniqueId := random.UniqueId()
 	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
@@ -84,9 +87,9 @@ func TestVaultImmutability(t *testing.T) {
 	defer test_structure.RunTestStage(t, "teardown", func() {
 		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
 
-		terraform.Destroy(t, terraformOptions)
+		DestroyTerraform(t, environment, terraformOptions)
 
-		DeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
 	})
 
 	// Setup stage
@@ -116,31 +119,30 @@ func TestVaultImmutability(t *testing.T) {
 		// Save options for later test stages
 		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
 
-		terraform.InitAndApply(t, terraformOptions)
+		ApplyTerraform(t, environment, terraformOptions)
 	})
 
 	// Validate stage
 	// ...
 
 	test_structure.RunTestStage(t, "validate", func() {
-		testFile := CreateTestFile(t)
+		testFile := MustCreateTestFile(t)
 		defer os.Remove(testFile.Name())
 
-		UploadFileToStorageAccount(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name,
-			*externalResources.StorageAccount.Name, *externalResources.StorageAccountContainer.Name, testFile.Name())
+		MustUploadFileToStorageAccount(t, credential, *externalResources.StorageAccount.Name, *externalResources.StorageAccountContainer.Name, testFile.Name())
 
-		backupInstanceName := fmt.Sprintf("bkinst-blob-%s", blobStorageBackups["backup1"]["backup_name"].(string))
-		BeginAdHocBackup(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+		backupInstanceName := MustGetBackupNames(t, naming.ResourceTypeBlobStorage, blobStorageBackups["backup1"]).InstanceName()
+		MustBeginAdHocBackup(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
 
-		errOne := DeleteBackupInstance(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+		errOne := azure.DeleteBackupInstance(t.Context(), credential, clientOptions(), environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
 		assert.Error(t, errOne, "Expected an error when deleting a backup instance from an immutable vault: %v", errOne)
 
 		disabledState := armdataprotection.ImmutabilityStateDisabled
-		UpdateBackupVaultImmutability(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, armdataprotection.ImmutabilitySettings{
+		MustUpdateBackupVaultImmutability(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, armdataprotection.ImmutabilitySettings{
 			State: &disabledState,
 		})
 
-		errTwo := DeleteBackupInstance(t, credential, environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
+		errTwo := azure.DeleteBackupInstance(t.Context(), credential, clientOptions(), environment.SubscriptionID, resourceGroupName, backupVaultName, backupInstanceName)
 		assert.NoError(t, errTwo, "Expected no error when deleting a backup instance from an unlocked vault: %v", errTwo)
 	})
 }
@@ -0,0 +1,196 @@
+package e2e_tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"e2e_tests/azure"
+	"e2e_tests/interval"
+	"e2e_tests/naming"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationalinsights/armoperationalinsights"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestVMBackupExternalResources struct {
+	ResourceGroup         armresources.ResourceGroup
+	LogAnalyticsWorkspace armoperationalinsights.Workspace
+	VirtualMachine        armcompute.VirtualMachine
+}
+
+/*
+ * Creates resources which are "external" to the az-backup module, and models
+ * what would be backed up in a real scenario.
+ */
+func setupExternalResourcesForVMBackupTest(t *testing.T, credential azcore.TokenCredential, subscriptionID string, resourceGroupName string, resourceGroupLocation string, uniqueId string) *TestVMBackupExternalResources {
+	externalResourceGroupName := fmt.Sprintf("%s-external", resourceGroupName)
+	resourceGroup := MustCreateResourceGroup(t, credential, subscriptionID, externalResourceGroupName, resourceGroupLocation)
+
+	logAnalyticsWorkspaceName := fmt.Sprintf("law-%s-external", strings.ToLower(uniqueId))
+	logAnalyticsWorkspace := MustCreateLogAnalyticsWorkspace(t, credential, subscriptionID, externalResourceGroupName, logAnalyticsWorkspaceName, resourceGroupLocation)
+
+	vmName := fmt.Sprintf("vm-%s-external", strings.ToLower(uniqueId))
+	virtualMachine := MustCreateVirtualMachine(t, credential, subscriptionID, externalResourceGroupName, vmName, resourceGroupLocation)
+
+	externalResources := &TestVMBackupExternalResources{
+		ResourceGroup:         resourceGroup,
+		LogAnalyticsWorkspace: logAnalyticsWorkspace,
+		VirtualMachine:        virtualMachine,
+	}
+
+	return externalResources
+}
+
+/*
+ * TestVMBackup tests the deployment of a recovery services vault alongside the backup vault,
+ * and the backup policy and protected item for a virtual machine.
+ */
+func TestVMBackup(t *testing.T) {
+	t.Parallel()
+
+	environment := MustGetEnvironmentConfiguration(t)
+	credential := MustGetAzureCredential(t, environment)
+
+	uniqueId := random.UniqueId()
+	resourceGroupName := fmt.Sprintf("rg-nhsbackup-%s", uniqueId)
+	resourceGroupLocation := "uksouth"
+	backupVaultName := fmt.Sprintf("bvault-nhsbackup-%s", uniqueId)
+	recoveryServicesVaultName := fmt.Sprintf("rsvault-nhsbackup-%s", uniqueId)
+
+	externalResources := setupExternalResourcesForVMBackupTest(t, credential, environment.SubscriptionID, resourceGroupName, resourceGroupLocation, uniqueId)
+
+	// A map of backups which we'll use to apply the TF module, and then validate the
+	// policies have been created correctly
+	vmBackups := map[string]map[string]interface{}{
+		"backup1": {
+			"backup_name":      "vm1",
+			"retention_period": "P7D",
+			"backup_intervals": []string{"R/2024-01-01T01:30:00+00:00/P1D"},
+			"vm_id":            *externalResources.VirtualMachine.ID,
+			"retention_rules": map[string]map[string]interface{}{
+				"weekly":  {"count": 4, "weekdays": []string{"Sunday"}},
+				"monthly": {"count": 12, "weekdays": []string{"Sunday"}, "weeks": []string{"First"}},
+				"yearly":  {"count": 5, "weekdays": []string{"Sunday"}, "weeks": []string{"First"}, "months": []string{"January"}},
+			},
+		},
+	}
+
+	// Teardown stage
+	// ...
+
+	defer test_structure.RunTestStage(t, "teardown", func() {
+		terraformOptions := test_structure.LoadTerraformOptions(t, environment.TerraformFolder)
+
+		DestroyTerraform(t, environment, terraformOptions)
+
+		MustDeleteResourceGroup(t, credential, environment.SubscriptionID, *externalResources.ResourceGroup.Name)
+	})
+
+	// Setup stage
+	// ...
+
+	test_structure.RunTestStage(t, "setup", func() {
+		terraformOptions := &terraform.Options{
+			TerraformDir: environment.TerraformFolder,
+
+			Vars: map[string]interface{}{
+				"resource_group_name":          resourceGroupName,
+				"resource_group_location":      resourceGroupLocation,
+				"backup_vault_name":            backupVaultName,
+				"recovery_services_vault_name": recoveryServicesVaultName,
+				"use_extended_retention":       true,
+				"log_analytics_workspace_id":   *externalResources.LogAnalyticsWorkspace.ID,
+				"vm_backups":                   vmBackups,
+			},
+
+			BackendConfig: map[string]interface{}{
+				"resource_group_name":  environment.TerraformStateResourceGroup,
+				"storage_account_name": environment.TerraformStateStorageAccount,
+				"container_name":       environment.TerraformStateContainer,
+				"key":                  backupVaultName + ".tfstate",
+			},
+		}
+
+		// Save options for later test stages
+		test_structure.SaveTerraformOptions(t, environment.TerraformFolder, terraformOptions)
+
+		ApplyTerraform(t, environment, terraformOptions)
+	})
+
+	// Validate stage
+	// ...
+
+	test_structure.RunTestStage(t, "validate", func() {
+		recoveryServicesVault := MustGetRecoveryServicesVault(t, credential, environment.SubscriptionID, resourceGroupName, recoveryServicesVaultName)
+		backupPolicies := MustGetRecoveryServicesBackupPolicies(t, credential, environment.SubscriptionID, resourceGroupName, recoveryServicesVaultName)
+		protectedItems := MustGetRecoveryServicesProtectedItems(t, credential, environment.SubscriptionID, resourceGroupName, recoveryServicesVaultName)
+
+		// Validate the recovery services vault has the module's default settings
+		assert.Equal(t, "Standard", recoveryServicesVault.SKU.Name, "Expected the recovery services vault to be a standard vault")
+		assert.Equal(t, "LocallyRedundant", recoveryServicesVault.Properties.RedundancySettings.StandardTierStorageRedundancy, "Expected the recovery services vault to be locally redundant")
+		assert.Equal(t, "Disabled", recoveryServicesVault.Properties.SecuritySettings.ImmutabilitySettings.State, "Expected the recovery services vault immutability to be disabled")
+		assert.Equal(t, "Disabled", recoveryServicesVault.Properties.SecuritySettings.SoftDeleteSettings.SoftDeleteState, "Expected the recovery services vault soft delete to be disabled")
+		assert.NotNil(t, recoveryServicesVault.Identity, "Expected the recovery services vault to have a system assigned identity")
+
+		// Validate the recovery services vault sends its logs to the same workspace as the backup vault
+		diagnosticSettings := MustGetDiagnosticSettings(t, credential, recoveryServicesVault.ID)
+		assert.Equal(t, 4, len(diagnosticSettings.Properties.Logs), "Expected to find %d log categories in diagnostic settings", 4)
+		assert.True(t, strings.EqualFold(*externalResources.LogAnalyticsWorkspace.ID, *diagnosticSettings.Properties.WorkspaceID), "Expected the diagnostic settings to use workspace %s", *externalResources.LogAnalyticsWorkspace.ID)
+
+		assert.Equal(t, len(vmBackups), len(backupPolicies), "Expected to find %d backup policies in vault", len(vmBackups))
+		assert.Equal(t, len(vmBackups), len(protectedItems), "Expected to find %d protected items in vault", len(vmBackups))
+
+		for _, backup := range vmBackups {
+			vmId := backup["vm_id"].(string)
+			backupInterval, err := interval.Parse(backup["backup_intervals"].([]string)[0])
+			assert.NoError(t, err)
+
+			// Validate backup policy
+			backupPolicyName := MustGetBackupNames(t, naming.ResourceTypeVirtualMachine, backup).PolicyName()
+			backupPolicy := azure.GetRecoveryServicesBackupPolicyForName(backupPolicies, backupPolicyName)
+			if !assert.NotNil(t, backupPolicy, "Expected to find a backup policy called %s", backupPolicyName) {
+				continue
+			}
+
+			assert.Equal(t, "AzureIaasVM", backupPolicy.Properties.BackupManagementType, "Expected the backup policy to be for virtual machines")
+			assert.Equal(t, "Daily", backupPolicy.Properties.SchedulePolicy.ScheduleRunFrequency, "Expected the backup policy to run daily")
+
+			// Validate the backup runs at the time of day that the interval starts
+			if assert.Len(t, backupPolicy.Properties.SchedulePolicy.ScheduleRunTimes, 1) {
+				runTime, err := time.Parse(time.RFC3339, backupPolicy.Properties.SchedulePolicy.ScheduleRunTimes[0])
+				assert.NoError(t, err)
+				assert.Equal(t, backupInterval.Start.UTC().Format("15:04"), runTime.UTC().Format("15:04"), "Expected the backup policy to run at the start time of the backup interval")
+			}
+
+			// Validate retention period
+			if assert.NotNil(t, backupPolicy.Properties.RetentionPolicy.DailySchedule, "Expected the backup policy to keep daily recovery points") {
+				assert.Equal(t, 7, backupPolicy.Properties.RetentionPolicy.DailySchedule.RetentionDuration.Count, "Expected the backup policy to keep %d daily recovery points", 7)
+			}
+
+			// Validate retention rules
+			if retentionRules, ok := backup["retention_rules"].(map[string]map[string]interface{}); ok {
+				AssertRecoveryServicesRetentionRulesEqual(t, retentionRules, backupPolicy)
+			}
+
+			// Validate protected item
+			protectedItem := azure.GetRecoveryServicesProtectedItemForSource(protectedItems, vmId, "")
+			if !assert.NotNil(t, protectedItem, "Expected to find a protected item for %s", vmId) {
+				continue
+			}
+
+			assert.Equal(t, "VM", protectedItem.Properties.WorkloadType, "Expected the protected item to be a virtual machine")
+			assert.True(t, strings.EqualFold(backupPolicy.ID, protectedItem.Properties.PolicyID), "Expected the protected item policy ID to be %s", backupPolicy.ID)
+
+			// The initial backup is pending until the policy first runs
+			assert.Contains(t, []string{"IRPending", "Protected"}, protectedItem.Properties.ProtectionState, "Expected the virtual machine to be protected")
+		}
+	})
+}
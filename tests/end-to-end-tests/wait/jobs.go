@@ -0,0 +1,142 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+)
+
+/*
+ * JobStatus is the status of a backup vault job, as reported by the data protection API.
+ */
+type JobStatus string
+
+const (
+	JobStatusNotStarted            JobStatus = "NotStarted"
+	JobStatusInProgress            JobStatus = "InProgress"
+	JobStatusCancelling            JobStatus = "Cancelling"
+	JobStatusCompleted             JobStatus = "Completed"
+	JobStatusCompletedWithWarnings JobStatus = "CompletedWithWarnings"
+	JobStatusFailed                JobStatus = "Failed"
+	JobStatusCancelled             JobStatus = "Cancelled"
+)
+
+/*
+ * Terminal reports whether a job with the status has finished. Statuses which aren't known
+ * are treated as still running, so the wait is bounded by the timeout rather than ending early.
+ */
+func (status JobStatus) Terminal() bool {
+	switch status {
+	case JobStatusCompleted, JobStatusCompletedWithWarnings, JobStatusFailed, JobStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+ * Succeeded reports whether a job with the status finished successfully. Jobs which completed
+ * with warnings are treated as successful - the warnings are available in the result.
+ */
+func (status JobStatus) Succeeded() bool {
+	return status == JobStatusCompleted || status == JobStatusCompletedWithWarnings
+}
+
+/*
+ * JobsClient is the part of the data protection jobs client needed to wait for a job, which
+ * is satisfied by *armdataprotection.JobsClient.
+ */
+type JobsClient interface {
+	Get(ctx context.Context, resourceGroupName string, vaultName string, jobID string, options *armdataprotection.JobsClientGetOptions) (armdataprotection.JobsClientGetResponse, error)
+}
+
+/*
+ * JobResult is the outcome of a backup vault job which has finished.
+ */
+type JobResult struct {
+	JobID        string
+	Operation    string
+	Status       JobStatus
+	Duration     time.Duration
+	ErrorDetails []*armdataprotection.UserFacingError
+}
+
+/*
+ * JobError is returned when a job finishes without succeeding.
+ */
+type JobError struct {
+	Result *JobResult
+}
+
+func (e *JobError) Error() string {
+	message := fmt.Sprintf("job '%s' finished with status %s", e.Result.JobID, e.Result.Status)
+
+	var details []string
+	for _, detail := range e.Result.ErrorDetails {
+		details = append(details, fmt.Sprintf("%s: %s", valueOf(detail.Code), valueOf(detail.Message)))
+	}
+	if len(details) > 0 {
+		message += ": " + strings.Join(details, "; ")
+	}
+
+	return message
+}
+
+/*
+ * ForJob waits for a backup vault job to finish and returns its result. A *JobError is
+ * returned alongside the result when the job failed or was cancelled.
+ *
+ * The job ID can be provided as the full resource ID, or just the name.
+ */
+func ForJob(ctx context.Context, client JobsClient, resourceGroupName string, vaultName string, jobID string, options *Options) (*JobResult, error) {
+	jobName := jobID[strings.LastIndex(jobID, "/")+1:]
+	started := time.Now()
+
+	var job *armdataprotection.AzureBackupJob
+	err := Until(ctx, options, func(ctx context.Context) (bool, error) {
+		resp, err := client.Get(ctx, resourceGroupName, vaultName, jobName, nil)
+		if err != nil {
+			return false, fmt.Errorf("failed to get job '%s': %w", jobName, err)
+		}
+
+		if resp.Properties == nil || resp.Properties.Status == nil {
+			return false, nil
+		}
+
+		job = resp.Properties
+
+		return JobStatus(*job.Status).Terminal(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed waiting for job '%s': %w", jobName, err)
+	}
+
+	result := &JobResult{
+		JobID:        jobName,
+		Operation:    valueOf(job.Operation),
+		Status:       JobStatus(*job.Status),
+		Duration:     time.Since(started),
+		ErrorDetails: job.ErrorDetails,
+	}
+
+	if job.StartTime != nil && job.EndTime != nil {
+		result.Duration = job.EndTime.Sub(*job.StartTime)
+	}
+
+	if !result.Status.Succeeded() {
+		return result, &JobError{Result: result}
+	}
+
+	return result, nil
+}
+
+func valueOf(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
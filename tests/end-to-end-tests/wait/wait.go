@@ -0,0 +1,142 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+const (
+	DefaultTimeout         = time.Hour
+	DefaultInitialInterval = 5 * time.Second
+	DefaultMaxInterval     = time.Minute
+	DefaultMultiplier      = 2.0
+	DefaultJitter          = 0.2
+)
+
+/*
+ * ErrTimeout is the cause reported when the overall timeout elapses before the thing being
+ * waited for has finished.
+ */
+var ErrTimeout = errors.New("timed out waiting")
+
+/*
+ * Options control how long to wait for, and how often to poll. Zero values are replaced with
+ * the defaults.
+ */
+type Options struct {
+	// The overall time to wait before giving up
+	Timeout time.Duration
+
+	// The interval before the first retry, which grows by the multiplier up to the max interval
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+
+	// The fraction of each interval that is randomly added or removed, so that concurrent
+	// tests don't poll in lockstep
+	Jitter float64
+}
+
+func (options *Options) withDefaults() Options {
+	result := Options{}
+	if options != nil {
+		result = *options
+	}
+
+	if result.Timeout <= 0 {
+		result.Timeout = DefaultTimeout
+	}
+	if result.InitialInterval <= 0 {
+		result.InitialInterval = DefaultInitialInterval
+	}
+	if result.MaxInterval <= 0 {
+		result.MaxInterval = DefaultMaxInterval
+	}
+	if result.Multiplier < 1 {
+		result.Multiplier = DefaultMultiplier
+	}
+	if result.Jitter <= 0 || result.Jitter > 1 {
+		result.Jitter = DefaultJitter
+	}
+
+	return result
+}
+
+/*
+ * Gets the interval to wait before the provided (zero based) retry, with exponential backoff
+ * capped at the max interval, and jitter applied.
+ */
+func (options Options) interval(retry int) time.Duration {
+	interval := float64(options.InitialInterval)
+	for range retry {
+		interval *= options.Multiplier
+		if interval >= float64(options.MaxInterval) {
+			interval = float64(options.MaxInterval)
+			break
+		}
+	}
+
+	return time.Duration(interval * (1 + options.Jitter*(2*rand.Float64()-1)))
+}
+
+/*
+ * Until calls the condition until it reports that it's done, it returns an error, the
+ * timeout elapses or the context is cancelled - backing off between each call.
+ */
+func Until(ctx context.Context, options *Options, condition func(ctx context.Context) (bool, error)) error {
+	resolvedOptions := options.withDefaults()
+
+	ctx, cancel := context.WithTimeoutCause(ctx, resolvedOptions.Timeout, fmt.Errorf("%w after %s", ErrTimeout, resolvedOptions.Timeout))
+	defer cancel()
+
+	for retry := 0; ; retry++ {
+		done, err := condition(ctx)
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		timer := time.NewTimer(resolvedOptions.interval(retry))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return context.Cause(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
+/*
+ * ForOperation waits for a long running ARM operation to finish and returns its result,
+ * polling with backoff rather than the fixed frequency used by PollUntilDone.
+ */
+func ForOperation[T any](ctx context.Context, poller *runtime.Poller[T], options *Options) (T, error) {
+	err := Until(ctx, options, func(ctx context.Context) (bool, error) {
+		if poller.Done() {
+			return true, nil
+		}
+
+		if _, err := poller.Poll(ctx); err != nil {
+			return false, err
+		}
+
+		return poller.Done(), nil
+	})
+	if err != nil {
+		var result T
+		return result, err
+	}
+
+	return poller.Result(ctx)
+}
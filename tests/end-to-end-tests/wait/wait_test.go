@@ -0,0 +1,134 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dataprotection/armdataprotection/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testOptions = &Options{Timeout: time.Second, InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond}
+
+/*
+ * A fake jobs client which reports each of the provided statuses in turn, and then keeps
+ * reporting the last one.
+ */
+type fakeJobsClient struct {
+	statuses     []JobStatus
+	errorDetails []*armdataprotection.UserFacingError
+	err          error
+	calls        int
+}
+
+func (client *fakeJobsClient) Get(ctx context.Context, resourceGroupName string, vaultName string, jobID string, options *armdataprotection.JobsClientGetOptions) (armdataprotection.JobsClientGetResponse, error) {
+	client.calls++
+
+	if client.err != nil {
+		return armdataprotection.JobsClientGetResponse{}, client.err
+	}
+
+	status := client.statuses[min(client.calls, len(client.statuses))-1]
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	job := &armdataprotection.AzureBackupJob{
+		Operation: to.Ptr("Backup"),
+		Status:    to.Ptr(string(status)),
+		StartTime: &startTime,
+	}
+
+	if status.Terminal() {
+		job.EndTime = to.Ptr(startTime.Add(90 * time.Second))
+		job.ErrorDetails = client.errorDetails
+	}
+
+	return armdataprotection.JobsClientGetResponse{
+		AzureBackupJobResource: armdataprotection.AzureBackupJobResource{Name: &jobID, Properties: job},
+	}, nil
+}
+
+func TestForJobWaitsForTerminalStatus(t *testing.T) {
+	client := &fakeJobsClient{statuses: []JobStatus{JobStatusNotStarted, JobStatusInProgress, JobStatusInProgress, JobStatusCompleted}}
+
+	result, err := ForJob(context.Background(), client, "rg", "vault", "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.DataProtection/backupVaults/vault/backupJobs/job1", testOptions)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, client.calls)
+	assert.Equal(t, "job1", result.JobID)
+	assert.Equal(t, "Backup", result.Operation)
+	assert.Equal(t, JobStatusCompleted, result.Status)
+	assert.Equal(t, 90*time.Second, result.Duration)
+}
+
+func TestForJobTreatsWarningsAsSuccess(t *testing.T) {
+	client := &fakeJobsClient{
+		statuses:     []JobStatus{JobStatusCompletedWithWarnings},
+		errorDetails: []*armdataprotection.UserFacingError{{Code: to.Ptr("UserErrorSomeFilesSkipped"), Message: to.Ptr("Some files were skipped")}},
+	}
+
+	result, err := ForJob(context.Background(), client, "rg", "vault", "job1", testOptions)
+	require.NoError(t, err)
+
+	assert.Equal(t, JobStatusCompletedWithWarnings, result.Status)
+	assert.Len(t, result.ErrorDetails, 1)
+}
+
+func TestForJobReturnsJobErrorForFailedJobs(t *testing.T) {
+	for _, status := range []JobStatus{JobStatusFailed, JobStatusCancelled} {
+		t.Run(string(status), func(t *testing.T) {
+			client := &fakeJobsClient{
+				statuses:     []JobStatus{JobStatusInProgress, status},
+				errorDetails: []*armdataprotection.UserFacingError{{Code: to.Ptr("UserErrorMissingRequiredPermissions"), Message: to.Ptr("Missing permissions")}},
+			}
+
+			result, err := ForJob(context.Background(), client, "rg", "vault", "job1", testOptions)
+
+			var jobError *JobError
+			require.ErrorAs(t, err, &jobError)
+			assert.Same(t, result, jobError.Result)
+			assert.Equal(t, status, result.Status)
+			assert.EqualError(t, err, "job 'job1' finished with status "+string(status)+": UserErrorMissingRequiredPermissions: Missing permissions")
+		})
+	}
+}
+
+func TestForJobTimesOut(t *testing.T) {
+	client := &fakeJobsClient{statuses: []JobStatus{JobStatusInProgress}}
+
+	_, err := ForJob(context.Background(), client, "rg", "vault", "job1", &Options{Timeout: 20 * time.Millisecond, InitialInterval: time.Millisecond})
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Greater(t, client.calls, 1)
+}
+
+func TestForJobStopsWhenContextIsCancelled(t *testing.T) {
+	client := &fakeJobsClient{statuses: []JobStatus{JobStatusInProgress}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err := ForJob(ctx, client, "rg", "vault", "job1", testOptions)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrTimeout)
+}
+
+func TestForJobReturnsClientErrors(t *testing.T) {
+	client := &fakeJobsClient{err: errors.New("forbidden")}
+
+	_, err := ForJob(context.Background(), client, "rg", "vault", "job1", testOptions)
+	assert.ErrorContains(t, err, "forbidden")
+	assert.Equal(t, 1, client.calls)
+}
+
+func TestIntervalBacksOffWithJitter(t *testing.T) {
+	options := (&Options{InitialInterval: time.Second, MaxInterval: 10 * time.Second, Multiplier: 2, Jitter: 0.5}).withDefaults()
+
+	for retry, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		interval := options.interval(retry)
+		assert.GreaterOrEqual(t, interval, expected/2, "Expected retry %d to wait at least half of %s", retry, expected)
+		assert.LessOrEqual(t, interval, expected*3/2, "Expected retry %d to wait at most one and a half times %s", retry, expected)
+	}
+}
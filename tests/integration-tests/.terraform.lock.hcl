@@ -3,7 +3,7 @@
 
 provider "registry.terraform.io/hashicorp/azurerm" {
   version     = "5.0.1"
-  constraints = ">= 4.18.0, < 5.1.0"
+  constraints = ">= 4.37.0, < 5.1.0"
   hashes = [
     "h1:V69grO5xSjPMLwrPA/LDoLvsRGzuSwkka7zFbRXuEhc=",
     "zh:2de9caf937237bf5ee747b803b15a08276ceb275f611f6444fca3d82fc75afec",
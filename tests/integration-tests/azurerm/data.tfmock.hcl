@@ -22,8 +22,57 @@ mock_resource "azurerm_data_protection_backup_policy_disk" {
   }
 }
 
+mock_resource "azurerm_data_protection_backup_policy_data_lake_storage" {
+  defaults = {
+    id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DataProtection/backupVaults/bvault-testvault/backupPolicies/bkpol-testvault-testpolicy"
+  }
+}
+
 mock_resource "azurerm_data_protection_backup_policy_postgresql_flexible_server" {
   defaults = {
     id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DataProtection/backupVaults/bvault-testvault/backupPolicies/bkpol-testvault-testpolicy"
   }
 }
+
+mock_resource "azurerm_data_protection_backup_policy_mysql_flexible_server" {
+  defaults = {
+    id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DataProtection/backupVaults/bvault-testvault/backupPolicies/bkpol-testvault-testpolicy"
+  }
+}
+
+mock_resource "azurerm_data_protection_backup_policy_kubernetes_cluster" {
+  defaults = {
+    id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DataProtection/backupVaults/bvault-testvault/backupPolicies/bkpol-testvault-testpolicy"
+  }
+}
+
+mock_resource "azurerm_kubernetes_cluster_extension" {
+  defaults = {
+    id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1/providers/Microsoft.KubernetesConfiguration/extensions/azure-aks-backup"
+    aks_assigned_identity = [
+      {
+        principal_id = "00000000-0000-0000-0000-000000000001"
+        tenant_id    = "00000000-0000-0000-0000-000000000002"
+        type         = "SystemAssigned"
+      }
+    ]
+  }
+}
+
+mock_resource "azurerm_recovery_services_vault" {
+  defaults = {
+    id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.RecoveryServices/vaults/rsvault-testvault"
+  }
+}
+
+mock_resource "azurerm_backup_policy_vm" {
+  defaults = {
+    id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.RecoveryServices/vaults/rsvault-testvault/backupPolicies/bkpol-vm-testpolicy"
+  }
+}
+
+mock_resource "azurerm_backup_policy_file_share" {
+  defaults = {
+    id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.RecoveryServices/vaults/rsvault-testvault/backupPolicies/bkpol-afs-testpolicy"
+  }
+}
@@ -0,0 +1,580 @@
+mock_provider "azurerm" {
+  source = "./azurerm"
+}
+
+run "setup_tests" {
+  module {
+    source = "./setup"
+  }
+}
+
+run "create_aks_cluster_backup" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    aks_cluster_backups = {
+      backup1 = {
+        backup_name                   = "aks1"
+        retention_period              = "P1D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000001"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks1"
+      }
+      backup2 = {
+        backup_name                   = "aks2"
+        retention_period              = "P7D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/PT4H"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-2"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000002"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group2"
+          name = "example-resource-group2"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks2"
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.aks_cluster_backup) == 2
+    error_message = "Number of backup modules not as expected."
+  }
+
+  assert {
+    condition     = length(module.aks_cluster_backup["backup1"].backup_policy.id) > 0
+    error_message = "AKS cluster backup policy id not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_policy.name == "bkpol-aks-aks1"
+    error_message = "AKS cluster backup policy name not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_policy.vault_name == azurerm_data_protection_backup_vault.backup_vault.name
+    error_message = "AKS cluster backup policy vault name not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_policy.default_retention_rule[0].life_cycle[0].duration == "P1D"
+    error_message = "AKS cluster backup policy retention period not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_policy.default_retention_rule[0].life_cycle[0].data_store_type == "OperationalStore"
+    error_message = "AKS cluster backup policy data store type not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_policy.backup_repeating_time_intervals[0] == "R/2024-01-01T00:00:00+00:00/P1D"
+    error_message = "AKS cluster backup policy backup intervals not as expected."
+  }
+
+  assert {
+    condition     = length(module.aks_cluster_backup["backup1"].backup_instance.id) > 0
+    error_message = "AKS cluster backup instance id not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_instance.name == "bkinst-aks-aks1"
+    error_message = "AKS cluster backup instance name not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_instance.vault_id == azurerm_data_protection_backup_vault.backup_vault.id
+    error_message = "AKS cluster backup instance vault id not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_instance.location == azurerm_data_protection_backup_vault.backup_vault.location
+    error_message = "AKS cluster backup instance location not as expected."
+  }
+
+  assert {
+    condition     = length(module.aks_cluster_backup["backup1"].backup_instance.kubernetes_cluster_id) > 0
+    error_message = "AKS cluster backup instance cluster id not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_instance.snapshot_resource_group_name == "example-resource-group1"
+    error_message = "AKS cluster backup instance snapshot resource group not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_instance.backup_policy_id == module.aks_cluster_backup["backup1"].backup_policy.id
+    error_message = "AKS cluster backup instance backup policy id not as expected."
+  }
+
+  assert {
+    condition     = length(module.aks_cluster_backup["backup2"].backup_policy.id) > 0
+    error_message = "AKS cluster backup policy id not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup2"].backup_policy.name == "bkpol-aks-aks2"
+    error_message = "AKS cluster backup policy name not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup2"].backup_policy.vault_name == azurerm_data_protection_backup_vault.backup_vault.name
+    error_message = "AKS cluster backup policy vault name not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup2"].backup_policy.default_retention_rule[0].life_cycle[0].duration == "P7D"
+    error_message = "AKS cluster backup policy retention period not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup2"].backup_policy.default_retention_rule[0].life_cycle[0].data_store_type == "OperationalStore"
+    error_message = "AKS cluster backup policy data store type not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup2"].backup_policy.backup_repeating_time_intervals[0] == "R/2024-01-01T00:00:00+00:00/PT4H"
+    error_message = "AKS cluster backup policy backup intervals not as expected."
+  }
+
+  assert {
+    condition     = length(module.aks_cluster_backup["backup2"].backup_instance.id) > 0
+    error_message = "AKS cluster backup instance id not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup2"].backup_instance.name == "bkinst-aks-aks2"
+    error_message = "AKS cluster backup instance name not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup2"].backup_instance.vault_id == azurerm_data_protection_backup_vault.backup_vault.id
+    error_message = "AKS cluster backup instance vault id not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup2"].backup_instance.location == azurerm_data_protection_backup_vault.backup_vault.location
+    error_message = "AKS cluster backup instance location not as expected."
+  }
+
+  assert {
+    condition     = length(module.aks_cluster_backup["backup2"].backup_instance.kubernetes_cluster_id) > 0
+    error_message = "AKS cluster backup instance cluster id not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup2"].backup_instance.snapshot_resource_group_name == "example-resource-group2"
+    error_message = "AKS cluster backup instance snapshot resource group not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup2"].backup_instance.backup_policy_id == module.aks_cluster_backup["backup2"].backup_policy.id
+    error_message = "AKS cluster backup instance backup policy id not as expected."
+  }
+}
+
+run "validate_retention_period" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    aks_cluster_backups = {
+      backup1 = {
+        backup_name                   = "aks1"
+        retention_period              = "P30D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000001"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.aks_cluster_backups,
+  ]
+}
+
+run "validate_retention_period_with_extended_retention" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    aks_cluster_backups = {
+      backup1 = {
+        backup_name                   = "aks1"
+        retention_period              = "P30D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000001"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks1"
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.aks_cluster_backup) == 1
+    error_message = "Number of backup modules not as expected."
+  }
+}
+
+run "validate_backup_intervals" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    aks_cluster_backups = {
+      backup1 = {
+        backup_name                   = "aks1"
+        retention_period              = "P7D"
+        backup_intervals              = []
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000001"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.aks_cluster_backups,
+  ]
+}
+
+run "validate_backup_intervals_invalid_frequency" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    aks_cluster_backups = {
+      backup1 = {
+        backup_name                   = "aks1"
+        retention_period              = "P7D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/PT1H"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000001"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.aks_cluster_backups,
+  ]
+}
+
+run "validate_cluster_backed_up_once" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    aks_cluster_backups = {
+      backup1 = {
+        backup_name                   = "aks1"
+        retention_period              = "P7D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000001"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks1"
+      }
+      backup2 = {
+        backup_name                   = "aks2"
+        retention_period              = "P7D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000002"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks2"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.aks_cluster_backups,
+  ]
+}
+
+run "create_aks_cluster_backup_with_retention_rules" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    aks_cluster_backups = {
+      backup1 = {
+        backup_name                   = "aks1"
+        retention_period              = "P30D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000001"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks1"
+        retention_rules = [
+          {
+            name              = "Yearly"
+            duration          = "P10Y"
+            absolute_criteria = "FirstOfYear"
+          },
+          {
+            name              = "Monthly"
+            duration          = "P12M"
+            absolute_criteria = "FirstOfMonth"
+          },
+          {
+            name              = "Weekly"
+            duration          = "P4W"
+            absolute_criteria = "FirstOfWeek"
+          },
+        ]
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.aks_cluster_backup["backup1"].backup_policy.retention_rule) == 3
+    error_message = "AKS cluster backup policy retention rules not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_policy.retention_rule[0].name == "Yearly"
+    error_message = "AKS cluster backup policy retention rule name not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_policy.retention_rule[0].criteria[0].absolute_criteria == "FirstOfYear"
+    error_message = "AKS cluster backup policy retention rule criteria not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_policy.retention_rule[2].life_cycle[0].duration == "P4W"
+    error_message = "AKS cluster backup policy retention rule duration not as expected."
+  }
+
+  assert {
+    condition     = module.aks_cluster_backup["backup1"].backup_policy.retention_rule[2].priority == 3
+    error_message = "AKS cluster backup policy retention rule priority not as expected."
+  }
+}
+
+run "validate_retention_rules_duration" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    aks_cluster_backups = {
+      backup1 = {
+        backup_name                   = "aks1"
+        retention_period              = "P7D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000001"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks1"
+        retention_rules = [
+          {
+            name              = "Weekly"
+            duration          = "P4W"
+            absolute_criteria = "FirstOfWeek"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.aks_cluster_backups,
+  ]
+}
+
+run "validate_retention_rules_criteria" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    aks_cluster_backups = {
+      backup1 = {
+        backup_name                   = "aks1"
+        retention_period              = "P30D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000001"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks1"
+        retention_rules = [
+          {
+            name              = "Decade"
+            duration          = "P10Y"
+            absolute_criteria = "FirstOfDecade"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.aks_cluster_backups,
+  ]
+}
+
+run "validate_retention_rules_name" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    aks_cluster_backups = {
+      backup1 = {
+        backup_name                   = "aks1"
+        retention_period              = "P30D"
+        backup_intervals              = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        cluster_id                    = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.ContainerService/managedClusters/aks-1"
+        cluster_identity_principal_id = "00000000-0000-0000-0000-000000000001"
+        snapshot_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        storage_account_id        = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/storageaccount1"
+        storage_account_container = "aks1"
+        retention_rules = [
+          {
+            name              = "Default"
+            duration          = "P4W"
+            absolute_criteria = "FirstOfWeek"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.aks_cluster_backups,
+  ]
+}
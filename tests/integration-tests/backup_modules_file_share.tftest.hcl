@@ -0,0 +1,503 @@
+mock_provider "azurerm" {
+  source = "./azurerm"
+}
+
+run "setup_tests" {
+  module {
+    source = "./setup"
+  }
+}
+
+run "create_file_share_backup" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P7D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+      }
+      backup2 = {
+        backup_name        = "share2"
+        retention_period   = "P3D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-2"
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.file_share_backup) == 2
+    error_message = "Number of backup modules not as expected."
+  }
+
+  assert {
+    condition     = length(azurerm_backup_container_storage_account.file_share) == 1
+    error_message = "Storage account should only be registered with the vault once."
+  }
+
+  assert {
+    condition     = azurerm_backup_container_storage_account.file_share["/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"].recovery_vault_name == azurerm_recovery_services_vault.recovery_services_vault[0].name
+    error_message = "Storage account container vault name not as expected."
+  }
+
+  assert {
+    condition     = length(module.file_share_backup["backup1"].backup_policy.id) > 0
+    error_message = "File share backup policy id not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].backup_policy.name == "bkpol-afs-share1"
+    error_message = "File share backup policy name not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].backup_policy.recovery_vault_name == azurerm_recovery_services_vault.recovery_services_vault[0].name
+    error_message = "File share backup policy vault name not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].backup_policy.backup[0].frequency == "Daily"
+    error_message = "File share backup policy frequency not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].backup_policy.retention_daily[0].count == 7
+    error_message = "File share backup policy retention period not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].protected_item.source_storage_account_id == var.file_share_backups["backup1"].storage_account_id
+    error_message = "File share protected item storage account id not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].protected_item.source_file_share_name == "share-1"
+    error_message = "File share protected item file share name not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].protected_item.backup_policy_id == module.file_share_backup["backup1"].backup_policy.id
+    error_message = "File share protected item backup policy id not as expected."
+  }
+
+  assert {
+    condition     = length(module.file_share_backup["backup2"].backup_policy.id) > 0
+    error_message = "File share backup policy id not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup2"].backup_policy.name == "bkpol-afs-share2"
+    error_message = "File share backup policy name not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup2"].backup_policy.recovery_vault_name == azurerm_recovery_services_vault.recovery_services_vault[0].name
+    error_message = "File share backup policy vault name not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup2"].backup_policy.backup[0].frequency == "Daily"
+    error_message = "File share backup policy frequency not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup2"].backup_policy.retention_daily[0].count == 3
+    error_message = "File share backup policy retention period not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup2"].protected_item.source_storage_account_id == var.file_share_backups["backup2"].storage_account_id
+    error_message = "File share protected item storage account id not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup2"].protected_item.source_file_share_name == "share-2"
+    error_message = "File share protected item file share name not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup2"].protected_item.backup_policy_id == module.file_share_backup["backup2"].backup_policy.id
+    error_message = "File share protected item backup policy id not as expected."
+  }
+}
+
+run "validate_recovery_services_vault_name" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P7D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.file_share_backups,
+  ]
+}
+
+run "validate_retention_period" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P30D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.file_share_backups,
+  ]
+}
+
+run "validate_retention_period_with_extended_retention" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    use_extended_retention       = true
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P30D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.file_share_backup) == 1
+    error_message = "Number of backup modules not as expected."
+  }
+}
+
+run "validate_backup_intervals" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P7D"
+        backup_intervals   = []
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.file_share_backups,
+  ]
+}
+
+run "validate_backup_intervals_single_interval" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P7D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/P1D", "R/2024-01-01T12:00:00+00:00/P1D"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.file_share_backups,
+  ]
+}
+
+run "validate_backup_intervals_invalid_frequency" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P7D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/PT4H"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.file_share_backups,
+  ]
+}
+
+run "create_file_share_backup_with_retention_rules" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    use_extended_retention       = true
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P7D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+        retention_rules = {
+          weekly = {
+            count    = 4
+            weekdays = ["Sunday"]
+          }
+          monthly = {
+            count    = 12
+            weekdays = ["Sunday"]
+            weeks    = ["First"]
+          }
+          yearly = {
+            count    = 5
+            weekdays = ["Sunday"]
+            weeks    = ["First"]
+            months   = ["January"]
+          }
+        }
+      }
+    }
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].backup_policy.retention_weekly[0].count == 4
+    error_message = "File share backup policy weekly retention not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].backup_policy.retention_weekly[0].weekdays == toset(["Sunday"])
+    error_message = "File share backup policy weekly retention days not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].backup_policy.retention_monthly[0].count == 12
+    error_message = "File share backup policy monthly retention not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].backup_policy.retention_monthly[0].weeks == toset(["First"])
+    error_message = "File share backup policy monthly retention weeks not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].backup_policy.retention_yearly[0].count == 5
+    error_message = "File share backup policy yearly retention not as expected."
+  }
+
+  assert {
+    condition     = module.file_share_backup["backup1"].backup_policy.retention_yearly[0].months == toset(["January"])
+    error_message = "File share backup policy yearly retention months not as expected."
+  }
+}
+
+run "validate_retention_rules_without_extended_retention" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P7D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+        retention_rules = {
+          weekly = {
+            count    = 4
+            weekdays = ["Sunday"]
+          }
+        }
+      }
+    }
+  }
+
+  expect_failures = [
+    var.file_share_backups,
+  ]
+}
+
+run "validate_retention_rules_count" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    use_extended_retention       = true
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P7D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+        retention_rules = {
+          weekly = {
+            count    = 201
+            weekdays = ["Sunday"]
+          }
+        }
+      }
+    }
+  }
+
+  expect_failures = [
+    var.file_share_backups,
+  ]
+}
+
+run "validate_retention_rules_schedule" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    use_extended_retention       = true
+    file_share_backups = {
+      backup1 = {
+        backup_name        = "share1"
+        retention_period   = "P7D"
+        backup_intervals   = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        storage_account_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Storage/storageAccounts/sastorage1"
+        file_share_name    = "share-1"
+        retention_rules = {
+          monthly = {
+            count    = 12
+            weekdays = ["Sunday"]
+            weeks    = ["Fifth"]
+          }
+        }
+      }
+    }
+  }
+
+  expect_failures = [
+    var.file_share_backups,
+  ]
+}
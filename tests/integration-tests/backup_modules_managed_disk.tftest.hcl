@@ -332,3 +332,188 @@ run "validate_backup_intervals_invalid_structure" {
     var.managed_disk_backups,
   ]
 }
+
+run "create_managed_disk_backup_with_retention_rules" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    managed_disk_backups = {
+      backup1 = {
+        backup_name      = "disk1"
+        retention_period = "P30D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        managed_disk_id  = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/disks/disk-1"
+        managed_disk_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        retention_rules = [
+          {
+            name              = "Weekly"
+            duration          = "P4W"
+            absolute_criteria = "FirstOfWeek"
+          },
+          {
+            name              = "Daily"
+            duration          = "P14D"
+            absolute_criteria = "FirstOfDay"
+          },
+        ]
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.managed_disk_backup["backup1"].backup_policy.retention_rule) == 2
+    error_message = "Managed disk backup policy retention rules not as expected."
+  }
+
+  assert {
+    condition     = module.managed_disk_backup["backup1"].backup_policy.retention_rule[0].name == "Weekly"
+    error_message = "Managed disk backup policy retention rule name not as expected."
+  }
+
+  assert {
+    condition     = module.managed_disk_backup["backup1"].backup_policy.retention_rule[0].criteria[0].absolute_criteria == "FirstOfWeek"
+    error_message = "Managed disk backup policy retention rule criteria not as expected."
+  }
+
+  assert {
+    condition     = module.managed_disk_backup["backup1"].backup_policy.retention_rule[1].duration == "P14D"
+    error_message = "Managed disk backup policy retention rule duration not as expected."
+  }
+
+  assert {
+    condition     = module.managed_disk_backup["backup1"].backup_policy.retention_rule[1].priority == 2
+    error_message = "Managed disk backup policy retention rule priority not as expected."
+  }
+}
+
+run "validate_retention_rules_duration" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    managed_disk_backups = {
+      backup1 = {
+        backup_name      = "disk1"
+        retention_period = "P7D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        managed_disk_id  = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/disks/disk-1"
+        managed_disk_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        retention_rules = [
+          {
+            name              = "Daily"
+            duration          = "P14D"
+            absolute_criteria = "FirstOfDay"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.managed_disk_backups,
+  ]
+}
+
+run "validate_retention_rules_criteria" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    managed_disk_backups = {
+      backup1 = {
+        backup_name      = "disk1"
+        retention_period = "P30D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        managed_disk_id  = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/disks/disk-1"
+        managed_disk_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        retention_rules = [
+          {
+            name              = "Monthly"
+            duration          = "P12M"
+            absolute_criteria = "FirstOfMonth"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.managed_disk_backups,
+  ]
+}
+
+run "validate_retention_rules_name" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    managed_disk_backups = {
+      backup1 = {
+        backup_name      = "disk1"
+        retention_period = "P30D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        managed_disk_id  = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/disks/disk-1"
+        managed_disk_resource_group = {
+          id   = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+          name = "example-resource-group1"
+        }
+        retention_rules = [
+          {
+            name              = "Default"
+            duration          = "P14D"
+            absolute_criteria = "FirstOfDay"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.managed_disk_backups,
+  ]
+}
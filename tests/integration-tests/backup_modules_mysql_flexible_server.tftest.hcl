@@ -0,0 +1,481 @@
+mock_provider "azurerm" {
+  source = "./azurerm"
+}
+
+run "setup_tests" {
+  module {
+    source = "./setup"
+  }
+}
+
+run "create_mysql_flexible_server_backup" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    mysql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P1D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+      }
+      backup2 = {
+        backup_name              = "server2"
+        retention_period         = "P7D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-2"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group2"
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.mysql_flexible_server_backup) == 2
+    error_message = "Number of backup modules not as expected."
+  }
+
+  assert {
+    condition     = length(module.mysql_flexible_server_backup["backup1"].backup_policy.id) > 0
+    error_message = "Mysql flexible server backup policy id not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_policy.name == "bkpol-mysqlflex-server1"
+    error_message = "Mysql flexible server backup policy name not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_policy.vault_id == azurerm_data_protection_backup_vault.backup_vault.id
+    error_message = "Mysql flexible server backup policy vault id not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_policy.default_retention_rule[0].life_cycle[0].duration == "P1D"
+    error_message = "Mysql flexible server backup policy retention period not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_policy.backup_repeating_time_intervals[0] == "R/2024-01-01T00:00:00+00:00/P1W"
+    error_message = "Mysql flexible server backup policy backup intervals not as expected."
+  }
+
+  assert {
+    condition     = length(module.mysql_flexible_server_backup["backup1"].backup_instance.id) > 0
+    error_message = "Mysql flexible server backup instance id not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_instance.name == "bkinst-mysqlflex-server1"
+    error_message = "Mysql flexible server backup instance name not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_instance.vault_id == azurerm_data_protection_backup_vault.backup_vault.id
+    error_message = "Mysql flexible server backup instance vault id not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_instance.location == azurerm_data_protection_backup_vault.backup_vault.location
+    error_message = "Mysql flexible server backup instance location not as expected."
+  }
+
+  assert {
+    condition     = length(module.mysql_flexible_server_backup["backup1"].backup_instance.server_id) > 0
+    error_message = "Mysql flexible server backup instance server id not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_instance.backup_policy_id == module.mysql_flexible_server_backup["backup1"].backup_policy.id
+    error_message = "Mysql flexible server backup instance backup policy id not as expected."
+  }
+
+  assert {
+    condition     = length(module.mysql_flexible_server_backup["backup2"].backup_policy.id) > 0
+    error_message = "Mysql flexible server backup policy id not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup2"].backup_policy.name == "bkpol-mysqlflex-server2"
+    error_message = "Mysql flexible server backup policy name not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup2"].backup_policy.vault_id == azurerm_data_protection_backup_vault.backup_vault.id
+    error_message = "Mysql flexible server backup policy vault id not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup2"].backup_policy.default_retention_rule[0].life_cycle[0].duration == "P7D"
+    error_message = "Mysql flexible server backup policy retention period not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup2"].backup_policy.backup_repeating_time_intervals[0] == "R/2024-01-01T00:00:00+00:00/P1W"
+    error_message = "Mysql flexible server backup policy backup intervals not as expected."
+  }
+
+  assert {
+    condition     = length(module.mysql_flexible_server_backup["backup2"].backup_instance.id) > 0
+    error_message = "Mysql flexible server backup instance id not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup2"].backup_instance.name == "bkinst-mysqlflex-server2"
+    error_message = "Mysql flexible server backup instance name not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup2"].backup_instance.vault_id == azurerm_data_protection_backup_vault.backup_vault.id
+    error_message = "Mysql flexible server backup instance vault id not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup2"].backup_instance.location == azurerm_data_protection_backup_vault.backup_vault.location
+    error_message = "Mysql flexible server backup instance location not as expected."
+  }
+
+  assert {
+    condition     = length(module.mysql_flexible_server_backup["backup2"].backup_instance.server_id) > 0
+    error_message = "Mysql flexible server backup instance server id not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup2"].backup_instance.backup_policy_id == module.mysql_flexible_server_backup["backup2"].backup_policy.id
+    error_message = "Mysql flexible server backup instance backup policy id not as expected."
+  }
+}
+
+run "validate_retention_period" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    mysql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P30D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.mysql_flexible_server_backups,
+  ]
+}
+
+run "validate_retention_period_with_extended_retention" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    mysql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P30D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.mysql_flexible_server_backup) == 1
+    error_message = "Number of backup modules not as expected."
+  }
+}
+
+run "validate_backup_intervals" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    mysql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P7D"
+        backup_intervals         = []
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.mysql_flexible_server_backups,
+  ]
+}
+
+run "validate_backup_intervals_invalid_frequency" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    mysql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P7D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.mysql_flexible_server_backups,
+  ]
+}
+
+run "validate_backup_intervals_invalid_structure" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    mysql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P7D"
+        backup_intervals         = ["P1D", "R/bad-date/P1D", "R/2024-01-01T00:00:00+00:00/PT10H"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.mysql_flexible_server_backups,
+  ]
+}
+
+run "create_mysql_flexible_server_backup_with_retention_rules" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    mysql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P30D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+        retention_rules = [
+          {
+            name              = "Yearly"
+            duration          = "P10Y"
+            absolute_criteria = "FirstOfYear"
+          },
+          {
+            name              = "Monthly"
+            duration          = "P12M"
+            absolute_criteria = "FirstOfMonth"
+          },
+          {
+            name              = "Weekly"
+            duration          = "P4W"
+            absolute_criteria = "FirstOfWeek"
+          },
+        ]
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.mysql_flexible_server_backup["backup1"].backup_policy.retention_rule) == 3
+    error_message = "Mysql flexible server backup policy retention rules not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_policy.retention_rule[0].name == "Yearly"
+    error_message = "Mysql flexible server backup policy retention rule name not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_policy.retention_rule[0].criteria[0].absolute_criteria == "FirstOfYear"
+    error_message = "Mysql flexible server backup policy retention rule criteria not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_policy.retention_rule[2].life_cycle[0].duration == "P4W"
+    error_message = "Mysql flexible server backup policy retention rule duration not as expected."
+  }
+
+  assert {
+    condition     = module.mysql_flexible_server_backup["backup1"].backup_policy.retention_rule[2].priority == 3
+    error_message = "Mysql flexible server backup policy retention rule priority not as expected."
+  }
+}
+
+run "validate_retention_rules_duration" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    mysql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P7D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+        retention_rules = [
+          {
+            name              = "Weekly"
+            duration          = "P4W"
+            absolute_criteria = "FirstOfWeek"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.mysql_flexible_server_backups,
+  ]
+}
+
+run "validate_retention_rules_criteria" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    mysql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P30D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+        retention_rules = [
+          {
+            name              = "Decade"
+            duration          = "P10Y"
+            absolute_criteria = "FirstOfDecade"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.mysql_flexible_server_backups,
+  ]
+}
+
+run "validate_retention_rules_name" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    mysql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P30D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforMySQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+        retention_rules = [
+          {
+            name              = "Default"
+            duration          = "P4W"
+            absolute_criteria = "FirstOfWeek"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.mysql_flexible_server_backups,
+  ]
+}
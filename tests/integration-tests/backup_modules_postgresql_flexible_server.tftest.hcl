@@ -301,3 +301,181 @@ run "validate_backup_intervals_invalid_structure" {
     var.postgresql_flexible_server_backups,
   ]
 }
+
+run "create_postgresql_flexible_server_backup_with_retention_rules" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    postgresql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P30D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforPostgreSQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+        retention_rules = [
+          {
+            name              = "Yearly"
+            duration          = "P10Y"
+            absolute_criteria = "FirstOfYear"
+          },
+          {
+            name              = "Monthly"
+            duration          = "P12M"
+            absolute_criteria = "FirstOfMonth"
+          },
+          {
+            name              = "Weekly"
+            duration          = "P4W"
+            absolute_criteria = "FirstOfWeek"
+          },
+        ]
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.postgresql_flexible_server_backup["backup1"].backup_policy.retention_rule) == 3
+    error_message = "Postgresql flexible server backup policy retention rules not as expected."
+  }
+
+  assert {
+    condition     = module.postgresql_flexible_server_backup["backup1"].backup_policy.retention_rule[0].name == "Yearly"
+    error_message = "Postgresql flexible server backup policy retention rule name not as expected."
+  }
+
+  assert {
+    condition     = module.postgresql_flexible_server_backup["backup1"].backup_policy.retention_rule[0].criteria[0].absolute_criteria == "FirstOfYear"
+    error_message = "Postgresql flexible server backup policy retention rule criteria not as expected."
+  }
+
+  assert {
+    condition     = module.postgresql_flexible_server_backup["backup1"].backup_policy.retention_rule[2].life_cycle[0].duration == "P4W"
+    error_message = "Postgresql flexible server backup policy retention rule duration not as expected."
+  }
+
+  assert {
+    condition     = module.postgresql_flexible_server_backup["backup1"].backup_policy.retention_rule[2].priority == 3
+    error_message = "Postgresql flexible server backup policy retention rule priority not as expected."
+  }
+}
+
+run "validate_retention_rules_duration" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    postgresql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P7D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforPostgreSQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+        retention_rules = [
+          {
+            name              = "Weekly"
+            duration          = "P4W"
+            absolute_criteria = "FirstOfWeek"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.postgresql_flexible_server_backups,
+  ]
+}
+
+run "validate_retention_rules_criteria" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    postgresql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P30D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforPostgreSQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+        retention_rules = [
+          {
+            name              = "Decade"
+            duration          = "P10Y"
+            absolute_criteria = "FirstOfDecade"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.postgresql_flexible_server_backups,
+  ]
+}
+
+run "validate_retention_rules_name" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    use_extended_retention     = true
+    postgresql_flexible_server_backups = {
+      backup1 = {
+        backup_name              = "server1"
+        retention_period         = "P30D"
+        backup_intervals         = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        server_id                = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.DBforPostgreSQL/flexibleServers/server-1"
+        server_resource_group_id = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group1"
+        retention_rules = [
+          {
+            name              = "Default"
+            duration          = "P4W"
+            absolute_criteria = "FirstOfWeek"
+          },
+        ]
+      }
+    }
+  }
+
+  expect_failures = [
+    var.postgresql_flexible_server_backups,
+  ]
+}
@@ -0,0 +1,510 @@
+mock_provider "azurerm" {
+  source = "./azurerm"
+}
+
+run "setup_tests" {
+  module {
+    source = "./setup"
+  }
+}
+
+run "create_virtual_machine_backup" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P7D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+      }
+      backup2 = {
+        backup_name      = "vm2"
+        retention_period = "P7D"
+        backup_intervals = ["R/2024-01-01T22:30:00+01:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-2"
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.virtual_machine_backup) == 2
+    error_message = "Number of backup modules not as expected."
+  }
+
+  assert {
+    condition     = length(module.virtual_machine_backup["backup1"].backup_policy.id) > 0
+    error_message = "Virtual machine backup policy id not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.name == "bkpol-vm-vm1"
+    error_message = "Virtual machine backup policy name not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.recovery_vault_name == azurerm_recovery_services_vault.recovery_services_vault[0].name
+    error_message = "Virtual machine backup policy vault name not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.backup[0].frequency == "Daily"
+    error_message = "Virtual machine backup policy frequency not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.backup[0].time == "00:00"
+    error_message = "Virtual machine backup policy time not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.retention_daily[0].count == 7
+    error_message = "Virtual machine backup policy retention period not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].protected_item.source_vm_id == var.vm_backups["backup1"].vm_id
+    error_message = "Virtual machine protected item source vm id not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].protected_item.backup_policy_id == module.virtual_machine_backup["backup1"].backup_policy.id
+    error_message = "Virtual machine protected item backup policy id not as expected."
+  }
+
+  assert {
+    condition     = length(module.virtual_machine_backup["backup2"].backup_policy.id) > 0
+    error_message = "Virtual machine backup policy id not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup2"].backup_policy.name == "bkpol-vm-vm2"
+    error_message = "Virtual machine backup policy name not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup2"].backup_policy.recovery_vault_name == azurerm_recovery_services_vault.recovery_services_vault[0].name
+    error_message = "Virtual machine backup policy vault name not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup2"].backup_policy.backup[0].frequency == "Daily"
+    error_message = "Virtual machine backup policy frequency not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup2"].backup_policy.backup[0].time == "21:30"
+    error_message = "Virtual machine backup policy time not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup2"].backup_policy.retention_daily[0].count == 7
+    error_message = "Virtual machine backup policy retention period not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup2"].protected_item.source_vm_id == var.vm_backups["backup2"].vm_id
+    error_message = "Virtual machine protected item source vm id not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup2"].protected_item.backup_policy_id == module.virtual_machine_backup["backup2"].backup_policy.id
+    error_message = "Virtual machine protected item backup policy id not as expected."
+  }
+}
+
+run "validate_recovery_services_vault_name" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P7D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.vm_backups,
+  ]
+}
+
+run "validate_retention_period" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P30D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.vm_backups,
+  ]
+}
+
+run "validate_minimum_retention_period" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P3D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.vm_backups,
+  ]
+}
+
+run "validate_retention_period_with_extended_retention" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    use_extended_retention       = true
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P30D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+      }
+    }
+  }
+
+  assert {
+    condition     = length(module.virtual_machine_backup) == 1
+    error_message = "Number of backup modules not as expected."
+  }
+}
+
+run "validate_backup_intervals" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P7D"
+        backup_intervals = []
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.vm_backups,
+  ]
+}
+
+run "validate_backup_intervals_single_interval" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P7D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D", "R/2024-01-01T12:00:00+00:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.vm_backups,
+  ]
+}
+
+run "validate_backup_intervals_invalid_frequency" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P7D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1W"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+      }
+    }
+  }
+
+  expect_failures = [
+    var.vm_backups,
+  ]
+}
+
+run "create_virtual_machine_backup_with_retention_rules" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    use_extended_retention       = true
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P7D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+        retention_rules = {
+          weekly = {
+            count    = 4
+            weekdays = ["Sunday"]
+          }
+          monthly = {
+            count    = 12
+            weekdays = ["Sunday"]
+            weeks    = ["First"]
+          }
+          yearly = {
+            count    = 5
+            weekdays = ["Sunday"]
+            weeks    = ["First"]
+            months   = ["January"]
+          }
+        }
+      }
+    }
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.retention_weekly[0].count == 4
+    error_message = "Virtual machine backup policy weekly retention not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.retention_weekly[0].weekdays == toset(["Sunday"])
+    error_message = "Virtual machine backup policy weekly retention days not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.retention_monthly[0].count == 12
+    error_message = "Virtual machine backup policy monthly retention not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.retention_monthly[0].weeks == toset(["First"])
+    error_message = "Virtual machine backup policy monthly retention weeks not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.retention_yearly[0].count == 5
+    error_message = "Virtual machine backup policy yearly retention not as expected."
+  }
+
+  assert {
+    condition     = module.virtual_machine_backup["backup1"].backup_policy.retention_yearly[0].months == toset(["January"])
+    error_message = "Virtual machine backup policy yearly retention months not as expected."
+  }
+}
+
+run "validate_retention_rules_without_extended_retention" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P7D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+        retention_rules = {
+          weekly = {
+            count    = 4
+            weekdays = ["Sunday"]
+          }
+        }
+      }
+    }
+  }
+
+  expect_failures = [
+    var.vm_backups,
+  ]
+}
+
+run "validate_retention_rules_count" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    use_extended_retention       = true
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P7D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+        retention_rules = {
+          weekly = {
+            count    = 5164
+            weekdays = ["Sunday"]
+          }
+        }
+      }
+    }
+  }
+
+  expect_failures = [
+    var.vm_backups,
+  ]
+}
+
+run "validate_retention_rules_schedule" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name          = run.setup_tests.resource_group_name
+    resource_group_location      = "uksouth"
+    backup_vault_name            = run.setup_tests.backup_vault_name
+    recovery_services_vault_name = "rsvault-${run.setup_tests.backup_vault_name}"
+    log_analytics_workspace_id   = run.setup_tests.log_analytics_workspace_id
+    tags                         = run.setup_tests.tags
+    use_extended_retention       = true
+    vm_backups = {
+      backup1 = {
+        backup_name      = "vm1"
+        retention_period = "P7D"
+        backup_intervals = ["R/2024-01-01T00:00:00+00:00/P1D"]
+        vm_id            = "/subscriptions/12345678-1234-9876-4563-123456789012/resourceGroups/example-resource-group/providers/Microsoft.Compute/virtualMachines/vm-1"
+        retention_rules = {
+          monthly = {
+            count    = 12
+            weekdays = ["Sunday"]
+            weeks    = ["Fifth"]
+          }
+        }
+      }
+    }
+  }
+
+  expect_failures = [
+    var.vm_backups,
+  ]
+}
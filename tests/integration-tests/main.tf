@@ -2,7 +2,7 @@ terraform {
   required_providers {
     azurerm = {
       source  = "hashicorp/azurerm"
-      version = ">= 4.18.0, < 5.1"
+      version = ">= 4.37.0, < 5.1"
     }
   }
 }
@@ -0,0 +1,194 @@
+mock_provider "azurerm" {
+  source = "./azurerm"
+}
+
+run "setup_tests" {
+  module {
+    source = "./setup"
+  }
+}
+
+run "no_recovery_services_vault_by_default" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name        = run.setup_tests.resource_group_name
+    resource_group_location    = "uksouth"
+    backup_vault_name          = run.setup_tests.backup_vault_name
+    log_analytics_workspace_id = run.setup_tests.log_analytics_workspace_id
+    tags                       = run.setup_tests.tags
+  }
+
+  assert {
+    condition     = length(azurerm_recovery_services_vault.recovery_services_vault) == 0
+    error_message = "Recovery services vault should not be created without a name."
+  }
+
+  assert {
+    condition     = length(azurerm_monitor_diagnostic_setting.recovery_services_vault) == 0
+    error_message = "Recovery services vault diagnostic setting should not be created without a vault."
+  }
+
+  assert {
+    condition     = output.recovery_services_vault == null
+    error_message = "Recovery services vault output not as expected."
+  }
+}
+
+run "create_recovery_services_vault" {
+  command = apply
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name                  = run.setup_tests.resource_group_name
+    resource_group_location              = "uksouth"
+    backup_vault_name                    = run.setup_tests.backup_vault_name
+    recovery_services_vault_name         = "rsvault-${run.setup_tests.backup_vault_name}"
+    recovery_services_vault_redundancy   = "GeoRedundant"
+    recovery_services_vault_immutability = "Unlocked"
+    recovery_services_vault_soft_delete  = "On"
+    log_analytics_workspace_id           = run.setup_tests.log_analytics_workspace_id
+    tags                                 = run.setup_tests.tags
+  }
+
+  assert {
+    condition     = azurerm_recovery_services_vault.recovery_services_vault[0].name == var.recovery_services_vault_name
+    error_message = "Recovery services vault name not as expected."
+  }
+
+  assert {
+    condition     = azurerm_recovery_services_vault.recovery_services_vault[0].resource_group_name == local.resource_group.name
+    error_message = "Resource group not as expected."
+  }
+
+  assert {
+    condition     = azurerm_recovery_services_vault.recovery_services_vault[0].location == local.resource_group.location
+    error_message = "Recovery services vault location not as expected."
+  }
+
+  assert {
+    condition     = azurerm_recovery_services_vault.recovery_services_vault[0].sku == "Standard"
+    error_message = "Recovery services vault sku not as expected."
+  }
+
+  assert {
+    condition     = azurerm_recovery_services_vault.recovery_services_vault[0].storage_mode_type == var.recovery_services_vault_redundancy
+    error_message = "Recovery services vault redundancy not as expected."
+  }
+
+  assert {
+    condition     = azurerm_recovery_services_vault.recovery_services_vault[0].immutability == var.recovery_services_vault_immutability
+    error_message = "Recovery services vault immutability not as expected."
+  }
+
+  assert {
+    condition     = azurerm_recovery_services_vault.recovery_services_vault[0].soft_delete_enabled == true
+    error_message = "Recovery services vault soft delete not as expected."
+  }
+
+  assert {
+    condition     = length(azurerm_recovery_services_vault.recovery_services_vault[0].identity[0].principal_id) > 0
+    error_message = "Recovery services vault identity not as expected."
+  }
+
+  assert {
+    condition = alltrue([
+      for tag_key, tag_value in run.setup_tests.tags :
+      lookup(azurerm_recovery_services_vault.recovery_services_vault[0].tags, tag_key, null) == tag_value
+    ])
+    error_message = "Tags not as expected."
+  }
+
+  assert {
+    condition     = azurerm_monitor_diagnostic_setting.recovery_services_vault[0].target_resource_id == azurerm_recovery_services_vault.recovery_services_vault[0].id
+    error_message = "Recovery services vault diagnostic setting target resource id not as expected."
+  }
+
+  assert {
+    condition     = length(azurerm_monitor_diagnostic_setting.recovery_services_vault[0].enabled_log) == length(local.backup_vault_diagnostics_log_categories)
+    error_message = "Recovery services vault diagnostic setting enabled logs not as expected."
+  }
+
+  assert {
+    condition     = length(azurerm_monitor_diagnostic_setting.recovery_services_vault[0].enabled_metric) == length(local.backup_vault_diagnostics_metric_categories)
+    error_message = "Recovery services vault diagnostic setting metrics not as expected."
+  }
+
+  assert {
+    condition     = output.recovery_services_vault.id == azurerm_recovery_services_vault.recovery_services_vault[0].id
+    error_message = "Recovery services vault output not as expected."
+  }
+}
+
+run "validate_soft_delete" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name                 = run.setup_tests.resource_group_name
+    resource_group_location             = "uksouth"
+    backup_vault_name                   = run.setup_tests.backup_vault_name
+    recovery_services_vault_name        = "rsvault-${run.setup_tests.backup_vault_name}"
+    recovery_services_vault_soft_delete = "AlwaysOn"
+    log_analytics_workspace_id          = run.setup_tests.log_analytics_workspace_id
+    tags                                = run.setup_tests.tags
+  }
+
+  expect_failures = [
+    var.recovery_services_vault_soft_delete,
+  ]
+}
+
+run "validate_redundancy" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name                = run.setup_tests.resource_group_name
+    resource_group_location            = "uksouth"
+    backup_vault_name                  = run.setup_tests.backup_vault_name
+    recovery_services_vault_name       = "rsvault-${run.setup_tests.backup_vault_name}"
+    recovery_services_vault_redundancy = "ReadAccessGeoRedundant"
+    log_analytics_workspace_id         = run.setup_tests.log_analytics_workspace_id
+    tags                               = run.setup_tests.tags
+  }
+
+  expect_failures = [
+    var.recovery_services_vault_redundancy,
+  ]
+}
+
+run "validate_immutability" {
+  command = plan
+
+  module {
+    source = "../../infrastructure"
+  }
+
+  variables {
+    resource_group_name                  = run.setup_tests.resource_group_name
+    resource_group_location              = "uksouth"
+    backup_vault_name                    = run.setup_tests.backup_vault_name
+    recovery_services_vault_name         = "rsvault-${run.setup_tests.backup_vault_name}"
+    recovery_services_vault_immutability = "Enabled"
+    log_analytics_workspace_id           = run.setup_tests.log_analytics_workspace_id
+    tags                                 = run.setup_tests.tags
+  }
+
+  expect_failures = [
+    var.recovery_services_vault_immutability,
+  ]
+}